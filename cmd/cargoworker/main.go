@@ -19,7 +19,8 @@ func main() {
 	bus := project.NewEventBus(1024)
 	defer bus.Close()
 
-	bootstrap := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	// stdout is reserved for command output (reports, exports); logs go to stderr.
+	bootstrap := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelInfo}))
 	bootstrap.Info("cargoworker starting", "pid", os.Getpid())
 
 	cmd := cli.NewRootCmd(cli.Deps{
@@ -0,0 +1,382 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/ChaseHampton/cargoworker/internal/emit"
+	"github.com/ChaseHampton/cargoworker/internal/ir"
+	"github.com/ChaseHampton/cargoworker/internal/project"
+	"github.com/ChaseHampton/cargoworker/internal/store"
+)
+
+func NewEmitCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "emit",
+		Short: "Export a run to other formats, or import one back",
+	}
+	cmd.AddCommand(newEmitJSONLCmd())
+	cmd.AddCommand(newEmitHTMLCmd())
+	cmd.AddCommand(newEmitMarkdownCmd())
+	cmd.AddCommand(newEmitGraphCmd())
+	cmd.AddCommand(newEmitSCIPCmd())
+	cmd.AddCommand(newEmitImportCmd())
+	return cmd
+}
+
+func newEmitJSONLCmd() *cobra.Command {
+	var fOutput string
+
+	cmd := &cobra.Command{
+		Use:               "jsonl <run-dir>",
+		Short:             "Write every IR record of a run as tagged JSON lines",
+		Args:              cobra.ExactArgs(1),
+		PersistentPreRunE: inspectPreRun,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rc := project.FromContext(cmd.Context())
+			if rc == nil {
+				return fmt.Errorf("internal: run context unavailable")
+			}
+
+			w, done, err := openOutput(cmd, viper.GetString("emit.jsonl.output"))
+			if err != nil {
+				return err
+			}
+			n, err := emit.WriteJSONL(cmd.Context(), rc.DB, w)
+			if err := done(err); err != nil {
+				return fmt.Errorf("emit jsonl: %w", err)
+			}
+			rc.Logger.Info("emit jsonl completed", "records", n)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&fOutput, "output", "o", "-", "output file (- for stdout)")
+
+	// Viper bindings (env keys: CARGOWORKER_EMIT_JSONL_OUTPUT)
+	_ = viper.BindPFlag("emit.jsonl.output", cmd.Flags().Lookup("output"))
+	viper.SetDefault("emit.jsonl.output", "-")
+
+	return cmd
+}
+
+func newEmitHTMLCmd() *cobra.Command {
+	var fOutput string
+
+	cmd := &cobra.Command{
+		Use:               "html <run-dir>",
+		Short:             "Render a run as a static, offline HTML site",
+		Args:              cobra.ExactArgs(1),
+		PersistentPreRunE: inspectPreRun,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rc := project.FromContext(cmd.Context())
+			if rc == nil {
+				return fmt.Errorf("internal: run context unavailable")
+			}
+			dir, err := outputPath(rc, viper.GetString("emit.html.output"), "html")
+			if err != nil {
+				return err
+			}
+
+			res, err := emit.WriteHTML(cmd.Context(), rc.DB, dir)
+			if err != nil {
+				return fmt.Errorf("emit html: %w", err)
+			}
+			rc.Logger.Info("emit html completed", "dir", dir,
+				"pages", res.Pages, "symbols", res.Symbols, "indexed", res.Indexed)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&fOutput, "output", "o", "", "output directory (default: <run-dir>-html beside the run directory)")
+
+	// Viper bindings (env keys: CARGOWORKER_EMIT_HTML_OUTPUT)
+	_ = viper.BindPFlag("emit.html.output", cmd.Flags().Lookup("output"))
+	viper.SetDefault("emit.html.output", "")
+
+	return cmd
+}
+
+func newEmitMarkdownCmd() *cobra.Command {
+	var fOutput string
+
+	cmd := &cobra.Command{
+		Use:               "markdown <run-dir>",
+		Aliases:           []string{"md"},
+		Short:             "Write one Markdown file per container",
+		Args:              cobra.ExactArgs(1),
+		PersistentPreRunE: inspectPreRun,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rc := project.FromContext(cmd.Context())
+			if rc == nil {
+				return fmt.Errorf("internal: run context unavailable")
+			}
+			dir, err := outputPath(rc, viper.GetString("emit.markdown.output"), "markdown")
+			if err != nil {
+				return err
+			}
+
+			res, err := emit.WriteMarkdown(cmd.Context(), rc.DB, dir)
+			if err != nil {
+				return fmt.Errorf("emit markdown: %w", err)
+			}
+			rc.Logger.Info("emit markdown completed", "dir", dir, "files", res.Files, "symbols", res.Symbols)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&fOutput, "output", "o", "", "output directory (default: <run-dir>-markdown beside the run directory)")
+
+	// Viper bindings (env keys: CARGOWORKER_EMIT_MARKDOWN_OUTPUT)
+	_ = viper.BindPFlag("emit.markdown.output", cmd.Flags().Lookup("output"))
+	viper.SetDefault("emit.markdown.output", "")
+
+	return cmd
+}
+
+func newEmitGraphCmd() *cobra.Command {
+	var (
+		fOutput, fFormat, fRoot, fDirection string
+		fKinds                              []string
+		fDepth                              int
+		fCollapse                           bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "graph <run-dir>",
+		Short: "Draw the relation graph of a run as DOT, Mermaid or GraphML",
+		Long: `Draw the relation graph of a run as DOT, Mermaid or GraphML.
+
+Nodes are symbols grouped by package, or packages with --collapse, where
+relations between symbols are counted per package pair and pkg_import rows
+between packages of the run become "imports" edges. For example:
+
+  cargoworker emit graph RUN --collapse --kinds imports
+  cargoworker emit graph RUN --kinds implements --root example.com/mod.Reader --depth 1`,
+		Args:              cobra.ExactArgs(1),
+		PersistentPreRunE: inspectPreRun,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rc := project.FromContext(cmd.Context())
+			if rc == nil {
+				return fmt.Errorf("internal: run context unavailable")
+			}
+			format, err := emit.ParseGraphFormat(viper.GetString("emit.graph.format"))
+			if err != nil {
+				return err
+			}
+			dir := viper.GetString("emit.graph.direction")
+			switch dir {
+			case emit.DirOut, emit.DirIn, emit.DirBoth:
+			default:
+				return fmt.Errorf("unknown direction %q (want out|in|both)", dir)
+			}
+			opts := emit.GraphOptions{
+				Format:    format,
+				Kinds:     viper.GetStringSlice("emit.graph.kinds"),
+				Root:      viper.GetString("emit.graph.root"),
+				Depth:     viper.GetInt("emit.graph.depth"),
+				Direction: dir,
+				Collapse:  viper.GetBool("emit.graph.collapse"),
+			}
+
+			w, done, err := openOutput(cmd, viper.GetString("emit.graph.output"))
+			if err != nil {
+				return err
+			}
+			res, err := emit.WriteGraph(cmd.Context(), rc.DB, w, opts)
+			if err := done(err); err != nil {
+				return fmt.Errorf("emit graph: %w", err)
+			}
+			rc.Logger.Info("emit graph completed", "format", format, "nodes", res.Nodes, "edges", res.Edges)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&fOutput, "output", "o", "-", "output file (- for stdout)")
+	cmd.Flags().StringVar(&fFormat, "format", "dot", "output format: dot|mermaid|graphml")
+	cmd.Flags().StringSliceVar(&fKinds, "kinds", nil, "edge kinds to keep, e.g. implements,imports (default: all)")
+	cmd.Flags().StringVar(&fRoot, "root", "", "start from this symbol or package full name")
+	cmd.Flags().IntVar(&fDepth, "depth", 2, "hops to follow from --root (0 for no limit)")
+	cmd.Flags().StringVar(&fDirection, "direction", emit.DirBoth, "edges followed from --root: out|in|both")
+	cmd.Flags().BoolVar(&fCollapse, "collapse", false, "draw packages instead of symbols")
+
+	// Viper bindings (env keys: CARGOWORKER_EMIT_GRAPH_FORMAT, _EMIT_GRAPH_KINDS, ...)
+	_ = viper.BindPFlag("emit.graph.output", cmd.Flags().Lookup("output"))
+	_ = viper.BindPFlag("emit.graph.format", cmd.Flags().Lookup("format"))
+	_ = viper.BindPFlag("emit.graph.kinds", cmd.Flags().Lookup("kinds"))
+	_ = viper.BindPFlag("emit.graph.root", cmd.Flags().Lookup("root"))
+	_ = viper.BindPFlag("emit.graph.depth", cmd.Flags().Lookup("depth"))
+	_ = viper.BindPFlag("emit.graph.direction", cmd.Flags().Lookup("direction"))
+	_ = viper.BindPFlag("emit.graph.collapse", cmd.Flags().Lookup("collapse"))
+	viper.SetDefault("emit.graph.output", "-")
+	viper.SetDefault("emit.graph.format", "dot")
+	viper.SetDefault("emit.graph.depth", 2)
+	viper.SetDefault("emit.graph.direction", emit.DirBoth)
+
+	return cmd
+}
+
+func newEmitSCIPCmd() *cobra.Command {
+	var fOutput string
+
+	cmd := &cobra.Command{
+		Use:               "scip <run-dir>",
+		Short:             "Write a SCIP index for code navigation tools",
+		Args:              cobra.ExactArgs(1),
+		PersistentPreRunE: inspectPreRun,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rc := project.FromContext(cmd.Context())
+			if rc == nil {
+				return fmt.Errorf("internal: run context unavailable")
+			}
+			path, err := outputPath(rc, viper.GetString("emit.scip.output"), "index.scip")
+			if err != nil {
+				return err
+			}
+
+			w, done, err := openOutput(cmd, path)
+			if err != nil {
+				return err
+			}
+			res, err := emit.WriteSCIP(cmd.Context(), rc.DB, w)
+			if err := done(err); err != nil {
+				return fmt.Errorf("emit scip: %w", err)
+			}
+			rc.Logger.Info("emit scip completed", "out", path, "documents", res.Documents,
+				"symbols", res.Symbols, "occurrences", res.Occurrences, "external", res.External)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&fOutput, "output", "o", "", "output file, - for stdout (default: <run-dir>-index.scip beside the run directory)")
+
+	// Viper bindings (env keys: CARGOWORKER_EMIT_SCIP_OUTPUT)
+	_ = viper.BindPFlag("emit.scip.output", cmd.Flags().Lookup("output"))
+	viper.SetDefault("emit.scip.output", "")
+
+	return cmd
+}
+
+func newEmitImportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "import <file.jsonl>",
+		Short: "Rebuild a finalized run database from emit jsonl output (- for stdin)",
+		Args:  cobra.ExactArgs(1),
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			in := args[0]
+			if in != "-" {
+				abs, err := filepath.Abs(in)
+				if err != nil {
+					return err
+				}
+				if fi, err := os.Stat(abs); err != nil || fi.IsDir() {
+					return fmt.Errorf("input file not found: %s", in)
+				}
+				in = abs
+			}
+			return startRun(cmd, in, nil)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rc := project.FromContext(cmd.Context())
+			if rc == nil {
+				return fmt.Errorf("internal: run context unavailable")
+			}
+			in := project.InputPathFrom(cmd.Context())
+
+			var r io.Reader = cmd.InOrStdin()
+			if in != "-" {
+				f, err := os.Open(in)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				r = f
+			}
+			p, frag, err := emit.ReadJSONL(r)
+			if err != nil {
+				return fmt.Errorf("read %s: %w", in, err)
+			}
+			rc.Logger.Info("import start", "run_id", rc.RunId, "in", in,
+				"containers", len(frag.Containers), "symbols", len(frag.Symbols))
+
+			if err := importRun(cmd, rc, p, frag); err != nil {
+				return fmt.Errorf("import failed: %w", err)
+			}
+			if err := finalizeRun(cmd, rc, in, store.KindSQLite, false); err != nil {
+				return fmt.Errorf("finalize failed: %w", err)
+			}
+			rc.Logger.Info("import completed", "out", rc.OutDir)
+			return nil
+		},
+	}
+	return cmd
+}
+
+// importRun writes an imported project and its records into the run database.
+// Unlike persistRun the project keeps its original identity.
+func importRun(cmd *cobra.Command, rc *project.RunContext, p *ir.Project, frag *ir.Fragment) error {
+	ctx := cmd.Context()
+	st := store.NewSQLite(rc.DB)
+	if err := st.Migrate(ctx); err != nil {
+		return fmt.Errorf("migrate: %w", err)
+	}
+	if err := st.WriteProject(ctx, p); err != nil {
+		return fmt.Errorf("write project: %w", err)
+	}
+	if err := st.WriteFragment(ctx, frag); err != nil {
+		return fmt.Errorf("write records: %w", err)
+	}
+	dropped, err := st.Flush(ctx)
+	if err != nil {
+		return fmt.Errorf("flush: %w", err)
+	}
+	if dropped > 0 {
+		rc.Logger.Warn("dropped unresolved references", "count", dropped)
+		rc.Stats.IncWarnings(int64(dropped))
+	}
+	return nil
+}
+
+// outputPath resolves an emitter's output file or directory: the flag value,
+// or <run-dir>-name beside the run directory when the run was given as a
+// directory. A finalized run directory is sealed, so nothing is written into
+// it. "-" is kept for emitters writing to stdout.
+func outputPath(rc *project.RunContext, flag, name string) (string, error) {
+	if flag == "-" {
+		return flag, nil
+	}
+	if flag != "" {
+		return filepath.Abs(flag)
+	}
+	if rc.OutDir == "" {
+		return "", fmt.Errorf("no run directory to write %s beside: pass --output", name)
+	}
+	return filepath.Clean(rc.OutDir) + "-" + name, nil
+}
+
+// openOutput returns stdout for "-" and a created file otherwise. done must be
+// called with the write error; it closes the file and removes it when the
+// write failed.
+func openOutput(cmd *cobra.Command, path string) (io.Writer, func(error) error, error) {
+	if path == "" || path == "-" {
+		return cmd.OutOrStdout(), func(err error) error { return err }, nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, func(err error) error {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			_ = os.Remove(path)
+		}
+		return err
+	}, nil
+}
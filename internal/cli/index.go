@@ -0,0 +1,262 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ChaseHampton/cargoworker/internal/db"
+	"github.com/ChaseHampton/cargoworker/internal/ir"
+	"github.com/ChaseHampton/cargoworker/internal/language"
+	"github.com/ChaseHampton/cargoworker/internal/manifest"
+	"github.com/ChaseHampton/cargoworker/internal/pack"
+	_ "github.com/ChaseHampton/cargoworker/internal/pack/cpp"
+	_ "github.com/ChaseHampton/cargoworker/internal/pack/csharp"
+	_ "github.com/ChaseHampton/cargoworker/internal/pack/java"
+	_ "github.com/ChaseHampton/cargoworker/internal/pack/proto"
+	_ "github.com/ChaseHampton/cargoworker/internal/pack/python"
+	_ "github.com/ChaseHampton/cargoworker/internal/pack/rust"
+	_ "github.com/ChaseHampton/cargoworker/internal/pack/shell"
+	_ "github.com/ChaseHampton/cargoworker/internal/pack/sql"
+	_ "github.com/ChaseHampton/cargoworker/internal/pack/treesitter" // registers the tree-sitter languages when built with cgo
+	_ "github.com/ChaseHampton/cargoworker/internal/pack/typescript"
+	"github.com/ChaseHampton/cargoworker/internal/plan"
+	"github.com/ChaseHampton/cargoworker/internal/project"
+	"github.com/ChaseHampton/cargoworker/internal/stats"
+	"github.com/ChaseHampton/cargoworker/internal/store"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func NewIndexCmd() *cobra.Command {
+	var (
+		fCompact     bool
+		fStore, fDSN string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "index",
+		Short: "Run the pipeline and write a finalized docdb.sqlite",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rc := project.FromContext(cmd.Context())
+			if rc == nil {
+				return fmt.Errorf("internal: run context unavailable")
+			}
+			in := project.InputPathFrom(cmd.Context())
+			if in == "" {
+				return fmt.Errorf("internal: input path not resolved")
+			}
+
+			compact := viper.GetBool("index.compact")
+			kind, err := store.ParseKind(viper.GetString("index.store"))
+			if err != nil {
+				return err
+			}
+			rc.Logger.Info("index start", "run_id", rc.RunId, "in", in, "compact", compact, "store", kind)
+
+			planStats := stats.NewPlan(in, []string{}, viper.GetStringSlice("plan.ignore"))
+			snap, err := plan.NewRunner(planStats).Plan(cmd.Context())
+			if err != nil {
+				return fmt.Errorf("plan failed: %w", err)
+			}
+			rc.Stats.SetPlan(snap)
+
+			if err := persistRun(cmd, rc, in, kind); err != nil {
+				return fmt.Errorf("persist failed: %w", err)
+			}
+
+			if err := finalizeRun(cmd, rc, in, kind, compact); err != nil {
+				return fmt.Errorf("finalize failed: %w", err)
+			}
+			rc.Logger.Info("index completed", "out", rc.OutDir)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&fCompact, "compact", false, "also write a VACUUM INTO copy ("+db.CompactFileName+") next to the database")
+	cmd.Flags().StringVar(&fStore, "store", "sqlite", "IR storage backend: sqlite|postgres")
+	cmd.Flags().StringVar(&fDSN, "dsn", "", "postgres connection string (required with --store=postgres)")
+
+	// Viper bindings (env keys: CARGOWORKER_INDEX_COMPACT, _INDEX_STORE, _INDEX_DSN)
+	_ = viper.BindPFlag("index.compact", cmd.Flags().Lookup("compact"))
+	_ = viper.BindPFlag("index.store", cmd.Flags().Lookup("store"))
+	_ = viper.BindPFlag("index.dsn", cmd.Flags().Lookup("dsn"))
+	viper.SetDefault("index.compact", false)
+	viper.SetDefault("index.store", "sqlite")
+	viper.SetDefault("index.dsn", "")
+
+	return cmd
+}
+
+// persistRun writes the run's IR through the selected store. With the sqlite
+// backend that is the run database itself; with postgres the run becomes one
+// project in the shared database. The run database is migrated either way:
+// extraction looks languages up in it and finalize seals it.
+func persistRun(cmd *cobra.Command, rc *project.RunContext, in string, kind store.Kind) error {
+	ctx := cmd.Context()
+
+	// A finalized run is never written again: its digest and counts would
+	// no longer match, and the run would hold the project twice.
+	sealed, err := db.Sealed(filepath.Join(rc.OutDir, db.FileName))
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(filepath.Join(rc.OutDir, manifest.FileName)); sealed || err == nil {
+		return fmt.Errorf("run %s in %s is already finalized: index with another --run-id", rc.RunId, rc.OutDir)
+	}
+
+	if err := db.RunMigrations(ctx, rc.DB); err != nil {
+		return fmt.Errorf("migrate run database: %w", err)
+	}
+	st, err := store.Open(ctx, store.Config{Kind: kind, DB: rc.DB, DSN: viper.GetString("index.dsn")})
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	if err := st.Migrate(ctx); err != nil {
+		return fmt.Errorf("migrate %s: %w", kind, err)
+	}
+	err = st.WriteProject(ctx, &ir.Project{
+		Id:          rc.RunId,
+		Name:        filepath.Base(in),
+		RootUri:     in,
+		ToolVersion: rc.ToolVersion,
+		IrSchema:    rc.IRSchema,
+		CreatedUtc:  rc.Stats.Run.StartedAt,
+	})
+	if err != nil {
+		return fmt.Errorf("write project: %w", err)
+	}
+	if err := extractRun(cmd, rc, st, in); err != nil {
+		return err
+	}
+
+	dropped, err := st.Flush(ctx)
+	if err != nil {
+		return fmt.Errorf("flush: %w", err)
+	}
+	if dropped > 0 {
+		rc.Logger.Warn("dropped unresolved references", "count", dropped)
+		rc.Stats.IncWarnings(int64(dropped))
+	}
+	return nil
+}
+
+// extractRun runs the language packs over the planned files, writing their
+// fragments to st.
+func extractRun(cmd *cobra.Command, rc *project.RunContext, st store.Store, in string) error {
+	ctx := cmd.Context()
+	if rc.PlanContext == nil {
+		return nil
+	}
+	langs := language.NewLanguageCache(rc.DB)
+	var files []string
+	for _, f := range rc.PlanContext.Files {
+		if f.IsDir {
+			continue
+		}
+		if rel, err := filepath.Rel(in, f.Path); err == nil {
+			files = append(files, filepath.ToSlash(rel))
+		}
+	}
+	units := pack.Units(in, files, func(rel string) string {
+		if ext := strings.TrimPrefix(path.Ext(rel), "."); ext != "" {
+			if e, err := langs.GetExtensionInfo(strings.ToLower(ext), ctx); err == nil && e.IsText {
+				return e.LanguageID
+			}
+		}
+		if b, err := langs.GetBasenameInfo(path.Base(rel), ctx); err == nil && b.IsText {
+			return b.LanguageID
+		}
+		return ""
+	})
+	rc.Logger.Info("extract start", "units", len(units), "packs", pack.Languages())
+	s, err := pack.Run(ctx, st, units, rc.Logger)
+	if err != nil {
+		return fmt.Errorf("extract: %w", err)
+	}
+	if s.Failed > 0 {
+		rc.Stats.IncWarnings(int64(s.Failed))
+	}
+	rc.Logger.Info("extract completed", "units", s.Units, "files", s.Files, "symbols", s.Symbols, "failed", s.Failed)
+	return nil
+}
+
+// finalizeRun seals the run directory: the database is compacted and made
+// immutable, then stats.json and manifest.json are written with its digest.
+// With postgres the IR lives in the shared database and the run database holds
+// none of it, so it is neither sealed nor digested and stats.json carries no
+// row counts; the manifest's store says where the run went.
+func finalizeRun(cmd *cobra.Command, rc *project.RunContext, in string, kind store.Kind, compact bool) error {
+	ctx := cmd.Context()
+	started := time.Now()
+
+	m := &manifest.Manifest{
+		RunID:       rc.RunId.String(),
+		ToolVersion: rc.ToolVersion,
+		IRSchema:    rc.IRSchema,
+		Store:       string(kind),
+		InputPath:   in,
+		CreatedUTC:  rc.Stats.Run.StartedAt,
+	}
+	ver, err := db.CurrentUserVersion(ctx, rc.DB)
+	if err != nil {
+		return fmt.Errorf("read user_version: %w", err)
+	}
+	m.DBSchema = ver
+
+	if kind == store.KindPostgres {
+		if compact {
+			rc.Logger.Warn("compact ignored: the run database holds no IR with --store=postgres")
+		}
+	} else if err := sealRun(cmd, rc, m, compact); err != nil {
+		return err
+	}
+
+	rc.Stats.End()
+	if err := rc.Stats.WriteFile(filepath.Join(rc.OutDir, stats.FileName)); err != nil {
+		return fmt.Errorf("write %s: %w", stats.FileName, err)
+	}
+	m.FinalizedUTC = time.Now().UTC()
+	if err := m.Write(filepath.Join(rc.OutDir, manifest.FileName)); err != nil {
+		return fmt.Errorf("write %s: %w", manifest.FileName, err)
+	}
+
+	rc.Logger.Info("run finalized", "store", kind, "compact", compact, "dur", time.Since(started))
+	return nil
+}
+
+// sealRun finalizes the run database, records its row counts in the run stats
+// and its digest, and the compact copy's, in m.
+func sealRun(cmd *cobra.Command, rc *project.RunContext, m *manifest.Manifest, compact bool) error {
+	ctx := cmd.Context()
+
+	opts := db.FinalizeOptions{}
+	if compact {
+		opts.CompactPath = filepath.Join(rc.OutDir, db.CompactFileName)
+	}
+	if err := db.Finalize(ctx, rc.DB, opts); err != nil {
+		return err
+	}
+
+	counts, err := db.TableCounts(ctx, rc.DB)
+	if err != nil {
+		return err
+	}
+	rc.Stats.SetCounts(counts)
+
+	if m.Database, err = manifest.Digest(rc.OutDir, db.FileName); err != nil {
+		return fmt.Errorf("digest database: %w", err)
+	}
+	if compact {
+		if m.Compact, err = manifest.Digest(rc.OutDir, db.CompactFileName); err != nil {
+			return fmt.Errorf("digest compact copy: %w", err)
+		}
+	}
+	rc.Logger.Info("database sealed", "sha256", m.Database.SHA256, "bytes", m.Database.Bytes)
+	return nil
+}
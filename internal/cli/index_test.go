@@ -0,0 +1,132 @@
+package cli_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/ChaseHampton/cargoworker/internal/cli"
+	"github.com/ChaseHampton/cargoworker/internal/db"
+	"github.com/ChaseHampton/cargoworker/internal/manifest"
+	"github.com/ChaseHampton/cargoworker/internal/stats"
+)
+
+// runIndex indexes a small Python tree and returns the run directory and its
+// stats.
+func runIndex(t *testing.T, args ...string) (string, *stats.Stats) {
+	t.Helper()
+	in := t.TempDir()
+	if err := os.WriteFile(filepath.Join(in, "util.py"), []byte("def helper():\n    \"\"\"Help.\"\"\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out, runID := t.TempDir(), uuid.NewString()
+
+	root := cli.NewRootCmd(cli.Deps{})
+	root.SetContext(context.Background())
+	root.SetArgs(append([]string{"index", "--out", out, "--run-id", runID,
+		"--log.console=false", "--log.file=false", in}, args...))
+	if err := root.Execute(); err != nil {
+		t.Fatalf("index %v: %v", args, err)
+	}
+	runDir := filepath.Join(out, runID)
+	st, err := stats.ReadFile(filepath.Join(runDir, stats.FileName))
+	if err != nil {
+		t.Fatalf("read stats: %v", err)
+	}
+	return runDir, st
+}
+
+func TestIndex_SQLite(t *testing.T) {
+	_, st := runIndex(t, "--store=sqlite")
+	if st.Counts == nil {
+		t.Fatalf("stats.json has no row counts")
+	}
+	if st.Counts["symbol"] == 0 {
+		t.Fatalf("no symbols extracted: %v", st.Counts)
+	}
+}
+
+func TestIndex_SealedRunDir(t *testing.T) {
+	runDir, _ := runIndex(t, "--store=sqlite")
+	fi, err := os.Stat(filepath.Join(runDir, db.FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != db.SealedMode {
+		t.Errorf("run database has mode %v, want %v", fi.Mode().Perm(), db.SealedMode)
+	}
+
+	for _, tc := range []struct{ cmd, out string }{{"markdown", "markdown"}, {"scip", "index.scip"}} {
+		root := cli.NewRootCmd(cli.Deps{})
+		root.SetContext(context.Background())
+		root.SetArgs([]string{"emit", tc.cmd, "--log.console=false", runDir})
+		if err := root.Execute(); err != nil {
+			t.Fatalf("emit %s: %v", tc.cmd, err)
+		}
+		if _, err := os.Stat(runDir + "-" + tc.out); err != nil {
+			t.Errorf("no %s beside the run directory: %v", tc.out, err)
+		}
+		if _, err := os.Stat(filepath.Join(runDir, tc.out)); !os.IsNotExist(err) {
+			t.Errorf("emit wrote %s into the run directory: %v", tc.out, err)
+		}
+	}
+}
+
+func TestIndex_FinalizedRunRefused(t *testing.T) {
+	runDir, _ := runIndex(t, "--store=sqlite")
+	m, err := manifest.Read(filepath.Join(runDir, manifest.FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	in := t.TempDir()
+	root := cli.NewRootCmd(cli.Deps{})
+	root.SetContext(context.Background())
+	root.SetArgs([]string{"index", "--out", filepath.Dir(runDir), "--run-id", filepath.Base(runDir),
+		"--log.console=false", "--log.file=false", in})
+	if err := root.Execute(); err == nil || !strings.Contains(err.Error(), "already finalized") {
+		t.Fatalf("second index = %v, want already finalized", err)
+	}
+	got, err := manifest.Digest(runDir, db.FileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.SHA256 != m.Database.SHA256 {
+		t.Error("the second index changed the sealed database")
+	}
+}
+
+func TestIndex_Postgres(t *testing.T) {
+	dsn := os.Getenv("CARGOWORKER_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("CARGOWORKER_TEST_POSTGRES_DSN not set (docker compose up db)")
+	}
+	runDir, st := runIndex(t, "--store=postgres", "--dsn", dsn)
+	runID := filepath.Base(runDir)
+	if st.Counts != nil {
+		t.Errorf("stats.json counts the empty run database: %v", st.Counts)
+	}
+
+	ctx := context.Background()
+	pool, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		t.Fatalf("open postgres: %v", err)
+	}
+	defer pool.Close()
+	var n int
+	err = pool.QueryRow(ctx, `SELECT count(*) FROM symbol s
+		JOIN package p ON p.id = s.package_id JOIN container c ON c.id = p.container_id
+		JOIN project pr ON pr.id = c.project_id
+		WHERE pr.uid = $1 AND s.name = 'helper'`, runID).Scan(&n)
+	if err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("postgres has %d helper symbols for the run, want 1", n)
+	}
+}
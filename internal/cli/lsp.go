@@ -0,0 +1,42 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/ChaseHampton/cargoworker/internal/lsp"
+	"github.com/ChaseHampton/cargoworker/internal/project"
+)
+
+func NewLSPCmd() *cobra.Command {
+	var fDB string
+
+	cmd := &cobra.Command{
+		Use:   "lsp --db <run-dir|docdb.sqlite>",
+		Short: "Speak the Language Server Protocol over stdio, answering from a run database",
+		Long: `Runs a language server on stdin/stdout for editors: workspace symbols,
+go to definition, hover and find references, answered from the index.
+Logs go to stderr; stdout carries protocol messages only.`,
+		Args:              cobra.NoArgs,
+		PersistentPreRunE: inspectPreRun,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rc := project.FromContext(cmd.Context())
+			if rc == nil {
+				return fmt.Errorf("internal: run context unavailable")
+			}
+			if err := lsp.New(rc.DB, rc.Logger).Serve(cmd.Context(), cmd.InOrStdin(), cmd.OutOrStdout()); err != nil {
+				return fmt.Errorf("lsp: %w", err)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&fDB, "db", "", "run directory or docdb.sqlite to answer from")
+
+	// Viper bindings (env keys: CARGOWORKER_LSP_DB)
+	_ = viper.BindPFlag("lsp.db", cmd.Flags().Lookup("db"))
+
+	return cmd
+}
@@ -0,0 +1,44 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/ChaseHampton/cargoworker/internal/mcp"
+	"github.com/ChaseHampton/cargoworker/internal/project"
+)
+
+func NewMCPCmd() *cobra.Command {
+	var fDB string
+
+	cmd := &cobra.Command{
+		Use:   "mcp --db <run-dir|docdb.sqlite>",
+		Short: "Serve index tools to AI assistants over the Model Context Protocol on stdio",
+		Long: `Runs a Model Context Protocol server on stdin/stdout with read-only tools over
+the index: search_symbols, get_symbol, list_container, find_implementations
+and get_file_outline. Results are paginated and size-bounded. The server
+reads only the run database and opens no network connections.
+Logs go to stderr; stdout carries protocol messages only.`,
+		Args:              cobra.NoArgs,
+		PersistentPreRunE: inspectPreRun,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rc := project.FromContext(cmd.Context())
+			if rc == nil {
+				return fmt.Errorf("internal: run context unavailable")
+			}
+			if err := mcp.New(rc.DB, rc.Logger).Serve(cmd.Context(), cmd.InOrStdin(), cmd.OutOrStdout()); err != nil {
+				return fmt.Errorf("mcp: %w", err)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&fDB, "db", "", "run directory or docdb.sqlite to answer from")
+
+	// Viper bindings (env keys: CARGOWORKER_MCP_DB)
+	_ = viper.BindPFlag("mcp.db", cmd.Flags().Lookup("db"))
+
+	return cmd
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
+	"github.com/ChaseHampton/cargoworker/internal/db"
 	"github.com/ChaseHampton/cargoworker/internal/logx"
 	"github.com/ChaseHampton/cargoworker/internal/project"
 	"github.com/ChaseHampton/cargoworker/internal/stats"
@@ -53,89 +54,7 @@ func NewRootCmd(deps Deps) *cobra.Command {
 				return fmt.Errorf("input path not found or not a directory: %s", inPath)
 			}
 
-			opts := logx.Options{
-				ConsoleEnabled: viper.GetBool("log.console"),
-				ConsoleFormat:  logx.Format(viper.GetString("log.console.format")),
-				ConsoleLevel:   logx.Level(viper.GetString("log.console.level")),
-				FileEnabled:    viper.GetBool("log.file"),
-				FilePath:       viper.GetString("log.file.path"),
-				FileFormat:     logx.Format(viper.GetString("log.file.format")),
-				FileLevel:      logx.Level(viper.GetString("log.file.level")),
-				Source:         true, // or viper.GetBool("log.source") if you expose it
-				RunID:          viper.GetString("run-id"),
-				Component:      "cargoworker",
-				Quiet:          viper.GetBool("quiet"),
-			}
-
-			if opts.RunID == "" {
-				opts.RunID = uuid.New().String()
-			}
-
-			outRoot := viper.GetString("out")
-			if outRoot == "" {
-				outRoot = "./cargoworkerout"
-			}
-			runOut := filepath.Join(outRoot, opts.RunID)
-			if err := os.MkdirAll(runOut, 0o755); err != nil {
-				return err
-			}
-			if opts.FileEnabled {
-				if opts.FilePath == "" {
-					if err := os.MkdirAll(filepath.Join(runOut, "logs"), 0o755); err != nil {
-						return err
-					}
-					opts.FilePath = filepath.Join(runOut, "logs", "run.log")
-				} else {
-					if err := os.MkdirAll(filepath.Dir(opts.FilePath), 0o755); err != nil {
-						return err
-					}
-				}
-			}
-
-			final, closer, err := logx.New(opts)
-			if err != nil {
-				return fmt.Errorf("setup logging: %w", err)
-			}
-
-			var runUUID uuid.UUID
-			if u, err := uuid.Parse(opts.RunID); err == nil {
-				runUUID = u
-			} else {
-				runUUID = uuid.New()
-			}
-
-			st := stats.New(
-				stats.WithRunID(opts.RunID),
-				stats.WithInputPath(inPath),
-				stats.WithOutDir(runOut),
-				stats.WithToolVersion("v1"),
-				stats.WithIRSchema("v1"),
-			)
-
-			rc := &project.RunContext{
-				RunId:       runUUID,
-				OutDir:      runOut,
-				ToolVersion: "v1",
-				IRSchema:    "v1",             // fill in later
-				Limits:      project.Limits{}, // fill in later
-				Logger:      final,
-				DB:          nil,
-				Events:      deps.EventChan,
-				Stats:       st,
-				Closers:     []func() error{closer},
-			}
-
-			ctx := project.WithRunContext(cmd.Context(), rc)
-			ctx = project.WithInputPath(ctx, inPath)
-			cmd.SetContext(ctx)
-
-			// A single handoff log
-			final.Info("logger initialized",
-				"run_id", opts.RunID, "out", runOut,
-				"console", opts.ConsoleEnabled, "file", opts.FileEnabled,
-				"file_path", opts.FilePath, "level", opts.ConsoleLevel)
-
-			return nil
+			return startRun(cmd, inPath, deps.EventChan)
 		},
 		PersistentPostRunE: func(cmd *cobra.Command, args []string) error {
 			rc := project.FromContext(cmd.Context())
@@ -201,5 +120,108 @@ func NewRootCmd(deps Deps) *cobra.Command {
 	_ = viper.BindPFlag("quiet", pf.Lookup("quiet"))
 
 	cmd.AddCommand(NewPlanCmd())
+	cmd.AddCommand(NewIndexCmd())
+	cmd.AddCommand(NewVerifyCmd())
+	cmd.AddCommand(NewSearchCmd())
+	cmd.AddCommand(NewEmitCmd())
+	cmd.AddCommand(NewServeCmd())
+	cmd.AddCommand(NewLSPCmd())
+	cmd.AddCommand(NewMCPCmd())
+	cmd.AddCommand(NewShellCmd())
 	return cmd
 }
+
+// startRun creates the run directory for a command that produces a new run:
+// logging to console and OUT/RUNID/logs, a fresh docdb.sqlite, stats, and the
+// RunContext on cmd's context. inPath is recorded as the run's input.
+func startRun(cmd *cobra.Command, inPath string, events chan<- project.Event) error {
+	opts := logx.Options{
+		ConsoleEnabled: viper.GetBool("log.console"),
+		ConsoleFormat:  logx.Format(viper.GetString("log.console.format")),
+		ConsoleLevel:   logx.Level(viper.GetString("log.console.level")),
+		FileEnabled:    viper.GetBool("log.file"),
+		FilePath:       viper.GetString("log.file.path"),
+		FileFormat:     logx.Format(viper.GetString("log.file.format")),
+		FileLevel:      logx.Level(viper.GetString("log.file.level")),
+		Source:         true, // or viper.GetBool("log.source") if you expose it
+		RunID:          viper.GetString("run-id"),
+		Component:      "cargoworker",
+		Quiet:          viper.GetBool("quiet"),
+	}
+
+	if opts.RunID == "" {
+		opts.RunID = uuid.New().String()
+	}
+
+	outRoot := viper.GetString("out")
+	if outRoot == "" {
+		outRoot = "./cargoworkerout"
+	}
+	runOut := filepath.Join(outRoot, opts.RunID)
+	if err := os.MkdirAll(runOut, 0o755); err != nil {
+		return err
+	}
+	if opts.FileEnabled {
+		if opts.FilePath == "" {
+			if err := os.MkdirAll(filepath.Join(runOut, "logs"), 0o755); err != nil {
+				return err
+			}
+			opts.FilePath = filepath.Join(runOut, "logs", "run.log")
+		} else {
+			if err := os.MkdirAll(filepath.Dir(opts.FilePath), 0o755); err != nil {
+				return err
+			}
+		}
+	}
+
+	final, closer, err := logx.New(opts)
+	if err != nil {
+		return fmt.Errorf("setup logging: %w", err)
+	}
+
+	rdb, err := db.Open(cmd.Context(), filepath.Join(runOut, db.FileName))
+	if err != nil {
+		_ = closer()
+		return fmt.Errorf("open run database: %w", err)
+	}
+
+	var runUUID uuid.UUID
+	if u, err := uuid.Parse(opts.RunID); err == nil {
+		runUUID = u
+	} else {
+		runUUID = uuid.New()
+	}
+
+	st := stats.New(
+		stats.WithRunID(opts.RunID),
+		stats.WithInputPath(inPath),
+		stats.WithOutDir(runOut),
+		stats.WithToolVersion("v1"),
+		stats.WithIRSchema("v1"),
+	)
+
+	rc := &project.RunContext{
+		RunId:       runUUID,
+		OutDir:      runOut,
+		ToolVersion: "v1",
+		IRSchema:    "v1",             // fill in later
+		Limits:      project.Limits{}, // fill in later
+		Logger:      final,
+		DB:          rdb,
+		Events:      events,
+		Stats:       st,
+		Closers:     []func() error{closer, rdb.Close},
+	}
+
+	ctx := project.WithRunContext(cmd.Context(), rc)
+	ctx = project.WithInputPath(ctx, inPath)
+	cmd.SetContext(ctx)
+
+	// A single handoff log
+	final.Info("logger initialized",
+		"run_id", opts.RunID, "out", runOut,
+		"console", opts.ConsoleEnabled, "file", opts.FileEnabled,
+		"file_path", opts.FilePath, "level", opts.ConsoleLevel)
+
+	return nil
+}
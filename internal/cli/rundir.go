@@ -0,0 +1,91 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/ChaseHampton/cargoworker/internal/db"
+	"github.com/ChaseHampton/cargoworker/internal/logx"
+	"github.com/ChaseHampton/cargoworker/internal/project"
+)
+
+// inspectPreRun replaces the root pre-run for commands that read an existing
+// run instead of creating a new one. Commands with a --db flag (bound to the
+// viper key "<command>.db") take the run from it, others from their first
+// positional argument; either may name a run directory or a database file.
+// Logging goes to the console only and the database is opened read-only.
+func inspectPreRun(cmd *cobra.Command, args []string) error {
+	target := ""
+	if cmd.Flags().Lookup("db") != nil {
+		target = strings.TrimSpace(viper.GetString(cmd.Name() + ".db"))
+		if target == "" {
+			return fmt.Errorf("missing run: provide --db or CARGOWORKER_%s_DB", strings.ToUpper(cmd.Name()))
+		}
+	} else if len(args) > 0 {
+		target = args[0]
+	}
+	if target == "" {
+		return fmt.Errorf("missing run: provide <run-dir>")
+	}
+
+	runDir, dbPath, err := resolveRun(target)
+	if err != nil {
+		return err
+	}
+
+	lg, closer, err := logx.New(logx.Options{
+		ConsoleEnabled: viper.GetBool("log.console"),
+		ConsoleFormat:  logx.Format(viper.GetString("log.console.format")),
+		ConsoleLevel:   logx.Level(viper.GetString("log.console.level")),
+		Component:      "cargoworker",
+		Quiet:          viper.GetBool("quiet"),
+	})
+	if err != nil {
+		return fmt.Errorf("setup logging: %w", err)
+	}
+
+	rdb, err := db.OpenReadOnly(cmd.Context(), dbPath)
+	if err != nil {
+		_ = closer()
+		return fmt.Errorf("open run database: %w", err)
+	}
+
+	rc := &project.RunContext{
+		OutDir:  runDir,
+		Logger:  lg,
+		DB:      rdb,
+		Events:  nil,
+		Closers: []func() error{closer, rdb.Close},
+	}
+	ctx := project.WithRunContext(cmd.Context(), rc)
+	ctx = project.WithDBPath(ctx, dbPath)
+	cmd.SetContext(ctx)
+
+	lg.Debug("run opened", "run_dir", runDir, "db", dbPath)
+	return nil
+}
+
+// resolveRun maps a run directory or database file to both paths. runDir is
+// empty when target is a database outside a run directory layout.
+func resolveRun(target string) (runDir, dbPath string, err error) {
+	abs, err := filepath.Abs(target)
+	if err != nil {
+		return "", "", err
+	}
+	fi, err := os.Stat(abs)
+	if err != nil {
+		return "", "", fmt.Errorf("run not found: %s", target)
+	}
+	if fi.IsDir() {
+		return abs, filepath.Join(abs, db.FileName), nil
+	}
+	if filepath.Base(abs) == db.FileName {
+		return filepath.Dir(abs), abs, nil
+	}
+	return "", abs, nil
+}
@@ -0,0 +1,106 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/mattn/go-isatty"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/ChaseHampton/cargoworker/internal/project"
+	"github.com/ChaseHampton/cargoworker/internal/search"
+)
+
+func NewSearchCmd() *cobra.Command {
+	var (
+		fDB, fKind, fPkg, fLang, fMode string
+		fLimit                         int
+		fJSON, fRaw                    bool
+	)
+
+	cmd := &cobra.Command{
+		Use:               "search <query>",
+		Short:             "Look up symbols by full-text, prefix, exact or substring name",
+		Args:              cobra.MinimumNArgs(1),
+		PersistentPreRunE: inspectPreRun,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rc := project.FromContext(cmd.Context())
+			if rc == nil {
+				return fmt.Errorf("internal: run context unavailable")
+			}
+
+			mode, err := search.ParseMode(viper.GetString("search.mode"))
+			if err != nil {
+				return err
+			}
+			asJSON := viper.GetBool("search.json")
+
+			q := search.Query{
+				Text:  strings.Join(args, " "),
+				Mode:  mode,
+				Kind:  viper.GetString("search.kind"),
+				Pkg:   viper.GetString("search.pkg"),
+				Lang:  viper.GetString("search.lang"),
+				Limit: viper.GetInt("search.limit"),
+				Raw:   viper.GetBool("search.raw"),
+			}
+			if !asJSON && isatty.IsTerminal(os.Stdout.Fd()) {
+				q.Mark = [2]string{"\x1b[1m", "\x1b[0m"}
+			}
+
+			hits, err := search.Search(cmd.Context(), rc.DB, q)
+			if err != nil {
+				return err
+			}
+			rc.Logger.Debug("search", "query", q.Text, "mode", mode, "hits", len(hits))
+
+			out := cmd.OutOrStdout()
+			if asJSON {
+				enc := json.NewEncoder(out)
+				enc.SetIndent("", "  ")
+				return enc.Encode(hits)
+			}
+
+			tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+			for _, h := range hits {
+				loc := h.File
+				if loc != "" && h.Line > 0 {
+					loc = fmt.Sprintf("%s:%d", h.File, h.Line)
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", h.Kind, h.Pkg, h.Highlight, loc)
+				if doc := strings.Join(strings.Fields(h.Snippet), " "); doc != "" {
+					fmt.Fprintf(tw, "\t\t  %s\t\n", doc)
+				}
+			}
+			return tw.Flush()
+		},
+	}
+
+	cmd.Flags().StringVar(&fDB, "db", "", "run directory or docdb.sqlite to search")
+	cmd.Flags().StringVar(&fKind, "kind", "", "only symbols of this kind (e.g. function, struct)")
+	cmd.Flags().StringVar(&fPkg, "pkg", "", "only symbols in this package import path (glob allowed)")
+	cmd.Flags().StringVar(&fLang, "lang", "", "only symbols of this language")
+	cmd.Flags().StringVar(&fMode, "mode", "fts", "match mode: fts|prefix|exact|substring")
+	cmd.Flags().IntVar(&fLimit, "limit", 20, "maximum number of results")
+	cmd.Flags().BoolVar(&fJSON, "json", false, "print results as JSON")
+	cmd.Flags().BoolVar(&fRaw, "raw", false, "pass the query to FTS5 MATCH unmodified")
+
+	// Viper bindings (env keys: CARGOWORKER_SEARCH_DB, _SEARCH_KIND, ...)
+	_ = viper.BindPFlag("search.db", cmd.Flags().Lookup("db"))
+	_ = viper.BindPFlag("search.kind", cmd.Flags().Lookup("kind"))
+	_ = viper.BindPFlag("search.pkg", cmd.Flags().Lookup("pkg"))
+	_ = viper.BindPFlag("search.lang", cmd.Flags().Lookup("lang"))
+	_ = viper.BindPFlag("search.mode", cmd.Flags().Lookup("mode"))
+	_ = viper.BindPFlag("search.limit", cmd.Flags().Lookup("limit"))
+	_ = viper.BindPFlag("search.json", cmd.Flags().Lookup("json"))
+	_ = viper.BindPFlag("search.raw", cmd.Flags().Lookup("raw"))
+
+	viper.SetDefault("search.mode", "fts")
+	viper.SetDefault("search.limit", 20)
+
+	return cmd
+}
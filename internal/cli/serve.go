@@ -0,0 +1,48 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/ChaseHampton/cargoworker/internal/project"
+	"github.com/ChaseHampton/cargoworker/internal/serve"
+)
+
+func NewServeCmd() *cobra.Command {
+	var fAddr string
+
+	cmd := &cobra.Command{
+		Use:               "serve <run-dir>",
+		Short:             "Serve a run database over a read-only JSON and GraphQL HTTP API",
+		Args:              cobra.ExactArgs(1),
+		PersistentPreRunE: inspectPreRun,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rc := project.FromContext(cmd.Context())
+			if rc == nil {
+				return fmt.Errorf("internal: run context unavailable")
+			}
+			dbPath := project.DBPathFrom(cmd.Context())
+			etag, err := serve.ETag(dbPath)
+			if err != nil {
+				return fmt.Errorf("checksum %s: %w", dbPath, err)
+			}
+
+			h := serve.New(rc.DB, etag, rc.Logger)
+			// Runs until the signal context from main is cancelled.
+			if err := serve.Run(cmd.Context(), viper.GetString("serve.addr"), h, rc.Logger); err != nil {
+				return fmt.Errorf("serve: %w", err)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&fAddr, "addr", ":8080", "listen address")
+
+	// Viper bindings (env keys: CARGOWORKER_SERVE_ADDR)
+	_ = viper.BindPFlag("serve.addr", cmd.Flags().Lookup("addr"))
+	viper.SetDefault("serve.addr", ":8080")
+
+	return cmd
+}
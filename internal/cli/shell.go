@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/ChaseHampton/cargoworker/internal/db"
+	"github.com/ChaseHampton/cargoworker/internal/project"
+	"github.com/ChaseHampton/cargoworker/internal/shell"
+)
+
+func NewShellCmd() *cobra.Command {
+	var fJSON bool
+
+	cmd := &cobra.Command{
+		Use:   "shell <run-dir|docdb.sqlite>",
+		Short: "Query a run database interactively with SQL and dot-commands",
+		Long: `Opens a read-only SQL shell over a run database. Besides SQL it knows
+.symbols <name>, .impl <interface>, .callers <function> and .deps <container>;
+.help lists all commands. Tab completes table and column names.
+Commands can also be piped in, one per line.`,
+		Args:              cobra.ExactArgs(1),
+		PersistentPreRunE: inspectPreRun,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rc := project.FromContext(cmd.Context())
+			if rc == nil {
+				return fmt.Errorf("internal: run context unavailable")
+			}
+			tables, err := db.Schema(cmd.Context())
+			if err != nil {
+				return fmt.Errorf("load schema: %w", err)
+			}
+			sh := shell.New(rc.DB, tables, cmd.OutOrStdout(), cmd.ErrOrStderr())
+			if viper.GetBool("shell.json") {
+				_ = sh.SetMode(shell.ModeJSON)
+			}
+			return sh.Run(cmd.Context(), cmd.InOrStdin())
+		},
+	}
+
+	cmd.Flags().BoolVar(&fJSON, "json", false, "print results as JSON instead of tables")
+
+	// Viper bindings (env keys: CARGOWORKER_SHELL_JSON)
+	_ = viper.BindPFlag("shell.json", cmd.Flags().Lookup("json"))
+
+	return cmd
+}
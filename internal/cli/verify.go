@@ -0,0 +1,44 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ChaseHampton/cargoworker/internal/project"
+	"github.com/ChaseHampton/cargoworker/internal/verify"
+)
+
+func NewVerifyCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:               "verify <run-dir>",
+		Short:             "Check a run database for integrity, orphans and drift from stats.json",
+		Args:              cobra.ExactArgs(1),
+		PersistentPreRunE: inspectPreRun,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rc := project.FromContext(cmd.Context())
+			if rc == nil {
+				return fmt.Errorf("internal: run context unavailable")
+			}
+
+			report, err := verify.Run(cmd.Context(), rc.DB, rc.OutDir, project.DBPathFrom(cmd.Context()))
+			if err != nil {
+				return err
+			}
+
+			enc := json.NewEncoder(cmd.OutOrStdout())
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(report); err != nil {
+				return err
+			}
+
+			if !report.OK {
+				return fmt.Errorf("verify: %d of %d checks failed", report.Failed, len(report.Checks))
+			}
+			rc.Logger.Info("verify passed", "checks", len(report.Checks))
+			return nil
+		},
+	}
+	return cmd
+}
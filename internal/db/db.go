@@ -4,11 +4,18 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"os"
+	"runtime"
 	"time"
 
 	_ "embed"
+
+	_ "modernc.org/sqlite"
 )
 
+// FileName is the canonical name of the run database inside a run directory.
+const FileName = "docdb.sqlite"
+
 //go:embed sql/language_by_extension.sql
 var LanguageByExtensionSQL string
 
@@ -61,6 +68,48 @@ func Open(ctx context.Context, filePath string) (*sql.DB, error) {
 	return db, nil
 }
 
+// OpenReadOnly opens an existing run database for inspection. Unlike Open it
+// never creates the file or touches persistent pragmas, and it allows several
+// concurrent readers.
+func OpenReadOnly(ctx context.Context, filePath string) (*sql.DB, error) {
+	if _, err := os.Stat(filePath); err != nil {
+		return nil, fmt.Errorf("open db: %w", err)
+	}
+	conn_str := fmt.Sprintf("file:%s?mode=ro&_pragma=busy_timeout(5000)&_pragma=query_only(1)", filePath)
+	db, err := sql.Open("sqlite", conn_str)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open db: %w", err)
+	}
+	db.SetMaxOpenConns(runtime.NumCPU())
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("ping sqlite: %w", err)
+	}
+	return db, nil
+}
+
+// IRTables lists the tables that hold a run's IR, parents before children.
+var IRTables = []string{
+	"project", "container", "package", "file", "symbol", "signature",
+	"type_ref", "member", "relation", "pkg_import", "diagnostic",
+}
+
+// TableCounts returns the row count of every table in IRTables.
+func TableCounts(ctx context.Context, db *sql.DB) (map[string]int64, error) {
+	counts := make(map[string]int64, len(IRTables))
+	for _, t := range IRTables {
+		var n int64
+		if err := db.QueryRowContext(ctx, fmt.Sprintf(`SELECT count(*) FROM %q;`, t)).Scan(&n); err != nil {
+			return nil, fmt.Errorf("count %s: %w", t, err)
+		}
+		counts[t] = n
+	}
+	return counts, nil
+}
+
 func CurrentUserVersion(ctx context.Context, db *sql.DB) (int, error) {
 	var v int
 	if err := db.QueryRowContext(ctx, `PRAGMA user_version;`).Scan(&v); err != nil {
@@ -0,0 +1,136 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// CompactFileName is the default name of the VACUUM INTO copy written next to
+// the run database when compaction is requested.
+const CompactFileName = "docdb.compact.sqlite"
+
+// SealedMode is the permission Finalize leaves the run database and its
+// compact copy with.
+const SealedMode os.FileMode = 0o444
+
+type FinalizeOptions struct {
+	// CompactPath, when set, receives a defragmented VACUUM INTO copy of the
+	// finalized database. An existing file at that path is replaced.
+	CompactPath string
+}
+
+// Finalize prepares the run database for publishing. It refreshes planner
+// statistics, merges FTS segments, reclaims free pages, folds the WAL back into
+// the main file and switches the journal to DELETE so the database is a single
+// self-contained file. The database is then sealed: the connection turns
+// query-only and the file, like the compact copy, is made read-only.
+func Finalize(ctx context.Context, db *sql.DB, opts FinalizeOptions) error {
+	if _, err := db.ExecContext(ctx, `PRAGMA optimize;`); err != nil {
+		return fmt.Errorf("optimize: %w", err)
+	}
+
+	hasFTS, err := tableExists(ctx, db, "search_fts")
+	if err != nil {
+		return fmt.Errorf("lookup search_fts: %w", err)
+	}
+	if hasFTS {
+		if _, err := db.ExecContext(ctx, `INSERT INTO search_fts(search_fts) VALUES('optimize');`); err != nil {
+			return fmt.Errorf("fts optimize: %w", err)
+		}
+	}
+
+	if _, err := db.ExecContext(ctx, `PRAGMA incremental_vacuum;`); err != nil {
+		return fmt.Errorf("incremental vacuum: %w", err)
+	}
+
+	var busy, logFrames, checkpointed int
+	if err := db.QueryRowContext(ctx, `PRAGMA wal_checkpoint(TRUNCATE);`).Scan(&busy, &logFrames, &checkpointed); err != nil {
+		return fmt.Errorf("wal checkpoint: %w", err)
+	}
+	if busy != 0 {
+		return fmt.Errorf("wal checkpoint: database busy (%d/%d frames checkpointed)", checkpointed, logFrames)
+	}
+
+	var mode string
+	if err := db.QueryRowContext(ctx, `PRAGMA journal_mode=DELETE;`).Scan(&mode); err != nil {
+		return fmt.Errorf("journal mode: %w", err)
+	}
+	if mode != "delete" {
+		return fmt.Errorf("journal mode: expected delete, got %q", mode)
+	}
+
+	if opts.CompactPath != "" {
+		if err := os.Remove(opts.CompactPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("remove stale compact copy: %w", err)
+		}
+		if _, err := db.ExecContext(ctx, `VACUUM INTO ?;`, opts.CompactPath); err != nil {
+			return fmt.Errorf("vacuum into %s: %w", opts.CompactPath, err)
+		}
+		if err := os.Chmod(opts.CompactPath, SealedMode); err != nil {
+			return fmt.Errorf("seal compact copy: %w", err)
+		}
+	}
+
+	if _, err := db.ExecContext(ctx, `PRAGMA query_only=ON;`); err != nil {
+		return fmt.Errorf("query only: %w", err)
+	}
+	path, err := mainFile(ctx, db)
+	if err != nil {
+		return fmt.Errorf("lookup database file: %w", err)
+	}
+	if path != "" {
+		if err := os.Chmod(path, SealedMode); err != nil {
+			return fmt.Errorf("seal database: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Sealed reports whether Finalize sealed the database at path: the file
+// exists and no one may write to it.
+func Sealed(path string) (bool, error) {
+	fi, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return fi.Mode().Perm()&0o222 == 0, nil
+}
+
+// mainFile returns the path of the main database, or "" for an in-memory one.
+func mainFile(ctx context.Context, db *sql.DB) (string, error) {
+	rows, err := db.QueryContext(ctx, `PRAGMA database_list;`)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			seq        int
+			name, file string
+		)
+		if err := rows.Scan(&seq, &name, &file); err != nil {
+			return "", err
+		}
+		if name == "main" {
+			return file, nil
+		}
+	}
+	return "", rows.Err()
+}
+
+func tableExists(ctx context.Context, db *sql.DB, name string) (bool, error) {
+	var n int
+	err := db.QueryRowContext(ctx,
+		`SELECT count(*) FROM sqlite_master WHERE type IN ('table','view') AND name = ?;`, name).Scan(&n)
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
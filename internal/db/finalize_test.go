@@ -0,0 +1,68 @@
+package db
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFinalize_SelfContainedFile(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	path := filepath.Join(dir, FileName)
+
+	d, err := Open(ctx, path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer d.Close()
+	if err := RunMigrations(ctx, d); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if _, err := d.ExecContext(ctx, `INSERT INTO project(id, name, root_path) VALUES (1, 'p', '/src')`); err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+
+	compact := filepath.Join(dir, CompactFileName)
+	if err := Finalize(ctx, d, FinalizeOptions{CompactPath: compact}); err != nil {
+		t.Fatalf("finalize: %v", err)
+	}
+
+	var mode string
+	if err := d.QueryRowContext(ctx, `PRAGMA journal_mode;`).Scan(&mode); err != nil {
+		t.Fatalf("journal_mode: %v", err)
+	}
+	if mode != "delete" {
+		t.Fatalf("journal_mode = %q, want delete", mode)
+	}
+	if _, err := os.Stat(path + "-wal"); !os.IsNotExist(err) {
+		t.Fatalf("expected no -wal sidecar after finalize, stat err = %v", err)
+	}
+
+	for _, p := range []string{path, compact} {
+		fi, err := os.Stat(p)
+		if err != nil {
+			t.Fatalf("stat: %v", err)
+		}
+		if fi.Mode().Perm() != SealedMode {
+			t.Errorf("%s has mode %v after finalize, want %v", filepath.Base(p), fi.Mode().Perm(), SealedMode)
+		}
+	}
+	if _, err := d.ExecContext(ctx, `INSERT INTO project(id, name, root_path) VALUES (2, 'q', '/src')`); err == nil {
+		t.Error("the finalized database accepted a write")
+	}
+
+	c, err := Open(ctx, compact)
+	if err != nil {
+		t.Fatalf("open compact copy: %v", err)
+	}
+	defer c.Close()
+	var n int
+	if err := c.QueryRowContext(ctx, `SELECT count(*) FROM project`).Scan(&n); err != nil {
+		t.Fatalf("query compact copy: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("compact copy has %d projects, want 1", n)
+	}
+}
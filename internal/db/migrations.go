@@ -14,10 +14,13 @@ import (
 //go:embed sql/migrations/*.sql
 var migFS embed.FS
 
-type migration struct {
-	version int
-	name    string
-	sql     string
+//go:embed sql/postgres/*.sql
+var pgMigFS embed.FS
+
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
 }
 
 func RunMigrations(ctx context.Context, db *sql.DB) error {
@@ -26,12 +29,33 @@ func RunMigrations(ctx context.Context, db *sql.DB) error {
 		return fmt.Errorf("get current user version: %w", err)
 	}
 
-	entries, err := migFS.ReadDir("sql/migrations")
+	list, err := loadMigrations(migFS, "sql/migrations", curr)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range list {
+		if err := applyOne(ctx, db, m); err != nil {
+			return fmt.Errorf("migration %s failed: %w", m.Name, err)
+		}
+	}
+	return nil
+}
+
+// PostgresMigrations returns the Postgres translations of the schema
+// migrations newer than version after, in apply order. Versions match the
+// SQLite migrations one for one.
+func PostgresMigrations(after int) ([]Migration, error) {
+	return loadMigrations(pgMigFS, "sql/postgres", after)
+}
+
+func loadMigrations(fsys embed.FS, dir string, after int) ([]Migration, error) {
+	entries, err := fsys.ReadDir(dir)
 	if err != nil {
-		return fmt.Errorf("read migrations dir: %w", err)
+		return nil, fmt.Errorf("read migrations dir: %w", err)
 	}
 
-	var list []migration
+	var list []Migration
 	for _, e := range entries {
 		if e.IsDir() {
 			continue
@@ -42,43 +66,81 @@ func RunMigrations(ctx context.Context, db *sql.DB) error {
 		if err != nil {
 			continue
 		}
-		if v <= curr {
+		if v <= after {
 			continue
 		}
 
-		b, err := migFS.ReadFile(path.Join("sql/migrations", base))
+		b, err := fsys.ReadFile(path.Join(dir, base))
 		if err != nil {
-			return err
+			return nil, err
 		}
-		list = append(list, migration{version: v, name: base, sql: string(b)})
+		list = append(list, Migration{Version: v, Name: base, SQL: string(b)})
 	}
 
-	sort.Slice(list, func(i, j int) bool { return list[i].version < list[j].version })
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
 
-	for _, m := range list {
-		if err := applyOne(ctx, db, m); err != nil {
-			return fmt.Errorf("migration %s failed: %w", m.name, err)
-		}
+// applyOne runs a migration the way SQLite documents for schema changes:
+// with foreign keys off, so that a table can be rebuilt without cascading to
+// the rows that reference it, and checked before the commit instead.
+func applyOne(ctx context.Context, db *sql.DB, m Migration) error {
+	// foreign_keys is a no-op inside a transaction; pin the connection so it
+	// applies to the one the migration runs on.
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
 	}
-	return nil
-}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, `PRAGMA foreign_keys=OFF;`); err != nil {
+		return err
+	}
+	defer func() { _, _ = conn.ExecContext(context.WithoutCancel(ctx), `PRAGMA foreign_keys=ON;`) }()
 
-func applyOne(ctx context.Context, db *sql.DB, m migration) error {
-	tx, err := db.BeginTx(ctx, nil)
+	tx, err := conn.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 	defer func() { _ = tx.Rollback() }()
 
-	// Enforce FKs during migration execution.
-	if _, err := tx.ExecContext(ctx, `PRAGMA foreign_keys=ON;`); err != nil {
+	if _, err := tx.ExecContext(ctx, transactional(m.SQL)); err != nil {
 		return err
 	}
-	if _, err := tx.ExecContext(ctx, m.sql); err != nil {
+	rows, err := tx.QueryContext(ctx, `PRAGMA foreign_key_check;`)
+	if err != nil {
+		return err
+	}
+	violated := rows.Next()
+	if err := rows.Close(); err != nil {
 		return err
 	}
-	if _, err := tx.ExecContext(ctx, fmt.Sprintf(`PRAGMA user_version=%d;`, m.version)); err != nil {
+	if violated {
+		return fmt.Errorf("foreign key check failed")
+	}
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf(`PRAGMA user_version=%d;`, m.Version)); err != nil {
 		return err
 	}
 	return tx.Commit()
 }
+
+// transactional drops the persistent pragmas a migration sets at the top of
+// the file. They cannot run inside the migration transaction, and
+// BootstrapPersistentPragmas has already applied them when the file was
+// created.
+func transactional(script string) string {
+	lines := strings.SplitAfter(script, "\n")
+	for i, l := range lines {
+		stmt := strings.ToUpper(strings.Join(strings.Fields(l), ""))
+		switch {
+		case stmt == "" || strings.HasPrefix(stmt, "--"):
+		case stmt == "VACUUM;",
+			strings.HasPrefix(stmt, "PRAGMAJOURNAL_MODE="),
+			strings.HasPrefix(stmt, "PRAGMAPAGE_SIZE="),
+			strings.HasPrefix(stmt, "PRAGMAAUTO_VACUUM="):
+			lines[i] = ""
+		default:
+			return strings.Join(lines, "")
+		}
+	}
+	return strings.Join(lines, "")
+}
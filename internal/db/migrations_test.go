@@ -0,0 +1,31 @@
+package db
+
+import "testing"
+
+// Every SQLite migration needs a Postgres translation with the same version.
+func TestPostgresMigrationsTrackSQLite(t *testing.T) {
+	lite, err := loadMigrations(migFS, "sql/migrations", 0)
+	if err != nil {
+		t.Fatalf("load sqlite migrations: %v", err)
+	}
+	pg, err := PostgresMigrations(0)
+	if err != nil {
+		t.Fatalf("load postgres migrations: %v", err)
+	}
+	if len(lite) != len(pg) {
+		t.Fatalf("sqlite has %d migrations, postgres has %d", len(lite), len(pg))
+	}
+	for i := range lite {
+		if lite[i].Version != pg[i].Version || lite[i].Name != pg[i].Name {
+			t.Fatalf("migration %d: sqlite %s, postgres %s", i, lite[i].Name, pg[i].Name)
+		}
+	}
+}
+
+// 001 sets persistent pragmas that cannot run in the migration transaction.
+func TestTransactionalSkipsPersistentPragmas(t *testing.T) {
+	got := transactional("PRAGMA journal_mode = WAL;\nPRAGMA page_size = 4096;\nVACUUM;\n\nPRAGMA foreign_keys = ON;\nCREATE TABLE t (id INTEGER);\n")
+	if want := "\nPRAGMA foreign_keys = ON;\nCREATE TABLE t (id INTEGER);\n"; got != want {
+		t.Fatalf("transactional = %q, want %q", got, want)
+	}
+}
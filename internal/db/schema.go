@@ -0,0 +1,57 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Table is a table or view of the run database schema.
+type Table struct {
+	Name    string
+	View    bool
+	Columns []string
+}
+
+// Schema returns the tables and views the migrations create, in name order,
+// by applying them to a scratch in-memory database. Tables internal to
+// SQLite and FTS5 are left out.
+func Schema(ctx context.Context) ([]Table, error) {
+	mem, err := sql.Open("sqlite", "file::memory:")
+	if err != nil {
+		return nil, fmt.Errorf("open scratch db: %w", err)
+	}
+	defer mem.Close()
+	mem.SetMaxOpenConns(1) // every connection would get its own database
+
+	if err := RunMigrations(ctx, mem); err != nil {
+		return nil, err
+	}
+	rows, err := mem.QueryContext(ctx, `
+		SELECT m.name, m.type = 'view', c.name
+		FROM sqlite_schema m, pragma_table_info(m.name) c
+		WHERE m.type IN ('table', 'view')
+		  AND m.name NOT LIKE 'sqlite_%'
+		  AND NOT EXISTS (SELECT 1 FROM sqlite_schema v
+		                  WHERE v.type = 'table' AND v.sql LIKE 'CREATE VIRTUAL TABLE%'
+		                    AND m.name GLOB v.name || '_*')
+		ORDER BY m.name, c.cid;`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var tables []Table
+	for rows.Next() {
+		var name, col string
+		var view bool
+		if err := rows.Scan(&name, &view, &col); err != nil {
+			return nil, err
+		}
+		if n := len(tables); n == 0 || tables[n-1].Name != name {
+			tables = append(tables, Table{Name: name, View: view})
+		}
+		t := &tables[len(tables)-1]
+		t.Columns = append(t.Columns, col)
+	}
+	return tables, rows.Err()
+}
@@ -0,0 +1,80 @@
+PRAGMA foreign_keys = ON;
+
+-- Columns needed to persist the language-neutral IR (internal/ir) on top of the
+-- original schema. Every IR-backed table gets a uid holding the IR UUID so
+-- exports can round-trip identities.
+
+ALTER TABLE project ADD COLUMN uid TEXT;
+ALTER TABLE project ADD COLUMN tool_version TEXT;
+ALTER TABLE project ADD COLUMN ir_schema TEXT;
+
+-- container is the top-level build unit (module/crate/project); every IR
+-- container, top-level or nested, is a package row underneath it.
+ALTER TABLE container ADD COLUMN language TEXT;
+
+ALTER TABLE package ADD COLUMN uid TEXT;
+ALTER TABLE package ADD COLUMN parent_id INTEGER REFERENCES package(id) ON DELETE CASCADE;
+ALTER TABLE package ADD COLUMN language TEXT;
+ALTER TABLE package ADD COLUMN kind TEXT;             -- 'module','package','namespace'
+ALTER TABLE package ADD COLUMN version_tag TEXT;
+ALTER TABLE package ADD COLUMN doc_fmt TEXT;
+ALTER TABLE package ADD COLUMN extra_json TEXT;
+
+ALTER TABLE file ADD COLUMN uid TEXT;
+ALTER TABLE file ADD COLUMN package_id INTEGER REFERENCES package(id) ON DELETE CASCADE;
+ALTER TABLE file ADD COLUMN language TEXT;
+
+ALTER TABLE symbol ADD COLUMN uid TEXT;
+ALTER TABLE symbol ADD COLUMN full_name TEXT;
+ALTER TABLE symbol ADD COLUMN visibility TEXT;
+ALTER TABLE symbol ADD COLUMN flags INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE symbol ADD COLUMN end_line INTEGER;
+ALTER TABLE symbol ADD COLUMN end_col INTEGER;
+ALTER TABLE symbol ADD COLUMN doc_raw TEXT;          -- symbol.doc keeps the cleaned form for search
+ALTER TABLE symbol ADD COLUMN extra_json TEXT;
+
+ALTER TABLE signature ADD COLUMN json TEXT;          -- full Signature JSON; *_json columns are slices of it
+
+ALTER TABLE type_ref ADD COLUMN uid TEXT;
+ALTER TABLE type_ref ADD COLUMN slot TEXT;           -- 'param:<idx>', 'result:<idx>', 'field:<name>', ...
+ALTER TABLE type_ref ADD COLUMN json TEXT;
+ALTER TABLE type_ref ADD COLUMN ord INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE member ADD COLUMN uid TEXT;
+ALTER TABLE member ADD COLUMN ord INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE pkg_import ADD COLUMN details_json TEXT;
+
+-- Overloads, partial declarations and same-named members of different owners
+-- are legitimate in most languages; identity now comes from uid.
+DROP INDEX IF EXISTS ux_symbol_pkg_kind_name_recv;
+
+-- Diagnostics may be project- or container-scoped, so file_id becomes nullable.
+CREATE TABLE diagnostic_new (
+  id            INTEGER PRIMARY KEY,
+  uid           TEXT,
+  file_id       INTEGER REFERENCES file(id) ON DELETE CASCADE,
+  scope         TEXT NOT NULL DEFAULT 'file',  -- 'project','container','file','symbol'
+  severity      TEXT NOT NULL,      -- 'info','warn','error'
+  code          TEXT,               -- tool/source code (e.g., 'types', 'parser')
+  message       TEXT NOT NULL,
+  line          INTEGER,
+  col           INTEGER
+);
+INSERT INTO diagnostic_new (id, file_id, severity, code, message, line, col)
+SELECT id, file_id, severity, code, message, line, col FROM diagnostic;
+DROP TABLE diagnostic;
+ALTER TABLE diagnostic_new RENAME TO diagnostic;
+
+CREATE INDEX IF NOT EXISTS idx_project_uid ON project(uid);
+CREATE INDEX IF NOT EXISTS idx_package_uid ON package(uid);
+CREATE INDEX IF NOT EXISTS idx_package_parent ON package(parent_id);
+CREATE INDEX IF NOT EXISTS idx_file_uid ON file(uid);
+CREATE INDEX IF NOT EXISTS idx_symbol_uid ON symbol(uid);
+CREATE INDEX IF NOT EXISTS idx_symbol_full_name ON symbol(full_name);
+CREATE INDEX IF NOT EXISTS idx_symbol_file ON symbol(file_id);
+CREATE INDEX IF NOT EXISTS idx_member_parent ON member(parent_symbol_id);
+CREATE INDEX IF NOT EXISTS idx_member_child ON member(child_symbol_id);
+CREATE INDEX IF NOT EXISTS idx_diagnostic_file ON diagnostic(file_id);
+
+PRAGMA user_version = 4;
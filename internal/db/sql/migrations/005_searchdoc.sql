@@ -0,0 +1,30 @@
+PRAGMA foreign_keys = ON;
+
+-- search_fts is an external-content table over symbol, but symbol has no pkg
+-- column, so FTS5 could not read documents back for highlight() and
+-- snippet(). Point it at a view that yields exactly the indexed columns.
+CREATE VIEW search_doc AS
+SELECT s.id,
+       s.name,
+       p.import_path AS pkg,
+       s.kind,
+       COALESCE(s.doc,'') AS doc
+FROM symbol s
+JOIN package p ON p.id = s.package_id;
+
+-- The symbol_* triggers keep writing to search_fts by name and need no change.
+DROP TABLE search_fts;
+
+CREATE VIRTUAL TABLE search_fts USING fts5(
+  name,
+  pkg,
+  kind,
+  doc,
+  content='search_doc',
+  content_rowid='id',
+  tokenize='unicode61'
+);
+
+INSERT INTO search_fts(search_fts) VALUES('rebuild');
+
+PRAGMA user_version = 5;
@@ -0,0 +1,87 @@
+PRAGMA foreign_keys = ON;
+
+-- unicode61 keeps ParseHTTPRequest as one token. The store writes the split,
+-- lower-cased words of each name into name_terms ("parse http request") and
+-- search_fts indexes them as their own column. Rows from before this
+-- migration only get snake_case split; re-index to fill them properly.
+ALTER TABLE symbol ADD COLUMN name_terms TEXT;
+UPDATE symbol SET name_terms = lower(replace(name, '_', ' ')) WHERE name_terms IS NULL;
+
+DROP TRIGGER symbol_ai;
+DROP TRIGGER symbol_ad;
+DROP TRIGGER symbol_au;
+DROP TABLE search_fts;
+DROP VIEW search_doc;
+
+CREATE VIEW search_doc AS
+SELECT s.id,
+       s.name,
+       COALESCE(s.name_terms,'') AS terms,
+       p.import_path AS pkg,
+       s.kind,
+       COALESCE(s.doc,'') AS doc
+FROM symbol s
+JOIN package p ON p.id = s.package_id;
+
+CREATE VIRTUAL TABLE search_fts USING fts5(
+  name,
+  terms,
+  pkg,
+  kind,
+  doc,
+  content='search_doc',
+  content_rowid='id',
+  tokenize='unicode61'
+);
+
+-- Substring search on names ("ttpReq" finds ParseHTTPRequest).
+CREATE VIRTUAL TABLE search_trigram USING fts5(
+  name,
+  content='search_doc',
+  content_rowid='id',
+  tokenize='trigram'
+);
+
+INSERT INTO search_fts(search_fts) VALUES('rebuild');
+INSERT INTO search_trigram(search_trigram) VALUES('rebuild');
+
+CREATE TRIGGER symbol_ai AFTER INSERT ON symbol BEGIN
+  INSERT INTO search_fts(rowid, name, terms, pkg, kind, doc)
+  VALUES (
+    new.id,
+    new.name,
+    COALESCE(new.name_terms,''),
+    (SELECT import_path FROM package p WHERE p.id = new.package_id),
+    new.kind,
+    COALESCE(new.doc,'')
+  );
+  INSERT INTO search_trigram(rowid, name) VALUES (new.id, new.name);
+END;
+
+CREATE TRIGGER symbol_ad AFTER DELETE ON symbol BEGIN
+  INSERT INTO search_fts(search_fts, rowid, name, terms, pkg, kind, doc)
+  VALUES ('delete', old.id, old.name, COALESCE(old.name_terms,''),
+          (SELECT import_path FROM package p WHERE p.id = old.package_id),
+          old.kind, COALESCE(old.doc,''));
+  INSERT INTO search_trigram(search_trigram, rowid, name) VALUES ('delete', old.id, old.name);
+END;
+
+CREATE TRIGGER symbol_au AFTER UPDATE ON symbol BEGIN
+  INSERT INTO search_fts(search_fts, rowid, name, terms, pkg, kind, doc)
+  VALUES ('delete', old.id, old.name, COALESCE(old.name_terms,''),
+          (SELECT import_path FROM package p WHERE p.id = old.package_id),
+          old.kind, COALESCE(old.doc,''));
+  INSERT INTO search_fts(rowid, name, terms, pkg, kind, doc)
+  VALUES (
+    new.id,
+    new.name,
+    COALESCE(new.name_terms,''),
+    (SELECT import_path FROM package p WHERE p.id = new.package_id),
+    new.kind,
+    COALESCE(new.doc,'')
+  );
+  INSERT INTO search_trigram(search_trigram, rowid, name) VALUES ('delete', old.id, old.name);
+  INSERT INTO search_trigram(rowid, name) VALUES (new.id, new.name);
+END;
+
+PRAGMA user_version = 6;
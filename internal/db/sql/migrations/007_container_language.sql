@@ -0,0 +1,23 @@
+-- Language packs name their top-level containers independently, so a Python
+-- package and a Rust module may share a path; a container is now unique per
+-- language. SQLite cannot drop a table constraint, so the table is rebuilt;
+-- the migration runs with foreign keys off, so dropping the old table leaves
+-- the file and package rows that reference it in place.
+
+CREATE TABLE container_new (
+  id           INTEGER PRIMARY KEY,
+  project_id   INTEGER NOT NULL REFERENCES project(id) ON DELETE CASCADE,
+  module_path  TEXT,                -- e.g., module path from go.mod (nullable if not available)
+  go_version   TEXT,                -- e.g., "go 1.25"
+  source_hash  TEXT,                -- content hash of the snapshot for reproducibility
+  language     TEXT,
+  UNIQUE(project_id, language, module_path)
+);
+
+INSERT INTO container_new (id, project_id, module_path, go_version, source_hash, language)
+SELECT id, project_id, module_path, go_version, source_hash, language FROM container;
+
+DROP TABLE container;
+ALTER TABLE container_new RENAME TO container;
+
+PRAGMA user_version = 7;
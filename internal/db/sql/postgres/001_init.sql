@@ -0,0 +1,149 @@
+-- Postgres translation of migrations/001_init.sql. Keep the numbering in step
+-- with the SQLite migrations so both backends describe the same schema version.
+--
+-- A shared Postgres holds many runs; everything hangs off a project row, so ids
+-- are identities rather than per-run integers. Booleans stay 0/1 smallints to
+-- match the SQLite columns row for row.
+
+CREATE TABLE project (
+  id           BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+  name         TEXT NOT NULL,
+  root_path    TEXT NOT NULL,
+  created_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE container (
+  id           BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+  project_id   BIGINT NOT NULL REFERENCES project(id) ON DELETE CASCADE,
+  module_path  TEXT,
+  go_version   TEXT,
+  source_hash  TEXT,
+  UNIQUE(project_id, module_path)
+);
+
+CREATE TABLE file (
+  id            BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+  container_id  BIGINT NOT NULL REFERENCES container(id) ON DELETE CASCADE,
+  rel_path      TEXT NOT NULL,
+  pkg_name      TEXT NOT NULL,
+  is_test       SMALLINT NOT NULL CHECK (is_test IN (0,1)),
+  digest        TEXT,
+  size_bytes    BIGINT NOT NULL,
+  mod_time      TEXT,
+  UNIQUE(container_id, rel_path)
+);
+
+CREATE TABLE package (
+  id            BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+  container_id  BIGINT NOT NULL REFERENCES container(id) ON DELETE CASCADE,
+  import_path   TEXT NOT NULL,
+  name          TEXT NOT NULL,
+  doc           TEXT,
+  UNIQUE(container_id, import_path)
+);
+
+CREATE TABLE pkg_import (
+  id           BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+  package_id   BIGINT NOT NULL REFERENCES package(id) ON DELETE CASCADE,
+  path         TEXT NOT NULL,
+  alias        TEXT,
+  is_stdlib    SMALLINT NOT NULL DEFAULT 0 CHECK (is_stdlib IN (0,1))
+);
+
+CREATE TABLE symbol (
+  id            BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+  package_id    BIGINT NOT NULL REFERENCES package(id) ON DELETE CASCADE,
+  file_id       BIGINT REFERENCES file(id) ON DELETE SET NULL,
+  kind          TEXT NOT NULL,
+  name          TEXT NOT NULL,
+  recv_type     TEXT,
+  type_text     TEXT,
+  doc           TEXT,
+  span_start    INTEGER,
+  span_end      INTEGER,
+  line          INTEGER,
+  col           INTEGER,
+  exported      SMALLINT NOT NULL DEFAULT 0 CHECK (exported IN (0,1))
+);
+
+CREATE TABLE signature (
+  id            BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+  symbol_id     BIGINT NOT NULL REFERENCES symbol(id) ON DELETE CASCADE,
+  text          TEXT NOT NULL,
+  params_json   TEXT,
+  results_json  TEXT,
+  type_params_json TEXT
+);
+
+CREATE TABLE relation (
+  id            BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+  from_symbol_id BIGINT NOT NULL REFERENCES symbol(id) ON DELETE CASCADE,
+  to_symbol_id   BIGINT NOT NULL REFERENCES symbol(id) ON DELETE CASCADE,
+  kind          TEXT NOT NULL,
+  detail        TEXT,
+  UNIQUE(from_symbol_id, to_symbol_id, kind)
+);
+
+CREATE TABLE type_ref (
+  id            BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+  symbol_id     BIGINT NOT NULL REFERENCES symbol(id) ON DELETE CASCADE,
+  target_pkg    TEXT,
+  target_name   TEXT NOT NULL,
+  kind          TEXT NOT NULL,
+  pos_byte      INTEGER
+);
+
+CREATE TABLE member (
+  id            BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+  parent_symbol_id BIGINT NOT NULL REFERENCES symbol(id) ON DELETE CASCADE,
+  child_symbol_id  BIGINT REFERENCES symbol(id) ON DELETE SET NULL,
+  name          TEXT NOT NULL,
+  exported      SMALLINT NOT NULL DEFAULT 0 CHECK (exported IN (0,1)),
+  kind          TEXT NOT NULL
+);
+
+CREATE TABLE diagnostic (
+  id            BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+  file_id       BIGINT NOT NULL REFERENCES file(id) ON DELETE CASCADE,
+  severity      TEXT NOT NULL,
+  code          TEXT,
+  message       TEXT NOT NULL,
+  line          INTEGER,
+  col           INTEGER
+);
+
+CREATE TABLE IF NOT EXISTS language (
+  id        TEXT PRIMARY KEY,
+  name      TEXT NOT NULL UNIQUE,
+  ecosystem TEXT,
+  CHECK (length(id) > 0)
+);
+
+CREATE TABLE IF NOT EXISTS source_extension (
+  ext          TEXT PRIMARY KEY,
+  language_id  TEXT NOT NULL REFERENCES language(id) ON DELETE RESTRICT,
+  is_text      SMALLINT NOT NULL DEFAULT 1,
+  is_primary   SMALLINT NOT NULL DEFAULT 1,
+  notes        TEXT
+);
+
+CREATE TABLE IF NOT EXISTS source_basename (
+  name         TEXT PRIMARY KEY,
+  language_id  TEXT NOT NULL REFERENCES language(id) ON DELETE RESTRICT,
+  is_text      SMALLINT NOT NULL DEFAULT 1,
+  notes        TEXT
+);
+
+CREATE UNIQUE INDEX ux_symbol_pkg_kind_name_recv
+  ON symbol(package_id, kind, name, coalesce(recv_type, ''));
+
+CREATE INDEX IF NOT EXISTS idx_file_container_path ON file(container_id, rel_path);
+CREATE INDEX IF NOT EXISTS idx_pkg_container_import ON package(container_id, import_path);
+CREATE INDEX IF NOT EXISTS idx_symbol_pkg_name ON symbol(package_id, name);
+CREATE INDEX IF NOT EXISTS idx_symbol_kind ON symbol(kind);
+CREATE INDEX IF NOT EXISTS idx_relation_from ON relation(from_symbol_id);
+CREATE INDEX IF NOT EXISTS idx_relation_to ON relation(to_symbol_id);
+CREATE INDEX IF NOT EXISTS idx_typeref_symbol ON type_ref(symbol_id);
+CREATE INDEX IF NOT EXISTS idx_pkg_import_pkg ON pkg_import(package_id);
+CREATE INDEX IF NOT EXISTS idx_source_extension_lang ON source_extension(language_id);
+CREATE INDEX IF NOT EXISTS idx_source_basename_lang ON source_basename(language_id);
@@ -0,0 +1,52 @@
+-- Postgres counterpart of the FTS5 search_fts table: one row per symbol with a
+-- weighted tsvector (name > pkg > kind > doc), kept in sync by a trigger the
+-- same way the SQLite triggers maintain the external-content FTS table.
+
+CREATE TABLE search_fts (
+  symbol_id  BIGINT PRIMARY KEY REFERENCES symbol(id) ON DELETE CASCADE,
+  name       TEXT NOT NULL,
+  pkg        TEXT,
+  kind       TEXT NOT NULL,
+  doc        TEXT NOT NULL DEFAULT '',
+  tsv        tsvector GENERATED ALWAYS AS (
+               setweight(to_tsvector('simple', coalesce(name, '')), 'A') ||
+               setweight(to_tsvector('simple', coalesce(pkg, '')), 'B') ||
+               setweight(to_tsvector('simple', coalesce(kind, '')), 'C') ||
+               setweight(to_tsvector('english', coalesce(doc, '')), 'D')
+             ) STORED
+);
+
+CREATE INDEX idx_search_fts_tsv ON search_fts USING GIN (tsv);
+
+INSERT INTO search_fts (symbol_id, name, pkg, kind, doc)
+SELECT s.id,
+       s.name,
+       (SELECT import_path FROM package p WHERE p.id = s.package_id),
+       s.kind,
+       COALESCE(s.doc, '')
+FROM symbol s;
+
+CREATE FUNCTION search_fts_sync() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+  INSERT INTO search_fts (symbol_id, name, pkg, kind, doc)
+  VALUES (
+    NEW.id,
+    NEW.name,
+    (SELECT import_path FROM package p WHERE p.id = NEW.package_id),
+    NEW.kind,
+    COALESCE(NEW.doc, '')
+  )
+  ON CONFLICT (symbol_id) DO UPDATE
+    SET name = EXCLUDED.name,
+        pkg  = EXCLUDED.pkg,
+        kind = EXCLUDED.kind,
+        doc  = EXCLUDED.doc;
+  RETURN NULL;
+END;
+$$;
+
+-- Deletes are handled by the ON DELETE CASCADE on search_fts.symbol_id.
+CREATE TRIGGER symbol_search_sync
+  AFTER INSERT OR UPDATE ON symbol
+  FOR EACH ROW EXECUTE FUNCTION search_fts_sync();
@@ -0,0 +1,57 @@
+-- Postgres translation of migrations/004_ir.sql.
+
+ALTER TABLE project ADD COLUMN uid TEXT;
+ALTER TABLE project ADD COLUMN tool_version TEXT;
+ALTER TABLE project ADD COLUMN ir_schema TEXT;
+
+ALTER TABLE container ADD COLUMN language TEXT;
+
+ALTER TABLE package ADD COLUMN uid TEXT;
+ALTER TABLE package ADD COLUMN parent_id BIGINT REFERENCES package(id) ON DELETE CASCADE;
+ALTER TABLE package ADD COLUMN language TEXT;
+ALTER TABLE package ADD COLUMN kind TEXT;
+ALTER TABLE package ADD COLUMN version_tag TEXT;
+ALTER TABLE package ADD COLUMN doc_fmt TEXT;
+ALTER TABLE package ADD COLUMN extra_json TEXT;
+
+ALTER TABLE file ADD COLUMN uid TEXT;
+ALTER TABLE file ADD COLUMN package_id BIGINT REFERENCES package(id) ON DELETE CASCADE;
+ALTER TABLE file ADD COLUMN language TEXT;
+
+ALTER TABLE symbol ADD COLUMN uid TEXT;
+ALTER TABLE symbol ADD COLUMN full_name TEXT;
+ALTER TABLE symbol ADD COLUMN visibility TEXT;
+ALTER TABLE symbol ADD COLUMN flags INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE symbol ADD COLUMN end_line INTEGER;
+ALTER TABLE symbol ADD COLUMN end_col INTEGER;
+ALTER TABLE symbol ADD COLUMN doc_raw TEXT;
+ALTER TABLE symbol ADD COLUMN extra_json TEXT;
+
+ALTER TABLE signature ADD COLUMN json TEXT;
+
+ALTER TABLE type_ref ADD COLUMN uid TEXT;
+ALTER TABLE type_ref ADD COLUMN slot TEXT;
+ALTER TABLE type_ref ADD COLUMN json TEXT;
+ALTER TABLE type_ref ADD COLUMN ord INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE member ADD COLUMN uid TEXT;
+ALTER TABLE member ADD COLUMN ord INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE pkg_import ADD COLUMN details_json TEXT;
+
+DROP INDEX IF EXISTS ux_symbol_pkg_kind_name_recv;
+
+ALTER TABLE diagnostic ADD COLUMN uid TEXT;
+ALTER TABLE diagnostic ADD COLUMN scope TEXT NOT NULL DEFAULT 'file';
+ALTER TABLE diagnostic ALTER COLUMN file_id DROP NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_project_uid ON project(uid);
+CREATE INDEX IF NOT EXISTS idx_package_uid ON package(uid);
+CREATE INDEX IF NOT EXISTS idx_package_parent ON package(parent_id);
+CREATE INDEX IF NOT EXISTS idx_file_uid ON file(uid);
+CREATE INDEX IF NOT EXISTS idx_symbol_uid ON symbol(uid);
+CREATE INDEX IF NOT EXISTS idx_symbol_full_name ON symbol(full_name);
+CREATE INDEX IF NOT EXISTS idx_symbol_file ON symbol(file_id);
+CREATE INDEX IF NOT EXISTS idx_member_parent ON member(parent_symbol_id);
+CREATE INDEX IF NOT EXISTS idx_member_child ON member(child_symbol_id);
+CREATE INDEX IF NOT EXISTS idx_diagnostic_file ON diagnostic(file_id);
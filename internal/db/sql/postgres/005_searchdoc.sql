@@ -0,0 +1,10 @@
+-- search_fts stores its own columns here; the view mirrors the SQLite one so
+-- readers can fetch indexed documents the same way on both backends.
+CREATE VIEW search_doc AS
+SELECT s.id,
+       s.name,
+       p.import_path AS pkg,
+       s.kind,
+       COALESCE(s.doc, '') AS doc
+FROM symbol s
+JOIN package p ON p.id = s.package_id;
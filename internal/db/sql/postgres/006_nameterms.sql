@@ -0,0 +1,55 @@
+-- Identifier-aware search: name_terms holds the split, lower-cased words of
+-- each name and is indexed with the name at weight A. pg_trgm gives
+-- substring search on names, the counterpart of the SQLite search_trigram.
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+ALTER TABLE symbol ADD COLUMN name_terms TEXT;
+UPDATE symbol SET name_terms = lower(replace(name, '_', ' ')) WHERE name_terms IS NULL;
+
+ALTER TABLE search_fts ADD COLUMN terms TEXT NOT NULL DEFAULT '';
+UPDATE search_fts f SET terms = COALESCE(s.name_terms, '') FROM symbol s WHERE s.id = f.symbol_id;
+
+ALTER TABLE search_fts DROP COLUMN tsv;
+ALTER TABLE search_fts ADD COLUMN tsv tsvector GENERATED ALWAYS AS (
+  setweight(to_tsvector('simple', coalesce(name, '')), 'A') ||
+  setweight(to_tsvector('simple', coalesce(terms, '')), 'A') ||
+  setweight(to_tsvector('simple', coalesce(pkg, '')), 'B') ||
+  setweight(to_tsvector('simple', coalesce(kind, '')), 'C') ||
+  setweight(to_tsvector('english', coalesce(doc, '')), 'D')
+) STORED;
+
+CREATE INDEX idx_search_fts_tsv ON search_fts USING GIN (tsv);
+CREATE INDEX idx_search_fts_name_trgm ON search_fts USING GIN (name gin_trgm_ops);
+
+CREATE OR REPLACE FUNCTION search_fts_sync() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+  INSERT INTO search_fts (symbol_id, name, terms, pkg, kind, doc)
+  VALUES (
+    NEW.id,
+    NEW.name,
+    COALESCE(NEW.name_terms, ''),
+    (SELECT import_path FROM package p WHERE p.id = NEW.package_id),
+    NEW.kind,
+    COALESCE(NEW.doc, '')
+  )
+  ON CONFLICT (symbol_id) DO UPDATE
+    SET name  = EXCLUDED.name,
+        terms = EXCLUDED.terms,
+        pkg   = EXCLUDED.pkg,
+        kind  = EXCLUDED.kind,
+        doc   = EXCLUDED.doc;
+  RETURN NULL;
+END;
+$$;
+
+DROP VIEW search_doc;
+CREATE VIEW search_doc AS
+SELECT s.id,
+       s.name,
+       COALESCE(s.name_terms, '') AS terms,
+       p.import_path AS pkg,
+       s.kind,
+       COALESCE(s.doc, '') AS doc
+FROM symbol s
+JOIN package p ON p.id = s.package_id;
@@ -0,0 +1,5 @@
+-- Postgres translation of migrations/007_container_language.sql.
+
+ALTER TABLE container DROP CONSTRAINT container_project_id_module_path_key;
+ALTER TABLE container ADD CONSTRAINT container_project_id_language_module_path_key
+  UNIQUE (project_id, language, module_path);
@@ -0,0 +1,148 @@
+package emit
+
+import (
+	"strings"
+)
+
+// docBlock is one block of a cleaned doc comment.
+type docBlock struct {
+	Kind  string // "p", "code", "heading" or "list"
+	Text  string // paragraph text, code, or heading
+	Items []string
+}
+
+// entryTitles are the titles, lower-cased, of the doc sections that list
+// entries, as the Python and C# packs write them: "Args:" followed by one
+// indented line per parameter.
+var entryTitles = map[string]bool{
+	"args": true, "arguments": true, "parameters": true, "params": true, "keyword args": true,
+	"other parameters": true, "type parameters": true, "attributes": true, "receives": true,
+	"returns": true, "yields": true, "raises": true, "exceptions": true, "warns": true, "see also": true,
+}
+
+// parseDoc splits doc_fmt text into blocks using the conventions shared by
+// most doc comment styles: blank lines separate paragraphs, indented lines
+// are code, "# Heading" lines are headings and "- "/"* " lines are list items.
+// Indented lines under a line ending in ":" are a section: its own paragraph
+// followed by a list of the entries of "Args:" and the like, each deeper line
+// continuing the entry above, or by the preformatted lines of any other.
+func parseDoc(text string) []docBlock {
+	var (
+		blocks []docBlock
+		cur    []string
+		kind   string
+		base   int // indentation of the entries of a section
+	)
+	flush := func() {
+		if len(cur) == 0 {
+			return
+		}
+		switch kind {
+		case "code":
+			blocks = append(blocks, docBlock{Kind: "code", Text: dedent(cur)})
+		case "list", "entries":
+			blocks = append(blocks, docBlock{Kind: "list", Items: cur})
+		default:
+			blocks = append(blocks, docBlock{Kind: "p", Text: strings.Join(cur, " ")})
+		}
+		cur, kind = nil, ""
+	}
+
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+		indented := strings.HasPrefix(line, "\t") || strings.HasPrefix(line, "  ")
+		if (kind == "code" || kind == "entries") && !indented && trimmed != "" {
+			flush()
+		}
+		switch {
+		case trimmed == "":
+			if kind == "code" {
+				cur = append(cur, "")
+				continue
+			}
+			flush()
+		case kind == "code" && indented:
+			cur = append(cur, line)
+		case kind == "entries":
+			if width := indentWidth(line); width > base {
+				cur[len(cur)-1] += " " + trimmed
+			} else {
+				cur, base = append(cur, trimmed), width
+			}
+		case indented && kind == "p" && strings.HasSuffix(cur[len(cur)-1], ":"):
+			title := cur[len(cur)-1]
+			cur = cur[:len(cur)-1]
+			flush()
+			blocks = append(blocks, docBlock{Kind: "p", Text: title})
+			if entryTitles[strings.ToLower(strings.TrimSuffix(title, ":"))] {
+				kind, cur, base = "entries", []string{trimmed}, indentWidth(line)
+			} else {
+				kind, cur = "code", []string{line}
+			}
+		case strings.HasPrefix(trimmed, "# ") && !indented:
+			flush()
+			blocks = append(blocks, docBlock{Kind: "heading", Text: strings.TrimSpace(trimmed[2:])})
+		case (strings.HasPrefix(trimmed, "- ") || strings.HasPrefix(trimmed, "* ")) && (!indented || kind != "code"):
+			if kind != "list" {
+				flush()
+				kind = "list"
+			}
+			cur = append(cur, strings.TrimSpace(trimmed[2:]))
+		case indented && kind == "":
+			kind = "code"
+			cur = append(cur, line)
+		case kind == "list" && indented:
+			cur[len(cur)-1] += " " + trimmed
+		default:
+			if kind != "" && kind != "p" {
+				flush()
+			}
+			kind = "p"
+			cur = append(cur, trimmed)
+		}
+	}
+	flush()
+	return blocks
+}
+
+// indentWidth returns the width of the indentation of line, a tab counting
+// as four spaces.
+func indentWidth(line string) int {
+	n := 0
+	for _, c := range line {
+		switch c {
+		case ' ':
+			n++
+		case '\t':
+			n += 4
+		default:
+			return n
+		}
+	}
+	return n
+}
+
+// dedent strips trailing blank lines and the indentation common to all lines.
+func dedent(lines []string) string {
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	prefix := ""
+	for i, l := range lines {
+		if strings.TrimSpace(l) == "" {
+			continue
+		}
+		ind := l[:len(l)-len(strings.TrimLeft(l, " \t"))]
+		if i == 0 || prefix == "" && i > 0 && !strings.HasPrefix(ind, prefix) {
+			prefix = ind
+		}
+		for !strings.HasPrefix(l, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	out := make([]string, len(lines))
+	for i, l := range lines {
+		out[i] = strings.TrimPrefix(l, prefix)
+	}
+	return strings.Join(out, "\n")
+}
@@ -0,0 +1,59 @@
+package emit
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ChaseHampton/cargoworker/internal/pack/packtest"
+	_ "github.com/ChaseHampton/cargoworker/internal/pack/python"
+)
+
+// The sections of a docstring, as the Python pack stores them, stay lists
+// and blocks rather than running into one paragraph.
+func TestParseDocSections(t *testing.T) {
+	f := packtest.ExtractTree(t, map[string]string{"io.py": `def save(path, data, mode="w"):
+    """Write data to path.
+
+    Args:
+        path (str): Where to write.
+        data (bytes): What to write, which may be
+            longer than one line.
+        mode: The open mode.
+
+    Returns:
+        int: The number of bytes written.
+
+    Example:
+        >>> save("x", b"1")
+        1
+    """
+`}, packtest.ByExt(map[string]string{".py": "python"}))
+	var text string
+	for _, s := range f.Symbols {
+		if s.Name == "save" {
+			text = s.DocFmt
+		}
+	}
+	want := []docBlock{
+		{Kind: "p", Text: "Write data to path."},
+		{Kind: "p", Text: "Args:"},
+		{Kind: "list", Items: []string{
+			"path (str): Where to write.",
+			"data (bytes): What to write, which may be longer than one line.",
+			"mode: The open mode.",
+		}},
+		{Kind: "p", Text: "Returns:"},
+		{Kind: "list", Items: []string{"int: The number of bytes written."}},
+		{Kind: "p", Text: "Example:"},
+		{Kind: "code", Text: ">>> save(\"x\", b\"1\")\n1"},
+	}
+	if got := parseDoc(text); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseDoc(%q) =\n%+v\nwant\n%+v", text, got, want)
+	}
+
+	// A C# comment formatted with its parameters.
+	got := parseDoc("Opens a file.\n\nParameters:\n    path: Where.\n    mode: How.")
+	if len(got) != 3 || got[1].Text != "Parameters:" || !reflect.DeepEqual(got[2].Items, []string{"path: Where.", "mode: How."}) {
+		t.Errorf("parseDoc = %+v", got)
+	}
+}
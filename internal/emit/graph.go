@@ -0,0 +1,473 @@
+package emit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/ChaseHampton/cargoworker/internal/ir"
+)
+
+type GraphFormat string
+
+const (
+	GraphDOT     GraphFormat = "dot"
+	GraphMermaid GraphFormat = "mermaid"
+	GraphML      GraphFormat = "graphml"
+)
+
+func ParseGraphFormat(s string) (GraphFormat, error) {
+	switch f := GraphFormat(strings.ToLower(strings.TrimSpace(s))); f {
+	case GraphDOT, "":
+		return GraphDOT, nil
+	case GraphMermaid, GraphML:
+		return f, nil
+	default:
+		return "", fmt.Errorf("graph: unknown format %q (want dot|mermaid|graphml)", s)
+	}
+}
+
+// Edge directions followed from GraphOptions.Root.
+const (
+	DirOut  = "out"
+	DirIn   = "in"
+	DirBoth = "both"
+)
+
+// ImportEdge is the edge kind of pkg_import rows in package graphs.
+const ImportEdge = "imports"
+
+type GraphOptions struct {
+	Format GraphFormat
+	// Kinds allowlists edge kinds (relation kinds, and ImportEdge when
+	// collapsed). Empty keeps all.
+	Kinds []string
+	// Root limits the graph to what is reachable from a symbol (full name)
+	// or package (full name) within Depth hops along Direction. A package
+	// root in a symbol graph starts from all of its symbols.
+	Root      string
+	Depth     int // 0 is unlimited
+	Direction string
+	// Collapse draws packages instead of symbols: relations between symbols
+	// become edges between their packages (weighted by count) and pkg_import
+	// rows between packages of the run become ImportEdge edges.
+	Collapse bool
+}
+
+type GraphResult struct {
+	Nodes int `json:"nodes"`
+	Edges int `json:"edges"`
+}
+
+type graphNode struct {
+	id    string // output id, assigned after sorting
+	key   uuid.UUID
+	Label string
+	Kind  string
+	Group string // package full name of symbols; empty for packages
+	root  bool
+}
+
+type graphEdge struct {
+	From, To *graphNode
+	Kind     string
+	Weight   int
+}
+
+type graph struct {
+	Name  string
+	Nodes []*graphNode
+	Edges []*graphEdge
+}
+
+// WriteGraph renders the relation graph of the run (or its package graph
+// with Collapse) to w. Without a root, nodes that have no edge after
+// filtering are left out.
+func WriteGraph(ctx context.Context, rdb *sql.DB, w io.Writer, opts GraphOptions) (*GraphResult, error) {
+	p, f, err := Load(ctx, rdb)
+	if err != nil {
+		return nil, err
+	}
+	g, err := buildGraph(p, f, opts)
+	if err != nil {
+		return nil, err
+	}
+	switch opts.Format {
+	case GraphDOT, "":
+		err = g.writeDOT(w)
+	case GraphMermaid:
+		err = g.writeMermaid(w)
+	case GraphML:
+		err = g.writeGraphML(w)
+	default:
+		err = fmt.Errorf("graph: unknown format %q", opts.Format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &GraphResult{Nodes: len(g.Nodes), Edges: len(g.Edges)}, nil
+}
+
+func buildGraph(p *ir.Project, f *ir.Fragment, opts GraphOptions) (*graph, error) {
+	allow := map[string]bool{}
+	for _, k := range opts.Kinds {
+		if k = strings.TrimSpace(k); k != "" {
+			allow[k] = true
+		}
+	}
+	allowed := func(kind string) bool { return len(allow) == 0 || allow[kind] }
+
+	g := &graph{Name: "cargoworker"}
+	if p != nil && p.Name != "" {
+		g.Name = p.Name
+	}
+
+	containers := make(map[uuid.UUID]ir.Container, len(f.Containers))
+	byPath := make(map[string]uuid.UUID, len(f.Containers))
+	for _, c := range f.Containers {
+		containers[c.Id] = c
+		byPath[c.FullName] = c.Id
+	}
+	symbols := make(map[uuid.UUID]ir.Symbol, len(f.Symbols))
+	for _, s := range f.Symbols {
+		symbols[s.Id] = s
+	}
+
+	nodes := map[uuid.UUID]*graphNode{}
+	node := func(id uuid.UUID) *graphNode {
+		if n, ok := nodes[id]; ok {
+			return n
+		}
+		var n *graphNode
+		if opts.Collapse {
+			c := containers[id]
+			n = &graphNode{key: id, Label: c.FullName, Kind: c.Kind}
+		} else {
+			s := symbols[id]
+			c := containers[s.ContainerId]
+			label := s.Name
+			if rest := strings.TrimPrefix(s.FullName, c.FullName+"."); s.FullName != "" && rest != s.FullName {
+				label = rest
+			}
+			n = &graphNode{key: id, Label: label, Kind: s.Kind, Group: c.FullName}
+		}
+		nodes[id] = n
+		return n
+	}
+
+	type edgeKey struct {
+		from, to uuid.UUID
+		kind     string
+	}
+	edges := map[edgeKey]*graphEdge{}
+	addEdge := func(from, to uuid.UUID, kind string) {
+		if !allowed(kind) || (opts.Collapse && from == to) {
+			return
+		}
+		k := edgeKey{from, to, kind}
+		if e, ok := edges[k]; ok {
+			e.Weight++
+			return
+		}
+		edges[k] = &graphEdge{From: node(from), To: node(to), Kind: kind, Weight: 1}
+	}
+
+	for _, r := range f.Relations {
+		src, ok1 := symbols[r.SourceSymbolId]
+		dst, ok2 := symbols[r.DstSymbolId]
+		if !ok1 || !ok2 {
+			continue
+		}
+		if opts.Collapse {
+			addEdge(src.ContainerId, dst.ContainerId, r.Relation)
+		} else {
+			addEdge(src.Id, dst.Id, r.Relation)
+		}
+	}
+	if opts.Collapse {
+		for _, im := range f.Imports {
+			if to, ok := byPath[im.Target]; ok {
+				addEdge(im.ContainerId, to, ImportEdge)
+			}
+		}
+	}
+
+	var roots []*graphNode
+	if opts.Root != "" {
+		if opts.Collapse {
+			id, ok := byPath[opts.Root]
+			if !ok {
+				return nil, fmt.Errorf("graph: root package %q not found", opts.Root)
+			}
+			roots = append(roots, node(id))
+		} else {
+			for _, s := range f.Symbols {
+				if s.FullName == opts.Root || containers[s.ContainerId].FullName == opts.Root {
+					roots = append(roots, node(s.Id))
+				}
+			}
+			if len(roots) == 0 {
+				return nil, fmt.Errorf("graph: root %q matches no symbol or package", opts.Root)
+			}
+		}
+		for _, r := range roots {
+			r.root = true
+		}
+	}
+
+	all := make([]*graphEdge, 0, len(edges))
+	for _, e := range edges {
+		all = append(all, e)
+	}
+	keep := map[*graphNode]bool{}
+	if roots == nil {
+		for _, e := range all {
+			keep[e.From], keep[e.To] = true, true
+		}
+	} else {
+		keep = reachable(roots, all, opts.Depth, opts.Direction)
+	}
+	for _, n := range nodes {
+		if keep[n] {
+			g.Nodes = append(g.Nodes, n)
+		}
+	}
+	for _, e := range all {
+		if keep[e.From] && keep[e.To] {
+			g.Edges = append(g.Edges, e)
+		}
+	}
+
+	sort.Slice(g.Nodes, func(i, j int) bool {
+		a, b := g.Nodes[i], g.Nodes[j]
+		if a.Group != b.Group {
+			return a.Group < b.Group
+		}
+		if a.Label != b.Label {
+			return a.Label < b.Label
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.key.String() < b.key.String()
+	})
+	index := make(map[*graphNode]int, len(g.Nodes))
+	for i, n := range g.Nodes {
+		n.id = fmt.Sprintf("n%d", i+1)
+		index[n] = i
+	}
+	sort.Slice(g.Edges, func(i, j int) bool {
+		a, b := g.Edges[i], g.Edges[j]
+		if index[a.From] != index[b.From] {
+			return index[a.From] < index[b.From]
+		}
+		if index[a.To] != index[b.To] {
+			return index[a.To] < index[b.To]
+		}
+		return a.Kind < b.Kind
+	})
+	return g, nil
+}
+
+// reachable walks edges breadth-first from roots up to depth hops (0 for no
+// limit) in the given direction.
+func reachable(roots []*graphNode, edges []*graphEdge, depth int, dir string) map[*graphNode]bool {
+	out := map[*graphNode][]*graphNode{}
+	for _, e := range edges {
+		if dir != DirIn {
+			out[e.From] = append(out[e.From], e.To)
+		}
+		if dir == DirIn || dir == DirBoth || dir == "" {
+			out[e.To] = append(out[e.To], e.From)
+		}
+	}
+	seen := map[*graphNode]bool{}
+	frontier := roots
+	for _, r := range roots {
+		seen[r] = true
+	}
+	for hop := 0; len(frontier) > 0 && (depth <= 0 || hop < depth); hop++ {
+		var next []*graphNode
+		for _, n := range frontier {
+			for _, m := range out[n] {
+				if !seen[m] {
+					seen[m] = true
+					next = append(next, m)
+				}
+			}
+		}
+		frontier = next
+	}
+	return seen
+}
+
+func (g *graph) writeDOT(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph %s {\n", dotQuote(g.Name))
+	b.WriteString("  rankdir=LR;\n  node [shape=box, fontname=\"Helvetica\", fontsize=10];\n  edge [fontname=\"Helvetica\", fontsize=9];\n")
+
+	cluster := 0
+	for i := 0; i < len(g.Nodes); {
+		group := g.Nodes[i].Group
+		indent := "  "
+		if group != "" {
+			cluster++
+			fmt.Fprintf(&b, "  subgraph cluster_%d {\n    label=%s;\n", cluster, dotQuote(group))
+			indent = "    "
+		}
+		for ; i < len(g.Nodes) && g.Nodes[i].Group == group; i++ {
+			n := g.Nodes[i]
+			attrs := fmt.Sprintf("label=%s, tooltip=%s", dotQuote(n.Label), dotQuote(n.Kind))
+			if n.root {
+				attrs += ", style=bold"
+			}
+			fmt.Fprintf(&b, "%s%s [%s];\n", indent, n.id, attrs)
+		}
+		if group != "" {
+			b.WriteString("  }\n")
+		}
+	}
+	for _, e := range g.Edges {
+		label := e.Kind
+		if e.Weight > 1 {
+			label = fmt.Sprintf("%s (%d)", e.Kind, e.Weight)
+		}
+		fmt.Fprintf(&b, "  %s -> %s [label=%s];\n", e.From.id, e.To.id, dotQuote(label))
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+func (g *graph) writeMermaid(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+	sub := 0
+	for i := 0; i < len(g.Nodes); {
+		group := g.Nodes[i].Group
+		indent := "  "
+		if group != "" {
+			sub++
+			fmt.Fprintf(&b, "  subgraph g%d[%s]\n", sub, mermaidQuote(group))
+			indent = "    "
+		}
+		for ; i < len(g.Nodes) && g.Nodes[i].Group == group; i++ {
+			n := g.Nodes[i]
+			fmt.Fprintf(&b, "%s%s[%s]\n", indent, n.id, mermaidQuote(n.Label))
+		}
+		if group != "" {
+			b.WriteString("  end\n")
+		}
+	}
+	for _, e := range g.Edges {
+		label := e.Kind
+		if e.Weight > 1 {
+			label = fmt.Sprintf("%s (%d)", e.Kind, e.Weight)
+		}
+		fmt.Fprintf(&b, "  %s -->|%s| %s\n", e.From.id, mermaidQuote(label), e.To.id)
+	}
+	for _, n := range g.Nodes {
+		if n.root {
+			fmt.Fprintf(&b, "  style %s stroke-width:3px\n", n.id)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// mermaidQuote makes s safe inside a quoted Mermaid label.
+func mermaidQuote(s string) string {
+	return `"` + strings.NewReplacer(`"`, "#quot;", "\n", " ", "|", "#124;").Replace(s) + `"`
+}
+
+type gmlDoc struct {
+	XMLName xml.Name `xml:"graphml"`
+	Xmlns   string   `xml:"xmlns,attr"`
+	Keys    []gmlKey `xml:"key"`
+	Graph   gmlGraph `xml:"graph"`
+}
+
+type gmlKey struct {
+	ID   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+
+type gmlGraph struct {
+	ID          string    `xml:"id,attr"`
+	EdgeDefault string    `xml:"edgedefault,attr"`
+	Nodes       []gmlNode `xml:"node"`
+	Edges       []gmlEdge `xml:"edge"`
+}
+
+type gmlNode struct {
+	ID   string    `xml:"id,attr"`
+	Data []gmlData `xml:"data"`
+}
+
+type gmlEdge struct {
+	ID     string    `xml:"id,attr"`
+	Source string    `xml:"source,attr"`
+	Target string    `xml:"target,attr"`
+	Data   []gmlData `xml:"data"`
+}
+
+type gmlData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+func (g *graph) writeGraphML(w io.Writer) error {
+	doc := gmlDoc{
+		Xmlns: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []gmlKey{
+			{ID: "label", For: "node", Name: "label", Type: "string"},
+			{ID: "kind", For: "node", Name: "kind", Type: "string"},
+			{ID: "package", For: "node", Name: "package", Type: "string"},
+			{ID: "uid", For: "node", Name: "uid", Type: "string"},
+			{ID: "root", For: "node", Name: "root", Type: "boolean"},
+			{ID: "relation", For: "edge", Name: "relation", Type: "string"},
+			{ID: "weight", For: "edge", Name: "weight", Type: "int"},
+		},
+		Graph: gmlGraph{ID: g.Name, EdgeDefault: "directed"},
+	}
+	for _, n := range g.Nodes {
+		data := []gmlData{{"label", n.Label}, {"kind", n.Kind}}
+		if n.Group != "" {
+			data = append(data, gmlData{"package", n.Group})
+		}
+		data = append(data, gmlData{"uid", n.key.String()})
+		if n.root {
+			data = append(data, gmlData{"root", "true"})
+		}
+		doc.Graph.Nodes = append(doc.Graph.Nodes, gmlNode{ID: n.id, Data: data})
+	}
+	for i, e := range g.Edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, gmlEdge{
+			ID: fmt.Sprintf("e%d", i+1), Source: e.From.id, Target: e.To.id,
+			Data: []gmlData{{"relation", e.Kind}, {"weight", fmt.Sprint(e.Weight)}},
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
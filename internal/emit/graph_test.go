@@ -0,0 +1,93 @@
+package emit
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/ChaseHampton/cargoworker/internal/ir"
+)
+
+func TestWriteGraph(t *testing.T) {
+	ctx := context.Background()
+	p, f := testRun()
+	sub := f.Containers[0].Id
+	f.Imports = append(f.Imports, ir.Import{ContainerId: sub, Target: "example.com/mod"})
+	rdb := writeDB(t, ctx, p, f)
+
+	render := func(opts GraphOptions) string {
+		t.Helper()
+		var buf bytes.Buffer
+		if _, err := WriteGraph(ctx, rdb, &buf, opts); err != nil {
+			t.Fatalf("graph %+v: %v", opts, err)
+		}
+		return buf.String()
+	}
+
+	dot := render(GraphOptions{Format: GraphDOT})
+	for _, want := range []string{
+		"digraph \"mod\" {\n",
+		"  subgraph cluster_1 {\n    label=\"example.com/mod\";\n    n1 [label=\"Reader\", tooltip=\"interface\"];\n  }\n",
+		"    n2 [label=\"File\", tooltip=\"struct\"];\n",
+		"  n2 -> n1 [label=\"implements\"];\n",
+	} {
+		if !strings.Contains(dot, want) {
+			t.Errorf("dot lacks %q:\n%s", want, dot)
+		}
+	}
+	if strings.Contains(dot, "Read\"") {
+		t.Errorf("dot includes a symbol without edges:\n%s", dot)
+	}
+	if again := render(GraphOptions{Format: GraphDOT}); again != dot {
+		t.Errorf("dot output not stable:\n%s\n---\n%s", dot, again)
+	}
+
+	pkgs := render(GraphOptions{Format: GraphMermaid, Collapse: true})
+	want := "flowchart LR\n" +
+		"  n1[\"example.com/mod\"]\n" +
+		"  n2[\"example.com/mod/sub\"]\n" +
+		"  n2 -->|\"implements\"| n1\n" +
+		"  n2 -->|\"imports\"| n1\n"
+	if pkgs != want {
+		t.Errorf("mermaid =\n%s\nwant\n%s", pkgs, want)
+	}
+	if got := render(GraphOptions{Format: GraphMermaid, Collapse: true, Kinds: []string{ImportEdge}}); strings.Contains(got, "implements") {
+		t.Errorf("kinds allowlist ignored:\n%s", got)
+	}
+
+	ml := render(GraphOptions{Format: GraphML, Root: "example.com/mod/sub", Depth: 1, Direction: DirOut})
+	var doc gmlDoc
+	if err := xml.Unmarshal([]byte(ml), &doc); err != nil {
+		t.Fatalf("graphml: %v\n%s", err, ml)
+	}
+	if len(doc.Graph.Nodes) != 3 || len(doc.Graph.Edges) != 1 {
+		t.Fatalf("graphml has %d nodes, %d edges:\n%s", len(doc.Graph.Nodes), len(doc.Graph.Edges), ml)
+	}
+
+	// Depth and direction: following only incoming edges from Reader reaches File.
+	in := render(GraphOptions{Root: "example.com/mod.Reader", Depth: 1, Direction: DirIn})
+	if !strings.Contains(in, "File") {
+		t.Errorf("incoming walk missed File:\n%s", in)
+	}
+	out := render(GraphOptions{Root: "example.com/mod.Reader", Depth: 1, Direction: DirOut})
+	if strings.Contains(out, "File") {
+		t.Errorf("outgoing walk reached File:\n%s", out)
+	}
+
+	if _, err := WriteGraph(ctx, rdb, &bytes.Buffer{}, GraphOptions{Root: "nope"}); err == nil {
+		t.Error("expected error for unknown root")
+	}
+}
+
+func TestParseGraphFormat(t *testing.T) {
+	for in, want := range map[string]GraphFormat{"": GraphDOT, "DOT": GraphDOT, "mermaid": GraphMermaid, "graphml": GraphML} {
+		if got, err := ParseGraphFormat(in); err != nil || got != want {
+			t.Errorf("ParseGraphFormat(%q) = %q, %v", in, got, err)
+		}
+	}
+	if _, err := ParseGraphFormat("svg"); err == nil {
+		t.Error("expected error for svg")
+	}
+}
@@ -0,0 +1,243 @@
+package emit
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"html"
+	"html/template"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+//go:embed html
+var htmlFS embed.FS
+
+// HTMLResult summarizes a WriteHTML run.
+type HTMLResult struct {
+	Pages   int `json:"pages"`
+	Symbols int `json:"symbols"`
+	Indexed int `json:"indexed"` // search index entries
+}
+
+// WriteHTML renders the run as a static site in dir: index.html, one page per
+// container named after its full name, and assets/ with the stylesheet, the
+// search script and the search index. Everything is linked relatively and
+// the index is a script rather than JSON so pages work from file:// without
+// a server. Existing files with the same names are overwritten.
+func WriteHTML(ctx context.Context, rdb *sql.DB, dir string) (*HTMLResult, error) {
+	m, err := buildModel(ctx, rdb)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := template.New("site").Funcs(htmlFuncs(m)).ParseFS(htmlFS, "html/*.html")
+	if err != nil {
+		return nil, fmt.Errorf("parse templates: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "assets"), 0o755); err != nil {
+		return nil, err
+	}
+
+	res := &HTMLResult{Symbols: len(m.symbols)}
+	page := htmlPage{Model: m, ProjectName: "Documentation", Symbols: len(m.symbols)}
+	if m.Project != nil {
+		page.ProjectName, page.RootURI = m.Project.Name, m.Project.RootUri
+	}
+
+	page.Title = page.ProjectName
+	if err := renderFile(tmpl, "index.html", page, filepath.Join(dir, "index.html")); err != nil {
+		return nil, err
+	}
+	for _, dc := range m.Containers {
+		p := page
+		p.Container = dc
+		p.Title = dc.FullName + " - " + page.ProjectName
+		if err := renderFile(tmpl, "container.html", p, filepath.Join(dir, dc.Page+".html")); err != nil {
+			return nil, err
+		}
+		res.Pages++
+	}
+
+	assets, err := fs.Sub(htmlFS, "html/assets")
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range []string{"style.css", "search.js"} {
+		b, err := fs.ReadFile(assets, name)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(filepath.Join(dir, "assets", name), b, 0o644); err != nil {
+			return nil, err
+		}
+	}
+
+	idx, n, err := searchIndex(ctx, rdb, m)
+	if err != nil {
+		return nil, fmt.Errorf("search index: %w", err)
+	}
+	res.Indexed = n
+	if err := os.WriteFile(filepath.Join(dir, "assets", "search-index.js"), idx, 0o644); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+type htmlPage struct {
+	Title       string
+	ProjectName string
+	RootURI     string
+	Symbols     int
+	Model       *docModel
+	Container   *docContainer
+}
+
+// htmlBlock is a docBlock with its inline text escaped and linked.
+type htmlBlock struct {
+	Kind      string
+	Text      string
+	HTML      template.HTML
+	ItemsHTML []template.HTML
+}
+
+func renderFile(tmpl *template.Template, name string, data any, path string) error {
+	var buf bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&buf, name, data); err != nil {
+		return fmt.Errorf("render %s: %w", filepath.Base(path), err)
+	}
+	return os.WriteFile(path, buf.Bytes(), 0o644)
+}
+
+func htmlFuncs(m *docModel) template.FuncMap {
+	return template.FuncMap{
+		"href": href,
+		"qual": qualName,
+		"doc": func(ds *docSymbol) []htmlBlock {
+			return m.docHTML(ds.Container, ds.DocFmt, ds.DocRaw)
+		},
+		"containerDoc": func(dc *docContainer) []htmlBlock {
+			return m.docHTML(dc, dc.DocFmt, dc.DocRaw)
+		},
+		"crumbs": func(dc *docContainer) []*docContainer {
+			var out []*docContainer
+			for p := dc.Parent; p != nil && len(out) < 64; p = p.Parent {
+				out = append([]*docContainer{p}, out...)
+			}
+			return out
+		},
+	}
+}
+
+// href links to ds from any page of the site.
+func href(ds *docSymbol) string {
+	return ds.Home().Page + ".html#" + ds.Anchor
+}
+
+// qualName is the display name of a cross-link: container name, owner and
+// symbol name ("sub.File.Read").
+func qualName(ds *docSymbol) string {
+	name := ds.Name
+	for o, n := ds.Owner, 0; o != nil && n < 64; o, n = o.Owner, n+1 {
+		name = o.Name + "." + name
+	}
+	return ds.Home().Name + "." + name
+}
+
+var (
+	urlRe     = regexp.MustCompile(`https?://[^\s<>"]+[^\s<>".,;:!?)]`)
+	docLinkRe = regexp.MustCompile(`\[([A-Za-z_][\w./-]*)\]`)
+)
+
+// docHTML renders doc_fmt (falling back to the raw doc) as blocks. URLs are
+// linked, and doc links in brackets ("[Reader]", "[io.Reader]") become
+// cross-links when they name a symbol of the run.
+func (m *docModel) docHTML(dc *docContainer, text, fallback string) []htmlBlock {
+	if strings.TrimSpace(text) == "" {
+		text = fallback
+	}
+	blocks := parseDoc(text)
+	out := make([]htmlBlock, len(blocks))
+	for i, b := range blocks {
+		hb := htmlBlock{Kind: b.Kind, Text: b.Text, HTML: m.inlineHTML(dc, b.Text)}
+		for _, it := range b.Items {
+			hb.ItemsHTML = append(hb.ItemsHTML, m.inlineHTML(dc, it))
+		}
+		out[i] = hb
+	}
+	return out
+}
+
+func (m *docModel) inlineHTML(dc *docContainer, text string) template.HTML {
+	s := html.EscapeString(text)
+	s = urlRe.ReplaceAllStringFunc(s, func(u string) string {
+		return `<a href="` + u + `">` + u + `</a>`
+	})
+	s = docLinkRe.ReplaceAllStringFunc(s, func(match string) string {
+		name := match[1 : len(match)-1]
+		target := m.byName[dc.FullName+"."+name]
+		if target == nil {
+			target = m.byName[name]
+		}
+		if target == nil {
+			return match
+		}
+		return `<a href="` + html.EscapeString(href(target)) + `"><code>` + name + `</code></a>`
+	})
+	return template.HTML(s)
+}
+
+// searchIndex builds assets/search-index.js from search_fts, so the client
+// searches the same name, identifier words, package, kind and doc columns as
+// the search command.
+func searchIndex(ctx context.Context, rdb *sql.DB, m *docModel) ([]byte, int, error) {
+	rows, err := rdb.QueryContext(ctx, `
+		SELECT s.id, s.uid, f.name, f.terms, f.kind, f.pkg, substr(f.doc, 1, 160)
+		FROM search_fts f
+		JOIN symbol s ON s.id = f.rowid
+		ORDER BY f.pkg, f.name, f.kind, s.uid, s.id;`)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var entries [][6]string
+	for rows.Next() {
+		var (
+			id                          int64
+			u                           sql.NullString
+			name, terms, kind, pkg, doc sql.NullString
+		)
+		if err := rows.Scan(&id, &u, &name, &terms, &kind, &pkg, &doc); err != nil {
+			return nil, 0, err
+		}
+		ds := m.symbols[uid("symbol", id, u)]
+		if ds == nil {
+			continue
+		}
+		entries = append(entries, [6]string{name.String, terms.String, kind.String, pkg.String, href(ds),
+			strings.Join(strings.Fields(doc.String), " ")})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	if entries == nil {
+		entries = [][6]string{}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("window.CARGOWORKER_INDEX = ")
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(true) // the index is loaded as a script
+	if err := enc.Encode(entries); err != nil {
+		return nil, 0, err
+	}
+	buf.Truncate(buf.Len() - 1) // Encode's trailing newline
+	buf.WriteString(";\n")
+	return buf.Bytes(), len(entries), nil
+}
@@ -0,0 +1,86 @@
+// Client-side symbol search over search-index.js. Entries are
+// [name, terms, kind, pkg, href, doc]; terms holds the identifier words of
+// the name ("parse http request") as indexed in search_fts.
+(function () {
+  "use strict";
+  var index = window.CARGOWORKER_INDEX || [];
+  var input = document.getElementById("q");
+  var list = document.getElementById("results");
+  if (!input || !list) return;
+
+  function words(s) {
+    return s
+      .replace(/([a-z0-9])([A-Z])/g, "$1 $2")
+      .replace(/([A-Z]+)([A-Z][a-z])/g, "$1 $2")
+      .toLowerCase()
+      .split(/[^a-z0-9]+/)
+      .filter(Boolean);
+  }
+
+  function score(e, q, qw) {
+    var name = e[0].toLowerCase();
+    if (name === q) return 1000;
+    var s = 0;
+    if (name.indexOf(q) === 0) s += 500 - name.length;
+    else if (name.indexOf(q) > 0) s += 200 - name.length;
+    var terms = e[1].split(" ");
+    var hay = (e[3] + " " + e[5]).toLowerCase();
+    for (var i = 0; i < qw.length; i++) {
+      var w = qw[i], hit = false;
+      for (var j = 0; j < terms.length; j++) {
+        if (terms[j].indexOf(w) === 0) { s += 40; hit = true; break; }
+      }
+      if (!hit) {
+        if (hay.indexOf(w) >= 0) s += 5;
+        else return 0;
+      }
+    }
+    return s;
+  }
+
+  function render(q) {
+    list.innerHTML = "";
+    q = q.trim();
+    if (!q) { list.hidden = true; return; }
+    var lq = q.toLowerCase().replace(/\s+/g, "");
+    var qw = words(q);
+    var hits = [];
+    for (var i = 0; i < index.length; i++) {
+      var s = score(index[i], lq, qw);
+      if (s > 0) hits.push([s, index[i]]);
+    }
+    hits.sort(function (a, b) { return b[0] - a[0] || (a[1][0] < b[1][0] ? -1 : a[1][0] > b[1][0] ? 1 : 0); });
+    hits.slice(0, 50).forEach(function (h) {
+      var e = h[1];
+      var li = document.createElement("li");
+      var a = document.createElement("a");
+      a.href = e[4];
+      a.textContent = e[0];
+      var meta = document.createElement("span");
+      meta.className = "pkg";
+      meta.textContent = e[2] + " · " + e[3];
+      a.appendChild(meta);
+      if (e[5]) a.title = e[5];
+      li.appendChild(a);
+      list.appendChild(li);
+    });
+    list.hidden = hits.length === 0;
+  }
+
+  input.addEventListener("input", function () { render(input.value); });
+  input.addEventListener("keydown", function (ev) {
+    if (ev.key === "Enter") {
+      var first = list.querySelector("a");
+      if (first) window.location.href = first.href;
+    } else if (ev.key === "Escape") {
+      input.value = "";
+      render("");
+    }
+  });
+  document.addEventListener("keydown", function (ev) {
+    if (ev.key === "/" && document.activeElement !== input) {
+      ev.preventDefault();
+      input.focus();
+    }
+  });
+})();
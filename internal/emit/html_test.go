@@ -0,0 +1,84 @@
+package emit
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteHTML(t *testing.T) {
+	ctx := context.Background()
+	p, f := testRun()
+	f.Symbols[2].DocFmt = "Reader reads.\n\nSee [File] and https://example.com/doc.\n\n\tr := New()"
+	rdb := writeDB(t, ctx, p, f)
+
+	dir := t.TempDir()
+	res, err := WriteHTML(ctx, rdb, dir)
+	if err != nil {
+		t.Fatalf("html: %v", err)
+	}
+	if res.Pages != 2 || res.Symbols != 3 || res.Indexed != 3 {
+		t.Fatalf("result = %+v", res)
+	}
+
+	read := func(name string) string {
+		b, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("read %s: %v", name, err)
+		}
+		return string(b)
+	}
+	index := read("index.html")
+	if !strings.Contains(index, `href="example.com_mod.html"`) || !strings.Contains(index, `href="example.com_mod_sub.html"`) {
+		t.Fatalf("index lacks container pages:\n%s", index)
+	}
+
+	sub := read("example.com_mod_sub.html")
+	for _, want := range []string{
+		`id="File"`, `id="File.Read"`, // member nested under its receiver
+		`<pre class="sig"><code>func (f *File) Read(p []byte) (int, error)</code></pre>`,
+		`implements → <a href="example.com_mod.html#Reader">mod.Reader</a>`,
+		`<a href="example.com_mod.html">mod</a> /`, // breadcrumb
+	} {
+		if !strings.Contains(sub, want) {
+			t.Errorf("sub page lacks %q", want)
+		}
+	}
+	if strings.Count(sub, `id="Read"`) != 0 {
+		t.Errorf("method rendered outside its receiver")
+	}
+
+	mod := read("example.com_mod.html")
+	for _, want := range []string{
+		`implements ← <a href="example.com_mod_sub.html#File">sub.File</a>`,
+		`<a href="https://example.com/doc">https://example.com/doc</a>`,
+		`<pre><code>r := New()</code></pre>`,
+	} {
+		if !strings.Contains(mod, want) {
+			t.Errorf("mod page lacks %q", want)
+		}
+	}
+
+	idx := read("assets/search-index.js")
+	if !strings.HasPrefix(idx, "window.CARGOWORKER_INDEX = [") || !strings.Contains(idx, `"example.com_mod_sub.html#File.Read"`) {
+		t.Fatalf("search index = %s", idx)
+	}
+	read("assets/style.css")
+	read("assets/search.js")
+}
+
+func TestParseDoc(t *testing.T) {
+	blocks := parseDoc("Intro line\ncontinues.\n\n# Usage\n\n\tgo run .\n\n\tmore\n- one\n- two\n\nEnd.")
+	var kinds []string
+	for _, b := range blocks {
+		kinds = append(kinds, b.Kind)
+	}
+	if got := strings.Join(kinds, ","); got != "p,heading,code,list,p" {
+		t.Fatalf("kinds = %s (%+v)", got, blocks)
+	}
+	if blocks[0].Text != "Intro line continues." || blocks[2].Text != "go run .\n\nmore" || len(blocks[3].Items) != 2 {
+		t.Fatalf("blocks = %+v", blocks)
+	}
+}
@@ -0,0 +1,130 @@
+package emit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ChaseHampton/cargoworker/internal/ir"
+)
+
+// Line is one JSONL record: the record type and the ir value as its JSON
+// encoding, e.g. {"type":"symbol","data":{"id":"…","name":"Reader",…}}.
+type Line struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// WriteJSONL streams every IR record of the run to w, one tagged JSON object
+// per line, in Walk order. It returns the number of lines written.
+func WriteJSONL(ctx context.Context, rdb *sql.DB, w io.Writer) (int, error) {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	enc.SetEscapeHTML(false)
+
+	n := 0
+	err := Walk(ctx, rdb, func(typ string, rec any) error {
+		data, err := marshal(rec)
+		if err != nil {
+			return err
+		}
+		n++
+		return enc.Encode(Line{Type: typ, Data: data})
+	})
+	if err != nil {
+		return n, err
+	}
+	return n, bw.Flush()
+}
+
+func marshal(v any) (json.RawMessage, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+// ReadJSONL parses the output of WriteJSONL. The stream must hold exactly one
+// project record; every other record is collected into one fragment, ready to
+// be written through a store. Blank lines are ignored.
+func ReadJSONL(r io.Reader) (*ir.Project, *ir.Fragment, error) {
+	var (
+		p  *ir.Project
+		f  = &ir.Fragment{}
+		br = bufio.NewReader(r)
+	)
+	for lineNo := 1; ; lineNo++ {
+		raw, err := br.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, nil, err
+		}
+		if b := bytes.TrimSpace(raw); len(b) > 0 {
+			rec, err := decodeLine(b)
+			if err != nil {
+				return nil, nil, fmt.Errorf("jsonl line %d: %w", lineNo, err)
+			}
+			if v, ok := rec.(ir.Project); ok {
+				if p != nil {
+					return nil, nil, fmt.Errorf("jsonl line %d: more than one project record", lineNo)
+				}
+				p = &v
+			} else {
+				f.Add(rec)
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+	}
+	if p == nil {
+		return nil, nil, fmt.Errorf("jsonl: no project record")
+	}
+	return p, f, nil
+}
+
+func decodeLine(b []byte) (any, error) {
+	var l Line
+	if err := json.Unmarshal(b, &l); err != nil {
+		return nil, err
+	}
+	switch l.Type {
+	case TypeProject:
+		return decode[ir.Project](l.Data)
+	case TypeContainer:
+		return decode[ir.Container](l.Data)
+	case TypeFile:
+		return decode[ir.File](l.Data)
+	case TypeSymbol:
+		return decode[ir.Symbol](l.Data)
+	case TypeSignature:
+		return decode[ir.Signature](l.Data)
+	case TypeTyperef:
+		return decode[ir.Typeref](l.Data)
+	case TypeMember:
+		return decode[ir.Member](l.Data)
+	case TypeRelation:
+		return decode[ir.Relation](l.Data)
+	case TypeImport:
+		return decode[ir.Import](l.Data)
+	case TypeDiagnostic:
+		return decode[ir.Diagnostic](l.Data)
+	default:
+		return nil, fmt.Errorf("unknown record type %q", l.Type)
+	}
+}
+
+func decode[T any](data json.RawMessage) (any, error) {
+	var v T
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
@@ -0,0 +1,123 @@
+package emit
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/ChaseHampton/cargoworker/internal/db"
+	"github.com/ChaseHampton/cargoworker/internal/ir"
+	"github.com/ChaseHampton/cargoworker/internal/store"
+)
+
+func testRun() (*ir.Project, *ir.Fragment) {
+	p := &ir.Project{Id: uuid.New(), Name: "mod", RootUri: "/src/mod", ToolVersion: "v1", IrSchema: "v1",
+		CreatedUtc: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)}
+	mod, sub := uuid.New(), uuid.New()
+	fa, fb := uuid.New(), uuid.New()
+	iface, typ, method := uuid.New(), uuid.New(), uuid.New()
+	return p, &ir.Fragment{
+		Containers: []ir.Container{
+			{Id: sub, ProjectId: p.Id, ParentId: mod, Language: "go", Name: "sub", FullName: "example.com/mod/sub", Kind: "package"},
+			{Id: mod, ProjectId: p.Id, Language: "go", Name: "mod", FullName: "example.com/mod", Kind: "module", DocRaw: "// Package mod.", DocFmt: "Package mod."},
+		},
+		Files: []ir.File{
+			{Id: fb, ProjectId: p.Id, ContainerId: sub, Path: "sub/file.go", Language: "go", SizeBytes: 20},
+			{Id: fa, ProjectId: p.Id, ContainerId: mod, Path: "api.go", Language: "go", Checksum: "abc", SizeBytes: 10},
+		},
+		Symbols: []ir.Symbol{
+			{Id: method, ContainerId: sub, Name: "Read", FullName: "example.com/mod/sub.File.Read", Kind: "method", Visibility: "public", OriginFileId: fb, StartLine: 7, EndLine: 9},
+			{Id: typ, ContainerId: sub, Name: "File", FullName: "example.com/mod/sub.File", Kind: "struct", Visibility: "public", OriginFileId: fb, StartLine: 3},
+			{Id: iface, ContainerId: mod, Name: "Reader", FullName: "example.com/mod.Reader", Kind: "interface", Visibility: "public", OriginFileId: fa, DocRaw: "// Reader reads.", DocFmt: "Reader reads.", Flags: 2},
+		},
+		Signatures: []ir.Signature{{SymbolId: method, Text: "func (f *File) Read(p []byte) (int, error)", Json: `{"params":[]}`}},
+		Typerefs:   []ir.Typeref{{Id: uuid.New(), OwnerSymbolId: method, Slot: "result:1", Json: `{"type":"named","symbol":"builtin.error"}`, Order: 1}},
+		Members:    []ir.Member{{Id: uuid.New(), OwnerSymbolId: typ, ChildSymbolId: method}},
+		Relations:  []ir.Relation{{SourceSymbolId: typ, Relation: "implements", DstSymbolId: iface, DetailsJson: `{"via":"Read"}`}},
+		Imports:    []ir.Import{{ContainerId: sub, Target: "io", DetailsJson: `{"stdlib":true}`}},
+		Diagnostics: []ir.Diagnostic{
+			{Id: uuid.New(), Scope: "file", Severity: "warn", Code: "parser", Message: "odd", FileId: fb, Line: 2, Column: 1},
+			{Id: uuid.New(), Scope: "container", Severity: "info", Message: "skipped cgo file"},
+		},
+	}
+}
+
+func writeDB(t *testing.T, ctx context.Context, p *ir.Project, f *ir.Fragment) *sql.DB {
+	t.Helper()
+	rdb, err := db.Open(ctx, filepath.Join(t.TempDir(), db.FileName))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { rdb.Close() })
+	st := store.NewSQLite(rdb)
+	if err := st.Migrate(ctx); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if err := st.WriteProject(ctx, p); err != nil {
+		t.Fatalf("project: %v", err)
+	}
+	if err := st.WriteFragment(ctx, f); err != nil {
+		t.Fatalf("fragment: %v", err)
+	}
+	if dropped, err := st.Flush(ctx); err != nil || dropped != 0 {
+		t.Fatalf("flush: dropped %d, %v", dropped, err)
+	}
+	return rdb
+}
+
+func TestJSONL_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	p, f := testRun()
+
+	var first bytes.Buffer
+	n, err := WriteJSONL(ctx, writeDB(t, ctx, p, f), &first)
+	if err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if n != 15 {
+		t.Fatalf("wrote %d lines, want 15:\n%s", n, first.String())
+	}
+	lines := strings.Split(strings.TrimSpace(first.String()), "\n")
+	if !strings.HasPrefix(lines[0], `{"type":"project"`) || !strings.HasPrefix(lines[1], `{"type":"container","data":{"id":"`+f.Containers[1].Id.String()) {
+		t.Fatalf("unexpected order:\n%s", first.String())
+	}
+
+	p2, f2, err := ReadJSONL(&first)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if p2.Id != p.Id || !p2.CreatedUtc.Equal(p.CreatedUtc) || len(f2.Symbols) != 3 || len(f2.Diagnostics) != 2 {
+		t.Fatalf("decoded %+v / %+v", p2, f2)
+	}
+
+	var want bytes.Buffer
+	if _, err := WriteJSONL(ctx, writeDB(t, ctx, p, f), &want); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	var second bytes.Buffer
+	if _, err := WriteJSONL(ctx, writeDB(t, ctx, p2, f2), &second); err != nil {
+		t.Fatalf("write imported: %v", err)
+	}
+	if want.String() != second.String() {
+		t.Fatalf("round trip differs:\n--- original\n%s\n--- imported\n%s", want.String(), second.String())
+	}
+}
+
+func TestReadJSONL_Errors(t *testing.T) {
+	for name, in := range map[string]string{
+		"no project":   `{"type":"file","data":{}}`,
+		"two projects": "{\"type\":\"project\",\"data\":{}}\n{\"type\":\"project\",\"data\":{}}",
+		"unknown type": "{\"type\":\"project\",\"data\":{}}\n{\"type\":\"widget\",\"data\":{}}",
+		"bad json":     `{"type":`,
+	} {
+		if _, _, err := ReadJSONL(strings.NewReader(in)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package emit
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// MarkdownResult summarizes a WriteMarkdown run.
+type MarkdownResult struct {
+	Files   int `json:"files"`
+	Symbols int `json:"symbols"`
+}
+
+// WriteMarkdown writes one .md file per container into dir, plus index.md
+// listing them. Each page has a table of contents and a section per symbol
+// kind; members (methods, fields) are nested under their owner. Output holds
+// no timestamps or row ids and is sorted throughout, so re-running on an
+// unchanged code base reproduces it byte for byte.
+func WriteMarkdown(ctx context.Context, rdb *sql.DB, dir string) (*MarkdownResult, error) {
+	m, err := buildModel(ctx, rdb)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	res := &MarkdownResult{Symbols: len(m.symbols)}
+	if err := os.WriteFile(filepath.Join(dir, "index.md"), []byte(m.markdownIndex()), 0o644); err != nil {
+		return nil, err
+	}
+	for _, dc := range m.Containers {
+		if err := os.WriteFile(filepath.Join(dir, dc.Page+".md"), []byte(m.markdownPage(dc)), 0o644); err != nil {
+			return nil, err
+		}
+		res.Files++
+	}
+	return res, nil
+}
+
+func (m *docModel) markdownIndex() string {
+	var b strings.Builder
+	title := "Documentation"
+	if m.Project != nil && m.Project.Name != "" {
+		title = m.Project.Name
+	}
+	fmt.Fprintf(&b, "# %s\n\n", title)
+	var tree func(cs []*docContainer, depth int)
+	tree = func(cs []*docContainer, depth int) {
+		for _, dc := range cs {
+			fmt.Fprintf(&b, "%s- %s", strings.Repeat("  ", depth), mdLink(mdCode(dc.FullName), dc.Page+".md"))
+			if dc.Kind != "" {
+				fmt.Fprintf(&b, " (%s)", dc.Kind)
+			}
+			b.WriteString("\n")
+			if depth < 32 {
+				tree(dc.Children, depth+1)
+			}
+		}
+	}
+	tree(m.Roots, 0)
+	return b.String()
+}
+
+func (m *docModel) markdownPage(dc *docContainer) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# %s\n\n", mdCode(dc.FullName))
+	var meta []string
+	if dc.Kind != "" {
+		meta = append(meta, dc.Kind)
+	}
+	if dc.Language != "" {
+		meta = append(meta, dc.Language)
+	}
+	if dc.VersionTag != "" {
+		meta = append(meta, dc.VersionTag)
+	}
+	if dc.Parent != nil {
+		meta = append(meta, "in "+mdLink(mdCode(dc.Parent.FullName), dc.Parent.Page+".md"))
+	}
+	if len(meta) > 0 {
+		fmt.Fprintf(&b, "_%s_\n\n", strings.Join(meta, " · "))
+	}
+	m.markdownDoc(&b, dc, dc.DocFmt, dc.DocRaw)
+
+	if len(dc.Children) > 0 {
+		b.WriteString("## Contents\n\n")
+		for _, c := range dc.Children {
+			fmt.Fprintf(&b, "- %s\n", mdLink(mdCode(c.FullName), c.Page+".md"))
+		}
+		b.WriteString("\n")
+	}
+
+	if len(dc.Imports) > 0 {
+		b.WriteString("## Imports\n\n")
+		for _, im := range dc.Imports {
+			if im.Alias != "" {
+				fmt.Fprintf(&b, "- %s as %s\n", mdCode(im.Target), mdCode(im.Alias))
+			} else {
+				fmt.Fprintf(&b, "- %s\n", mdCode(im.Target))
+			}
+		}
+		b.WriteString("\n")
+	}
+
+	if len(dc.Sections) == 0 {
+		return b.String()
+	}
+
+	b.WriteString("## Contents of this page\n\n")
+	for _, sec := range dc.Sections {
+		fmt.Fprintf(&b, "- %s\n", mdLink(sec.Title, "#section-"+sec.Kind))
+		for _, ds := range sec.Symbols {
+			mdTOC(&b, ds, 1)
+		}
+	}
+	b.WriteString("\n")
+
+	for _, sec := range dc.Sections {
+		fmt.Fprintf(&b, "<a id=\"section-%s\"></a>\n\n## %s\n\n", sec.Kind, sec.Title)
+		for _, ds := range sec.Symbols {
+			m.markdownSymbol(&b, dc, ds, 3)
+		}
+	}
+	return b.String()
+}
+
+func mdTOC(b *strings.Builder, ds *docSymbol, depth int) {
+	fmt.Fprintf(b, "%s- %s\n", strings.Repeat("  ", depth), mdLink(mdCode(ds.Name), "#"+ds.Anchor))
+	if depth < 6 {
+		for _, mem := range ds.Members {
+			mdTOC(b, mem, depth+1)
+		}
+	}
+}
+
+func (m *docModel) markdownSymbol(b *strings.Builder, dc *docContainer, ds *docSymbol, level int) {
+	fmt.Fprintf(b, "<a id=\"%s\"></a>\n\n%s %s", ds.Anchor, strings.Repeat("#", min(level, 6)), mdCode(ds.Name))
+	if ds.Owner != nil || level > 3 {
+		fmt.Fprintf(b, " _%s_", ds.Kind)
+	}
+	b.WriteString("\n\n")
+
+	if ds.Signature != "" {
+		fmt.Fprintf(b, "%s%s\n%s\n%s\n\n", fence(ds.Signature), dc.Language, ds.Signature, fence(ds.Signature))
+	}
+	if ds.File != "" {
+		loc := ds.File
+		if ds.StartLine > 0 {
+			loc = fmt.Sprintf("%s:%d", ds.File, ds.StartLine)
+		}
+		fmt.Fprintf(b, "_Defined in %s_\n\n", mdCode(loc))
+	}
+	m.markdownDoc(b, ds.Container, ds.DocFmt, ds.DocRaw)
+
+	if len(ds.Uses) > 0 {
+		links := make([]string, len(ds.Uses))
+		for i, u := range ds.Uses {
+			links[i] = m.mdSymbolLink(dc, u)
+		}
+		fmt.Fprintf(b, "**Uses:** %s\n\n", strings.Join(links, ", "))
+	}
+	if len(ds.Out)+len(ds.In) > 0 {
+		for _, r := range ds.Out {
+			fmt.Fprintf(b, "- %s → %s\n", r.Kind, m.mdSymbolLink(dc, r.Other))
+		}
+		for _, r := range ds.In {
+			fmt.Fprintf(b, "- %s ← %s\n", r.Kind, m.mdSymbolLink(dc, r.Other))
+		}
+		b.WriteString("\n")
+	}
+
+	for _, mem := range ds.Members {
+		m.markdownSymbol(b, dc, mem, level+1)
+	}
+}
+
+// markdownDoc writes doc blocks; doc links in brackets become relative links.
+func (m *docModel) markdownDoc(b *strings.Builder, dc *docContainer, text, fallback string) {
+	if strings.TrimSpace(text) == "" {
+		text = fallback
+	}
+	for _, blk := range parseDoc(text) {
+		switch blk.Kind {
+		case "code":
+			fmt.Fprintf(b, "%s\n%s\n%s\n\n", fence(blk.Text), blk.Text, fence(blk.Text))
+		case "heading":
+			fmt.Fprintf(b, "**%s**\n\n", blk.Text)
+		case "list":
+			for _, it := range blk.Items {
+				fmt.Fprintf(b, "- %s\n", m.mdInline(dc, it))
+			}
+			b.WriteString("\n")
+		default:
+			fmt.Fprintf(b, "%s\n\n", m.mdInline(dc, blk.Text))
+		}
+	}
+}
+
+func (m *docModel) mdInline(dc *docContainer, text string) string {
+	return docLinkRe.ReplaceAllStringFunc(text, func(match string) string {
+		name := match[1 : len(match)-1]
+		target := m.byName[dc.FullName+"."+name]
+		if target == nil {
+			target = m.byName[name]
+		}
+		if target == nil {
+			return match
+		}
+		return mdLink(mdCode(name), mdHref(dc, target))
+	})
+}
+
+func (m *docModel) mdSymbolLink(from *docContainer, ds *docSymbol) string {
+	return mdLink(mdCode(qualName(ds)), mdHref(from, ds))
+}
+
+// mdHref links to ds relative to the page of from: an in-page anchor when ds
+// is documented on the same page.
+func mdHref(from *docContainer, ds *docSymbol) string {
+	if home := ds.Home(); home != from {
+		return home.Page + ".md#" + ds.Anchor
+	}
+	return "#" + ds.Anchor
+}
+
+func mdLink(text, target string) string {
+	return "[" + text + "](" + target + ")"
+}
+
+// mdCode wraps s in an inline code span long enough not to clash with
+// backticks inside it.
+func mdCode(s string) string {
+	ticks := "`"
+	for strings.Contains(s, ticks) {
+		ticks += "`"
+	}
+	if strings.HasPrefix(s, "`") || strings.HasSuffix(s, "`") {
+		return ticks + " " + s + " " + ticks
+	}
+	return ticks + s + ticks
+}
+
+// fence returns a code fence longer than any backtick run in s.
+func fence(s string) string {
+	f := "```"
+	for strings.Contains(s, f) {
+		f += "`"
+	}
+	return f
+}
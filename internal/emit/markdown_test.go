@@ -0,0 +1,195 @@
+package emit
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/ChaseHampton/cargoworker/internal/ir"
+)
+
+func TestWriteMarkdown(t *testing.T) {
+	ctx := context.Background()
+	p, f := testRun()
+	rdb := writeDB(t, ctx, p, f)
+
+	dir := t.TempDir()
+	res, err := WriteMarkdown(ctx, rdb, dir)
+	if err != nil {
+		t.Fatalf("markdown: %v", err)
+	}
+	if res.Files != 2 || res.Symbols != 3 {
+		t.Fatalf("result = %+v", res)
+	}
+	read := func(name string) string {
+		b, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("read %s: %v", name, err)
+		}
+		return string(b)
+	}
+
+	index := read("index.md")
+	if index != "# mod\n\n- [`example.com/mod`](example.com_mod.md) (module)\n  - [`example.com/mod/sub`](example.com_mod_sub.md) (package)\n" {
+		t.Fatalf("index.md =\n%s", index)
+	}
+
+	sub := read("example.com_mod_sub.md")
+	for _, want := range []string{
+		"_package · go · in [`example.com/mod`](example.com_mod.md)_",
+		"- [Structs](#section-struct)\n  - [`File`](#File)\n    - [`Read`](#File.Read)\n",
+		"<a id=\"File.Read\"></a>\n\n#### `Read` _method_\n\n```go\nfunc (f *File) Read(p []byte) (int, error)\n```\n",
+		"- implements → [`mod.Reader`](example.com_mod.md#Reader)\n",
+		"- `io`\n",
+	} {
+		if !strings.Contains(sub, want) {
+			t.Errorf("sub page lacks %q:\n%s", want, sub)
+		}
+	}
+	if strings.Contains(sub, "## Methods") {
+		t.Errorf("method listed outside its receiver:\n%s", sub)
+	}
+
+	// Stable output: a second run, and a rebuilt database, produce the same bytes.
+	dir2 := t.TempDir()
+	if _, err := WriteMarkdown(ctx, writeDB(t, ctx, p, f), dir2); err != nil {
+		t.Fatalf("markdown: %v", err)
+	}
+	for _, name := range []string{"index.md", "example.com_mod.md", "example.com_mod_sub.md"} {
+		b, _ := os.ReadFile(filepath.Join(dir2, name))
+		if string(b) != read(name) {
+			t.Errorf("%s differs between runs", name)
+		}
+	}
+}
+
+// A container named index, a Python module index.py for one, does not
+// overwrite the index page.
+func TestIndexPageReserved(t *testing.T) {
+	ctx := context.Background()
+	p, f := testRun()
+	f.Containers[0].FullName = "index"
+	rdb := writeDB(t, ctx, p, f)
+
+	for _, tc := range []struct {
+		ext   string
+		write func(dir string) error
+	}{
+		{".md", func(dir string) error { _, err := WriteMarkdown(ctx, rdb, dir); return err }},
+		{".html", func(dir string) error { _, err := WriteHTML(ctx, rdb, dir); return err }},
+	} {
+		dir := t.TempDir()
+		if err := tc.write(dir); err != nil {
+			t.Fatalf("write %s: %v", tc.ext, err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "index.go"+tc.ext)); err != nil {
+			t.Fatalf("no container page index.go%s: %v", tc.ext, err)
+		}
+		index, err := os.ReadFile(filepath.Join(dir, "index"+tc.ext))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(index), "index.go"+tc.ext) {
+			t.Errorf("index%s does not link the container page:\n%s", tc.ext, index)
+		}
+	}
+}
+
+// sameNameRun is a run whose C and Thrift containers share the full name
+// acme, with fresh ids on every call.
+func sameNameRun() (*ir.Project, *ir.Fragment) {
+	p := &ir.Project{Id: uuid.New(), Name: "acme", RootUri: "/src/acme"}
+	f := &ir.Fragment{}
+	for _, lang := range []string{"thrift", "c"} {
+		cid, fid := uuid.New(), uuid.New()
+		f.Containers = append(f.Containers, ir.Container{Id: cid, ProjectId: p.Id, Language: lang, Name: "acme", FullName: "acme", Kind: "namespace"})
+		f.Files = append(f.Files, ir.File{Id: fid, ProjectId: p.Id, ContainerId: cid, Path: "acme." + lang, Language: lang})
+		f.Symbols = append(f.Symbols, ir.Symbol{Id: uuid.New(), ContainerId: cid, Name: "From_" + lang, FullName: "acme.From_" + lang, Kind: "struct", OriginFileId: fid})
+	}
+	return p, f
+}
+
+// Containers sharing a name get pages named after their language, whatever
+// ids the run gave them.
+func TestPageNamesStable(t *testing.T) {
+	ctx := context.Background()
+	var first map[string]string
+	for run := 0; run < 4; run++ {
+		p, f := sameNameRun()
+		if run%2 == 1 {
+			f.Containers[0], f.Containers[1] = f.Containers[1], f.Containers[0]
+		}
+		m, err := newModel(p, f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, dc := range m.Containers {
+			if dc.Page != "acme."+dc.Language {
+				t.Errorf("%s container page = %q, want acme.%s", dc.Language, dc.Page, dc.Language)
+			}
+		}
+
+		rdb := writeDB(t, ctx, p, f)
+		dir := t.TempDir()
+		if _, err := WriteMarkdown(ctx, rdb, filepath.Join(dir, "md")); err != nil {
+			t.Fatalf("markdown: %v", err)
+		}
+		if _, err := WriteHTML(ctx, rdb, filepath.Join(dir, "html")); err != nil {
+			t.Fatalf("html: %v", err)
+		}
+		out := map[string]string{}
+		for _, name := range []string{"md/index.md", "md/acme.c.md", "md/acme.thrift.md", "html/index.html", "html/acme.c.html", "html/acme.thrift.html"} {
+			b, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+			if err != nil {
+				t.Fatalf("run %d: %v", run, err)
+			}
+			out[name] = string(b)
+		}
+		if !strings.Contains(out["md/acme.c.md"], "From_c") || strings.Contains(out["md/acme.c.md"], "From_thrift") {
+			t.Errorf("acme.c.md does not document the C container:\n%s", out["md/acme.c.md"])
+		}
+		if first == nil {
+			first = out
+			continue
+		}
+		for name, text := range out {
+			if text != first[name] {
+				t.Errorf("run %d: %s differs from the first run", run, name)
+			}
+		}
+	}
+}
+
+// Names holding Markdown syntax are shown as code, and underscores stay in
+// their anchors.
+func TestMarkdownNames(t *testing.T) {
+	p, f := testRun()
+	sub, file := f.Containers[0].Id, f.Files[0].Id
+	for _, name := range []string{"__init__", "init", "operator[]"} {
+		f.Symbols = append(f.Symbols, ir.Symbol{Id: uuid.New(), ContainerId: sub, Name: name, FullName: "example.com/mod/sub." + name, Kind: "function", OriginFileId: file})
+	}
+	f.Containers[0].FullName = "example.com/mod/__sub__"
+	m, err := newModel(p, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dc *docContainer
+	for _, c := range m.Containers {
+		if c.Id == sub {
+			dc = c
+		}
+	}
+	page := m.markdownPage(dc)
+	for _, want := range []string{"- [`__init__`](#__init__)\n", "- [`init`](#init)\n", "- [`operator[]`](#operator_)\n"} {
+		if !strings.Contains(page, want) {
+			t.Errorf("page lacks %q:\n%s", want, page)
+		}
+	}
+	if index := m.markdownIndex(); !strings.Contains(index, "[`example.com/mod/__sub__`](example.com_mod___sub.md)") {
+		t.Errorf("index.md =\n%s", index)
+	}
+}
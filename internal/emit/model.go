@@ -0,0 +1,423 @@
+package emit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/ChaseHampton/cargoworker/internal/ir"
+)
+
+// docModel is the run arranged for documentation output: containers sorted by
+// full name, each with its top-level symbols grouped by kind and members
+// nested under their owners.
+type docModel struct {
+	Project    *ir.Project
+	Containers []*docContainer
+	Roots      []*docContainer // containers without a parent
+
+	symbols map[uuid.UUID]*docSymbol
+	byName  map[string]*docSymbol // full name -> symbol
+}
+
+type docContainer struct {
+	ir.Container
+	Page     string // file name without extension, unique within the output
+	Parent   *docContainer
+	Children []*docContainer
+	Sections []*docSection
+	Imports  []ir.Import
+
+	anchors map[string]int
+}
+
+// docSection holds the top-level symbols of one kind.
+type docSection struct {
+	Kind    string
+	Title   string
+	Symbols []*docSymbol
+}
+
+type docSymbol struct {
+	ir.Symbol
+	Container *docContainer
+	Owner     *docSymbol
+	Anchor    string
+	File      string
+	Signature string
+	Members   []*docSymbol
+	Uses      []*docSymbol // resolved type references, in slot order
+	Out, In   []docRelation
+}
+
+type docRelation struct {
+	Kind  string
+	Other *docSymbol
+}
+
+// kindOrder lists section kinds in display order; other kinds follow
+// alphabetically.
+var kindOrder = []string{
+	"module", "namespace", "package",
+	"interface", "trait", "protocol", "class", "struct", "record", "union", "enum", "type", "alias", "typedef",
+	"const", "constant", "var", "variable", "field", "property",
+	"constructor", "function", "method", "macro",
+}
+
+// kindTitles are section headings for kinds whose plural is not kind+"s".
+var kindTitles = map[string]string{
+	"alias":     "Aliases",
+	"class":     "Classes",
+	"const":     "Constants",
+	"property":  "Properties",
+	"typedef":   "Typedefs",
+	"type":      "Types",
+	"var":       "Variables",
+	"namespace": "Namespaces",
+}
+
+func kindRank(kind string) int {
+	for i, k := range kindOrder {
+		if k == kind {
+			return i
+		}
+	}
+	return len(kindOrder)
+}
+
+func kindTitle(kind string) string {
+	if t, ok := kindTitles[kind]; ok {
+		return t
+	}
+	if kind == "" {
+		return "Other"
+	}
+	return strings.ToUpper(kind[:1]) + kind[1:] + "s"
+}
+
+// buildModel loads the run and links it up. Member order follows
+// member.ord, then name; everything else is sorted by name so output is
+// stable across runs.
+func buildModel(ctx context.Context, rdb *sql.DB) (*docModel, error) {
+	p, f, err := Load(ctx, rdb)
+	if err != nil {
+		return nil, err
+	}
+	return newModel(p, f)
+}
+
+func newModel(p *ir.Project, f *ir.Fragment) (*docModel, error) {
+	m := &docModel{
+		Project: p,
+		symbols: make(map[uuid.UUID]*docSymbol, len(f.Symbols)),
+		byName:  make(map[string]*docSymbol, len(f.Symbols)),
+	}
+
+	containers := make(map[uuid.UUID]*docContainer, len(f.Containers))
+	for _, c := range f.Containers {
+		dc := &docContainer{Container: c, anchors: map[string]int{}}
+		containers[c.Id] = dc
+		m.Containers = append(m.Containers, dc)
+	}
+	sort.SliceStable(m.Containers, func(i, j int) bool { return containerLess(m.Containers[i], m.Containers[j]) })
+	assignPages(m.Containers)
+	for _, dc := range m.Containers {
+		if parent, ok := containers[dc.ParentId]; ok {
+			dc.Parent = parent
+			parent.Children = append(parent.Children, dc)
+		} else {
+			m.Roots = append(m.Roots, dc)
+		}
+	}
+
+	files := make(map[uuid.UUID]string, len(f.Files))
+	for _, fl := range f.Files {
+		files[fl.Id] = fl.Path
+	}
+	for _, s := range f.Symbols {
+		dc, ok := containers[s.ContainerId]
+		if !ok {
+			return nil, fmt.Errorf("symbol %s: unknown container %s", s.FullName, s.ContainerId)
+		}
+		ds := &docSymbol{Symbol: s, Container: dc, File: files[s.OriginFileId]}
+		m.symbols[s.Id] = ds
+		if s.FullName != "" {
+			m.byName[s.FullName] = ds
+		}
+		if s.FullName == "" || dc.FullName+"."+s.Name != s.FullName {
+			if _, ok := m.byName[dc.FullName+"."+s.Name]; !ok {
+				m.byName[dc.FullName+"."+s.Name] = ds
+			}
+		}
+	}
+	for _, sig := range f.Signatures {
+		if ds, ok := m.symbols[sig.SymbolId]; ok {
+			ds.Signature = sig.Text
+		}
+	}
+
+	// Members nest under their owner; members are left out of the sections.
+	order := map[*docSymbol]int{}
+	for _, mem := range f.Members {
+		owner, child := m.symbols[mem.OwnerSymbolId], m.symbols[mem.ChildSymbolId]
+		if owner == nil || child == nil || child.Owner != nil {
+			continue
+		}
+		child.Owner = owner
+		owner.Members = append(owner.Members, child)
+		order[child] = mem.Order
+	}
+	for _, ds := range m.symbols {
+		sort.SliceStable(ds.Members, func(i, j int) bool {
+			a, b := ds.Members[i], ds.Members[j]
+			if order[a] != order[b] {
+				return order[a] < order[b]
+			}
+			return symbolLess(a, b)
+		})
+	}
+
+	for _, rel := range f.Relations {
+		src, dst := m.symbols[rel.SourceSymbolId], m.symbols[rel.DstSymbolId]
+		if src == nil || dst == nil {
+			continue
+		}
+		src.Out = append(src.Out, docRelation{Kind: rel.Relation, Other: dst})
+		dst.In = append(dst.In, docRelation{Kind: rel.Relation, Other: src})
+	}
+
+	typerefs := append([]ir.Typeref(nil), f.Typerefs...)
+	sort.SliceStable(typerefs, func(i, j int) bool { return typerefs[i].Order < typerefs[j].Order })
+	for _, tr := range typerefs {
+		owner := m.symbols[tr.OwnerSymbolId]
+		if owner == nil {
+			continue
+		}
+		if target := m.resolveTyperef(owner.Container, tr.Json); target != nil && target != owner && !containsSymbol(owner.Uses, target) {
+			owner.Uses = append(owner.Uses, target)
+		}
+	}
+
+	for _, im := range f.Imports {
+		if dc, ok := containers[im.ContainerId]; ok {
+			dc.Imports = append(dc.Imports, im)
+		}
+	}
+
+	// Sections and anchors, in sorted order so anchor suffixes are stable.
+	all := make([]*docSymbol, 0, len(m.symbols))
+	for _, ds := range m.symbols {
+		all = append(all, ds)
+	}
+	sort.Slice(all, func(i, j int) bool { return symbolLess(all[i], all[j]) })
+	for _, ds := range all {
+		if ds.Owner != nil {
+			continue
+		}
+		ds.Container.section(ds.Kind).Symbols = append(ds.Container.section(ds.Kind).Symbols, ds)
+	}
+	for _, dc := range m.Containers {
+		sort.SliceStable(dc.Sections, func(i, j int) bool {
+			a, b := dc.Sections[i], dc.Sections[j]
+			if ra, rb := kindRank(a.Kind), kindRank(b.Kind); ra != rb {
+				return ra < rb
+			}
+			return a.Kind < b.Kind
+		})
+		for _, sec := range dc.Sections {
+			for _, ds := range sec.Symbols {
+				dc.assignAnchors(ds)
+			}
+		}
+	}
+	for _, ds := range all {
+		if ds.Anchor == "" {
+			// Members of an ownership cycle have no top-level symbol.
+			ds.Container.assignAnchors(ds)
+		}
+		sortRelations(ds.Out)
+		sortRelations(ds.In)
+	}
+	return m, nil
+}
+
+// Home is the container whose page documents ds: members are shown under
+// their owner even when declared elsewhere.
+func (ds *docSymbol) Home() *docContainer {
+	seen := map[*docSymbol]bool{}
+	for ds.Owner != nil && !seen[ds] {
+		seen[ds] = true
+		ds = ds.Owner
+	}
+	return ds.Container
+}
+
+func (dc *docContainer) section(kind string) *docSection {
+	for _, s := range dc.Sections {
+		if s.Kind == kind {
+			return s
+		}
+	}
+	s := &docSection{Kind: kind, Title: kindTitle(kind)}
+	dc.Sections = append(dc.Sections, s)
+	return s
+}
+
+// assignAnchors names ds and its members: "Name" for top-level symbols and
+// "Owner.Name" for members, with a numeric suffix on collisions (overloads).
+func (dc *docContainer) assignAnchors(ds *docSymbol) {
+	base := ds.Name
+	if ds.Owner != nil {
+		base = ds.Owner.Anchor + "." + ds.Name
+	}
+	base = anchorSafe(base)
+	dc.anchors[base]++
+	ds.Anchor = base
+	if n := dc.anchors[base]; n > 1 {
+		ds.Anchor = fmt.Sprintf("%s-%d", base, n)
+	}
+	for _, mem := range ds.Members {
+		if mem.Anchor == "" {
+			dc.assignAnchors(mem)
+		}
+	}
+}
+
+// resolveTyperef finds the symbol a TypeRef JSON value names, trying its
+// qualified name first and the owner's container second.
+func (m *docModel) resolveTyperef(in *docContainer, js string) *docSymbol {
+	var ref struct {
+		Name   string `json:"name"`
+		Symbol string `json:"symbol"`
+	}
+	if err := json.Unmarshal([]byte(js), &ref); err != nil {
+		return nil
+	}
+	if ref.Symbol != "" {
+		if ds := m.byName[ref.Symbol]; ds != nil {
+			return ds
+		}
+	}
+	if ref.Name != "" {
+		return m.byName[in.FullName+"."+ref.Name]
+	}
+	return nil
+}
+
+// containerLess orders containers by full name, then language and kind, so
+// containers sharing a name keep their order across runs.
+func containerLess(a, b *docContainer) bool {
+	if a.FullName != b.FullName {
+		return a.FullName < b.FullName
+	}
+	if a.Language != b.Language {
+		return a.Language < b.Language
+	}
+	return a.Kind < b.Kind
+}
+
+func symbolLess(a, b *docSymbol) bool {
+	if a.Container.FullName != b.Container.FullName {
+		return a.Container.FullName < b.Container.FullName
+	}
+	if a.Name != b.Name {
+		return a.Name < b.Name
+	}
+	if a.Kind != b.Kind {
+		return a.Kind < b.Kind
+	}
+	if a.StartLine != b.StartLine {
+		return a.StartLine < b.StartLine
+	}
+	return a.Id.String() < b.Id.String()
+}
+
+func sortRelations(rs []docRelation) {
+	sort.SliceStable(rs, func(i, j int) bool {
+		if rs[i].Kind != rs[j].Kind {
+			return rs[i].Kind < rs[j].Kind
+		}
+		return symbolLess(rs[i].Other, rs[j].Other)
+	})
+}
+
+func containsSymbol(list []*docSymbol, s *docSymbol) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+var unsafeChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// slug turns a container full name into a file name: "example.com/mod/sub"
+// becomes "example.com_mod_sub".
+func slug(s string) string {
+	s = strings.Trim(unsafeChars.ReplaceAllString(s, "_"), "_.")
+	if s == "" {
+		return "_"
+	}
+	return s
+}
+
+// anchorSafe turns a symbol name into an anchor. Underscores are kept, so
+// __init__ and init stay apart.
+func anchorSafe(s string) string {
+	if s = unsafeChars.ReplaceAllString(s, "_"); s == "" {
+		return "_"
+	}
+	return s
+}
+
+// reservedPages are the page names the emitters write besides the
+// containers' own, lower-cased.
+var reservedPages = []string{"index"}
+
+// assignPages names the page of each container, in containerLess order. A
+// container has its slug unless another one, or a reserved page, shares it
+// (names differing only in punctuation or case); the sharers are then told
+// apart by language, then kind, as in "acme.c" and "acme.thrift", and by a
+// numeric suffix when both agree.
+func assignPages(cs []*docContainer) {
+	taken := map[string]bool{}
+	for _, name := range reservedPages {
+		taken[name] = true
+	}
+	shared := map[string]int{}
+	for _, dc := range cs {
+		shared[strings.ToLower(slug(dc.FullName))]++
+	}
+	var rest []*docContainer
+	for _, dc := range cs {
+		name := slug(dc.FullName)
+		if key := strings.ToLower(name); shared[key] == 1 && !taken[key] {
+			dc.Page, taken[key] = name, true
+		} else {
+			rest = append(rest, dc)
+		}
+	}
+	for _, dc := range rest {
+		name := slug(dc.FullName)
+		for _, part := range []string{dc.Language, dc.Kind} {
+			if part != "" {
+				name += "." + slug(part)
+			}
+			if !taken[strings.ToLower(name)] {
+				break
+			}
+		}
+		base := name
+		for n := 2; taken[strings.ToLower(name)]; n++ {
+			name = fmt.Sprintf("%s-%d", base, n)
+		}
+		dc.Page, taken[strings.ToLower(name)] = name, true
+	}
+}
@@ -0,0 +1,368 @@
+// Package emit exports a run database to other formats.
+package emit
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/ChaseHampton/cargoworker/internal/ir"
+)
+
+// Record types, in the order Walk visits them.
+const (
+	TypeProject    = "project"
+	TypeContainer  = "container"
+	TypeFile       = "file"
+	TypeSymbol     = "symbol"
+	TypeSignature  = "signature"
+	TypeTyperef    = "typeref"
+	TypeMember     = "member"
+	TypeRelation   = "relation"
+	TypeImport     = "import"
+	TypeDiagnostic = "diagnostic"
+)
+
+// WalkFunc receives one IR record. rec is a value of the ir type named by typ
+// (ir.Project for TypeProject, ir.Container for TypeContainer, ...).
+type WalkFunc func(typ string, rec any) error
+
+// Walk reads every IR record of a run database in a deterministic order:
+// record types in the order of the Type constants, and within a type by
+// natural keys (package path, file path, full name, declaration order) so two
+// databases with the same content walk identically regardless of row ids.
+func Walk(ctx context.Context, rdb *sql.DB, fn WalkFunc) error {
+	for _, step := range []struct {
+		typ  string
+		walk func(context.Context, *sql.DB, WalkFunc) error
+	}{
+		{TypeProject, walkProjects},
+		{TypeContainer, walkContainers},
+		{TypeFile, walkFiles},
+		{TypeSymbol, walkSymbols},
+		{TypeSignature, walkSignatures},
+		{TypeTyperef, walkTyperefs},
+		{TypeMember, walkMembers},
+		{TypeRelation, walkRelations},
+		{TypeImport, walkImports},
+		{TypeDiagnostic, walkDiagnostics},
+	} {
+		if err := step.walk(ctx, rdb, fn); err != nil {
+			return fmt.Errorf("read %s: %w", step.typ, err)
+		}
+	}
+	return nil
+}
+
+// Load reads the whole run into memory: the project (nil if the database has
+// none) and a single fragment holding every other record.
+func Load(ctx context.Context, rdb *sql.DB) (*ir.Project, *ir.Fragment, error) {
+	var (
+		p *ir.Project
+		f = &ir.Fragment{}
+	)
+	err := Walk(ctx, rdb, func(typ string, rec any) error {
+		switch v := rec.(type) {
+		case ir.Project:
+			if p == nil {
+				p = &v
+			}
+		default:
+			f.Add(v)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return p, f, nil
+}
+
+// uid returns the stored uid of a row, or a uuid derived from its table and
+// row id when the row predates uids.
+func uid(table string, id int64, s sql.NullString) uuid.UUID {
+	if s.Valid {
+		if u, err := uuid.Parse(s.String); err == nil {
+			return u
+		}
+	}
+	return uuid.NewSHA1(uuid.NameSpaceOID, fmt.Appendf(nil, "cargoworker/%s/%d", table, id))
+}
+
+// ref is uid for nullable foreign keys: a NULL id yields the zero uuid.
+func ref(table string, id sql.NullInt64, s sql.NullString) uuid.UUID {
+	if !id.Valid {
+		return uuid.Nil
+	}
+	return uid(table, id.Int64, s)
+}
+
+// each runs q and calls scan for every row.
+func each(ctx context.Context, rdb *sql.DB, q string, scan func(*sql.Rows) error) error {
+	rows, err := rdb.QueryContext(ctx, q)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func walkProjects(ctx context.Context, rdb *sql.DB, fn WalkFunc) error {
+	return each(ctx, rdb, `
+		SELECT id, uid, name, root_path, created_at, COALESCE(tool_version, ''), COALESCE(ir_schema, '')
+		FROM project ORDER BY id;`, func(rows *sql.Rows) error {
+		var (
+			p       ir.Project
+			id      int64
+			u       sql.NullString
+			created string
+		)
+		if err := rows.Scan(&id, &u, &p.Name, &p.RootUri, &created, &p.ToolVersion, &p.IrSchema); err != nil {
+			return err
+		}
+		p.Id = uid("project", id, u)
+		p.CreatedUtc = parseTime(created)
+		return fn(TypeProject, p)
+	})
+}
+
+// The project of every container and file is the run's single project.
+const projectUID = `(SELECT uid FROM project ORDER BY id LIMIT 1)`
+
+func walkContainers(ctx context.Context, rdb *sql.DB, fn WalkFunc) error {
+	return each(ctx, rdb, `
+		SELECT p.id, p.uid, `+projectUID+`, p.parent_id, pp.uid,
+		       COALESCE(p.language, ''), p.name, p.import_path, COALESCE(p.kind, ''),
+		       COALESCE(p.version_tag, ''), COALESCE(p.doc, ''), COALESCE(p.doc_fmt, ''), COALESCE(p.extra_json, '')
+		FROM package p
+		LEFT JOIN package pp ON pp.id = p.parent_id
+		ORDER BY p.import_path, p.uid, p.id;`, func(rows *sql.Rows) error {
+		var (
+			c          ir.Container
+			id         int64
+			u, pu, ppu sql.NullString
+			parent     sql.NullInt64
+		)
+		if err := rows.Scan(&id, &u, &pu, &parent, &ppu, &c.Language, &c.Name, &c.FullName, &c.Kind,
+			&c.VersionTag, &c.DocRaw, &c.DocFmt, &c.ExtraJson); err != nil {
+			return err
+		}
+		c.Id = uid("package", id, u)
+		c.ProjectId = projectRef(pu)
+		c.ParentId = ref("package", parent, ppu)
+		return fn(TypeContainer, c)
+	})
+}
+
+func walkFiles(ctx context.Context, rdb *sql.DB, fn WalkFunc) error {
+	return each(ctx, rdb, `
+		SELECT f.id, f.uid, `+projectUID+`, f.package_id, p.uid, f.rel_path,
+		       COALESCE(f.digest, ''), COALESCE(f.language, ''), f.size_bytes
+		FROM file f
+		LEFT JOIN package p ON p.id = f.package_id
+		ORDER BY p.import_path, f.rel_path, f.uid, f.id;`, func(rows *sql.Rows) error {
+		var (
+			fl     ir.File
+			id     int64
+			u, pu  sql.NullString
+			pkg    sql.NullInt64
+			pkgUID sql.NullString
+		)
+		if err := rows.Scan(&id, &u, &pu, &pkg, &pkgUID, &fl.Path, &fl.Checksum, &fl.Language, &fl.SizeBytes); err != nil {
+			return err
+		}
+		fl.Id = uid("file", id, u)
+		fl.ProjectId = projectRef(pu)
+		fl.ContainerId = ref("package", pkg, pkgUID)
+		return fn(TypeFile, fl)
+	})
+}
+
+// symbolOrder sorts symbols (aliased s, with package p) by natural key.
+const symbolOrder = `p.import_path, COALESCE(s.full_name, s.name), s.kind, COALESCE(s.line, 0), s.uid, s.id`
+
+func walkSymbols(ctx context.Context, rdb *sql.DB, fn WalkFunc) error {
+	return each(ctx, rdb, `
+		SELECT s.id, s.uid, s.package_id, p.uid, s.name, COALESCE(s.full_name, ''), s.kind,
+		       COALESCE(s.visibility, ''), s.flags, s.file_id, f.uid,
+		       COALESCE(s.line, 0), COALESCE(s.col, 0), COALESCE(s.end_line, 0), COALESCE(s.end_col, 0),
+		       COALESCE(s.doc_raw, ''), COALESCE(s.doc, ''), COALESCE(s.extra_json, '')
+		FROM symbol s
+		JOIN package p ON p.id = s.package_id
+		LEFT JOIN file f ON f.id = s.file_id
+		ORDER BY `+symbolOrder+`;`, func(rows *sql.Rows) error {
+		var (
+			s         ir.Symbol
+			id, pkg   int64
+			u, pu, fu sql.NullString
+			file      sql.NullInt64
+		)
+		if err := rows.Scan(&id, &u, &pkg, &pu, &s.Name, &s.FullName, &s.Kind,
+			&s.Visibility, &s.Flags, &file, &fu,
+			&s.StartLine, &s.StartCol, &s.EndLine, &s.EndCol,
+			&s.DocRaw, &s.DocFmt, &s.ExtraJson); err != nil {
+			return err
+		}
+		s.Id = uid("symbol", id, u)
+		s.ContainerId = uid("package", pkg, pu)
+		s.OriginFileId = ref("file", file, fu)
+		return fn(TypeSymbol, s)
+	})
+}
+
+func walkSignatures(ctx context.Context, rdb *sql.DB, fn WalkFunc) error {
+	return each(ctx, rdb, `
+		SELECT s.id, s.uid, g.text, COALESCE(g.json, '')
+		FROM signature g
+		JOIN symbol s ON s.id = g.symbol_id
+		JOIN package p ON p.id = s.package_id
+		ORDER BY `+symbolOrder+`, g.id;`, func(rows *sql.Rows) error {
+		var (
+			g  ir.Signature
+			id int64
+			u  sql.NullString
+		)
+		if err := rows.Scan(&id, &u, &g.Text, &g.Json); err != nil {
+			return err
+		}
+		g.SymbolId = uid("symbol", id, u)
+		return fn(TypeSignature, g)
+	})
+}
+
+func walkTyperefs(ctx context.Context, rdb *sql.DB, fn WalkFunc) error {
+	return each(ctx, rdb, `
+		SELECT t.id, t.uid, s.id, s.uid, COALESCE(t.slot, ''), COALESCE(t.json, ''), COALESCE(t.ord, 0)
+		FROM type_ref t
+		JOIN symbol s ON s.id = t.symbol_id
+		JOIN package p ON p.id = s.package_id
+		ORDER BY `+symbolOrder+`, t.ord, t.slot, t.id;`, func(rows *sql.Rows) error {
+		var (
+			t         ir.Typeref
+			id, owner int64
+			u, ou     sql.NullString
+		)
+		if err := rows.Scan(&id, &u, &owner, &ou, &t.Slot, &t.Json, &t.Order); err != nil {
+			return err
+		}
+		t.Id = uid("type_ref", id, u)
+		t.OwnerSymbolId = uid("symbol", owner, ou)
+		return fn(TypeTyperef, t)
+	})
+}
+
+func walkMembers(ctx context.Context, rdb *sql.DB, fn WalkFunc) error {
+	return each(ctx, rdb, `
+		SELECT m.id, m.uid, s.id, s.uid, m.child_symbol_id, c.uid, COALESCE(m.ord, 0)
+		FROM member m
+		JOIN symbol s ON s.id = m.parent_symbol_id
+		JOIN package p ON p.id = s.package_id
+		LEFT JOIN symbol c ON c.id = m.child_symbol_id
+		ORDER BY `+symbolOrder+`, m.ord, m.name, m.id;`, func(rows *sql.Rows) error {
+		var (
+			m         ir.Member
+			id, owner int64
+			child     sql.NullInt64
+			u, ou, cu sql.NullString
+		)
+		if err := rows.Scan(&id, &u, &owner, &ou, &child, &cu, &m.Order); err != nil {
+			return err
+		}
+		m.Id = uid("member", id, u)
+		m.OwnerSymbolId = uid("symbol", owner, ou)
+		m.ChildSymbolId = ref("symbol", child, cu)
+		return fn(TypeMember, m)
+	})
+}
+
+func walkRelations(ctx context.Context, rdb *sql.DB, fn WalkFunc) error {
+	return each(ctx, rdb, `
+		SELECT s.id, s.uid, r.kind, d.id, d.uid, COALESCE(r.detail, '')
+		FROM relation r
+		JOIN symbol s ON s.id = r.from_symbol_id
+		JOIN package p ON p.id = s.package_id
+		JOIN symbol d ON d.id = r.to_symbol_id
+		JOIN package dp ON dp.id = d.package_id
+		ORDER BY `+symbolOrder+`, r.kind, dp.import_path, COALESCE(d.full_name, d.name), d.kind, d.uid, r.id;`,
+		func(rows *sql.Rows) error {
+			var (
+				r        ir.Relation
+				src, dst int64
+				su, du   sql.NullString
+			)
+			if err := rows.Scan(&src, &su, &r.Relation, &dst, &du, &r.DetailsJson); err != nil {
+				return err
+			}
+			r.SourceSymbolId = uid("symbol", src, su)
+			r.DstSymbolId = uid("symbol", dst, du)
+			return fn(TypeRelation, r)
+		})
+}
+
+func walkImports(ctx context.Context, rdb *sql.DB, fn WalkFunc) error {
+	return each(ctx, rdb, `
+		SELECT p.id, p.uid, i.path, COALESCE(i.alias, ''), COALESCE(i.details_json, '')
+		FROM pkg_import i
+		JOIN package p ON p.id = i.package_id
+		ORDER BY p.import_path, p.uid, i.path, i.alias, i.id;`, func(rows *sql.Rows) error {
+		var (
+			im  ir.Import
+			pkg int64
+			pu  sql.NullString
+		)
+		if err := rows.Scan(&pkg, &pu, &im.Target, &im.Alias, &im.DetailsJson); err != nil {
+			return err
+		}
+		im.ContainerId = uid("package", pkg, pu)
+		return fn(TypeImport, im)
+	})
+}
+
+func walkDiagnostics(ctx context.Context, rdb *sql.DB, fn WalkFunc) error {
+	return each(ctx, rdb, `
+		SELECT d.id, d.uid, d.scope, d.severity, COALESCE(d.code, ''), d.message,
+		       d.file_id, f.uid, COALESCE(d.line, 0), COALESCE(d.col, 0)
+		FROM diagnostic d
+		LEFT JOIN file f ON f.id = d.file_id
+		ORDER BY COALESCE(f.rel_path, ''), d.line, d.col, d.code, d.message, d.uid, d.id;`, func(rows *sql.Rows) error {
+		var (
+			d     ir.Diagnostic
+			id    int64
+			file  sql.NullInt64
+			u, fu sql.NullString
+		)
+		if err := rows.Scan(&id, &u, &d.Scope, &d.Severity, &d.Code, &d.Message, &file, &fu, &d.Line, &d.Column); err != nil {
+			return err
+		}
+		d.Id = uid("diagnostic", id, u)
+		d.FileId = ref("file", file, fu)
+		return fn(TypeDiagnostic, d)
+	})
+}
+
+func projectRef(s sql.NullString) uuid.UUID {
+	if !s.Valid {
+		return uuid.Nil
+	}
+	u, _ := uuid.Parse(s.String)
+	return u
+}
+
+// parseTime accepts the RFC 3339 timestamps the store writes and SQLite's
+// datetime('now') default.
+func parseTime(s string) time.Time {
+	for _, layout := range []string{time.RFC3339Nano, time.DateTime} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC()
+		}
+	}
+	return time.Time{}
+}
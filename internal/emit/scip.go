@@ -0,0 +1,489 @@
+package emit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/ChaseHampton/cargoworker/internal/ir"
+)
+
+// SCIPScheme is the scheme of every symbol moniker in emit scip output.
+const SCIPScheme = "cargoworker"
+
+// SCIPResult summarizes a WriteSCIP run.
+type SCIPResult struct {
+	Documents   int `json:"documents"`
+	Symbols     int `json:"symbols"`
+	Occurrences int `json:"occurrences"`
+	External    int `json:"external"` // symbols without a source file
+}
+
+// Field numbers and enum values from scip.proto
+// (https://github.com/sourcegraph/scip/blob/main/scip.proto).
+const (
+	scipIndexMetadata        = 1
+	scipIndexDocuments       = 2
+	scipIndexExternalSymbols = 3
+
+	scipMetadataToolInfo     = 2
+	scipMetadataProjectRoot  = 3
+	scipMetadataTextEncoding = 4
+
+	scipToolName    = 1
+	scipToolVersion = 2
+
+	scipDocPath             = 1
+	scipDocOccurrences      = 2
+	scipDocSymbols          = 3
+	scipDocLanguage         = 4
+	scipDocText             = 5
+	scipDocPositionEncoding = 6
+
+	scipOccRange          = 1
+	scipOccSymbol         = 2
+	scipOccRoles          = 3
+	scipOccEnclosingRange = 7
+
+	scipInfoSymbol          = 1
+	scipInfoDocumentation   = 3
+	scipInfoRelationships   = 4
+	scipInfoKind            = 5
+	scipInfoDisplayName     = 6
+	scipInfoSignature       = 7
+	scipInfoEnclosingSymbol = 8
+
+	scipRelSymbol         = 1
+	scipRelImplementation = 3
+
+	scipTextUTF8       = 1
+	scipPositionUTF8   = 1 // UTF8CodeUnitOffsetFromLineStart
+	scipRoleDefinition = 1
+)
+
+// scipKinds maps symbol kinds to SymbolInformation.Kind.
+var scipKinds = map[string]int{
+	"class":       7,
+	"const":       8,
+	"constant":    8,
+	"constructor": 9,
+	"enum":        11,
+	"enum_member": 12,
+	"field":       15,
+	"function":    17,
+	"interface":   21,
+	"macro":       25,
+	"method":      26,
+	"module":      29,
+	"namespace":   30,
+	"package":     35,
+	"property":    41,
+	"protocol":    42,
+	"struct":      49,
+	"trait":       53,
+	"type":        54,
+	"alias":       55,
+	"typedef":     55,
+	"union":       59,
+	"var":         61,
+	"variable":    61,
+}
+
+// Relation kinds exported as references to their target, and as
+// implementation relationships of their source.
+var (
+	scipReferenceRelations      = map[string]bool{"calls": true, "references": true}
+	scipImplementationRelations = map[string]bool{"implements": true, "satisfies": true, "overrides": true}
+)
+
+// WriteSCIP writes the run as a SCIP index (one protobuf Index message) to w.
+//
+// Every symbol gets a moniker derived from its container and full name, so
+// re-indexing an unchanged code base yields the same monikers:
+//
+//	cargoworker go example.com/mod/sub . File#Read().
+//
+// Definitions cover the symbol's start and end line/column. References come
+// from type references and "calls"/"references" relations; their range is
+// taken from "line", "col", "end_line" and "end_col" (1-based) in the record's
+// JSON, or is the using symbol's declaration when the record has no location.
+// Symbols without a source file are listed as external symbols.
+func WriteSCIP(ctx context.Context, rdb *sql.DB, w io.Writer) (*SCIPResult, error) {
+	p, f, err := Load(ctx, rdb)
+	if err != nil {
+		return nil, err
+	}
+	m, err := newModel(p, f)
+	if err != nil {
+		return nil, err
+	}
+	b, res := m.scipIndex(f)
+	if _, err := w.Write(b); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+type scipOccurrence struct {
+	rng       []int32
+	symbol    string
+	roles     int
+	enclosing []int32
+}
+
+type scipDocument struct {
+	path, language string
+	occurrences    []scipOccurrence
+	symbols        []*docSymbol
+}
+
+func (m *docModel) scipIndex(f *ir.Fragment) ([]byte, *SCIPResult) {
+	monikers := m.scipMonikers()
+	res := &SCIPResult{Symbols: len(monikers)}
+
+	docs := map[string]*scipDocument{}
+	for _, fl := range f.Files {
+		path := filepath.ToSlash(fl.Path)
+		docs[path] = &scipDocument{path: path, language: fl.Language}
+	}
+	doc := func(ds *docSymbol) *scipDocument {
+		if ds.File == "" {
+			return nil
+		}
+		return docs[filepath.ToSlash(ds.File)]
+	}
+
+	var external []*docSymbol
+	for _, ds := range m.sortedSymbols() {
+		d := doc(ds)
+		if d == nil {
+			external = append(external, ds)
+			continue
+		}
+		d.symbols = append(d.symbols, ds)
+		if rng := scipRange(ds.StartLine, ds.StartCol, ds.EndLine, ds.EndCol); rng != nil {
+			d.occurrences = append(d.occurrences, scipOccurrence{rng: rng, symbol: monikers[ds], roles: scipRoleDefinition})
+		}
+	}
+
+	reference := func(owner, target *docSymbol, js string) {
+		d := doc(owner)
+		if d == nil || target == nil {
+			return
+		}
+		decl := scipRange(owner.StartLine, owner.StartCol, owner.EndLine, owner.EndCol)
+		rng := scipLocation(js)
+		if rng == nil {
+			rng = decl
+		}
+		if rng == nil {
+			return
+		}
+		d.occurrences = append(d.occurrences, scipOccurrence{rng: rng, symbol: monikers[target], enclosing: decl})
+	}
+	for _, tr := range f.Typerefs {
+		if owner := m.symbols[tr.OwnerSymbolId]; owner != nil {
+			reference(owner, m.resolveTyperef(owner.Container, tr.Json), tr.Json)
+		}
+	}
+	for _, rel := range f.Relations {
+		if scipReferenceRelations[rel.Relation] {
+			reference(m.symbols[rel.SourceSymbolId], m.symbols[rel.DstSymbolId], rel.DetailsJson)
+		}
+	}
+
+	var meta pbuf
+	var tool pbuf
+	tool.str(scipToolName, SCIPScheme)
+	if m.Project != nil {
+		tool.str(scipToolVersion, m.Project.ToolVersion)
+	}
+	meta.msg(scipMetadataToolInfo, tool)
+	if m.Project != nil {
+		meta.str(scipMetadataProjectRoot, projectRootURI(m.Project.RootUri))
+	}
+	meta.varint(scipMetadataTextEncoding, scipTextUTF8)
+
+	var idx pbuf
+	idx.msg(scipIndexMetadata, meta)
+
+	paths := make([]string, 0, len(docs))
+	for path, d := range docs {
+		if len(d.symbols)+len(d.occurrences) > 0 {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		d := docs[path]
+		sortOccurrences(d.occurrences)
+		var db pbuf
+		db.str(scipDocPath, d.path)
+		for _, o := range d.occurrences {
+			var ob pbuf
+			ob.packed(scipOccRange, o.rng)
+			ob.str(scipOccSymbol, o.symbol)
+			ob.varint(scipOccRoles, uint64(o.roles))
+			ob.packed(scipOccEnclosingRange, o.enclosing)
+			db.msg(scipDocOccurrences, ob)
+		}
+		for _, ds := range d.symbols {
+			db.msg(scipDocSymbols, m.scipInfo(ds, monikers))
+		}
+		db.str(scipDocLanguage, d.language)
+		db.varint(scipDocPositionEncoding, scipPositionUTF8)
+		idx.msg(scipIndexDocuments, db)
+		res.Documents++
+		res.Occurrences += len(d.occurrences)
+	}
+	for _, ds := range external {
+		idx.msg(scipIndexExternalSymbols, m.scipInfo(ds, monikers))
+	}
+	res.External = len(external)
+	return idx, res
+}
+
+func (m *docModel) scipInfo(ds *docSymbol, monikers map[*docSymbol]string) pbuf {
+	var b pbuf
+	b.str(scipInfoSymbol, monikers[ds])
+	doc := ds.DocFmt
+	if strings.TrimSpace(doc) == "" {
+		doc = ds.DocRaw
+	}
+	if doc = strings.TrimSpace(doc); doc != "" {
+		b.str(scipInfoDocumentation, doc)
+	}
+	var impl []string
+	for _, r := range ds.Out {
+		if scipImplementationRelations[r.Kind] {
+			impl = append(impl, monikers[r.Other])
+		}
+	}
+	sort.Strings(impl)
+	for i, sym := range impl {
+		if i > 0 && sym == impl[i-1] {
+			continue
+		}
+		var rb pbuf
+		rb.str(scipRelSymbol, sym)
+		rb.varint(scipRelImplementation, 1)
+		b.msg(scipInfoRelationships, rb)
+	}
+	b.varint(scipInfoKind, uint64(scipKinds[ds.Kind]))
+	b.str(scipInfoDisplayName, ds.Name)
+	if ds.Signature != "" {
+		var sb pbuf
+		sb.str(scipDocLanguage, ds.Container.Language)
+		sb.str(scipDocText, ds.Signature)
+		b.msg(scipInfoSignature, sb)
+	}
+	if ds.Owner != nil {
+		b.str(scipInfoEnclosingSymbol, monikers[ds.Owner])
+	}
+	return b
+}
+
+func (m *docModel) sortedSymbols() []*docSymbol {
+	all := make([]*docSymbol, 0, len(m.symbols))
+	for _, ds := range m.symbols {
+		all = append(all, ds)
+	}
+	sort.Slice(all, func(i, j int) bool { return symbolLess(all[i], all[j]) })
+	return all
+}
+
+// scipMonikers names every symbol "cargoworker <language> <container> <version>
+// <descriptors>", the descriptors following the full name below its container.
+// Overloads that would share a moniker get a "(+N)" disambiguator.
+func (m *docModel) scipMonikers() map[*docSymbol]string {
+	out := make(map[*docSymbol]string, len(m.symbols))
+	taken := map[string]int{}
+	for _, ds := range m.sortedSymbols() {
+		c := ds.Container
+		pkg := strings.Join([]string{SCIPScheme, scipPackagePart(c.Language), scipPackagePart(c.FullName),
+			scipPackagePart(c.VersionTag)}, " ")
+
+		path := ds.FullName
+		if rest, ok := strings.CutPrefix(path, c.FullName+"."); ok {
+			path = rest
+		} else if path == "" || path == c.FullName {
+			path = ds.Name
+		}
+		segs := strings.Split(strings.ReplaceAll(path, "::", "."), ".")
+
+		var desc strings.Builder
+		for i, seg := range segs[:len(segs)-1] {
+			kind := "type"
+			if owner := m.byName[c.FullName+"."+strings.Join(segs[:i+1], ".")]; owner != nil {
+				kind = owner.Kind
+			}
+			desc.WriteString(scipDescriptor(seg, kind, ""))
+		}
+		last := segs[len(segs)-1]
+		moniker := pkg + " " + desc.String() + scipDescriptor(last, ds.Kind, "")
+		if n := taken[moniker]; n > 0 && scipIsMethod(ds.Kind) {
+			moniker = pkg + " " + desc.String() + scipDescriptor(last, ds.Kind, fmt.Sprintf("+%d", n))
+		}
+		taken[pkg+" "+desc.String()+scipDescriptor(last, ds.Kind, "")]++
+		out[ds] = moniker
+	}
+	return out
+}
+
+func scipIsMethod(kind string) bool {
+	switch kind {
+	case "function", "method", "constructor":
+		return true
+	}
+	return false
+}
+
+// scipDescriptor formats one descriptor: "name/" for namespaces, "name#" for
+// types, "name(disambiguator)." for callables, "name!" for macros and
+// "name." for other terms.
+func scipDescriptor(name, kind, disambiguator string) string {
+	name = scipName(name)
+	switch kind {
+	case "module", "namespace", "package":
+		return name + "/"
+	case "class", "struct", "interface", "trait", "protocol", "enum", "union", "record", "type", "alias", "typedef":
+		return name + "#"
+	case "macro":
+		return name + "!"
+	}
+	if scipIsMethod(kind) {
+		return name + "(" + disambiguator + ")."
+	}
+	return name + "."
+}
+
+// scipName backquotes names that are not simple identifiers.
+func scipName(name string) string {
+	simple := name != ""
+	for _, r := range name {
+		if !(r == '_' || r == '+' || r == '-' || r == '$' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+			simple = false
+			break
+		}
+	}
+	if simple {
+		return name
+	}
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// scipPackagePart escapes a space-separated moniker field; "." stands for
+// an empty value.
+func scipPackagePart(s string) string {
+	if s == "" {
+		return "."
+	}
+	return strings.ReplaceAll(s, " ", "  ")
+}
+
+// scipRange converts 1-based positions to a SCIP range: [line, col, endcol]
+// on one line, [line, col, endline, endcol] otherwise. A missing end line is
+// the start line, a missing end column the start column; a missing start
+// yields nil.
+func scipRange(line, col, endLine, endCol int) []int32 {
+	if line <= 0 {
+		return nil
+	}
+	col = max(col, 1)
+	if endLine <= 0 {
+		endLine = line
+	} else if endLine < line {
+		endLine, endCol = line, col
+	}
+	if endCol <= 0 {
+		endCol = col
+	}
+	if endLine == line {
+		return []int32{int32(line - 1), int32(col - 1), int32(max(endCol, col) - 1)}
+	}
+	return []int32{int32(line - 1), int32(col - 1), int32(endLine - 1), int32(endCol - 1)}
+}
+
+// scipLocation reads a reference site from a record's JSON.
+func scipLocation(js string) []int32 {
+	var loc struct {
+		Line    int `json:"line"`
+		Col     int `json:"col"`
+		EndLine int `json:"end_line"`
+		EndCol  int `json:"end_col"`
+	}
+	if js == "" || json.Unmarshal([]byte(js), &loc) != nil {
+		return nil
+	}
+	return scipRange(loc.Line, loc.Col, loc.EndLine, loc.EndCol)
+}
+
+func sortOccurrences(occs []scipOccurrence) {
+	sort.SliceStable(occs, func(i, j int) bool {
+		a, b := occs[i], occs[j]
+		for k := 0; k < len(a.rng) && k < len(b.rng); k++ {
+			if a.rng[k] != b.rng[k] {
+				return a.rng[k] < b.rng[k]
+			}
+		}
+		if len(a.rng) != len(b.rng) {
+			return len(a.rng) < len(b.rng)
+		}
+		if a.roles != b.roles {
+			return a.roles > b.roles // definitions first
+		}
+		return a.symbol < b.symbol
+	})
+}
+
+// projectRootURI turns a root path into a file:// URI; values that already
+// carry a scheme are kept.
+func projectRootURI(root string) string {
+	if root == "" || strings.Contains(root, "://") {
+		return root
+	}
+	return "file://" + filepath.ToSlash(root)
+}
+
+// pbuf appends protobuf fields, leaving out zero values as proto3 does.
+type pbuf []byte
+
+func (b *pbuf) str(num protowire.Number, s string) {
+	if s == "" {
+		return
+	}
+	*b = protowire.AppendTag(*b, num, protowire.BytesType)
+	*b = protowire.AppendString(*b, s)
+}
+
+func (b *pbuf) msg(num protowire.Number, m pbuf) {
+	*b = protowire.AppendTag(*b, num, protowire.BytesType)
+	*b = protowire.AppendBytes(*b, m)
+}
+
+func (b *pbuf) varint(num protowire.Number, v uint64) {
+	if v == 0 {
+		return
+	}
+	*b = protowire.AppendTag(*b, num, protowire.VarintType)
+	*b = protowire.AppendVarint(*b, v)
+}
+
+func (b *pbuf) packed(num protowire.Number, vs []int32) {
+	if len(vs) == 0 {
+		return
+	}
+	var p []byte
+	for _, v := range vs {
+		p = protowire.AppendVarint(p, uint64(int64(v)))
+	}
+	*b = protowire.AppendTag(*b, num, protowire.BytesType)
+	*b = protowire.AppendBytes(*b, p)
+}
@@ -0,0 +1,188 @@
+package emit
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/ChaseHampton/cargoworker/internal/ir"
+)
+
+// pbField is one decoded protobuf field: bytes for length-delimited values,
+// n for varints.
+type pbField struct {
+	num   protowire.Number
+	bytes []byte
+	n     uint64
+}
+
+func pbDecode(t *testing.T, b []byte) []pbField {
+	t.Helper()
+	var out []pbField
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			t.Fatalf("bad tag: %v", protowire.ParseError(n))
+		}
+		b = b[n:]
+		f := pbField{num: num}
+		switch typ {
+		case protowire.VarintType:
+			f.n, n = protowire.ConsumeVarint(b)
+		case protowire.BytesType:
+			f.bytes, n = protowire.ConsumeBytes(b)
+		default:
+			t.Fatalf("unexpected wire type %d", typ)
+		}
+		if n < 0 {
+			t.Fatalf("bad field %d: %v", num, protowire.ParseError(n))
+		}
+		b = b[n:]
+		out = append(out, f)
+	}
+	return out
+}
+
+func pbGet(fs []pbField, num protowire.Number) []pbField {
+	var out []pbField
+	for _, f := range fs {
+		if f.num == num {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+func pbInts(t *testing.T, b []byte) []int32 {
+	var out []int32
+	for len(b) > 0 {
+		v, n := protowire.ConsumeVarint(b)
+		if n < 0 {
+			t.Fatalf("bad packed varint")
+		}
+		out = append(out, int32(v))
+		b = b[n:]
+	}
+	return out
+}
+
+func TestWriteSCIP(t *testing.T) {
+	ctx := context.Background()
+	p, f := testRun()
+	typ := f.Symbols[1].Id
+	f.Symbols[0].StartCol, f.Symbols[0].EndCol = 1, 2
+	f.Relations = append(f.Relations, ir.Relation{SourceSymbolId: f.Symbols[0].Id, Relation: "calls", DstSymbolId: typ,
+		DetailsJson: `{"line":8,"col":3,"end_col":7}`})
+	rdb := writeDB(t, ctx, p, f)
+
+	var buf bytes.Buffer
+	res, err := WriteSCIP(ctx, rdb, &buf)
+	if err != nil {
+		t.Fatalf("scip: %v", err)
+	}
+	if res.Documents != 2 || res.Symbols != 3 || res.External != 0 || res.Occurrences != 3 {
+		t.Fatalf("result = %+v", res)
+	}
+
+	index := pbDecode(t, buf.Bytes())
+	meta := pbDecode(t, pbGet(index, scipIndexMetadata)[0].bytes)
+	if root := string(pbGet(meta, scipMetadataProjectRoot)[0].bytes); root != "file:///src/mod" {
+		t.Errorf("project root = %q", root)
+	}
+	docs := pbGet(index, scipIndexDocuments)
+	if len(docs) != 2 {
+		t.Fatalf("%d documents", len(docs))
+	}
+	sub := pbDecode(t, docs[1].bytes)
+	if path := string(pbGet(sub, scipDocPath)[0].bytes); path != "sub/file.go" {
+		t.Fatalf("second document = %q", path)
+	}
+
+	const (
+		file = "cargoworker go example.com/mod/sub . File#"
+		read = "cargoworker go example.com/mod/sub . File#Read()."
+	)
+	type occ struct {
+		rng    []int32
+		symbol string
+		roles  uint64
+	}
+	var got []occ
+	for _, o := range pbGet(sub, scipDocOccurrences) {
+		fs := pbDecode(t, o.bytes)
+		var x occ
+		x.rng = pbInts(t, pbGet(fs, scipOccRange)[0].bytes)
+		x.symbol = string(pbGet(fs, scipOccSymbol)[0].bytes)
+		if r := pbGet(fs, scipOccRoles); len(r) > 0 {
+			x.roles = r[0].n
+		}
+		got = append(got, x)
+	}
+	want := []occ{
+		{[]int32{2, 0, 0}, file, 1},
+		{[]int32{6, 0, 8, 1}, read, 1},
+		{[]int32{7, 2, 6}, file, 0},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("occurrences = %+v", got)
+	}
+	for i := range want {
+		if !equalInts(got[i].rng, want[i].rng) || got[i].symbol != want[i].symbol || got[i].roles != want[i].roles {
+			t.Errorf("occurrence %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	var fileInfo []pbField
+	for _, s := range pbGet(sub, scipDocSymbols) {
+		fs := pbDecode(t, s.bytes)
+		if string(pbGet(fs, scipInfoSymbol)[0].bytes) == file {
+			fileInfo = fs
+		}
+	}
+	rels := pbGet(fileInfo, scipInfoRelationships)
+	if len(rels) != 1 {
+		t.Fatalf("File relationships = %d", len(rels))
+	}
+	rel := pbDecode(t, rels[0].bytes)
+	if string(pbGet(rel, scipRelSymbol)[0].bytes) != "cargoworker go example.com/mod . Reader#" || pbGet(rel, scipRelImplementation)[0].n != 1 {
+		t.Errorf("relationship = %+v", rel)
+	}
+
+	var again bytes.Buffer
+	if _, err := WriteSCIP(ctx, writeDB(t, ctx, p, f), &again); err != nil {
+		t.Fatalf("scip: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), again.Bytes()) {
+		t.Error("output differs between runs")
+	}
+}
+
+func TestSCIPDescriptor(t *testing.T) {
+	for _, c := range []struct{ name, kind, dis, want string }{
+		{"Read", "method", "", "Read()."},
+		{"Read", "function", "+1", "Read(+1)."},
+		{"File", "struct", "", "File#"},
+		{"sub", "package", "", "sub/"},
+		{"MAX", "const", "", "MAX."},
+		{"operator<", "function", "", "`operator<`()."},
+		{"a`b", "var", "", "`a``b`."},
+	} {
+		if got := scipDescriptor(c.name, c.kind, c.dis); got != c.want {
+			t.Errorf("scipDescriptor(%q, %q) = %q, want %q", c.name, c.kind, got, c.want)
+		}
+	}
+}
+
+func equalInts(a, b []int32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
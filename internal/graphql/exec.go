@@ -0,0 +1,552 @@
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// maxDepth bounds the nesting of a query's selection sets.
+const maxDepth = 12
+
+// resolver resolves one field for every parent object at the same position
+// in the result at once, returning one value per parent. Executing a level of
+// the query at a time is what batches lookups: a field under a list of 50
+// symbols costs one query, not 50.
+//
+// Objects are passed as the loader's row types; lists are []any.
+type resolver func(ctx context.Context, l *loader, parents []any, args map[string]any) ([]any, error)
+
+// Request is a GraphQL request as sent over HTTP.
+type Request struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName,omitempty"`
+	Variables     map[string]any `json:"variables,omitempty"`
+}
+
+type Response struct {
+	Data   any      `json:"data"`
+	Errors []*Error `json:"errors,omitempty"`
+}
+
+type Error struct {
+	Message   string     `json:"message"`
+	Locations []Location `json:"locations,omitempty"`
+	Path      []any      `json:"path,omitempty"`
+}
+
+type Location struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+func (e *Error) Error() string { return e.Message }
+
+// object is a result object; it keeps fields in selection order.
+type object []objectField
+
+type objectField struct {
+	key string
+	val any
+}
+
+func (o object) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, f := range o {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		k, _ := json.Marshal(f.key)
+		b.Write(k)
+		b.WriteByte(':')
+		v, err := json.Marshal(f.val)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(v)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+type executor struct {
+	schema *Schema
+	doc    *document
+	vars   map[string]any
+	loader *loader
+	errs   []*Error
+}
+
+func (e *executor) errorf(f *field, path []any, format string, args ...any) {
+	err := &Error{Message: fmt.Sprintf(format, args...), Path: append([]any(nil), path...)}
+	if f != nil {
+		err.Locations = []Location{{f.line, f.col}}
+	}
+	e.errs = append(e.errs, err)
+}
+
+// Execute runs req against the schema. Syntax and validation errors are
+// reported in the response with null data, like field errors are reported
+// next to partial data.
+func (s *Schema) Execute(ctx context.Context, l *loader, req Request) *Response {
+	doc, err := parseDocument(req.Query)
+	if err != nil {
+		return &Response{Errors: []*Error{requestError(err)}}
+	}
+	op, err := doc.operation(req.OperationName)
+	if err != nil {
+		return &Response{Errors: []*Error{{Message: err.Error()}}}
+	}
+	e := &executor{schema: s, doc: doc, loader: l}
+	if e.vars, err = coerceVariables(op.vars, req.Variables); err != nil {
+		return &Response{Errors: []*Error{{Message: err.Error()}}}
+	}
+	if err := e.validate(s.query, op.sel, 1, map[string]bool{}); err != nil {
+		return &Response{Errors: []*Error{err}}
+	}
+	res := e.execObjects(ctx, s.query, []any{struct{}{}}, op.sel, nil)
+	return &Response{Data: res[0], Errors: e.errs}
+}
+
+func requestError(err error) *Error {
+	if se, ok := err.(*syntaxError); ok {
+		return &Error{Message: se.Error(), Locations: []Location{{se.line, se.col}}}
+	}
+	return &Error{Message: err.Error()}
+}
+
+func (d *document) operation(name string) (*operation, error) {
+	if name == "" {
+		if len(d.operations) > 1 {
+			return nil, fmt.Errorf("operationName is required when the document has several operations")
+		}
+		return d.operations[0], nil
+	}
+	for _, op := range d.operations {
+		if op.name == name {
+			return op, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown operation %q", name)
+}
+
+func coerceVariables(defs []*varDef, given map[string]any) (map[string]any, error) {
+	out := map[string]any{}
+	for _, d := range defs {
+		if !isInputType(d.typ) {
+			return nil, fmt.Errorf("variable $%s: %s is not an input type", d.name, d.typ)
+		}
+		v, ok := given[d.name]
+		if !ok {
+			if d.hasDef {
+				v, ok = d.def, true
+			} else if d.typ.nonNull {
+				return nil, fmt.Errorf("variable $%s of type %s is required", d.name, d.typ)
+			}
+		}
+		if !ok {
+			continue
+		}
+		c, err := coerceInput(v, d.typ)
+		if err != nil {
+			return nil, fmt.Errorf("variable $%s: %w", d.name, err)
+		}
+		out[d.name] = c
+	}
+	return out, nil
+}
+
+// validate checks selections against the schema before anything runs:
+// fields and arguments must exist, required arguments be given, objects
+// have selections and scalars none, and fragments resolve without cycles.
+func (e *executor) validate(typ *objectType, sel []selection, depth int, visiting map[string]bool) *Error {
+	if depth > maxDepth {
+		return &Error{Message: fmt.Sprintf("query is nested deeper than %d levels", maxDepth)}
+	}
+	for _, s := range sel {
+		switch s := s.(type) {
+		case *field:
+			if err := e.validateDirectives(s.dirs); err != nil {
+				return err
+			}
+			if s.name == "__typename" {
+				if s.sel != nil {
+					return fieldError(s, "field __typename has no subfields")
+				}
+				continue
+			}
+			fd := e.schema.field(typ, s.name)
+			if fd == nil {
+				return fieldError(s, "cannot query field %q on type %s", s.name, typ.name)
+			}
+			given := map[string]bool{}
+			for _, a := range s.args {
+				if fd.arg(a.name) == nil {
+					return fieldError(s, "unknown argument %q on field %s.%s", a.name, typ.name, fd.name)
+				}
+				if given[a.name] {
+					return fieldError(s, "argument %q given twice", a.name)
+				}
+				given[a.name] = true
+			}
+			for _, a := range fd.args {
+				if a.typ.nonNull && !a.hasDef && !given[a.name] {
+					return fieldError(s, "field %s.%s requires argument %q", typ.name, fd.name, a.name)
+				}
+			}
+			sub := e.schema.types[namedType(fd.typ)]
+			switch {
+			case sub == nil && s.sel != nil:
+				return fieldError(s, "field %q of type %s has no subfields", s.name, fd.typ)
+			case sub != nil && s.sel == nil:
+				return fieldError(s, "field %q of type %s needs a selection of subfields", s.name, fd.typ)
+			case sub != nil:
+				if err := e.validate(sub, s.sel, depth+1, visiting); err != nil {
+					return err
+				}
+			}
+		case *fragmentSpread:
+			if err := e.validateDirectives(s.dirs); err != nil {
+				return err
+			}
+			f := e.doc.fragments[s.name]
+			if f == nil {
+				return &Error{Message: fmt.Sprintf("unknown fragment %q", s.name)}
+			}
+			if visiting[s.name] {
+				return &Error{Message: fmt.Sprintf("fragment %q spreads itself", s.name)}
+			}
+			if f.on != typ.name {
+				return &Error{Message: fmt.Sprintf("fragment %q on %s cannot be spread on type %s", s.name, f.on, typ.name)}
+			}
+			visiting[s.name] = true
+			err := e.validate(typ, f.sel, depth, visiting)
+			delete(visiting, s.name)
+			if err != nil {
+				return err
+			}
+		case *inlineFragment:
+			if err := e.validateDirectives(s.dirs); err != nil {
+				return err
+			}
+			if s.on != "" && s.on != typ.name {
+				return &Error{Message: fmt.Sprintf("inline fragment on %s cannot appear on type %s", s.on, typ.name)}
+			}
+			if err := e.validate(typ, s.sel, depth, visiting); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (e *executor) validateDirectives(dirs []*directive) *Error {
+	for _, d := range dirs {
+		if d.name != "skip" && d.name != "include" {
+			return &Error{Message: fmt.Sprintf("unknown directive @%s", d.name)}
+		}
+		if len(d.args) != 1 || d.args[0].name != "if" {
+			return &Error{Message: fmt.Sprintf("directive @%s takes exactly one argument, if", d.name)}
+		}
+	}
+	return nil
+}
+
+func fieldError(f *field, format string, args ...any) *Error {
+	return &Error{Message: fmt.Sprintf(format, args...), Locations: []Location{{f.line, f.col}}}
+}
+
+// included evaluates @skip and @include.
+func (e *executor) included(dirs []*directive) bool {
+	for _, d := range dirs {
+		v, err := e.resolveValue(d.args[0].val)
+		b, ok := v.(bool)
+		if err != nil || !ok {
+			continue
+		}
+		if d.name == "skip" && b || d.name == "include" && !b {
+			return false
+		}
+	}
+	return true
+}
+
+// fieldGroup is the fields sharing one response key, merged.
+type fieldGroup struct {
+	key    string
+	fields []*field
+}
+
+// collect flattens fragments into the fields to execute, in order.
+func (e *executor) collect(sel []selection, groups []*fieldGroup, index map[string]*fieldGroup) []*fieldGroup {
+	for _, s := range sel {
+		switch s := s.(type) {
+		case *field:
+			if !e.included(s.dirs) {
+				continue
+			}
+			if g, ok := index[s.key()]; ok {
+				g.fields = append(g.fields, s)
+				continue
+			}
+			g := &fieldGroup{key: s.key(), fields: []*field{s}}
+			index[g.key] = g
+			groups = append(groups, g)
+		case *fragmentSpread:
+			if e.included(s.dirs) {
+				groups = e.collect(e.doc.fragments[s.name].sel, groups, index)
+			}
+		case *inlineFragment:
+			if e.included(s.dirs) {
+				groups = e.collect(s.sel, groups, index)
+			}
+		}
+	}
+	return groups
+}
+
+func (e *executor) execObjects(ctx context.Context, typ *objectType, parents []any, sel []selection, path []any) []object {
+	out := make([]object, len(parents))
+	for _, g := range e.collect(sel, nil, map[string]*fieldGroup{}) {
+		f := g.fields[0]
+		fpath := append(append([]any(nil), path...), g.key)
+		if f.name == "__typename" {
+			for i := range out {
+				out[i] = append(out[i], objectField{g.key, typ.name})
+			}
+			continue
+		}
+		fd := e.schema.field(typ, f.name)
+
+		var sub []selection
+		for _, gf := range g.fields {
+			sub = append(sub, gf.sel...)
+		}
+		vals, err := e.resolveField(ctx, fd, f, parents)
+		if err != nil {
+			e.errorf(f, fpath, "%v", err)
+			vals = make([]any, len(parents))
+		}
+		vals = e.complete(ctx, fd.typ, vals, f, sub, fpath)
+		for i := range out {
+			out[i] = append(out[i], objectField{g.key, vals[i]})
+		}
+	}
+	return out
+}
+
+func (e *executor) resolveField(ctx context.Context, fd *fieldDef, f *field, parents []any) ([]any, error) {
+	args := map[string]any{}
+	for _, a := range fd.args {
+		if a.hasDef {
+			args[a.name] = a.def
+		}
+	}
+	for _, a := range f.args {
+		v, err := e.resolveValue(a.val)
+		if err != nil {
+			return nil, err
+		}
+		if _, isVar := a.val.(variable); isVar && v == nil {
+			if _, set := e.vars[string(a.val.(variable))]; !set {
+				continue // unset variable: the default applies
+			}
+		}
+		args[a.name] = v
+	}
+	for _, a := range fd.args {
+		c, err := coerceInput(args[a.name], a.typ)
+		if err != nil {
+			return nil, fmt.Errorf("argument %q: %w", a.name, err)
+		}
+		if c == nil {
+			delete(args, a.name)
+		} else {
+			args[a.name] = c
+		}
+	}
+	vals, err := fd.resolve(ctx, e.loader, parents, args)
+	if err == nil && len(vals) != len(parents) {
+		err = fmt.Errorf("internal: resolver returned %d values for %d objects", len(vals), len(parents))
+	}
+	return vals, err
+}
+
+// resolveValue substitutes variables in a literal.
+func (e *executor) resolveValue(v any) (any, error) {
+	switch v := v.(type) {
+	case variable:
+		return e.vars[string(v)], nil
+	case []any:
+		out := make([]any, len(v))
+		for i, x := range v {
+			r, err := e.resolveValue(x)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = r
+		}
+		return out, nil
+	case enumValue:
+		return nil, fmt.Errorf("unexpected enum value %s", string(v))
+	case objectValue:
+		return nil, fmt.Errorf("input objects are not supported")
+	}
+	return v, nil
+}
+
+// complete shapes resolved values by their schema type: lists are flattened
+// so each element type is completed in one batch, objects execute their
+// selections, and nulls in non-null positions are reported.
+func (e *executor) complete(ctx context.Context, t *typeRef, vals []any, f *field, sel []selection, path []any) []any {
+	out := make([]any, len(vals))
+	switch {
+	case t.elem != nil:
+		var (
+			flat []any
+			lens = make([]int, len(vals))
+		)
+		for i, v := range vals {
+			list, ok := v.([]any)
+			if v == nil || !ok {
+				lens[i] = -1
+				continue
+			}
+			lens[i] = len(list)
+			flat = append(flat, list...)
+		}
+		done := e.complete(ctx, t.elem, flat, f, sel, path)
+		for i, n := range lens {
+			if n < 0 {
+				continue
+			}
+			out[i], done = done[:n:n], done[n:]
+		}
+	case e.schema.types[t.name] != nil:
+		var (
+			idx     []int
+			parents []any
+		)
+		for i, v := range vals {
+			if v != nil {
+				idx = append(idx, i)
+				parents = append(parents, v)
+			}
+		}
+		if len(parents) > 0 {
+			for j, o := range e.execObjects(ctx, e.schema.types[t.name], parents, sel, path) {
+				out[idx[j]] = o
+			}
+		}
+	default:
+		for i, v := range vals {
+			out[i] = serializeScalar(t.name, v)
+		}
+	}
+	if t.nonNull {
+		for _, v := range out {
+			if v == nil {
+				e.errorf(f, path, "null value for non-null field of type %s", t)
+				break
+			}
+		}
+	}
+	return out
+}
+
+func serializeScalar(name string, v any) any {
+	if v == nil {
+		return nil
+	}
+	if name == "ID" {
+		switch id := v.(type) {
+		case int64:
+			return strconv.FormatInt(id, 10)
+		case int:
+			return strconv.Itoa(id)
+		}
+	}
+	return v
+}
+
+var scalarTypes = map[string]bool{"ID": true, "String": true, "Int": true, "Float": true, "Boolean": true}
+
+func namedType(t *typeRef) string {
+	for t.elem != nil {
+		t = t.elem
+	}
+	return t.name
+}
+
+func isInputType(t *typeRef) bool { return scalarTypes[namedType(t)] }
+
+// coerceInput converts an argument or variable value to t: Int arguments
+// become int64, ID arguments strings, and a single value given for a list
+// becomes a list of one.
+func coerceInput(v any, t *typeRef) (any, error) {
+	if v == nil {
+		if t.nonNull {
+			return nil, fmt.Errorf("null for non-null type %s", t)
+		}
+		return nil, nil
+	}
+	if t.elem != nil {
+		list, ok := v.([]any)
+		if !ok {
+			list = []any{v}
+		}
+		out := make([]any, len(list))
+		for i, x := range list {
+			c, err := coerceInput(x, t.elem)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = c
+		}
+		return out, nil
+	}
+	switch t.name {
+	case "Int":
+		switch n := v.(type) {
+		case int64:
+			return n, nil
+		case float64:
+			if n == math.Trunc(n) && math.Abs(n) < 1<<53 {
+				return int64(n), nil
+			}
+		}
+	case "Float":
+		switch n := v.(type) {
+		case int64:
+			return float64(n), nil
+		case float64:
+			return n, nil
+		}
+	case "String":
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+	case "ID":
+		switch n := v.(type) {
+		case string:
+			return n, nil
+		case int64:
+			return strconv.FormatInt(n, 10), nil
+		case float64:
+			if n == math.Trunc(n) {
+				return strconv.FormatInt(int64(n), 10), nil
+			}
+		}
+	case "Boolean":
+		if b, ok := v.(bool); ok {
+			return b, nil
+		}
+	default:
+		return nil, fmt.Errorf("%s is not an input type", t.name)
+	}
+	return nil, fmt.Errorf("cannot use %v as %s", v, t.name)
+}
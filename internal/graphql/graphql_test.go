@@ -0,0 +1,304 @@
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/ChaseHampton/cargoworker/internal/db"
+	"github.com/ChaseHampton/cargoworker/internal/ir"
+	"github.com/ChaseHampton/cargoworker/internal/store"
+)
+
+// seedDB writes a package with an interface Reader and n structs, each with
+// a Read method and an implements relation to Reader.
+func seedDB(t *testing.T, ctx context.Context, n int) *sql.DB {
+	t.Helper()
+	rdb, err := db.Open(ctx, filepath.Join(t.TempDir(), db.FileName))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { rdb.Close() })
+	st := store.NewSQLite(rdb)
+	if err := st.Migrate(ctx); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if err := st.WriteProject(ctx, &ir.Project{Id: uuid.New(), Name: "p", RootUri: "/src"}); err != nil {
+		t.Fatalf("project: %v", err)
+	}
+	c, f, iface := uuid.New(), uuid.New(), uuid.New()
+	frag := &ir.Fragment{
+		Containers: []ir.Container{{Id: c, Name: "p", FullName: "example.com/p", Kind: "package", Language: "go"}},
+		Files:      []ir.File{{Id: f, ContainerId: c, Path: "p.go", Language: "go", SizeBytes: 42}},
+		Symbols: []ir.Symbol{{Id: iface, ContainerId: c, Name: "Reader", FullName: "example.com/p.Reader",
+			Kind: "interface", OriginFileId: f, StartLine: 1, DocFmt: "Reader reads."}},
+	}
+	for i := range n {
+		s, m := uuid.New(), uuid.New()
+		name := fmt.Sprintf("R%02d", i)
+		frag.Symbols = append(frag.Symbols,
+			ir.Symbol{Id: s, ContainerId: c, Name: name, FullName: "example.com/p." + name, Kind: "struct", OriginFileId: f, StartLine: 10 + 2*i},
+			ir.Symbol{Id: m, ContainerId: c, Name: "Read", FullName: "example.com/p." + name + ".Read", Kind: "method", OriginFileId: f, StartLine: 11 + 2*i})
+		frag.Signatures = append(frag.Signatures, ir.Signature{SymbolId: m, Text: "func (r *" + name + ") Read() error"})
+		frag.Members = append(frag.Members, ir.Member{Id: uuid.New(), OwnerSymbolId: s, ChildSymbolId: m})
+		frag.Relations = append(frag.Relations, ir.Relation{SourceSymbolId: s, Relation: "implements", DstSymbolId: iface})
+		frag.Typerefs = append(frag.Typerefs, ir.Typeref{Id: uuid.New(), OwnerSymbolId: m, Json: `{"name":"Reader"}`})
+	}
+	if err := st.WriteFragment(ctx, frag); err != nil {
+		t.Fatalf("fragment: %v", err)
+	}
+	if _, err := st.Flush(ctx); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	return rdb
+}
+
+func execute(t *testing.T, rdb *sql.DB, query string, vars map[string]any) (map[string]any, *loader) {
+	t.Helper()
+	l := newLoader(rdb)
+	resp := schema.Execute(context.Background(), l, Request{Query: query, Variables: vars})
+	b, err := json.Marshal(resp)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var out map[string]any
+	if err := json.Unmarshal(b, &out); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	return out, l
+}
+
+// path walks decoded JSON by object keys and list indexes.
+func path(v any, keys ...any) any {
+	for _, k := range keys {
+		switch k := k.(type) {
+		case string:
+			v = v.(map[string]any)[k]
+		case int:
+			v = v.([]any)[k]
+		}
+	}
+	return v
+}
+
+const nested = `
+query Impls($kind: String = "struct", $withDoc: Boolean!) {
+  containers {
+    fullName
+    symbols(kind: $kind) {
+      ...sym
+      members { order symbol { name signature { text } typeRefs { target { name doc @include(if: $withDoc) } } } }
+      relations(direction: "out") { kind other { name __typename } }
+    }
+  }
+}
+fragment sym on Symbol { name line container { language } }`
+
+func TestExecute(t *testing.T) {
+	rdb := seedDB(t, context.Background(), 2)
+
+	out, _ := execute(t, rdb, nested, map[string]any{"withDoc": true})
+	if out["errors"] != nil {
+		t.Fatalf("errors: %v", out["errors"])
+	}
+	data := out["data"]
+	if got := path(data, "containers", 0, "fullName"); got != "example.com/p" {
+		t.Fatalf("fullName = %v", got)
+	}
+	syms := path(data, "containers", 0, "symbols").([]any)
+	if len(syms) != 2 || path(syms[1], "name") != "R01" || path(syms[1], "line") != 12.0 ||
+		path(syms[0], "container", "language") != "go" {
+		t.Fatalf("symbols = %v", syms)
+	}
+	read := path(syms[0], "members", 0, "symbol")
+	if path(read, "signature", "text") != "func (r *R00) Read() error" ||
+		path(read, "typeRefs", 0, "target", "doc") != "Reader reads." {
+		t.Fatalf("member = %v", read)
+	}
+	if rel := path(syms[0], "relations", 0); path(rel, "kind") != "implements" ||
+		path(rel, "other", "name") != "Reader" || path(rel, "other", "__typename") != "Symbol" {
+		t.Fatalf("relation = %v", rel)
+	}
+
+	// Keys follow the selection order, aliases included.
+	resp := schema.Execute(context.Background(), newLoader(rdb), Request{
+		Query: `{ b: symbol(fullName: "example.com/p.Reader") { kind, name } a: symbol(id: "0") { name } }`,
+	})
+	b, _ := json.Marshal(resp)
+	if want := `{"data":{"b":{"kind":"interface","name":"Reader"},"a":null}}`; string(b) != want {
+		t.Fatalf("response = %s, want %s", b, want)
+	}
+
+	out, _ = execute(t, rdb, `{ symbol(fullName: "example.com/p.Reader") {
+		relations(direction: "in") { direction from { name } }
+		skipped: name @skip(if: true)
+	} }`, nil)
+	rels := path(out, "data", "symbol", "relations").([]any)
+	if len(rels) != 2 || path(rels[0], "direction") != "in" || path(rels[0], "from", "name") != "R00" {
+		t.Fatalf("incoming = %v", out)
+	}
+	if _, ok := path(out, "data", "symbol").(map[string]any)["skipped"]; ok {
+		t.Fatalf("@skip field present: %v", out)
+	}
+}
+
+// TestBatching checks the loaders keep the number of statements independent
+// of the number of objects: one per field and level.
+func TestBatching(t *testing.T) {
+	counts := map[int]int{}
+	for _, n := range []int{2, 40} {
+		rdb := seedDB(t, context.Background(), n)
+		out, l := execute(t, rdb, nested, map[string]any{"withDoc": false})
+		if out["errors"] != nil {
+			t.Fatalf("n=%d: errors: %v", n, out["errors"])
+		}
+		if got := len(path(out, "data", "containers", 0, "symbols").([]any)); got != n {
+			t.Fatalf("n=%d: %d symbols", n, got)
+		}
+		counts[n] = l.queries
+	}
+	if counts[2] != counts[40] {
+		t.Fatalf("statements grew with the result: %v", counts)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	rdb := seedDB(t, context.Background(), 1)
+	for _, tc := range []struct{ query, want string }{
+		{`{ containers { nope } }`, `cannot query field "nope" on type Container`},
+		{`{ containers }`, "needs a selection"},
+		{`{ containers { name { x } } }`, "has no subfields"},
+		{`{ file { path } }`, `requires argument "id"`},
+		{`{ symbol(id: "1", bogus: 1) { name } }`, `unknown argument "bogus"`},
+		{`{ ...f } fragment f on Query { ...f }`, "spreads itself"},
+		{`{ containers { ...s } } fragment s on Symbol { name }`, "cannot be spread on type Container"},
+		{`{ containers { name @defer } }`, "unknown directive"},
+		{`mutation { x }`, "not supported"},
+		{`{ containers(limit: "x") { name } }`, `argument "limit"`},
+		{`query($n: Int!) { containers(limit: $n) { name } }`, "$n of type Int! is required"},
+		{`{ symbol { name } }`, "id or fullName is required"},
+		{`{ symbol(fullName: "example.com/p.R00") { relations(direction: "up") { kind } } }`, "direction must be"},
+		{`{ containers { name`, "unterminated selection set"},
+		{"{ containers { " + strings.Repeat("parent { ", maxDepth) + "name" + strings.Repeat(" }", maxDepth+2), "nested deeper"},
+	} {
+		out, _ := execute(t, rdb, tc.query, nil)
+		errs, _ := out["errors"].([]any)
+		if len(errs) == 0 || !strings.Contains(path(errs[0], "message").(string), tc.want) {
+			t.Errorf("%s: errors = %v, want %q", tc.query, out["errors"], tc.want)
+		}
+	}
+
+	// A field error leaves the rest of the data in place and names its path.
+	out, _ := execute(t, rdb, `{ a: containers { name } b: search(q: "x", mode: "fuzzy") { name } }`, nil)
+	if path(out, "data", "a", 0, "name") != "p" || path(out, "errors", 0, "path", 0) != "b" {
+		t.Fatalf("partial result = %v", out)
+	}
+}
+
+func TestIntrospection(t *testing.T) {
+	rdb := seedDB(t, context.Background(), 0)
+	out, _ := execute(t, rdb, `{ __schema { queryType { name } types { name kind } directives { name args { name } } } }`, nil)
+	if out["errors"] != nil {
+		t.Fatalf("errors: %v", out["errors"])
+	}
+	if got := path(out, "data", "__schema", "queryType", "name"); got != "Query" {
+		t.Fatalf("queryType = %v", got)
+	}
+	kinds := map[string]string{}
+	for _, typ := range path(out, "data", "__schema", "types").([]any) {
+		kinds[path(typ, "name").(string)] = path(typ, "kind").(string)
+	}
+	for name, kind := range map[string]string{"Symbol": "OBJECT", "String": "SCALAR", "__Type": "OBJECT"} {
+		if kinds[name] != kind {
+			t.Errorf("type %s kind = %q, want %s", name, kinds[name], kind)
+		}
+	}
+	if path(out, "data", "__schema", "directives", 0, "name") != "skip" ||
+		path(out, "data", "__schema", "directives", 0, "args", 0, "name") != "if" {
+		t.Errorf("directives = %v", path(out, "data", "__schema", "directives"))
+	}
+
+	// Wrapped types nest through ofType; defaults are GraphQL literals.
+	out, _ = execute(t, rdb, `{
+		symbol: __type(name: "Symbol") { kind fields { name type { kind ofType { name } } } }
+		query: __type(name: "Query") { fields { name args { name defaultValue } } }
+		none: __type(name: "Nope") { name }
+	}`, nil)
+	if out["errors"] != nil {
+		t.Fatalf("errors: %v", out["errors"])
+	}
+	name := path(out, "data", "symbol", "fields", 2)
+	if path(name, "name") != "name" || path(name, "type", "kind") != "NON_NULL" ||
+		path(name, "type", "ofType", "name") != "String" {
+		t.Fatalf("Symbol.name = %v", name)
+	}
+	search := path(out, "data", "query", "fields", 4)
+	if path(search, "name") != "search" || path(search, "args", 1, "defaultValue") != `"fts"` ||
+		path(search, "args", 0, "defaultValue") != nil {
+		t.Fatalf("Query.search = %v", search)
+	}
+	if got, ok := path(out, "data").(map[string]any)["none"]; !ok || got != nil {
+		t.Fatalf("unknown type = %v", got)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	rdb := seedDB(t, context.Background(), 1)
+	srv := httptest.NewServer(NewHandler(rdb, slog.New(slog.NewTextHandler(io.Discard, nil))))
+	defer srv.Close()
+
+	body, _ := json.Marshal(Request{
+		Query:     `query($q: String!) { search(q: $q, mode: "exact") { name fullName } }`,
+		Variables: map[string]any{"q": "Reader"},
+	})
+	resp, err := http.Post(srv.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out map[string]any
+	err = json.NewDecoder(resp.Body).Decode(&out)
+	resp.Body.Close()
+	if err != nil || resp.StatusCode != 200 || path(out, "data", "search", 0, "fullName") != "example.com/p.Reader" {
+		t.Fatalf("POST = %d %v %v", resp.StatusCode, out, err)
+	}
+
+	resp, err = http.Get(srv.URL + "?query=" + "%7B+containers+%7B+name+%7D+%7D")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = json.NewDecoder(resp.Body).Decode(&out)
+	resp.Body.Close()
+	if err != nil || path(out, "data", "containers", 0, "name") != "p" {
+		t.Fatalf("GET = %v %v", out, err)
+	}
+
+	resp, err = http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sdl, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(sdl), "type Query {") {
+		t.Fatalf("GET without query = %q", sdl)
+	}
+
+	resp, err = http.Post(srv.URL, "application/json", strings.NewReader("{"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("bad body: %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,92 @@
+// Package graphql serves a GraphQL view of a run database: the containers,
+// files and symbols of the IR with their relations, read through batched
+// loaders so that a nested query costs one statement per field and level
+// rather than one per object.
+//
+// The implementation covers what a read-only API needs — queries,
+// fragments, variables, aliases and @skip/@include over object types, with
+// __schema and __type introspection — and nothing else: no mutations,
+// subscriptions or interfaces.
+// GET /graphql without a query returns the schema definition instead.
+package graphql
+
+import (
+	"database/sql"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxBody bounds the size of a POSTed request.
+const maxBody = 1 << 20
+
+type handler struct {
+	db  *sql.DB
+	log *slog.Logger
+}
+
+// NewHandler returns the /graphql endpoint over rdb. It accepts POST with a
+// JSON body {"query", "operationName", "variables"} and GET with the same
+// as URL parameters.
+func NewHandler(rdb *sql.DB, lg *slog.Logger) http.Handler {
+	return &handler{db: rdb, log: lg}
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req Request
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		q := r.URL.Query()
+		if q.Get("query") == "" {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			_, _ = io.WriteString(w, schema.SDL())
+			return
+		}
+		req.Query, req.OperationName = q.Get("query"), q.Get("operationName")
+		if v := q.Get("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+				h.fail(w, http.StatusBadRequest, "variables: "+err.Error())
+				return
+			}
+		}
+	case http.MethodPost:
+		if ct := r.Header.Get("Content-Type"); ct != "" && !strings.HasPrefix(ct, "application/json") {
+			h.fail(w, http.StatusUnsupportedMediaType, "request body must be application/json")
+			return
+		}
+		if err := json.NewDecoder(io.LimitReader(r.Body, maxBody)).Decode(&req); err != nil {
+			h.fail(w, http.StatusBadRequest, "request body: "+err.Error())
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, HEAD, POST")
+		h.fail(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if strings.TrimSpace(req.Query) == "" {
+		h.fail(w, http.StatusBadRequest, "missing query")
+		return
+	}
+
+	start := time.Now()
+	l := newLoader(h.db)
+	resp := schema.Execute(r.Context(), l, req)
+	h.log.Debug("graphql request", "operation", req.OperationName, "errors", len(resp.Errors),
+		"queries", l.queries, "dur_ms", time.Since(start).Milliseconds())
+	h.write(w, http.StatusOK, resp)
+}
+
+func (h *handler) fail(w http.ResponseWriter, status int, msg string) {
+	h.write(w, status, &Response{Errors: []*Error{{Message: msg}}})
+}
+
+func (h *handler) write(w http.ResponseWriter, status int, resp *Response) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(resp)
+}
@@ -0,0 +1,269 @@
+package graphql
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed introspection.graphql
+var introspectionSDL string
+
+// The objects of the introspection types. A __Schema is the *Schema itself.
+type (
+	introType struct {
+		s    *Schema
+		kind string // OBJECT, SCALAR, LIST or NON_NULL
+		name string
+		obj  *objectType // of an OBJECT
+		of   *introType  // of a LIST or NON_NULL
+	}
+	introField struct {
+		s *Schema
+		f *fieldDef
+	}
+	introArg struct {
+		s *Schema
+		a *argDef
+	}
+	introDirective struct {
+		name, desc string
+		locations  []string
+		args       []*argDef
+	}
+	// introEnumValue is never resolved: the schema has no enums.
+	introEnumValue struct{ name, desc string }
+)
+
+// directives are the directives validateDirectives accepts.
+var directives = []introDirective{
+	{"skip", "Skips the selection when if is true.", []string{"FIELD", "FRAGMENT_SPREAD", "INLINE_FRAGMENT"},
+		[]*argDef{{name: "if", desc: "Skipped when true.", typ: &typeRef{name: "Boolean", nonNull: true}}}},
+	{"include", "Includes the selection only when if is true.", []string{"FIELD", "FRAGMENT_SPREAD", "INLINE_FRAGMENT"},
+		[]*argDef{{name: "if", desc: "Included when true.", typ: &typeRef{name: "Boolean", nonNull: true}}}},
+}
+
+var scalarDescriptions = map[string]string{
+	"ID":      "A unique identifier, serialized as a string.",
+	"String":  "UTF-8 text.",
+	"Int":     "A signed integer.",
+	"Float":   "A double-precision floating-point number.",
+	"Boolean": "true or false.",
+}
+
+// introspectionResolvers binds the fields of the introspection types.
+var introspectionResolvers = map[string]resolver{
+	"__Schema.description":      prop(func(*Schema) any { return nil }),
+	"__Schema.types":            prop(func(s *Schema) any { return s.introTypes() }),
+	"__Schema.queryType":        prop(func(s *Schema) any { return s.named(s.query.name) }),
+	"__Schema.mutationType":     prop(func(*Schema) any { return nil }),
+	"__Schema.subscriptionType": prop(func(*Schema) any { return nil }),
+	"__Schema.directives": prop(func(*Schema) any {
+		out := make([]any, len(directives))
+		for i := range directives {
+			out[i] = &directives[i]
+		}
+		return out
+	}),
+
+	"__Type.kind": prop(func(t *introType) any { return t.kind }),
+	"__Type.name": prop(func(t *introType) any { return opt(t.name) }),
+	"__Type.description": prop(func(t *introType) any {
+		if t.obj != nil {
+			return opt(t.obj.desc)
+		}
+		return opt(scalarDescriptions[t.name])
+	}),
+	"__Type.specifiedByURL": prop(func(*introType) any { return nil }),
+	"__Type.fields": prop(func(t *introType) any {
+		if t.obj == nil {
+			return nil
+		}
+		out := make([]any, len(t.obj.fields))
+		for i, f := range t.obj.fields {
+			out[i] = &introField{t.s, f}
+		}
+		return out
+	}),
+	"__Type.interfaces": prop(func(t *introType) any {
+		if t.obj == nil {
+			return nil
+		}
+		return []any{}
+	}),
+	"__Type.possibleTypes": prop(func(*introType) any { return nil }),
+	"__Type.enumValues":    prop(func(*introType) any { return nil }),
+	"__Type.inputFields":   prop(func(*introType) any { return nil }),
+	"__Type.ofType": prop(func(t *introType) any {
+		if t.of == nil {
+			return nil
+		}
+		return t.of
+	}),
+	"__Type.isOneOf": prop(func(*introType) any { return nil }),
+
+	"__Field.name":              prop(func(f *introField) any { return f.f.name }),
+	"__Field.description":       prop(func(f *introField) any { return opt(f.f.desc) }),
+	"__Field.args":              prop(func(f *introField) any { return introArgs(f.s, f.f.args) }),
+	"__Field.type":              prop(func(f *introField) any { return f.s.typeOf(f.f.typ) }),
+	"__Field.isDeprecated":      prop(func(*introField) any { return false }),
+	"__Field.deprecationReason": prop(func(*introField) any { return nil }),
+
+	"__InputValue.name":        prop(func(a *introArg) any { return a.a.name }),
+	"__InputValue.description": prop(func(a *introArg) any { return opt(a.a.desc) }),
+	"__InputValue.type":        prop(func(a *introArg) any { return a.s.typeOf(a.a.typ) }),
+	"__InputValue.defaultValue": prop(func(a *introArg) any {
+		if !a.a.hasDef {
+			return nil
+		}
+		return literal(a.a.def)
+	}),
+	"__InputValue.isDeprecated":      prop(func(*introArg) any { return false }),
+	"__InputValue.deprecationReason": prop(func(*introArg) any { return nil }),
+
+	"__EnumValue.name":              prop(func(v *introEnumValue) any { return v.name }),
+	"__EnumValue.description":       prop(func(v *introEnumValue) any { return opt(v.desc) }),
+	"__EnumValue.isDeprecated":      prop(func(*introEnumValue) any { return false }),
+	"__EnumValue.deprecationReason": prop(func(*introEnumValue) any { return nil }),
+
+	"__Directive.name":         prop(func(d *introDirective) any { return d.name }),
+	"__Directive.description":  prop(func(d *introDirective) any { return opt(d.desc) }),
+	"__Directive.locations":    prop(func(d *introDirective) any { return anyStrings(d.locations) }),
+	"__Directive.args":         prop(func(d *introDirective) any { return introArgs(nil, d.args) }),
+	"__Directive.isRepeatable": prop(func(*introDirective) any { return false }),
+}
+
+// metaFields are the __schema and __type fields of the query type. They are
+// not among its fields, as the specification has it, and resolve against s.
+func metaFields(s *Schema) map[string]*fieldDef {
+	return map[string]*fieldDef{
+		"__schema": {
+			name: "__schema", typ: &typeRef{name: "__Schema", nonNull: true},
+			resolve: func(_ context.Context, _ *loader, parents []any, _ map[string]any) ([]any, error) {
+				out := make([]any, len(parents))
+				for i := range out {
+					out[i] = s
+				}
+				return out, nil
+			},
+		},
+		"__type": {
+			name: "__type", typ: &typeRef{name: "__Type"},
+			args: []*argDef{{name: "name", typ: &typeRef{name: "String", nonNull: true}}},
+			resolve: func(_ context.Context, _ *loader, parents []any, args map[string]any) ([]any, error) {
+				var t any
+				if n := s.named(argString(args, "name")); n != nil {
+					t = n
+				}
+				out := make([]any, len(parents))
+				for i := range out {
+					out[i] = t
+				}
+				return out, nil
+			},
+		},
+	}
+}
+
+// field returns the field of typ named name, including the meta-fields of
+// the query type.
+func (s *Schema) field(typ *objectType, name string) *fieldDef {
+	if typ == s.query {
+		if f := s.meta[name]; f != nil {
+			return f
+		}
+	}
+	return typ.field(name)
+}
+
+// named returns the named type, or nil.
+func (s *Schema) named(name string) *introType {
+	if o := s.types[name]; o != nil {
+		return &introType{s: s, kind: "OBJECT", name: name, obj: o}
+	}
+	if scalarTypes[name] {
+		return &introType{s: s, kind: "SCALAR", name: name}
+	}
+	return nil
+}
+
+// typeOf returns the type of a reference, wrapped in LIST and NON_NULL.
+func (s *Schema) typeOf(t *typeRef) *introType {
+	switch {
+	case t.nonNull:
+		inner := *t
+		inner.nonNull = false
+		return &introType{s: s, kind: "NON_NULL", of: s.typeOf(&inner)}
+	case t.elem != nil:
+		return &introType{s: s, kind: "LIST", of: s.typeOf(t.elem)}
+	}
+	return s.named(t.name)
+}
+
+// introTypes returns every named type, scalars included, by name.
+func (s *Schema) introTypes() []any {
+	var names []string
+	for name := range s.types {
+		names = append(names, name)
+	}
+	for name := range scalarTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	out := make([]any, len(names))
+	for i, name := range names {
+		out[i] = s.named(name)
+	}
+	return out
+}
+
+func introArgs(s *Schema, args []*argDef) []any {
+	out := make([]any, len(args))
+	for i, a := range args {
+		out[i] = &introArg{s, a}
+	}
+	return out
+}
+
+func anyStrings(ss []string) []any {
+	out := make([]any, len(ss))
+	for i, s := range ss {
+		out[i] = s
+	}
+	return out
+}
+
+// literal writes a constant value as GraphQL source.
+func literal(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case string:
+		b, _ := json.Marshal(v)
+		return string(b)
+	case enumValue:
+		return string(v)
+	case []any:
+		parts := make([]string, len(v))
+		for i, x := range v {
+			parts[i] = literal(x)
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	case objectValue:
+		parts := make([]string, len(v))
+		for i, a := range v {
+			parts[i] = a.name + ": " + literal(a.val)
+		}
+		return "{" + strings.Join(parts, ", ") + "}"
+	}
+	return ""
+}
@@ -0,0 +1,587 @@
+package graphql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+)
+
+// chunkSize bounds the ids bound into one IN (...) list, well below SQLite's
+// variable limit.
+const chunkSize = 500
+
+// loader runs the batched lookups of one request and caches the containers,
+// files and symbols it has seen, so an object reached along several paths is
+// read once.
+type loader struct {
+	db      *sql.DB
+	queries int // statements run, for tests
+
+	containers map[int64]*container
+	files      map[int64]*file
+	symbols    map[int64]*symbol
+}
+
+func newLoader(rdb *sql.DB) *loader {
+	return &loader{
+		db:         rdb,
+		containers: map[int64]*container{},
+		files:      map[int64]*file{},
+		symbols:    map[int64]*symbol{},
+	}
+}
+
+type container struct {
+	id, parentID                             int64
+	uid, name, fullName, kind, lang, version string
+	doc                                      string
+}
+
+type file struct {
+	id, containerID           int64
+	uid, path, lang, checksum string
+	size                      int64
+}
+
+type symbol struct {
+	id, containerID, fileID           int64
+	uid, name, fullName, kind, vis    string
+	flags, line, col, endLine, endCol int
+	doc, docRaw                       string
+}
+
+type signature struct{ text, json string }
+
+type member struct {
+	id, ownerID, childID int64
+	name, kind           string
+	ord                  int
+}
+
+// relation is one relation row as reached from self.
+type relation struct {
+	self, fromID, toID int64
+	kind, dir, detail  string
+}
+
+type typeRefRow struct {
+	id, ownerID int64
+	slot, json  string
+	ord         int
+}
+
+type importRow struct {
+	containerID          int64
+	path, alias, details string
+	stdlib               bool
+}
+
+type diagnostic struct {
+	id, fileID                 int64
+	scope, severity, code, msg string
+	line, col                  int
+}
+
+func (l *loader) query(ctx context.Context, q string, args ...any) (*sql.Rows, error) {
+	l.queries++
+	return l.db.QueryContext(ctx, q, args...)
+}
+
+// batch runs q, whose single %s is replaced by the placeholders of a chunk
+// of ids, once per chunk; extra arguments are bound after the ids.
+func (l *loader) batch(ctx context.Context, q string, ids []int64, extra []any, scan func(*sql.Rows) error) error {
+	ids = uniq(ids)
+	for len(ids) > 0 {
+		n := min(len(ids), chunkSize)
+		args := make([]any, 0, n+len(extra))
+		for _, id := range ids[:n] {
+			args = append(args, id)
+		}
+		args = append(args, extra...)
+		rows, err := l.query(ctx, strings.Replace(q, "%s", placeholders(n), 1), args...)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			if err := scan(rows); err != nil {
+				rows.Close()
+				return err
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		ids = ids[n:]
+	}
+	return nil
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
+}
+
+func uniq(ids []int64) []int64 {
+	seen := make(map[int64]bool, len(ids))
+	out := ids[:0:0]
+	for _, id := range ids {
+		if id != 0 && !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+const containerCols = `p.id, COALESCE(p.uid, ''), COALESCE(p.parent_id, 0), p.name, p.import_path,
+	COALESCE(p.kind, ''), COALESCE(p.language, ''), COALESCE(p.version_tag, ''),
+	COALESCE(NULLIF(p.doc_fmt, ''), p.doc, '')`
+
+func (l *loader) scanContainer(rows *sql.Rows) (*container, error) {
+	c := &container{}
+	if err := rows.Scan(&c.id, &c.uid, &c.parentID, &c.name, &c.fullName, &c.kind, &c.lang,
+		&c.version, &c.doc); err != nil {
+		return nil, err
+	}
+	if cached := l.containers[c.id]; cached != nil {
+		return cached, nil
+	}
+	l.containers[c.id] = c
+	return c, nil
+}
+
+const fileCols = `f.id, COALESCE(f.uid, ''), COALESCE(f.package_id, 0), f.rel_path,
+	COALESCE(f.language, ''), COALESCE(f.digest, ''), f.size_bytes`
+
+func (l *loader) scanFile(rows *sql.Rows) (*file, error) {
+	f := &file{}
+	if err := rows.Scan(&f.id, &f.uid, &f.containerID, &f.path, &f.lang, &f.checksum, &f.size); err != nil {
+		return nil, err
+	}
+	if cached := l.files[f.id]; cached != nil {
+		return cached, nil
+	}
+	l.files[f.id] = f
+	return f, nil
+}
+
+const symbolCols = `s.id, COALESCE(s.uid, ''), s.package_id, COALESCE(s.file_id, 0), s.name,
+	COALESCE(s.full_name, ''), s.kind, COALESCE(s.visibility, ''), s.flags,
+	COALESCE(s.line, 0), COALESCE(s.col, 0), COALESCE(s.end_line, 0), COALESCE(s.end_col, 0),
+	COALESCE(s.doc, ''), COALESCE(s.doc_raw, '')`
+
+func (l *loader) scanSymbol(rows *sql.Rows, extra ...any) (*symbol, error) {
+	s := &symbol{}
+	if err := rows.Scan(append([]any{&s.id, &s.uid, &s.containerID, &s.fileID, &s.name, &s.fullName,
+		&s.kind, &s.vis, &s.flags, &s.line, &s.col, &s.endLine, &s.endCol, &s.doc, &s.docRaw},
+		extra...)...); err != nil {
+		return nil, err
+	}
+	if cached := l.symbols[s.id]; cached != nil {
+		return cached, nil
+	}
+	l.symbols[s.id] = s
+	return s, nil
+}
+
+// missing returns the ids not yet in cache.
+func missing[T any](ids []int64, cache map[int64]*T) []int64 {
+	var out []int64
+	for _, id := range ids {
+		if _, ok := cache[id]; !ok {
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+func (l *loader) containersByID(ctx context.Context, ids []int64) (map[int64]*container, error) {
+	err := l.batch(ctx, `SELECT `+containerCols+` FROM package p WHERE p.id IN (%s);`,
+		missing(ids, l.containers), nil, func(rows *sql.Rows) error {
+			_, err := l.scanContainer(rows)
+			return err
+		})
+	return l.containers, err
+}
+
+func (l *loader) filesByID(ctx context.Context, ids []int64) (map[int64]*file, error) {
+	err := l.batch(ctx, `SELECT `+fileCols+` FROM file f WHERE f.id IN (%s);`,
+		missing(ids, l.files), nil, func(rows *sql.Rows) error {
+			_, err := l.scanFile(rows)
+			return err
+		})
+	return l.files, err
+}
+
+func (l *loader) symbolsByID(ctx context.Context, ids []int64) (map[int64]*symbol, error) {
+	err := l.batch(ctx, `SELECT `+symbolCols+` FROM symbol s WHERE s.id IN (%s);`,
+		missing(ids, l.symbols), nil, func(rows *sql.Rows) error {
+			_, err := l.scanSymbol(rows)
+			return err
+		})
+	return l.symbols, err
+}
+
+// containerByName and symbolByName look up one row by full name, returning
+// nil when there is none.
+func (l *loader) containerByName(ctx context.Context, name string) (*container, error) {
+	rows, err := l.query(ctx, `SELECT `+containerCols+` FROM package p WHERE p.import_path = ? ORDER BY p.id LIMIT 1;`, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+	return l.scanContainer(rows)
+}
+
+func (l *loader) symbolByName(ctx context.Context, name string) (*symbol, error) {
+	rows, err := l.query(ctx, `SELECT `+symbolCols+` FROM symbol s WHERE s.full_name = ? ORDER BY s.id LIMIT 1;`, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+	return l.scanSymbol(rows)
+}
+
+func (l *loader) containersByParent(ctx context.Context, ids []int64) (map[int64][]*container, error) {
+	out := map[int64][]*container{}
+	err := l.batch(ctx, `SELECT `+containerCols+` FROM package p WHERE p.parent_id IN (%s)
+		ORDER BY p.import_path, p.id;`, ids, nil, func(rows *sql.Rows) error {
+		c, err := l.scanContainer(rows)
+		if err == nil {
+			out[c.parentID] = append(out[c.parentID], c)
+		}
+		return err
+	})
+	return out, err
+}
+
+// containersByPath maps import paths to the containers that have them.
+func (l *loader) containersByPath(ctx context.Context, paths []string) (map[string]*container, error) {
+	out := map[string]*container{}
+	seen := map[string]bool{}
+	var todo []any
+	for _, p := range paths {
+		if !seen[p] {
+			seen[p] = true
+			todo = append(todo, p)
+		}
+	}
+	for len(todo) > 0 {
+		n := min(len(todo), chunkSize)
+		rows, err := l.query(ctx, `SELECT `+containerCols+` FROM package p WHERE p.import_path IN (`+placeholders(n)+`)
+			ORDER BY p.id;`, todo[:n]...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			c, err := l.scanContainer(rows)
+			if err != nil {
+				rows.Close()
+				return nil, err
+			}
+			if out[c.fullName] == nil {
+				out[c.fullName] = c
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		todo = todo[n:]
+	}
+	return out, nil
+}
+
+func (l *loader) filesByContainer(ctx context.Context, ids []int64) (map[int64][]*file, error) {
+	out := map[int64][]*file{}
+	err := l.batch(ctx, `SELECT `+fileCols+` FROM file f WHERE f.package_id IN (%s)
+		ORDER BY f.rel_path, f.id;`, ids, nil, func(rows *sql.Rows) error {
+		f, err := l.scanFile(rows)
+		if err == nil {
+			out[f.containerID] = append(out[f.containerID], f)
+		}
+		return err
+	})
+	return out, err
+}
+
+// symbolsByContainer pages the symbols of each container by name; the window
+// applies limit and offset per container within one statement.
+func (l *loader) symbolsByContainer(ctx context.Context, ids []int64, kind string, limit, offset int) (map[int64][]*symbol, error) {
+	cond, extra := "", []any{}
+	if kind != "" {
+		cond, extra = " AND s.kind = ?", append(extra, kind)
+	}
+	extra = append(extra, offset, offset+limit)
+	out := map[int64][]*symbol{}
+	err := l.batch(ctx, `
+		SELECT `+symbolCols+` FROM (
+			SELECT s.*, ROW_NUMBER() OVER (PARTITION BY s.package_id ORDER BY s.name, s.id) AS rn
+			FROM symbol s WHERE s.package_id IN (%s)`+cond+`) s
+		WHERE s.rn > ? AND s.rn <= ?
+		ORDER BY s.package_id, s.rn;`, ids, extra, func(rows *sql.Rows) error {
+		s, err := l.scanSymbol(rows)
+		if err == nil {
+			out[s.containerID] = append(out[s.containerID], s)
+		}
+		return err
+	})
+	return out, err
+}
+
+func (l *loader) symbolsByFile(ctx context.Context, ids []int64) (map[int64][]*symbol, error) {
+	out := map[int64][]*symbol{}
+	err := l.batch(ctx, `SELECT `+symbolCols+` FROM symbol s WHERE s.file_id IN (%s)
+		ORDER BY s.file_id, COALESCE(s.line, 0), COALESCE(s.col, 0), s.id;`, ids, nil, func(rows *sql.Rows) error {
+		s, err := l.scanSymbol(rows)
+		if err == nil {
+			out[s.fileID] = append(out[s.fileID], s)
+		}
+		return err
+	})
+	return out, err
+}
+
+func (l *loader) signatures(ctx context.Context, ids []int64) (map[int64]*signature, error) {
+	out := map[int64]*signature{}
+	err := l.batch(ctx, `SELECT g.symbol_id, g.text, COALESCE(g.json, '') FROM signature g
+		WHERE g.symbol_id IN (%s) ORDER BY g.id;`, ids, nil, func(rows *sql.Rows) error {
+		var (
+			id int64
+			g  signature
+		)
+		if err := rows.Scan(&id, &g.text, &g.json); err != nil {
+			return err
+		}
+		if out[id] == nil {
+			out[id] = &g
+		}
+		return nil
+	})
+	return out, err
+}
+
+const memberCols = `m.id, m.parent_symbol_id, COALESCE(m.child_symbol_id, 0), m.name, m.kind, m.ord`
+
+func scanMember(rows *sql.Rows) (*member, error) {
+	m := &member{}
+	return m, rows.Scan(&m.id, &m.ownerID, &m.childID, &m.name, &m.kind, &m.ord)
+}
+
+func (l *loader) membersByOwner(ctx context.Context, ids []int64) (map[int64][]*member, error) {
+	out := map[int64][]*member{}
+	err := l.batch(ctx, `SELECT `+memberCols+` FROM member m WHERE m.parent_symbol_id IN (%s)
+		ORDER BY m.parent_symbol_id, m.ord, m.name, m.id;`, ids, nil, func(rows *sql.Rows) error {
+		m, err := scanMember(rows)
+		if err == nil {
+			out[m.ownerID] = append(out[m.ownerID], m)
+		}
+		return err
+	})
+	return out, err
+}
+
+// ownerMembers maps a symbol to the member record nesting it, the first if
+// there are several.
+func (l *loader) ownerMembers(ctx context.Context, ids []int64) (map[int64]*member, error) {
+	out := map[int64]*member{}
+	err := l.batch(ctx, `SELECT `+memberCols+` FROM member m WHERE m.child_symbol_id IN (%s)
+		ORDER BY m.id;`, ids, nil, func(rows *sql.Rows) error {
+		m, err := scanMember(rows)
+		if err == nil && out[m.childID] == nil {
+			out[m.childID] = m
+		}
+		return err
+	})
+	return out, err
+}
+
+// relations lists the relations of each symbol in dir ("out", "in" or
+// "both"), outgoing first, then by kind.
+func (l *loader) relations(ctx context.Context, ids []int64, dir, kind string) (map[int64][]*relation, error) {
+	out := map[int64][]*relation{}
+	for _, side := range []struct{ dir, self string }{{"out", "from_symbol_id"}, {"in", "to_symbol_id"}} {
+		if dir != "both" && dir != side.dir {
+			continue
+		}
+		cond, extra := "", []any(nil)
+		if kind != "" {
+			cond, extra = " AND r.kind = ?", []any{kind}
+		}
+		err := l.batch(ctx, `SELECT r.`+side.self+`, r.from_symbol_id, r.to_symbol_id, r.kind, COALESCE(r.detail, '')
+			FROM relation r WHERE r.`+side.self+` IN (%s)`+cond+`
+			ORDER BY r.kind, r.id;`, ids, extra, func(rows *sql.Rows) error {
+			r := &relation{dir: side.dir}
+			if err := rows.Scan(&r.self, &r.fromID, &r.toID, &r.kind, &r.detail); err != nil {
+				return err
+			}
+			out[r.self] = append(out[r.self], r)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func (l *loader) typeRefs(ctx context.Context, ids []int64) (map[int64][]*typeRefRow, error) {
+	out := map[int64][]*typeRefRow{}
+	err := l.batch(ctx, `SELECT t.id, t.symbol_id, COALESCE(t.slot, ''), COALESCE(t.json, ''), t.ord
+		FROM type_ref t WHERE t.symbol_id IN (%s) ORDER BY t.symbol_id, t.ord, t.id;`, ids, nil,
+		func(rows *sql.Rows) error {
+			t := &typeRefRow{}
+			if err := rows.Scan(&t.id, &t.ownerID, &t.slot, &t.json, &t.ord); err != nil {
+				return err
+			}
+			out[t.ownerID] = append(out[t.ownerID], t)
+			return nil
+		})
+	return out, err
+}
+
+// typeRefTargets resolves type references to symbols of the run: by the
+// qualified name in their JSON first, by their name within the owner's
+// container second (as the documentation emitters do).
+func (l *loader) typeRefTargets(ctx context.Context, refs []*typeRefRow) (map[int64]*symbol, error) {
+	owners := make([]int64, len(refs))
+	for i, t := range refs {
+		owners[i] = t.ownerID
+	}
+	syms, err := l.symbolsByID(ctx, owners)
+	if err != nil {
+		return nil, err
+	}
+	var containerIDs []int64
+	for _, s := range syms {
+		containerIDs = append(containerIDs, s.containerID)
+	}
+	cs, err := l.containersByID(ctx, containerIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	type want struct{ qualified, local string }
+	wants := make([]want, len(refs))
+	var names []any
+	for i, t := range refs {
+		var ref struct {
+			Name   string `json:"name"`
+			Symbol string `json:"symbol"`
+		}
+		if json.Unmarshal([]byte(t.json), &ref) != nil {
+			continue
+		}
+		wants[i].qualified = ref.Symbol
+		if owner := syms[t.ownerID]; owner != nil && ref.Name != "" && cs[owner.containerID] != nil {
+			wants[i].local = cs[owner.containerID].fullName + "." + ref.Name
+		}
+		for _, n := range []string{wants[i].qualified, wants[i].local} {
+			if n != "" {
+				names = append(names, n)
+			}
+		}
+	}
+
+	// A symbol is known by its full name and by container + "." + name.
+	byName := map[string]*symbol{}
+	for len(names) > 0 {
+		n := min(len(names), chunkSize)
+		args := append(append([]any(nil), names[:n]...), names[:n]...)
+		rows, err := l.query(ctx, `SELECT `+symbolCols+`, p.import_path FROM symbol s JOIN package p ON p.id = s.package_id
+			WHERE s.full_name IN (`+placeholders(n)+`) OR p.import_path || '.' || s.name IN (`+placeholders(n)+`)
+			ORDER BY s.id;`, args...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var path string
+			s, err := l.scanSymbol(rows, &path)
+			if err != nil {
+				rows.Close()
+				return nil, err
+			}
+			for _, k := range []string{s.fullName, path + "." + s.name} {
+				if k != "" && byName[k] == nil {
+					byName[k] = s
+				}
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		names = names[n:]
+	}
+
+	out := map[int64]*symbol{}
+	for i, t := range refs {
+		for _, n := range []string{wants[i].qualified, wants[i].local} {
+			if s := byName[n]; n != "" && s != nil && s.id != t.ownerID {
+				out[t.id] = s
+				break
+			}
+		}
+	}
+	return out, nil
+}
+
+func (l *loader) imports(ctx context.Context, ids []int64) (map[int64][]*importRow, error) {
+	out := map[int64][]*importRow{}
+	err := l.batch(ctx, `SELECT i.package_id, i.path, COALESCE(i.alias, ''), i.is_stdlib, COALESCE(i.details_json, '')
+		FROM pkg_import i WHERE i.package_id IN (%s) ORDER BY i.package_id, i.path, i.id;`, ids, nil,
+		func(rows *sql.Rows) error {
+			im := &importRow{}
+			if err := rows.Scan(&im.containerID, &im.path, &im.alias, &im.stdlib, &im.details); err != nil {
+				return err
+			}
+			out[im.containerID] = append(out[im.containerID], im)
+			return nil
+		})
+	return out, err
+}
+
+const diagnosticCols = `d.id, COALESCE(d.file_id, 0), d.scope, d.severity, COALESCE(d.code, ''), d.message,
+	COALESCE(d.line, 0), COALESCE(d.col, 0)`
+
+func scanDiagnostic(rows *sql.Rows, extra ...any) (*diagnostic, error) {
+	d := &diagnostic{}
+	return d, rows.Scan(append([]any{&d.id, &d.fileID, &d.scope, &d.severity, &d.code, &d.msg, &d.line, &d.col}, extra...)...)
+}
+
+func (l *loader) diagnosticsByFile(ctx context.Context, ids []int64) (map[int64][]*diagnostic, error) {
+	out := map[int64][]*diagnostic{}
+	err := l.batch(ctx, `SELECT `+diagnosticCols+` FROM diagnostic d WHERE d.file_id IN (%s)
+		ORDER BY d.file_id, COALESCE(d.line, 0), COALESCE(d.col, 0), d.id;`, ids, nil, func(rows *sql.Rows) error {
+		d, err := scanDiagnostic(rows)
+		if err == nil {
+			out[d.fileID] = append(out[d.fileID], d)
+		}
+		return err
+	})
+	return out, err
+}
+
+// diagnosticsByContainer gathers the diagnostics of each container's files.
+func (l *loader) diagnosticsByContainer(ctx context.Context, ids []int64) (map[int64][]*diagnostic, error) {
+	out := map[int64][]*diagnostic{}
+	err := l.batch(ctx, `SELECT `+diagnosticCols+`, f.package_id FROM diagnostic d JOIN file f ON f.id = d.file_id
+		WHERE f.package_id IN (%s)
+		ORDER BY f.package_id, f.rel_path, COALESCE(d.line, 0), COALESCE(d.col, 0), d.id;`, ids, nil,
+		func(rows *sql.Rows) error {
+			var cid int64
+			d, err := scanDiagnostic(rows, &cid)
+			if err == nil {
+				out[cid] = append(out[cid], d)
+			}
+			return err
+		})
+	return out, err
+}
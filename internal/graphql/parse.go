@@ -0,0 +1,878 @@
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// This file parses the subset of GraphQL the endpoint speaks: executable
+// documents (query operations with variables, aliases, arguments, fragments,
+// inline fragments and directives) and the object types of the schema
+// definition language used for schema.graphql.
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokPunct
+	tokName
+	tokInt
+	tokFloat
+	tokString
+)
+
+type token struct {
+	kind tokenKind
+	text string // punctuator, name, number literal or decoded string
+	line int
+	col  int
+}
+
+type lexer struct {
+	src       string
+	pos       int
+	line, col int
+	tok       token
+}
+
+func newLexer(src string) (*lexer, error) {
+	l := &lexer{src: src, line: 1, col: 1}
+	return l, l.next()
+}
+
+type syntaxError struct {
+	line, col int
+	msg       string
+}
+
+func (e *syntaxError) Error() string {
+	return fmt.Sprintf("syntax error at %d:%d: %s", e.line, e.col, e.msg)
+}
+
+func (l *lexer) errorf(format string, args ...any) error {
+	return &syntaxError{l.tok.line, l.tok.col, fmt.Sprintf(format, args...)}
+}
+
+func (l *lexer) advance(n int) {
+	for _, r := range l.src[l.pos : l.pos+n] {
+		if r == '\n' {
+			l.line, l.col = l.line+1, 1
+		} else {
+			l.col++
+		}
+	}
+	l.pos += n
+}
+
+// next reads the following token into l.tok. Whitespace, commas and comments
+// are insignificant.
+func (l *lexer) next() error {
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',' {
+			l.advance(1)
+			continue
+		}
+		if c == '#' {
+			end := strings.IndexByte(l.src[l.pos:], '\n')
+			if end < 0 {
+				end = len(l.src) - l.pos
+			}
+			l.advance(end)
+			continue
+		}
+		break
+	}
+	l.tok = token{line: l.line, col: l.col}
+	if l.pos >= len(l.src) {
+		l.tok.kind = tokEOF
+		return nil
+	}
+
+	rest := l.src[l.pos:]
+	c := rest[0]
+	switch {
+	case strings.HasPrefix(rest, "..."):
+		l.tok.kind, l.tok.text = tokPunct, "..."
+		l.advance(3)
+	case strings.IndexByte("!$():=@[]{}|&", c) >= 0:
+		l.tok.kind, l.tok.text = tokPunct, string(c)
+		l.advance(1)
+	case c == '_' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z':
+		n := 1
+		for n < len(rest) && isNameChar(rest[n]) {
+			n++
+		}
+		l.tok.kind, l.tok.text = tokName, rest[:n]
+		l.advance(n)
+	case c == '-' || c >= '0' && c <= '9':
+		return l.number(rest)
+	case strings.HasPrefix(rest, `"""`):
+		return l.blockString(rest)
+	case c == '"':
+		return l.string(rest)
+	default:
+		r, _ := utf8.DecodeRuneInString(rest)
+		return l.errorf("unexpected character %q", r)
+	}
+	return nil
+}
+
+func isNameChar(c byte) bool {
+	return c == '_' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9'
+}
+
+func (l *lexer) number(rest string) error {
+	n, float := 0, false
+	if rest[0] == '-' {
+		n++
+	}
+	digits := func() int {
+		start := n
+		for n < len(rest) && rest[n] >= '0' && rest[n] <= '9' {
+			n++
+		}
+		return n - start
+	}
+	if digits() == 0 {
+		return l.errorf("invalid number")
+	}
+	if n < len(rest) && rest[n] == '.' {
+		n++
+		float = true
+		if digits() == 0 {
+			return l.errorf("invalid number")
+		}
+	}
+	if n < len(rest) && (rest[n] == 'e' || rest[n] == 'E') {
+		n++
+		float = true
+		if n < len(rest) && (rest[n] == '+' || rest[n] == '-') {
+			n++
+		}
+		if digits() == 0 {
+			return l.errorf("invalid number")
+		}
+	}
+	if n < len(rest) && (isNameChar(rest[n]) || rest[n] == '.') {
+		return l.errorf("invalid number")
+	}
+	l.tok.kind, l.tok.text = tokInt, rest[:n]
+	if float {
+		l.tok.kind = tokFloat
+	}
+	l.advance(n)
+	return nil
+}
+
+func (l *lexer) string(rest string) error {
+	var b strings.Builder
+	for i := 1; i < len(rest); {
+		c := rest[i]
+		switch {
+		case c == '"':
+			l.tok.kind, l.tok.text = tokString, b.String()
+			l.advance(i + 1)
+			return nil
+		case c == '\n' || c == '\r':
+			return l.errorf("unterminated string")
+		case c == '\\':
+			if i+1 >= len(rest) {
+				return l.errorf("unterminated string")
+			}
+			switch e := rest[i+1]; e {
+			case '"', '\\', '/':
+				b.WriteByte(e)
+			case 'b':
+				b.WriteByte('\b')
+			case 'f':
+				b.WriteByte('\f')
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case 'u':
+				if i+6 > len(rest) {
+					return l.errorf("invalid unicode escape")
+				}
+				r, err := strconv.ParseUint(rest[i+2:i+6], 16, 32)
+				if err != nil {
+					return l.errorf("invalid unicode escape")
+				}
+				b.WriteRune(rune(r))
+				i += 4
+			default:
+				return l.errorf("invalid escape \\%c", e)
+			}
+			i += 2
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return l.errorf("unterminated string")
+}
+
+// blockString reads a """ string, removing the common indentation and
+// leading and trailing blank lines.
+func (l *lexer) blockString(rest string) error {
+	end := strings.Index(rest[3:], `"""`)
+	for end >= 0 && strings.HasSuffix(rest[3:3+end], `\`) {
+		next := strings.Index(rest[3+end+3:], `"""`)
+		if next < 0 {
+			end = -1
+			break
+		}
+		end += 3 + next
+	}
+	if end < 0 {
+		return l.errorf("unterminated block string")
+	}
+	raw := strings.ReplaceAll(rest[3:3+end], `\"""`, `"""`)
+	lines := strings.Split(strings.ReplaceAll(raw, "\r\n", "\n"), "\n")
+	indent := -1
+	for _, ln := range lines[1:] {
+		trimmed := strings.TrimLeft(ln, " \t")
+		if trimmed == "" {
+			continue
+		}
+		if n := len(ln) - len(trimmed); indent < 0 || n < indent {
+			indent = n
+		}
+	}
+	for i := 1; i < len(lines) && indent > 0; i++ {
+		if len(lines[i]) >= indent {
+			lines[i] = lines[i][indent:]
+		} else {
+			lines[i] = ""
+		}
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	l.tok.kind, l.tok.text = tokString, strings.Join(lines, "\n")
+	l.advance(3 + end + 3)
+	return nil
+}
+
+type parser struct {
+	*lexer
+}
+
+func (p *parser) peek(text string) bool {
+	return p.tok.kind == tokPunct && p.tok.text == text
+}
+
+func (p *parser) skip(text string) (bool, error) {
+	if !p.peek(text) {
+		return false, nil
+	}
+	return true, p.next()
+}
+
+func (p *parser) expect(text string) error {
+	if !p.peek(text) {
+		return p.errorf("expected %q, found %s", text, p.describe())
+	}
+	return p.next()
+}
+
+func (p *parser) name() (string, error) {
+	if p.tok.kind != tokName {
+		return "", p.errorf("expected name, found %s", p.describe())
+	}
+	s := p.tok.text
+	return s, p.next()
+}
+
+func (p *parser) keyword(kw string) (bool, error) {
+	if p.tok.kind != tokName || p.tok.text != kw {
+		return false, nil
+	}
+	return true, p.next()
+}
+
+func (p *parser) describe() string {
+	switch p.tok.kind {
+	case tokEOF:
+		return "end of input"
+	case tokString:
+		return "string"
+	default:
+		return strconv.Quote(p.tok.text)
+	}
+}
+
+// Executable documents.
+
+type document struct {
+	operations []*operation
+	fragments  map[string]*fragmentDef
+}
+
+type operation struct {
+	name string
+	vars []*varDef
+	dirs []*directive
+	sel  []selection
+}
+
+type varDef struct {
+	name   string
+	typ    *typeRef
+	def    any
+	hasDef bool
+}
+
+type selection interface{ isSelection() }
+
+type field struct {
+	alias, name string
+	args        []*argument
+	dirs        []*directive
+	sel         []selection
+	line, col   int
+}
+
+type fragmentSpread struct {
+	name string
+	dirs []*directive
+}
+
+type inlineFragment struct {
+	on   string
+	dirs []*directive
+	sel  []selection
+}
+
+func (*field) isSelection()          {}
+func (*fragmentSpread) isSelection() {}
+func (*inlineFragment) isSelection() {}
+
+func (f *field) key() string {
+	if f.alias != "" {
+		return f.alias
+	}
+	return f.name
+}
+
+type fragmentDef struct {
+	name, on string
+	sel      []selection
+}
+
+type argument struct {
+	name string
+	val  any
+}
+
+type directive struct {
+	name string
+	args []*argument
+}
+
+// Literal values are nil, bool, int64, float64, string, enumValue, []any,
+// objectValue or, in executable documents, variable.
+type (
+	enumValue   string
+	variable    string
+	objectValue []*argument
+)
+
+type typeRef struct {
+	name    string   // named type, or "" for a list
+	elem    *typeRef // list element
+	nonNull bool
+}
+
+func (t *typeRef) String() string {
+	s := t.name
+	if t.elem != nil {
+		s = "[" + t.elem.String() + "]"
+	}
+	if t.nonNull {
+		s += "!"
+	}
+	return s
+}
+
+func parseDocument(src string) (*document, error) {
+	lx, err := newLexer(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{lx}
+	doc := &document{fragments: map[string]*fragmentDef{}}
+	for p.tok.kind != tokEOF {
+		switch {
+		case p.peek("{"):
+			sel, err := p.selectionSet()
+			if err != nil {
+				return nil, err
+			}
+			doc.operations = append(doc.operations, &operation{sel: sel})
+		case p.tok.kind == tokName && p.tok.text == "query":
+			op, err := p.operation()
+			if err != nil {
+				return nil, err
+			}
+			doc.operations = append(doc.operations, op)
+		case p.tok.kind == tokName && p.tok.text == "fragment":
+			f, err := p.fragment()
+			if err != nil {
+				return nil, err
+			}
+			if _, dup := doc.fragments[f.name]; dup {
+				return nil, fmt.Errorf("fragment %q defined more than once", f.name)
+			}
+			doc.fragments[f.name] = f
+		case p.tok.kind == tokName && (p.tok.text == "mutation" || p.tok.text == "subscription"):
+			return nil, p.errorf("%s operations are not supported: the API is read-only", p.tok.text)
+		default:
+			return nil, p.errorf("expected an operation or fragment, found %s", p.describe())
+		}
+	}
+	if len(doc.operations) == 0 {
+		return nil, fmt.Errorf("document has no operation")
+	}
+	return doc, nil
+}
+
+func (p *parser) operation() (*operation, error) {
+	if err := p.next(); err != nil { // "query"
+		return nil, err
+	}
+	op := &operation{}
+	if p.tok.kind == tokName {
+		op.name = p.tok.text
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+	if ok, err := p.skip("("); err != nil {
+		return nil, err
+	} else if ok {
+		for !p.peek(")") {
+			if err := p.expect("$"); err != nil {
+				return nil, err
+			}
+			v := &varDef{}
+			var err error
+			if v.name, err = p.name(); err != nil {
+				return nil, err
+			}
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+			if v.typ, err = p.typeRef(); err != nil {
+				return nil, err
+			}
+			if ok, err := p.skip("="); err != nil {
+				return nil, err
+			} else if ok {
+				if v.def, err = p.value(true); err != nil {
+					return nil, err
+				}
+				v.hasDef = true
+			}
+			op.vars = append(op.vars, v)
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+	var err error
+	if op.dirs, err = p.directives(); err != nil {
+		return nil, err
+	}
+	if op.sel, err = p.selectionSet(); err != nil {
+		return nil, err
+	}
+	return op, nil
+}
+
+func (p *parser) fragment() (*fragmentDef, error) {
+	if err := p.next(); err != nil { // "fragment"
+		return nil, err
+	}
+	f := &fragmentDef{}
+	var err error
+	if f.name, err = p.name(); err != nil {
+		return nil, err
+	}
+	if f.name == "on" {
+		return nil, p.errorf("fragment cannot be named \"on\"")
+	}
+	if ok, err := p.keyword("on"); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, p.errorf("expected \"on\", found %s", p.describe())
+	}
+	if f.on, err = p.name(); err != nil {
+		return nil, err
+	}
+	if _, err := p.directives(); err != nil {
+		return nil, err
+	}
+	if f.sel, err = p.selectionSet(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (p *parser) selectionSet() ([]selection, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	var out []selection
+	for !p.peek("}") {
+		if p.tok.kind == tokEOF {
+			return nil, p.errorf("unterminated selection set")
+		}
+		s, err := p.selection()
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	if len(out) == 0 {
+		return nil, p.errorf("empty selection set")
+	}
+	return out, p.next()
+}
+
+func (p *parser) selection() (selection, error) {
+	if ok, err := p.skip("..."); err != nil {
+		return nil, err
+	} else if ok {
+		if p.tok.kind == tokName && p.tok.text != "on" {
+			s := &fragmentSpread{name: p.tok.text}
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			var err error
+			s.dirs, err = p.directives()
+			return s, err
+		}
+		in := &inlineFragment{}
+		if ok, err := p.keyword("on"); err != nil {
+			return nil, err
+		} else if ok {
+			if in.on, err = p.name(); err != nil {
+				return nil, err
+			}
+		}
+		var err error
+		if in.dirs, err = p.directives(); err != nil {
+			return nil, err
+		}
+		in.sel, err = p.selectionSet()
+		return in, err
+	}
+
+	f := &field{line: p.tok.line, col: p.tok.col}
+	var err error
+	if f.name, err = p.name(); err != nil {
+		return nil, err
+	}
+	if ok, err := p.skip(":"); err != nil {
+		return nil, err
+	} else if ok {
+		f.alias = f.name
+		if f.name, err = p.name(); err != nil {
+			return nil, err
+		}
+	}
+	if f.args, err = p.arguments(false); err != nil {
+		return nil, err
+	}
+	if f.dirs, err = p.directives(); err != nil {
+		return nil, err
+	}
+	if p.peek("{") {
+		if f.sel, err = p.selectionSet(); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+func (p *parser) arguments(constant bool) ([]*argument, error) {
+	if ok, err := p.skip("("); err != nil || !ok {
+		return nil, err
+	}
+	var out []*argument
+	for !p.peek(")") {
+		a := &argument{}
+		var err error
+		if a.name, err = p.name(); err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		if a.val, err = p.value(constant); err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	if len(out) == 0 {
+		return nil, p.errorf("empty argument list")
+	}
+	return out, p.next()
+}
+
+func (p *parser) directives() ([]*directive, error) {
+	var out []*directive
+	for p.peek("@") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		d := &directive{}
+		var err error
+		if d.name, err = p.name(); err != nil {
+			return nil, err
+		}
+		if d.args, err = p.arguments(false); err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	return out, nil
+}
+
+func (p *parser) value(constant bool) (any, error) {
+	t := p.tok
+	switch t.kind {
+	case tokInt:
+		n, err := strconv.ParseInt(t.text, 10, 64)
+		if err != nil {
+			return nil, p.errorf("integer out of range")
+		}
+		return n, p.next()
+	case tokFloat:
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, p.errorf("invalid float")
+		}
+		return f, p.next()
+	case tokString:
+		return t.text, p.next()
+	case tokName:
+		var v any
+		switch t.text {
+		case "true":
+			v = true
+		case "false":
+			v = false
+		case "null":
+			v = nil
+		default:
+			v = enumValue(t.text)
+		}
+		return v, p.next()
+	case tokPunct:
+		switch t.text {
+		case "$":
+			if constant {
+				return nil, p.errorf("variables are not allowed here")
+			}
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			name, err := p.name()
+			return variable(name), err
+		case "[":
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			list := []any{}
+			for !p.peek("]") {
+				if p.tok.kind == tokEOF {
+					return nil, p.errorf("unterminated list")
+				}
+				v, err := p.value(constant)
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, v)
+			}
+			return list, p.next()
+		case "{":
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			obj := objectValue{}
+			for !p.peek("}") {
+				name, err := p.name()
+				if err != nil {
+					return nil, err
+				}
+				if err := p.expect(":"); err != nil {
+					return nil, err
+				}
+				v, err := p.value(constant)
+				if err != nil {
+					return nil, err
+				}
+				obj = append(obj, &argument{name, v})
+			}
+			return obj, p.next()
+		}
+	}
+	return nil, p.errorf("expected a value, found %s", p.describe())
+}
+
+func (p *parser) typeRef() (*typeRef, error) {
+	t := &typeRef{}
+	if ok, err := p.skip("["); err != nil {
+		return nil, err
+	} else if ok {
+		if t.elem, err = p.typeRef(); err != nil {
+			return nil, err
+		}
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+	} else if t.name, err = p.name(); err != nil {
+		return nil, err
+	}
+	ok, err := p.skip("!")
+	t.nonNull = ok
+	return t, err
+}
+
+// Schema definition language: object types only, which is all the schema
+// needs.
+
+type objectType struct {
+	name   string
+	desc   string
+	fields []*fieldDef
+	byName map[string]*fieldDef
+}
+
+type fieldDef struct {
+	name    string
+	desc    string
+	args    []*argDef
+	typ     *typeRef
+	resolve resolver
+}
+
+type argDef struct {
+	name   string
+	desc   string
+	typ    *typeRef
+	def    any
+	hasDef bool
+}
+
+func (o *objectType) field(name string) *fieldDef { return o.byName[name] }
+
+func (f *fieldDef) arg(name string) *argDef {
+	for _, a := range f.args {
+		if a.name == name {
+			return a
+		}
+	}
+	return nil
+}
+
+func parseSchema(src string) (map[string]*objectType, error) {
+	lx, err := newLexer(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{lx}
+	types := map[string]*objectType{}
+	for p.tok.kind != tokEOF {
+		desc, err := p.description()
+		if err != nil {
+			return nil, err
+		}
+		if ok, err := p.keyword("type"); err != nil {
+			return nil, err
+		} else if !ok {
+			return nil, p.errorf("expected a type definition, found %s", p.describe())
+		}
+		o := &objectType{desc: desc, byName: map[string]*fieldDef{}}
+		if o.name, err = p.name(); err != nil {
+			return nil, err
+		}
+		if types[o.name] != nil {
+			return nil, p.errorf("type %s defined more than once", o.name)
+		}
+		if err := p.expect("{"); err != nil {
+			return nil, err
+		}
+		for !p.peek("}") {
+			f := &fieldDef{}
+			if f.desc, err = p.description(); err != nil {
+				return nil, err
+			}
+			if f.name, err = p.name(); err != nil {
+				return nil, err
+			}
+			if ok, err := p.skip("("); err != nil {
+				return nil, err
+			} else if ok {
+				for !p.peek(")") {
+					a := &argDef{}
+					if a.desc, err = p.description(); err != nil {
+						return nil, err
+					}
+					if a.name, err = p.name(); err != nil {
+						return nil, err
+					}
+					if err := p.expect(":"); err != nil {
+						return nil, err
+					}
+					if a.typ, err = p.typeRef(); err != nil {
+						return nil, err
+					}
+					if ok, err := p.skip("="); err != nil {
+						return nil, err
+					} else if ok {
+						if a.def, err = p.value(true); err != nil {
+							return nil, err
+						}
+						a.hasDef = true
+					}
+					f.args = append(f.args, a)
+				}
+				if err := p.next(); err != nil {
+					return nil, err
+				}
+			}
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+			if f.typ, err = p.typeRef(); err != nil {
+				return nil, err
+			}
+			o.fields = append(o.fields, f)
+			o.byName[f.name] = f
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		types[o.name] = o
+	}
+	return types, nil
+}
+
+func (p *parser) description() (string, error) {
+	if p.tok.kind != tokString {
+		return "", nil
+	}
+	s := p.tok.text
+	return s, p.next()
+}
@@ -0,0 +1,413 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ChaseHampton/cargoworker/internal/query"
+	"github.com/ChaseHampton/cargoworker/internal/search"
+)
+
+// resolvers binds every schema field, keyed "Type.field".
+var resolvers = map[string]resolver{
+	"Query.containers": queryContainers,
+	"Query.container":  queryContainer,
+	"Query.symbol":     querySymbol,
+	"Query.symbols":    querySymbols,
+	"Query.search":     querySearch,
+	"Query.file":       queryFile,
+
+	"Container.id":          prop(func(c *container) any { return c.id }),
+	"Container.uid":         prop(func(c *container) any { return opt(c.uid) }),
+	"Container.name":        prop(func(c *container) any { return c.name }),
+	"Container.fullName":    prop(func(c *container) any { return c.fullName }),
+	"Container.kind":        prop(func(c *container) any { return opt(c.kind) }),
+	"Container.language":    prop(func(c *container) any { return opt(c.lang) }),
+	"Container.version":     prop(func(c *container) any { return opt(c.version) }),
+	"Container.doc":         prop(func(c *container) any { return opt(c.doc) }),
+	"Container.parent":      ref(func(c *container) int64 { return c.parentID }, (*loader).containersByID),
+	"Container.children":    refList(func(c *container) int64 { return c.id }, (*loader).containersByParent),
+	"Container.files":       refList(func(c *container) int64 { return c.id }, (*loader).filesByContainer),
+	"Container.symbols":     containerSymbols,
+	"Container.imports":     refList(func(c *container) int64 { return c.id }, (*loader).imports),
+	"Container.diagnostics": refList(func(c *container) int64 { return c.id }, (*loader).diagnosticsByContainer),
+
+	"File.id":          prop(func(f *file) any { return f.id }),
+	"File.uid":         prop(func(f *file) any { return opt(f.uid) }),
+	"File.path":        prop(func(f *file) any { return f.path }),
+	"File.language":    prop(func(f *file) any { return opt(f.lang) }),
+	"File.checksum":    prop(func(f *file) any { return opt(f.checksum) }),
+	"File.sizeBytes":   prop(func(f *file) any { return f.size }),
+	"File.container":   ref(func(f *file) int64 { return f.containerID }, (*loader).containersByID),
+	"File.symbols":     refList(func(f *file) int64 { return f.id }, (*loader).symbolsByFile),
+	"File.diagnostics": refList(func(f *file) int64 { return f.id }, (*loader).diagnosticsByFile),
+
+	"Symbol.id":         prop(func(s *symbol) any { return s.id }),
+	"Symbol.uid":        prop(func(s *symbol) any { return opt(s.uid) }),
+	"Symbol.name":       prop(func(s *symbol) any { return s.name }),
+	"Symbol.fullName":   prop(func(s *symbol) any { return opt(s.fullName) }),
+	"Symbol.kind":       prop(func(s *symbol) any { return s.kind }),
+	"Symbol.visibility": prop(func(s *symbol) any { return opt(s.vis) }),
+	"Symbol.flags":      prop(func(s *symbol) any { return s.flags }),
+	"Symbol.line":       prop(func(s *symbol) any { return optInt(s.line) }),
+	"Symbol.col":        prop(func(s *symbol) any { return optInt(s.col) }),
+	"Symbol.endLine":    prop(func(s *symbol) any { return optInt(s.endLine) }),
+	"Symbol.endCol":     prop(func(s *symbol) any { return optInt(s.endCol) }),
+	"Symbol.doc":        prop(func(s *symbol) any { return opt(s.doc) }),
+	"Symbol.docRaw":     prop(func(s *symbol) any { return opt(s.docRaw) }),
+	"Symbol.container":  ref(func(s *symbol) int64 { return s.containerID }, (*loader).containersByID),
+	"Symbol.file":       ref(func(s *symbol) int64 { return s.fileID }, (*loader).filesByID),
+	"Symbol.signature":  ref(func(s *symbol) int64 { return s.id }, (*loader).signatures),
+	"Symbol.owner":      ref(func(s *symbol) int64 { return s.id }, (*loader).ownerMembers),
+	"Symbol.members":    refList(func(s *symbol) int64 { return s.id }, (*loader).membersByOwner),
+	"Symbol.relations":  symbolRelations,
+	"Symbol.typeRefs":   refList(func(s *symbol) int64 { return s.id }, (*loader).typeRefs),
+
+	"Signature.text": prop(func(g *signature) any { return g.text }),
+	"Signature.json": prop(func(g *signature) any { return opt(g.json) }),
+
+	"Member.id":     prop(func(m *member) any { return m.id }),
+	"Member.name":   prop(func(m *member) any { return m.name }),
+	"Member.kind":   prop(func(m *member) any { return m.kind }),
+	"Member.order":  prop(func(m *member) any { return m.ord }),
+	"Member.owner":  ref(func(m *member) int64 { return m.ownerID }, (*loader).symbolsByID),
+	"Member.symbol": ref(func(m *member) int64 { return m.childID }, (*loader).symbolsByID),
+
+	"Relation.kind":      prop(func(r *relation) any { return r.kind }),
+	"Relation.direction": prop(func(r *relation) any { return r.dir }),
+	"Relation.details":   prop(func(r *relation) any { return opt(r.detail) }),
+	"Relation.from":      ref(func(r *relation) int64 { return r.fromID }, (*loader).symbolsByID),
+	"Relation.to":        ref(func(r *relation) int64 { return r.toID }, (*loader).symbolsByID),
+	"Relation.other": ref(func(r *relation) int64 {
+		if r.dir == query.Out {
+			return r.toID
+		}
+		return r.fromID
+	}, (*loader).symbolsByID),
+
+	"TypeRef.id":     prop(func(t *typeRefRow) any { return t.id }),
+	"TypeRef.slot":   prop(func(t *typeRefRow) any { return opt(t.slot) }),
+	"TypeRef.json":   prop(func(t *typeRefRow) any { return opt(t.json) }),
+	"TypeRef.order":  prop(func(t *typeRefRow) any { return t.ord }),
+	"TypeRef.owner":  ref(func(t *typeRefRow) int64 { return t.ownerID }, (*loader).symbolsByID),
+	"TypeRef.target": typeRefTarget,
+
+	"Import.path":      prop(func(im *importRow) any { return im.path }),
+	"Import.alias":     prop(func(im *importRow) any { return opt(im.alias) }),
+	"Import.stdlib":    prop(func(im *importRow) any { return im.stdlib }),
+	"Import.details":   prop(func(im *importRow) any { return opt(im.details) }),
+	"Import.container": ref(func(im *importRow) int64 { return im.containerID }, (*loader).containersByID),
+	"Import.target":    importTarget,
+
+	"Diagnostic.id":       prop(func(d *diagnostic) any { return d.id }),
+	"Diagnostic.scope":    prop(func(d *diagnostic) any { return d.scope }),
+	"Diagnostic.severity": prop(func(d *diagnostic) any { return d.severity }),
+	"Diagnostic.code":     prop(func(d *diagnostic) any { return opt(d.code) }),
+	"Diagnostic.message":  prop(func(d *diagnostic) any { return d.msg }),
+	"Diagnostic.line":     prop(func(d *diagnostic) any { return optInt(d.line) }),
+	"Diagnostic.col":      prop(func(d *diagnostic) any { return optInt(d.col) }),
+	"Diagnostic.file":     ref(func(d *diagnostic) int64 { return d.fileID }, (*loader).filesByID),
+}
+
+// prop resolves a field read off the parent object.
+func prop[T any](fn func(T) any) resolver {
+	return func(_ context.Context, _ *loader, parents []any, _ map[string]any) ([]any, error) {
+		out := make([]any, len(parents))
+		for i, p := range parents {
+			out[i] = fn(p.(T))
+		}
+		return out, nil
+	}
+}
+
+// ref resolves a field naming one object by id, with one load for all
+// parents.
+func ref[T, R any](id func(T) int64, load func(*loader, context.Context, []int64) (map[int64]*R, error)) resolver {
+	return func(ctx context.Context, l *loader, parents []any, _ map[string]any) ([]any, error) {
+		ids := make([]int64, len(parents))
+		for i, p := range parents {
+			ids[i] = id(p.(T))
+		}
+		m, err := load(l, ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+		out := make([]any, len(parents))
+		for i, id := range ids {
+			if r := m[id]; id != 0 && r != nil {
+				out[i] = r
+			}
+		}
+		return out, nil
+	}
+}
+
+// refList resolves a list field keyed by the parent's id.
+func refList[T, R any](id func(T) int64, load func(*loader, context.Context, []int64) (map[int64][]*R, error)) resolver {
+	return func(ctx context.Context, l *loader, parents []any, _ map[string]any) ([]any, error) {
+		ids := make([]int64, len(parents))
+		for i, p := range parents {
+			ids[i] = id(p.(T))
+		}
+		m, err := load(l, ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+		out := make([]any, len(parents))
+		for i, id := range ids {
+			out[i] = anys(m[id])
+		}
+		return out, nil
+	}
+}
+
+func anys[T any](xs []*T) []any {
+	out := make([]any, len(xs))
+	for i, x := range xs {
+		out[i] = x
+	}
+	return out
+}
+
+func opt(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+func optInt(n int) any {
+	if n == 0 {
+		return nil
+	}
+	return n
+}
+
+func argString(args map[string]any, name string) string {
+	s, _ := args[name].(string)
+	return s
+}
+
+func argInt(args map[string]any, name string) int {
+	n, _ := args[name].(int64)
+	return int(n)
+}
+
+// page reads limit and offset, clamped as the JSON API clamps them.
+func page(args map[string]any) (query.Page, error) {
+	p := query.Page{Limit: argInt(args, "limit"), Offset: argInt(args, "offset")}
+	if p.Limit < 0 || p.Offset < 0 {
+		return p, fmt.Errorf("limit and offset must not be negative")
+	}
+	return p.Clamp(), nil
+}
+
+// lookup resolves the id or full name a root field is given, returning 0 when
+// nothing matches.
+func lookup(ctx context.Context, l *loader, table string, args map[string]any, byName func(string) (int64, error)) (int64, error) {
+	id, name := argString(args, "id"), argString(args, "fullName")
+	switch {
+	case id != "" && name != "":
+		return 0, fmt.Errorf("give id or fullName, not both")
+	case id != "":
+		l.queries++
+		n, err := query.ResolveID(ctx, l.db, table, id)
+		if errors.Is(err, query.ErrNotFound) {
+			return 0, nil
+		}
+		return n, err
+	case name != "":
+		return byName(name)
+	}
+	return 0, fmt.Errorf("id or fullName is required")
+}
+
+func queryContainers(ctx context.Context, l *loader, _ []any, args map[string]any) ([]any, error) {
+	p, err := page(args)
+	if err != nil {
+		return nil, err
+	}
+	l.queries++
+	cs, err := query.Containers(ctx, l.db, query.ContainerFilter{
+		Kind: argString(args, "kind"), Language: argString(args, "language"), Prefix: argString(args, "prefix"),
+	}, p)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]any, len(cs))
+	for i, c := range cs {
+		out[i] = l.cacheContainer(&container{
+			id: c.ID, parentID: c.ParentID, uid: c.UID, name: c.Name, fullName: c.FullName,
+			kind: c.Kind, lang: c.Language, version: c.Version, doc: c.Doc,
+		})
+	}
+	return []any{out}, nil
+}
+
+func (l *loader) cacheContainer(c *container) *container {
+	if cached := l.containers[c.id]; cached != nil {
+		return cached
+	}
+	l.containers[c.id] = c
+	return c
+}
+
+func queryContainer(ctx context.Context, l *loader, _ []any, args map[string]any) ([]any, error) {
+	id, err := lookup(ctx, l, "package", args, func(name string) (int64, error) {
+		c, err := l.containerByName(ctx, name)
+		if c == nil {
+			return 0, err
+		}
+		return c.id, err
+	})
+	if err != nil || id == 0 {
+		return []any{nil}, err
+	}
+	return ref(func(struct{}) int64 { return id }, (*loader).containersByID)(ctx, l, []any{struct{}{}}, nil)
+}
+
+func querySymbol(ctx context.Context, l *loader, _ []any, args map[string]any) ([]any, error) {
+	id, err := lookup(ctx, l, "symbol", args, func(name string) (int64, error) {
+		s, err := l.symbolByName(ctx, name)
+		if s == nil {
+			return 0, err
+		}
+		return s.id, err
+	})
+	if err != nil || id == 0 {
+		return []any{nil}, err
+	}
+	return ref(func(struct{}) int64 { return id }, (*loader).symbolsByID)(ctx, l, []any{struct{}{}}, nil)
+}
+
+func querySymbols(ctx context.Context, l *loader, _ []any, args map[string]any) ([]any, error) {
+	refs, _ := args["ids"].([]any)
+	ids := make([]int64, len(refs))
+	for i, r := range refs {
+		l.queries++
+		id, err := query.ResolveID(ctx, l.db, "symbol", r.(string))
+		if err != nil && !errors.Is(err, query.ErrNotFound) {
+			return nil, err
+		}
+		ids[i] = id
+	}
+	syms, err := l.symbolsByID(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]any, len(ids))
+	for i, id := range ids {
+		if s := syms[id]; s != nil {
+			out[i] = s
+		}
+	}
+	return []any{out}, nil
+}
+
+func querySearch(ctx context.Context, l *loader, _ []any, args map[string]any) ([]any, error) {
+	mode, err := search.ParseMode(argString(args, "mode"))
+	if err != nil {
+		return nil, err
+	}
+	p, err := page(args)
+	if err != nil {
+		return nil, err
+	}
+	l.queries++
+	hits, err := search.Search(ctx, l.db, search.Query{
+		Text: argString(args, "q"), Mode: mode, Kind: argString(args, "kind"),
+		Pkg: argString(args, "pkg"), Lang: argString(args, "lang"), Limit: p.Limit, Offset: p.Offset,
+	})
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int64, len(hits))
+	for i, h := range hits {
+		ids[i] = h.SymbolID
+	}
+	syms, err := l.symbolsByID(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	out := []any{}
+	for _, id := range ids {
+		if s := syms[id]; s != nil {
+			out = append(out, s)
+		}
+	}
+	return []any{out}, nil
+}
+
+func queryFile(ctx context.Context, l *loader, _ []any, args map[string]any) ([]any, error) {
+	l.queries++
+	id, err := query.ResolveID(ctx, l.db, "file", argString(args, "id"))
+	if errors.Is(err, query.ErrNotFound) {
+		return []any{nil}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ref(func(struct{}) int64 { return id }, (*loader).filesByID)(ctx, l, []any{struct{}{}}, nil)
+}
+
+func containerSymbols(ctx context.Context, l *loader, parents []any, args map[string]any) ([]any, error) {
+	args["limit"] = int64(max(argInt(args, "limit"), 1))
+	p, err := page(args)
+	if err != nil {
+		return nil, err
+	}
+	return refList(func(c *container) int64 { return c.id },
+		func(l *loader, ctx context.Context, ids []int64) (map[int64][]*symbol, error) {
+			return l.symbolsByContainer(ctx, ids, argString(args, "kind"), p.Limit, p.Offset)
+		})(ctx, l, parents, args)
+}
+
+func symbolRelations(ctx context.Context, l *loader, parents []any, args map[string]any) ([]any, error) {
+	dir := argString(args, "direction")
+	switch dir {
+	case query.Out, query.In, query.Both:
+	default:
+		return nil, fmt.Errorf("direction must be out, in or both")
+	}
+	return refList(func(s *symbol) int64 { return s.id },
+		func(l *loader, ctx context.Context, ids []int64) (map[int64][]*relation, error) {
+			return l.relations(ctx, ids, dir, argString(args, "kind"))
+		})(ctx, l, parents, args)
+}
+
+func typeRefTarget(ctx context.Context, l *loader, parents []any, _ map[string]any) ([]any, error) {
+	refs := make([]*typeRefRow, len(parents))
+	for i, p := range parents {
+		refs[i] = p.(*typeRefRow)
+	}
+	targets, err := l.typeRefTargets(ctx, refs)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]any, len(refs))
+	for i, t := range refs {
+		if s := targets[t.id]; s != nil {
+			out[i] = s
+		}
+	}
+	return out, nil
+}
+
+func importTarget(ctx context.Context, l *loader, parents []any, _ map[string]any) ([]any, error) {
+	paths := make([]string, len(parents))
+	for i, p := range parents {
+		paths[i] = p.(*importRow).path
+	}
+	cs, err := l.containersByPath(ctx, paths)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]any, len(paths))
+	for i, p := range paths {
+		if c := cs[p]; c != nil {
+			out[i] = c
+		}
+	}
+	return out, nil
+}
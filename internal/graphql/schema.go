@@ -0,0 +1,96 @@
+package graphql
+
+import (
+	_ "embed"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+//go:embed schema.graphql
+var schemaSDL string
+
+// Schema is the parsed schema with a resolver bound to every field.
+type Schema struct {
+	types map[string]*objectType
+	query *objectType
+	meta  map[string]*fieldDef // __schema and __type
+	sdl   string
+}
+
+// SDL returns the schema definition the endpoint serves.
+func (s *Schema) SDL() string { return s.sdl }
+
+// newSchema parses sdl and binds resolvers, keyed "Type.field". Every field
+// needs a resolver and every resolver a field, so schema and bindings cannot
+// drift apart unnoticed. The introspection types are added to sdl's.
+func newSchema(sdl string, resolvers map[string]resolver) (*Schema, error) {
+	types, err := parseSchema(sdl)
+	if err != nil {
+		return nil, fmt.Errorf("graphql: schema: %w", err)
+	}
+	s := &Schema{types: types, query: types["Query"], sdl: sdl}
+	if s.query == nil {
+		return nil, fmt.Errorf("graphql: schema has no Query type")
+	}
+	intro, err := parseSchema(introspectionSDL)
+	if err != nil {
+		return nil, fmt.Errorf("graphql: introspection schema: %w", err)
+	}
+	var problems []string
+	for name, t := range intro {
+		if types[name] != nil {
+			problems = append(problems, name+": reserved for introspection")
+			continue
+		}
+		types[name] = t
+	}
+	all := make(map[string]resolver, len(resolvers)+len(introspectionResolvers))
+	for key, r := range introspectionResolvers {
+		all[key] = r
+	}
+	for key, r := range resolvers {
+		all[key] = r
+	}
+	resolvers = all
+	s.meta = metaFields(s)
+	bound := map[string]bool{}
+	for _, t := range types {
+		for _, f := range t.fields {
+			key := t.name + "." + f.name
+			if named := namedType(f.typ); types[named] == nil && !scalarTypes[named] {
+				problems = append(problems, key+": unknown type "+named)
+			}
+			for _, a := range f.args {
+				if !isInputType(a.typ) {
+					problems = append(problems, key+"("+a.name+"): not an input type")
+				}
+			}
+			if f.resolve = resolvers[key]; f.resolve == nil {
+				problems = append(problems, key+": no resolver")
+			}
+			bound[key] = true
+		}
+	}
+	for key := range resolvers {
+		if !bound[key] {
+			problems = append(problems, key+": resolver for no field")
+		}
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return nil, fmt.Errorf("graphql: schema: %s", strings.Join(problems, "; "))
+	}
+	return s, nil
+}
+
+// schema is the schema the endpoint serves. It is built at init so a
+// mistake in the bindings fails every test instead of the first request.
+var schema = mustSchema(newSchema(schemaSDL, resolvers))
+
+func mustSchema(s *Schema, err error) *Schema {
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
@@ -0,0 +1,69 @@
+// Package ident splits source identifiers into the words a person would type
+// when looking for them.
+package ident
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Split breaks name at separators and case boundaries:
+//
+//	ParseHTTPRequest -> Parse HTTP Request
+//	snake_case_name  -> snake case name
+//	utf8Decode       -> utf8 Decode
+//
+// A run of upper-case letters is one acronym word, except that its last letter
+// starts the next word when followed by lower case; a plural "s" (IDs, URLs)
+// stays with the acronym. Digits stay with the word they follow.
+func Split(name string) []string {
+	var (
+		words []string
+		start = -1
+	)
+	rs := []rune(name)
+	flush := func(end int) {
+		if start >= 0 && end > start {
+			words = append(words, string(rs[start:end]))
+		}
+		start = -1
+	}
+	for i, r := range rs {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			flush(i)
+			continue
+		}
+		if start < 0 {
+			start = i
+			continue
+		}
+		prev := rs[i-1]
+		switch {
+		case unicode.IsUpper(r) && (unicode.IsLower(prev) || unicode.IsDigit(prev)):
+			// fooBar, utf8Decode
+			flush(i)
+			start = i
+		case unicode.IsUpper(r) && unicode.IsUpper(prev) && i+1 < len(rs) && unicode.IsLower(rs[i+1]) && !pluralS(rs, i+1):
+			// HTTPRequest: R starts the next word
+			flush(i)
+			start = i
+		}
+	}
+	flush(len(rs))
+	return words
+}
+
+// Terms returns the lower-cased words of name separated by spaces, the form
+// stored in symbol.name_terms for full-text indexing.
+func Terms(name string) string {
+	words := Split(name)
+	for i, w := range words {
+		words[i] = strings.ToLower(w)
+	}
+	return strings.Join(words, " ")
+}
+
+// pluralS reports whether rs[i] is a lone trailing "s" after an acronym.
+func pluralS(rs []rune, i int) bool {
+	return rs[i] == 's' && (i+1 == len(rs) || !unicode.IsLower(rs[i+1]))
+}
@@ -0,0 +1,34 @@
+package ident
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	for in, want := range map[string]string{
+		"ParseHTTPRequest": "Parse HTTP Request",
+		"parseHttpRequest": "parse Http Request",
+		"snake_case_name":  "snake case name",
+		"SCREAMING_CASE":   "SCREAMING CASE",
+		"utf8Decode":       "utf8 Decode",
+		"HTTP2Server":      "HTTP2 Server",
+		"IDs":              "IDs",
+		"URLsFor":          "URLs For",
+		"HTTPServer":       "HTTP Server",
+		"io.Reader":        "io Reader",
+		"__init__":         "init",
+		"x":                "x",
+		"":                 "",
+	} {
+		if got := strings.Join(Split(in), " "); got != want {
+			t.Errorf("Split(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestTerms(t *testing.T) {
+	if got := Terms("ParseHTTPRequest"); got != "parse http request" {
+		t.Fatalf("Terms = %q", got)
+	}
+}
@@ -5,6 +5,7 @@ import "github.com/google/uuid"
 type Container struct {
 	Id         uuid.UUID `json:"id"`
 	ProjectId  uuid.UUID `json:"project_id"`
+	ParentId   uuid.UUID `json:"parent_id"` // zero for top-level containers
 	Language   string    `json:"language"`
 	Name       string    `json:"name"`
 	FullName   string    `json:"full_name"`
@@ -3,9 +3,11 @@ package ir
 import "github.com/google/uuid"
 
 type File struct {
-	Id        uuid.UUID `json:"id"`
-	ProjectId uuid.UUID `json:"project_id"`
-	Path      string    `json:"path"`
-	Checksum  string    `json:"checksum"`
-	Language  string    `json:"language"`
+	Id          uuid.UUID `json:"id"`
+	ProjectId   uuid.UUID `json:"project_id"`
+	ContainerId uuid.UUID `json:"container_id"`
+	Path        string    `json:"path"`
+	Checksum    string    `json:"checksum"`
+	Language    string    `json:"language"`
+	SizeBytes   int64     `json:"size_bytes"`
 }
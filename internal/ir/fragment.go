@@ -0,0 +1,44 @@
+package ir
+
+// Fragment is the unit a language pack hands to the core for persistence.
+// It usually covers a single container; references to records outside the
+// fragment are resolved by the store once the whole run has been written.
+type Fragment struct {
+	Containers  []Container  `json:"containers"`
+	Files       []File       `json:"files"`
+	Symbols     []Symbol     `json:"symbols"`
+	Signatures  []Signature  `json:"signatures"`
+	Typerefs    []Typeref    `json:"typerefs"`
+	Members     []Member     `json:"members"`
+	Relations   []Relation   `json:"relations"`
+	Imports     []Import     `json:"imports"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+// Add appends rec to the slice of its type. It reports false for values that
+// are not fragment records (including ir.Project).
+func (f *Fragment) Add(rec any) bool {
+	switch v := rec.(type) {
+	case Container:
+		f.Containers = append(f.Containers, v)
+	case File:
+		f.Files = append(f.Files, v)
+	case Symbol:
+		f.Symbols = append(f.Symbols, v)
+	case Signature:
+		f.Signatures = append(f.Signatures, v)
+	case Typeref:
+		f.Typerefs = append(f.Typerefs, v)
+	case Member:
+		f.Members = append(f.Members, v)
+	case Relation:
+		f.Relations = append(f.Relations, v)
+	case Import:
+		f.Imports = append(f.Imports, v)
+	case Diagnostic:
+		f.Diagnostics = append(f.Diagnostics, v)
+	default:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,368 @@
+package lsp
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/ChaseHampton/cargoworker/internal/search"
+)
+
+const (
+	// maxSymbols bounds workspace/symbol results.
+	maxSymbols = 100
+	// maxCandidates bounds the symbols a name at the cursor may resolve to.
+	maxCandidates = 50
+)
+
+// sym is a symbol with what the handlers need to locate and describe it.
+type sym struct {
+	id, fileID, containerID    int64
+	name, fullName, kind       string
+	container, lang, path      string
+	line, col, endLine, endCol int
+	doc, signature             string
+}
+
+const symCols = `s.id, COALESCE(s.file_id, 0), s.package_id, s.name, COALESCE(s.full_name, ''), s.kind,
+	p.import_path, COALESCE(p.language, ''), COALESCE(f.rel_path, ''),
+	COALESCE(s.line, 0), COALESCE(s.col, 0), COALESCE(s.end_line, 0), COALESCE(s.end_col, 0),
+	COALESCE(s.doc, ''),
+	COALESCE((SELECT g.text FROM signature g WHERE g.symbol_id = s.id ORDER BY g.id LIMIT 1), '')`
+
+const symJoins = `JOIN package p ON p.id = s.package_id LEFT JOIN file f ON f.id = s.file_id`
+
+func scanSym(sc interface{ Scan(...any) error }, extra ...any) (*sym, error) {
+	x := &sym{}
+	err := sc.Scan(append([]any{&x.id, &x.fileID, &x.containerID, &x.name, &x.fullName, &x.kind,
+		&x.container, &x.lang, &x.path, &x.line, &x.col, &x.endLine, &x.endCol, &x.doc, &x.signature},
+		extra...)...)
+	return x, err
+}
+
+func (s *Server) workspaceSymbol(ctx context.Context, q string) ([]SymbolInformation, error) {
+	out := []SymbolInformation{}
+	if strings.TrimSpace(q) == "" {
+		return out, nil
+	}
+	hits, err := search.Search(ctx, s.db, search.Query{Text: q, Mode: search.ModeFTS, Limit: maxSymbols})
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range hits {
+		if h.File == "" {
+			continue
+		}
+		x, err := scanSym(s.db.QueryRowContext(ctx, `SELECT `+symCols+` FROM symbol s `+symJoins+` WHERE s.id = ?;`, h.SymbolID))
+		if err != nil {
+			return nil, err
+		}
+		loc, ok := s.declaration(x)
+		if !ok {
+			continue
+		}
+		out = append(out, SymbolInformation{Name: x.name, Kind: symbolKind(x.kind), Location: loc, ContainerName: x.container})
+	}
+	return out, nil
+}
+
+func (s *Server) definition(ctx context.Context, uri string, pos Position) ([]Location, error) {
+	cands, _, err := s.resolve(ctx, uri, pos)
+	if err != nil {
+		return nil, err
+	}
+	out := []Location{}
+	for _, c := range cands {
+		if loc, ok := s.declaration(c); ok {
+			out = append(out, loc)
+		}
+	}
+	return out, nil
+}
+
+func (s *Server) hover(ctx context.Context, uri string, pos Position) (*Hover, error) {
+	cands, rng, err := s.resolve(ctx, uri, pos)
+	if err != nil || len(cands) == 0 {
+		return nil, err
+	}
+	c := cands[0]
+	head := c.signature
+	if head == "" {
+		head = c.kind + " " + c.name
+		if c.fullName != "" {
+			head = c.kind + " " + c.fullName
+		}
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "```%s\n%s\n```", c.lang, head)
+	if doc := strings.TrimSpace(c.doc); doc != "" {
+		b.WriteString("\n\n" + doc)
+	}
+	if c.fullName != "" && c.fullName != head {
+		fmt.Fprintf(&b, "\n\n`%s` in `%s`", c.fullName, c.container)
+	}
+	if len(cands) > 1 {
+		fmt.Fprintf(&b, "\n\n(%d more symbols named %s)", len(cands)-1, c.name)
+	}
+	return &Hover{Contents: MarkupContent{Kind: "markdown", Value: b.String()}, Range: rng}, nil
+}
+
+// references lists the sites that use the symbols at pos: type references
+// and "calls"/"references" relations. A site is the location the record
+// gives in its JSON, else the declaration of the symbol that uses it.
+func (s *Server) references(ctx context.Context, uri string, pos Position, includeDecl bool) ([]Location, error) {
+	cands, _, err := s.resolve(ctx, uri, pos)
+	if err != nil {
+		return nil, err
+	}
+	var out []Location
+	seen := map[Location]bool{}
+	add := func(loc Location) {
+		if !seen[loc] {
+			seen[loc] = true
+			out = append(out, loc)
+		}
+	}
+	for _, c := range cands {
+		if includeDecl {
+			if loc, ok := s.declaration(c); ok {
+				add(loc)
+			}
+		}
+		rows, err := s.db.QueryContext(ctx, `
+			SELECT `+symCols+`, COALESCE(r.detail, '')
+			FROM relation r JOIN symbol s ON s.id = r.from_symbol_id `+symJoins+`
+			WHERE r.to_symbol_id = ?1 AND r.kind IN ('calls', 'references')
+			UNION ALL
+			SELECT `+symCols+`, COALESCE(t.json, '')
+			FROM type_ref t JOIN symbol s ON s.id = t.symbol_id `+symJoins+`
+			WHERE s.id <> ?1 AND (json_extract(t.json, '$.symbol') = ?2 OR
+			      (json_extract(t.json, '$.symbol') IS NULL AND json_extract(t.json, '$.name') = ?3 AND s.package_id = ?4));`,
+			c.id, c.fullName, c.name, c.containerID)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var js string
+			user, err := scanSym(rows, &js)
+			if err != nil {
+				rows.Close()
+				return nil, err
+			}
+			if loc, ok := s.site(user, js); ok {
+				add(loc)
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	if out == nil {
+		out = []Location{}
+	}
+	sortLocations(out)
+	return out, nil
+}
+
+// resolve finds the symbols the identifier at pos names: its declaration
+// when pos is on one, else the symbols of that name, preferring the current
+// file, then the current container.
+func (s *Server) resolve(ctx context.Context, uri string, pos Position) ([]*sym, *Range, error) {
+	text, ok := s.line(uri, pos.Line)
+	if !ok {
+		return nil, nil, nil
+	}
+	start, end := wordAt(text, s.byteCol(text, pos.Character))
+	if start == end {
+		return nil, nil, nil
+	}
+	word := text[start:end]
+	rng := &Range{
+		Start: Position{pos.Line, s.charCol(text, start)},
+		End:   Position{pos.Line, s.charCol(text, end)},
+	}
+
+	var fileID, containerID int64
+	rel := s.relPath(uri)
+	err := s.db.QueryRowContext(ctx, `
+		SELECT f.id, COALESCE(f.package_id, 0) FROM file f
+		WHERE f.rel_path = ?1 OR substr(?1, length(?1) - length(f.rel_path)) = '/' || f.rel_path
+		ORDER BY f.rel_path = ?1 DESC, length(f.rel_path) DESC LIMIT 1;`, rel).Scan(&fileID, &containerID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+symCols+`,
+		       CASE WHEN s.file_id = ?2 AND s.line = ?3 THEN 0
+		            WHEN s.file_id = ?2 THEN 1
+		            WHEN s.package_id = ?4 THEN 2
+		            ELSE 3 END AS tier
+		FROM symbol s `+symJoins+`
+		WHERE s.name = ?1
+		ORDER BY tier, p.import_path, f.rel_path, s.line, s.id
+		LIMIT ?5;`, word, fileID, pos.Line+1, containerID, maxCandidates)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	var (
+		out  []*sym
+		best = -1
+	)
+	for rows.Next() {
+		var tier int
+		x, err := scanSym(rows, &tier)
+		if err != nil {
+			return nil, nil, err
+		}
+		if best >= 0 && tier != best {
+			break
+		}
+		best = tier
+		out = append(out, x)
+	}
+	return out, rng, rows.Err()
+}
+
+// declaration locates a symbol's declaration. Symbols without a file or a
+// line have none.
+func (s *Server) declaration(x *sym) (Location, bool) {
+	if x.path == "" || x.line <= 0 {
+		return Location{}, false
+	}
+	uri := s.fileURI(x.path)
+	return Location{URI: uri, Range: s.span(uri, x.line, x.col, x.endLine, x.endCol)}, true
+}
+
+// site locates a reference made by user: from "line", "col", "end_line" and
+// "end_col" in the record's JSON, else user's declaration.
+func (s *Server) site(user *sym, js string) (Location, bool) {
+	var loc struct {
+		Line    int `json:"line"`
+		Col     int `json:"col"`
+		EndLine int `json:"end_line"`
+		EndCol  int `json:"end_col"`
+	}
+	if js != "" && json.Unmarshal([]byte(js), &loc) == nil && loc.Line > 0 && user.path != "" {
+		uri := s.fileURI(user.path)
+		return Location{URI: uri, Range: s.span(uri, loc.Line, loc.Col, loc.EndLine, loc.EndCol)}, true
+	}
+	return s.declaration(user)
+}
+
+// span converts a 1-based, byte-column span from the index to an LSP range.
+// A missing end is the start; a missing column the start of the line.
+func (s *Server) span(uri string, line, col, endLine, endCol int) Range {
+	if endLine <= 0 {
+		endLine = line
+	} else if endLine < line {
+		endLine, endCol = line, col
+	}
+	if endLine == line && endCol < col {
+		endCol = col
+	}
+	conv := func(l, c int) Position {
+		c = max(c-1, 0)
+		if text, ok := s.line(uri, l-1); ok {
+			c = s.charCol(text, min(c, len(text)))
+		}
+		return Position{Line: l - 1, Character: c}
+	}
+	return Range{Start: conv(line, col), End: conv(endLine, endCol)}
+}
+
+// byteCol converts a client character offset on line to a byte offset.
+func (s *Server) byteCol(line string, char int) int {
+	if s.utf8 {
+		return min(max(char, 0), len(line))
+	}
+	units := 0
+	for i, r := range line {
+		if units >= char {
+			return i
+		}
+		units += utf16.RuneLen(r)
+	}
+	return len(line)
+}
+
+// charCol converts a byte offset on line to a client character offset.
+func (s *Server) charCol(line string, b int) int {
+	if s.utf8 {
+		return b
+	}
+	units := 0
+	for i, r := range line {
+		if i >= b {
+			break
+		}
+		units += utf16.RuneLen(r)
+	}
+	return units
+}
+
+// wordAt returns the byte bounds of the identifier at or just before b.
+func wordAt(line string, b int) (int, int) {
+	isWord := func(r rune) bool { return r == '_' || r == '$' || unicode.IsLetter(r) || unicode.IsDigit(r) }
+	start, end := b, b
+	for start > 0 {
+		r, n := utf8.DecodeLastRuneInString(line[:start])
+		if !isWord(r) {
+			break
+		}
+		start -= n
+	}
+	for end < len(line) {
+		r, n := utf8.DecodeRuneInString(line[end:])
+		if !isWord(r) {
+			break
+		}
+		end += n
+	}
+	return start, end
+}
+
+// uriPath is the file system path of a file URI.
+func uriPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	p := u.Path
+	if len(p) >= 3 && p[0] == '/' && p[2] == ':' { // /C:/dir
+		p = p[1:]
+	}
+	return filepath.FromSlash(p)
+}
+
+func pathURI(p string) string {
+	p = filepath.ToSlash(p)
+	if !strings.HasPrefix(p, "/") {
+		p = "/" + p
+	}
+	return (&url.URL{Scheme: "file", Path: p}).String()
+}
+
+// sortLocations orders locations by file and position.
+func sortLocations(locs []Location) {
+	sort.SliceStable(locs, func(i, j int) bool {
+		a, b := locs[i], locs[j]
+		if a.URI != b.URI {
+			return a.URI < b.URI
+		}
+		if a.Range.Start.Line != b.Range.Start.Line {
+			return a.Range.Start.Line < b.Range.Start.Line
+		}
+		return a.Range.Start.Character < b.Range.Start.Character
+	})
+}
@@ -0,0 +1,221 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/ChaseHampton/cargoworker/internal/db"
+	"github.com/ChaseHampton/cargoworker/internal/ir"
+	"github.com/ChaseHampton/cargoworker/internal/store"
+)
+
+const source = `package p
+
+// Reader reads.
+type Reader interface{ Read() error }
+
+type File struct{}
+
+func (f *File) Read() error { return nil }
+
+func Use(r Reader) { r.Read() }
+`
+
+// seed writes p/p.go under a temporary root and a run database indexing it.
+func seed(t *testing.T, ctx context.Context) (*sql.DB, string) {
+	t.Helper()
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "p"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "p", "p.go"), []byte(source), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rdb, err := db.Open(ctx, filepath.Join(t.TempDir(), db.FileName))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { rdb.Close() })
+	st := store.NewSQLite(rdb)
+	if err := st.Migrate(ctx); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if err := st.WriteProject(ctx, &ir.Project{Id: uuid.New(), Name: "p", RootUri: root}); err != nil {
+		t.Fatalf("project: %v", err)
+	}
+	c, f, reader, file, read, use := uuid.New(), uuid.New(), uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	err = st.WriteFragment(ctx, &ir.Fragment{
+		Containers: []ir.Container{{Id: c, Name: "p", FullName: "example.com/p", Kind: "package", Language: "go"}},
+		Files:      []ir.File{{Id: f, ContainerId: c, Path: "p/p.go", Language: "go", SizeBytes: int64(len(source))}},
+		Symbols: []ir.Symbol{
+			{Id: reader, ContainerId: c, Name: "Reader", FullName: "example.com/p.Reader", Kind: "interface", OriginFileId: f,
+				StartLine: 4, StartCol: 6, EndLine: 4, EndCol: 12, DocFmt: "Reader reads."},
+			{Id: file, ContainerId: c, Name: "File", FullName: "example.com/p.File", Kind: "struct", OriginFileId: f, StartLine: 6, StartCol: 6, EndCol: 10},
+			{Id: read, ContainerId: c, Name: "Read", FullName: "example.com/p.File.Read", Kind: "method", OriginFileId: f, StartLine: 8, StartCol: 16, EndCol: 20},
+			{Id: use, ContainerId: c, Name: "Use", FullName: "example.com/p.Use", Kind: "function", OriginFileId: f, StartLine: 10, StartCol: 6, EndCol: 9},
+		},
+		Signatures: []ir.Signature{{SymbolId: read, Text: "func (f *File) Read() error"}},
+		Relations:  []ir.Relation{{SourceSymbolId: use, Relation: "calls", DstSymbolId: read, DetailsJson: `{"line":10,"col":24,"end_col":28}`}},
+		Typerefs:   []ir.Typeref{{Id: uuid.New(), OwnerSymbolId: use, Json: `{"name":"Reader"}`}},
+	})
+	if err != nil {
+		t.Fatalf("fragment: %v", err)
+	}
+	if _, err := st.Flush(ctx); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	return rdb, root
+}
+
+// session runs the server over a scripted client: each request gets id i+1.
+// It returns the responses by id.
+func session(t *testing.T, rdb *sql.DB, reqs ...any) map[int]map[string]any {
+	t.Helper()
+	var in bytes.Buffer
+	for i, r := range reqs {
+		m := r.(map[string]any)
+		m["jsonrpc"] = "2.0"
+		if _, notification := m["notify"]; notification {
+			delete(m, "notify")
+		} else {
+			m["id"] = i + 1
+		}
+		if err := writeMessage(&in, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var out bytes.Buffer
+	if err := New(rdb, slog.New(slog.NewTextHandler(io.Discard, nil))).Serve(context.Background(), &in, &out); err != nil {
+		t.Fatalf("serve: %v", err)
+	}
+	resps := map[int]map[string]any{}
+	br := bufio.NewReader(&out)
+	for {
+		body, err := readMessage(br)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("read response: %v", err)
+		}
+		var m map[string]any
+		if err := json.Unmarshal(body, &m); err != nil {
+			t.Fatal(err)
+		}
+		id, _ := m["id"].(float64)
+		resps[int(id)] = m
+	}
+	return resps
+}
+
+func req(method string, params any) map[string]any {
+	return map[string]any{"method": method, "params": params}
+}
+
+func notify(method string, params any) map[string]any {
+	return map[string]any{"method": method, "params": params, "notify": true}
+}
+
+func at(uri string, line, char int) map[string]any {
+	return map[string]any{"textDocument": map[string]any{"uri": uri}, "position": map[string]any{"line": line, "character": char}}
+}
+
+func TestSession(t *testing.T) {
+	rdb, root := seed(t, context.Background())
+	uri := pathURI(filepath.Join(root, "p", "p.go"))
+	refs := at(uri, 3, 8)
+	refs["context"] = map[string]any{"includeDeclaration": true}
+
+	resps := session(t, rdb,
+		req("initialize", map[string]any{"rootUri": pathURI(root)}),
+		notify("initialized", map[string]any{}),
+		req("workspace/symbol", map[string]any{"query": "Reader"}),
+		req("textDocument/definition", at(uri, 9, 14)), // Use(r Reader)
+		req("textDocument/hover", at(uri, 9, 25)),      // r.Read()
+		req("textDocument/references", refs),           // type Reader
+		req("textDocument/references", at(uri, 7, 17)), // func (f *File) Read
+		req("textDocument/formatting", map[string]any{}),
+		req("shutdown", nil),
+		notify("exit", nil),
+	)
+	result := func(id int) string {
+		t.Helper()
+		r, ok := resps[id]
+		if !ok {
+			t.Fatalf("no response %d", id)
+		}
+		b, _ := json.Marshal(r["result"])
+		return string(b)
+	}
+	// norm re-encodes JSON the way result does, with sorted keys.
+	norm := func(js string) string {
+		t.Helper()
+		var v any
+		if err := json.Unmarshal([]byte(js), &v); err != nil {
+			t.Fatalf("%s: %v", js, err)
+		}
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+	loc := func(line, char, endChar int) string {
+		return fmt.Sprintf(`{"uri":%q,"range":{"start":{"line":%d,"character":%d},"end":{"line":%d,"character":%d}}}`,
+			uri, line, char, line, endChar)
+	}
+
+	if got := result(1); !strings.Contains(got, `"definitionProvider":true`) || !strings.Contains(got, `"positionEncoding":"utf-16"`) {
+		t.Errorf("initialize = %s", got)
+	}
+	if got, want := result(3), norm(`[{"name":"Reader","kind":11,"location":`+loc(3, 5, 11)+`,"containerName":"example.com/p"}]`); got != want {
+		t.Errorf("workspace/symbol = %s\nwant %s", got, want)
+	}
+	if got, want := result(4), norm("["+loc(3, 5, 11)+"]"); got != want {
+		t.Errorf("definition = %s\nwant %s", got, want)
+	}
+	if got := result(5); !strings.Contains(got, "func (f *File) Read() error") || !strings.Contains(got, `"start":{"character":23,"line":9}`) {
+		t.Errorf("hover = %s", got)
+	}
+	if got, want := result(6), norm("["+loc(3, 5, 11)+","+loc(9, 5, 8)+"]"); got != want {
+		t.Errorf("references = %s\nwant %s", got, want)
+	}
+	if got, want := result(7), norm("["+loc(9, 23, 27)+"]"); got != want {
+		t.Errorf("call references = %s\nwant %s", got, want)
+	}
+	if e, _ := resps[8]["error"].(map[string]any); e == nil || e["code"] != float64(codeMethodNotFound) {
+		t.Errorf("unsupported method = %v", resps[8])
+	}
+	if len(resps) != 8 {
+		t.Errorf("got %d responses, want 8 (notifications get none)", len(resps))
+	}
+}
+
+func TestPositions(t *testing.T) {
+	s := &Server{}
+	line := "x := \"é𝄞\" + name"
+	b := strings.Index(line, "name")
+	// é is one UTF-16 unit, 𝄞 two.
+	if got := s.charCol(line, b); got != 13 {
+		t.Errorf("charCol = %d", got)
+	}
+	if got := s.byteCol(line, 13); got != b {
+		t.Errorf("byteCol = %d, want %d", got, b)
+	}
+	if start, end := wordAt(line, b+2); line[start:end] != "name" {
+		t.Errorf("wordAt = %q", line[start:end])
+	}
+	s.utf8 = true
+	if got := s.charCol(line, b); got != b {
+		t.Errorf("utf-8 charCol = %d", got)
+	}
+}
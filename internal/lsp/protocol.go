@@ -0,0 +1,123 @@
+package lsp
+
+// The subset of LSP 3.17 structures the server reads and writes.
+
+type Position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+type Location struct {
+	URI   string `json:"uri"`
+	Range Range  `json:"range"`
+}
+
+type SymbolInformation struct {
+	Name          string   `json:"name"`
+	Kind          int      `json:"kind"`
+	Location      Location `json:"location"`
+	ContainerName string   `json:"containerName,omitempty"`
+}
+
+type MarkupContent struct {
+	Kind  string `json:"kind"`
+	Value string `json:"value"`
+}
+
+type Hover struct {
+	Contents MarkupContent `json:"contents"`
+	Range    *Range        `json:"range,omitempty"`
+}
+
+type initializeParams struct {
+	RootURI          string `json:"rootUri"`
+	RootPath         string `json:"rootPath"`
+	WorkspaceFolders []struct {
+		URI string `json:"uri"`
+	} `json:"workspaceFolders"`
+	Capabilities struct {
+		General struct {
+			PositionEncodings []string `json:"positionEncodings"`
+		} `json:"general"`
+	} `json:"capabilities"`
+}
+
+type textDocumentIdentifier struct {
+	URI string `json:"uri"`
+}
+
+type textDocumentPositionParams struct {
+	TextDocument textDocumentIdentifier `json:"textDocument"`
+	Position     Position               `json:"position"`
+}
+
+type referenceParams struct {
+	textDocumentPositionParams
+	Context struct {
+		IncludeDeclaration bool `json:"includeDeclaration"`
+	} `json:"context"`
+}
+
+type workspaceSymbolParams struct {
+	Query string `json:"query"`
+}
+
+type didOpenParams struct {
+	TextDocument struct {
+		URI  string `json:"uri"`
+		Text string `json:"text"`
+	} `json:"textDocument"`
+}
+
+type didChangeParams struct {
+	TextDocument   textDocumentIdentifier `json:"textDocument"`
+	ContentChanges []struct {
+		Range *Range `json:"range"`
+		Text  string `json:"text"`
+	} `json:"contentChanges"`
+}
+
+type didCloseParams struct {
+	TextDocument textDocumentIdentifier `json:"textDocument"`
+}
+
+// symbolKinds maps IR symbol kinds to LSP SymbolKind; unknown kinds are
+// reported as variables.
+var symbolKinds = map[string]int{
+	"module":      2,
+	"namespace":   3,
+	"package":     4,
+	"class":       5,
+	"method":      6,
+	"property":    7,
+	"field":       8,
+	"constructor": 9,
+	"enum":        10,
+	"interface":   11,
+	"protocol":    11,
+	"trait":       11,
+	"function":    12,
+	"macro":       12,
+	"var":         13,
+	"variable":    13,
+	"const":       14,
+	"constant":    14,
+	"enum_member": 22,
+	"struct":      23,
+	"union":       23,
+	"type":        5,
+	"alias":       5,
+	"typedef":     5,
+}
+
+func symbolKind(kind string) int {
+	if k, ok := symbolKinds[kind]; ok {
+		return k
+	}
+	return 13
+}
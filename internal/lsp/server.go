@@ -0,0 +1,306 @@
+// Package lsp answers Language Server Protocol requests from a run database,
+// giving editors workspace symbols, go-to-definition, hover and references
+// for any language the run indexed, without a native language server.
+//
+// Answers come from the index, not from the buffers being edited: the
+// identifier under the cursor is read from the open document (or the file on
+// disk) and resolved by name to the symbols the run recorded, whose spans are
+// those of the indexed sources.
+package lsp
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Server is one LSP session over a run database.
+type Server struct {
+	db  *sql.DB
+	log *slog.Logger
+
+	root        string // workspace root directory that file paths are relative to
+	utf8        bool   // the client counts characters in UTF-8 code units
+	initialized bool
+	shutdown    bool
+
+	mu    sync.Mutex
+	docs  map[string]string   // text of open documents, by URI
+	lines map[string][]string // split text, by URI, of open documents and files read from disk
+}
+
+func New(rdb *sql.DB, lg *slog.Logger) *Server {
+	return &Server{db: rdb, log: lg, docs: map[string]string{}, lines: map[string][]string{}}
+}
+
+// errExit is returned by a handler for the exit notification.
+var errExit = errors.New("exit")
+
+// Serve reads requests from r and writes responses to w until the client
+// sends exit, closes the stream, or ctx is cancelled. Requests are answered
+// in order. Exiting without a prior shutdown request is an error, which the
+// protocol asks servers to report with a non-zero exit status.
+func (s *Server) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	type frame struct {
+		body []byte
+		err  error
+	}
+	frames := make(chan frame)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		br := bufio.NewReader(r)
+		for {
+			body, err := readMessage(br)
+			select {
+			case frames <- frame{body, err}:
+			case <-done:
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		var f frame
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case f = <-frames:
+		}
+		if errors.Is(f.err, io.EOF) {
+			return nil
+		}
+		if f.err != nil {
+			return f.err
+		}
+
+		var msg message
+		if err := json.Unmarshal(f.body, &msg); err != nil {
+			if err := writeMessage(w, errorResponse{"2.0", json.RawMessage("null"), errorf(codeParseError, "%v", err)}); err != nil {
+				return err
+			}
+			continue
+		}
+		result, err := s.handle(ctx, &msg)
+		if errors.Is(err, errExit) {
+			if !s.shutdown {
+				return errors.New("exit before shutdown")
+			}
+			return nil
+		}
+		if msg.ID == nil {
+			if err != nil {
+				s.log.Warn("lsp notification failed", "method", msg.Method, "err", err)
+			}
+			continue
+		}
+		if err != nil {
+			var re *rpcError
+			if !errors.As(err, &re) {
+				s.log.Error("lsp request failed", "method", msg.Method, "err", err)
+				re = errorf(codeInternalError, "%v", err)
+			}
+			err = writeMessage(w, errorResponse{"2.0", msg.ID, re})
+		} else {
+			err = writeMessage(w, response{"2.0", msg.ID, result})
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) handle(ctx context.Context, msg *message) (any, error) {
+	s.log.Debug("lsp message", "method", msg.Method)
+	switch msg.Method {
+	case "initialize":
+		var p initializeParams
+		if err := unmarshal(msg.Params, &p); err != nil {
+			return nil, err
+		}
+		return s.initialize(ctx, &p)
+	case "initialized", "$/cancelRequest", "$/setTrace", "workspace/didChangeConfiguration":
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "exit":
+		return nil, errExit
+	}
+	if !s.initialized {
+		return nil, errorf(codeServerNotInitialized, "server not initialized")
+	}
+	if s.shutdown {
+		return nil, errorf(codeInvalidRequest, "server is shutting down")
+	}
+
+	switch msg.Method {
+	case "textDocument/didOpen":
+		var p didOpenParams
+		if err := unmarshal(msg.Params, &p); err != nil {
+			return nil, err
+		}
+		s.setText(p.TextDocument.URI, p.TextDocument.Text, true)
+		return nil, nil
+	case "textDocument/didChange":
+		var p didChangeParams
+		if err := unmarshal(msg.Params, &p); err != nil {
+			return nil, err
+		}
+		for _, c := range p.ContentChanges {
+			// Full sync is all the server asks for; a ranged change means the
+			// client ignored that, so fall back to the file on disk.
+			s.setText(p.TextDocument.URI, c.Text, c.Range == nil)
+		}
+		return nil, nil
+	case "textDocument/didClose":
+		var p didCloseParams
+		if err := unmarshal(msg.Params, &p); err != nil {
+			return nil, err
+		}
+		s.setText(p.TextDocument.URI, "", false)
+		return nil, nil
+	case "workspace/symbol":
+		var p workspaceSymbolParams
+		if err := unmarshal(msg.Params, &p); err != nil {
+			return nil, err
+		}
+		return s.workspaceSymbol(ctx, p.Query)
+	case "textDocument/definition":
+		var p textDocumentPositionParams
+		if err := unmarshal(msg.Params, &p); err != nil {
+			return nil, err
+		}
+		return s.definition(ctx, p.TextDocument.URI, p.Position)
+	case "textDocument/hover":
+		var p textDocumentPositionParams
+		if err := unmarshal(msg.Params, &p); err != nil {
+			return nil, err
+		}
+		return s.hover(ctx, p.TextDocument.URI, p.Position)
+	case "textDocument/references":
+		var p referenceParams
+		if err := unmarshal(msg.Params, &p); err != nil {
+			return nil, err
+		}
+		return s.references(ctx, p.TextDocument.URI, p.Position, p.Context.IncludeDeclaration)
+	}
+	if strings.HasPrefix(msg.Method, "$/") || msg.ID == nil {
+		return nil, nil
+	}
+	return nil, errorf(codeMethodNotFound, "method %s not supported", msg.Method)
+}
+
+func unmarshal(params json.RawMessage, v any) error {
+	if len(params) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(params, v); err != nil {
+		return errorf(codeInvalidParams, "%v", err)
+	}
+	return nil
+}
+
+func (s *Server) initialize(ctx context.Context, p *initializeParams) (any, error) {
+	switch {
+	case p.RootURI != "":
+		s.root = uriPath(p.RootURI)
+	case len(p.WorkspaceFolders) > 0:
+		s.root = uriPath(p.WorkspaceFolders[0].URI)
+	case p.RootPath != "":
+		s.root = p.RootPath
+	default:
+		// Without a workspace, paths are relative to the indexed directory.
+		if err := s.db.QueryRowContext(ctx, `SELECT root_path FROM project ORDER BY id LIMIT 1;`).Scan(&s.root); err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+	}
+	encoding := "utf-16"
+	for _, e := range p.Capabilities.General.PositionEncodings {
+		if e == "utf-8" {
+			s.utf8, encoding = true, e
+		}
+	}
+	s.initialized = true
+	s.log.Info("lsp initialized", "root", s.root, "position_encoding", encoding)
+	return map[string]any{
+		"capabilities": map[string]any{
+			"positionEncoding":        encoding,
+			"textDocumentSync":        1, // full
+			"workspaceSymbolProvider": true,
+			"definitionProvider":      true,
+			"hoverProvider":           true,
+			"referencesProvider":      true,
+		},
+		"serverInfo": map[string]string{"name": "cargoworker"},
+	}, nil
+}
+
+// setText records (open) or forgets the text of a document.
+func (s *Server) setText(uri, text string, open bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.lines, uri)
+	if open {
+		s.docs[uri] = text
+	} else {
+		delete(s.docs, uri)
+	}
+}
+
+// line returns line n (0-based) of a document: its open text, else the file
+// on disk. ok is false when neither is available.
+func (s *Server) line(uri string, n int) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lines, ok := s.lines[uri]
+	if !ok {
+		text, open := s.docs[uri]
+		if !open {
+			b, err := os.ReadFile(uriPath(uri))
+			if err == nil {
+				text, open = string(b), true
+			}
+		}
+		if open {
+			lines = strings.Split(text, "\n")
+		}
+		s.lines[uri] = lines
+	}
+	if n < 0 || n >= len(lines) {
+		return "", false
+	}
+	return strings.TrimSuffix(lines[n], "\r"), true
+}
+
+// fileURI turns a path relative to the workspace root into a file URI.
+func (s *Server) fileURI(rel string) string {
+	p := filepath.FromSlash(rel)
+	if !filepath.IsAbs(p) && s.root != "" {
+		p = filepath.Join(s.root, p)
+	}
+	return pathURI(p)
+}
+
+// relPath is the path of uri relative to the workspace root, with forward
+// slashes.
+func (s *Server) relPath(uri string) string {
+	p := uriPath(uri)
+	if s.root != "" {
+		if rel, err := filepath.Rel(s.root, p); err == nil && !strings.HasPrefix(rel, "..") {
+			p = rel
+		}
+	}
+	return filepath.ToSlash(p)
+}
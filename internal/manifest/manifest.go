@@ -0,0 +1,83 @@
+package manifest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileName is the name of the manifest inside a run directory.
+const FileName = "manifest.json"
+
+// Manifest describes a finalized run so downstream consumers can verify what
+// they downloaded without opening the database.
+type Manifest struct {
+	RunID        string    `json:"run_id"`
+	ToolVersion  string    `json:"tool_version"`
+	IRSchema     string    `json:"ir_schema"`
+	DBSchema     int       `json:"db_schema"` // PRAGMA user_version at finalize
+	Store        string    `json:"store"`     // where the IR was written: sqlite|postgres
+	InputPath    string    `json:"input_path"`
+	CreatedUTC   time.Time `json:"created_utc"`
+	FinalizedUTC time.Time `json:"finalized_utc"`
+	Database     *Artifact `json:"database,omitempty"` // absent with postgres: the run database holds no IR
+	Compact      *Artifact `json:"compact,omitempty"`
+}
+
+// Artifact is a file in the run directory together with its content digest.
+type Artifact struct {
+	Path   string `json:"path"` // relative to the run directory
+	SHA256 string `json:"sha256"`
+	Bytes  int64  `json:"bytes"`
+}
+
+// Digest hashes runDir/rel and returns it as an Artifact.
+func Digest(runDir, rel string) (*Artifact, error) {
+	f, err := os.Open(filepath.Join(runDir, rel))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return nil, fmt.Errorf("hash %s: %w", rel, err)
+	}
+	return &Artifact{
+		Path:   filepath.ToSlash(rel),
+		SHA256: hex.EncodeToString(h.Sum(nil)),
+		Bytes:  n,
+	}, nil
+}
+
+// Write stores the manifest at path, replacing any previous file atomically.
+func (m *Manifest) Write(path string) error {
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(b, '\n'), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Read loads a manifest written by Write.
+func Read(path string) (*Manifest, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return &m, nil
+}
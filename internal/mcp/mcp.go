@@ -0,0 +1,227 @@
+// Package mcp serves a run database to AI assistants over the Model Context
+// Protocol: JSON-RPC messages, one per line, on stdin and stdout. The server
+// offers read-only tools over the index (see tools.go) and never opens a
+// network connection.
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+)
+
+// protocolVersions are the MCP revisions the server speaks, newest first.
+var protocolVersions = []string{"2025-06-18", "2025-03-26", "2024-11-05"}
+
+// maxMessage bounds the length of one incoming line.
+const maxMessage = 16 << 20
+
+// JSON-RPC error codes.
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	codeInternalError  = -32603
+)
+
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result"`
+}
+
+type errorResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Error   *rpcError       `json:"error"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string { return e.Message }
+
+func errorf(code int, format string, args ...any) *rpcError {
+	return &rpcError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// Server is one MCP session over a run database.
+type Server struct {
+	db  *sql.DB
+	log *slog.Logger
+}
+
+func New(rdb *sql.DB, lg *slog.Logger) *Server {
+	return &Server{db: rdb, log: lg}
+}
+
+// Serve answers requests read from r on w until r is closed or ctx is
+// cancelled.
+func (s *Server) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	type line struct {
+		b   []byte
+		err error
+	}
+	lines := make(chan line)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		sc := bufio.NewScanner(r)
+		sc.Buffer(make([]byte, 0, 64<<10), maxMessage)
+		for sc.Scan() {
+			select {
+			case lines <- line{b: append([]byte(nil), sc.Bytes()...)}:
+			case <-done:
+				return
+			}
+		}
+		err := sc.Err()
+		if err == nil {
+			err = io.EOF
+		}
+		select {
+		case lines <- line{err: err}:
+		case <-done:
+		}
+	}()
+
+	out := bufio.NewWriter(w)
+	for {
+		var l line
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case l = <-lines:
+		}
+		if errors.Is(l.err, io.EOF) {
+			return nil
+		}
+		if l.err != nil {
+			return l.err
+		}
+		if len(l.b) == 0 {
+			continue
+		}
+		if reply := s.dispatch(ctx, l.b); reply != nil {
+			b, err := json.Marshal(reply)
+			if err != nil {
+				return err
+			}
+			if _, err := out.Write(append(b, '\n')); err != nil {
+				return err
+			}
+			if err := out.Flush(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// dispatch handles one message and returns the reply to send, nil for
+// notifications.
+func (s *Server) dispatch(ctx context.Context, b []byte) any {
+	var msg message
+	if err := json.Unmarshal(b, &msg); err != nil {
+		return errorResponse{"2.0", json.RawMessage("null"), errorf(codeParseError, "%v", err)}
+	}
+	if msg.JSONRPC != "2.0" || msg.Method == "" {
+		return errorResponse{"2.0", idOrNull(msg.ID), errorf(codeInvalidRequest, "not a JSON-RPC 2.0 request")}
+	}
+	s.log.Debug("mcp message", "method", msg.Method)
+	result, err := s.handle(ctx, &msg)
+	if msg.ID == nil {
+		if err != nil {
+			s.log.Warn("mcp notification failed", "method", msg.Method, "err", err)
+		}
+		return nil
+	}
+	if err != nil {
+		var re *rpcError
+		if !errors.As(err, &re) {
+			s.log.Error("mcp request failed", "method", msg.Method, "err", err)
+			re = errorf(codeInternalError, "%v", err)
+		}
+		return errorResponse{"2.0", msg.ID, re}
+	}
+	return response{"2.0", msg.ID, result}
+}
+
+func idOrNull(id json.RawMessage) json.RawMessage {
+	if id == nil {
+		return json.RawMessage("null")
+	}
+	return id
+}
+
+func (s *Server) handle(ctx context.Context, msg *message) (any, error) {
+	switch msg.Method {
+	case "initialize":
+		var p struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		if err := unmarshal(msg.Params, &p); err != nil {
+			return nil, err
+		}
+		version := protocolVersions[0]
+		if slices.Contains(protocolVersions, p.ProtocolVersion) {
+			version = p.ProtocolVersion
+		}
+		// Report the version of the tool that built the index.
+		tool := "unknown"
+		if err := s.db.QueryRowContext(ctx, `SELECT COALESCE(tool_version, '') FROM project ORDER BY id LIMIT 1;`).Scan(&tool); err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		s.log.Info("mcp initialized", "protocol_version", version)
+		return map[string]any{
+			"protocolVersion": version,
+			"capabilities":    map[string]any{"tools": map[string]any{}},
+			"serverInfo":      map[string]string{"name": "cargoworker", "version": tool},
+			"instructions": "Read-only access to a cargoworker index of a code base. Start with search_symbols " +
+				"or list_container; ids in results can be passed to get_symbol, find_implementations and " +
+				"get_file_outline. Lists are paginated: pass next_cursor back as cursor for more.",
+		}, nil
+	case "ping":
+		return map[string]any{}, nil
+	case "tools/list":
+		return map[string]any{"tools": toolList()}, nil
+	case "tools/call":
+		var p struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		if err := unmarshal(msg.Params, &p); err != nil {
+			return nil, err
+		}
+		return s.call(ctx, p.Name, p.Arguments)
+	}
+	if msg.ID == nil {
+		return nil, nil // notifications/initialized, notifications/cancelled, ...
+	}
+	return nil, errorf(codeMethodNotFound, "method %s not supported", msg.Method)
+}
+
+func unmarshal(params json.RawMessage, v any) error {
+	if len(params) == 0 || string(params) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(params, v); err != nil {
+		return errorf(codeInvalidParams, "%v", err)
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/ChaseHampton/cargoworker/internal/db"
+	"github.com/ChaseHampton/cargoworker/internal/ir"
+	"github.com/ChaseHampton/cargoworker/internal/query"
+	"github.com/ChaseHampton/cargoworker/internal/store"
+)
+
+// seed writes a run database with one package holding an interface, a struct
+// implementing it, and n functions.
+func seed(t *testing.T, ctx context.Context, n int) *sql.DB {
+	t.Helper()
+	rdb, err := db.Open(ctx, filepath.Join(t.TempDir(), db.FileName))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { rdb.Close() })
+	st := store.NewSQLite(rdb)
+	if err := st.Migrate(ctx); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if err := st.WriteProject(ctx, &ir.Project{Id: uuid.New(), Name: "p", RootUri: "/src", ToolVersion: "v1"}); err != nil {
+		t.Fatalf("project: %v", err)
+	}
+	c, f, reader, file, read := uuid.New(), uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	frag := &ir.Fragment{
+		Containers: []ir.Container{{Id: c, Name: "p", FullName: "example.com/p", Kind: "package", Language: "go"}},
+		Files:      []ir.File{{Id: f, ContainerId: c, Path: "p/p.go", Language: "go"}},
+		Symbols: []ir.Symbol{
+			{Id: reader, ContainerId: c, Name: "Reader", FullName: "example.com/p.Reader", Kind: "interface", OriginFileId: f,
+				StartLine: 4, DocFmt: "Reader reads " + strings.Repeat("at length ", 500)},
+			{Id: file, ContainerId: c, Name: "File", FullName: "example.com/p.File", Kind: "struct", OriginFileId: f, StartLine: 6},
+			{Id: read, ContainerId: c, Name: "Read", FullName: "example.com/p.File.Read", Kind: "method", OriginFileId: f, StartLine: 8},
+		},
+		Members:   []ir.Member{{Id: uuid.New(), OwnerSymbolId: file, ChildSymbolId: read}},
+		Relations: []ir.Relation{{SourceSymbolId: file, Relation: "implements", DstSymbolId: reader}},
+	}
+	for i := range n {
+		frag.Symbols = append(frag.Symbols, ir.Symbol{Id: uuid.New(), ContainerId: c, Name: fmt.Sprintf("Fn%02d", i),
+			FullName: fmt.Sprintf("example.com/p.Fn%02d", i), Kind: "function", OriginFileId: f, StartLine: 10 + i})
+	}
+	if err := st.WriteFragment(ctx, frag); err != nil {
+		t.Fatalf("fragment: %v", err)
+	}
+	if _, err := st.Flush(ctx); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	return rdb
+}
+
+// session runs the server over scripted requests, one per line; request i
+// gets id i+1 unless it is a notification. It returns the responses by id.
+func session(t *testing.T, rdb *sql.DB, reqs ...map[string]any) map[int]map[string]any {
+	t.Helper()
+	var in bytes.Buffer
+	for i, m := range reqs {
+		m["jsonrpc"] = "2.0"
+		if !strings.HasPrefix(m["method"].(string), "notifications/") {
+			m["id"] = i + 1
+		}
+		b, _ := json.Marshal(m)
+		in.Write(append(b, '\n'))
+	}
+	in.WriteString("not json\n")
+	var out bytes.Buffer
+	if err := New(rdb, slog.New(slog.NewTextHandler(io.Discard, nil))).Serve(context.Background(), &in, &out); err != nil {
+		t.Fatalf("serve: %v", err)
+	}
+	resps := map[int]map[string]any{}
+	sc := bufio.NewScanner(&out)
+	sc.Buffer(nil, maxMessage)
+	for sc.Scan() {
+		var m map[string]any
+		if err := json.Unmarshal(sc.Bytes(), &m); err != nil {
+			t.Fatalf("response %q: %v", sc.Text(), err)
+		}
+		id, _ := m["id"].(float64)
+		resps[int(id)] = m
+	}
+	return resps
+}
+
+func req(method string, params any) map[string]any {
+	return map[string]any{"method": method, "params": params}
+}
+
+func call(name string, args map[string]any) map[string]any {
+	return req("tools/call", map[string]any{"name": name, "arguments": args})
+}
+
+func TestSession(t *testing.T) {
+	rdb := seed(t, context.Background(), 30)
+	resps := session(t, rdb,
+		req("initialize", map[string]any{"protocolVersion": "2025-03-26", "capabilities": map[string]any{}}),
+		req("notifications/initialized", nil),
+		req("tools/list", nil),
+		call("search_symbols", map[string]any{"query": "Reader"}),
+		call("get_symbol", map[string]any{"full_name": "example.com/p.File"}),
+		call("list_container", map[string]any{"container": "example.com/p", "kind": "function", "limit": 25}),
+		call("list_container", map[string]any{"container": "example.com/p", "kind": "function", "limit": 25, "cursor": "25"}),
+		call("find_implementations", map[string]any{"full_name": "example.com/p.Reader"}),
+		call("get_file_outline", map[string]any{"file": "p/p.go", "limit": 2}),
+		call("get_symbol", map[string]any{"full_name": "example.com/p.Missing"}),
+		call("no_such_tool", nil),
+		req("resources/list", nil),
+	)
+	// structured returns the structuredContent of a tool result.
+	structured := func(id int) map[string]any {
+		t.Helper()
+		r, _ := resps[id]["result"].(map[string]any)
+		if r == nil || r["isError"] == true {
+			t.Fatalf("response %d = %v", id, resps[id])
+		}
+		v, _ := r["structuredContent"].(map[string]any)
+		return v
+	}
+	js := func(v any) string { b, _ := json.Marshal(v); return string(b) }
+
+	if got := js(resps[1]["result"]); !strings.Contains(got, `"protocolVersion":"2025-03-26"`) || !strings.Contains(got, `"version":"v1"`) {
+		t.Errorf("initialize = %s", got)
+	}
+	tools, _ := resps[3]["result"].(map[string]any)["tools"].([]any)
+	if len(tools) != 5 {
+		t.Errorf("tools/list = %d tools, want 5", len(tools))
+	}
+	if got := js(structured(4)); !strings.Contains(got, `"full_name":"example.com/p.Reader"`) {
+		t.Errorf("search_symbols = %s", got)
+	}
+	sym := structured(5)
+	if got := js(sym); !strings.Contains(got, `"name":"Read"`) || !strings.Contains(got, `"kind":"implements"`) {
+		t.Errorf("get_symbol = %s", got)
+	}
+	page1, page2 := structured(6)["symbols"].(map[string]any), structured(7)["symbols"].(map[string]any)
+	if n := len(page1["items"].([]any)); n != 25 || page1["next_cursor"] != "25" {
+		t.Errorf("page 1 = %d items, cursor %v", n, page1["next_cursor"])
+	}
+	if n := len(page2["items"].([]any)); n != 5 || page2["next_cursor"] != nil {
+		t.Errorf("page 2 = %d items, cursor %v", n, page2["next_cursor"])
+	}
+	if got := js(structured(8)); !strings.Contains(got, `"full_name":"example.com/p.File"`) {
+		t.Errorf("find_implementations = %s", got)
+	}
+	outline := structured(9)
+	if got := js(outline["symbols"]); outline["total"] != float64(33) || !strings.Contains(got, `"next_cursor":"2"`) {
+		t.Errorf("get_file_outline = %s", js(outline))
+	}
+	if r, _ := resps[10]["result"].(map[string]any); r == nil || r["isError"] != true {
+		t.Errorf("missing symbol = %v", resps[10])
+	}
+	if e, _ := resps[11]["error"].(map[string]any); e == nil || e["code"] != float64(codeInvalidParams) {
+		t.Errorf("unknown tool = %v", resps[11])
+	}
+	if e, _ := resps[12]["error"].(map[string]any); e == nil || e["code"] != float64(codeMethodNotFound) {
+		t.Errorf("unsupported method = %v", resps[12])
+	}
+	if e, _ := resps[0]["error"].(map[string]any); e == nil || e["code"] != float64(codeParseError) {
+		t.Errorf("bad line = %v", resps[0])
+	}
+	if len(resps) != 12 {
+		t.Errorf("got %d responses, want 12 (notifications get none)", len(resps))
+	}
+}
+
+func TestSizeBound(t *testing.T) {
+	type item struct{ S string }
+	items := make([]item, 100)
+	for i := range items {
+		items[i].S = strings.Repeat("x", 1000)
+	}
+	l := newList(items, query.Page{Limit: 100}, 0)
+	b, _ := json.MarshalIndent(l, "", "  ")
+	if len(b) > maxResultBytes || len(l.Items) == 0 || l.NextCursor != fmt.Sprint(len(l.Items)) {
+		t.Errorf("newList = %d items, %d bytes, cursor %q", len(l.Items), len(b), l.NextCursor)
+	}
+	if got := clip(strings.Repeat("é", maxText)); len(got) > maxText+len("…") || !strings.HasSuffix(got, "…") {
+		t.Errorf("clip = %d bytes", len(got))
+	}
+}
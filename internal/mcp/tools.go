@@ -0,0 +1,456 @@
+package mcp
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ChaseHampton/cargoworker/internal/query"
+	"github.com/ChaseHampton/cargoworker/internal/search"
+)
+
+const (
+	// defaultLimit and maxLimit bound the items of one page.
+	defaultLimit = 20
+	maxLimit     = 100
+	// maxResultBytes bounds the encoded result of a tool call; pages are cut
+	// short, with a cursor for the rest, to stay under it.
+	maxResultBytes = 48 << 10
+	// maxText bounds doc comments and signatures in results.
+	maxText = 2000
+	// maxMembers and maxRelations bound the lists nested in get_symbol.
+	maxMembers   = 50
+	maxRelations = 25
+)
+
+type tool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	InputSchema map[string]any `json:"inputSchema"`
+	Annotations map[string]any `json:"annotations"`
+
+	run func(ctx context.Context, s *Server, args json.RawMessage) (any, error)
+}
+
+func str(desc string) map[string]any { return map[string]any{"type": "string", "description": desc} }
+
+func object(required []string, props map[string]any) map[string]any {
+	schema := map[string]any{"type": "object", "properties": props, "additionalProperties": false}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+var (
+	limitProp  = map[string]any{"type": "integer", "minimum": 1, "maximum": maxLimit, "default": defaultLimit, "description": "maximum number of items to return"}
+	cursorProp = str("next_cursor from a previous page")
+	idProp     = str("symbol id or uid, as returned by other tools")
+	nameProp   = str("fully qualified symbol name, instead of id")
+)
+
+// readOnly annotates every tool: none changes the index or reaches outside it.
+var readOnly = map[string]any{"readOnlyHint": true, "openWorldHint": false}
+
+var tools = []*tool{
+	{
+		Name:        "search_symbols",
+		Description: "Full-text search over symbol names, containers, kinds and doc comments. Returns ranked hits with ids for get_symbol.",
+		InputSchema: object([]string{"query"}, map[string]any{
+			"query":     str("search text"),
+			"mode":      map[string]any{"type": "string", "enum": []string{"fts", "prefix", "exact", "substring"}, "default": "fts", "description": "match mode"},
+			"kind":      str("only symbols of this kind, e.g. function, struct, interface"),
+			"container": str("only symbols in containers matching this full name; * and ? glob"),
+			"language":  str("only symbols of this language"),
+			"limit":     limitProp,
+			"cursor":    cursorProp,
+		}),
+		Annotations: readOnly,
+		run:         searchSymbols,
+	},
+	{
+		Name:        "get_symbol",
+		Description: "A symbol with its signature, doc comment, owner, members and first relations.",
+		InputSchema: object(nil, map[string]any{"id": idProp, "full_name": nameProp}),
+		Annotations: readOnly,
+		run:         getSymbol,
+	},
+	{
+		Name: "list_container",
+		Description: "Without container: lists containers (modules, packages, namespaces). With container: " +
+			"the container and its top-level symbols by name.",
+		InputSchema: object(nil, map[string]any{
+			"container": str("container id, uid or full name"),
+			"kind":      str("with container: only symbols of this kind; without: only containers of this kind"),
+			"prefix":    str("without container: only containers whose full name starts with this"),
+			"limit":     limitProp,
+			"cursor":    cursorProp,
+		}),
+		Annotations: readOnly,
+		run:         listContainer,
+	},
+	{
+		Name:        "find_implementations",
+		Description: "Symbols that implement, satisfy or override the given symbol.",
+		InputSchema: object(nil, map[string]any{"id": idProp, "full_name": nameProp, "limit": limitProp, "cursor": cursorProp}),
+		Annotations: readOnly,
+		run:         findImplementations,
+	},
+	{
+		Name:        "get_file_outline",
+		Description: "The symbols declared in a source file, in order of position.",
+		InputSchema: object([]string{"file"}, map[string]any{
+			"file":   str("file id, uid or path relative to the indexed root"),
+			"limit":  limitProp,
+			"cursor": cursorProp,
+		}),
+		Annotations: readOnly,
+		run:         getFileOutline,
+	},
+}
+
+func toolList() []*tool { return tools }
+
+type toolResult struct {
+	Content           []textContent `json:"content"`
+	StructuredContent any           `json:"structuredContent,omitempty"`
+	IsError           bool          `json:"isError,omitempty"`
+}
+
+type textContent struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// toolError is a failure reported to the model as a tool result rather than
+// as a protocol error, so it can correct its arguments.
+type toolError struct{ msg string }
+
+func (e toolError) Error() string { return e.msg }
+
+func badArgs(format string, args ...any) error { return toolError{fmt.Sprintf(format, args...)} }
+
+func (s *Server) call(ctx context.Context, name string, args json.RawMessage) (any, error) {
+	var t *tool
+	for _, c := range tools {
+		if c.Name == name {
+			t = c
+		}
+	}
+	if t == nil {
+		return nil, errorf(codeInvalidParams, "unknown tool %q", name)
+	}
+	v, err := t.run(ctx, s, args)
+	var te toolError
+	switch {
+	case errors.As(err, &te), errors.Is(err, query.ErrNotFound):
+		return toolResult{Content: []textContent{{"text", err.Error()}}, IsError: true}, nil
+	case err != nil:
+		return nil, err
+	}
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return toolResult{Content: []textContent{{"text", string(b)}}, StructuredContent: v}, nil
+}
+
+func decode(args json.RawMessage, v any) error {
+	if len(args) == 0 || string(args) == "null" {
+		return nil
+	}
+	dec := json.NewDecoder(strings.NewReader(string(args)))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return badArgs("invalid arguments: %v", err)
+	}
+	return nil
+}
+
+// pageArgs are the pagination arguments every list tool takes.
+type pageArgs struct {
+	Limit  int    `json:"limit"`
+	Cursor string `json:"cursor"`
+}
+
+func (a pageArgs) page() (query.Page, error) {
+	p := query.Page{Limit: a.Limit}
+	if p.Limit <= 0 {
+		p.Limit = defaultLimit
+	}
+	p.Limit = min(p.Limit, maxLimit)
+	if a.Cursor != "" {
+		n, err := strconv.Atoi(a.Cursor)
+		if err != nil || n < 0 {
+			return p, badArgs("invalid cursor %q", a.Cursor)
+		}
+		p.Offset = n
+	}
+	return p, nil
+}
+
+// list is a page of results; next_cursor is set while more may follow.
+type list[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// newList pages items fetched with p, cutting the page short when it would
+// encode to more than maxResultBytes (less reserve, the room other fields of
+// the result need).
+func newList[T any](items []T, p query.Page, reserve int) list[T] {
+	l := list[T]{Items: items}
+	full := len(items) == p.Limit
+	for len(l.Items) > 1 {
+		b, _ := json.MarshalIndent(l, "", "  ")
+		if len(b)+reserve <= maxResultBytes {
+			break
+		}
+		l.Items, full = l.Items[:len(l.Items)/2], true
+	}
+	if full {
+		l.NextCursor = strconv.Itoa(p.Offset + len(l.Items))
+	}
+	return l
+}
+
+func clip(s string) string {
+	if len(s) <= maxText {
+		return s
+	}
+	cut := maxText
+	for cut > 0 && s[cut]&0xC0 == 0x80 { // not inside a UTF-8 sequence
+		cut--
+	}
+	return s[:cut] + "…"
+}
+
+var nouns = map[string]string{"package": "container", "symbol": "symbol", "file": "file"}
+
+// resolve maps a tool argument to a row id of table: a row id or uid as
+// query.ResolveID accepts, else the name column.
+func (s *Server) resolve(ctx context.Context, table, ref string) (int64, error) {
+	id, err := query.ResolveID(ctx, s.db, table, ref)
+	if !errors.Is(err, query.ErrNotFound) {
+		return id, err
+	}
+	col := map[string]string{"package": "import_path", "symbol": "full_name", "file": "rel_path"}[table]
+	err = s.db.QueryRowContext(ctx, `SELECT id FROM `+table+` WHERE `+col+` = ? ORDER BY id LIMIT 1;`, ref).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, badArgs("no %s %q in the index", nouns[table], ref)
+	}
+	return id, err
+}
+
+// symbolID resolves the id or full_name argument of a symbol tool.
+func (s *Server) symbolID(ctx context.Context, id, fullName string) (int64, error) {
+	switch {
+	case id != "" && fullName != "":
+		return 0, badArgs("pass id or full_name, not both")
+	case id != "":
+		return s.resolve(ctx, "symbol", id)
+	case fullName != "":
+		return s.resolve(ctx, "symbol", fullName)
+	}
+	return 0, badArgs("id or full_name is required")
+}
+
+func searchSymbols(ctx context.Context, s *Server, raw json.RawMessage) (any, error) {
+	var a struct {
+		Query     string `json:"query"`
+		Mode      string `json:"mode"`
+		Kind      string `json:"kind"`
+		Container string `json:"container"`
+		Language  string `json:"language"`
+		pageArgs
+	}
+	if err := decode(raw, &a); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(a.Query) == "" {
+		return nil, badArgs("query is required")
+	}
+	mode, err := search.ParseMode(a.Mode)
+	if err != nil {
+		return nil, badArgs("%v", err)
+	}
+	p, err := a.page()
+	if err != nil {
+		return nil, err
+	}
+	hits, err := search.Search(ctx, s.db, search.Query{
+		Text: a.Query, Mode: mode, Kind: a.Kind, Pkg: a.Container, Lang: a.Language,
+		Limit: p.Limit, Offset: p.Offset,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i := range hits {
+		hits[i].Snippet = clip(hits[i].Snippet)
+	}
+	return newList(hits, p, 0), nil
+}
+
+func getSymbol(ctx context.Context, s *Server, raw json.RawMessage) (any, error) {
+	var a struct {
+		ID       string `json:"id"`
+		FullName string `json:"full_name"`
+	}
+	if err := decode(raw, &a); err != nil {
+		return nil, err
+	}
+	id, err := s.symbolID(ctx, a.ID, a.FullName)
+	if err != nil {
+		return nil, err
+	}
+	sym, err := query.GetSymbol(ctx, s.db, id)
+	if err != nil {
+		return nil, err
+	}
+	sym.Doc, sym.Signature = clip(sym.Doc), clip(sym.Signature)
+	out := struct {
+		*query.Symbol
+		MembersTotal    int              `json:"members_total,omitempty"`
+		Relations       []query.Relation `json:"relations"`
+		RelationsCapped bool             `json:"relations_truncated,omitempty"`
+	}{Symbol: sym}
+	if len(sym.Members) > maxMembers {
+		out.MembersTotal, sym.Members = len(sym.Members), sym.Members[:maxMembers]
+	}
+	out.Relations, err = query.Relations(ctx, s.db, id, query.Both, "", query.Page{Limit: maxRelations + 1})
+	if err != nil {
+		return nil, err
+	}
+	if len(out.Relations) > maxRelations {
+		out.Relations, out.RelationsCapped = out.Relations[:maxRelations], true
+	}
+	return out, nil
+}
+
+func listContainer(ctx context.Context, s *Server, raw json.RawMessage) (any, error) {
+	var a struct {
+		Container string `json:"container"`
+		Kind      string `json:"kind"`
+		Prefix    string `json:"prefix"`
+		pageArgs
+	}
+	if err := decode(raw, &a); err != nil {
+		return nil, err
+	}
+	p, err := a.page()
+	if err != nil {
+		return nil, err
+	}
+	if a.Container == "" {
+		cs, err := query.Containers(ctx, s.db, query.ContainerFilter{Kind: a.Kind, Prefix: a.Prefix}, p)
+		if err != nil {
+			return nil, err
+		}
+		for i := range cs {
+			cs[i].Doc = clip(cs[i].Doc)
+		}
+		return newList(cs, p, 0), nil
+	}
+	if a.Prefix != "" {
+		return nil, badArgs("prefix only applies when listing containers")
+	}
+	id, err := s.resolve(ctx, "package", a.Container)
+	if err != nil {
+		return nil, err
+	}
+	c, err := query.GetContainer(ctx, s.db, id)
+	if err != nil {
+		return nil, err
+	}
+	c.Doc = clip(c.Doc)
+	syms, err := query.ContainerSymbols(ctx, s.db, id, a.Kind, p)
+	if err != nil {
+		return nil, err
+	}
+	head, _ := json.Marshal(c)
+	return struct {
+		Container *query.Container      `json:"container"`
+		Symbols   list[query.SymbolRef] `json:"symbols"`
+	}{c, newList(syms, p, len(head))}, nil
+}
+
+func findImplementations(ctx context.Context, s *Server, raw json.RawMessage) (any, error) {
+	var a struct {
+		ID       string `json:"id"`
+		FullName string `json:"full_name"`
+		pageArgs
+	}
+	if err := decode(raw, &a); err != nil {
+		return nil, err
+	}
+	id, err := s.symbolID(ctx, a.ID, a.FullName)
+	if err != nil {
+		return nil, err
+	}
+	p, err := a.page()
+	if err != nil {
+		return nil, err
+	}
+	impls, err := query.Implementations(ctx, s.db, id, p)
+	if err != nil {
+		return nil, err
+	}
+	return newList(impls, p, 0), nil
+}
+
+// outlineEntry is a symbol of a file outline; the file and container are
+// those of the outline.
+type outlineEntry struct {
+	ID       int64  `json:"id"`
+	Name     string `json:"name"`
+	FullName string `json:"full_name,omitempty"`
+	Kind     string `json:"kind"`
+	Line     int    `json:"line,omitempty"`
+}
+
+func getFileOutline(ctx context.Context, s *Server, raw json.RawMessage) (any, error) {
+	var a struct {
+		File string `json:"file"`
+		pageArgs
+	}
+	if err := decode(raw, &a); err != nil {
+		return nil, err
+	}
+	if a.File == "" {
+		return nil, badArgs("file is required")
+	}
+	p, err := a.page()
+	if err != nil {
+		return nil, err
+	}
+	id, err := s.resolve(ctx, "file", a.File)
+	if err != nil {
+		return nil, err
+	}
+	f, err := query.GetFile(ctx, s.db, id)
+	if err != nil {
+		return nil, err
+	}
+	var entries []outlineEntry
+	for _, r := range f.Symbols[min(p.Offset, len(f.Symbols)):min(p.Offset+p.Limit, len(f.Symbols))] {
+		entries = append(entries, outlineEntry{r.ID, r.Name, r.FullName, r.Kind, r.Line})
+	}
+	if entries == nil {
+		entries = []outlineEntry{}
+	}
+	symbols := newList(entries, p, 512)
+	if p.Offset+len(symbols.Items) >= len(f.Symbols) {
+		symbols.NextCursor = ""
+	}
+	total := len(f.Symbols)
+	f.Symbols = nil
+	return struct {
+		File    *query.File        `json:"file"`
+		Symbols list[outlineEntry] `json:"symbols"`
+		Total   int                `json:"total"`
+	}{f, symbols, total}, nil
+}
@@ -0,0 +1,530 @@
+// Package cpp is the language pack for C, C++, Objective-C and
+// Objective-C++. It reads sources without a compiler: a token-level
+// outline parser, run after the conditional directives as the compiler
+// would with the file's macros, finds the namespaces, classes, structs,
+// unions, enums, typedefs and aliases of a file, with their functions,
+// methods, fields and variables, and the macros it defines; for
+// Objective-C, the interfaces, protocols and implementations with their
+// methods and properties.
+//
+// Each namespace is a container, inside the CMake or Meson project the
+// sources belong to, if any. #include directives are the imports of a
+// file's container, resolved against the file's directory, the include
+// paths of an optional compile_commands.json, and the files under the
+// input root; the database's -D and -U flags, and the macros of included
+// headers, decide the branches of #if directives. A function or variable
+// declared without its definition, as in a header, is paired with the
+// definition in a source that includes it: the definition defines the
+// declaration, and the declaration declares the definition. Doxygen
+// comments are parsed: \brief, \param, \return and the other commands
+// become the sections of doc_fmt, and the parsed comment is kept in
+// extra_json.
+package cpp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+
+	"github.com/ChaseHampton/cargoworker/internal/ir"
+	"github.com/ChaseHampton/cargoworker/internal/pack"
+	"github.com/ChaseHampton/cargoworker/internal/pack/outline"
+)
+
+func init() {
+	p := New()
+	for _, lang := range []string{"c", "cpp", "objc", "objcxx"} {
+		pack.Register(lang, p)
+	}
+}
+
+// globalNamespace names the container of declarations outside any
+// namespace.
+const globalNamespace = "(global)"
+
+// Pack extracts the C family. Headers and sources are different languages
+// and a namespace spans directories, so one pack serves them all and keeps
+// containers and symbol ids in tables across units; each root's layout
+// keeps its parsed sources.
+type Pack struct {
+	mu      sync.Mutex
+	layouts map[string]*layout // by input root
+	tables  *outline.Tables
+}
+
+func New() *Pack {
+	return &Pack{layouts: map[string]*layout{}, tables: outline.NewTables()}
+}
+
+func (p *Pack) Name() string { return "cpp" }
+
+var _ pack.Pack = (*Pack)(nil)
+
+// Extract outlines the files of a unit. CMake files, which share the cpp
+// language, are left out.
+func (p *Pack) Extract(ctx context.Context, u pack.Unit) (*ir.Fragment, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	l, ok := p.layouts[u.Root]
+	if !ok {
+		l = newLayout(u.Root)
+		p.layouts[u.Root] = l
+	}
+	x := &extraction{l: l, u: u, proj: l.project(u.Dir), frag: p.tables.Fragment(u)}
+	for _, rel := range u.Files {
+		if !sources[path.Ext(rel)] {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		b, err := os.ReadFile(filepath.Join(u.Root, filepath.FromSlash(rel)))
+		if err != nil {
+			return nil, err
+		}
+		f := l.parse(rel)
+		if f == nil {
+			f = parse(string(b), l.defines(rel))
+		}
+		x.file(rel, b, f)
+	}
+	return x.frag.Fragment, nil
+}
+
+// extraction is the state of one Extract call.
+type extraction struct {
+	l    *layout
+	u    pack.Unit
+	proj *project
+	frag *outline.Fragment // containers of the fragment, by key
+}
+
+func qualify(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	if name == "" {
+		return prefix
+	}
+	return prefix + "::" + name
+}
+
+// isHeader reports whether a file is a header, whose macros and
+// declarations are there for the files that include it.
+func isHeader(rel string) bool {
+	ext := path.Ext(rel)
+	return strings.HasPrefix(ext, ".h") || ext == ".inl" || ext == ".ipp" || ext == ".tpp"
+}
+
+// projectExtra is the extra_json of a project container.
+type projectExtra struct {
+	File            string   `json:"file"`
+	Dir             string   `json:"dir"`
+	Build           string   `json:"build"`
+	Languages       []string `json:"languages,omitempty"`
+	CompileCommands string   `json:"compile_commands,omitempty"`
+}
+
+// projectContainer returns the container of the unit's project.
+func (x *extraction) projectContainer() uuid.UUID {
+	proj := x.proj
+	return x.frag.Container("project:"+proj.dir, func() ir.Container {
+		c := ir.Container{Name: proj.name, FullName: proj.name, Kind: "project", VersionTag: proj.version, DocRaw: proj.description, DocFmt: proj.description}
+		b, _ := json.Marshal(projectExtra{File: proj.file, Dir: proj.dir, Build: proj.build, Languages: proj.languages, CompileCommands: x.l.db})
+		c.ExtraJson = string(b)
+		return c
+	})
+}
+
+// namespaceContainer returns the container of a namespace of the project,
+// or of the input root outside any project, making it.
+func (x *extraction) namespaceContainer(ns string) uuid.UUID {
+	var parent uuid.UUID
+	key := "namespace::" + ns
+	if x.proj != nil {
+		parent = x.projectContainer()
+		key = "namespace:" + x.proj.dir + ":" + ns
+	}
+	name := ns
+	if i := strings.LastIndex(ns, "::"); i >= 0 {
+		name = ns[i+2:]
+	}
+	if ns == "" {
+		ns, name = globalNamespace, globalNamespace
+	}
+	return x.frag.Container(key, func() ir.Container {
+		return ir.Container{ParentId: parent, Name: name, FullName: ns, Kind: "namespace"}
+	})
+}
+
+func (x *extraction) file(rel string, src []byte, f *file) {
+	ns := ""
+	for _, d := range f.decls {
+		if d.kind == "namespace" && d.name != "" {
+			ns = d.name
+			break
+		}
+	}
+	cid := x.namespaceContainer(ns)
+	fid := x.frag.File(cid, rel, src)
+	x.includes(cid, rel, f.includes)
+	x.imports(cid, rel, f.usings)
+	ix := x.l.index(rel)
+	res := &resolver{aliases: map[string]string{}, types: ix.types}
+	res.use(f.usings)
+	e := &emitter{x: x, ix: ix, fid: fid, rel: rel}
+	e.macros(f.macros)
+	e.decls(nil, nil, "", uuid.Nil, f.decls, res, nil, false)
+}
+
+// includes records the #include and #import directives of a file.
+func (x *extraction) includes(cid uuid.UUID, rel string, incs []include) {
+	type details struct {
+		File     string `json:"file"`
+		Line     int    `json:"line"`
+		System   bool   `json:"system,omitempty"`   // <path>
+		Resolved string `json:"resolved,omitempty"` // the file under the input root
+		Stdlib   bool   `json:"stdlib,omitempty"`
+	}
+	for _, inc := range incs {
+		r, stdlib := x.l.resolve(rel, inc)
+		b, _ := json.Marshal(details{File: rel, Line: inc.line, System: inc.angle, Resolved: r, Stdlib: stdlib})
+		x.frag.Imports = append(x.frag.Imports, ir.Import{ContainerId: cid, Target: inc.path, DetailsJson: string(b)})
+	}
+}
+
+// imports records the using directives and declarations of a file or
+// namespace.
+func (x *extraction) imports(cid uuid.UUID, rel string, usings []usingDecl) {
+	type details struct {
+		File   string `json:"file"`
+		Line   int    `json:"line"`
+		Kind   string `json:"kind"` // namespace for using namespace, else declaration
+		Stdlib bool   `json:"stdlib,omitempty"`
+	}
+	for _, u := range usings {
+		kind := "declaration"
+		if u.namespace {
+			kind = "namespace"
+		}
+		b, _ := json.Marshal(details{File: rel, Line: u.line, Kind: kind, Stdlib: isStdlib(u.path)})
+		x.frag.Imports = append(x.frag.Imports, ir.Import{ContainerId: cid, Target: u.path, DetailsJson: string(b)})
+	}
+}
+
+// emitter turns the declarations of a file into symbols.
+type emitter struct {
+	x   *extraction
+	ix  *index
+	fid uuid.UUID
+	rel string
+}
+
+// extra is the extra_json of a symbol.
+type extra struct {
+	Modifiers   []string    `json:"modifiers,omitempty"`
+	Attributes  []string    `json:"attributes,omitempty"`
+	Linkage     string      `json:"linkage,omitempty"`     // C, of an extern "C" declaration
+	Declaration bool        `json:"declaration,omitempty"` // declared here, defined elsewhere
+	Bases       []baseExtra `json:"bases,omitempty"`
+	Doc         *doxygen    `json:"doc,omitempty"`
+}
+
+type baseExtra struct {
+	Type    string `json:"type"`
+	Access  string `json:"access,omitempty"` // public, protected or private; protocol for Objective-C
+	Virtual bool   `json:"virtual,omitempty"`
+}
+
+// isType reports whether a kind of declaration is a type, whose members
+// are its children.
+func isType(kind string) bool {
+	switch kind {
+	case "class", "struct", "union", "enum", "protocol":
+		return true
+	}
+	return false
+}
+
+// macros emits the macros a file defines, in the global namespace. Those
+// of a source file are private to it.
+func (e *emitter) macros(macros []macro) {
+	if len(macros) == 0 {
+		return
+	}
+	cid := e.x.namespaceContainer("")
+	vis := "public"
+	if !isHeader(e.rel) {
+		vis = "private"
+	}
+	for _, m := range macros {
+		id := e.x.frag.NewSymbolID("macro:" + m.name)
+		sym := ir.Symbol{
+			Id: id, ContainerId: cid, Name: m.name, FullName: m.name, Kind: "macro", Visibility: vis, OriginFileId: e.fid,
+			StartLine: m.line, StartCol: m.col, EndLine: m.endLine, EndCol: m.endCol, DocRaw: m.doc,
+		}
+		var doc *doxygen
+		sym.DocFmt, doc = formatDoc(m.doc)
+		if doc != nil {
+			b, _ := json.Marshal(extra{Doc: doc})
+			sym.ExtraJson = string(b)
+		}
+		e.x.frag.Symbols = append(e.x.frag.Symbols, sym)
+		text := "#define " + m.name
+		var js struct {
+			Params []param `json:"params,omitempty"`
+		}
+		if m.params != nil {
+			text += "(" + strings.Join(m.params, ", ") + ")"
+			js.Params = []param{}
+			for _, pr := range m.params {
+				js.Params = append(js.Params, param{Name: pr})
+			}
+		}
+		if m.body != "" {
+			text += " " + m.body
+		}
+		sig := ir.Signature{SymbolId: id, Text: text}
+		if b, _ := json.Marshal(js); string(b) != "{}" {
+			sig.Json = string(b)
+		}
+		e.x.frag.Signatures = append(e.x.frag.Signatures, sig)
+	}
+}
+
+// decls emits declarations into the namespace container cid, or the
+// global namespace's, made when needed, if it is nil. owner is the type
+// they are members of, with its symbol id, and params the template
+// parameters in scope; all are nil outside types. anon is whether they are
+// in an anonymous namespace.
+func (e *emitter) decls(owner *decl, ownerID *uuid.UUID, prefix string, cid uuid.UUID, decls []*decl, res *resolver, params map[string]bool, anon bool) {
+	for i, d := range decls {
+		if d.kind == "namespace" {
+			full, ncid := qualify(prefix, d.name), cid
+			if d.name != "" {
+				ncid = e.x.namespaceContainer(full)
+			} else if ncid == uuid.Nil {
+				ncid = e.x.namespaceContainer(prefix)
+			}
+			e.x.imports(ncid, e.rel, d.usings)
+			e.decls(nil, nil, full, ncid, d.children, res.within(full, d.usings), nil, anon || d.name == "")
+			continue
+		}
+		if d.name == "" {
+			continue
+		}
+		if cid == uuid.Nil {
+			cid = e.x.namespaceContainer(prefix)
+		}
+		scope := maps.Clone(params)
+		if scope == nil {
+			scope = map[string]bool{}
+		}
+		for _, tp := range d.template {
+			scope[tp.Name] = true
+		}
+		written := qualify(prefix, qualify(d.qualifier, d.name))
+		full, kind, vis, doc := written, d.kind, visibility(d, owner, anon), d.doc
+		if owner == nil && d.qualifier != "" {
+			// Out of line: Foo::bar is a member of the type Foo.
+			if r, ok := res.resolve(d.qualifier); ok && isType(res.kind(r.Symbol)) {
+				full = qualify(r.Symbol, d.name)
+				if kind == "function" {
+					kind = "method"
+				}
+			}
+		}
+		var id uuid.UUID
+		var prior entry
+		paired := false
+		switch {
+		case isDeclaration(d):
+			id = e.x.frag.NewSymbolID(entry{rel: e.rel, key: key(written, d)}.id())
+		case owner == nil && (isCallable(d.kind) || d.kind == "variable"):
+			// A definition, of a declaration it may pair with.
+			if prior, paired = e.ix.match(prefix, d); paired {
+				full, vis = prior.full, prior.vis
+				if prior.d.kind != "function" {
+					kind = prior.d.kind
+				}
+				if doc == "" {
+					doc = prior.d.doc
+				}
+			}
+			id = e.x.frag.NewSymbolID(full)
+		default:
+			id = e.x.frag.NewSymbolID(full)
+		}
+		sym := ir.Symbol{
+			Id: id, ContainerId: cid, Name: d.name, FullName: full, Kind: kind,
+			Visibility: vis, OriginFileId: e.fid,
+			StartLine: d.line, StartCol: d.col, EndLine: d.endLine, EndCol: d.endCol, DocRaw: doc,
+		}
+		ex := extra{Modifiers: d.modifiers, Attributes: d.attributes, Linkage: d.linkage, Declaration: isDeclaration(d)}
+		for _, b := range d.bases {
+			ex.Bases = append(ex.Bases, baseExtra{Type: b.typ, Access: b.access, Virtual: b.virtual})
+		}
+		var dx *doxygen
+		sym.DocFmt, dx = formatDoc(doc)
+		ex.Doc = dx
+		if b, _ := json.Marshal(ex); string(b) != "{}" {
+			sym.ExtraJson = string(b)
+		}
+		e.x.frag.Symbols = append(e.x.frag.Symbols, sym)
+		if ownerID != nil {
+			e.x.frag.Members = append(e.x.frag.Members, ir.Member{Id: uuid.New(), OwnerSymbolId: *ownerID, ChildSymbolId: id, Order: i})
+		}
+		if paired {
+			e.pair(id, d, prior)
+		}
+		inner := res
+		if isType(d.kind) {
+			inner = res.within(full, nil)
+			e.relations(id, d, inner)
+		}
+		e.signature(id, ownerID, d, dx, inner, scope)
+		if len(d.children) > 0 && isType(d.kind) {
+			e.decls(d, &id, full, cid, d.children, inner, scope, anon)
+		}
+	}
+}
+
+// pair links a definition and the declaration it defines, both ways.
+func (e *emitter) pair(id uuid.UUID, d *decl, decl entry) {
+	declID := e.x.frag.SymbolID(decl.id())
+	type details struct {
+		File string `json:"file"`
+		Line int    `json:"line"`
+	}
+	b, _ := json.Marshal(details{File: decl.rel, Line: decl.d.line})
+	e.x.frag.Relations = append(e.x.frag.Relations, ir.Relation{SourceSymbolId: id, Relation: "defines", DstSymbolId: declID, DetailsJson: string(b)})
+	b, _ = json.Marshal(details{File: e.rel, Line: d.line})
+	e.x.frag.Relations = append(e.x.frag.Relations, ir.Relation{SourceSymbolId: declID, Relation: "declares", DstSymbolId: id, DetailsJson: string(b)})
+}
+
+// visibility returns a declaration's visibility: its access in a class,
+// public by default there; private at namespace scope for what is static
+// or in an anonymous namespace, which other files cannot see; else public.
+func visibility(d *decl, owner *decl, anon bool) string {
+	switch {
+	case owner != nil && d.access != "":
+		return d.access
+	case owner != nil:
+		return "public"
+	case anon, d.has("static") && d.qualifier == "":
+		return "private"
+	}
+	return "public"
+}
+
+// relations records the types a type derives from: its base classes, and
+// for Objective-C the protocols it conforms to. Base types the file does
+// not see are left to the typerefs.
+func (e *emitter) relations(id uuid.UUID, d *decl, res *resolver) {
+	for _, b := range d.bases {
+		r, ok := res.resolve(stripTemplateArgs(b.typ))
+		if !ok || r.Symbol == "" || isStdlib(r.Symbol) || res.kind(r.Symbol) == "" {
+			continue
+		}
+		kind := "extends"
+		if b.access == "protocol" && d.kind != "protocol" {
+			kind = "implements"
+		}
+		js, _ := json.Marshal(map[string]string{"file": e.rel, "type": b.typ})
+		e.x.frag.Relations = append(e.x.frag.Relations, ir.Relation{
+			SourceSymbolId: id, Relation: kind, DstSymbolId: e.x.frag.SymbolID(r.Symbol), DetailsJson: string(js),
+		})
+	}
+}
+
+// thrown is an exception a function documents.
+type thrown struct {
+	Type string `json:"type"`
+	Desc string `json:"desc,omitempty"`
+}
+
+// signature records the signature and type references of a declaration.
+// params holds the template parameters in scope.
+func (e *emitter) signature(id uuid.UUID, owner *uuid.UUID, d *decl, doc *doxygen, res *resolver, params map[string]bool) {
+	if d.sig == "" {
+		return
+	}
+	sig := ir.Signature{SymbolId: id, Text: d.sig}
+	type result struct {
+		Type string `json:"type"`
+	}
+	js := struct {
+		Params     []param     `json:"params,omitempty"`
+		Results    []result    `json:"results,omitempty"`
+		TypeParams []typeParam `json:"type_params,omitempty"`
+		Throws     []thrown    `json:"throws,omitempty"`
+	}{TypeParams: d.template}
+	switch d.kind {
+	case "function", "method", "constructor", "destructor", "operator":
+		js.Params = d.params
+		if js.Params == nil {
+			js.Params = []param{}
+		}
+		if d.returns != "" && d.returns != "void" {
+			js.Results = []result{{d.returns}}
+		}
+		if doc != nil {
+			for _, t := range doc.Throws {
+				js.Throws = append(js.Throws, thrown{Type: t.Name, Desc: t.Desc})
+			}
+		}
+		for i, pr := range d.params {
+			e.typerefs(res, id, fmt.Sprintf("param:%d", i), pr.Type, "", params)
+		}
+		if d.returns != "void" {
+			e.typerefs(res, id, "result:0", d.returns, "", params)
+		}
+		for i, t := range js.Throws {
+			e.typerefs(res, id, fmt.Sprintf("throws:%d", i), t.Type, "class", params)
+		}
+	case "class", "struct", "union", "protocol":
+		bases, impls := 0, 0
+		for _, b := range d.bases {
+			if b.access == "protocol" {
+				e.typerefs(res, id, fmt.Sprintf("implements:%d", impls), b.typ, "protocol", params)
+				impls++
+			} else {
+				e.typerefs(res, id, fmt.Sprintf("base:%d", bases), b.typ, "class", params)
+				bases++
+			}
+		}
+	case "enum", "typedef", "typealias":
+		e.typerefs(res, id, "type", d.typ, "", params)
+	case "field", "property":
+		if owner != nil {
+			e.typerefs(res, *owner, "field:"+d.name, d.typ, "", params)
+		} else {
+			e.typerefs(res, id, "type", d.typ, "", params)
+		}
+	case "variable":
+		e.typerefs(res, id, "type", d.typ, "", params)
+	}
+	if b, _ := json.Marshal(js); string(b) != "{}" {
+		sig.Json = string(b)
+	}
+	e.x.frag.Signatures = append(e.x.frag.Signatures, sig)
+}
+
+func (e *emitter) typerefs(res *resolver, owner uuid.UUID, slot, typ, kind string, params map[string]bool) {
+	if typ == "" {
+		return
+	}
+	for i, r := range res.refs(typ, params) {
+		r.Type = kind
+		b, _ := json.Marshal(r)
+		e.x.frag.Typerefs = append(e.x.frag.Typerefs, ir.Typeref{Id: uuid.New(), OwnerSymbolId: owner, Slot: slot, Json: string(b), Order: i})
+	}
+}
@@ -0,0 +1,436 @@
+package cpp
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/ChaseHampton/cargoworker/internal/ir"
+	"github.com/ChaseHampton/cargoworker/internal/pack/packtest"
+)
+
+var tree = map[string]string{
+	"CMakeLists.txt": `cmake_minimum_required(VERSION 3.20)
+project(Geo VERSION 1.2.0 DESCRIPTION "Shapes and such" LANGUAGES C CXX)
+`,
+	"compile_commands.json": `[
+  {"directory": "/home/ci/geo/build", "file": "/home/ci/geo/src/shapes.cpp",
+   "command": "c++ -I/home/ci/geo/include -DGEO_FAST -DGEO_LEVEL=2 -c ../src/shapes.cpp"},
+  {"directory": "/home/ci/geo/build", "file": "../c/util.c",
+   "arguments": ["cc", "-I", "../c", "-UNDEBUG", "-c", "../c/util.c"]}
+]
+`,
+	"include/geo/shapes.hpp": `#ifndef GEO_SHAPES_HPP
+#define GEO_SHAPES_HPP
+
+#include <vector>
+#include "geo/point.hpp"
+
+/// The most sides a polygon has.
+#define GEO_MAX_SIDES 64
+
+namespace geo {
+
+/**
+ * @brief A shape.
+ *
+ * Shapes are \b immutable.
+ */
+class Shape {
+public:
+    virtual ~Shape() = default;
+    /// The area.
+    /// @return the area in square units
+    virtual double area() const;
+    static int count;
+    int id = 0; ///< The id.
+protected:
+    explicit Shape(int id);
+private:
+    std::vector<Point> points_;
+};
+
+/// A polygon of N sides.
+/// @tparam T the coordinate type
+template <typename T, int N = 3>
+struct Poly : public Shape {
+    T sides[N];
+    bool operator==(const Poly& o) const;
+};
+
+enum class Color : unsigned char { Red, Green = 2 };
+
+using Shapes = std::vector<Shape*>;
+
+/**
+ * Sums the areas of shapes.
+ * @param[in] shapes the shapes
+ * @param n how many
+ * @throws std::invalid_argument when n is 0
+ */
+double total(const Shape *shapes[], size_t n);
+double total(const Shapes &shapes);
+
+#if GEO_LEVEL > 1
+int fast_path();
+#else
+int slow_path();
+#endif
+
+namespace {
+int hidden();
+}
+
+}  // namespace geo
+
+#endif
+`,
+	"include/geo/point.hpp": `#pragma once
+namespace geo {
+struct Point { double x, y; };
+}
+`,
+	"src/shapes.cpp": `#include "geo/shapes.hpp"
+#include <cmath>
+
+namespace geo {
+
+int Shape::count = 0;
+
+Shape::Shape(int id) : id(id) {}
+
+double Shape::area() const { return 0; }
+
+template <typename T, int N>
+bool Poly<T, N>::operator==(const Poly& o) const { return true; }
+
+double total(const Shapes &shapes) { return 0; }
+
+#ifdef GEO_FAST
+int fast_path() { return 1; }
+#endif
+
+}  // namespace geo
+
+static int helper(void) { return 0; }
+`,
+	"c/util.h": `#ifndef UTIL_H
+#define UTIL_H
+
+/** Clamps v to [lo, hi]. */
+int clamp(int v, int lo, int hi);
+extern const char *util_name;
+
+typedef struct {
+    int len;
+} buf_t;
+
+#ifndef NDEBUG
+void debug_dump(const buf_t *b);
+#endif
+
+#endif
+`,
+	"c/util.c": `#include "util.h"
+
+#define SQUARE(x) ((x) * (x))
+
+const char *util_name = "util";
+
+int clamp(int value, int lo, int hi) {
+    return value < lo ? lo : value > hi ? hi : value;
+}
+
+void debug_dump(const buf_t *b) {}
+`,
+	"objc/Greeter.m": `#import <Foundation/Foundation.h>
+
+/// Says hello.
+@protocol Greeting
+- (NSString *)greet:(NSString *)name times:(int)n;
+@end
+
+@interface Greeter : NSObject <Greeting>
+@property (nonatomic, copy) NSString *title;
+- (void)reset;
+@end
+
+@implementation Greeter
+- (void)reset {}
+- (NSString *)greet:(NSString *)name times:(int)n { return name; }
+@end
+`,
+}
+
+// languages are the language ids of the tree's files, as the language
+// table gives them.
+var languages = map[string]string{".h": "c", ".c": "c", ".hpp": "cpp", ".cpp": "cpp", ".txt": "cpp", ".m": "objc"}
+
+func TestExtract(t *testing.T) {
+	f := packtest.ExtractTree(t, tree, packtest.ByExt(languages))
+
+	containers := map[string]ir.Container{}
+	byID := map[uuid.UUID]string{}
+	for _, c := range f.Containers {
+		byID[c.Id] = c.FullName
+	}
+	for _, c := range f.Containers {
+		key := byID[c.ParentId] + "/" + c.FullName
+		if _, dup := containers[key]; dup {
+			t.Errorf("duplicate container %s", key)
+		}
+		containers[key] = c
+	}
+	if p := containers["/Geo"]; p.Kind != "project" || p.VersionTag != "1.2.0" || p.DocRaw != "Shapes and such" ||
+		p.ExtraJson != `{"file":"CMakeLists.txt","dir":".","build":"cmake","languages":["C","CXX"],"compile_commands":"compile_commands.json"}` {
+		t.Errorf("project = %+v", p)
+	}
+	for _, key := range []string{"Geo/geo", "Geo/" + globalNamespace} {
+		if c := containers[key]; c.Kind != "namespace" {
+			t.Errorf("container %s = %+v", key, c)
+		}
+	}
+	if len(f.Containers) != 3 {
+		t.Errorf("%d containers", len(f.Containers))
+	}
+
+	// Declarations and definitions share names; key them by file too.
+	files := map[uuid.UUID]string{}
+	for _, fl := range f.Files {
+		files[fl.Id] = fl.Path
+	}
+	syms := map[string]ir.Symbol{}
+	names := map[uuid.UUID]string{}
+	for _, s := range f.Symbols {
+		key := s.FullName + "@" + path.Base(files[s.OriginFileId])
+		if _, dup := syms[key]; dup {
+			key += fmt.Sprintf(":%d", s.StartLine) // an overload
+		}
+		syms[key] = s
+		names[s.Id] = key
+	}
+	for name, want := range map[string]string{
+		"GEO_MAX_SIDES@shapes.hpp":         "macro public",
+		"geo::Shape@shapes.hpp":            "class public",
+		"geo::Shape::~Shape@shapes.hpp":    "destructor public",
+		"geo::Shape::area@shapes.hpp":      "method public",
+		"geo::Shape::count@shapes.hpp":     "field public",
+		"geo::Shape::id@shapes.hpp":        "field public",
+		"geo::Shape::Shape@shapes.hpp":     "constructor protected",
+		"geo::Shape::points_@shapes.hpp":   "field private",
+		"geo::Poly@shapes.hpp":             "struct public",
+		"geo::Poly::sides@shapes.hpp":      "field public",
+		"geo::Poly::operator==@shapes.hpp": "operator public",
+		"geo::Color@shapes.hpp":            "enum public",
+		"geo::Color::Green@shapes.hpp":     "enum_member public",
+		"geo::Shapes@shapes.hpp":           "typealias public",
+		"geo::fast_path@shapes.hpp":        "function public",
+		"geo::slow_path@shapes.hpp":        "",
+		"geo::hidden@shapes.hpp":           "function private",
+		"geo::Point@point.hpp":             "struct public",
+		"geo::Shape::count@shapes.cpp":     "field public",
+		"geo::Shape::Shape@shapes.cpp":     "constructor protected",
+		"geo::Shape::area@shapes.cpp":      "method public",
+		"geo::Poly::operator==@shapes.cpp": "operator public",
+		"geo::total@shapes.cpp":            "function public",
+		"geo::fast_path@shapes.cpp":        "function public",
+		"helper@shapes.cpp":                "function private",
+		"clamp@util.h":                     "function public",
+		"util_name@util.h":                 "variable public",
+		"buf_t@util.h":                     "struct public",
+		"debug_dump@util.h":                "function public",
+		"SQUARE@util.c":                    "macro private",
+		"UTIL_H@util.h":                    "",
+		"clamp@util.c":                     "function public",
+		"util_name@util.c":                 "variable public",
+		"Greeting@Greeter.m":               "protocol public",
+		"Greeting::greet:times:@Greeter.m": "method public",
+		"Greeter@Greeter.m":                "class public",
+		"Greeter::title@Greeter.m":         "property public",
+	} {
+		if got := strings.TrimSpace(syms[name].Kind + " " + syms[name].Visibility); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	if n := len(f.Symbols); n != 45 {
+		t.Errorf("%d symbols", n)
+	}
+
+	shape := syms["geo::Shape@shapes.hpp"]
+	if shape.StartLine != 17 || shape.StartCol != 7 || shape.EndLine != 29 ||
+		shape.DocFmt != "A shape.\n\nShapes are **immutable**." ||
+		shape.ExtraJson != `{"doc":{"brief":"A shape.","details":"Shapes are **immutable**."}}` {
+		t.Errorf("Shape = %+v", shape)
+	}
+	if a := syms["geo::Shape::area@shapes.cpp"]; a.DocFmt != "The area.\n\nReturns:\n    the area in square units" {
+		t.Errorf("area definition doc = %q", a.DocFmt)
+	}
+	if tl := syms["geo::total@shapes.hpp"]; tl.DocFmt != "Sums the areas of shapes.\n\nParameters:\n    shapes [in]: the shapes\n    n: how many\n\n"+
+		"Throws:\n    std::invalid_argument: when n is 0" {
+		t.Errorf("total doc = %q", tl.DocFmt)
+	}
+	if c := syms["geo::Shape::count@shapes.hpp"]; c.ExtraJson != `{"modifiers":["static"],"declaration":true}` {
+		t.Errorf("count extra = %s", c.ExtraJson)
+	}
+	if m := syms["GEO_MAX_SIDES@shapes.hpp"]; m.DocFmt != "The most sides a polygon has." {
+		t.Errorf("macro doc = %q", m.DocFmt)
+	}
+
+	var rels []string
+	for _, r := range f.Relations {
+		rels = append(rels, names[r.SourceSymbolId]+" "+r.Relation+" "+names[r.DstSymbolId])
+	}
+	sort.Strings(rels)
+	if got, want := strings.Join(rels, "\n"), strings.Join([]string{
+		"Greeter::reset@Greeter.m declares Greeter::reset@Greeter.m:14",
+		"Greeter::reset@Greeter.m:14 defines Greeter::reset@Greeter.m",
+		"Greeter@Greeter.m implements Greeting@Greeter.m",
+		"clamp@util.c defines clamp@util.h",
+		"clamp@util.h declares clamp@util.c",
+		"debug_dump@util.c defines debug_dump@util.h",
+		"debug_dump@util.h declares debug_dump@util.c",
+		"geo::Poly::operator==@shapes.cpp defines geo::Poly::operator==@shapes.hpp",
+		"geo::Poly::operator==@shapes.hpp declares geo::Poly::operator==@shapes.cpp",
+		"geo::Poly@shapes.hpp extends geo::Shape@shapes.hpp",
+		"geo::Shape::Shape@shapes.cpp defines geo::Shape::Shape@shapes.hpp",
+		"geo::Shape::Shape@shapes.hpp declares geo::Shape::Shape@shapes.cpp",
+		"geo::Shape::area@shapes.cpp defines geo::Shape::area@shapes.hpp",
+		"geo::Shape::area@shapes.hpp declares geo::Shape::area@shapes.cpp",
+		"geo::Shape::count@shapes.cpp defines geo::Shape::count@shapes.hpp",
+		"geo::Shape::count@shapes.hpp declares geo::Shape::count@shapes.cpp",
+		"geo::fast_path@shapes.cpp defines geo::fast_path@shapes.hpp",
+		"geo::fast_path@shapes.hpp declares geo::fast_path@shapes.cpp",
+		"geo::total@shapes.cpp defines geo::total@shapes.hpp:50",
+		"geo::total@shapes.hpp:50 declares geo::total@shapes.cpp",
+		"util_name@util.c defines util_name@util.h",
+		"util_name@util.h declares util_name@util.c",
+	}, "\n"); got != want {
+		t.Errorf("relations =\n%s\nwant\n%s", got, want)
+	}
+	for _, r := range f.Relations {
+		if names[r.SourceSymbolId] == "clamp@util.c" && r.DetailsJson != `{"file":"c/util.h","line":5}` {
+			t.Errorf("clamp defines details = %s", r.DetailsJson)
+		}
+	}
+
+	members := map[string]string{}
+	for _, m := range f.Members {
+		members[names[m.ChildSymbolId]] = fmt.Sprintf("%s %d", names[m.OwnerSymbolId], m.Order)
+	}
+	for child, owner := range map[string]string{
+		"geo::Shape::~Shape@shapes.hpp":  "geo::Shape@shapes.hpp 0",
+		"geo::Shape::points_@shapes.hpp": "geo::Shape@shapes.hpp 5",
+		"geo::Color::Green@shapes.hpp":   "geo::Color@shapes.hpp 1",
+		"Greeter::title@Greeter.m":       "Greeter@Greeter.m 0",
+		"geo::Shape::area@shapes.cpp":    "",
+	} {
+		if members[child] != owner {
+			t.Errorf("owner of %s = %q, want %q", child, members[child], owner)
+		}
+	}
+
+	sigs := map[uuid.UUID]ir.Signature{}
+	for _, s := range f.Signatures {
+		sigs[s.SymbolId] = s
+	}
+	for name, want := range map[string]string{
+		"geo::Poly@shapes.hpp":             "template <typename T, int N = 3> struct Poly : public Shape",
+		"geo::Shape::Shape@shapes.hpp":     "explicit Shape(int id)",
+		"geo::Shape::count@shapes.cpp":     "int Shape::count",
+		"geo::Color@shapes.hpp":            "enum class Color : unsigned char",
+		"Greeting::greet:times:@Greeter.m": "- (NSString *)greet:(NSString *)name times:(int)n",
+		"SQUARE@util.c":                    "#define SQUARE(x) ((x) * (x))",
+		"util_name@util.h":                 "extern const char *util_name",
+	} {
+		if got := sigs[syms[name].Id].Text; got != want {
+			t.Errorf("%s sig = %s, want %s", name, got, want)
+		}
+	}
+	for name, want := range map[string]string{
+		"geo::total@shapes.hpp": `{"params":[{"name":"shapes","type":"const Shape *[]"},{"name":"n","type":"size_t"}],"results":[{"type":"double"}],` +
+			`"throws":[{"type":"std::invalid_argument","desc":"when n is 0"}]}`,
+		"geo::Poly::operator==@shapes.cpp": `{"params":[{"name":"o","type":"const Poly\u0026"}],"results":[{"type":"bool"}],` +
+			`"type_params":[{"name":"T","kind":"typename"},{"name":"N","kind":"int"}]}`,
+		"SQUARE@util.c":         `{"params":[{"name":"x"}]}`,
+		"geo::Shape@shapes.hpp": "",
+	} {
+		if got := sigs[syms[name].Id].Json; got != want {
+			t.Errorf("%s sig json = %s\nwant %s", name, got, want)
+		}
+	}
+
+	refs := map[string][]string{}
+	for _, tr := range f.Typerefs {
+		key := names[tr.OwnerSymbolId] + " " + tr.Slot
+		refs[key] = append(refs[key], tr.Json)
+	}
+	for key, want := range map[string]string{
+		"geo::Shape@shapes.hpp field:points_": `{"symbol":"std::vector","text":"std::vector\u003cPoint\u003e"} {"symbol":"geo::Point","text":"std::vector\u003cPoint\u003e"}`,
+		"geo::Poly@shapes.hpp base:0":         `{"symbol":"geo::Shape","type":"class","text":"Shape"}`,
+		"geo::Poly@shapes.hpp field:sides":    "",
+		"geo::total@shapes.cpp param:0":       `{"symbol":"geo::Shapes","text":"const Shapes \u0026"}`,
+		"debug_dump@util.h param:0":           `{"symbol":"buf_t","text":"const buf_t *"}`,
+		"Greeter@Greeter.m implements:0":      `{"symbol":"Greeting","type":"protocol","text":"Greeting"}`,
+	} {
+		if got := strings.Join(refs[key], " "); got != want {
+			t.Errorf("typerefs %s = %s, want %s", key, got, want)
+		}
+	}
+
+	var imports []string
+	for _, im := range f.Imports {
+		imports = append(imports, byID[im.ContainerId]+" "+im.Target+" "+im.DetailsJson)
+	}
+	sort.Strings(imports)
+	if got, want := strings.Join(imports, "\n"), strings.Join([]string{
+		`(global) Foundation/Foundation.h {"file":"objc/Greeter.m","line":1,"system":true,"stdlib":true}`,
+		`(global) util.h {"file":"c/util.c","line":1,"resolved":"c/util.h"}`,
+		`geo cmath {"file":"src/shapes.cpp","line":2,"system":true,"stdlib":true}`,
+		`geo geo/point.hpp {"file":"include/geo/shapes.hpp","line":5,"resolved":"include/geo/point.hpp"}`,
+		`geo geo/shapes.hpp {"file":"src/shapes.cpp","line":1,"resolved":"include/geo/shapes.hpp"}`,
+		`geo vector {"file":"include/geo/shapes.hpp","line":4,"system":true,"stdlib":true}`,
+	}, "\n"); got != want {
+		t.Errorf("imports =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestParseDoc(t *testing.T) {
+	for raw, want := range map[string]string{
+		"/// The area.\n/// @return the area": "The area.\n\nReturns:\n    the area",
+		"///< The id.":                        "The id.",
+		"/*! \\brief Short.\n *\n *  Long, with \\c code and \\e stress. */":                              "Short.\n\nLong, with `code` and *stress*.",
+		"//! First.\n//!\n//! \\code\n//! int x = 1;\n//!\n//! x++;\n//! \\endcode\n//! - one\n//! - two": "First.\n\n```\nint x = 1;\n\nx++;\n```\n\n- one\n- two",
+		"/** @deprecated Use add2.\n * @retval 0 on failure\n * @see sub */":                              "Deprecated. Use add2.\n\nReturn values:\n    0: on failure\n\nSee also:\n    sub",
+		// A block command within a line ends the brief.
+		"/// Opens a file. @param path where from\n/// @return the handle": "Opens a file.\n\nParameters:\n    path: where from\n\nReturns:\n    the handle",
+		"/** \\brief Opens. \\param[in] p the path \\return 0 */":          "Opens.\n\nParameters:\n    p [in]: the path\n\nReturns:\n    0",
+		"/// Mail me@param.org, or see @Override.":                         "Mail me@param.org, or see @Override.",
+	} {
+		if got, _ := formatDoc(raw); got != want {
+			t.Errorf("formatDoc(%q) = %q, want %q", raw, got, want)
+		}
+	}
+}
+
+func TestLex(t *testing.T) {
+	src := "#include <a.h>\n#define N 2\n/// Doc.\nauto s = R\"x(a \" b)x\" /* c */ + u8\"\\\"\";\n#if N > 1 && defined(N)\nint a;\n#elif 1\nint b;\n#else\n{\n#endif\nchar c = '\\'';\n"
+	l := lex(src, nil)
+	var got []string
+	for _, tok := range l.out {
+		got = append(got, tok.Text)
+	}
+	want := []string{"/// Doc.", "auto", "s", "=", `R"x(a " b)x"`, "+", `u8"\""`, ";", "int", "a", ";", "char", "c", "=", `'\''`, ";"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("tokens = %q, want %q", got, want)
+	}
+	if len(l.includes) != 1 || l.includes[0] != (include{path: "a.h", angle: true, line: 1}) {
+		t.Errorf("includes = %+v", l.includes)
+	}
+	if len(l.macros) != 1 || l.macros[0].name != "N" || l.macros[0].body != "2" {
+		t.Errorf("macros = %+v", l.macros)
+	}
+}
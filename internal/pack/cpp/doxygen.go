@@ -0,0 +1,414 @@
+package cpp
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/ChaseHampton/cargoworker/internal/pack/outline"
+)
+
+// doxygen is a parsed Doxygen comment: its brief and detailed
+// descriptions and the commands that describe the symbol.
+type doxygen struct {
+	Brief      string     `json:"brief,omitempty"`
+	Details    string     `json:"details,omitempty"`
+	Params     []docParam `json:"params,omitempty"`
+	TypeParams []docParam `json:"type_params,omitempty"`
+	Returns    string     `json:"returns,omitempty"`
+	RetVals    []docParam `json:"retvals,omitempty"`
+	Throws     []docParam `json:"throws,omitempty"`
+	Notes      []string   `json:"notes,omitempty"`
+	Warnings   []string   `json:"warnings,omitempty"`
+	Deprecated *string    `json:"deprecated,omitempty"` // set, maybe empty, when deprecated
+	Since      string     `json:"since,omitempty"`
+	See        []string   `json:"see,omitempty"`
+	Tags       []docTag   `json:"tags,omitempty"` // the other block commands, in order
+}
+
+type docParam struct {
+	Name string `json:"name"`
+	Dir  string `json:"dir,omitempty"` // in, out or in,out
+	Desc string `json:"desc,omitempty"`
+}
+
+type docTag struct {
+	Tag  string `json:"tag"`
+	Text string `json:"text,omitempty"`
+}
+
+// cleanComment strips the markers of a doc comment: the /// or //! of
+// each line, or the /** or /*! and */ of a block and the leading asterisks
+// of its lines, with the < of a trailing comment.
+func cleanComment(raw string) string {
+	raw = strings.ReplaceAll(raw, "\r\n", "\n")
+	var lines []string
+	if strings.HasPrefix(raw, "/*") {
+		s := strings.TrimSuffix(raw[3:], "*/")
+		s = strings.TrimPrefix(s, "<")
+		for i, l := range strings.Split(s, "\n") {
+			t := strings.TrimLeft(l, " \t")
+			if strings.HasPrefix(t, "*") {
+				t = strings.TrimPrefix(strings.TrimLeft(t, "*"), " ")
+			} else if i > 0 {
+				t = l // a line without the asterisk keeps its indentation
+			}
+			lines = append(lines, strings.TrimRight(t, " \t"))
+		}
+	} else {
+		for _, l := range strings.Split(raw, "\n") {
+			l = strings.TrimLeft(l, " \t")
+			if len(l) >= 3 {
+				l = l[3:]
+			}
+			l = strings.TrimPrefix(l, "<")
+			lines = append(lines, strings.TrimRight(strings.TrimPrefix(l, " "), " \t"))
+		}
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// command returns the Doxygen command a line starts with, \name or @name,
+// and the rest of the line.
+func command(line string) (string, string, bool) {
+	t := strings.TrimSpace(line)
+	if len(t) < 2 || t[0] != '\\' && t[0] != '@' || !isIdentStart(t[1]) {
+		return "", "", false
+	}
+	j := 1
+	for j < len(t) && isIdent(t[j]) {
+		j++
+	}
+	name, rest := t[1:j], t[j:]
+	if strings.HasPrefix(rest, "[") {
+		// \param[in]
+		if end := strings.IndexByte(rest, ']'); end > 0 {
+			name += rest[:end+1]
+			rest = rest[end+1:]
+		}
+	}
+	return name, strings.TrimSpace(rest), true
+}
+
+// inline are the commands that format a word or span within text; the
+// others start a paragraph.
+var inline = outline.Set(`a b c e em p ref link endlink n copydoc f anchor`)
+
+// blocks are the block commands that also start a paragraph within a line,
+// as in "Opens a file. @param path where": the ones with a meaning of their
+// own, so a stray @word or \path in prose is left alone.
+var blocks = outline.Set(`brief short details param tparam return returns result retval throw throws exception
+	note remark remarks warning attention deprecated since see sa pre post invariant todo bug`)
+
+// blockAt returns the offset of the first block command inside a line, after
+// its start, or -1.
+func blockAt(line string) int {
+	for i := 1; i < len(line); i++ {
+		if line[i] != '\\' && line[i] != '@' || line[i-1] != ' ' && line[i-1] != '\t' {
+			continue
+		}
+		j := i + 1
+		for j < len(line) && isIdent(line[j]) {
+			j++
+		}
+		if blocks[line[i+1:j]] && (j == len(line) || line[j] == ' ' || line[j] == '\t' || line[j] == '[') {
+			return i
+		}
+	}
+	return -1
+}
+
+// parseDoc splits a cleaned comment into its descriptions and commands.
+// A paragraph ends at a blank line or the next block command, even one
+// within a line; without \brief, the first paragraph is the brief
+// description.
+func parseDoc(text string) *doxygen {
+	d := &doxygen{}
+	var desc []string
+	var tag string
+	var body []string
+	code := false
+	flush := func() {
+		if tag != "" {
+			d.command(tag, strings.TrimSpace(strings.Join(body, "\n")))
+		}
+		tag, body = "", nil
+	}
+	lines := strings.Split(text, "\n")
+	for i := 0; i < len(lines); i++ {
+		l := lines[i]
+		if j := blockAt(l); j > 0 && !code {
+			l, lines = strings.TrimRight(l[:j], " \t"), slices.Insert(lines, i+1, l[j:])
+		}
+		t := strings.TrimSpace(l)
+		name, rest, ok := command(t)
+		switch {
+		case ok && (name == "code" || name == "verbatim") || strings.HasPrefix(t, "```") && !code:
+			code = true
+		case ok && (name == "endcode" || name == "endverbatim") || strings.HasPrefix(t, "```") && code:
+			code = false
+		case code:
+		case ok && !inline[name]:
+			flush()
+			tag, body = name, []string{rest}
+			continue
+		case t == "" && tag != "":
+			flush()
+			continue
+		}
+		if tag != "" {
+			body = append(body, l)
+		} else {
+			desc = append(desc, l)
+		}
+	}
+	flush()
+	text = strings.TrimSpace(strings.Join(desc, "\n"))
+	if d.Brief == "" {
+		brief, rest, _ := strings.Cut(text, "\n\n")
+		d.Brief, text = render(brief), rest
+	}
+	if details := render(strings.TrimSpace(text)); details != "" {
+		d.Details = joinPara(d.Details, details)
+	}
+	return d
+}
+
+func joinPara(a, b string) string {
+	switch {
+	case a == "":
+		return b
+	case b == "":
+		return a
+	}
+	return a + "\n\n" + b
+}
+
+// command records one block command.
+func (d *doxygen) command(name, text string) {
+	dir := ""
+	if i := strings.IndexByte(name, '['); i >= 0 {
+		name, dir = name[:i], strings.ReplaceAll(strings.Trim(name[i:], "[]"), " ", "")
+	}
+	first, rest, _ := strings.Cut(strings.TrimSpace(text), " ")
+	rest = render(strings.TrimSpace(rest))
+	flat := render(text)
+	switch name {
+	case "brief", "short":
+		d.Brief = joinPara(d.Brief, flat)
+	case "details":
+		d.Details = joinPara(d.Details, flat)
+	case "param":
+		for _, n := range strings.Split(first, ",") {
+			d.Params = append(d.Params, docParam{Name: n, Dir: dir, Desc: rest})
+		}
+	case "tparam":
+		d.TypeParams = append(d.TypeParams, docParam{Name: first, Desc: rest})
+	case "return", "returns", "result":
+		d.Returns = flat
+	case "retval":
+		d.RetVals = append(d.RetVals, docParam{Name: first, Desc: rest})
+	case "throw", "throws", "exception":
+		d.Throws = append(d.Throws, docParam{Name: first, Desc: rest})
+	case "note", "remark", "remarks":
+		d.Notes = append(d.Notes, flat)
+	case "warning", "attention":
+		d.Warnings = append(d.Warnings, flat)
+	case "deprecated":
+		d.Deprecated = &flat
+	case "since":
+		d.Since = flat
+	case "see", "sa":
+		d.See = append(d.See, flat)
+	default:
+		d.Tags = append(d.Tags, docTag{Tag: name, Text: flat})
+	}
+}
+
+// render turns the inline commands of text into Markdown: \c and \p as
+// code, \b as bold, \e, \em and \a as emphasis, code blocks as fences.
+// Lines of a paragraph are joined.
+func render(text string) string {
+	var paras []string
+	var cur []string
+	var fence []string
+	inCode := false
+	flush := func() {
+		if len(cur) > 0 {
+			paras = append(paras, strings.Join(cur, " "))
+			cur = nil
+		}
+	}
+	for _, l := range strings.Split(text, "\n") {
+		t := strings.TrimSpace(l)
+		name, _, ok := command(t)
+		switch {
+		case !inCode && (ok && (name == "code" || name == "verbatim") || strings.HasPrefix(t, "```")):
+			flush()
+			inCode = true
+			fence = []string{"```"}
+			continue
+		case inCode && (ok && (name == "endcode" || name == "endverbatim") || strings.HasPrefix(t, "```")):
+			inCode = false
+			paras = append(paras, strings.Join(append(fence, "```"), "\n"))
+			continue
+		case inCode:
+			fence = append(fence, strings.TrimRight(l, " \t"))
+			continue
+		case t == "":
+			flush()
+			continue
+		case strings.HasPrefix(t, "- ") || strings.HasPrefix(t, "-# ") || strings.HasPrefix(t, "* "):
+			flush()
+			paras = append(paras, "- "+inlines(strings.TrimSpace(t[strings.IndexByte(t, ' '):])))
+			continue
+		}
+		cur = append(cur, inlines(t))
+	}
+	if inCode {
+		paras = append(paras, strings.Join(append(fence, "```"), "\n"))
+	}
+	flush()
+	// Consecutive list items form one list.
+	var out strings.Builder
+	for i, p := range paras {
+		if i > 0 {
+			if strings.HasPrefix(p, "- ") && strings.HasPrefix(paras[i-1], "- ") {
+				out.WriteString("\n")
+			} else {
+				out.WriteString("\n\n")
+			}
+		}
+		out.WriteString(p)
+	}
+	return out.String()
+}
+
+// inlines renders the inline commands of a line.
+func inlines(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c != '\\' && c != '@') || i+1 >= len(s) || !isIdentStart(s[i+1]) || i > 0 && isIdent(s[i-1]) {
+			b.WriteByte(c)
+			continue
+		}
+		j := i + 1
+		for j < len(s) && isIdent(s[j]) {
+			j++
+		}
+		name := s[i+1 : j]
+		if !inline[name] {
+			b.WriteByte(c)
+			continue
+		}
+		// The word the command applies to.
+		k := j
+		for k < len(s) && s[k] == ' ' {
+			k++
+		}
+		w := k
+		for w < len(s) && s[w] != ' ' && !(strings.IndexByte(".,;:!?)", s[w]) >= 0 && (w+1 == len(s) || s[w+1] == ' ')) {
+			w++
+		}
+		word := s[k:w]
+		switch name {
+		case "c", "p":
+			b.WriteString("`" + word + "`")
+		case "ref", "link", "copydoc":
+			b.WriteString("`" + word + "`")
+		case "b":
+			b.WriteString("**" + word + "**")
+		case "e", "em", "a":
+			b.WriteString("*" + word + "*")
+		case "n":
+			b.WriteString("\n")
+			w = j
+		default:
+			w = j // \endlink, \f, \anchor: dropped
+		}
+		i = w - 1
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// format renders the comment as text: the descriptions, then a section for
+// each kind of command.
+func (d *doxygen) format() string {
+	var b strings.Builder
+	para := func(s string) {
+		if s == "" {
+			return
+		}
+		if b.Len() > 0 {
+			b.WriteString("\n\n")
+		}
+		b.WriteString(s)
+	}
+	indent := func(s string) string { return "    " + strings.ReplaceAll(s, "\n", "\n    ") }
+	section := func(title string, items []docParam) {
+		var lines []string
+		for _, it := range items {
+			name := it.Name
+			if it.Dir != "" {
+				name += " [" + it.Dir + "]"
+			}
+			switch {
+			case name == "":
+				lines = append(lines, indent(it.Desc))
+			case it.Desc == "":
+				lines = append(lines, indent(name))
+			default:
+				lines = append(lines, indent(name+": "+it.Desc))
+			}
+		}
+		if len(lines) > 0 {
+			para(title + ":\n" + strings.Join(lines, "\n"))
+		}
+	}
+	texts := func(items []string) []docParam {
+		var out []docParam
+		for _, s := range items {
+			out = append(out, docParam{Desc: s})
+		}
+		return out
+	}
+	para(d.Brief)
+	para(d.Details)
+	if d.Deprecated != nil {
+		para(strings.TrimSpace("Deprecated. " + *d.Deprecated))
+	}
+	section("Parameters", d.Params)
+	section("Template parameters", d.TypeParams)
+	if d.Returns != "" {
+		section("Returns", []docParam{{Desc: d.Returns}})
+	}
+	section("Return values", d.RetVals)
+	section("Throws", d.Throws)
+	section("Notes", texts(d.Notes))
+	section("Warnings", texts(d.Warnings))
+	if d.Since != "" {
+		section("Since", []docParam{{Desc: d.Since}})
+	}
+	var see []docParam
+	for _, s := range d.See {
+		see = append(see, docParam{Name: s})
+	}
+	section("See also", see)
+	return b.String()
+}
+
+// formatDoc cleans and parses a raw doc comment, returning the doc_fmt
+// text and the parsed doc.
+func formatDoc(raw string) (string, *doxygen) {
+	if strings.TrimSpace(raw) == "" {
+		return "", nil
+	}
+	d := parseDoc(cleanComment(raw))
+	return d.format(), d
+}
@@ -0,0 +1,322 @@
+package cpp
+
+import (
+	"strings"
+
+	"github.com/ChaseHampton/cargoworker/internal/pack/outline"
+)
+
+// builtins are the fundamental types, the words of type expressions, and
+// the fixed-width and Objective-C scalar types, which type references
+// leave out.
+var builtins = outline.Set(`
+	void bool char wchar_t char8_t char16_t char32_t short int long signed unsigned float double
+	auto const volatile restrict __restrict _Bool _Complex struct class union enum typename template
+	size_t ssize_t ptrdiff_t intptr_t uintptr_t intmax_t uintmax_t nullptr_t max_align_t
+	int8_t int16_t int32_t int64_t uint8_t uint16_t uint32_t uint64_t off_t FILE va_list
+	id SEL IMP BOOL Class instancetype NSInteger NSUInteger CGFloat`)
+
+// stdNames are the names of the C++ standard library that a using
+// namespace std brings in.
+var stdNames = outline.Set(`
+	string string_view wstring u16string u32string vector array deque list forward_list map multimap
+	set multiset unordered_map unordered_set unordered_multimap unordered_multiset stack queue
+	priority_queue pair tuple optional variant any function shared_ptr unique_ptr weak_ptr
+	basic_string span bitset complex chrono thread mutex lock_guard unique_lock condition_variable
+	atomic future promise exception runtime_error logic_error invalid_argument out_of_range
+	ostream istream iostream ostringstream istringstream stringstream ifstream ofstream fstream
+	initializer_list byte filesystem path error_code`)
+
+// isStdlib reports whether a qualified name is in the C++ standard
+// library.
+func isStdlib(name string) bool {
+	return name == "std" || strings.HasPrefix(name, "std::")
+}
+
+// ref is a name a type refers to: qualified when it resolves, else as
+// written.
+type ref struct {
+	Symbol string `json:"symbol,omitempty"`
+	Name   string `json:"name,omitempty"`
+	Type   string `json:"type,omitempty"`
+	Text   string `json:"text,omitempty"` // the whole type
+}
+
+// resolver resolves the type names of a file to qualified names: those of
+// the types the file and the headers it includes declare, looked up from
+// the scopes the name is used in, then in the namespaces using directives
+// import.
+type resolver struct {
+	scopes  []string          // the enclosing classes and namespaces, innermost first
+	usings  []string          // using namespace N
+	aliases map[string]string // using N::name -> N::name
+	types   map[string]string // full name -> kind
+}
+
+// refs returns the names a type refers to, in order, leaving out builtins
+// and the template parameters in scope.
+func (r *resolver) refs(typ string, params map[string]bool) []ref {
+	var out []ref
+	seen := map[string]bool{}
+	toks := lex(typ, nil).out
+	angles := 0
+	for i := 0; i < len(toks); i++ {
+		t := toks[i]
+		if t.Is("<") {
+			angles++
+		} else if t.Is(">") && angles > 0 {
+			angles--
+		}
+		if t.Kind != outline.Ident || i > 0 && (toks[i-1].Is("::") || toks[i-1].Is(".") || toks[i-1].Is("->")) {
+			if i > 0 && t.Is("(") && typeOperators[toks[i-1].Text] {
+				// decltype(x): an expression, not a type.
+				for depth := 0; i < len(toks); i++ {
+					if toks[i].Is("(") {
+						depth++
+					} else if toks[i].Is(")") {
+						if depth--; depth == 0 {
+							break
+						}
+					}
+				}
+			}
+			continue
+		}
+		if keywords[t.Text] || typeOperators[t.Text] {
+			continue
+		}
+		if angles == 0 && i > 0 && i+1 < len(toks) && (toks[i-1].Kind == outline.Ident || toks[i-1].Is("*") || toks[i-1].Is("&")) &&
+			(toks[i+1].Is(",") || toks[i+1].Is(")") || toks[i+1].Is("[")) {
+			continue // the name of a parameter of a function type
+		}
+		name := t.Text
+		for i+2 < len(toks) && toks[i+1].Is("::") && toks[i+2].Kind == outline.Ident {
+			name += "::" + toks[i+2].Text
+			i += 2
+		}
+		if i+1 < len(toks) && toks[i+1].Is("(") && !toks[min(i+2, len(toks)-1)].Is("*") {
+			continue // a macro
+		}
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		first, _, _ := strings.Cut(name, "::")
+		if params[first] {
+			continue
+		}
+		if q, ok := r.resolve(name); ok {
+			q.Text = typ
+			out = append(out, q)
+		}
+	}
+	return out
+}
+
+// resolve resolves a simple or qualified type name.
+func (r *resolver) resolve(name string) (ref, bool) {
+	name = strings.TrimPrefix(name, "::")
+	first, rest, qualified := strings.Cut(name, "::")
+	join := func(q string) string {
+		if qualified {
+			return q + "::" + rest
+		}
+		return q
+	}
+	if q := r.aliases[first]; q != "" {
+		return ref{Symbol: join(q)}, true
+	}
+	for _, s := range r.scopes {
+		if q := qualify(s, name); r.types[q] != "" {
+			return ref{Symbol: q}, true
+		}
+	}
+	if r.types[name] != "" {
+		return ref{Symbol: name}, true
+	}
+	for _, u := range r.usings {
+		if q := qualify(u, name); r.types[q] != "" {
+			return ref{Symbol: q}, true
+		}
+	}
+	switch {
+	case builtins[name]:
+		return ref{}, false
+	case isStdlib(name):
+		return ref{Symbol: name}, true
+	case stdNames[first]:
+		for _, u := range r.usings {
+			if u == "std" {
+				return ref{Symbol: "std::" + name}, true
+			}
+		}
+	}
+	return ref{Name: name}, true
+}
+
+// kind returns the kind of a type the file sees, "" for others.
+func (r *resolver) kind(full string) string { return r.types[full] }
+
+// within returns a resolver for the body of a namespace or class: its
+// name comes first, and the using directives of a namespace add to the
+// others.
+func (r *resolver) within(scope string, usings []usingDecl) *resolver {
+	s := *r
+	if scope != "" && (len(r.scopes) == 0 || r.scopes[0] != scope) {
+		s.scopes = append([]string{scope}, r.scopes...)
+	}
+	if len(usings) > 0 {
+		s.usings = append([]string(nil), r.usings...)
+		s.aliases = map[string]string{}
+		for k, v := range r.aliases {
+			s.aliases[k] = v
+		}
+		s.use(usings)
+	}
+	return &s
+}
+
+// use adds using directives and declarations.
+func (r *resolver) use(usings []usingDecl) {
+	for _, u := range usings {
+		p := strings.TrimPrefix(u.path, "::")
+		if u.namespace {
+			r.usings = append(r.usings, p)
+		} else {
+			r.aliases[p[strings.LastIndex(p, ":")+1:]] = p
+		}
+	}
+}
+
+// index is what a file and the headers it includes declare: the types
+// names resolve to, and the declarations its definitions pair with.
+type index struct {
+	types map[string]string  // full name -> kind
+	decls map[string][]entry // full name -> the declarations of that name
+}
+
+// entry is a declaration of a function or variable without its
+// definition.
+type entry struct {
+	rel  string
+	full string
+	key  string // the full name with the parameter types
+	d    *decl
+	vis  string
+}
+
+// id is the key a declaration's symbol id is kept under: a header can
+// declare a name more than once, and a definition can pair with any.
+func (en entry) id() string { return "decl:" + en.rel + ":" + en.key }
+
+// index returns what a file sees: the declarations of its include
+// closure.
+func (l *layout) index(rel string) *index {
+	if ix, ok := l.indexes[rel]; ok {
+		return ix
+	}
+	ix := &index{types: map[string]string{}, decls: map[string][]entry{}}
+	for _, r := range l.closure(rel) {
+		if f := l.parse(r); f != nil {
+			ix.add(r, "", nil, false, f.decls)
+		}
+	}
+	l.indexes[rel] = ix
+	return ix
+}
+
+// add records the types and bodyless declarations among decls, prefix
+// being the namespace or class they are in.
+func (ix *index) add(rel, prefix string, owner *decl, anon bool, decls []*decl) {
+	for _, d := range decls {
+		if d.kind == "namespace" {
+			ix.add(rel, qualify(prefix, d.name), nil, anon || d.name == "", d.children)
+			continue
+		}
+		if d.name == "" {
+			continue
+		}
+		full := qualify(prefix, qualify(d.qualifier, d.name))
+		switch {
+		case isType(d.kind) || d.kind == "typedef" || d.kind == "typealias":
+			if d.body || ix.types[full] == "" {
+				ix.types[full] = d.kind
+			}
+			ix.add(rel, full, d, anon, d.children)
+		case isDeclaration(d):
+			en := entry{rel: rel, full: full, key: key(full, d), d: d, vis: visibility(d, owner, anon)}
+			ix.decls[full] = append(ix.decls[full], en)
+		}
+	}
+}
+
+// isDeclaration reports whether a declaration is of a function or
+// variable defined elsewhere.
+func isDeclaration(d *decl) bool {
+	if d.body || d.has("pure") {
+		return false
+	}
+	switch d.kind {
+	case "function", "method", "constructor", "destructor", "operator", "variable", "field":
+		return true
+	}
+	return false
+}
+
+// isCallable reports whether a kind of declaration has parameters.
+func isCallable(kind string) bool {
+	switch kind {
+	case "function", "method", "constructor", "destructor", "operator":
+		return true
+	}
+	return false
+}
+
+// key identifies a declaration among the overloads of its name: the name
+// with its parameter types, and const for a const member function.
+func key(full string, d *decl) string {
+	if !isCallable(d.kind) {
+		return full
+	}
+	var types []string
+	for _, pr := range d.params {
+		types = append(types, strings.Join(strings.Fields(pr.Type), ""))
+	}
+	k := full + "(" + strings.Join(types, ",") + ")"
+	if d.has("const") {
+		k += " const"
+	}
+	return k
+}
+
+// match finds the declaration a definition defines: by name and parameter
+// types, looking the name up from the namespace the definition is in
+// outwards. A name declared once pairs whatever its parameters, as C has
+// no overloads.
+func (ix *index) match(prefix string, d *decl) (entry, bool) {
+	name := qualify(d.qualifier, d.name)
+	for s := prefix; ; s = parentScope(s) {
+		full := qualify(s, name)
+		ens := ix.decls[full]
+		k := key(full, d)
+		for _, en := range ens {
+			if en.key == k {
+				return en, true
+			}
+		}
+		if len(ens) == 1 {
+			return ens[0], true
+		}
+		if s == "" {
+			return entry{}, false
+		}
+	}
+}
+
+// parentScope returns the namespace or class a qualified name is in.
+func parentScope(name string) string {
+	if i := strings.LastIndex(name, "::"); i >= 0 {
+		return name[:i]
+	}
+	return ""
+}
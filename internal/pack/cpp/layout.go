@@ -0,0 +1,475 @@
+package cpp
+
+import (
+	"encoding/json"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ChaseHampton/cargoworker/internal/pack/outline"
+)
+
+// project is a CMake or Meson project: the nearest directory at or above
+// a source with a CMakeLists.txt or meson.build that names one.
+type project struct {
+	file        string // the build file, relative to the input root
+	dir         string
+	name        string
+	build       string // cmake or meson
+	version     string
+	description string
+	languages   []string
+}
+
+// flags are what a compile command says about a file's includes and
+// macros.
+type flags struct {
+	source   string            // the file the command compiles, relative to the input root
+	includes []string          // include directories, relative to the input root; "." for it
+	defines  map[string]string // -D, less -U
+}
+
+// compileCommand is an entry of compile_commands.json.
+type compileCommand struct {
+	Directory string   `json:"directory"`
+	File      string   `json:"file"`
+	Arguments []string `json:"arguments"`
+	Command   string   `json:"command"`
+}
+
+// layout indexes the C-family sources of an input root and parses them,
+// once each: a file's declarations are paired with those of the headers it
+// includes, wherever those are, and their macros decide its conditional
+// directives.
+type layout struct {
+	root     string
+	db       string            // compile_commands.json, relative to the input root; "" for none
+	commands map[string]*flags // by source
+	order    []string          // the sources of commands, in the database's order
+	files    map[string]bool   // every file under the root
+	byBase   map[string][]string
+	projects map[string]*project // by directory; nil where there is none
+	parses   map[string]*file
+	parsing  map[string]bool // the files being parsed, against include cycles
+	closures map[string][]string
+	indexes  map[string]*index
+}
+
+func newLayout(root string) *layout {
+	l := &layout{
+		root: root, commands: map[string]*flags{}, files: map[string]bool{}, byBase: map[string][]string{},
+		projects: map[string]*project{}, parses: map[string]*file{}, parsing: map[string]bool{},
+		closures: map[string][]string{}, indexes: map[string]*index{},
+	}
+	l.walk()
+	for _, db := range []string{"compile_commands.json", "build/compile_commands.json"} {
+		if l.files[db] {
+			l.readCommands(db)
+			break
+		}
+	}
+	return l
+}
+
+// walk lists the files under the root, but not in hidden directories.
+func (l *layout) walk() {
+	filepath.WalkDir(l.root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		rel, _ := filepath.Rel(l.root, p)
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			if rel != "." && (strings.HasPrefix(d.Name(), ".") || d.Name() == "node_modules") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		l.files[rel] = true
+		l.byBase[path.Base(rel)] = append(l.byBase[path.Base(rel)], rel)
+		return nil
+	})
+	for _, rels := range l.byBase {
+		sort.Strings(rels)
+	}
+}
+
+// readCommands reads a compilation database. Its paths are absolute, or
+// relative to an entry's directory, and may be those of another checkout
+// of the tree; they are mapped onto the input root.
+func (l *layout) readCommands(db string) {
+	b, err := os.ReadFile(filepath.Join(l.root, filepath.FromSlash(db)))
+	if err != nil {
+		return
+	}
+	var cmds []compileCommand
+	if json.Unmarshal(b, &cmds) != nil {
+		return
+	}
+	l.db = db
+	for _, c := range cmds {
+		dir := c.Directory
+		if dir == "" || !filepath.IsAbs(dir) {
+			dir = filepath.Join(l.root, filepath.FromSlash(path.Dir(db)), dir)
+		}
+		src, ok := l.rel(dir, c.File)
+		if !ok || l.commands[src] != nil {
+			continue
+		}
+		args := c.Arguments
+		if len(args) == 0 {
+			args = shellWords(c.Command)
+		}
+		f := &flags{source: src, defines: map[string]string{}}
+		for i := 0; i < len(args); i++ {
+			a := args[i]
+			opt, val := "", ""
+			for _, o := range []string{"-isystem", "-iquote", "-idirafter", "-I", "-D", "-U"} {
+				if strings.HasPrefix(a, o) {
+					opt, val = o[1:], a[len(o):]
+					break
+				}
+			}
+			if opt == "" {
+				continue
+			}
+			if val == "" && i+1 < len(args) {
+				i++
+				val = args[i]
+			}
+			switch opt {
+			case "D":
+				name, body, ok := strings.Cut(val, "=")
+				if !ok {
+					body = "1"
+				}
+				f.defines[name] = body
+			case "U":
+				delete(f.defines, val)
+			default:
+				if inc, ok := l.rel(dir, val); ok {
+					f.includes = append(f.includes, inc)
+				}
+			}
+		}
+		l.commands[src] = f
+		l.order = append(l.order, src)
+	}
+}
+
+// rel maps a path of the compilation database onto the input root. A path
+// outside it matches the files of the root that end with its last parts.
+func (l *layout) rel(dir, p string) (string, bool) {
+	if p == "" {
+		return "", false
+	}
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(dir, p)
+	}
+	p = filepath.Clean(p)
+	if r, err := filepath.Rel(l.root, p); err == nil && r != ".." && !strings.HasPrefix(r, ".."+string(filepath.Separator)) {
+		return filepath.ToSlash(r), true
+	}
+	parts := strings.Split(filepath.ToSlash(p), "/")
+	for i := 1; i < len(parts); i++ {
+		cand := strings.Join(parts[i:], "/")
+		if l.files[cand] {
+			return cand, true
+		}
+		if st, err := os.Stat(filepath.Join(l.root, filepath.FromSlash(cand))); err == nil && st.IsDir() {
+			return cand, true
+		}
+	}
+	return "", false
+}
+
+// shellWords splits a command line as a POSIX shell would, without
+// expansions.
+func shellWords(s string) []string {
+	var out []string
+	var b strings.Builder
+	word, quote := false, byte(0)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote == '\'':
+			b.WriteByte(c)
+		case c == '\\' && i+1 < len(s) && (quote == 0 || strings.IndexByte(`"\$`+"`", s[i+1]) >= 0):
+			i++
+			b.WriteByte(s[i])
+			word = true
+		case quote != 0:
+			b.WriteByte(c)
+		case c == '"' || c == '\'':
+			quote, word = c, true
+		case c == ' ' || c == '\t' || c == '\n':
+			if word {
+				out = append(out, b.String())
+				b.Reset()
+				word = false
+			}
+		default:
+			b.WriteByte(c)
+			word = true
+		}
+	}
+	if word {
+		out = append(out, b.String())
+	}
+	return out
+}
+
+// flags returns the compile command that applies to a file: its own, or
+// for a header that of a source with the same stem, then of one in the
+// same directory, then the first. nil without a database.
+func (l *layout) flags(rel string) *flags {
+	if f := l.commands[rel]; f != nil {
+		return f
+	}
+	if len(l.order) == 0 {
+		return nil
+	}
+	stem := strings.TrimSuffix(path.Base(rel), path.Ext(rel))
+	var sameDir *flags
+	for _, src := range l.order {
+		if strings.TrimSuffix(path.Base(src), path.Ext(src)) == stem {
+			return l.commands[src]
+		}
+		if sameDir == nil && path.Dir(src) == path.Dir(rel) {
+			sameDir = l.commands[src]
+		}
+	}
+	if sameDir != nil {
+		return sameDir
+	}
+	return l.commands[l.order[0]]
+}
+
+// cplusplus are the extensions of C++ and Objective-C++ sources and
+// headers.
+var cplusplus = outline.Set(`.cc .cpp .cxx .c++ .hpp .hh .hxx .h++ .ipp .tpp .mm`)
+
+// sources are the extensions the pack reads; CMake files share the cpp
+// language.
+var sources = outline.Set(`.c .h .cc .cpp .cxx .c++ .hpp .hh .hxx .h++ .inl .ipp .tpp .m .mm`)
+
+// defines returns the macros a file is compiled with: those of its
+// compile command, and the compiler's for its language. A header is taken
+// to be compiled as the source its command is for.
+func (l *layout) defines(rel string) map[string]string {
+	out := map[string]string{"__STDC__": "1"}
+	lang := rel
+	if f := l.flags(rel); f != nil {
+		if path.Ext(rel) == ".h" {
+			lang = f.source
+		}
+		maps.Copy(out, f.defines)
+	}
+	ext := path.Ext(lang)
+	if cplusplus[ext] {
+		out["__cplusplus"] = "201703L"
+	}
+	if ext == ".m" || ext == ".mm" {
+		out["__OBJC__"] = "1"
+	}
+	return out
+}
+
+// parse returns the outline of a file, parsing it once; nil if it cannot
+// be read. The macros of the headers it includes can switch its
+// conditional directives, so a file whose headers define new ones is
+// parsed again with them.
+func (l *layout) parse(rel string) *file {
+	if f, ok := l.parses[rel]; ok {
+		return f
+	}
+	if l.parsing[rel] {
+		return nil
+	}
+	b, err := os.ReadFile(filepath.Join(l.root, filepath.FromSlash(rel)))
+	if err != nil {
+		l.parses[rel] = nil
+		return nil
+	}
+	l.parsing[rel] = true
+	defer delete(l.parsing, rel)
+	defines := l.defines(rel)
+	f := parse(string(b), defines)
+	more := false
+	for _, inc := range f.includes {
+		r, _ := l.resolve(rel, inc)
+		if r == "" {
+			continue
+		}
+		if h := l.parse(r); h != nil {
+			for name, body := range h.defines {
+				if _, ok := defines[name]; !ok {
+					defines[name] = body
+					more = true
+				}
+			}
+		}
+	}
+	if more {
+		f = parse(string(b), defines)
+	}
+	l.parses[rel] = f
+	return f
+}
+
+// resolve finds the file an include names: beside the including file for
+// a quoted include, then in the include directories of its compile
+// command, then anywhere under the root that ends with its path. An
+// unresolved angle include is taken to be of the standard library; a
+// single-name one is not looked for under the root, so that <string.h>
+// is not a project's string.h.
+func (l *layout) resolve(from string, inc include) (rel string, stdlib bool) {
+	if !inc.angle {
+		if r := path.Join(path.Dir(from), inc.path); l.files[r] {
+			return r, false
+		}
+	}
+	if f := l.flags(from); f != nil {
+		for _, dir := range f.includes {
+			if r := path.Join(dir, inc.path); l.files[r] {
+				return r, false
+			}
+		}
+	}
+	if !inc.angle || strings.Contains(inc.path, "/") {
+		best, score := "", -1
+		for _, r := range l.byBase[path.Base(inc.path)] {
+			if r != inc.path && !strings.HasSuffix(r, "/"+inc.path) {
+				continue
+			}
+			// The nearest to the including file.
+			if n := commonPrefix(r, from); n > score {
+				best, score = r, n
+			}
+		}
+		if best != "" {
+			return best, false
+		}
+	}
+	return "", inc.angle
+}
+
+func commonPrefix(a, b string) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
+
+// closure returns a file and the files it includes, transitively, in the
+// order they are first included.
+func (l *layout) closure(rel string) []string {
+	if c, ok := l.closures[rel]; ok {
+		return c
+	}
+	seen := map[string]bool{}
+	var out []string
+	var visit func(r string)
+	visit = func(r string) {
+		if seen[r] {
+			return
+		}
+		seen[r] = true
+		out = append(out, r)
+		f := l.parse(r)
+		if f == nil {
+			return
+		}
+		for _, inc := range f.includes {
+			if h, _ := l.resolve(r, inc); h != "" {
+				visit(h)
+			}
+		}
+	}
+	visit(rel)
+	l.closures[rel] = out
+	return out
+}
+
+var (
+	cmakeProject = regexp.MustCompile(`(?is)\bproject\s*\(\s*([A-Za-z0-9_.+-]+)([^)]*)\)`)
+	cmakeVersion = regexp.MustCompile(`(?i)\bVERSION\s+([0-9][0-9.]*)`)
+	cmakeDesc    = regexp.MustCompile(`(?i)\bDESCRIPTION\s+"([^"]*)"`)
+	cmakeLangs   = regexp.MustCompile(`(?i)\bLANGUAGES\s+([A-Za-z+ \t]+)`)
+	mesonProject = regexp.MustCompile(`(?s)\bproject\s*\(\s*'([^']+)'(.*?)\)\s*(\n|$)`)
+	mesonVersion = regexp.MustCompile(`\bversion\s*:\s*'([^']+)'`)
+	mesonLangs   = regexp.MustCompile(`'([a-z+]+)'`)
+)
+
+// project returns the nearest project at or above dir, or nil.
+func (l *layout) project(dir string) *project {
+	if p, seen := l.projects[dir]; seen {
+		return p
+	}
+	p := l.readProject(dir)
+	if p == nil && dir != "." {
+		p = l.project(path.Dir(dir))
+	}
+	l.projects[dir] = p
+	return p
+}
+
+// readProject reads the project() of dir's CMakeLists.txt or meson.build.
+func (l *layout) readProject(dir string) *project {
+	for _, name := range []string{"CMakeLists.txt", "meson.build"} {
+		rel := path.Join(dir, name)
+		if !l.files[rel] {
+			continue
+		}
+		b, err := os.ReadFile(filepath.Join(l.root, filepath.FromSlash(rel)))
+		if err != nil {
+			continue
+		}
+		p := &project{file: rel, dir: dir}
+		if name == "meson.build" {
+			m := mesonProject.FindStringSubmatch(string(b))
+			if m == nil {
+				continue
+			}
+			p.name, p.build = m[1], "meson"
+			args := m[2]
+			if v := mesonVersion.FindStringSubmatch(args); v != nil {
+				p.version = v[1]
+				args = strings.Replace(args, v[0], "", 1)
+			}
+			for _, lang := range mesonLangs.FindAllStringSubmatch(args, -1) {
+				p.languages = append(p.languages, lang[1])
+			}
+			return p
+		}
+		m := cmakeProject.FindStringSubmatch(string(b))
+		if m == nil {
+			continue
+		}
+		p.name, p.build = m[1], "cmake"
+		if v := cmakeVersion.FindStringSubmatch(m[2]); v != nil {
+			p.version = v[1]
+		}
+		if v := cmakeDesc.FindStringSubmatch(m[2]); v != nil {
+			p.description = v[1]
+		}
+		if v := cmakeLangs.FindStringSubmatch(m[2]); v != nil {
+			for _, lang := range strings.Fields(v[1]) {
+				if up := strings.ToUpper(lang); up == "VERSION" || up == "DESCRIPTION" || up == "HOMEPAGE" {
+					break
+				}
+				p.languages = append(p.languages, lang)
+			}
+		}
+		return p
+	}
+	return nil
+}
@@ -0,0 +1,377 @@
+package cpp
+
+import (
+	"strings"
+
+	"github.com/ChaseHampton/cargoworker/internal/pack/outline"
+)
+
+// token is a token of a source. Doc tokens are /** */ or /*! */
+// comments, or /// or //! lines, markers included.
+type token = outline.Token
+
+// trailing reports whether a doc comment documents the declaration before
+// it: ///< or //!< or /**< or /*!<.
+func trailing(t token) bool {
+	return t.Kind == outline.Doc && len(t.Text) > 3 && t.Text[3] == '<'
+}
+
+// puncts are the multi-character operators, longest first. Angle brackets
+// are always single tokens so that template arguments nest; >> is two.
+var puncts = []string{
+	"<<=", "...", "->*", "<=>", "::", "->", "++", "--", "<<", "&&", "||", "==", "!=", "<=", ">=",
+	"+=", "-=", "*=", "/=", "%=", "&=", "|=", "^=", ".*", "##",
+}
+
+// include is an #include or #import directive.
+type include struct {
+	path  string
+	angle bool // <path> rather than "path"
+	line  int
+}
+
+// macro is a #define.
+type macro struct {
+	name      string
+	params    []string // nil for object-like macros
+	body      string
+	line, col int // of the name: 1-based line and byte column
+	endLine   int
+	endCol    int
+	doc       string
+}
+
+type lexer struct {
+	outline.Scanner
+	out      []token
+	pp       *preproc
+	includes []include
+	macros   []macro
+	ifndef   string // the name tested by the last #ifndef, and its line
+	ifndefAt int
+}
+
+// lex splits a source into tokens, dropping comments other than doc
+// comments and running the conditional directives: the tokens of inactive
+// branches are left out, as the compiler would with the given defines.
+// Includes and macro definitions of the active branches are collected.
+func lex(src string, defines map[string]string) *lexer {
+	l := &lexer{Scanner: outline.NewScanner(src), pp: newPreproc(defines)}
+	if strings.HasPrefix(src, "\ufeff") {
+		l.I = 3
+	}
+	l.run()
+	return l
+}
+
+func (l *lexer) run() {
+	lineStart := true // only whitespace since the line began
+	for l.I < len(l.Src) {
+		c := l.Src[l.I]
+		switch {
+		case c == '\n':
+			l.SkipTo(l.I + 1)
+			lineStart = true
+			continue
+		case c == ' ' || c == '\t' || c == '\r' || c == '\f' || c == '\v':
+			l.I++
+			continue
+		case c == '\\' && (l.Peek(1) == '\n' || l.Peek(1) == '\r'):
+			l.I++ // a line continuation
+			continue
+		case c == '#' && lineStart:
+			l.directive()
+			continue
+		case !l.pp.active():
+			l.skipLine()
+			continue
+		case c == '/' && l.Peek(1) == '/':
+			t := token{Kind: outline.Doc, Pos: l.I, Line: l.Line, Col: l.I - l.LineStart}
+			l.SkipLine()
+			t.Text = strings.TrimRight(l.Src[t.Pos:l.I], "\r")
+			if strings.HasPrefix(t.Text, "///") && !strings.HasPrefix(t.Text, "////") || strings.HasPrefix(t.Text, "//!") {
+				t.EndLine, t.EndCol = l.Line, l.I-l.LineStart
+				l.out = append(l.out, t)
+			}
+			continue
+		case c == '/' && l.Peek(1) == '*':
+			t := token{Kind: outline.Doc, Pos: l.I, Line: l.Line, Col: l.I - l.LineStart}
+			end := strings.Index(l.Src[l.I+2:], "*/")
+			if end < 0 {
+				end = len(l.Src)
+			} else {
+				end += l.I + 4
+			}
+			l.SkipTo(end)
+			t.Text = l.Src[t.Pos:l.I]
+			if strings.HasPrefix(t.Text, "/**") && t.Text != "/**/" && !strings.HasPrefix(t.Text, "/***") || strings.HasPrefix(t.Text, "/*!") {
+				t.EndLine, t.EndCol = l.Line, l.I-l.LineStart
+				l.out = append(l.out, t)
+			}
+			continue
+		}
+		lineStart = false
+		t := token{Line: l.Line, Col: l.I - l.LineStart, Pos: l.I}
+		start := l.I
+		switch {
+		case c == '"' || c == '\'' || isIdentStart(c) && l.quotePrefix():
+			l.SkipTo(l.literal(l.I))
+			t.Kind = outline.String
+			if l.Src[l.I-1] == '\'' {
+				t.Kind = outline.Char
+			}
+		case isIdentStart(c):
+			for l.I < len(l.Src) && isIdent(l.Src[l.I]) {
+				l.I++
+			}
+			t.Kind = outline.Ident
+		case c >= '0' && c <= '9' || c == '.' && l.Peek(1) >= '0' && l.Peek(1) <= '9':
+			l.I++
+			for l.I < len(l.Src) {
+				d := l.Src[l.I]
+				prev := l.Src[l.I-1] | 0x20
+				if isIdent(d) || d == '.' || d == '\'' && isIdent(l.Peek(1)) ||
+					(d == '+' || d == '-') && (prev == 'e' || prev == 'p') {
+					l.I++
+					continue
+				}
+				break
+			}
+			t.Kind = outline.Number
+		default:
+			t.Kind = outline.Punct
+			l.SkipPunct(puncts)
+		}
+		t.Text = l.Src[start:l.I]
+		t.EndLine, t.EndCol = l.Line, l.I-l.LineStart
+		l.out = append(l.out, t)
+	}
+}
+
+// skipLine skips past the next line break that does not continue the
+// line.
+func (l *lexer) skipLine() {
+	for l.I < len(l.Src) {
+		switch l.Src[l.I] {
+		case '\\':
+			l.I++
+			if l.I < len(l.Src) && l.Src[l.I] == '\r' {
+				l.I++
+			}
+			if l.I < len(l.Src) && l.Src[l.I] == '\n' {
+				l.SkipTo(l.I + 1)
+			}
+			continue
+		case '\n':
+			l.SkipTo(l.I + 1)
+			return
+		}
+		l.I++
+	}
+}
+
+// quotePrefix reports whether the identifier at l.i is the encoding or raw
+// prefix of a literal: L"", u8"", R"()", LR"()" and so on.
+func (l *lexer) quotePrefix() bool {
+	j := l.I
+	for j < len(l.Src) && j-l.I < 3 && strings.IndexByte("LuUR8", l.Src[j]) >= 0 {
+		j++
+	}
+	if j == l.I || j >= len(l.Src) || l.Src[j] != '"' && l.Src[j] != '\'' {
+		return false
+	}
+	switch l.Src[l.I:j] {
+	case "L", "u", "U", "u8", "R", "LR", "uR", "UR", "u8R":
+		return true
+	}
+	return false
+}
+
+// literal returns the offset after the string or character literal at i,
+// its prefix included. A raw string ends at its delimiter.
+func (l *lexer) literal(i int) int {
+	raw := false
+	for i < len(l.Src) && l.Src[i] != '"' && l.Src[i] != '\'' {
+		raw = raw || l.Src[i] == 'R'
+		i++
+	}
+	if i >= len(l.Src) {
+		return i
+	}
+	q := l.Src[i]
+	if raw && q == '"' {
+		open := strings.IndexByte(l.Src[i:], '(')
+		if open < 0 {
+			return len(l.Src)
+		}
+		delim := ")" + l.Src[i+1:i+open] + `"`
+		end := strings.Index(l.Src[i+open:], delim)
+		if end < 0 {
+			return len(l.Src)
+		}
+		return i + open + end + len(delim)
+	}
+	for j := i + 1; j < len(l.Src); j++ {
+		switch l.Src[j] {
+		case '\\':
+			j++
+		case q:
+			return j + 1
+		case '\n':
+			return j // unterminated
+		}
+	}
+	return len(l.Src)
+}
+
+// directive runs the preprocessor directive at l.i, a # at the start of a
+// line, and skips past its logical line.
+func (l *lexer) directive() {
+	line, col := l.Line, l.I-l.LineStart
+	start := l.I
+	l.skipLine()
+	text := stripComments(l.Src[start+1 : l.I])
+	name, rest := directiveWord(text)
+	switch name {
+	case "if", "ifdef", "ifndef", "elif", "elifdef", "elifndef", "else", "endif":
+		l.pp.conditional(name, rest)
+		if name == "ifndef" {
+			l.ifndef, _ = directiveWord(rest)
+			l.ifndefAt = line
+		}
+		return
+	}
+	if !l.pp.active() {
+		return
+	}
+	switch name {
+	case "include", "include_next", "import":
+		rest = strings.TrimSpace(rest)
+		switch {
+		case strings.HasPrefix(rest, "<"):
+			if end := strings.IndexByte(rest, '>'); end > 0 {
+				l.includes = append(l.includes, include{path: rest[1:end], angle: true, line: line})
+			}
+		case strings.HasPrefix(rest, `"`):
+			if end := strings.IndexByte(rest[1:], '"'); end >= 0 {
+				l.includes = append(l.includes, include{path: rest[1 : end+1], line: line})
+			}
+		}
+	case "define":
+		m, ok := parseDefine(rest)
+		if !ok {
+			return
+		}
+		l.pp.define(m.name, m.params, m.body)
+		if m.params == nil && m.body == "" && m.name == l.ifndef && line-l.ifndefAt <= 2 {
+			return // an include guard
+		}
+		m.line = line
+		after := strings.Index(l.Src[start:], "define") + len("define")
+		m.col = col + 1 + after + strings.Index(l.Src[start+after:], m.name)
+		end := strings.TrimRight(l.Src[start:l.I], "\r\n")
+		m.endLine = line + strings.Count(end, "\n")
+		m.endCol = len(end) - strings.LastIndexByte(end, '\n')
+		if n := len(l.out); n > 0 && l.out[n-1].Kind == outline.Doc && l.out[n-1].EndLine >= line-1 && !trailing(l.out[n-1]) {
+			// The doc comment before the #define is the macro's.
+			var docs []string
+			for n > 0 && l.out[n-1].Kind == outline.Doc && l.out[n-1].EndLine >= line-1-len(docs) {
+				n--
+				docs = append([]string{l.out[n].Text}, docs...)
+				if strings.HasPrefix(l.out[n].Text, "/*") {
+					break
+				}
+			}
+			m.doc = strings.Join(docs, "\n")
+			l.out = l.out[:n]
+		}
+		l.macros = append(l.macros, m)
+	case "undef":
+		l.pp.undef(strings.TrimSpace(rest))
+	}
+}
+
+// directiveWord splits a directive, without its #, into its name and the
+// rest.
+func directiveWord(text string) (string, string) {
+	text = strings.TrimLeft(text, " \t")
+	j := 0
+	for j < len(text) && isIdent(text[j]) {
+		j++
+	}
+	return text[:j], text[j:]
+}
+
+// stripComments removes the comments and line continuations of a
+// directive's logical line.
+func stripComments(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '"' || s[i] == '\'':
+			q, j := s[i], i+1
+			for j < len(s) && s[j] != q && s[j] != '\n' {
+				if s[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			b.WriteString(s[i:min(j+1, len(s))])
+			i = j
+		case strings.HasPrefix(s[i:], "//"):
+			return strings.TrimSpace(b.String())
+		case strings.HasPrefix(s[i:], "/*"):
+			end := strings.Index(s[i+2:], "*/")
+			if end < 0 {
+				return strings.TrimSpace(b.String())
+			}
+			b.WriteByte(' ')
+			i += end + 3
+		case s[i] == '\\' && i+1 < len(s) && (s[i+1] == '\n' || s[i+1] == '\r'):
+			b.WriteByte(' ')
+			for i+1 < len(s) && (s[i+1] == '\n' || s[i+1] == '\r') {
+				i++
+			}
+		case s[i] == '\n' || s[i] == '\r':
+			b.WriteByte(' ')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// parseDefine parses the text of a #define after the directive name.
+func parseDefine(rest string) (macro, bool) {
+	rest = strings.TrimLeft(rest, " \t")
+	j := 0
+	for j < len(rest) && isIdent(rest[j]) {
+		j++
+	}
+	if j == 0 {
+		return macro{}, false
+	}
+	m := macro{name: rest[:j]}
+	rest = rest[j:]
+	if strings.HasPrefix(rest, "(") {
+		end := strings.IndexByte(rest, ')')
+		if end < 0 {
+			return macro{}, false
+		}
+		m.params = []string{}
+		for _, p := range strings.Split(rest[1:end], ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				m.params = append(m.params, p)
+			}
+		}
+		rest = rest[end+1:]
+	}
+	m.body = strings.Join(strings.Fields(rest), " ")
+	return m, true
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c == '$' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+func isIdent(c byte) bool { return isIdentStart(c) || c >= '0' && c <= '9' }
@@ -0,0 +1,181 @@
+package cpp
+
+import (
+	"strings"
+
+	"github.com/ChaseHampton/cargoworker/internal/pack/outline"
+)
+
+// objc parses an Objective-C directive at p.i, an @ and its word:
+// @interface, @protocol and @implementation with their members up to
+// @end. The methods of an @implementation, and of a category, name their
+// class as an out-of-line definition does. Other directives are skipped.
+func (p *parser) objc(d *decl) []*decl {
+	word := p.Peek(1).Text
+	switch word {
+	case "interface", "protocol", "implementation":
+		if word == "protocol" && (p.Peek(3).Is(";") || p.Peek(3).Is(",")) {
+			p.skipStatement() // a forward declaration
+			return nil
+		}
+	case "class", "import", "synthesize", "dynamic", "compatibility_alias":
+		p.skipStatement()
+		return nil
+	default:
+		p.I += 2 // @public, @optional, @end, ...
+		return nil
+	}
+	start := p.I
+	p.I += 2
+	d.kind = "class"
+	if word == "protocol" {
+		d.kind = "protocol"
+	}
+	d.name = p.Peek(0).Text
+	p.at(d, p.I)
+	p.I++
+	category := p.Peek(0).Is("(") // a category, or a class extension
+	if category {
+		p.Group()
+	}
+	if p.Accept(":") {
+		d.bases = append(d.bases, base{typ: p.Peek(0).Text})
+		p.I++
+	}
+	if p.Peek(0).Is("<") {
+		from, to := p.angles()
+		for _, part := range p.Split(from, to) {
+			d.bases = append(d.bases, base{typ: p.Text(part[0], part[1]), access: "protocol"})
+		}
+	}
+	d.sig = p.Text(start, p.I)
+	if p.Peek(0).Is("{") {
+		// Instance variables.
+		p.I++
+		ivars, _ := p.body(&decl{kind: "struct"}, true)
+		p.Accept("}")
+		for _, v := range ivars {
+			v.access = "protected"
+		}
+		d.children = append(d.children, ivars...)
+	}
+	var outside []*decl
+	for !p.EOF() && !(p.Peek(0).Is("@") && p.Peek(1).Is("end")) {
+		before := p.I
+		c := &decl{doc: p.doc()}
+		switch t := p.Peek(0); {
+		case trailing(t):
+			if n := len(d.children); n > 0 && d.children[n-1].doc == "" {
+				d.children[n-1].doc = t.Text
+			}
+			p.I++
+		case t.Is("-"), t.Is("+"):
+			p.objcMethod(c)
+			d.children = append(d.children, c)
+		case t.Is("@") && p.Peek(1).Is("property"):
+			d.children = append(d.children, p.objcProperty(c)...)
+		case t.Is("@"):
+			p.objc(c)
+		default:
+			// C declarations: the functions of an implementation file.
+			p.I = before
+			outside = append(outside, p.member(nil)...)
+		}
+		if p.I == before {
+			p.I++
+		}
+	}
+	p.I += 2
+	p.end(d)
+	if word == "implementation" || category {
+		for _, c := range d.children {
+			c.qualifier = d.name
+		}
+		return append(d.children, outside...)
+	}
+	d.body = true
+	return append([]*decl{d}, outside...)
+}
+
+// objcMethod parses a method declaration or definition: - (T)name:(A)a
+// with:(B)b. Its name is its selector.
+func (p *parser) objcMethod(d *decl) {
+	d.kind = "method"
+	start := p.I
+	if p.Peek(0).Is("+") {
+		d.modifiers = append(d.modifiers, "class")
+	}
+	p.I++
+	if p.Peek(0).Is("(") {
+		from, to := p.Group()
+		d.returns = p.Text(from, to)
+	}
+	p.at(d, p.I)
+	var sel strings.Builder
+	for p.Peek(0).Kind == outline.Ident || p.Peek(0).Is(":") {
+		label := ""
+		if p.Peek(0).Kind == outline.Ident {
+			if sel.Len() > 0 && !p.Peek(1).Is(":") {
+				break // a macro after the selector
+			}
+			label = p.Peek(0).Text
+			p.I++
+		}
+		if !p.Accept(":") {
+			sel.WriteString(label)
+			break
+		}
+		sel.WriteString(label + ":")
+		pr := param{}
+		if p.Peek(0).Is("(") {
+			from, to := p.Group()
+			pr.Type = p.Text(from, to)
+		}
+		if p.Peek(0).Kind == outline.Ident {
+			pr.Name = p.Peek(0).Text
+			p.I++
+		}
+		d.params = append(d.params, pr)
+	}
+	if p.Accept(",") && p.Accept("...") {
+		d.params = append(d.params, param{Name: "..."})
+	}
+	d.name = sel.String()
+	sigEnd := p.I
+	for !p.EOF() && !p.Peek(0).Is(";") && !p.Peek(0).Is("{") && !p.Peek(0).Is("-") && !p.Peek(0).Is("+") && !p.Peek(0).Is("@") {
+		if !p.attributes(d) {
+			p.I++ // NS_DESIGNATED_INITIALIZER and other macros
+		}
+	}
+	d.sig = p.Text(start, sigEnd)
+	if p.Peek(0).Is("{") {
+		p.Group()
+		d.body = true
+	} else {
+		p.Accept(";")
+	}
+	p.end(d)
+}
+
+// objcProperty parses an @property declaration.
+func (p *parser) objcProperty(d *decl) []*decl {
+	start := p.I
+	p.I += 2
+	if p.Peek(0).Is("(") {
+		from, to := p.Group()
+		for _, part := range p.Split(from, to) {
+			d.modifiers = append(d.modifiers, p.Text(part[0], part[1]))
+		}
+	}
+	from, to := p.Until(true, ";")
+	p.Accept(";")
+	var out []*decl
+	for _, dc := range p.declarators("", from, to, false) {
+		c := &decl{kind: "property", name: p.Toks[dc.at].Text, doc: d.doc, modifiers: d.modifiers, typ: dc.typ, body: true, access: "public"}
+		p.at(c, dc.at)
+		c.endLine, c.endCol = p.Toks[dc.end-1].EndLine, p.Toks[dc.end-1].EndCol+1
+		c.sig = p.Text(start, from) + " " + dc.text
+		out = append(out, c)
+	}
+	return out
+}
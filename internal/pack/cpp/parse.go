@@ -0,0 +1,1277 @@
+package cpp
+
+import (
+	"strings"
+
+	"github.com/ChaseHampton/cargoworker/internal/pack/outline"
+)
+
+// file is the outline of one source file.
+type file struct {
+	decls    []*decl // namespaces hold theirs as children
+	usings   []usingDecl
+	includes []include
+	macros   []macro
+	defines  map[string]string // the object-like macros defined at its end
+}
+
+// usingDecl is a using directive, using namespace N, or a using
+// declaration, using N::name.
+type usingDecl struct {
+	path      string
+	namespace bool
+	line      int
+}
+
+// decl is a declaration: a namespace, a type, or a function, variable or
+// member.
+type decl struct {
+	kind       string // namespace; class, struct, union, enum, typedef, typealias; function, method, constructor, destructor, operator, field, variable, enum_member; interface, protocol, property for Objective-C
+	name       string
+	qualifier  string // the scope an out-of-line definition names: Foo for Foo::bar
+	line, col  int    // of the name: 1-based line and byte column
+	endLine    int
+	endCol     int // 1-based, after the last character
+	modifiers  []string
+	attributes []string
+	doc        string // the raw doc comment, /// lines joined
+	template   []typeParam
+	templated  bool // template<...>, maybe with no parameters
+	params     []param
+	returns    string
+	typ        string // of variables, fields, typedefs and aliases; the underlying type of an enum
+	bases      []base
+	access     string // of members: public, protected or private
+	body       bool   // a definition: a function with its body, a variable that is not extern
+	linkage    string // "C" inside extern "C"
+	sig        string
+	usings     []usingDecl // of a namespace
+	children   []*decl
+}
+
+type param struct {
+	Name    string `json:"name,omitempty"`
+	Type    string `json:"type,omitempty"`
+	Default string `json:"default,omitempty"`
+}
+
+// typeParam is a template parameter: a type, a non-type parameter with its
+// type, or a template template parameter.
+type typeParam struct {
+	Name    string `json:"name,omitempty"`
+	Kind    string `json:"kind,omitempty"` // typename or class, or the type of a non-type parameter
+	Default string `json:"default,omitempty"`
+}
+
+// base is an entry of a class's base list.
+type base struct {
+	typ     string
+	access  string
+	virtual bool
+}
+
+func (d *decl) has(modifier string) bool {
+	for _, m := range d.modifiers {
+		if m == modifier {
+			return true
+		}
+	}
+	return false
+}
+
+// specifiers are the declaration specifiers kept as modifiers. const and
+// volatile are part of types.
+var specifiers = outline.Set(`static inline virtual explicit constexpr consteval constinit extern friend mutable
+	thread_local register _Noreturn _Thread_local __inline __inline__ __forceinline`)
+
+// keywords are the fundamental types and the words of type expressions,
+// which are never the names of declarations.
+var keywords = outline.Set(`void bool char wchar_t char8_t char16_t char32_t short int long signed unsigned float double
+	auto decltype const volatile restrict __restrict _Bool _Complex struct class union enum typename`)
+
+// classKeys begin class, struct, union and enum declarations.
+var classKeys = outline.Set(`class struct union enum`)
+
+type parser struct {
+	outline.Cursor
+}
+
+// parse outlines a C, C++ or Objective-C source, running its conditional
+// directives with the given defines.
+func parse(src string, defines map[string]string) *file {
+	l := lex(src, defines)
+	p := &parser{Cursor: outline.Cursor{Toks: l.out}}
+	p.Angle = func(i int) bool { return p.Toks[i-1].Kind == outline.Ident }
+	f := &file{includes: l.includes, macros: l.macros, defines: map[string]string{}}
+	for name, d := range l.pp.defines {
+		if d.params == nil {
+			f.defines[name] = d.body
+		}
+	}
+	f.decls, f.usings = p.body(nil, false)
+	return f
+}
+
+// body parses declarations up to a closing brace, or the end of the file.
+// owner is the class they are members of.
+func (p *parser) body(owner *decl, braced bool) ([]*decl, []usingDecl) {
+	var decls []*decl
+	var usings []usingDecl
+	access := ""
+	if owner != nil {
+		access = "public"
+		if owner.kind == "class" {
+			access = "private"
+		}
+	}
+	for !p.EOF() {
+		t := p.Peek(0)
+		if braced && t.Is("}") {
+			break
+		}
+		if trailing(t) {
+			if n := len(decls); n > 0 && decls[n-1].doc == "" {
+				decls[n-1].doc = t.Text
+			}
+			p.I++
+			continue
+		}
+		if owner != nil && (t.Is("public") || t.Is("protected") || t.Is("private")) {
+			j := 1
+			if p.Peek(1).Kind == outline.Ident && p.Peek(2).Is(":") {
+				j = 2 // public slots:
+			}
+			if p.Peek(j).Is(":") {
+				access = t.Text
+				p.I += j + 1
+				continue
+			}
+		}
+		before := p.I
+		if u, ok := p.using(); ok {
+			if u.path != "" {
+				usings = append(usings, u)
+			}
+			continue
+		}
+		for _, d := range p.member(owner) {
+			if owner != nil && d.access == "" {
+				d.access = access
+			}
+			decls = append(decls, d)
+		}
+		if p.I == before {
+			p.I++
+		}
+	}
+	return decls, usings
+}
+
+// using parses a using directive or declaration, reporting whether there
+// was one. Aliases, using X = T, are left to member.
+func (p *parser) using() (usingDecl, bool) {
+	if !p.Peek(0).Is("using") || p.Peek(1).Kind == outline.Ident && p.Peek(2).Is("=") {
+		return usingDecl{}, false
+	}
+	u := usingDecl{line: p.Peek(0).Line}
+	p.I++
+	u.namespace = p.Accept("namespace")
+	p.Accept("typename")
+	from, to := p.Until(false, ";")
+	p.Accept(";")
+	u.path = strings.TrimPrefix(p.Name(from, to), "::")
+	return u, true
+}
+
+// member parses a declaration in a file, a namespace or the body of the
+// class owner.
+func (p *parser) member(owner *decl) []*decl {
+	d := &decl{doc: p.doc()}
+	start := p.I
+	for p.Peek(0).Is("template") {
+		if !p.Peek(1).Is("<") {
+			p.skipStatement() // an explicit instantiation
+			return nil
+		}
+		p.I++
+		d.templated = true
+		d.template = append(d.template, p.templateParams()...)
+	}
+	switch t := p.Peek(0); {
+	case p.EOF() || t.Is("}"):
+		return nil
+	case t.Is(";"):
+		p.I++
+		return nil
+	case t.Is("namespace"), t.Is("inline") && p.Peek(1).Is("namespace"):
+		return p.namespace(d)
+	case t.Is("extern") && p.Peek(1).Kind == outline.String:
+		linkage := strings.Trim(p.Peek(1).Text, `"`)
+		p.I += 2
+		if p.Accept("{") {
+			decls, _ := p.body(owner, true)
+			p.Accept("}")
+			for _, c := range decls {
+				c.linkage = linkage
+			}
+			return decls
+		}
+		d.linkage = linkage
+	case t.Is("@"):
+		return p.objc(d)
+	}
+	p.attributes(d)
+	switch t := p.Peek(0); {
+	case t.Is("using"):
+		return p.alias(d)
+	case t.Is("typedef"):
+		return p.typedef(d)
+	case t.Is("static_assert"), t.Is("_Static_assert"), t.Is("friend"):
+		p.skipStatement()
+		return nil
+	case t.Kind == outline.Ident && classKeys[t.Text]:
+		if p.typeDecl(d, start) {
+			if !d.body {
+				return nil // a forward declaration
+			}
+			return p.typeDeclarators(d, owner)
+		}
+	case p.macroCall():
+		return nil
+	}
+	return p.declaration(d, owner)
+}
+
+// namespace parses a namespace definition; aliases are skipped.
+func (p *parser) namespace(d *decl) []*decl {
+	if p.Accept("inline") {
+		d.modifiers = append(d.modifiers, "inline")
+	}
+	p.I++
+	p.attributes(d)
+	from, to := p.Until(false, "{", "=", ";")
+	if !p.Peek(0).Is("{") {
+		p.skipStatement()
+		return nil
+	}
+	d.kind = "namespace"
+	d.name = strings.ReplaceAll(p.Name(from, to), "inline", "")
+	p.at(d, from)
+	p.I++
+	d.children, d.usings = p.body(nil, true)
+	p.Accept("}")
+	p.end(d)
+	return []*decl{d}
+}
+
+// alias parses using X = T, maybe a template.
+func (p *parser) alias(d *decl) []*decl {
+	p.I++
+	d.kind, d.name, d.body = "typealias", p.Peek(0).Text, true
+	p.at(d, p.I)
+	p.I += 2
+	p.attributes(d)
+	from, to := p.Until(true, ";")
+	d.typ = p.Text(from, to)
+	d.sig = joinType(templateHead(d), "using "+d.name+" = "+d.typ)
+	p.Accept(";")
+	p.end(d)
+	return []*decl{d}
+}
+
+// typedef parses a typedef. A struct, union or enum defined in it is
+// declared as well; one without a tag takes the typedef's first name.
+func (p *parser) typedef(d *decl) []*decl {
+	p.I++
+	var out []*decl
+	base := ""
+	if p.Peek(0).Kind == outline.Ident && classKeys[p.Peek(0).Text] {
+		save := p.I
+		inner := &decl{doc: d.doc}
+		switch {
+		case !p.typeDecl(inner, p.I):
+		case inner.body:
+			base = inner.kind + " " + inner.name
+			if inner.name != "" {
+				out = append(out, inner)
+			}
+		default:
+			p.I = save
+		}
+		if base != "" && inner.name == "" {
+			// typedef struct { ... } Point;
+			from, to := p.Until(true, ";")
+			if parts := p.Split(from, to); len(parts) > 0 {
+				if at, ok := p.declName(parts[0][0], parts[0][1]); ok && at == parts[0][0] {
+					inner.name = p.Toks[at].Text
+					inner.sig = strings.Replace(inner.sig, inner.kind, inner.kind+" "+inner.name, 1)
+					out = append(out, inner)
+					base = inner.kind + " " + inner.name
+				}
+			}
+			p.I = from
+		}
+	}
+	from, to := p.Until(true, ";")
+	p.Accept(";")
+	for _, dc := range p.declarators(base, from, to, false) {
+		name := p.Toks[dc.at].Text
+		if len(out) > 0 && out[len(out)-1].name == name && dc.at == from {
+			continue // the name an untagged struct took
+		}
+		t := &decl{kind: "typedef", name: name, doc: d.doc, attributes: d.attributes, typ: dc.typ, body: true}
+		p.at(t, dc.at)
+		t.endLine, t.endCol = p.Toks[dc.end-1].EndLine, p.Toks[dc.end-1].EndCol+1
+		t.sig = "typedef " + dc.text
+		out = append(out, t)
+	}
+	return out
+}
+
+// typeDecl parses the head of a class, struct, union or enum and, when it
+// has one, its body. It reports false, having consumed nothing, for an
+// elaborated type specifier such as struct stat st; a forward declaration
+// is consumed, leaving d.body unset.
+func (p *parser) typeDecl(d *decl, start int) bool {
+	save := p.I
+	d.kind = p.Peek(0).Text
+	p.I++
+	scoped := ""
+	if d.kind == "enum" && (p.Peek(0).Is("class") || p.Peek(0).Is("struct")) {
+		scoped = p.Peek(0).Text
+		d.modifiers = append(d.modifiers, scoped)
+		p.I++
+	}
+	p.attributes(d)
+	for p.Peek(0).Kind == outline.Ident && isMacroName(p.Peek(0).Text) && p.Peek(1).Kind == outline.Ident {
+		d.attributes = append(d.attributes, p.Peek(0).Text) // an export macro
+		p.I++
+	}
+	if p.Peek(0).Kind == outline.Ident && !p.Peek(0).Is("final") {
+		from := p.I
+		for p.Peek(0).Kind == outline.Ident && p.Peek(1).Is("::") {
+			p.I += 2
+		}
+		d.name = p.Peek(0).Text
+		d.qualifier = strings.TrimSuffix(p.Name(from, p.I), "::")
+		p.at(d, p.I)
+		p.I++
+		if p.Peek(0).Is("<") {
+			from, to := p.angles()
+			d.name += "<" + p.Text(from, to) + ">" // a specialization
+		}
+	}
+	final := p.Accept("final")
+	if final {
+		d.modifiers = append(d.modifiers, "final")
+	}
+	if p.Accept(":") {
+		from, to := p.Until(true, "{", ";")
+		if d.kind == "enum" {
+			d.typ = p.Text(from, to)
+		} else {
+			for _, part := range p.Split(from, to) {
+				b := base{}
+				i := part[0]
+			words:
+				for ; i < part[1]; i++ {
+					switch t := p.Toks[i]; {
+					case t.Is("public"), t.Is("protected"), t.Is("private"):
+						b.access = t.Text
+					case t.Is("virtual"):
+						b.virtual = true
+					default:
+						break words
+					}
+				}
+				b.typ = p.Text(i, part[1])
+				d.bases = append(d.bases, b)
+			}
+		}
+	}
+	switch {
+	case p.Peek(0).Is(";") && (d.name != "" || d.templated):
+		p.I++
+		return true
+	case !p.Peek(0).Is("{"):
+		p.I = save
+		d.kind, d.name, d.qualifier, d.modifiers, d.bases, d.typ = "", "", "", nil, nil, ""
+		return false
+	}
+	// The signature, without attributes and export macros.
+	sig := joinType(templateHead(d), d.kind, scoped, d.name)
+	if final {
+		sig += " final"
+	}
+	if d.typ != "" {
+		sig += " : " + d.typ
+	}
+	var bases []string
+	for _, b := range d.bases {
+		s := b.typ
+		if b.virtual {
+			s = "virtual " + s
+		}
+		bases = append(bases, joinType(b.access, s))
+	}
+	if len(bases) > 0 {
+		sig += " : " + strings.Join(bases, ", ")
+	}
+	d.sig = sig
+	if d.line == 0 {
+		p.at(d, start)
+	}
+	p.I++
+	d.body = true
+	if d.kind == "enum" {
+		d.children = p.enumMembers()
+	} else {
+		d.children, _ = p.body(d, true)
+	}
+	p.Accept("}")
+	p.end(d)
+	return true
+}
+
+// typeDeclarators returns a type defined by a declaration, with the
+// variables declared after its body: struct { ... } a, *b. The members of
+// an anonymous union or struct in a class are the class's, and the
+// enumerators of an anonymous enum are constants of its scope.
+func (p *parser) typeDeclarators(d *decl, owner *decl) []*decl {
+	var out []*decl
+	if d.name != "" {
+		out = append(out, d)
+	}
+	from, to := p.Until(true, ";")
+	p.Accept(";")
+	typ := joinType(d.kind, d.name)
+	if d.name == "" {
+		typ = d.kind + " { ... }"
+	}
+	for _, dc := range p.declarators(typ, from, to, true) {
+		v := &decl{kind: "variable", name: p.Toks[dc.at].Text, typ: dc.typ, sig: dc.text, body: true}
+		if owner != nil {
+			v.kind = "field"
+		}
+		p.at(v, dc.at)
+		v.endLine, v.endCol = p.Toks[dc.end-1].EndLine, p.Toks[dc.end-1].EndCol+1
+		out = append(out, v)
+	}
+	if d.name == "" && len(out) == 0 {
+		if d.kind == "enum" || owner != nil {
+			return d.children
+		}
+	}
+	return out
+}
+
+// enumMembers parses the enumerators of an enum body.
+func (p *parser) enumMembers() []*decl {
+	var out []*decl
+	for !p.EOF() && !p.Peek(0).Is("}") {
+		if t := p.Peek(0); trailing(t) {
+			if n := len(out); n > 0 && out[n-1].doc == "" {
+				out[n-1].doc = t.Text
+			}
+			p.I++
+			continue
+		}
+		c := &decl{kind: "enum_member", doc: p.doc(), body: true}
+		t := p.Peek(0)
+		if t.Kind != outline.Ident {
+			if !trailing(t) && !t.Is("}") {
+				p.Until(false, ",")
+				p.Accept(",")
+			}
+			continue
+		}
+		c.name = t.Text
+		p.at(c, p.I)
+		start := p.I
+		p.I++
+		p.attributes(c)
+		if p.Accept("=") {
+			p.Until(false, ",")
+		}
+		c.sig = p.Text(start, p.I)
+		p.end(c)
+		p.Accept(",")
+		out = append(out, c)
+	}
+	return out
+}
+
+// declaration parses a function or variable declaration: its specifiers
+// and type, then a declarator with a parameter list, or the variables it
+// declares.
+func (p *parser) declaration(d *decl, owner *decl) []*decl {
+	for {
+		t := p.Peek(0)
+		if t.Kind == outline.Ident && specifiers[t.Text] {
+			d.modifiers = append(d.modifiers, t.Text)
+			p.I++
+			if t.Is("explicit") && p.Peek(0).Is("(") {
+				p.Group()
+			}
+			continue
+		}
+		if p.attributes(d) {
+			continue
+		}
+		if t.Kind == outline.Ident && isMacroName(t.Text) && p.Peek(1).Kind == outline.Ident && p.typeRemains() {
+			d.attributes = append(d.attributes, t.Text) // an export or calling convention macro
+			p.I++
+			continue
+		}
+		break
+	}
+	name, params := p.declarator()
+	switch {
+	case name < 0:
+		p.skipStatement()
+		return nil
+	case params >= 0 && !p.isCall(params):
+		return p.function(d, owner, name, params)
+	}
+	return p.variables(d, owner)
+}
+
+// typeRemains reports whether the declaration at p.i, without its first
+// word, still has a type and a name.
+func (p *parser) typeRemains() bool {
+	n, angles := 0, 0
+	for i := p.I + 1; i < len(p.Toks); i++ {
+		t := p.Toks[i]
+		switch {
+		case t.Is("<"):
+			angles++
+		case t.Is(">") && angles > 0:
+			angles--
+		case angles > 0:
+		case t.Kind == outline.Ident:
+			n++
+		case t.Is("::"), t.Is("*"), t.Is("&"), t.Is("&&"), t.Is("~"):
+		default:
+			return n >= 2
+		}
+	}
+	return n >= 2
+}
+
+// declarator scans the first declarator of the declaration at p.i. It
+// returns the index of the declared name, or -1, and that of the opening
+// parenthesis of a function's parameters, or -1.
+func (p *parser) declarator() (name, params int) {
+	name = -1
+	angles := 0
+	for i := p.I; i < len(p.Toks); i++ {
+		t := p.Toks[i]
+		switch {
+		case angles > 0:
+			switch {
+			case t.Is("<"):
+				angles++
+			case t.Is(">"):
+				angles--
+			case t.Is(";"), t.Is("{"), t.Is("}"):
+				return name, -1
+			case t.Opens():
+				i = p.Skip(i) - 1
+			}
+		case t.Is("operator"):
+			// The name of an operator runs to its parameters: operator(),
+			// operator new[], operator<<, operator const char*.
+			j := i + 1
+			if j+1 < len(p.Toks) && p.Toks[j].Is("(") && p.Toks[j+1].Is(")") {
+				j += 2
+			}
+			for j < len(p.Toks) && !p.Toks[j].Is("(") && !p.Toks[j].Is(";") && !p.Toks[j].Is("{") {
+				j++
+			}
+			if j >= len(p.Toks) || !p.Toks[j].Is("(") {
+				return -1, -1
+			}
+			return i, j
+		case t.Is("<") && i > p.I && p.Toks[i-1].Kind == outline.Ident:
+			angles++
+		case t.Is("[") && i+1 < len(p.Toks) && p.Toks[i+1].Is("["):
+			i = p.Skip(i) - 1
+		case t.Is("(") && i > p.I && typeOperators[p.Toks[i-1].Text]:
+			i = p.Skip(i) - 1 // decltype(x), __attribute__((x))
+		case t.Is("("):
+			if i+1 < len(p.Toks) && (p.Toks[i+1].Is("*") || p.Toks[i+1].Is("&") || p.Toks[i+1].Is("^") ||
+				p.Toks[i+1].Kind == outline.Ident && i+2 < len(p.Toks) && p.Toks[i+2].Is("::")) {
+				// A declarator in parentheses: void (*fp)(int), a
+				// variable.
+				end := p.Skip(i)
+				if at, ok := p.declName(i, min(end, len(p.Toks))); ok {
+					name = at
+				}
+				return name, -1
+			}
+			if name < 0 || name != i-1 {
+				return -1, -1
+			}
+			return name, i
+		case t.Is("["), t.Is(";"), t.Is("="), t.Is(","), t.Is("{"), t.Is(":"), t.Closes():
+			return name, -1
+		case t.Kind == outline.Ident && !keywords[t.Text] && !typeOperators[t.Text]:
+			name = i
+		}
+	}
+	return name, -1
+}
+
+// typeOperators are the words followed by a parenthesized operand within
+// a type or declaration.
+var typeOperators = outline.Set(`decltype typeof __typeof__ __typeof sizeof alignof _Alignof alignas _Atomic
+	__attribute__ __declspec __asm__ asm noexcept`)
+
+// isCall reports whether the parenthesized group at i is the initializer
+// of a variable rather than a parameter list: Widget w(42, "x").
+func (p *parser) isCall(i int) bool {
+	if i+1 >= len(p.Toks) {
+		return false
+	}
+	t := p.Toks[i+1]
+	return t.Kind == outline.Number || t.Kind == outline.String || t.Kind == outline.Char || t.Is("{")
+}
+
+// function parses the rest of a function declaration or definition, from
+// its name at name and its parameter list at params.
+func (p *parser) function(d *decl, owner *decl, name, params int) []*decl {
+	typeFrom := p.I
+	nameFrom := name
+	if p.Toks[name].Is("operator") {
+		d.name = "operator" + spaceOperator(p.Name(name+1, params))
+	} else {
+		d.name = p.Toks[name].Text
+	}
+	if nameFrom > typeFrom && p.Toks[nameFrom-1].Is("~") {
+		nameFrom--
+		d.name = "~" + d.name
+	}
+	// The qualifier of an out-of-line definition: A::B<T>::name.
+	qualFrom := nameFrom
+	for qualFrom-2 >= typeFrom && p.Toks[qualFrom-1].Is("::") {
+		j := qualFrom - 2
+		if p.Toks[j].Is(">") {
+			for depth := 0; j >= typeFrom; j-- {
+				if p.Toks[j].Is(">") {
+					depth++
+				} else if p.Toks[j].Is("<") {
+					if depth--; depth == 0 {
+						break
+					}
+				}
+			}
+			j--
+		}
+		if j < typeFrom || p.Toks[j].Kind != outline.Ident {
+			break
+		}
+		qualFrom = j
+	}
+	if qualFrom == typeFrom+1 && p.Toks[typeFrom].Is("::") {
+		qualFrom = typeFrom
+	}
+	if qualFrom < nameFrom {
+		d.qualifier = stripTemplateArgs(strings.Trim(p.Name(qualFrom, nameFrom), ":"))
+	}
+	p.at(d, name)
+	d.returns = p.Text(typeFrom, qualFrom)
+	cls := d.qualifier[strings.LastIndex(d.qualifier, ":")+1:]
+	switch {
+	case strings.HasPrefix(d.name, "~"):
+		d.kind = "destructor"
+	case strings.HasPrefix(d.name, "operator"):
+		d.kind = "operator"
+	case d.returns == "" && (owner != nil && d.name == baseName(owner.name) || d.qualifier != "" && d.name == cls):
+		d.kind = "constructor"
+	case d.returns == "":
+		// A function-like macro used as a statement: TEST(a, b) { ... }
+		// or DEFINE_THING(x);
+		p.I = params
+		p.skipStatement()
+		return nil
+	case owner != nil:
+		d.kind = "method"
+	default:
+		d.kind = "function"
+	}
+	lead := len(d.modifiers)
+	p.I = params
+	from, to := p.Group()
+	d.params = p.params(from, to)
+	sigEnd := p.I
+	// The trailing parts: cv and ref qualifiers, noexcept, a trailing
+	// return type, override and final, = 0, = default, = delete, a
+	// member initializer list, and the body.
+trailing:
+	for !p.EOF() {
+		t := p.Peek(0)
+		switch {
+		case t.Is("const"), t.Is("override"), t.Is("final"), t.Is("noexcept"):
+			d.modifiers = append(d.modifiers, t.Text)
+			p.I++
+			if t.Is("noexcept") && p.Peek(0).Is("(") {
+				p.Group()
+			}
+			sigEnd = p.I
+		case t.Is("volatile"), t.Is("&"), t.Is("&&"), t.Is("throw"):
+			p.I++
+			if t.Is("throw") && p.Peek(0).Is("(") {
+				p.Group()
+			}
+			sigEnd = p.I
+		case t.Is("->"):
+			p.I++
+			from, to := p.Until(true, "{", ";", "=", "override", "final", "requires")
+			d.returns = p.Text(from, to)
+			sigEnd = p.I
+		case t.Is("requires"):
+			p.I++
+			p.Until(true, "{", ";", "=")
+			sigEnd = p.I
+		case p.attributes(d):
+		case t.Is("try") && (p.Peek(1).Is(":") || p.Peek(1).Is("{")):
+			p.I++
+		case t.Kind == outline.Ident && p.Peek(1).Is("("):
+			p.I++
+			p.Group() // a macro with arguments: __THROW_IF(x)
+		case t.Kind == outline.Ident:
+			p.I++ // a macro: Q_DECL_OVERRIDE, __THROW
+		default:
+			break trailing
+		}
+	}
+	switch t := p.Peek(0); {
+	case t.Is("="):
+		p.I++
+		switch w := p.Peek(0).Text; w {
+		case "0":
+			d.modifiers = append(d.modifiers, "pure")
+		case "default", "delete":
+			d.modifiers = append(d.modifiers, w)
+			d.body = true
+		}
+		sigEnd = p.I + 1
+		p.Until(false, ";")
+		p.Accept(";")
+	case t.Is(":"):
+		p.skipInitializers()
+		fallthrough
+	case t.Is("{"):
+		if p.Peek(0).Is("{") {
+			p.Group()
+			d.body = true
+		}
+		for p.Peek(0).Is("catch") {
+			p.I++
+			p.Group()
+			if p.Peek(0).Is("{") {
+				p.Group()
+			}
+		}
+	default:
+		p.Until(false, ";")
+		p.Accept(";")
+	}
+	p.end(d)
+	d.sig = joinType(templateHead(d), strings.Join(d.modifiers[:lead], " "), p.Text(typeFrom, sigEnd))
+	return []*decl{d}
+}
+
+// templateHead returns the template<...> of a declaration, "" for none.
+func templateHead(d *decl) string {
+	if !d.templated {
+		return ""
+	}
+	var tps []string
+	for _, tp := range d.template {
+		s := joinType(tp.Kind, tp.Name)
+		if tp.Default != "" {
+			s += " = " + tp.Default
+		}
+		tps = append(tps, s)
+	}
+	return "template <" + strings.Join(tps, ", ") + ">"
+}
+
+// joinType joins the parts of a declaration with spaces, leaving out empty
+// ones; none goes before brackets.
+func joinType(parts ...string) string {
+	var b strings.Builder
+	for _, s := range parts {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		if b.Len() > 0 && !strings.HasPrefix(s, "[") && !strings.HasPrefix(s, "(") && !strings.HasPrefix(s, ")") {
+			b.WriteByte(' ')
+		}
+		b.WriteString(s)
+	}
+	return b.String()
+}
+
+// declarator is one declarator of a declaration.
+type dclr struct {
+	at        int    // the name
+	end       int    // after the last token
+	qualifier string // of a name defined out of line: Foo::count
+	typ       string // the declared type
+	text      string // the declaration, without any initializer
+	init      string
+}
+
+// declarators parses the declarators in tokens [from, to), split at
+// commas. base is the type specifiers they share; when it is "", the first
+// declarator holds them, and the others take those up to the first * or &.
+func (p *parser) declarators(base string, from, to int, inits bool) []dclr {
+	var out []dclr
+	for k, part := range p.Split(from, to) {
+		end := p.Find(part[0], part[1], "=")
+		if br := p.Find(part[0], end, "{"); br < end {
+			end = br // a brace initializer
+		}
+		if co := p.Find(part[0], end, ":"); co < end {
+			end = co // a bit-field's width
+		}
+		at, ok := p.declName(part[0], end)
+		if !ok {
+			continue
+		}
+		init := ""
+		if inits && at+1 < end && p.Toks[at+1].Is("(") && p.Find(part[0], end, p.Toks[at].Text) == at {
+			// A direct initializer: Widget w(42).
+			close := min(p.Skip(at+1), end)
+			init = p.Text(at+2, close-1)
+			end = at + 1
+		}
+		q := at
+		for q-2 >= part[0] && p.Toks[q-1].Is("::") && p.Toks[q-2].Kind == outline.Ident {
+			q -= 2
+		}
+		dc := dclr{at: at, end: part[1], qualifier: strings.TrimSuffix(p.Name(q, at), "::")}
+		pre, post := p.Text(part[0], q), p.Text(at+1, end)
+		if k == 0 && base == "" {
+			base = pre
+			if i := strings.IndexAny(base, "*&("); i >= 0 {
+				base = strings.TrimSpace(base[:i])
+			}
+			dc.typ = joinType(pre, post)
+			dc.text = p.Text(part[0], end)
+		} else {
+			dc.typ = joinType(base, pre, post)
+			dc.text = joinType(base, p.Text(part[0], end))
+		}
+		dc.init = init
+		if end < part[1] && p.Toks[end].Is("=") {
+			dc.init = p.Text(end+1, part[1])
+		}
+		out = append(out, dc)
+	}
+	return out
+}
+
+// variables parses the variables or fields a declaration declares. A
+// variable declared extern, and a static data member, are declarations of
+// a definition elsewhere.
+func (p *parser) variables(d *decl, owner *decl) []*decl {
+	kind := "variable"
+	if owner != nil {
+		kind = "field"
+	}
+	from, to := p.Until(true, ";")
+	p.Accept(";")
+	var out []*decl
+	for _, dc := range p.declarators("", from, to, true) {
+		if dc.typ == "" {
+			continue
+		}
+		v := &decl{
+			kind: kind, name: p.Toks[dc.at].Text, qualifier: stripTemplateArgs(dc.qualifier), doc: d.doc,
+			modifiers: d.modifiers, attributes: d.attributes, linkage: d.linkage, template: d.template, templated: d.templated,
+			typ: dc.typ, body: true,
+		}
+		p.at(v, dc.at)
+		v.endLine, v.endCol = p.Toks[dc.end-1].EndLine, p.Toks[dc.end-1].EndCol+1
+		switch {
+		case v.has("extern") && dc.init == "":
+			v.body = false
+		case owner != nil && v.has("static") && !v.has("inline") && !v.has("constexpr"):
+			v.body = false
+		}
+		v.sig = joinType(templateHead(v), strings.Join(v.modifiers, " "), dc.text)
+		if dc.init != "" && len(dc.init) <= 60 && (v.has("constexpr") || strings.HasPrefix(v.typ, "const ")) {
+			v.sig += " = " + dc.init
+		}
+		out = append(out, v)
+	}
+	return out
+}
+
+// baseName returns a class name without its template arguments.
+func baseName(name string) string {
+	if i := strings.IndexByte(name, '<'); i >= 0 {
+		return name[:i]
+	}
+	return name
+}
+
+// stripTemplateArgs removes the template arguments of a qualified name:
+// Foo::Bar for Foo<T>::Bar.
+func stripTemplateArgs(name string) string {
+	var b strings.Builder
+	depth := 0
+	for _, c := range name {
+		switch {
+		case c == '<':
+			depth++
+		case c == '>' && depth > 0:
+			depth--
+		case depth == 0:
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+// spaceOperator spells an operator name: += as is, conversions and new
+// and delete with a space.
+func spaceOperator(s string) string {
+	if s != "" && isIdentStart(s[0]) {
+		return " " + s
+	}
+	return s
+}
+
+// skipInitializers skips a constructor's member initializer list, up to
+// the body. An opening brace after a closing bracket starts the body; one
+// after a name initializes a member.
+func (p *parser) skipInitializers() {
+	p.I++
+	for !p.EOF() {
+		t := p.Peek(0)
+		if t.Is("{") && p.I > 0 && (p.Toks[p.I-1].Is(")") || p.Toks[p.I-1].Is("}")) || t.Is(";") {
+			return
+		}
+		if t.Opens() {
+			p.Group()
+			continue
+		}
+		p.I++
+	}
+}
+
+// params parses the parameter list in tokens [from, to).
+func (p *parser) params(from, to int) []param {
+	var out []param
+	for _, part := range p.Split(from, to) {
+		if to := part[1]; to-part[0] == 1 && p.Toks[part[0]].Is("void") {
+			continue
+		}
+		eq := p.Find(part[0], part[1], "=")
+		pr := param{}
+		if eq < part[1] {
+			pr.Default = p.Text(eq+1, part[1])
+		}
+		if at, ok := p.paramName(part[0], eq); ok {
+			pr.Name = p.Toks[at].Text
+			pr.Type = joinType(p.Text(part[0], at), p.Text(at+1, eq))
+		} else {
+			pr.Type = p.Text(part[0], eq)
+		}
+		if pr.Type == "..." {
+			pr.Name, pr.Type = "...", ""
+		}
+		out = append(out, pr)
+	}
+	return out
+}
+
+// paramName finds the name of a parameter in tokens [from, to): the last
+// token, before any array bounds, or the name in a parenthesized
+// declarator, when what comes before it is a type.
+func (p *parser) paramName(from, to int) (int, bool) {
+	if to-from < 2 {
+		return 0, false
+	}
+	for i := from; i < to; i++ {
+		if p.Toks[i].Is("(") && i+1 < to && (p.Toks[i+1].Is("*") || p.Toks[i+1].Is("&") || p.Toks[i+1].Is("^")) {
+			end := p.Skip(i)
+			for j := i + 1; j < min(end, len(p.Toks))-1; j++ {
+				if t := p.Toks[j]; t.Kind == outline.Ident && !keywords[t.Text] && !p.Toks[j+1].Is("::") {
+					return j, true
+				}
+			}
+			return 0, false
+		}
+	}
+	last := to - 1
+	for last > from && p.Toks[last].Is("]") {
+		depth := 0
+		for ; last > from; last-- {
+			if p.Toks[last].Is("]") {
+				depth++
+			} else if p.Toks[last].Is("[") {
+				if depth--; depth == 0 {
+					break
+				}
+			}
+		}
+		last--
+	}
+	t := p.Toks[last]
+	if t.Kind != outline.Ident || keywords[t.Text] || last == from {
+		return 0, false
+	}
+	prev := p.Toks[last-1]
+	if prev.Is("::") || prev.Is("struct") || prev.Is("enum") || prev.Is("union") || prev.Is("class") {
+		return 0, false
+	}
+	return last, true
+}
+
+// declName finds the name declared by a declarator in tokens [from, to):
+// the last name outside brackets, or the one in a parenthesized
+// declarator.
+func (p *parser) declName(from, to int) (int, bool) {
+	at := -1
+	for i := from; i < to; i++ {
+		t := p.Toks[i]
+		switch {
+		case t.Is("("):
+			end := p.Skip(i)
+			if i+1 < to && (p.Toks[i+1].Is("*") || p.Toks[i+1].Is("&") || p.Toks[i+1].Is("^") || p.Toks[i+1].Kind == outline.Ident && i+2 < to && p.Toks[i+2].Is("::")) {
+				for j := i + 1; j < min(end, len(p.Toks))-1; j++ {
+					if t := p.Toks[j]; t.Kind == outline.Ident && !keywords[t.Text] && !p.Toks[j+1].Is("::") {
+						return j, true
+					}
+				}
+				return -1, false
+			}
+			i = end - 1
+		case t.Is("["), t.Is("{"), t.Is("<"):
+			if t.Is("<") {
+				depth := 0
+				for ; i < to; i++ {
+					if p.Toks[i].Is("<") {
+						depth++
+					} else if p.Toks[i].Is(">") {
+						if depth--; depth == 0 {
+							break
+						}
+					}
+				}
+				continue
+			}
+			i = p.Skip(i) - 1
+		case t.Is(":"):
+			return at, at >= 0 // a bit-field's width
+		case t.Kind == outline.Ident && !keywords[t.Text] && !typeOperators[t.Text]:
+			if i+1 < to && p.Toks[i+1].Is("::") {
+				continue
+			}
+			at = i
+		}
+	}
+	return at, at >= 0
+}
+
+// macroCall reports whether the tokens at p.i are a macro invoked as a
+// declaration, without a semicolon: Q_OBJECT, DECLARE_THING(Foo). The
+// invocation is skipped.
+func (p *parser) macroCall() bool {
+	t := p.Peek(0)
+	if t.Kind != outline.Ident || !isMacroName(t.Text) {
+		return false
+	}
+	j := p.I + 1
+	if j < len(p.Toks) && p.Toks[j].Is("(") {
+		j = p.Skip(j)
+	}
+	if j >= len(p.Toks) {
+		p.I = j
+		return true
+	}
+	next := p.Toks[j]
+	if next.Line == p.Toks[j-1].EndLine || next.Is(";") || next.Is("{") || next.Is(":") {
+		return false
+	}
+	p.I = j
+	return true
+}
+
+// isMacroName reports whether a name is spelled like a macro: capitals,
+// digits and underscores, with at least two letters.
+func isMacroName(s string) bool {
+	letters := 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c >= 'A' && c <= 'Z':
+			letters++
+		case c == '_' || c >= '0' && c <= '9':
+		default:
+			return false
+		}
+	}
+	return letters >= 2
+}
+
+// templateParams parses a template parameter list, the <...> at p.i.
+func (p *parser) templateParams() []typeParam {
+	from, to := p.angles()
+	out := []typeParam{}
+	for _, part := range p.Split(from, to) {
+		i, end := part[0], part[1]
+		eq := p.Find(i, end, "=")
+		tp := typeParam{}
+		if eq < end {
+			tp.Default = p.Text(eq+1, end)
+		}
+		last := eq - 1
+		if last >= i && p.Toks[last].Kind == outline.Ident && last > i && !keywords[p.Toks[last].Text] {
+			tp.Name = p.Toks[last].Text
+			tp.Kind = p.Text(i, last)
+		} else {
+			tp.Kind = p.Text(i, eq)
+		}
+		tp.Kind = strings.TrimSpace(tp.Kind)
+		out = append(out, tp)
+	}
+	return out
+}
+
+// angles skips the angle-bracketed list opening at p.i and returns the
+// token range inside it.
+func (p *parser) angles() (from, to int) {
+	p.I++
+	from = p.I
+	depth := 0
+	for !p.EOF() {
+		t := p.Peek(0)
+		switch {
+		case t.Is(">") && depth == 0:
+			to = p.I
+			p.I++
+			return from, to
+		case t.Is("<"):
+			depth++
+		case t.Is(">"):
+			depth--
+		case t.Opens():
+			p.Group()
+			continue
+		case t.Closes(), t.Is(";"):
+			return from, p.I
+		}
+		p.I++
+	}
+	return from, p.I
+}
+
+// attributes reads the attribute specifiers at p.i into d: [[...]],
+// __attribute__((...)), __declspec(...) and alignas(...). It reports
+// whether there were any.
+func (p *parser) attributes(d *decl) bool {
+	found := false
+	for {
+		t := p.Peek(0)
+		switch {
+		case t.Is("[") && p.Peek(1).Is("["):
+			from, to := p.Group()
+			for _, part := range p.Split(from+1, to-1) {
+				d.attributes = append(d.attributes, p.Text(part[0], part[1]))
+			}
+		case (t.Is("__attribute__") || t.Is("__declspec") || t.Is("alignas") || t.Is("__extension__")) && p.Peek(1).Is("("):
+			p.I++
+			from, to := p.Group()
+			text := p.Text(from, to)
+			if t.Is("__attribute__") {
+				text = strings.TrimSuffix(strings.TrimPrefix(text, "("), ")")
+			} else {
+				text = t.Text + "(" + text + ")"
+			}
+			d.attributes = append(d.attributes, text)
+		case t.Is("__extension__"):
+			p.I++
+		default:
+			return found
+		}
+		found = true
+	}
+}
+
+// skipStatement skips to the end of a statement, or past a braced block.
+func (p *parser) skipStatement() {
+	for !p.EOF() {
+		t := p.Peek(0)
+		switch {
+		case t.Is(";"):
+			p.I++
+			return
+		case t.Is("{"):
+			p.Group()
+			p.Accept(";")
+			return
+		case t.Closes():
+			return
+		case t.Opens():
+			p.Group()
+			continue
+		}
+		p.I++
+	}
+}
+
+// Text returns tokens [from, to) as written, with the whitespace between
+// them collapsed to single spaces and doc comments left out.
+func (p *parser) Text(from, to int) string {
+	to = min(to, len(p.Toks))
+	var b strings.Builder
+	var prev *token
+	for i := from; i < to; i++ {
+		t := &p.Toks[i]
+		if t.Kind == outline.Doc {
+			continue
+		}
+		if prev != nil && (t.Pos > prev.Pos+len(prev.Text) || t.Line != prev.EndLine) {
+			b.WriteByte(' ')
+		}
+		b.WriteString(t.Text)
+		prev = t
+	}
+	return b.String()
+}
+
+// end sets the end of d to the last consumed token.
+func (p *parser) end(d *decl) {
+	t := p.Last()
+	d.endLine, d.endCol = t.EndLine, t.EndCol+1
+}
+
+// at sets the position of d to the token at i.
+func (p *parser) at(d *decl, i int) {
+	if i < len(p.Toks) {
+		d.line, d.col = p.Toks[i].Line, p.Toks[i].Col+1
+	}
+}
+
+// doc reads the doc comments before a declaration: a run of /// or //!
+// lines, or the last /** */ or /*! */ comment. Trailing comments, ///<,
+// belong to what comes before and are left.
+func (p *parser) doc() string {
+	var lines []string
+	last := 0
+	for !p.EOF() && p.Peek(0).Kind == outline.Doc && !trailing(p.Peek(0)) {
+		t := p.Peek(0)
+		if strings.HasPrefix(t.Text, "/*") || len(lines) > 0 && (t.Line > last+1 || strings.HasPrefix(lines[len(lines)-1], "/*")) {
+			lines = nil // a block comment, or a gap, starts over
+		}
+		lines = append(lines, t.Text)
+		last = t.EndLine
+		p.I++
+	}
+	return strings.Join(lines, "\n")
+}
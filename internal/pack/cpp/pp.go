@@ -0,0 +1,330 @@
+package cpp
+
+import (
+	"strconv"
+	"strings"
+)
+
+// preproc tracks the macros defined so far and the conditional directives
+// a file is inside.
+type preproc struct {
+	defines map[string]*define
+	conds   []cond
+}
+
+type define struct {
+	params []string // nil for object-like macros
+	body   string
+}
+
+// cond is an #if group: whether its current branch is active, and whether
+// one of its branches was taken.
+type cond struct {
+	active, taken, outer bool // outer: whether the group itself is in an active region
+}
+
+func newPreproc(defines map[string]string) *preproc {
+	p := &preproc{defines: map[string]*define{}}
+	for name, body := range defines {
+		p.defines[name] = &define{body: body}
+	}
+	return p
+}
+
+func (p *preproc) active() bool {
+	return len(p.conds) == 0 || p.conds[len(p.conds)-1].active
+}
+
+func (p *preproc) define(name string, params []string, body string) {
+	p.defines[name] = &define{params: params, body: body}
+}
+
+func (p *preproc) undef(name string) { delete(p.defines, name) }
+
+// conditional runs an #if, #ifdef, #ifndef, #elif, #elifdef, #elifndef,
+// #else or #endif. Unbalanced ones are ignored.
+func (p *preproc) conditional(name, rest string) {
+	rest = strings.TrimSpace(rest)
+	n := len(p.conds)
+	switch name {
+	case "if", "ifdef", "ifndef":
+		outer := p.active()
+		c := cond{outer: outer}
+		if outer {
+			c.active = p.test(name, rest)
+			c.taken = c.active
+		}
+		p.conds = append(p.conds, c)
+	case "elif", "elifdef", "elifndef":
+		if n == 0 {
+			return
+		}
+		c := &p.conds[n-1]
+		c.active = false
+		if c.outer && !c.taken {
+			c.active = p.test(strings.TrimPrefix(name, "el"), rest)
+			c.taken = c.active
+		}
+	case "else":
+		if n == 0 {
+			return
+		}
+		c := &p.conds[n-1]
+		c.active = c.outer && !c.taken
+		c.taken = true
+	case "endif":
+		if n > 0 {
+			p.conds = p.conds[:n-1]
+		}
+	}
+}
+
+// test evaluates the condition of an #if, #ifdef or #ifndef.
+func (p *preproc) test(name, rest string) bool {
+	switch name {
+	case "ifdef":
+		w, _ := directiveWord(rest)
+		return p.defines[w] != nil
+	case "ifndef":
+		w, _ := directiveWord(rest)
+		return p.defines[w] == nil
+	}
+	e := &expr{p: p, toks: exprTokens(rest)}
+	return e.ternary() != 0
+}
+
+// exprTokens splits the expression of an #if into tokens.
+func exprTokens(s string) []string {
+	var out []string
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case isIdent(c):
+			j := i
+			for j < len(s) && (isIdent(s[j]) || s[j] == '\'' && c >= '0' && c <= '9') {
+				j++
+			}
+			out = append(out, s[i:j])
+			i = j
+		case c == '\'':
+			j := i + 1
+			for j < len(s) && s[j] != '\'' {
+				if s[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			out = append(out, s[i:min(j+1, len(s))])
+			i = j + 1
+		default:
+			n := 1
+			for _, op := range []string{"&&", "||", "==", "!=", "<=", ">=", "<<", ">>"} {
+				if strings.HasPrefix(s[i:], op) {
+					n = 2
+					break
+				}
+			}
+			out = append(out, s[i:i+n])
+			i += n
+		}
+	}
+	return out
+}
+
+// expr evaluates an #if expression. Identifiers that are not macros are
+// 0, as are calls of function-like macros and __has_include.
+type expr struct {
+	p     *preproc
+	toks  []string
+	i     int
+	depth int // of macro expansion
+}
+
+func (e *expr) peek() string {
+	if e.i < len(e.toks) {
+		return e.toks[e.i]
+	}
+	return ""
+}
+
+func (e *expr) next() string {
+	t := e.peek()
+	e.i++
+	return t
+}
+
+func (e *expr) ternary() int64 {
+	c := e.binary(0)
+	if e.peek() != "?" {
+		return c
+	}
+	e.i++
+	a := e.ternary()
+	if e.peek() == ":" {
+		e.i++
+	}
+	b := e.ternary()
+	if c != 0 {
+		return a
+	}
+	return b
+}
+
+// precedence of the binary operators, lowest first.
+var precedence = map[string]int{
+	"||": 1, "&&": 2, "|": 3, "^": 4, "&": 5, "==": 6, "!=": 6,
+	"<": 7, ">": 7, "<=": 7, ">=": 7, "<<": 8, ">>": 8, "+": 9, "-": 9, "*": 10, "/": 10, "%": 10,
+}
+
+func (e *expr) binary(min int) int64 {
+	x := e.unary()
+	for {
+		op := e.peek()
+		prec, ok := precedence[op]
+		if !ok || prec <= min {
+			return x
+		}
+		e.i++
+		y := e.binary(prec)
+		x = apply(op, x, y)
+	}
+}
+
+func apply(op string, x, y int64) int64 {
+	b := func(v bool) int64 {
+		if v {
+			return 1
+		}
+		return 0
+	}
+	switch op {
+	case "||":
+		return b(x != 0 || y != 0)
+	case "&&":
+		return b(x != 0 && y != 0)
+	case "|":
+		return x | y
+	case "^":
+		return x ^ y
+	case "&":
+		return x & y
+	case "==":
+		return b(x == y)
+	case "!=":
+		return b(x != y)
+	case "<":
+		return b(x < y)
+	case ">":
+		return b(x > y)
+	case "<=":
+		return b(x <= y)
+	case ">=":
+		return b(x >= y)
+	case "<<":
+		return x << (uint64(y) & 63)
+	case ">>":
+		return x >> (uint64(y) & 63)
+	case "+":
+		return x + y
+	case "-":
+		return x - y
+	case "*":
+		return x * y
+	case "/", "%":
+		if y == 0 {
+			return 0
+		}
+		if op == "/" {
+			return x / y
+		}
+		return x % y
+	}
+	return 0
+}
+
+func (e *expr) unary() int64 {
+	switch t := e.next(); {
+	case t == "!":
+		if e.unary() == 0 {
+			return 1
+		}
+		return 0
+	case t == "-":
+		return -e.unary()
+	case t == "+":
+		return e.unary()
+	case t == "~":
+		return ^e.unary()
+	case t == "(":
+		v := e.ternary()
+		if e.peek() == ")" {
+			e.i++
+		}
+		return v
+	case t == "defined":
+		paren := e.peek() == "("
+		if paren {
+			e.i++
+		}
+		name := e.next()
+		if paren && e.peek() == ")" {
+			e.i++
+		}
+		if e.p.defines[name] != nil {
+			return 1
+		}
+		return 0
+	case t == "true":
+		return 1
+	case t == "":
+		return 0
+	case t[0] >= '0' && t[0] <= '9':
+		return number(t)
+	case t[0] == '\'':
+		if s := strings.Trim(t, "'"); s != "" {
+			return int64(s[len(s)-1])
+		}
+		return 0
+	case isIdentStart(t[0]):
+		if e.peek() == "(" {
+			e.skipGroup() // a function-like macro, or __has_include
+			return 0
+		}
+		d := e.p.defines[t]
+		if d == nil || d.params != nil || e.depth > 8 {
+			return 0
+		}
+		sub := &expr{p: e.p, toks: exprTokens(d.body), depth: e.depth + 1}
+		return sub.ternary()
+	}
+	return 0
+}
+
+func (e *expr) skipGroup() {
+	depth := 0
+	for e.i < len(e.toks) {
+		switch e.next() {
+		case "(":
+			depth++
+		case ")":
+			if depth--; depth == 0 {
+				return
+			}
+		}
+	}
+}
+
+// number parses an integer literal of an #if: decimal, hex, octal or
+// binary, with digit separators and suffixes.
+func number(t string) int64 {
+	t = strings.ReplaceAll(strings.ToLower(t), "'", "")
+	t = strings.TrimRight(t, "ul")
+	v, err := strconv.ParseInt(t, 0, 64)
+	if err != nil {
+		u, _ := strconv.ParseUint(t, 0, 64)
+		return int64(u)
+	}
+	return v
+}
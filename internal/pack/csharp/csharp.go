@@ -0,0 +1,505 @@
+// Package csharp is the language pack for C#. It reads sources without a
+// compiler: a token-level outline parser finds the namespaces, classes,
+// structs, records, interfaces, enums and delegates of a file, with their
+// constructors, methods, operators, properties, indexers, events and
+// fields.
+//
+// Each .csproj is a container, holding a container for each namespace its
+// sources declare; its package and project references are its imports.
+// The parts of a partial type merge into one symbol, emitted with the
+// first part and listing the files of every part in its extra_json, whose
+// members are all its members. Base lists become extends and implements
+// relations. /// XML doc comments are parsed: <summary>, <param>,
+// <returns> and the other elements become the sections of doc_fmt, and
+// the parsed comment is kept in extra_json.
+package csharp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+
+	"github.com/ChaseHampton/cargoworker/internal/ir"
+	"github.com/ChaseHampton/cargoworker/internal/pack"
+	"github.com/ChaseHampton/cargoworker/internal/pack/outline"
+)
+
+func init() {
+	pack.Register("csharp", New())
+}
+
+// globalNamespace names the container of declarations outside any
+// namespace.
+const globalNamespace = "(global)"
+
+// Pack extracts C#. A namespace and the parts of a partial type span the
+// directories of a project, so containers and symbol ids are kept in tables
+// across units, and each project's layout keeps its parsed sources.
+type Pack struct {
+	mu      sync.Mutex
+	layouts map[string]*layout // by input root
+	tables  *outline.Tables
+}
+
+func New() *Pack {
+	return &Pack{layouts: map[string]*layout{}, tables: outline.NewTables()}
+}
+
+func (p *Pack) Name() string { return "csharp" }
+
+var _ pack.Pack = (*Pack)(nil)
+
+// Extract outlines the files of a unit.
+func (p *Pack) Extract(ctx context.Context, u pack.Unit) (*ir.Fragment, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	l, ok := p.layouts[u.Root]
+	if !ok {
+		l = newLayout(u.Root)
+		p.layouts[u.Root] = l
+	}
+	var files []string
+	for _, rel := range u.Files {
+		if strings.HasSuffix(rel, ".cs") {
+			files = append(files, rel)
+		}
+	}
+	proj := l.project(u.Dir)
+	x := &extraction{l: l, u: u, proj: proj, sc: l.scope(proj, u.Dir, files), frag: p.tables.Fragment(u)}
+	for _, rel := range files {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		b, err := os.ReadFile(filepath.Join(u.Root, filepath.FromSlash(rel)))
+		if err != nil {
+			return nil, err
+		}
+		f := l.parse(rel)
+		if f == nil {
+			f = parse(string(b))
+		}
+		x.file(rel, b, f)
+	}
+	return x.frag.Fragment, nil
+}
+
+// extraction is the state of one Extract call.
+type extraction struct {
+	l    *layout
+	u    pack.Unit
+	proj *project
+	sc   *scope
+	frag *outline.Fragment // containers of the fragment, by key
+}
+
+func qualify(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
+
+// projectExtra is the extra_json of a project container.
+type projectExtra struct {
+	File             string       `json:"file"`
+	Dir              string       `json:"dir"`
+	Sdk              string       `json:"sdk,omitempty"`
+	TargetFrameworks []string     `json:"target_frameworks,omitempty"`
+	RootNamespace    string       `json:"root_namespace,omitempty"`
+	OutputType       string       `json:"output_type,omitempty"`
+	Packages         []packageRef `json:"packages,omitempty"`
+}
+
+// projectContainer returns the container of a project, making it with the
+// imports of its package and project references.
+func (x *extraction) projectContainer() uuid.UUID {
+	proj := x.proj
+	made := false
+	id := x.frag.Container("project:"+proj.dir, func() ir.Container {
+		made = true
+		c := ir.Container{Name: proj.name, FullName: proj.name, Kind: "project", VersionTag: proj.version, DocRaw: proj.description, DocFmt: proj.description}
+		b, _ := json.Marshal(projectExtra{
+			File: proj.file, Dir: proj.dir, Sdk: proj.sdk, TargetFrameworks: proj.frameworks,
+			RootNamespace: proj.rootNS, OutputType: proj.outputType, Packages: proj.packages,
+		})
+		c.ExtraJson = string(b)
+		return c
+	})
+	if made {
+		type details struct {
+			File    string `json:"file"`
+			Kind    string `json:"kind"` // package or project
+			Version string `json:"version,omitempty"`
+		}
+		for _, r := range proj.packages {
+			b, _ := json.Marshal(details{File: proj.file, Kind: "package", Version: r.Version})
+			x.frag.Imports = append(x.frag.Imports, ir.Import{ContainerId: id, Target: r.Name, DetailsJson: string(b)})
+		}
+		for _, r := range proj.references {
+			b, _ := json.Marshal(details{File: proj.file, Kind: "project"})
+			x.frag.Imports = append(x.frag.Imports, ir.Import{ContainerId: id, Target: r, DetailsJson: string(b)})
+		}
+	}
+	return id
+}
+
+// namespaceContainer returns the container of a namespace of the project,
+// or of the input root outside any project, making it.
+func (x *extraction) namespaceContainer(ns string) uuid.UUID {
+	var parent uuid.UUID
+	key := "namespace::" + ns
+	if x.proj != nil {
+		parent = x.projectContainer()
+		key = "namespace:" + x.proj.dir + ":" + ns
+	}
+	if ns == "" {
+		ns = globalNamespace
+	}
+	return x.frag.Container(key, func() ir.Container {
+		return ir.Container{ParentId: parent, Name: ns[strings.LastIndex(ns, ".")+1:], FullName: ns, Kind: "namespace"}
+	})
+}
+
+func (x *extraction) file(rel string, src []byte, f *file) {
+	ns := ""
+	if len(f.decls) > 0 && f.decls[0].kind == "namespace" {
+		ns = f.decls[0].name
+	}
+	cid := x.namespaceContainer(ns)
+	fid := x.frag.File(cid, rel, src)
+	x.imports(cid, rel, f.usings)
+	res := &resolver{aliases: map[string]string{}, types: x.sc.types}
+	res.use(x.sc.globals)
+	res.use(f.usings)
+	e := &emitter{x: x, fid: fid, rel: rel}
+	e.decls(nil, nil, "", uuid.Nil, f.decls, res, nil)
+}
+
+// imports records the using directives of a file or namespace block.
+func (x *extraction) imports(cid uuid.UUID, rel string, usings []usingDecl) {
+	type details struct {
+		File   string `json:"file"`
+		Line   int    `json:"line"`
+		Static bool   `json:"static,omitempty"`
+		Global bool   `json:"global,omitempty"`
+		Stdlib bool   `json:"stdlib,omitempty"`
+	}
+	for _, u := range usings {
+		target := strings.TrimPrefix(u.path, "global::")
+		b, _ := json.Marshal(details{File: rel, Line: u.line, Static: u.static, Global: u.global, Stdlib: isStdlib(erasure(target))})
+		x.frag.Imports = append(x.frag.Imports, ir.Import{ContainerId: cid, Target: target, Alias: u.alias, DetailsJson: string(b)})
+	}
+}
+
+// emitter turns the declarations of a file into symbols.
+type emitter struct {
+	x   *extraction
+	fid uuid.UUID
+	rel string
+}
+
+// extra is the extra_json of a symbol.
+type extra struct {
+	Modifiers  []string `json:"modifiers,omitempty"`
+	Attributes []string `json:"attributes,omitempty"`
+	Explicit   string   `json:"explicit,omitempty"` // the interface an explicit implementation is of
+	Files      []string `json:"files,omitempty"`    // of the parts of a partial type
+	Doc        *xmldoc  `json:"doc,omitempty"`
+}
+
+// isType reports whether a kind of declaration is a type, whose members
+// are its children.
+func isType(kind string) bool {
+	switch kind {
+	case "class", "struct", "interface", "enum", "record":
+		return true
+	}
+	return false
+}
+
+// decls emits declarations into the namespace container cid, or the
+// global namespace's, made when needed, if it is nil. owner is the type
+// they are members of, with its symbol id, and params the type parameters
+// in scope; all are nil outside types.
+func (e *emitter) decls(owner *decl, ownerID *uuid.UUID, prefix string, cid uuid.UUID, decls []*decl, res *resolver, params map[string]bool) {
+	offset := 0
+	if owner != nil {
+		if pt, ok := e.x.sc.part(prefix, owner); ok {
+			offset = pt.offset
+		}
+	}
+	for i, d := range decls {
+		if d.name == "" {
+			continue
+		}
+		full := qualify(prefix, d.name)
+		if d.kind == "namespace" {
+			ncid := e.x.namespaceContainer(full)
+			e.x.imports(ncid, e.rel, d.usings)
+			e.decls(nil, nil, full, ncid, d.children, res.within(full, d.usings), nil)
+			continue
+		}
+		if cid == uuid.Nil {
+			cid = e.x.namespaceContainer("")
+		}
+		scope := maps.Clone(params)
+		if scope == nil {
+			scope = map[string]bool{}
+		}
+		for _, tp := range d.typeParams {
+			scope[tp.Name] = true
+		}
+		if isType(d.kind) && !e.x.sc.primary(full, d) {
+			// A later part of a partial type: only its members are new.
+			id := e.x.frag.SymbolID(full)
+			e.decls(d, &id, full, cid, d.children, res.scope(e.nested(full, d)), scope)
+			continue
+		}
+		id := e.x.frag.NewSymbolID(full)
+		merged := e.merge(full, d)
+		sym := ir.Symbol{
+			Id: id, ContainerId: cid, Name: d.name, FullName: full, Kind: d.kind,
+			Visibility: visibility(merged, owner), OriginFileId: e.fid,
+			StartLine: d.line, StartCol: d.col, EndLine: d.endLine, EndCol: d.endCol, DocRaw: merged.doc,
+		}
+		ex := extra{Modifiers: merged.modifiers, Attributes: merged.attributes, Explicit: d.explicit}
+		if parts := e.x.sc.parts[full]; len(parts) > 1 && isType(d.kind) {
+			for _, pt := range parts {
+				if len(ex.Files) == 0 || ex.Files[len(ex.Files)-1] != pt.rel {
+					ex.Files = append(ex.Files, pt.rel)
+				}
+			}
+		}
+		var doc *xmldoc
+		sym.DocFmt, doc = formatDoc(merged.doc)
+		ex.Doc = doc
+		if b, _ := json.Marshal(ex); string(b) != "{}" {
+			sym.ExtraJson = string(b)
+		}
+		e.x.frag.Symbols = append(e.x.frag.Symbols, sym)
+		if ownerID != nil {
+			e.x.frag.Members = append(e.x.frag.Members, ir.Member{Id: uuid.New(), OwnerSymbolId: *ownerID, ChildSymbolId: id, Order: offset + i})
+		}
+		inner := res
+		if isType(d.kind) {
+			inner = res.scope(e.nested(full, d))
+			e.relations(id, merged, inner)
+		}
+		e.signature(id, ownerID, merged, doc, inner, scope)
+		if len(d.children) > 0 {
+			e.decls(d, &id, full, cid, d.children, inner, scope)
+		}
+	}
+}
+
+// nested returns the types nested in a type, in all its parts.
+func (e *emitter) nested(full string, d *decl) map[string]string {
+	out := map[string]string{}
+	add := func(d *decl) {
+		for _, c := range d.children {
+			if isType(c.kind) {
+				out[c.name] = full + "." + c.name
+			}
+		}
+	}
+	add(d)
+	for _, pt := range e.x.sc.parts[full] {
+		add(pt.d)
+	}
+	return out
+}
+
+// merge returns the declaration of a type with what all its parts declare:
+// their modifiers, attributes and base types, and the first doc comment.
+// Other declarations are returned as they are.
+func (e *emitter) merge(full string, d *decl) *decl {
+	parts := e.x.sc.parts[full]
+	if len(parts) < 2 || !isType(d.kind) {
+		return d
+	}
+	m := *d
+	m.modifiers, m.attributes, m.bases = nil, nil, nil
+	has := func(list []string, s string) bool {
+		for _, x := range list {
+			if x == s {
+				return true
+			}
+		}
+		return false
+	}
+	for _, pt := range parts {
+		for _, s := range pt.d.modifiers {
+			if !has(m.modifiers, s) {
+				m.modifiers = append(m.modifiers, s)
+			}
+		}
+		m.attributes = append(m.attributes, pt.d.attributes...)
+		for _, s := range pt.d.bases {
+			if !has(m.bases, s) {
+				m.bases = append(m.bases, s)
+			}
+		}
+		if m.doc == "" {
+			m.doc = pt.d.doc
+		}
+		if len(m.typeParams) == 0 {
+			m.typeParams = pt.d.typeParams
+		}
+	}
+	return &m
+}
+
+// visibility maps modifiers onto a symbol's visibility. Types outside
+// types default to internal, members and nested types to private, and the
+// members of interfaces and enums, and explicit interface implementations,
+// are public. private protected is private to the assembly's subtypes,
+// protected internal public to it; both are taken as protected.
+func visibility(d *decl, owner *decl) string {
+	for _, v := range []string{"protected", "private", "internal", "public"} {
+		if d.has(v) {
+			return v
+		}
+	}
+	switch {
+	case owner == nil:
+		return "internal"
+	case d.kind == "enum_member", owner.kind == "interface", d.explicit != "":
+		return "public"
+	}
+	return "private"
+}
+
+// relations records the types a type derives from. An interface extends
+// the interfaces of its base list; a class or record extends the class in
+// it and implements the interfaces, and a struct implements all of them.
+// Base types outside the project are left to the typerefs.
+func (e *emitter) relations(id uuid.UUID, d *decl, res *resolver) {
+	for _, t := range d.bases {
+		r, ok := res.resolve(erasure(t))
+		if !ok || r.Symbol == "" || isStdlib(r.Symbol) || res.kind(r.Symbol) == "" {
+			continue
+		}
+		kind := "implements"
+		if d.kind == "interface" || res.kind(r.Symbol) != "interface" {
+			kind = "extends"
+		}
+		b, _ := json.Marshal(map[string]string{"file": e.rel, "type": t})
+		e.x.frag.Relations = append(e.x.frag.Relations, ir.Relation{
+			SourceSymbolId: id, Relation: kind, DstSymbolId: e.x.frag.SymbolID(r.Symbol), DetailsJson: string(b),
+		})
+	}
+}
+
+// baseKind guesses whether a base type is a class or an interface: by its
+// kind when the project declares it, else by .NET's I prefix.
+func baseKind(res *resolver, owner *decl, typ string) string {
+	if owner.kind == "interface" || owner.kind == "struct" {
+		return "interface"
+	}
+	if r, ok := res.resolve(erasure(typ)); ok && res.kind(r.Symbol) != "" {
+		if res.kind(r.Symbol) == "interface" {
+			return "interface"
+		}
+		return "class"
+	}
+	name := erasure(typ)
+	name = name[strings.LastIndex(name, ".")+1:]
+	if len(name) > 1 && name[0] == 'I' && name[1] >= 'A' && name[1] <= 'Z' {
+		return "interface"
+	}
+	return "class"
+}
+
+// thrown is an exception a member documents.
+type thrown struct {
+	Type string `json:"type"`
+	Desc string `json:"desc,omitempty"`
+}
+
+// signature records the signature and type references of a declaration.
+// params holds the type parameters in scope.
+func (e *emitter) signature(id uuid.UUID, owner *uuid.UUID, d *decl, doc *xmldoc, res *resolver, params map[string]bool) {
+	if d.sig == "" {
+		return
+	}
+	sig := ir.Signature{SymbolId: id, Text: d.sig}
+	type result struct {
+		Type string `json:"type"`
+	}
+	js := struct {
+		Receiver   string      `json:"receiver,omitempty"` // of an extension method
+		Params     []param     `json:"params,omitempty"`
+		Results    []result    `json:"results,omitempty"`
+		TypeParams []typeParam `json:"type_params,omitempty"`
+		Throws     []thrown    `json:"throws,omitempty"`
+	}{TypeParams: d.typeParams}
+	switch d.kind {
+	case "method", "constructor", "operator", "delegate", "indexer":
+		js.Params = d.params
+		if js.Params == nil {
+			js.Params = []param{}
+		}
+		if len(d.params) > 0 && d.params[0].Modifier == "this" && d.kind == "method" {
+			js.Receiver = d.params[0].Type
+		}
+		ret := d.returns
+		if d.kind == "indexer" {
+			ret = d.typ
+		}
+		if ret != "" && ret != "void" {
+			js.Results = []result{{ret}}
+		}
+		if doc != nil {
+			for _, ex := range doc.Exceptions {
+				js.Throws = append(js.Throws, thrown{Type: ex.Type, Desc: ex.Desc})
+			}
+		}
+		for i, pr := range d.params {
+			e.typerefs(res, id, fmt.Sprintf("param:%d", i), pr.Type, "", params)
+		}
+		e.typerefs(res, id, "result:0", ret, "", params)
+		for i, t := range js.Throws {
+			e.typerefs(res, id, fmt.Sprintf("throws:%d", i), t.Type, "class", params)
+		}
+	case "class", "struct", "interface", "enum", "record":
+		js.Params = d.params // of a primary constructor
+		bases, impls := 0, 0
+		for _, b := range d.bases {
+			if k := baseKind(res, d, b); k == "class" {
+				e.typerefs(res, id, fmt.Sprintf("base:%d", bases), b, k, params)
+				bases++
+			} else {
+				e.typerefs(res, id, fmt.Sprintf("implements:%d", impls), b, k, params)
+				impls++
+			}
+		}
+	case "field", "property", "event":
+		if owner != nil {
+			e.typerefs(res, *owner, "field:"+d.name, d.typ, "", params)
+		} else {
+			e.typerefs(res, id, "type", d.typ, "", params)
+		}
+	}
+	if b, _ := json.Marshal(js); string(b) != "{}" {
+		sig.Json = string(b)
+	}
+	e.x.frag.Signatures = append(e.x.frag.Signatures, sig)
+}
+
+func (e *emitter) typerefs(res *resolver, owner uuid.UUID, slot, typ, kind string, params map[string]bool) {
+	if typ == "" {
+		return
+	}
+	for i, r := range res.refs(typ, params) {
+		r.Type = kind
+		b, _ := json.Marshal(r)
+		e.x.frag.Typerefs = append(e.x.frag.Typerefs, ir.Typeref{Id: uuid.New(), OwnerSymbolId: owner, Slot: slot, Json: string(b), Order: i})
+	}
+}
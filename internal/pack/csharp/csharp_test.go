@@ -0,0 +1,413 @@
+package csharp
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/ChaseHampton/cargoworker/internal/ir"
+	"github.com/ChaseHampton/cargoworker/internal/pack/packtest"
+)
+
+var tree = map[string]string{
+	"Shop/Shop.csproj": `<Project Sdk="Microsoft.NET.Sdk">
+  <PropertyGroup>
+    <TargetFrameworks>net8.0;netstandard2.0</TargetFrameworks>
+    <AssemblyName>Acme.Shop</AssemblyName>
+    <RootNamespace>Acme.Shop</RootNamespace>
+    <Version>3.2.0</Version>
+    <Description>The shop.</Description>
+  </PropertyGroup>
+  <ItemGroup>
+    <PackageReference Include="Newtonsoft.Json" Version="13.0.3" />
+    <ProjectReference Include="..\Core\Core.csproj" />
+  </ItemGroup>
+</Project>
+`,
+	"Shop/Usings.cs": `global using Acme.Core;
+`,
+	"Shop/Cart.cs": `using System.Collections.Generic;
+using Json = Newtonsoft.Json;
+
+namespace Acme.Shop;
+
+/// <summary>
+/// A cart of <see cref="T:Acme.Shop.Line"/> items.
+/// </summary>
+/// <remarks>Carts are not thread-safe.</remarks>
+[Serializable]
+public partial class Cart : EntityBase, ICart
+{
+    private readonly List<Line> lines = new(), saved;
+
+    /// <summary>Makes an empty cart.</summary>
+    public Cart() : base(0) { }
+
+    /// <summary>Adds a line.</summary>
+    /// <param name="sku">The product, see <paramref name="qty"/>.</param>
+    /// <param name="qty">How many; <c>1</c> by default.</param>
+    /// <returns>The line added, or <see langword="null"/>.</returns>
+    /// <exception cref="ArgumentException">When <paramref name="qty"/> is negative.</exception>
+    public Line? Add(string sku, int qty = 1) => null;
+
+    public decimal Total { get; private set; } = 0m;
+
+    public event EventHandler<Line>? Changed;
+
+    public Line this[int index] => lines[index];
+
+    public static Cart operator +(Cart a, Line b) => a;
+
+    T ICart.Find<T>(string sku) where T : Line => default!;
+
+    ~Cart() { }
+}
+`,
+	"Shop/Parts/Cart.Lines.cs": `namespace Acme.Shop
+{
+    /// <summary>Ignored: the first part's doc wins.</summary>
+    sealed partial class Cart : IDisposable
+    {
+        public void Dispose() { }
+
+        public class Snapshot { }
+    }
+}
+`,
+	"Shop/Models.cs": `namespace Acme.Shop
+{
+    using Acme.Shop.Pricing;
+
+    /// <summary>Something that holds lines.</summary>
+    /// <typeparam name="T">The line type.</typeparam>
+    public interface ICart
+    {
+        decimal Total { get; }
+        event EventHandler<Line> Changed;
+        T Find<T>(string sku) where T : Line;
+    }
+
+    /// <summary>A line of a cart.</summary>
+    /// <param name="Sku">The product.</param>
+    public record Line(string Sku, int Qty = 1) : IPriced
+    {
+        public Price Price { get; init; }
+    }
+
+    public readonly record struct Coupon(string Code);
+
+    public struct Money : IEquatable<Money>
+    {
+        public const int Scale = 2;
+        public bool Equals(Money other) => true;
+    }
+
+    public enum State
+    {
+        /// <summary>Still open.</summary>
+        Open = 1,
+        [Obsolete] Closed,
+    }
+
+    public delegate void Notify<in T>(T item, ref int count);
+
+    public static class Extensions
+    {
+        /// <summary>
+        /// Counts lines.
+        /// <code>
+        /// var n = cart.Count();
+        ///
+        /// n++;
+        /// </code>
+        /// </summary>
+        public static int Count(this Cart cart) => 0;
+    }
+
+    namespace Pricing
+    {
+        public interface IPriced { }
+        public class Price { }
+    }
+}
+`,
+	"Core/Core.csproj": `<Project Sdk="Microsoft.NET.Sdk">
+  <PropertyGroup><TargetFramework>net8.0</TargetFramework></PropertyGroup>
+</Project>
+`,
+	"Core/EntityBase.cs": `namespace Acme.Core;
+
+/// Has an id.
+public abstract class EntityBase
+{
+    protected EntityBase(int id) { Id = id; }
+    public int Id { get; }
+#if DEBUG
+    public string Debug => "";
+#else
+    public string Debug => "{";
+#endif
+}
+`,
+	"Scratch/Program.cs": `using System;
+
+Console.WriteLine("hi");
+var x = Helper.Twice(2);
+
+static class Helper
+{
+    internal static int Twice(int n) => n * 2;
+}
+`,
+}
+
+func TestExtract(t *testing.T) {
+	f := packtest.ExtractTree(t, tree, packtest.ByExt(map[string]string{".cs": "csharp"}))
+
+	containers := map[string]ir.Container{}
+	byID := map[uuid.UUID]string{}
+	for _, c := range f.Containers {
+		byID[c.Id] = c.FullName
+	}
+	for _, c := range f.Containers {
+		key := byID[c.ParentId] + "/" + c.FullName
+		if _, dup := containers[key]; dup {
+			t.Errorf("duplicate container %s", key)
+		}
+		containers[key] = c
+	}
+	if p := containers["/Acme.Shop"]; p.Kind != "project" || p.VersionTag != "3.2.0" || p.DocRaw != "The shop." ||
+		p.ExtraJson != `{"file":"Shop/Shop.csproj","dir":"Shop","sdk":"Microsoft.NET.Sdk","target_frameworks":["net8.0","netstandard2.0"],`+
+			`"root_namespace":"Acme.Shop","packages":[{"name":"Newtonsoft.Json","version":"13.0.3"}]}` {
+		t.Errorf("shop project = %+v", p)
+	}
+	for key, want := range map[string]string{
+		"/Core":                        "project",
+		"Core/Acme.Core":               "namespace",
+		"Acme.Shop/Acme.Shop":          "namespace",
+		"Acme.Shop/Acme.Shop.Pricing":  "namespace",
+		"Acme.Shop/" + globalNamespace: "namespace",
+		"/" + globalNamespace:          "namespace",
+	} {
+		if c := containers[key]; c.Kind != want {
+			t.Errorf("container %s = %+v, want %s", key, c, want)
+		}
+	}
+	if len(f.Containers) != 7 {
+		t.Errorf("%d containers", len(f.Containers))
+	}
+
+	syms := map[string]ir.Symbol{}
+	for _, s := range f.Symbols {
+		if _, dup := syms[s.FullName]; dup {
+			t.Errorf("duplicate symbol %s", s.FullName)
+		}
+		syms[s.FullName] = s
+	}
+	for name, want := range map[string]string{
+		"Acme.Core.EntityBase":            "class public",
+		"Acme.Core.EntityBase.EntityBase": "constructor protected",
+		"Acme.Core.EntityBase.Id":         "property public",
+		"Acme.Core.EntityBase.Debug":      "property public",
+		"Acme.Shop.Cart":                  "class public",
+		"Acme.Shop.Cart.lines":            "field private",
+		"Acme.Shop.Cart.saved":            "field private",
+		"Acme.Shop.Cart.Cart":             "constructor public",
+		"Acme.Shop.Cart.Add":              "method public",
+		"Acme.Shop.Cart.Total":            "property public",
+		"Acme.Shop.Cart.Changed":          "event public",
+		"Acme.Shop.Cart.this":             "indexer public",
+		"Acme.Shop.Cart.operator +":       "operator public",
+		"Acme.Shop.Cart.Find":             "method public",
+		"Acme.Shop.Cart.Dispose":          "method public",
+		"Acme.Shop.Cart.Snapshot":         "class public",
+		"Acme.Shop.ICart":                 "interface public",
+		"Acme.Shop.ICart.Total":           "property public",
+		"Acme.Shop.ICart.Changed":         "event public",
+		"Acme.Shop.ICart.Find":            "method public",
+		"Acme.Shop.Line":                  "record public",
+		"Acme.Shop.Line.Sku":              "property public",
+		"Acme.Shop.Line.Price":            "property public",
+		"Acme.Shop.Coupon":                "record public",
+		"Acme.Shop.Money":                 "struct public",
+		"Acme.Shop.Money.Scale":           "field public",
+		"Acme.Shop.State.Open":            "enum_member public",
+		"Acme.Shop.State.Closed":          "enum_member public",
+		"Acme.Shop.Notify":                "delegate public",
+		"Acme.Shop.Extensions.Count":      "method public",
+		"Acme.Shop.Pricing.IPriced":       "interface public",
+		"Helper":                          "class internal",
+		"Helper.Twice":                    "method internal",
+		"Acme.Shop.Cart.~Cart":            "",
+		"WriteLine":                       "",
+		"x":                               "",
+	} {
+		if got := strings.TrimSpace(syms[name].Kind + " " + syms[name].Visibility); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	if n := len(f.Symbols); n != 39 {
+		t.Errorf("%d symbols", n)
+	}
+
+	files := map[uuid.UUID]string{}
+	for _, fl := range f.Files {
+		files[fl.Id] = fl.Path + " " + byID[fl.ContainerId]
+	}
+	cart := syms["Acme.Shop.Cart"]
+	if files[cart.OriginFileId] != "Shop/Cart.cs Acme.Shop" || cart.StartLine != 11 || cart.StartCol != 22 || cart.EndLine != 36 ||
+		cart.DocFmt != "A cart of `Acme.Shop.Line` items.\n\nCarts are not thread-safe." ||
+		cart.ExtraJson != `{"modifiers":["public","partial","sealed"],"attributes":["Serializable"],"files":["Shop/Cart.cs","Shop/Parts/Cart.Lines.cs"],`+
+			`"doc":{"summary":"A cart of `+"`Acme.Shop.Line`"+` items.","remarks":"Carts are not thread-safe."}}` {
+		t.Errorf("Cart = %+v", cart)
+	}
+	if d := syms["Acme.Shop.Cart.Dispose"]; files[d.OriginFileId] != "Shop/Parts/Cart.Lines.cs Acme.Shop" {
+		t.Errorf("Dispose file = %s", files[d.OriginFileId])
+	}
+	if a := syms["Acme.Shop.Cart.Add"]; a.DocFmt != "Adds a line.\n\nParameters:\n    sku: The product, see `qty`.\n    qty: How many; `1` by default.\n\n"+
+		"Returns:\n    The line added, or `null`.\n\nExceptions:\n    ArgumentException: When `qty` is negative." {
+		t.Errorf("Add doc = %q", a.DocFmt)
+	}
+	if c := syms["Acme.Shop.Extensions.Count"]; c.DocFmt != "Counts lines.\n\n```\nvar n = cart.Count();\n\nn++;\n```" {
+		t.Errorf("Count doc = %q", c.DocFmt)
+	}
+	if e := syms["Acme.Core.EntityBase"]; e.DocFmt != "Has an id." {
+		t.Errorf("EntityBase doc = %q", e.DocFmt)
+	}
+	if fd := syms["Acme.Shop.Cart.Find"]; fd.ExtraJson != `{"explicit":"ICart"}` {
+		t.Errorf("Find extra = %s", fd.ExtraJson)
+	}
+
+	owners := map[uuid.UUID]string{}
+	for name, s := range syms {
+		owners[s.Id] = name
+	}
+	var rels []string
+	for _, r := range f.Relations {
+		rels = append(rels, owners[r.SourceSymbolId]+" "+r.Relation+" "+owners[r.DstSymbolId])
+	}
+	sort.Strings(rels)
+	if got, want := strings.Join(rels, "\n"), strings.Join([]string{
+		"Acme.Shop.Cart extends Acme.Core.EntityBase",
+		"Acme.Shop.Cart implements Acme.Shop.ICart",
+		"Acme.Shop.Line implements Acme.Shop.Pricing.IPriced",
+	}, "\n"); got != want {
+		t.Errorf("relations =\n%s\nwant\n%s", got, want)
+	}
+	members := map[string]string{}
+	for _, m := range f.Members {
+		members[owners[m.ChildSymbolId]] = fmt.Sprintf("%s %d", owners[m.OwnerSymbolId], m.Order)
+	}
+	for child, owner := range map[string]string{
+		"Acme.Shop.Cart.lines":    "Acme.Shop.Cart 0",
+		"Acme.Shop.Cart.Find":     "Acme.Shop.Cart 8",
+		"Acme.Shop.Cart.Dispose":  "Acme.Shop.Cart 9",
+		"Acme.Shop.Cart.Snapshot": "Acme.Shop.Cart 10",
+		"Acme.Shop.Line.Qty":      "Acme.Shop.Line 1",
+		"Acme.Shop.Line.Price":    "Acme.Shop.Line 2",
+		"Acme.Shop.State.Closed":  "Acme.Shop.State 1",
+	} {
+		if members[child] != owner {
+			t.Errorf("owner of %s = %q, want %q", child, members[child], owner)
+		}
+	}
+
+	sigs := map[uuid.UUID]ir.Signature{}
+	for _, s := range f.Signatures {
+		sigs[s.SymbolId] = s
+	}
+	for name, want := range map[string]string{
+		"Acme.Shop.Cart":             "public partial class Cart : EntityBase, ICart",
+		"Acme.Shop.Cart.saved":       "private readonly List<Line> saved",
+		"Acme.Shop.Cart.Changed":     "public event EventHandler<Line>? Changed",
+		"Acme.Shop.Cart.this":        "public Line this[int index]",
+		"Acme.Shop.Cart.Find":        "T ICart.Find<T>(string sku) where T : Line",
+		"Acme.Shop.Line.Sku":         "public string Sku { get; init; }",
+		"Acme.Shop.Coupon":           "public readonly record struct Coupon(string Code)",
+		"Acme.Shop.State.Open":       "State.Open = 1",
+		"Acme.Shop.Notify":           "public delegate void Notify<in T>(T item, ref int count)",
+		"Acme.Core.EntityBase.Debug": "public string Debug",
+	} {
+		if got := sigs[syms[name].Id].Text; got != want {
+			t.Errorf("%s sig = %s, want %s", name, got, want)
+		}
+	}
+	for name, want := range map[string]string{
+		"Acme.Shop.Cart.Add": `{"params":[{"name":"sku","type":"string"},{"name":"qty","type":"int","default":"1"}],"results":[{"type":"Line?"}],` +
+			`"throws":[{"type":"ArgumentException","desc":"When ` + "`qty`" + ` is negative."}]}`,
+		"Acme.Shop.Cart.Find":        `{"params":[{"name":"sku","type":"string"}],"results":[{"type":"T"}],"type_params":[{"name":"T","constraint":"Line"}]}`,
+		"Acme.Shop.Extensions.Count": `{"receiver":"Cart","params":[{"name":"cart","type":"Cart","modifier":"this"}],"results":[{"type":"int"}]}`,
+		"Acme.Shop.Notify":           `{"params":[{"name":"item","type":"T"},{"name":"count","type":"int","modifier":"ref"}],"type_params":[{"name":"T","variance":"in"}]}`,
+		"Acme.Shop.Cart.Cart":        "",
+	} {
+		if got := sigs[syms[name].Id].Json; got != want {
+			t.Errorf("%s sig json = %s\nwant %s", name, got, want)
+		}
+	}
+
+	refs := map[string][]string{}
+	for _, tr := range f.Typerefs {
+		key := owners[tr.OwnerSymbolId] + " " + tr.Slot
+		refs[key] = append(refs[key], tr.Json)
+	}
+	for key, want := range map[string]string{
+		"Acme.Shop.Cart base:0":              `{"symbol":"Acme.Core.EntityBase","type":"class","text":"EntityBase"}`,
+		"Acme.Shop.Cart implements:0":        `{"symbol":"Acme.Shop.ICart","type":"interface","text":"ICart"}`,
+		"Acme.Shop.Cart field:lines":         `{"symbol":"Acme.Shop.Line","text":"List\u003cLine\u003e"}`,
+		"Acme.Shop.Line field:Price":         `{"symbol":"Acme.Shop.Pricing.Price","text":"Price"}`,
+		"Acme.Shop.Cart.Find result:0":       "",
+		"Acme.Shop.Cart.Add param:0":         "",
+		"Acme.Shop.Extensions.Count param:0": `{"symbol":"Acme.Shop.Cart","text":"Cart"}`,
+	} {
+		if got := strings.Join(refs[key], " "); got != want {
+			t.Errorf("typerefs %s = %s, want %s", key, got, want)
+		}
+	}
+
+	var imports []string
+	for _, im := range f.Imports {
+		imports = append(imports, byID[im.ContainerId]+" "+im.Target+" "+im.Alias+" "+im.DetailsJson)
+	}
+	sort.Strings(imports)
+	if got, want := strings.Join(imports, "\n"), strings.Join([]string{
+		`(global) Acme.Core  {"file":"Shop/Usings.cs","line":1,"global":true}`,
+		`(global) System  {"file":"Scratch/Program.cs","line":1,"stdlib":true}`,
+		`Acme.Shop Acme.Shop.Pricing  {"file":"Shop/Models.cs","line":3}`,
+		`Acme.Shop Core/Core.csproj  {"file":"Shop/Shop.csproj","kind":"project"}`,
+		`Acme.Shop Newtonsoft.Json  {"file":"Shop/Shop.csproj","kind":"package","version":"13.0.3"}`,
+		`Acme.Shop Newtonsoft.Json Json {"file":"Shop/Cart.cs","line":2}`,
+		`Acme.Shop System.Collections.Generic  {"file":"Shop/Cart.cs","line":1,"stdlib":true}`,
+	}, "\n"); got != want {
+		t.Errorf("imports =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestParseDoc(t *testing.T) {
+	for raw, want := range map[string]string{
+		"/// <summary>Gets it.</summary>\n/// <value>The value.</value>\n/// <seealso cref=\"M:A.B(System.Int32)\"/>": "Gets it.\n\nValue:\n    The value.\n\nSee also:\n    A.B(System.Int32)",
+		"/// <inheritdoc cref=\"IFoo\"/>":                                                                               "Inherits the documentation of `IFoo`.",
+		"/// <summary>See <see href=\"https://x.org\">the spec</see>.</summary>":                                        "See [the spec](https://x.org).",
+		"/// <summary>\n/// One.\n/// <para>Two.</para>\n/// <list><item>a</item><item>b</item></list>\n/// </summary>": "One.\n\nTwo.\n\n- a\n- b",
+		"/// a < b && c":                   "a < b && c",
+		"/** <summary>Block.</summary> */": "Block.",
+	} {
+		if got, _ := formatDoc(raw); got != want {
+			t.Errorf("formatDoc(%q) = %q, want %q", raw, got, want)
+		}
+	}
+}
+
+func TestLex(t *testing.T) {
+	src := "/// Doc.\nvar s = $@\"a{f(\"}\")}\"\"b\" /* c */ + @class + '}' + \"\"\"\n raw \" \n\"\"\";\n#if X\nint a;\n#else\n{\n#endif\n"
+	var got []string
+	for _, tok := range lex(src) {
+		got = append(got, tok.Text)
+	}
+	want := []string{"/// Doc.", "var", "s", "=", `$@"a{f("}")}""b"`, "+", "class", "+", "'}'", "+", "\"\"\"\n raw \" \n\"\"\"", ";", "int", "a", ";"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("tokens = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,194 @@
+package csharp
+
+import (
+	"strings"
+
+	"github.com/ChaseHampton/cargoworker/internal/pack/outline"
+)
+
+// builtins are the keyword types, the contextual words of type
+// expressions, and the types of the namespaces SDK projects import
+// implicitly, which type references leave out.
+var builtins = outline.Set(`
+	void bool byte sbyte char short ushort int uint long ulong nint nuint float double decimal
+	string object dynamic var where new class struct unmanaged notnull default
+	Object String Boolean Byte Char Int16 Int32 Int64 UInt32 UInt64 Single Double Decimal
+	DateTime DateTimeOffset TimeSpan Guid Uri Type Enum Array Attribute Delegate Action Func
+	Exception ArgumentException ArgumentNullException ArgumentOutOfRangeException
+	InvalidOperationException NotImplementedException NotSupportedException
+	ObjectDisposedException OperationCanceledException FormatException KeyNotFoundException
+	IDisposable IAsyncDisposable IEquatable IComparable IComparer IEqualityComparer IFormattable
+	Nullable Span ReadOnlySpan Memory ReadOnlyMemory Lazy Tuple ValueTuple EventArgs EventHandler
+	IEnumerable IEnumerator IAsyncEnumerable ICollection IList IDictionary IReadOnlyCollection
+	IReadOnlyList IReadOnlyDictionary ISet List Dictionary HashSet Queue Stack KeyValuePair
+	Task ValueTask CancellationToken Stream TextReader TextWriter HttpClient
+	Obsolete Serializable Flags`)
+
+// stdlib are the prefixes of the namespaces of the .NET base class
+// library.
+var stdlib = []string{"System.", "Microsoft."}
+
+// isStdlib reports whether a qualified name is in the base class library.
+func isStdlib(name string) bool {
+	for _, s := range stdlib {
+		if strings.HasPrefix(name, s) || name+"." == s {
+			return true
+		}
+	}
+	return false
+}
+
+// ref is a name a type refers to: qualified when it resolves, else as
+// written.
+type ref struct {
+	Symbol string `json:"symbol,omitempty"`
+	Name   string `json:"name,omitempty"`
+	Type   string `json:"type,omitempty"`
+	Text   string `json:"text,omitempty"` // the whole type
+}
+
+// resolver resolves the type names of a file to qualified names. Using
+// directives import namespaces, so a name resolves only to the types the
+// project declares; the rest are kept as written.
+type resolver struct {
+	namespaces []string          // the enclosing namespaces, innermost first, then the imported ones
+	aliases    map[string]string // using aliases -> the name they stand for
+	types      map[string]string // the project's types: full name -> kind
+	defs       map[string]string // nested types in scope -> full name
+}
+
+// refs returns the names a type refers to, in order, leaving out builtins
+// and the type parameters in scope.
+func (r *resolver) refs(typ string, params map[string]bool) []ref {
+	var out []ref
+	seen := map[string]bool{}
+	toks := lex(typ)
+	for i := 0; i < len(toks); i++ {
+		t := toks[i]
+		if t.Kind != outline.Ident || i > 0 && (toks[i-1].Is(".") || toks[i-1].Is("::")) {
+			continue
+		}
+		// The element names of tuple types: (int Count, string Name).
+		if i+1 < len(toks) && (toks[i+1].Is(",") || toks[i+1].Is(")")) && i > 0 && toks[i-1].Kind == outline.Ident {
+			continue
+		}
+		name := t.Text
+		if name == "global" && i+2 < len(toks) && toks[i+1].Is("::") {
+			name = toks[i+2].Text
+			i += 2
+		}
+		for i+2 < len(toks) && toks[i+1].Is(".") && toks[i+2].Kind == outline.Ident {
+			name += "." + toks[i+2].Text
+			i += 2
+		}
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		first, _, _ := strings.Cut(name, ".")
+		if params[first] {
+			continue
+		}
+		if q, ok := r.resolve(name); ok {
+			q.Text = typ
+			out = append(out, q)
+		}
+	}
+	return out
+}
+
+// resolve resolves a simple or dotted type name.
+func (r *resolver) resolve(name string) (ref, bool) {
+	first, rest, dotted := strings.Cut(name, ".")
+	join := func(q string) string {
+		if dotted {
+			return q + "." + rest
+		}
+		return q
+	}
+	if q := r.defs[first]; q != "" {
+		return ref{Symbol: join(q)}, true
+	}
+	if q := r.aliases[first]; q != "" {
+		return ref{Symbol: join(q)}, true
+	}
+	for _, ns := range r.namespaces {
+		if _, ok := r.types[qualify(ns, first)]; ok {
+			return ref{Symbol: join(qualify(ns, first))}, true
+		}
+	}
+	switch {
+	case r.types[name] != "":
+		return ref{Symbol: name}, true
+	case builtins[first] && !dotted:
+		return ref{}, false
+	case dotted && isStdlib(name):
+		return ref{Symbol: name}, true
+	}
+	return ref{Name: name}, true
+}
+
+// kind returns the kind of a type of the project, "" for others.
+func (r *resolver) kind(full string) string { return r.types[full] }
+
+// scope returns a resolver that also sees the nested types of a type.
+func (r *resolver) scope(nested map[string]string) *resolver {
+	if len(nested) == 0 {
+		return r
+	}
+	s := *r
+	s.defs = map[string]string{}
+	for k, v := range r.defs {
+		s.defs[k] = v
+	}
+	for k, v := range nested {
+		s.defs[k] = v
+	}
+	return &s
+}
+
+// within returns a resolver for the body of a namespace: its name and
+// those it is in come first, and its using directives add to the others.
+func (r *resolver) within(ns string, usings []usingDecl) *resolver {
+	s := *r
+	var chain []string
+	for n := ns; n != ""; n = n[:max(strings.LastIndex(n, "."), 0)] {
+		chain = append(chain, n)
+	}
+	s.namespaces = append(chain, r.namespaces...)
+	s.aliases = map[string]string{}
+	for k, v := range r.aliases {
+		s.aliases[k] = v
+	}
+	s.use(usings)
+	return &s
+}
+
+// use adds using directives. A static using imports the nested types of
+// a type, as a namespace does its types.
+func (r *resolver) use(usings []usingDecl) {
+	for _, u := range usings {
+		if u.alias != "" {
+			r.aliases[u.alias] = erasure(strings.TrimPrefix(u.path, "global::"))
+		} else {
+			r.namespaces = append(r.namespaces, strings.TrimPrefix(u.path, "global::"))
+		}
+	}
+}
+
+// erasure returns a type without its type arguments, nullability and
+// array ranks: System.Collections.Generic.List for List<int>?[].
+func erasure(typ string) string {
+	var b strings.Builder
+	for _, t := range lex(typ) {
+		switch {
+		case t.Kind == outline.Ident || t.Is("."):
+			b.WriteString(t.Text)
+		case t.Is("::"):
+			b.Reset() // global::
+		default:
+			return b.String()
+		}
+	}
+	return b.String()
+}
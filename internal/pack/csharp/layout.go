@@ -0,0 +1,292 @@
+package csharp
+
+import (
+	"encoding/xml"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// project is an MSBuild project: a directory with a .csproj.
+type project struct {
+	file        string // the .csproj, relative to the input root
+	dir         string
+	name        string // the assembly name
+	sdk         string
+	frameworks  []string
+	rootNS      string
+	outputType  string
+	version     string
+	description string
+	packages    []packageRef
+	references  []string // the .csproj files of project references, relative to the input root
+}
+
+type packageRef struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+// msbuild is the part of a .csproj the layout reads. Element names match
+// in any namespace, so the schema of old-style projects does not matter.
+type msbuild struct {
+	Sdk            string `xml:"Sdk,attr"`
+	PropertyGroups []struct {
+		AssemblyName     string `xml:"AssemblyName"`
+		RootNamespace    string `xml:"RootNamespace"`
+		TargetFramework  string `xml:"TargetFramework"`
+		TargetFrameworks string `xml:"TargetFrameworks"`
+		Version          string `xml:"Version"`
+		Description      string `xml:"Description"`
+		OutputType       string `xml:"OutputType"`
+	} `xml:"PropertyGroup"`
+	ItemGroups []struct {
+		PackageReferences []struct {
+			Include   string `xml:"Include,attr"`
+			Version   string `xml:"Version,attr"`
+			VersionEl string `xml:"Version"`
+		} `xml:"PackageReference"`
+		ProjectReferences []struct {
+			Include string `xml:"Include,attr"`
+		} `xml:"ProjectReference"`
+	} `xml:"ItemGroup"`
+}
+
+// layout finds the projects of an input root and parses their sources,
+// once each: a unit is a directory, but partial types and the names a
+// file can see span its whole project.
+type layout struct {
+	root     string
+	projects map[string]*project // by directory; nil where there is none
+	scopes   map[string]*scope   // by project directory, or "dir:" and the unit's
+	parses   map[string]*file    // by file
+}
+
+func newLayout(root string) *layout {
+	return &layout{root: root, projects: map[string]*project{}, scopes: map[string]*scope{}, parses: map[string]*file{}}
+}
+
+// project returns the nearest project at or above dir, or nil.
+func (l *layout) project(dir string) *project {
+	if p, seen := l.projects[dir]; seen {
+		return p
+	}
+	p := l.readProject(dir)
+	if p == nil && dir != "." {
+		p = l.project(path.Dir(dir))
+	}
+	l.projects[dir] = p
+	return p
+}
+
+// readProject parses the first .csproj in dir. A malformed one is a
+// project with only a name.
+func (l *layout) readProject(dir string) *project {
+	matches, _ := filepath.Glob(filepath.Join(l.root, filepath.FromSlash(dir), "*.csproj"))
+	if len(matches) == 0 {
+		return nil
+	}
+	sort.Strings(matches)
+	base := filepath.Base(matches[0])
+	p := &project{file: path.Join(dir, base), dir: dir, name: strings.TrimSuffix(base, ".csproj")}
+	b, err := os.ReadFile(matches[0])
+	if err != nil {
+		return p
+	}
+	var m msbuild
+	if xml.Unmarshal(b, &m) != nil {
+		return p
+	}
+	p.sdk = m.Sdk
+	for _, g := range m.PropertyGroups {
+		set := func(dst *string, v string) {
+			if v = strings.TrimSpace(v); v != "" && *dst == "" {
+				*dst = v
+			}
+		}
+		if n := strings.TrimSpace(g.AssemblyName); n != "" && !strings.Contains(n, "$(") {
+			p.name = n
+		}
+		set(&p.rootNS, g.RootNamespace)
+		set(&p.version, g.Version)
+		set(&p.description, g.Description)
+		set(&p.outputType, g.OutputType)
+		for _, f := range strings.Split(g.TargetFramework+";"+g.TargetFrameworks, ";") {
+			if f = strings.TrimSpace(f); f != "" {
+				p.frameworks = append(p.frameworks, f)
+			}
+		}
+	}
+	for _, g := range m.ItemGroups {
+		for _, r := range g.PackageReferences {
+			v := r.Version
+			if v == "" {
+				v = strings.TrimSpace(r.VersionEl)
+			}
+			if r.Include != "" {
+				p.packages = append(p.packages, packageRef{Name: r.Include, Version: v})
+			}
+		}
+		for _, r := range g.ProjectReferences {
+			if r.Include != "" {
+				p.references = append(p.references, path.Join(dir, strings.ReplaceAll(r.Include, `\`, "/")))
+			}
+		}
+	}
+	return p
+}
+
+// scope is what the files of a project, or of a directory outside any,
+// declare together, with the types of the projects it references.
+type scope struct {
+	types   map[string]string // full name -> kind of each type
+	parts   map[string][]part // full name -> the declarations of a partial type, by file and position
+	globals []usingDecl       // global using directives
+}
+
+// part is one declaration of a partial type. offset is the number of
+// members the parts before it declare.
+type part struct {
+	rel    string
+	d      *decl
+	offset int
+}
+
+// primary reports whether a declaration is the first part of its type,
+// the one the symbol is emitted with.
+func (s *scope) primary(full string, d *decl) bool {
+	ps := s.parts[full]
+	return len(ps) < 2 || ps[0].d == d
+}
+
+// part returns the part of a partial type a declaration is.
+func (s *scope) part(full string, d *decl) (part, bool) {
+	for _, p := range s.parts[full] {
+		if p.d == d {
+			return p, true
+		}
+	}
+	return part{}, false
+}
+
+// scope returns the scope of a project, or of the unit files when proj is
+// nil, scanning and parsing its sources.
+func (l *layout) scope(proj *project, dir string, files []string) *scope {
+	key := "dir:" + dir
+	if proj != nil {
+		key = proj.dir
+		files = l.sources(proj)
+	}
+	if s, ok := l.scopes[key]; ok {
+		return s
+	}
+	s := &scope{types: map[string]string{}, parts: map[string][]part{}}
+	for _, rel := range files {
+		f := l.parse(rel)
+		if f == nil {
+			continue
+		}
+		s.globals = append(s.globals, globals(f.usings)...)
+		s.index(rel, "", f.decls)
+	}
+	if proj != nil {
+		// The types of referenced projects resolve too; their partial
+		// types and global usings are their own.
+		refs := &scope{types: s.types, parts: map[string][]part{}}
+		for _, r := range proj.references {
+			if rp := l.project(path.Dir(r)); rp != nil && rp.dir != proj.dir {
+				for _, rel := range l.sources(rp) {
+					if f := l.parse(rel); f != nil {
+						refs.index(rel, "", f.decls)
+					}
+				}
+			}
+		}
+	}
+	for full, ps := range s.parts {
+		offset := 0
+		for i := range ps {
+			ps[i].offset = offset
+			offset += len(ps[i].d.children)
+		}
+		s.parts[full] = ps
+	}
+	l.scopes[key] = s
+	return s
+}
+
+func globals(us []usingDecl) []usingDecl {
+	var out []usingDecl
+	for _, u := range us {
+		if u.global {
+			out = append(out, u)
+		}
+	}
+	return out
+}
+
+// index records the types among decls, prefix being the namespace or type
+// they are declared in.
+func (s *scope) index(rel, prefix string, decls []*decl) {
+	for _, d := range decls {
+		if d.name == "" {
+			continue
+		}
+		full := qualify(prefix, d.name)
+		switch {
+		case d.kind == "namespace":
+			s.globals = append(s.globals, globals(d.usings)...)
+			s.index(rel, full, d.children)
+		case isType(d.kind):
+			s.types[full] = d.kind
+			if d.has("partial") {
+				s.parts[full] = append(s.parts[full], part{rel: rel, d: d})
+			}
+			s.index(rel, full, d.children)
+		}
+	}
+}
+
+// parse returns the outline of a file, parsing it once; nil if it cannot
+// be read.
+func (l *layout) parse(rel string) *file {
+	if f, ok := l.parses[rel]; ok {
+		return f
+	}
+	var f *file
+	if b, err := os.ReadFile(filepath.Join(l.root, filepath.FromSlash(rel))); err == nil {
+		f = parse(string(b))
+	}
+	l.parses[rel] = f
+	return f
+}
+
+// sources lists the .cs files of a project, in order: those under its
+// directory but not in bin, obj, hidden directories or other projects.
+func (l *layout) sources(proj *project) []string {
+	var out []string
+	top := filepath.Join(l.root, filepath.FromSlash(proj.dir))
+	filepath.WalkDir(top, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		rel, _ := filepath.Rel(l.root, p)
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			name := d.Name()
+			if p != top && (name == "bin" || name == "obj" || strings.HasPrefix(name, ".") || l.readProject(rel) != nil) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasSuffix(rel, ".cs") {
+			out = append(out, rel)
+		}
+		return nil
+	})
+	sort.Strings(out)
+	return out
+}
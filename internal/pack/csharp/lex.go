@@ -0,0 +1,278 @@
+package csharp
+
+import (
+	"strings"
+
+	"github.com/ChaseHampton/cargoworker/internal/pack/outline"
+)
+
+// token is a token. Verbatim @names are identifiers without the @; string
+// literals of every form keep their prefixes; doc comments, one /// line or
+// a /** */ comment, keep their markers.
+type token = outline.Token
+
+// puncts are the multi-character operators, longest first. Angle brackets
+// are always single tokens so that type arguments nest; >> is two.
+var puncts = []string{
+	"??=", "<<=", "=>", "==", "!=", "<=", "&&", "||", "??", "?.", "::", "++", "--", "->",
+	"+=", "-=", "*=", "/=", "%=", "&=", "|=", "^=", "<<", "..",
+}
+
+type lexer struct {
+	outline.Scanner
+	out []token
+}
+
+// lex splits a source into tokens, dropping comments other than doc
+// comments. Of the branches of #if directives only the first is kept, so
+// that the braces of the others do not unbalance the outline.
+func lex(src string) []token {
+	l := &lexer{Scanner: outline.NewScanner(src)}
+	if strings.HasPrefix(src, "\ufeff") {
+		l.I = 3
+	}
+	l.run()
+	return l.out
+}
+
+func (l *lexer) run() {
+	lineStart := true // only whitespace since the line began
+	for l.I < len(l.Src) {
+		c := l.Src[l.I]
+		switch {
+		case c == '\n':
+			l.SkipTo(l.I + 1)
+			lineStart = true
+			continue
+		case c == ' ' || c == '\t' || c == '\r' || c == '\f' || c == '\v':
+			l.I++
+			continue
+		case c == '#' && lineStart:
+			l.directive()
+			continue
+		case c == '/' && l.Peek(1) == '/':
+			t := token{Kind: outline.Doc, Pos: l.I, Line: l.Line, Col: l.I - l.LineStart}
+			l.SkipLine()
+			if t.Text = strings.TrimRight(l.Src[t.Pos:l.I], "\r"); strings.HasPrefix(t.Text, "///") && !strings.HasPrefix(t.Text, "////") {
+				t.EndLine, t.EndCol = l.Line, l.I-l.LineStart
+				l.out = append(l.out, t)
+			}
+			continue
+		case c == '/' && l.Peek(1) == '*':
+			t := token{Kind: outline.Doc, Pos: l.I, Line: l.Line, Col: l.I - l.LineStart}
+			end := strings.Index(l.Src[l.I+2:], "*/")
+			if end < 0 {
+				end = len(l.Src)
+			} else {
+				end += l.I + 4
+			}
+			l.SkipTo(end)
+			if t.Text = l.Src[t.Pos:l.I]; strings.HasPrefix(t.Text, "/**") && t.Text != "/**/" && !strings.HasPrefix(t.Text, "/***") {
+				t.EndLine, t.EndCol = l.Line, l.I-l.LineStart
+				l.out = append(l.out, t)
+			}
+			continue
+		}
+		lineStart = false
+		t := token{Line: l.Line, Col: l.I - l.LineStart, Pos: l.I}
+		start := l.I
+		switch {
+		case c == '"' || (c == '@' || c == '$') && l.stringStart(l.I):
+			l.SkipTo(l.string(l.I))
+			t.Kind = outline.String
+		case c == '\'':
+			l.SkipTo(l.char(l.I))
+			t.Kind = outline.Char
+		case c == '@' && isIdentStart(l.Peek(1)):
+			l.I++
+			for l.I < len(l.Src) && isIdent(l.Src[l.I]) {
+				l.I++
+			}
+			t.Kind = outline.Ident
+		case isIdentStart(c):
+			for l.I < len(l.Src) && isIdent(l.Src[l.I]) {
+				l.I++
+			}
+			t.Kind = outline.Ident
+		case c >= '0' && c <= '9' || c == '.' && l.Peek(1) >= '0' && l.Peek(1) <= '9':
+			l.I++
+			for l.I < len(l.Src) {
+				d := l.Src[l.I]
+				if isIdent(d) || d == '.' && l.Peek(1) >= '0' && l.Peek(1) <= '9' ||
+					(d == '+' || d == '-') && (l.Src[l.I-1] == 'e' || l.Src[l.I-1] == 'E') && !strings.HasPrefix(strings.ToLower(l.Src[start:]), "0x") {
+					l.I++
+					continue
+				}
+				break
+			}
+			t.Kind = outline.Number
+		default:
+			t.Kind = outline.Punct
+			l.SkipPunct(puncts)
+		}
+		t.Text = l.Src[start:l.I]
+		if t.Kind == outline.Ident {
+			t.Text = strings.TrimPrefix(t.Text, "@")
+		}
+		t.EndLine, t.EndCol = l.Line, l.I-l.LineStart
+		l.out = append(l.out, t)
+	}
+}
+
+// directive skips a preprocessor line. At #elif or #else it skips on to
+// the #endif closing the #if.
+func (l *lexer) directive() {
+	word := l.directiveWord()
+	l.skipLine()
+	if word != "elif" && word != "else" {
+		return
+	}
+	depth := 0
+	for l.I < len(l.Src) {
+		for l.I < len(l.Src) && (l.Src[l.I] == ' ' || l.Src[l.I] == '\t') {
+			l.I++
+		}
+		if l.I < len(l.Src) && l.Src[l.I] == '#' {
+			switch l.directiveWord() {
+			case "if":
+				depth++
+			case "endif":
+				if depth == 0 {
+					l.skipLine()
+					return
+				}
+				depth--
+			}
+		}
+		l.skipLine()
+	}
+}
+
+// directiveWord returns the name of the directive at the # at l.i.
+func (l *lexer) directiveWord() string {
+	j := l.I + 1
+	for j < len(l.Src) && (l.Src[j] == ' ' || l.Src[j] == '\t') {
+		j++
+	}
+	k := j
+	for k < len(l.Src) && isIdent(l.Src[k]) {
+		k++
+	}
+	return l.Src[j:k]
+}
+
+// skipLine skips past the next line break.
+func (l *lexer) skipLine() {
+	end := strings.IndexByte(l.Src[l.I:], '\n')
+	if end < 0 {
+		l.SkipTo(len(l.Src))
+		return
+	}
+	l.SkipTo(l.I + end + 1)
+}
+
+// stringStart reports whether the @ or $ at i begins a string: @"", $"",
+// $@"", @$"" or $$"""...""".
+func (l *lexer) stringStart(i int) bool {
+	for i < len(l.Src) && (l.Src[i] == '@' || l.Src[i] == '$') {
+		i++
+	}
+	return i < len(l.Src) && l.Src[i] == '"'
+}
+
+// string returns the offset after the string literal at i: regular,
+// verbatim, interpolated or raw. Interpolations may hold strings of their
+// own.
+func (l *lexer) string(i int) int {
+	verbatim, interp := false, 0
+	for ; i < len(l.Src) && l.Src[i] != '"'; i++ {
+		if l.Src[i] == '@' {
+			verbatim = true
+		} else {
+			interp++
+		}
+	}
+	if q := quotes(l.Src, i); q >= 3 {
+		// A raw string ends at a run of as many quotes.
+		end := strings.Index(l.Src[i+q:], strings.Repeat(`"`, q))
+		if end < 0 {
+			return len(l.Src)
+		}
+		return i + q + end + q
+	}
+	i++
+	for i < len(l.Src) {
+		switch c := l.Src[i]; {
+		case c == '"' && verbatim && i+1 < len(l.Src) && l.Src[i+1] == '"':
+			i += 2
+		case c == '"':
+			return i + 1
+		case c == '\\' && !verbatim:
+			i += 2
+		case c == '\n' && !verbatim:
+			return i // unterminated
+		case c == '{' && interp > 0 && i+1 < len(l.Src) && l.Src[i+1] == '{':
+			i += 2
+		case c == '{' && interp > 0:
+			i = l.interpolation(i + 1)
+		default:
+			i++
+		}
+	}
+	return len(l.Src)
+}
+
+// quotes counts the quotes at i.
+func quotes(s string, i int) int {
+	n := 0
+	for i+n < len(s) && s[i+n] == '"' {
+		n++
+	}
+	return n
+}
+
+// interpolation returns the offset after the brace closing an
+// interpolation whose expression starts at i.
+func (l *lexer) interpolation(i int) int {
+	depth := 1
+	for i < len(l.Src) {
+		switch c := l.Src[i]; {
+		case c == '{':
+			depth++
+		case c == '}':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		case c == '"' || (c == '@' || c == '$') && l.stringStart(i):
+			i = l.string(i)
+			continue
+		case c == '\'':
+			i = l.char(i)
+			continue
+		}
+		i++
+	}
+	return len(l.Src)
+}
+
+// char returns the offset after the character literal at i.
+func (l *lexer) char(i int) int {
+	for j := i + 1; j < len(l.Src); j++ {
+		switch l.Src[j] {
+		case '\\':
+			j++
+		case '\'':
+			return j + 1
+		case '\n':
+			return j
+		}
+	}
+	return len(l.Src)
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+func isIdent(c byte) bool { return isIdentStart(c) || c >= '0' && c <= '9' }
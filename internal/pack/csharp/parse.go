@@ -0,0 +1,716 @@
+package csharp
+
+import (
+	"strings"
+
+	"github.com/ChaseHampton/cargoworker/internal/pack/outline"
+)
+
+// file is the outline of one source file.
+type file struct {
+	usings []usingDecl
+	decls  []*decl // namespaces hold theirs as children
+}
+
+// usingDecl is a using directive: a namespace, a type with static, or an
+// alias.
+type usingDecl struct {
+	path   string
+	alias  string
+	static bool
+	global bool
+	line   int
+}
+
+// decl is a declaration: a namespace, a type, or a member of one.
+type decl struct {
+	kind       string // namespace; class, struct, interface, enum, record, delegate; constructor, method, operator, property, indexer, event, field, enum_member
+	name       string
+	line, col  int // of the name: 1-based line and byte column
+	endLine    int
+	endCol     int // 1-based, after the last character
+	modifiers  []string
+	attributes []string
+	doc        string // the raw doc comment, /// lines joined
+	typeParams []typeParam
+	params     []param
+	returns    string
+	typ        string // of fields, properties, indexers and events
+	explicit   string // the interface an explicit implementation is of
+	bases      []string
+	sig        string
+	usings     []usingDecl // of a namespace
+	children   []*decl
+}
+
+type param struct {
+	Name     string `json:"name"`
+	Type     string `json:"type,omitempty"`
+	Default  string `json:"default,omitempty"`
+	Modifier string `json:"modifier,omitempty"` // ref, out, in, params or this
+}
+
+type typeParam struct {
+	Name       string `json:"name"`
+	Constraint string `json:"constraint,omitempty"`
+	Variance   string `json:"variance,omitempty"` // in or out
+}
+
+func (d *decl) has(modifier string) bool {
+	for _, m := range d.modifiers {
+		if m == modifier {
+			return true
+		}
+	}
+	return false
+}
+
+// modifiers are C#'s declaration modifiers. partial, async, required and
+// file are contextual, and modifiers only before another word.
+var modifiers = outline.Set(`public protected private internal static readonly const volatile virtual override abstract
+	sealed extern unsafe new partial async required file fixed`)
+
+// paramModifiers precede the type of a parameter.
+var paramModifiers = outline.Set(`ref out in params this scoped readonly`)
+
+type parser struct {
+	outline.Cursor
+}
+
+// parse outlines a C# compilation unit.
+func parse(src string) *file {
+	p := &parser{Cursor: outline.Cursor{Src: src, Toks: lex(src)}}
+	f := &file{}
+	f.decls, f.usings = p.body("", "", false)
+	return f
+}
+
+// body parses the declarations of a compilation unit, a namespace or a
+// type, up to a closing brace. owner and ownerKind name the enclosing type.
+func (p *parser) body(owner, ownerKind string, braced bool) ([]*decl, []usingDecl) {
+	var decls []*decl
+	var usings []usingDecl
+	for !p.EOF() {
+		if braced && p.Peek(0).Is("}") {
+			break
+		}
+		before := p.I
+		if u, ok := p.using(); ok {
+			if u.path != "" {
+				usings = append(usings, u)
+			}
+			continue
+		}
+		decls = append(decls, p.member(owner, ownerKind)...)
+		if p.I == before {
+			p.I++
+		}
+	}
+	return decls, usings
+}
+
+// using parses a using directive, reporting whether there was one. using
+// statements, and extern alias directives, are skipped without one.
+func (p *parser) using() (usingDecl, bool) {
+	save := p.I
+	u := usingDecl{line: p.Peek(0).Line, global: p.Accept("global")}
+	if !p.Accept("using") {
+		if !u.global && p.Peek(0).Is("extern") && p.Peek(1).Is("alias") {
+			p.Until(false, ";")
+			p.Accept(";")
+			return usingDecl{}, true
+		}
+		p.I = save
+		return u, false
+	}
+	if p.Peek(0).Is("(") || p.Peek(0).Is("var") || p.Peek(0).Is("await") {
+		p.I = save // a using statement of top-level code
+		return u, false
+	}
+	u.static = p.Accept("static")
+	if p.Peek(0).Kind == outline.Ident && p.Peek(1).Is("=") {
+		u.alias = p.Peek(0).Text
+		p.I += 2
+	}
+	from, to := p.Until(true, ";")
+	p.Accept(";")
+	u.path = p.Text(from, to)
+	if u.alias == "" {
+		u.path = p.Name(from, to)
+	}
+	return u, true
+}
+
+// member parses a declaration: a namespace, a type, or a member of the
+// type named owner. Outside types only namespaces, types and delegates are
+// declarations; the statements of top-level programs are skipped.
+func (p *parser) member(owner, ownerKind string) []*decl {
+	d := &decl{doc: p.doc()}
+	for {
+		if p.Peek(0).Is("[") {
+			d.attributes = append(d.attributes, p.attributes()...)
+			continue
+		}
+		t := p.Peek(0)
+		if t.Kind == outline.Ident && modifiers[t.Text] && (p.Peek(1).Kind == outline.Ident || p.Peek(1).Is("[")) {
+			d.modifiers = append(d.modifiers, t.Text)
+			p.I++
+			continue
+		}
+		break
+	}
+	start := p.I
+	switch t := p.Peek(0); {
+	case p.EOF() || t.Is("}"):
+		return nil
+	case t.Is(";"):
+		p.I++
+		return nil
+	case t.Is("namespace"):
+		p.namespace(d)
+		return []*decl{d}
+	case t.Is("class"), t.Is("struct"), t.Is("interface"), t.Is("enum"),
+		t.Is("record") && (p.Peek(1).Kind == outline.Ident):
+		p.typeDecl(d)
+		return []*decl{d}
+	case t.Is("delegate") && !p.Peek(1).Is("(") && !p.Peek(1).Is("{"):
+		p.I++
+		d.kind = "delegate"
+		p.callable(d, start, "")
+		return []*decl{d}
+	case owner == "":
+		p.skipStatement()
+		return nil
+	case t.Is("~"):
+		p.skipMember() // a finalizer
+		return nil
+	case t.Is("event"):
+		p.I++
+		d.modifiers = append(d.modifiers, "event")
+		return p.typedMember(d, owner, ownerKind, start)
+	case (t.Is("implicit") || t.Is("explicit")) && p.Peek(1).Is("operator"):
+		d.kind = "operator"
+		p.at(d, p.I)
+		p.I += 2
+		from, to := p.Until(true, "(")
+		d.name = t.Text + " operator " + p.Text(from, to)
+		d.returns = p.Text(from, to)
+		p.callable(d, start, owner)
+		return []*decl{d}
+	}
+	return p.typedMember(d, owner, ownerKind, start)
+}
+
+// namespace parses a namespace: a block, or file-scoped, holding the rest
+// of the file.
+func (p *parser) namespace(d *decl) {
+	d.kind = "namespace"
+	p.I++
+	from, to := p.Until(false, "{", ";")
+	d.name = p.Name(from, to)
+	p.at(d, from)
+	if p.Accept(";") {
+		d.children, d.usings = p.body("", "", false)
+	} else if p.Accept("{") {
+		d.children, d.usings = p.body("", "", true)
+		p.Accept("}")
+	}
+	p.end(d)
+}
+
+// typeDecl parses a class, struct, interface, enum or record.
+func (p *parser) typeDecl(d *decl) {
+	start, mods := p.I, strings.Join(d.modifiers, " ")
+	d.kind = p.Peek(0).Text
+	p.I++
+	if d.kind == "record" && (p.Peek(0).Is("class") || p.Peek(0).Is("struct")) {
+		d.modifiers = append(d.modifiers, p.Peek(0).Text)
+		p.I++
+	}
+	if p.Peek(0).Kind == outline.Ident {
+		d.name = p.Peek(0).Text
+		p.at(d, p.I)
+		p.I++
+	}
+	p.typeParams(d)
+	if p.Peek(0).Is("(") {
+		// A primary constructor; the parameters of a record's are its
+		// properties.
+		from, to := p.Group()
+		for _, part := range p.Split(from, to) {
+			pr, at, ok := p.param(part[0], part[1])
+			if !ok {
+				continue
+			}
+			d.params = append(d.params, pr)
+			if d.kind == "record" {
+				c := &decl{kind: "property", name: pr.Name, typ: pr.Type, modifiers: []string{"public"}, sig: "public " + pr.Type + " " + pr.Name + " { get; init; }"}
+				p.at(c, at)
+				last := p.Toks[part[1]-1]
+				c.endLine, c.endCol = last.EndLine, last.EndCol+1
+				d.children = append(d.children, c)
+			}
+		}
+	}
+	if p.Accept(":") {
+		from, to := p.Until(true, "{", "where", ";")
+		for _, part := range p.Split(from, to) {
+			if call := p.Find(part[0], part[1], "("); call < part[1] {
+				part[1] = call // a record's base constructor arguments
+			}
+			d.bases = append(d.bases, p.Text(part[0], part[1]))
+		}
+	}
+	p.constraints(d)
+	d.sig = strings.TrimSpace(mods + " " + p.Text(start, p.I))
+	switch {
+	case p.Peek(0).Is("{") && d.kind == "enum":
+		p.I++
+		d.children = p.enumMembers(d.name)
+		p.Accept("}")
+	case p.Peek(0).Is("{"):
+		p.I++
+		members, _ := p.body(d.name, d.kind, true)
+		d.children = append(d.children, members...)
+		p.Accept("}")
+	default:
+		p.Accept(";")
+	}
+	p.end(d)
+	p.Accept(";")
+}
+
+// constraints reads where clauses into the constraints of d's type
+// parameters.
+func (p *parser) constraints(d *decl) {
+	for p.Accept("where") {
+		if p.Peek(0).Kind != outline.Ident || !p.Peek(1).Is(":") {
+			break
+		}
+		name := p.Peek(0).Text
+		p.I += 2
+		from, to := p.Until(true, "where", "{", ";", "=>")
+		for i := range d.typeParams {
+			if d.typeParams[i].Name == name {
+				d.typeParams[i].Constraint = p.Text(from, to)
+			}
+		}
+	}
+}
+
+// enumMembers parses the members of an enum body.
+func (p *parser) enumMembers(enum string) []*decl {
+	var out []*decl
+	for !p.EOF() && !p.Peek(0).Is("}") {
+		c := &decl{kind: "enum_member", doc: p.doc()}
+		for p.Peek(0).Is("[") {
+			c.attributes = append(c.attributes, p.attributes()...)
+		}
+		t := p.Peek(0)
+		if t.Kind != outline.Ident {
+			p.Until(false, ",")
+			p.Accept(",")
+			continue
+		}
+		c.name = t.Text
+		p.at(c, p.I)
+		start := p.I
+		p.I++
+		if p.Accept("=") {
+			p.Until(false, ",")
+		}
+		c.sig = enum + "." + p.Text(start, p.I)
+		p.end(c)
+		out = append(out, c)
+		p.Accept(",")
+	}
+	return out
+}
+
+// typedMember parses a member that starts with a type, or a constructor:
+// a method, operator, property, indexer, event or fields.
+func (p *parser) typedMember(d *decl, owner, ownerKind string, start int) []*decl {
+	head := p.I
+	from, to := p.Until(true, "(", "{", "=", ";", ",", "=>")
+	if to <= from {
+		p.skipMember()
+		return nil
+	}
+	// An indexer: Type this[int i] or Type IList.this[int i].
+	for i := from; i < to; i++ {
+		if p.Toks[i].Is("this") && i+1 < to && p.Toks[i+1].Is("[") {
+			d.kind, d.name = "indexer", "this"
+			p.at(d, i)
+			d.typ = p.Text(head, p.qualifier(d, from, i))
+			save := p.I
+			p.I = i + 1
+			pfrom, pto := p.Group()
+			p.I = save
+			d.params = p.params(pfrom, pto)
+			d.sig = strings.TrimSpace(strings.Join(d.modifiers, " ") + " " + p.Text(start, to))
+			p.accessors(d)
+			return []*decl{d}
+		}
+	}
+	// An operator: Type operator +(...).
+	for i := from; i < to; i++ {
+		if p.Toks[i].Is("operator") {
+			d.kind, d.name = "operator", "operator "+p.Text(i+1, to)
+			p.at(d, i)
+			d.returns = p.Text(head, i)
+			p.callable(d, start, owner)
+			return []*decl{d}
+		}
+	}
+	nameAt := to - 1
+	if p.Toks[nameAt].Is(">") && p.Peek(0).Is("(") {
+		// The type parameters of a generic method.
+		depth := 0
+		for ; nameAt > from; nameAt-- {
+			if p.Toks[nameAt].Is(">") {
+				depth++
+			} else if p.Toks[nameAt].Is("<") {
+				if depth--; depth == 0 {
+					break
+				}
+			}
+		}
+		save := p.I
+		p.I = nameAt
+		p.typeParams(d)
+		p.I = save
+		nameAt--
+	}
+	name := p.Toks[nameAt]
+	if name.Kind != outline.Ident || nameAt < from {
+		p.skipMember()
+		return nil
+	}
+	d.name = name.Text
+	p.at(d, nameAt)
+	typeEnd := p.qualifier(d, from, nameAt)
+	switch {
+	case p.Peek(0).Is("(") && d.has("event"):
+		p.skipMember()
+		return nil
+	case p.Peek(0).Is("("):
+		d.kind = "method"
+		if nameAt == head && name.Text == owner {
+			d.kind = "constructor"
+		} else if typeEnd == head {
+			p.skipMember() // a statement, not a declaration
+			return nil
+		} else {
+			d.returns = p.Text(head, typeEnd)
+		}
+		p.callable(d, start, owner)
+		return []*decl{d}
+	case p.Peek(0).Is("{") || p.Peek(0).Is("=>"):
+		d.kind = "property"
+		if d.has("event") {
+			d.kind = "event"
+		}
+		d.typ = p.Text(head, typeEnd)
+		d.sig = strings.TrimSpace(strings.Join(d.modifiers, " ") + " " + p.Text(start, to))
+		p.accessors(d)
+		return []*decl{d}
+	}
+	// Fields, or field-like events: Type a = 1, b;
+	d.kind = "field"
+	if d.has("event") {
+		d.kind = "event"
+	}
+	d.typ = p.Text(head, nameAt)
+	mods := strings.Join(d.modifiers, " ")
+	out := []*decl{d}
+	cur := d
+	for {
+		cur.sig = strings.TrimSpace(mods + " " + d.typ + " " + cur.name)
+		if p.Accept("=") {
+			p.Until(false, ",", ";")
+		}
+		p.end(cur)
+		if !p.Accept(",") || p.Peek(0).Kind != outline.Ident {
+			break
+		}
+		next := &decl{kind: d.kind, name: p.Peek(0).Text, typ: d.typ, modifiers: d.modifiers, attributes: d.attributes, doc: d.doc}
+		p.at(next, p.I)
+		p.I++
+		out = append(out, next)
+		cur = next
+	}
+	p.Accept(";")
+	return out
+}
+
+// qualifier records the interface of an explicit implementation, IFoo in
+// void IFoo.Bar(), whose name is at nameAt, and returns where the type
+// before it ends.
+func (p *parser) qualifier(d *decl, from, nameAt int) int {
+	i := nameAt
+	for i-2 >= from && p.Toks[i-1].Is(".") && p.Toks[i-2].Kind == outline.Ident {
+		i -= 2
+		for i-1 >= from && p.Toks[i-1].Is(">") {
+			// IEnumerable<T>.GetEnumerator: step back over the arguments.
+			depth := 0
+			for i--; i >= from; i-- {
+				if p.Toks[i].Is(">") {
+					depth++
+				} else if p.Toks[i].Is("<") {
+					if depth--; depth == 0 {
+						break
+					}
+				}
+			}
+			i--
+		}
+	}
+	if i < nameAt {
+		d.explicit = p.Text(i, nameAt-1)
+	}
+	return i
+}
+
+// callable parses the parameters, constraints and body of a method,
+// constructor, operator or delegate, the current token being its
+// parameter list.
+func (p *parser) callable(d *decl, start int, owner string) {
+	if d.kind == "delegate" {
+		from, to := p.Until(true, "(")
+		nameAt := to - 1
+		if nameAt >= from && p.Toks[nameAt].Is(">") {
+			for nameAt > from && !p.Toks[nameAt].Is("<") {
+				nameAt--
+			}
+			save := p.I
+			p.I = nameAt
+			p.typeParams(d)
+			p.I = save
+			nameAt--
+		}
+		if nameAt < from || p.Toks[nameAt].Kind != outline.Ident {
+			p.skipMember()
+			return
+		}
+		d.name = p.Toks[nameAt].Text
+		p.at(d, nameAt)
+		d.returns = p.Text(from, nameAt)
+	}
+	if p.Peek(0).Is("(") {
+		from, to := p.Group()
+		d.params = p.params(from, to)
+	}
+	p.constraints(d)
+	d.sig = strings.TrimSpace(strings.Join(d.modifiers, " ") + " " + p.Text(start, p.I))
+	if d.kind == "constructor" && p.Accept(":") {
+		p.Until(false, "{", "=>", ";") // base(...) or this(...)
+	}
+	switch {
+	case p.Peek(0).Is("{"):
+		p.Group()
+	case p.Accept("=>"):
+		p.Until(false, ";")
+		p.Accept(";")
+	default:
+		p.Accept(";")
+	}
+	p.end(d)
+}
+
+// accessors skips the accessor block or expression body of a property,
+// indexer or event, and a property's initializer.
+func (p *parser) accessors(d *decl) {
+	switch {
+	case p.Peek(0).Is("{"):
+		p.Group()
+		if p.Accept("=") {
+			p.Until(false, ";")
+			p.Accept(";")
+		}
+	case p.Accept("=>"):
+		p.Until(false, ";")
+		p.Accept(";")
+	}
+	p.end(d)
+}
+
+// params parses the parameters in tokens [from, to).
+func (p *parser) params(from, to int) []param {
+	out := []param{}
+	for _, part := range p.Split(from, to) {
+		if pr, _, ok := p.param(part[0], part[1]); ok {
+			out = append(out, pr)
+		}
+	}
+	return out
+}
+
+// param parses a parameter: [attributes] [modifiers] Type name [= default].
+// It returns the index of the name too.
+func (p *parser) param(from, to int) (param, int, bool) {
+	save := p.I
+	p.I = from
+	for p.Peek(0).Is("[") {
+		p.attributes()
+	}
+	var pr param
+	for p.I < to && p.Peek(0).Kind == outline.Ident && paramModifiers[p.Peek(0).Text] && p.I+1 < to && p.Toks[p.I+1].Kind == outline.Ident {
+		if p.Peek(0).Text != "scoped" && p.Peek(0).Text != "readonly" {
+			pr.Modifier = p.Peek(0).Text
+		}
+		p.I++
+	}
+	from, p.I = p.I, save
+	end := p.Find(from, to, "=")
+	if end < to {
+		pr.Default = p.Text(end+1, to)
+	}
+	nameAt := end - 1
+	if nameAt <= from || p.Toks[nameAt].Kind != outline.Ident {
+		return param{}, 0, false
+	}
+	pr.Name, pr.Type = p.Toks[nameAt].Text, p.Text(from, nameAt)
+	return pr, nameAt, true
+}
+
+// skipStatement skips to the end of a statement or block.
+func (p *parser) skipStatement() {
+	for !p.EOF() {
+		t := p.Peek(0)
+		switch {
+		case t.Is(";"):
+			p.I++
+			return
+		case t.Is("{"):
+			p.Group()
+			return
+		case t.Opens():
+			p.Group()
+		case t.Closes():
+			return
+		default:
+			p.I++
+		}
+	}
+}
+
+// skipMember skips a member that is not outlined, to the end of its body.
+func (p *parser) skipMember() {
+	for !p.EOF() {
+		t := p.Peek(0)
+		switch {
+		case t.Is(";"):
+			p.I++
+			return
+		case t.Is("{"):
+			p.Group()
+			if p.Accept("=") {
+				p.Until(false, ";")
+				p.Accept(";")
+			}
+			return
+		case t.Is("=>"):
+			p.Until(false, ";")
+			p.Accept(";")
+			return
+		case t.Opens():
+			p.Group()
+		case t.Closes():
+			return
+		default:
+			p.I++
+		}
+	}
+}
+
+// end sets the end of d to the last consumed token.
+func (p *parser) end(d *decl) {
+	t := p.Last()
+	d.endLine, d.endCol = t.EndLine, t.EndCol+1
+}
+
+// at sets the position of d to the token at i.
+func (p *parser) at(d *decl, i int) {
+	if i < len(p.Toks) {
+		d.line, d.col = p.Toks[i].Line, p.Toks[i].Col+1
+	}
+}
+
+// doc reads the doc comments before a declaration: a run of /// lines, or
+// the last /** */ comment.
+func (p *parser) doc() string {
+	var lines []string
+	last := 0
+	for !p.EOF() && p.Peek(0).Kind == outline.Doc {
+		t := p.Peek(0)
+		if strings.HasPrefix(t.Text, "/**") || len(lines) > 0 && t.Line > last+1 {
+			lines = nil // a block comment, or a gap, starts over
+		}
+		lines = append(lines, t.Text)
+		last = t.EndLine
+		p.I++
+	}
+	return strings.Join(lines, "\n")
+}
+
+// attributes reads an attribute section, [A, B(1)], and returns its
+// attributes. Those of an assembly or module target are dropped.
+func (p *parser) attributes() []string {
+	from, to := p.Group()
+	if to-from >= 2 && p.Toks[from+1].Is(":") && (p.Toks[from].Is("assembly") || p.Toks[from].Is("module")) {
+		return nil
+	}
+	var out []string
+	for _, part := range p.Split(from, to) {
+		out = append(out, p.Text(part[0], part[1]))
+	}
+	return out
+}
+
+// typeParams parses a type parameter list, if present.
+func (p *parser) typeParams(d *decl) {
+	if !p.Peek(0).Is("<") {
+		return
+	}
+	p.I++
+	from := p.I
+	depth := 0
+	for !p.EOF() {
+		t := p.Peek(0)
+		if t.Is(">") && depth == 0 {
+			break
+		}
+		switch {
+		case t.Is("<"):
+			depth++
+		case t.Is(">"):
+			depth--
+		case t.Opens():
+			p.Group()
+			continue
+		case t.Closes(), t.Is(";"), t.Is("{"):
+			return
+		}
+		p.I++
+	}
+	to := p.I
+	p.Accept(">")
+	for _, part := range p.Split(from, to) {
+		i, end := part[0], part[1]
+		for i < end && p.Toks[i].Is("[") {
+			save := p.I
+			p.I = i
+			p.Group()
+			i, p.I = p.I, save
+		}
+		var tp typeParam
+		if i < end && (p.Toks[i].Is("in") || p.Toks[i].Is("out")) {
+			tp.Variance = p.Toks[i].Text
+			i++
+		}
+		if i < end {
+			tp.Name = p.Toks[i].Text
+			d.typeParams = append(d.typeParams, tp)
+		}
+	}
+}
@@ -0,0 +1,375 @@
+package csharp
+
+import (
+	"encoding/xml"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// xmldoc is a parsed XML doc comment: the text of its top-level elements,
+// with inline markup rendered as Markdown.
+type xmldoc struct {
+	Summary    string     `json:"summary,omitempty"`
+	Remarks    string     `json:"remarks,omitempty"`
+	Params     []docParam `json:"params,omitempty"`
+	TypeParams []docParam `json:"type_params,omitempty"`
+	Returns    string     `json:"returns,omitempty"`
+	Value      string     `json:"value,omitempty"`
+	Exceptions []docType  `json:"exceptions,omitempty"`
+	Examples   []string   `json:"examples,omitempty"`
+	See        []string   `json:"see,omitempty"`        // seealso crefs and links
+	InheritDoc *string    `json:"inheritdoc,omitempty"` // set, maybe empty, to the cref documentation is inherited from
+}
+
+type docParam struct {
+	Name string `json:"name"`
+	Desc string `json:"desc,omitempty"`
+}
+
+type docType struct {
+	Type string `json:"type,omitempty"`
+	Desc string `json:"desc,omitempty"`
+}
+
+// node is an element of a doc comment, or a run of text when name is "".
+type node struct {
+	name     string
+	attrs    map[string]string
+	text     string
+	children []*node
+}
+
+// cleanComment strips the markers of a doc comment: the /// of each line
+// and a space after it, or the /** */ of a block and its lines' asterisks.
+func cleanComment(raw string) string {
+	var lines []string
+	if strings.HasPrefix(raw, "/**") {
+		s := strings.TrimSuffix(strings.TrimPrefix(raw, "/**"), "*/")
+		for i, l := range strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n") {
+			t := strings.TrimLeft(l, " \t")
+			if strings.HasPrefix(t, "*") {
+				t = strings.TrimPrefix(t[1:], " ")
+			} else if i > 0 {
+				t = l
+			}
+			lines = append(lines, strings.TrimRight(t, " \t"))
+		}
+	} else {
+		for _, l := range strings.Split(raw, "\n") {
+			l = strings.TrimPrefix(strings.TrimLeft(l, " \t"), "///")
+			lines = append(lines, strings.TrimRight(strings.TrimPrefix(l, " "), " \t\r"))
+		}
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// parseXML parses the elements of a cleaned doc comment. The decoder is
+// lenient, so mismatched tags are tolerated; what still fails to parse is
+// kept as the elements so far, or as text with its tags stripped when
+// there are none.
+func parseXML(s string) *node {
+	dec := xml.NewDecoder(strings.NewReader("<doc>" + s + "</doc>"))
+	dec.Strict, dec.Entity = false, xml.HTMLEntity
+	root := &node{name: "doc"}
+	var stack []*node
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			if err != io.EOF && !slices.ContainsFunc(root.children, func(n *node) bool { return n.name != "" }) {
+				root.children = []*node{{text: stripTags(s)}}
+			}
+			return root
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			n := root
+			if len(stack) > 0 {
+				n = &node{name: strings.ToLower(t.Name.Local), attrs: map[string]string{}}
+				for _, a := range t.Attr {
+					n.attrs[strings.ToLower(a.Name.Local)] = a.Value
+				}
+				top := stack[len(stack)-1]
+				top.children = append(top.children, n)
+			}
+			stack = append(stack, n)
+		case xml.EndElement:
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		case xml.CharData:
+			if len(stack) > 0 {
+				top := stack[len(stack)-1]
+				top.children = append(top.children, &node{text: string(t)})
+			}
+		}
+	}
+}
+
+var (
+	tagRE   = regexp.MustCompile(`<[^>]*>`)
+	spaceRE = regexp.MustCompile(`\s+`)
+)
+
+func stripTags(s string) string { return tagRE.ReplaceAllString(s, "") }
+
+// parseDoc parses a cleaned doc comment. Text outside any element is the
+// summary of a comment without one.
+func parseDoc(s string) *xmldoc {
+	d := &xmldoc{}
+	var loose []*node
+	for _, n := range parseXML(s).children {
+		switch n.name {
+		case "":
+			loose = append(loose, n)
+		case "summary":
+			d.Summary = join(d.Summary, render(n))
+		case "remarks":
+			d.Remarks = join(d.Remarks, render(n))
+		case "param":
+			d.Params = append(d.Params, docParam{Name: n.attrs["name"], Desc: render(n)})
+		case "typeparam":
+			d.TypeParams = append(d.TypeParams, docParam{Name: n.attrs["name"], Desc: render(n)})
+		case "returns":
+			d.Returns = render(n)
+		case "value":
+			d.Value = render(n)
+		case "exception":
+			d.Exceptions = append(d.Exceptions, docType{Type: cref(n.attrs["cref"]), Desc: render(n)})
+		case "example":
+			d.Examples = append(d.Examples, render(n))
+		case "seealso":
+			if c := cref(n.attrs["cref"]); c != "" {
+				d.See = append(d.See, c)
+			} else if h := n.attrs["href"]; h != "" {
+				d.See = append(d.See, h)
+			}
+		case "inheritdoc":
+			c := cref(n.attrs["cref"])
+			d.InheritDoc = &c
+		default:
+			loose = append(loose, n)
+		}
+	}
+	if d.Summary == "" {
+		d.Summary = render(&node{name: "summary", children: loose})
+	}
+	return d
+}
+
+func join(a, b string) string {
+	if a == "" || b == "" {
+		return a + b
+	}
+	return a + "\n\n" + b
+}
+
+// cref returns the name a cref attribute refers to, without the kind
+// prefix of an ID string: System.String for T:System.String.
+func cref(s string) string {
+	if len(s) > 2 && s[1] == ':' {
+		s = s[2:]
+	}
+	return s
+}
+
+// render returns the text of an element as Markdown: code as backticks
+// and fences, references as code, paragraphs and list items as blocks.
+func render(n *node) string {
+	var b strings.Builder
+	renderTo(&b, n)
+	var blocks []string
+	for _, block := range strings.Split(b.String(), "\n\n") {
+		if strings.HasPrefix(block, "```") {
+			blocks = append(blocks, strings.ReplaceAll(block, "\x00", ""))
+			continue
+		}
+		var lines []string
+		for _, l := range strings.Split(block, "\n") {
+			if l = strings.Join(strings.Fields(l), " "); l != "" {
+				lines = append(lines, l)
+			}
+		}
+		if len(lines) > 0 {
+			blocks = append(blocks, strings.Join(lines, "\n"))
+		}
+	}
+	return strings.Join(blocks, "\n\n")
+}
+
+func renderTo(b *strings.Builder, n *node) {
+	children := func() {
+		for _, c := range n.children {
+			renderTo(b, c)
+		}
+	}
+	switch n.name {
+	case "":
+		b.WriteString(spaceRE.ReplaceAllString(n.text, " "))
+	case "see", "seealso":
+		switch {
+		case n.attrs["langword"] != "":
+			b.WriteString("`" + n.attrs["langword"] + "`")
+		case n.attrs["cref"] != "":
+			b.WriteString("`" + cref(n.attrs["cref"]) + "`")
+		case n.attrs["href"] != "":
+			var t strings.Builder
+			for _, c := range n.children {
+				renderTo(&t, c)
+			}
+			if text := strings.TrimSpace(t.String()); text != "" {
+				b.WriteString("[" + text + "](" + n.attrs["href"] + ")")
+			} else {
+				b.WriteString(n.attrs["href"])
+			}
+		default:
+			children()
+		}
+	case "paramref", "typeparamref":
+		b.WriteString("`" + n.attrs["name"] + "`")
+	case "c":
+		b.WriteString("`" + strings.TrimSpace(text(n)) + "`")
+	case "code":
+		// Blank lines hold a NUL until render has split the blocks.
+		lines := dedent(strings.Split(strings.Trim(strings.ReplaceAll(text(n), "\r", ""), "\n"), "\n"))
+		for i, l := range lines {
+			if l == "" {
+				lines[i] = "\x00"
+			}
+		}
+		b.WriteString("\n\n```\n" + strings.Join(lines, "\n") + "\n```\n\n")
+	case "para":
+		b.WriteString("\n\n")
+		children()
+		b.WriteString("\n\n")
+	case "br":
+		b.WriteString("\n")
+	case "item":
+		b.WriteString("\n- ")
+		children()
+	case "term":
+		children()
+		b.WriteString(" – ")
+	case "list":
+		b.WriteString("\n\n")
+		children()
+		b.WriteString("\n\n")
+	default:
+		children()
+	}
+}
+
+// text returns the character data of an element and its descendants.
+func text(n *node) string {
+	if n.name == "" {
+		return n.text
+	}
+	var b strings.Builder
+	for _, c := range n.children {
+		b.WriteString(text(c))
+	}
+	return b.String()
+}
+
+// dedent removes the common indentation of non-blank lines.
+func dedent(lines []string) []string {
+	margin := -1
+	for _, l := range lines {
+		if t := strings.TrimLeft(l, " \t"); t != "" {
+			if n := len(l) - len(t); margin < 0 || n < margin {
+				margin = n
+			}
+		}
+	}
+	out := make([]string, len(lines))
+	for i, l := range lines {
+		if margin > 0 && len(l) >= margin {
+			l = l[margin:]
+		}
+		out[i] = strings.TrimRight(l, " \t\r")
+	}
+	return out
+}
+
+// format returns the doc_fmt of a comment: the summary and remarks, then
+// sections for the parameters, type parameters, return value, value,
+// exceptions and examples.
+func (d *xmldoc) format() string {
+	var b strings.Builder
+	para := func(s string) {
+		if s == "" {
+			return
+		}
+		if b.Len() > 0 {
+			b.WriteString("\n\n")
+		}
+		b.WriteString(s)
+	}
+	indent := func(s string) string { return "    " + strings.ReplaceAll(s, "\n", "\n    ") }
+	section := func(title string, items []docParam) {
+		var lines []string
+		for _, it := range items {
+			switch {
+			case it.Name == "":
+				lines = append(lines, indent(it.Desc))
+			case it.Desc == "":
+				lines = append(lines, indent(it.Name))
+			default:
+				lines = append(lines, indent(it.Name+": "+it.Desc))
+			}
+		}
+		if len(lines) > 0 {
+			para(title + ":\n" + strings.Join(lines, "\n"))
+		}
+	}
+	para(d.Summary)
+	para(d.Remarks)
+	if d.InheritDoc != nil && b.Len() == 0 {
+		if *d.InheritDoc != "" {
+			para("Inherits the documentation of `" + *d.InheritDoc + "`.")
+		} else {
+			para("Inherits the documentation of the member it overrides or implements.")
+		}
+	}
+	section("Parameters", d.Params)
+	section("Type parameters", d.TypeParams)
+	if d.Returns != "" {
+		section("Returns", []docParam{{Desc: d.Returns}})
+	}
+	if d.Value != "" {
+		section("Value", []docParam{{Desc: d.Value}})
+	}
+	var ex []docParam
+	for _, e := range d.Exceptions {
+		ex = append(ex, docParam{Name: e.Type, Desc: e.Desc})
+	}
+	section("Exceptions", ex)
+	var examples []docParam
+	for _, e := range d.Examples {
+		examples = append(examples, docParam{Desc: e})
+	}
+	section("Examples", examples)
+	var see []docParam
+	for _, s := range d.See {
+		see = append(see, docParam{Name: s})
+	}
+	section("See also", see)
+	return b.String()
+}
+
+// formatDoc cleans and parses a raw doc comment, returning the doc_fmt
+// text and the parsed doc.
+func formatDoc(raw string) (string, *xmldoc) {
+	if strings.TrimSpace(raw) == "" {
+		return "", nil
+	}
+	d := parseDoc(cleanComment(raw))
+	return d.format(), d
+}
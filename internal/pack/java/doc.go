@@ -0,0 +1,132 @@
+package java
+
+import (
+	"strings"
+)
+
+// javadoc is a parsed Javadoc or KDoc comment: its description and the
+// block tags that describe the symbol.
+type javadoc struct {
+	Summary    string     `json:"summary,omitempty"`
+	Params     []docParam `json:"params,omitempty"`
+	TypeParams []docParam `json:"type_params,omitempty"`
+	Properties []docParam `json:"properties,omitempty"` // KDoc @property
+	Returns    string     `json:"returns,omitempty"`
+	Throws     []docType  `json:"throws,omitempty"`
+	Deprecated *string    `json:"deprecated,omitempty"` // set, maybe empty, when deprecated
+	Since      string     `json:"since,omitempty"`
+	Author     []string   `json:"author,omitempty"`
+	See        []string   `json:"see,omitempty"`
+	Tags       []docTag   `json:"tags,omitempty"` // the others, in order
+}
+
+type docParam struct {
+	Name string `json:"name"`
+	Desc string `json:"desc,omitempty"`
+}
+
+type docType struct {
+	Type string `json:"type,omitempty"`
+	Desc string `json:"desc,omitempty"`
+}
+
+type docTag struct {
+	Tag  string `json:"tag"`
+	Text string `json:"text,omitempty"`
+}
+
+// cleanComment strips the comment markers of a doc comment and the leading
+// asterisks of its lines.
+func cleanComment(raw string) string {
+	s := strings.TrimSuffix(strings.TrimPrefix(raw, "/**"), "*/")
+	lines := strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+	for i, l := range lines {
+		t := strings.TrimLeft(l, " \t")
+		if strings.HasPrefix(t, "*") {
+			t = strings.TrimPrefix(t[1:], " ")
+		} else if i > 0 {
+			t = l // a line without the asterisk keeps its indentation
+		}
+		lines[i] = strings.TrimRight(t, " \t")
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// parseDoc splits a cleaned comment into its description and block tags.
+// Lines starting with @ outside <pre> blocks and code fences begin tags.
+func parseDoc(text string) *javadoc {
+	d := &javadoc{}
+	var desc []string
+	var tag string
+	var body []string
+	pre := false
+	flush := func() {
+		if tag != "" {
+			d.tag(tag, strings.Join(strings.Fields(strings.Join(body, " ")), " "))
+		}
+		tag, body = "", nil
+	}
+	for _, l := range strings.Split(text, "\n") {
+		t := strings.TrimSpace(l)
+		switch {
+		case strings.HasPrefix(t, "```"):
+			pre = !pre
+		case strings.Contains(t, "<pre>"):
+			pre = !strings.Contains(t, "</pre>")
+		case strings.Contains(t, "</pre>"):
+			pre = false
+		}
+		if !pre && strings.HasPrefix(t, "@") && len(t) > 1 && isIdentStart(t[1]) {
+			flush()
+			name, rest, _ := strings.Cut(t[1:], " ")
+			tag, body = name, []string{strings.TrimSpace(rest)}
+			continue
+		}
+		if tag != "" {
+			body = append(body, l)
+		} else {
+			desc = append(desc, l)
+		}
+	}
+	flush()
+	d.Summary = strings.TrimSpace(strings.Join(desc, "\n"))
+	return d
+}
+
+// tag records one block tag. Javadoc names type parameters <T> in @param;
+// KDoc names exceptions in brackets, @throws [IOException].
+func (d *javadoc) tag(name, text string) {
+	first, rest, _ := strings.Cut(text, " ")
+	first = strings.Trim(first, "[]")
+	rest = strings.TrimSpace(rest)
+	switch name {
+	case "param":
+		if strings.HasPrefix(first, "<") {
+			d.TypeParams = append(d.TypeParams, docParam{Name: strings.Trim(first, "<>"), Desc: rest})
+		} else {
+			d.Params = append(d.Params, docParam{Name: first, Desc: rest})
+		}
+	case "property":
+		d.Properties = append(d.Properties, docParam{Name: first, Desc: rest})
+	case "return", "returns":
+		d.Returns = text
+	case "throws", "exception":
+		d.Throws = append(d.Throws, docType{Type: first, Desc: rest})
+	case "deprecated":
+		d.Deprecated = &text
+	case "since":
+		d.Since = text
+	case "author":
+		d.Author = append(d.Author, text)
+	case "see", "sample":
+		d.See = append(d.See, text)
+	default:
+		d.Tags = append(d.Tags, docTag{Tag: name, Text: text})
+	}
+}
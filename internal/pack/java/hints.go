@@ -0,0 +1,167 @@
+package java
+
+import (
+	"strings"
+
+	"github.com/ChaseHampton/cargoworker/internal/pack/outline"
+)
+
+// builtins are the primitive types, the types of java.lang and of the
+// Kotlin standard library that need no import, and the keywords of type
+// expressions, which type references leave out.
+var builtins = outline.Set(`
+	void boolean byte char short int long float double var extends super in out
+	Object String Boolean Byte Character Short Integer Long Float Double Number Void Class Enum Record
+	Iterable Comparable CharSequence Runnable Thread Throwable Exception RuntimeException Error
+	IllegalArgumentException IllegalStateException NullPointerException IndexOutOfBoundsException
+	UnsupportedOperationException ArithmeticException ClassCastException InterruptedException
+	CloneNotSupportedException Cloneable AutoCloseable Override Deprecated FunctionalInterface
+	SuppressWarnings SafeVarargs StringBuilder Math System Annotation
+	Any Nothing Unit Int UInt ULong UByte UShort Array IntArray LongArray ByteArray CharArray ShortArray
+	FloatArray DoubleArray BooleanArray List MutableList Set MutableSet Map MutableMap Collection
+	MutableCollection Sequence Pair Triple Lazy Result Function0 Function1 Function2 KClass
+	Suppress JvmStatic JvmField JvmOverloads JvmName Throws`)
+
+// stdlib are the prefixes of the packages that ship with the JDK or the
+// Kotlin standard library.
+var stdlib = []string{"java.", "javax.", "jdk.", "sun.", "kotlin."}
+
+// isStdlib reports whether a qualified name is in the JDK or the Kotlin
+// standard library.
+func isStdlib(name string) bool {
+	for _, s := range stdlib {
+		if strings.HasPrefix(name, s) || name+"." == s {
+			return true
+		}
+	}
+	return false
+}
+
+// ref is a name a type refers to: qualified when it resolves, else as
+// written.
+type ref struct {
+	Symbol string `json:"symbol,omitempty"`
+	Name   string `json:"name,omitempty"`
+	Type   string `json:"type,omitempty"`
+	Text   string `json:"text,omitempty"` // the whole type
+}
+
+// resolver resolves the type names of a file to qualified names.
+type resolver struct {
+	pkg     string            // the package, "" for the default package
+	kotlin  bool              // Kotlin's default imports apply
+	local   bool              // names in no scope are types of the package
+	imports map[string]string // simple or alias name -> qualified name
+	defs    map[string]string // types of the package and nested types in scope -> qualified name
+}
+
+// refs returns the names a type refers to, in order, leaving out builtins
+// and the type parameters in scope.
+func (r *resolver) refs(typ string, params map[string]bool) []ref {
+	var out []ref
+	seen := map[string]bool{}
+	toks := lex(typ, r.kotlin)
+	for i := 0; i < len(toks); i++ {
+		t := toks[i]
+		if t.Kind != outline.Ident || i > 0 && (toks[i-1].Is(".") || toks[i-1].Is("@")) {
+			continue
+		}
+		// The parameter names of Kotlin function types: (name: Type) -> R.
+		if r.kotlin && i+1 < len(toks) && toks[i+1].Is(":") {
+			continue
+		}
+		name := t.Text
+		for i+2 < len(toks) && toks[i+1].Is(".") && toks[i+2].Kind == outline.Ident {
+			name += "." + toks[i+2].Text
+			i += 2
+		}
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		first, _, _ := strings.Cut(name, ".")
+		if params[first] {
+			continue
+		}
+		if q, ok := r.resolve(name); ok {
+			q.Text = typ
+			out = append(out, q)
+		}
+	}
+	return out
+}
+
+// resolve resolves a simple or dotted type name. A dotted name whose first
+// part is in no scope and starts in lower case is taken to be qualified.
+func (r *resolver) resolve(name string) (ref, bool) {
+	first, rest, dotted := strings.Cut(name, ".")
+	join := func(q string) string {
+		if dotted {
+			return q + "." + rest
+		}
+		return q
+	}
+	switch {
+	case r.defs[first] != "":
+		return ref{Symbol: join(r.defs[first])}, true
+	case r.imports[first] != "":
+		return ref{Symbol: join(r.imports[first])}, true
+	case builtins[first]:
+		return ref{}, false
+	case dotted && first != "" && first[0] >= 'a' && first[0] <= 'z':
+		return ref{Symbol: name}, true
+	case r.local && first != "" && first[0] >= 'A' && first[0] <= 'Z':
+		return ref{Symbol: join(qualify(r.pkg, first))}, true
+	}
+	return ref{Name: name}, true
+}
+
+// scope returns a resolver that also sees the nested types of a type.
+func (r *resolver) scope(nested map[string]string) *resolver {
+	if len(nested) == 0 {
+		return r
+	}
+	s := *r
+	s.defs = map[string]string{}
+	for k, v := range r.defs {
+		s.defs[k] = v
+	}
+	for k, v := range nested {
+		s.defs[k] = v
+	}
+	return &s
+}
+
+// erasure returns a type without its type arguments, annotations and
+// nullability: java.util.List for @NonNull java.util.List<String>?.
+func erasure(typ string) string {
+	toks := lex(typ, true)
+	var b strings.Builder
+	for i := 0; i < len(toks); i++ {
+		t := toks[i]
+		switch {
+		case t.Is("@"):
+			i++
+			for i+2 < len(toks) && toks[i+1].Is(".") {
+				i += 2
+			}
+			if i+1 < len(toks) && toks[i+1].Is("(") {
+				for depth := 0; i+1 < len(toks); i++ {
+					if toks[i+1].Opens() {
+						depth++
+					} else if toks[i+1].Closes() {
+						if depth--; depth == 0 {
+							i++
+							break
+						}
+					}
+				}
+			}
+		case t.Kind == outline.Ident || t.Is("."):
+			b.WriteString(t.Text)
+		default:
+			return b.String()
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,321 @@
+package java
+
+import (
+	"strings"
+
+	"github.com/ChaseHampton/cargoworker/internal/pack/outline"
+)
+
+// javaModifiers are the modifiers of Java declarations; sealed and
+// non-sealed are contextual.
+var javaModifiers = outline.Set(`public protected private static final abstract native synchronized transient volatile strictfp default sealed`)
+
+// parseJava outlines a Java compilation unit.
+func parseJava(src string) *file {
+	p := &parser{Cursor: outline.Cursor{Src: src, Toks: lex(src, false)}}
+	f := &file{}
+	for !p.EOF() {
+		start := p.I
+		doc := p.doc()
+		anns := p.annotations()
+		switch t := p.Peek(0); {
+		case t.Is("package"):
+			p.I++
+			from, to := p.Until(false, ";")
+			p.Accept(";")
+			f.pkg, f.pkgLine, f.pkgDoc, f.annotations = p.Name(from, to), t.Line, doc, anns
+		case t.Is("import"):
+			p.I++
+			im := importDecl{line: t.Line, static: p.Accept("static")}
+			from, to := p.Until(false, ";")
+			p.Accept(";")
+			im.path = p.Name(from, to)
+			if path, ok := strings.CutSuffix(im.path, ".*"); ok {
+				im.path, im.wildcard = path, true
+			}
+			f.imports = append(f.imports, im)
+		case t.Is("module") || t.Is("open") && p.Peek(1).Is("module"):
+			return f // module-info.java
+		default:
+			p.I = start
+			f.decls = append(f.decls, p.javaMember("")...)
+		}
+		if p.I == start {
+			p.I++
+		}
+	}
+	return f
+}
+
+// javaMember parses a declaration in a compilation unit or a type body:
+// a type, constructor, method, or one or more fields. owner is the name of
+// the enclosing type.
+func (p *parser) javaMember(owner string) []*decl {
+	d := &decl{doc: p.doc()}
+	d.annotations = p.annotations()
+	for p.javaModifier(d) {
+	}
+	start := p.I
+	switch t := p.Peek(0); {
+	case p.EOF() || t.Is("}"):
+		return nil
+	case t.Is(";"):
+		p.I++
+		return nil
+	case t.Is("{"):
+		p.Group() // an initializer
+		return nil
+	case t.Is("class"), t.Is("interface"), t.Is("enum") && p.Peek(1).Kind == outline.Ident,
+		t.Is("record") && p.Peek(1).Kind == outline.Ident && (p.Peek(2).Is("(") || p.Peek(2).Is("<")),
+		t.Is("@") && p.Peek(1).Is("interface"):
+		p.javaType(d)
+		return []*decl{d}
+	}
+	if p.Peek(0).Is("<") {
+		p.typeParams(d) // of a generic method or constructor
+	}
+	head := p.I
+	from, to := p.Until(true, "(", "=", ";", ",", "{")
+	if to <= from {
+		p.skipStatement()
+		return nil
+	}
+	nameAt := to - 1
+	for nameAt > from && (p.Toks[nameAt].Is("]") || p.Toks[nameAt].Is("[")) {
+		nameAt-- // int a[]
+	}
+	name := p.Toks[nameAt]
+	if name.Kind != outline.Ident {
+		p.skipStatement()
+		return nil
+	}
+	d.name = name.Text
+	p.at(d, nameAt)
+	mods := strings.Join(d.modifiers, " ")
+	switch {
+	case p.Peek(0).Is("("):
+		d.kind = "method"
+		if nameAt == head {
+			d.kind = "constructor"
+		} else {
+			d.returns = p.Text(head, nameAt)
+		}
+		pfrom, pto := p.Group()
+		for _, part := range p.Split(pfrom, pto) {
+			if pr, ok := p.javaParam(part[0], part[1]); ok {
+				d.params = append(d.params, pr)
+			}
+		}
+		for p.Peek(0).Is("[") {
+			p.Group() // int f()[]
+		}
+		if p.Accept("throws") {
+			tfrom, tto := p.Until(true, "{", ";")
+			d.throws = p.typeList(tfrom, tto)
+		}
+		d.sig = strings.TrimSpace(mods + " " + p.Text(start, p.I))
+		if p.Accept("default") {
+			p.Until(false, ";") // an annotation element's default
+		}
+		if p.Peek(0).Is("{") {
+			p.Group()
+		} else {
+			p.Accept(";")
+		}
+		p.end(d)
+		return []*decl{d}
+	case p.Peek(0).Is("{") && nameAt == head && name.Text == owner:
+		p.Group() // a compact record constructor
+		return nil
+	}
+	// Fields: Type a = 1, b[], c;
+	d.kind = "field"
+	typ := p.Text(head, nameAt)
+	out := []*decl{d}
+	cur := d
+	for {
+		cur.typ = typ + p.Text(nameAt+1, p.I) // int a[]
+		cur.sig = strings.TrimSpace(mods + " " + typ + " " + cur.name)
+		if p.Accept("=") {
+			p.Until(false, ",", ";")
+		}
+		p.end(cur)
+		if !p.Accept(",") || p.Peek(0).Kind != outline.Ident {
+			break
+		}
+		next := &decl{kind: "field", name: p.Peek(0).Text, modifiers: d.modifiers, annotations: d.annotations, doc: d.doc}
+		p.at(next, p.I)
+		nameAt = p.I
+		p.I++
+		for p.Peek(0).Is("[") {
+			p.Group()
+		}
+		out = append(out, next)
+		cur = next
+	}
+	p.Accept(";")
+	return out
+}
+
+// javaModifier reads a modifier or annotation of d, reporting whether
+// there was one.
+func (p *parser) javaModifier(d *decl) bool {
+	t := p.Peek(0)
+	switch {
+	case t.Kind == outline.Ident && javaModifiers[t.Text] && !(t.Is("default") && p.Peek(1).Is(":")):
+		d.modifiers = append(d.modifiers, t.Text)
+		p.I++
+	case t.Is("non") && p.Peek(1).Is("-") && p.Peek(2).Is("sealed"):
+		d.modifiers = append(d.modifiers, "non-sealed")
+		p.I += 3
+	case t.Is("@") && !p.Peek(1).Is("interface"):
+		d.annotations = append(d.annotations, p.annotations()...)
+	default:
+		return false
+	}
+	return true
+}
+
+// javaParam parses a formal parameter: [final] [@Ann] Type [...] name.
+func (p *parser) javaParam(from, to int) (param, bool) {
+	save := p.I
+	p.I = from
+	for p.Peek(0).Is("final") || p.Peek(0).Is("@") {
+		if !p.Accept("final") {
+			p.annotations()
+		}
+	}
+	from, p.I = p.I, save
+	last := to - 1
+	for last > from && (p.Toks[last].Is("]") || p.Toks[last].Is("[")) {
+		last--
+	}
+	if last <= from || p.Toks[last].Kind != outline.Ident || p.Toks[last].Is("this") {
+		return param{}, false // a receiver parameter
+	}
+	pr := param{Name: p.Toks[last].Text, Type: p.Text(from, last) + p.Text(last+1, to)}
+	if t, ok := strings.CutSuffix(pr.Type, "..."); ok {
+		pr.Type, pr.Variadic = strings.TrimSpace(t), true
+	}
+	return pr, true
+}
+
+// javaType parses a class, interface, enum, record or annotation type.
+func (p *parser) javaType(d *decl) {
+	start := p.I
+	switch kw := p.Peek(0); {
+	case kw.Is("@"):
+		d.kind = "annotation"
+		p.I += 2
+	default:
+		d.kind = kw.Text
+		p.I++
+	}
+	if p.Peek(0).Kind == outline.Ident {
+		d.name = p.Peek(0).Text
+		p.at(d, p.I)
+		p.I++
+	}
+	p.typeParams(d)
+	if d.kind == "record" && p.Peek(0).Is("(") {
+		from, to := p.Group()
+		for _, part := range p.Split(from, to) {
+			save := p.I
+			p.I = part[0]
+			doc, anns := p.doc(), p.annotations()
+			p.I = save
+			if pr, ok := p.javaParam(part[0], part[1]); ok {
+				d.params = append(d.params, pr)
+				c := &decl{kind: "property", name: pr.Name, typ: pr.Type, doc: doc, annotations: anns, sig: pr.Type + " " + pr.Name}
+				p.at(c, part[1]-1)
+				c.endLine, c.endCol = p.Toks[part[1]-1].EndLine, p.Toks[part[1]-1].EndCol+1
+				d.children = append(d.children, c)
+			}
+		}
+	}
+	for {
+		switch {
+		case p.Accept("extends"):
+			from, to := p.Until(true, "implements", "permits", "{")
+			d.extends = p.typeList(from, to)
+			continue
+		case p.Accept("implements"):
+			from, to := p.Until(true, "extends", "permits", "{")
+			d.implements = p.typeList(from, to)
+			continue
+		case p.Accept("permits"):
+			p.Until(true, "extends", "implements", "{")
+			continue
+		}
+		break
+	}
+	d.sig = strings.TrimSpace(strings.Join(d.modifiers, " ") + " " + p.Text(start, p.I))
+	if p.Peek(0).Is("{") {
+		p.I++
+		if d.kind == "enum" {
+			d.children = append(d.children, p.enumConstants(d.name)...)
+		}
+		for !p.EOF() && !p.Peek(0).Is("}") {
+			before := p.I
+			d.children = append(d.children, p.javaMember(d.name)...)
+			if p.I == before {
+				p.I++
+			}
+		}
+		p.Accept("}")
+	}
+	p.end(d)
+}
+
+// enumConstants parses the constants that open an enum body, and the
+// semicolon after them.
+func (p *parser) enumConstants(enum string) []*decl {
+	var out []*decl
+	for !p.EOF() {
+		doc := p.doc()
+		anns := p.annotations()
+		t := p.Peek(0)
+		if t.Kind != outline.Ident {
+			break
+		}
+		c := &decl{kind: "enum_member", name: t.Text, doc: doc, annotations: anns}
+		p.at(c, p.I)
+		start := p.I
+		p.I++
+		if p.Peek(0).Is("(") {
+			p.Group()
+		}
+		c.sig = enum + "." + p.Text(start, p.I)
+		if p.Peek(0).Is("{") {
+			p.Group() // a constant's body
+		}
+		p.end(c)
+		out = append(out, c)
+		if !p.Accept(",") {
+			break
+		}
+	}
+	p.Accept(";")
+	return out
+}
+
+// skipStatement skips to the end of a statement or block.
+func (p *parser) skipStatement() {
+	for !p.EOF() {
+		t := p.Peek(0)
+		switch {
+		case t.Is(";"):
+			p.I++
+			return
+		case t.Is("{"):
+			p.Group()
+			return
+		case t.Opens():
+			p.Group()
+		case t.Closes():
+			return
+		default:
+			p.I++
+		}
+	}
+}
@@ -0,0 +1,463 @@
+package java
+
+import (
+	"path"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/ChaseHampton/cargoworker/internal/ir"
+	"github.com/ChaseHampton/cargoworker/internal/pack/packtest"
+)
+
+var tree = map[string]string{
+	"shapes/pom.xml": `<?xml version="1.0"?>
+<project>
+  <parent><groupId>com.acme</groupId><version>2.1.0</version></parent>
+  <artifactId>shapes</artifactId>
+  <description>Shapes and areas.</description>
+</project>
+`,
+	"shapes/src/main/java/com/acme/shapes/package-info.java": `/** Shapes and the areas they cover. */
+@ParametersAreNonnullByDefault
+package com.acme.shapes;
+`,
+	"shapes/src/main/java/com/acme/shapes/Shape.java": `package com.acme.shapes;
+
+/** Anything with an area. */
+public interface Shape extends Named, Comparable<Shape> {
+    /**
+     * The area it covers.
+     *
+     * @param scale the factor to scale by
+     * @return the area
+     * @throws IllegalStateException if it is not closed
+     */
+    double area(double scale);
+
+    int SIDES = 0;
+}
+`,
+	"shapes/src/main/java/com/acme/shapes/Named.java": `package com.acme.shapes;
+
+interface Named {
+    String name();
+}
+`,
+	"shapes/src/main/java/com/acme/shapes/Square.java": `package com.acme.shapes;
+
+import java.io.IOException;
+import java.util.List;
+import com.acme.geo.Point;
+import static java.lang.Math.max;
+
+/**
+ * A square.
+ */
+@Entity
+@Table(name = "squares")
+public final class Square extends Polygon implements Shape, java.io.Serializable {
+    private final double side, cached[];
+    protected Point origin;
+
+    /** Makes a square. */
+    public Square(double side) { this.side = side; }
+
+    @Override
+    public double area(double scale) { return side * side * scale; }
+
+    /**
+     * Saves it.
+     * @param out where to
+     * @throws IOException when writing fails
+     * @throws IllegalArgumentException if out is empty
+     */
+    <T extends Point> List<T> save(String out, T... more) throws IOException { return null; }
+
+    static class Corner {}
+
+    public enum Kind { SQUARE, RHOMBUS("r") { }, ; Kind() {} Kind(String s) {} }
+
+    public record Span(double from, double to) implements Comparable<Span> {
+        public Span { }
+        public int compareTo(Span o) { return 0; }
+    }
+
+    public @interface Marker { String value() default "x"; }
+}
+`,
+	"shapes/src/main/java/com/acme/shapes/Polygon.java": `package com.acme.shapes;
+
+public abstract sealed class Polygon permits Square {
+    String label = "p";
+}
+`,
+	"shapes/src/test/java/com/acme/shapes/SquareTest.java": `package com.acme.other;
+
+class SquareTest {}
+`,
+	"geo/build.gradle.kts": `group = "com.acme"
+version = "1.0"
+
+sourceSets {
+    main {
+        java.srcDirs("gen")
+    }
+}
+`,
+	"geo/settings.gradle.kts": `rootProject.name = "acme-geo"
+`,
+	"geo/src/main/kotlin/com/acme/geo/Point.kt": `@file:JvmName("Points")
+package com.acme.geo
+
+import com.acme.shapes.Shape
+import com.acme.shapes.Square as Sq
+
+/**
+ * A point in the plane.
+ *
+ * @property x the abscissa
+ */
+data class Point(val x: Double, var y: Double = 0.0) : Base(1), Shape, Comparable<Point> {
+    override fun area(scale: Double): Double = 0.0
+
+    /**
+     * Moves it.
+     * @throws IllegalArgumentException when too far
+     */
+    @Throws(java.io.IOException::class)
+    fun move(dx: Double, vararg dys: Double): Point {
+        return Point(x + dx)
+    }
+
+    val norm: Double
+        get() = x * x
+    private var cache: Sq? = null
+        private set
+
+    constructor(s: Sq) : this(0.0)
+
+    companion object {
+        const val ORIGIN = 0
+        fun zero() = Point(0.0)
+    }
+}
+
+open class Base(val id: Int)
+
+sealed interface Path : Shape
+
+internal fun <T> List<T>.second(): T = this[1]
+
+val String.shout: String get() = uppercase()
+
+typealias Points = List<Point>
+
+enum class Dir(val dx: Int) { N(0), S(1); fun flip() = this }
+
+object Registry : Path {
+    override fun area(scale: Double) = 0.0
+}
+`,
+	"geo/gen/com/acme/geo/Gen.java": `package com.acme.geo;
+public class Gen {}
+`,
+	"scratch/Loose.java": `class Loose {}
+`,
+}
+
+func TestExtract(t *testing.T) {
+	f := packtest.ExtractTree(t, tree, packtest.ByExt(map[string]string{".java": "java", ".kt": "kotlin", ".kts": "kotlin"}))
+
+	containers := map[string]ir.Container{}
+	byID := map[uuid.UUID]string{}
+	for _, c := range f.Containers {
+		if _, dup := containers[c.FullName]; dup {
+			t.Errorf("duplicate container %s", c.FullName)
+		}
+		containers[c.FullName] = c
+		byID[c.Id] = c.FullName
+	}
+	if m := containers["com.acme:shapes"]; m.Kind != "module" || m.VersionTag != "2.1.0" || m.DocRaw != "Shapes and areas." ||
+		m.ExtraJson != `{"build":"maven","dir":"shapes","group":"com.acme","artifact":"shapes","source_roots":[{"dir":"shapes/src/main/java"},{"dir":"shapes/src/test/java","test":true}]}` {
+		t.Errorf("shapes module = %+v", m)
+	}
+	if m := containers["com.acme:acme-geo"]; m.VersionTag != "1.0" ||
+		m.ExtraJson != `{"build":"gradle","dir":"geo","group":"com.acme","artifact":"acme-geo","source_roots":[{"dir":"geo/src/main/kotlin"},{"dir":"geo/gen"}]}` {
+		t.Errorf("geo module = %+v", m)
+	}
+	for name, want := range map[string]string{
+		"com.acme.shapes": "package com.acme:shapes Shapes and the areas they cover.",
+		"com.acme.other":  "package com.acme:shapes ",
+		"com.acme.geo":    "package com.acme:acme-geo ",
+		defaultPackage:    "package  ",
+	} {
+		c := containers[name]
+		if got := c.Kind + " " + byID[c.ParentId] + " " + c.DocFmt; got != want {
+			t.Errorf("container %s = %q, want %q", name, got, want)
+		}
+	}
+	if c := containers["com.acme.shapes"]; c.ExtraJson != `{"annotations":["ParametersAreNonnullByDefault"]}` {
+		t.Errorf("package extra = %s", c.ExtraJson)
+	}
+	if len(f.Diagnostics) != 1 || f.Diagnostics[0].Code != "package_dir" || f.Diagnostics[0].Line != 1 {
+		t.Errorf("diagnostics = %+v", f.Diagnostics)
+	}
+	// Build scripts locate the projects; they are not sources.
+	for _, fl := range f.Files {
+		if base := path.Base(fl.Path); base == "pom.xml" || strings.HasPrefix(base, "build.gradle") || strings.HasPrefix(base, "settings.gradle") {
+			t.Errorf("build file %s indexed as a source", fl.Path)
+		}
+	}
+
+	syms := map[string]ir.Symbol{}
+	for _, s := range f.Symbols {
+		if _, dup := syms[s.FullName]; dup && s.Kind != "constructor" {
+			t.Errorf("duplicate symbol %s", s.FullName)
+		}
+		if _, dup := syms[s.FullName]; !dup {
+			syms[s.FullName] = s
+		}
+	}
+	for name, want := range map[string]string{
+		"com.acme.shapes.Shape":                    "interface public",
+		"com.acme.shapes.Shape.area":               "method public",
+		"com.acme.shapes.Shape.SIDES":              "field public",
+		"com.acme.shapes.Named":                    "interface internal",
+		"com.acme.shapes.Square":                   "class public",
+		"com.acme.shapes.Square.side":              "field private",
+		"com.acme.shapes.Square.cached":            "field private",
+		"com.acme.shapes.Square.origin":            "field protected",
+		"com.acme.shapes.Square.Square":            "constructor public",
+		"com.acme.shapes.Square.save":              "method internal",
+		"com.acme.shapes.Square.Corner":            "class internal",
+		"com.acme.shapes.Square.Kind":              "enum public",
+		"com.acme.shapes.Square.Kind.SQUARE":       "enum_member public",
+		"com.acme.shapes.Square.Kind.RHOMBUS":      "enum_member public",
+		"com.acme.shapes.Square.Kind.Kind":         "constructor private",
+		"com.acme.shapes.Square.Span":              "record public",
+		"com.acme.shapes.Square.Span.from":         "property public",
+		"com.acme.shapes.Square.Span.compareTo":    "method public",
+		"com.acme.shapes.Square.Marker":            "annotation public",
+		"com.acme.shapes.Square.Marker.value":      "method public",
+		"com.acme.shapes.Polygon.label":            "field internal",
+		"com.acme.other.SquareTest":                "class internal",
+		"com.acme.geo.Point":                       "class public",
+		"com.acme.geo.Point.Point":                 "constructor public",
+		"com.acme.geo.Point.x":                     "property public",
+		"com.acme.geo.Point.y":                     "property public",
+		"com.acme.geo.Point.move":                  "method public",
+		"com.acme.geo.Point.norm":                  "property public",
+		"com.acme.geo.Point.cache":                 "property private",
+		"com.acme.geo.Point.Companion":             "object public",
+		"com.acme.geo.Point.Companion.ORIGIN":      "property public",
+		"com.acme.geo.Point.Companion.zero":        "method public",
+		"com.acme.geo.Base.id":                     "property public",
+		"com.acme.geo.Path":                        "interface public",
+		"com.acme.geo.second":                      "function internal",
+		"com.acme.geo.shout":                       "property public",
+		"com.acme.geo.Points":                      "typealias public",
+		"com.acme.geo.Dir.N":                       "enum_member public",
+		"com.acme.geo.Dir.flip":                    "method public",
+		"com.acme.geo.Registry":                    "object public",
+		"com.acme.geo.Gen":                         "class public",
+		"Loose":                                    "class internal",
+		"com.acme.shapes.Square.Span.Span":         "",
+		"com.acme.geo.Point.Companion.Companion":   "",
+		"com.acme.shapes.Square.Kind.Kind.RHOMBUS": "",
+	} {
+		if got := strings.TrimSpace(syms[name].Kind + " " + syms[name].Visibility); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	if sq := syms["com.acme.shapes.Square"]; sq.StartLine != 13 || sq.StartCol != 20 || sq.EndLine != 41 || sq.DocFmt != "A square." ||
+		sq.ExtraJson != `{"modifiers":["public","final"],"annotations":["Entity","Table(name = \"squares\")"],"doc":{"summary":"A square."}}` {
+		t.Errorf("Square = %+v", sq)
+	}
+	if a := syms["com.acme.shapes.Shape.area"]; a.ExtraJson !=
+		`{"doc":{"summary":"The area it covers.","params":[{"name":"scale","desc":"the factor to scale by"}],"returns":"the area","throws":[{"type":"IllegalStateException","desc":"if it is not closed"}]}}` {
+		t.Errorf("area extra = %s", a.ExtraJson)
+	}
+	if s := syms["com.acme.geo.second"]; s.ExtraJson != `{"modifiers":["internal"],"receiver":"List\u003cT\u003e"}` {
+		t.Errorf("second extra = %s", s.ExtraJson)
+	}
+
+	owners := map[uuid.UUID]string{}
+	for name, s := range syms {
+		owners[s.Id] = name
+	}
+	var rels []string
+	for _, r := range f.Relations {
+		rels = append(rels, owners[r.SourceSymbolId]+" "+r.Relation+" "+owners[r.DstSymbolId])
+	}
+	sort.Strings(rels)
+	if got, want := strings.Join(rels, "\n"), strings.Join([]string{
+		"com.acme.geo.Path extends com.acme.shapes.Shape",
+		"com.acme.geo.Point extends com.acme.geo.Base",
+		"com.acme.geo.Point implements com.acme.shapes.Shape",
+		"com.acme.geo.Registry implements com.acme.geo.Path",
+		"com.acme.shapes.Shape extends com.acme.shapes.Named",
+		"com.acme.shapes.Square extends com.acme.shapes.Polygon",
+		"com.acme.shapes.Square implements com.acme.shapes.Shape",
+	}, "\n"); got != want {
+		t.Errorf("relations =\n%s\nwant\n%s", got, want)
+	}
+	members := map[string]string{}
+	for _, m := range f.Members {
+		members[owners[m.ChildSymbolId]] = owners[m.OwnerSymbolId]
+	}
+	for child, owner := range map[string]string{
+		"com.acme.shapes.Square.area":           "com.acme.shapes.Square",
+		"com.acme.shapes.Square.Kind.SQUARE":    "com.acme.shapes.Square.Kind",
+		"com.acme.shapes.Square.Span.from":      "com.acme.shapes.Square.Span",
+		"com.acme.geo.Point.x":                  "com.acme.geo.Point",
+		"com.acme.geo.Point.Companion.zero":     "com.acme.geo.Point.Companion",
+		"com.acme.geo.second":                   "",
+		"com.acme.shapes.Square.Marker.value":   "com.acme.shapes.Square.Marker",
+		"com.acme.shapes.Square.Span.compareTo": "com.acme.shapes.Square.Span",
+	} {
+		if members[child] != owner {
+			t.Errorf("owner of %s = %q, want %q", child, members[child], owner)
+		}
+	}
+
+	sigs := map[uuid.UUID]ir.Signature{}
+	for _, s := range f.Signatures {
+		sigs[s.SymbolId] = s
+	}
+	for name, want := range map[string]string{
+		"com.acme.shapes.Square":              "public final class Square extends Polygon implements Shape, java.io.Serializable",
+		"com.acme.shapes.Square.save":         "<T extends Point> List<T> save(String out, T... more) throws IOException",
+		"com.acme.shapes.Square.cached":       "private final double cached",
+		"com.acme.shapes.Square.Span":         "public record Span(double from, double to) implements Comparable<Span>",
+		"com.acme.shapes.Square.Kind.RHOMBUS": `Kind.RHOMBUS("r")`,
+		"com.acme.shapes.Square.Marker.value": `String value()`,
+		"com.acme.shapes.Polygon":             "public abstract sealed class Polygon permits Square",
+		"com.acme.geo.Point":                  "data class Point(val x: Double, var y: Double = 0.0) : Base(1), Shape, Comparable<Point>",
+		"com.acme.geo.Point.Point":            "constructor(val x: Double, var y: Double = 0.0)",
+		"com.acme.geo.Point.move":             "fun move(dx: Double, vararg dys: Double): Point",
+		"com.acme.geo.Point.norm":             "val norm: Double",
+		"com.acme.geo.Point.cache":            "private var cache: Sq?",
+		"com.acme.geo.Point.Companion.ORIGIN": "const val ORIGIN",
+		"com.acme.geo.second":                 "internal fun <T> List<T>.second(): T",
+		"com.acme.geo.shout":                  "val String.shout: String",
+		"com.acme.geo.Points":                 "typealias Points = List<Point>",
+		"com.acme.geo.Registry":               "object Registry : Path",
+	} {
+		if got := sigs[syms[name].Id].Text; got != want {
+			t.Errorf("%s sig = %s, want %s", name, got, want)
+		}
+	}
+	for name, want := range map[string]string{
+		"com.acme.shapes.Square.save": `{"params":[{"name":"out","type":"String"},{"name":"more","type":"T","variadic":true}],"results":[{"type":"List\u003cT\u003e"}],` +
+			`"type_params":[{"name":"T","constraint":"Point"}],"throws":[{"type":"IOException","desc":"when writing fails"},{"type":"IllegalArgumentException","desc":"if out is empty"}]}`,
+		"com.acme.shapes.Shape.area": `{"params":[{"name":"scale","type":"double"}],"results":[{"type":"double"}],"throws":[{"type":"IllegalStateException","desc":"if it is not closed"}]}`,
+		"com.acme.geo.Point.move": `{"params":[{"name":"dx","type":"Double"},{"name":"dys","type":"Double","variadic":true}],"results":[{"type":"Point"}],` +
+			`"throws":[{"type":"java.io.IOException"},{"type":"IllegalArgumentException","desc":"when too far"}]}`,
+		"com.acme.geo.second":         `{"receiver":"List\u003cT\u003e","results":[{"type":"T"}],"type_params":[{"name":"T"}]}`,
+		"com.acme.geo.Point.Point":    `{"params":[{"name":"x","type":"Double"},{"name":"y","type":"Double","default":"0.0"}]}`,
+		"com.acme.shapes.Square.Span": `{"params":[{"name":"from","type":"double"},{"name":"to","type":"double"}]}`,
+	} {
+		if got := sigs[syms[name].Id].Json; got != want {
+			t.Errorf("%s sig json = %s\nwant %s", name, got, want)
+		}
+	}
+
+	refs := map[string][]string{}
+	for _, tr := range f.Typerefs {
+		key := owners[tr.OwnerSymbolId] + " " + tr.Slot
+		refs[key] = append(refs[key], tr.Json)
+	}
+	for key, want := range map[string]string{
+		"com.acme.shapes.Square base:0":            `{"symbol":"com.acme.shapes.Polygon","type":"class","text":"Polygon"}`,
+		"com.acme.shapes.Square implements:1":      `{"symbol":"java.io.Serializable","type":"interface","text":"java.io.Serializable"}`,
+		"com.acme.shapes.Square field:origin":      `{"symbol":"com.acme.geo.Point","text":"Point"}`,
+		"com.acme.shapes.Square.save param:0":      "",
+		"com.acme.shapes.Square.save result:0":     `{"symbol":"java.util.List","text":"List\u003cT\u003e"}`,
+		"com.acme.shapes.Square.save throws:0":     `{"symbol":"java.io.IOException","type":"class","text":"IOException"}`,
+		"com.acme.shapes.Square.Span implements:0": `{"symbol":"com.acme.shapes.Square.Span","type":"interface","text":"Comparable\u003cSpan\u003e"}`,
+		"com.acme.geo.Point base:0":                `{"symbol":"com.acme.geo.Base","type":"class","text":"Base"}`,
+		"com.acme.geo.Point field:cache":           `{"symbol":"com.acme.shapes.Square","text":"Sq?"}`,
+		"com.acme.geo.Points type":                 `{"symbol":"com.acme.geo.Point","text":"List\u003cPoint\u003e"}`,
+		"com.acme.geo.second receiver":             "",
+	} {
+		if got := strings.Join(refs[key], " "); got != want {
+			t.Errorf("typerefs %s = %s, want %s", key, got, want)
+		}
+	}
+
+	var imports []string
+	for _, im := range f.Imports {
+		imports = append(imports, im.Target+" "+im.Alias+" "+im.DetailsJson)
+	}
+	for _, want := range []string{
+		`java.io.IOException  {"file":"shapes/src/main/java/com/acme/shapes/Square.java","line":3,"stdlib":true}`,
+		`java.lang.Math.max  {"file":"shapes/src/main/java/com/acme/shapes/Square.java","line":6,"static":true,"stdlib":true}`,
+		`com.acme.shapes.Square Sq {"file":"geo/src/main/kotlin/com/acme/geo/Point.kt","line":5}`,
+	} {
+		found := false
+		for _, im := range imports {
+			found = found || im == want
+		}
+		if !found {
+			t.Errorf("missing import %s in\n%s", want, strings.Join(imports, "\n"))
+		}
+	}
+}
+
+// TestMixedLanguages extends types across the Java and Kotlin roots, and
+// the main and test roots, of one package.
+func TestMixedLanguages(t *testing.T) {
+	f := packtest.ExtractTree(t, map[string]string{
+		"pom.xml": `<project><groupId>com.y</groupId><artifactId>y</artifactId></project>`,
+		"src/main/java/com/y/Base.java": `package com.y;
+
+public class Base {}
+`,
+		"src/main/java/com/y/JSub.java": `package com.y;
+
+public class JSub extends KBase {}
+`,
+		"src/main/kotlin/com/y/KSub.kt": `package com.y
+
+open class KBase
+
+class KSub : Base()
+`,
+		"src/test/kotlin/com/y/KSubTest.kt": `package com.y
+
+class KSubTest : KBase()
+`,
+	}, packtest.ByExt(map[string]string{".java": "java", ".kt": "kotlin"}))
+
+	names := map[uuid.UUID]string{}
+	for _, s := range f.Symbols {
+		names[s.Id] = s.FullName
+	}
+	var rels []string
+	for _, r := range f.Relations {
+		rels = append(rels, names[r.SourceSymbolId]+" "+r.Relation+" "+names[r.DstSymbolId])
+	}
+	sort.Strings(rels)
+	if got, want := strings.Join(rels, "\n"), strings.Join([]string{
+		"com.y.JSub extends com.y.KBase",
+		"com.y.KSub extends com.y.Base",
+		"com.y.KSubTest extends com.y.KBase",
+	}, "\n"); got != want {
+		t.Errorf("relations =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestLex(t *testing.T) {
+	src := "/** Doc. */\nval s = \"a${f(\"}\")}b\" /* a /* nested */ b */ + `odd name` + 'c'\n"
+	var got []string
+	for _, tok := range lex(src, true) {
+		got = append(got, tok.Text)
+	}
+	want := []string{"/** Doc. */", "val", "s", "=", `"a${f("}")}b"`, "+", "odd name", "+", "'c'"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("tokens = %q, want %q", got, want)
+	}
+}
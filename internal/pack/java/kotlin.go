@@ -0,0 +1,384 @@
+package java
+
+import (
+	"strings"
+
+	"github.com/ChaseHampton/cargoworker/internal/pack/outline"
+)
+
+// kotlinModifiers are Kotlin's modifier keywords, all soft: they are
+// modifiers only before another word.
+var kotlinModifiers = outline.Set(`public protected private internal open final abstract sealed data inner enum annotation
+	companion override lateinit const inline noinline crossinline tailrec operator infix external suspend
+	expect actual value vararg`)
+
+// parseKotlin outlines a Kotlin file or script.
+func parseKotlin(src string) *file {
+	p := &parser{Cursor: outline.Cursor{Src: src, Toks: lex(src, true)}, kotlin: true}
+	p.Ends = p.lineEnds
+	f := &file{}
+	for !p.EOF() {
+		start := p.I
+		doc := p.doc()
+		anns := p.annotations()
+		switch t := p.Peek(0); {
+		case t.Is("package"):
+			p.I++
+			from, to := p.Until(false, ";")
+			p.Accept(";")
+			f.pkg, f.pkgLine, f.pkgDoc, f.annotations = p.Name(from, to), t.Line, doc, anns
+		case t.Is("import"):
+			p.I++
+			im := importDecl{line: t.Line}
+			from, to := p.Until(false, ";", "as")
+			im.path = p.Name(from, to)
+			if path, ok := strings.CutSuffix(im.path, ".*"); ok {
+				im.path, im.wildcard = path, true
+			}
+			if p.Accept("as") && p.Peek(0).Kind == outline.Ident {
+				im.alias = p.Peek(0).Text
+				p.I++
+			}
+			p.Accept(";")
+			f.imports = append(f.imports, im)
+		default:
+			p.I = start
+			f.decls = append(f.decls, p.kotlinMember("")...)
+		}
+		if p.I == start {
+			p.I++
+		}
+	}
+	return f
+}
+
+// kotlinMember parses a declaration at the top level or in a class body:
+// a class, interface or object, a function, a property, a type alias or a
+// secondary constructor. Statements of scripts are skipped. owner is the
+// name of the enclosing type.
+func (p *parser) kotlinMember(owner string) []*decl {
+	d := &decl{doc: p.doc()}
+	d.annotations = p.annotations()
+	for p.kotlinModifier(d) {
+	}
+	start := p.I
+	switch t := p.Peek(0); {
+	case p.EOF() || t.Is("}"):
+		return nil
+	case t.Is(";"):
+		p.I++
+		return nil
+	case t.Is("class"), t.Is("interface"), t.Is("object"):
+		p.kotlinType(d)
+		return []*decl{d}
+	case t.Is("fun") && p.Peek(1).Is("interface"):
+		d.modifiers = append(d.modifiers, "fun")
+		p.I++
+		p.kotlinType(d)
+		return []*decl{d}
+	case t.Is("fun"):
+		p.I++
+		p.kotlinFunction(d, owner, start)
+		return []*decl{d}
+	case t.Is("val"), t.Is("var"):
+		p.I++
+		if p.kotlinProperty(d, start) {
+			return []*decl{d}
+		}
+		return nil
+	case t.Is("typealias"):
+		p.I++
+		d.kind = "typealias"
+		if p.Peek(0).Kind == outline.Ident {
+			d.name = p.Peek(0).Text
+			p.at(d, p.I)
+			p.I++
+		}
+		p.typeParams(d)
+		if p.Accept("=") {
+			from, to := p.Until(true, ";")
+			d.typ = p.Text(from, to)
+		}
+		d.sig = strings.TrimSpace(strings.Join(d.modifiers, " ") + " " + p.Text(start, p.I))
+		p.end(d)
+		p.Accept(";")
+		return []*decl{d}
+	case t.Is("constructor") && p.Peek(1).Is("(") && owner != "":
+		d.kind, d.name = "constructor", owner
+		p.at(d, p.I)
+		p.I++
+		d.params = p.kotlinParams(nil)
+		d.sig = strings.TrimSpace(strings.Join(d.modifiers, " ") + " " + p.Text(start, p.I))
+		if p.Accept(":") {
+			p.Until(false, "{", ";") // this(...) or super(...)
+		}
+		if p.Peek(0).Is("{") {
+			p.Group()
+		}
+		p.end(d)
+		return []*decl{d}
+	case t.Is("init") && p.Peek(1).Is("{"):
+		p.I++
+		p.Group()
+		return nil
+	}
+	p.Until(false, ";")
+	p.Accept(";")
+	return nil
+}
+
+// kotlinModifier reads a modifier or annotation of d, reporting whether
+// there was one.
+func (p *parser) kotlinModifier(d *decl) bool {
+	t := p.Peek(0)
+	switch {
+	case t.Kind == outline.Ident && kotlinModifiers[t.Text] && (p.Peek(1).Kind == outline.Ident || p.Peek(1).Is("@")):
+		d.modifiers = append(d.modifiers, t.Text)
+		p.I++
+	case t.Is("@"):
+		d.annotations = append(d.annotations, p.annotations()...)
+	default:
+		return false
+	}
+	return true
+}
+
+// kotlinType parses a class, interface or object declaration: its primary
+// constructor, its supertypes and its body.
+func (p *parser) kotlinType(d *decl) {
+	start := p.I
+	switch {
+	case p.Peek(0).Is("object"):
+		d.kind = "object"
+	case p.Peek(0).Is("interface"):
+		d.kind = "interface"
+	case d.has("enum"):
+		d.kind = "enum"
+	case d.has("annotation"):
+		d.kind = "annotation"
+	default:
+		d.kind = "class"
+	}
+	p.I++
+	if p.Peek(0).Kind == outline.Ident && !p.Peek(0).Is("constructor") {
+		d.name = p.Peek(0).Text
+		p.at(d, p.I)
+		p.I++
+	} else if d.has("companion") {
+		d.name = "Companion"
+		p.at(d, start)
+	}
+	p.typeParams(d)
+
+	// The primary constructor, whose val and var parameters are properties.
+	ctor := &decl{kind: "constructor", name: d.name}
+	save := p.I
+	for p.kotlinModifier(ctor) {
+	}
+	if !p.Peek(0).Is("constructor") && !p.Peek(0).Is("(") {
+		p.I = save // the modifiers of the next declaration
+	} else {
+		cstart := p.I
+		p.at(ctor, p.I)
+		p.Accept("constructor")
+		if p.Peek(0).Is("(") {
+			var props []*decl
+			ctor.params = p.kotlinParams(&props)
+			ctor.sig = strings.TrimSpace(strings.Join(ctor.modifiers, " ") + " constructor" + strings.TrimPrefix(p.Text(cstart, p.I), "constructor"))
+			p.end(ctor)
+			d.children = append(d.children, ctor)
+			d.children = append(d.children, props...)
+		}
+	}
+
+	if p.Accept(":") {
+		from, to := p.Until(true, "{", "where", ";")
+		for _, part := range p.Split(from, to) {
+			typ, call := p.supertype(part[0], part[1])
+			if d.kind == "interface" || call {
+				d.extends = append(d.extends, typ)
+			} else {
+				d.implements = append(d.implements, typ)
+			}
+		}
+	}
+	if p.Accept("where") {
+		p.Until(true, "{", ";")
+	}
+	d.sig = strings.TrimSpace(strings.Join(d.modifiers, " ") + " " + p.Text(start, p.I))
+	if p.Peek(0).Is("{") {
+		p.I++
+		if d.kind == "enum" {
+			d.children = append(d.children, p.enumConstants(d.name)...)
+		}
+		for !p.EOF() && !p.Peek(0).Is("}") {
+			before := p.I
+			d.children = append(d.children, p.kotlinMember(d.name)...)
+			if p.I == before {
+				p.I++
+			}
+		}
+		p.Accept("}")
+	}
+	p.end(d)
+}
+
+// supertype returns the type of a supertype entry, without the arguments
+// of a superclass constructor call or an interface delegation, and whether
+// it called a constructor.
+func (p *parser) supertype(from, to int) (string, bool) {
+	if by := p.Find(from, to, "by"); by < to {
+		return p.Text(from, by), false
+	}
+	if call := p.Find(from, to, "("); call < to {
+		return p.Text(from, call), true
+	}
+	return p.Text(from, to), false
+}
+
+// kotlinParams parses a parenthesized parameter list. Where props is not
+// nil, val and var parameters are added to it as properties.
+func (p *parser) kotlinParams(props *[]*decl) []param {
+	from, to := p.Group()
+	out := []param{}
+	for _, part := range p.Split(from, to) {
+		save := p.I
+		p.I = part[0]
+		prop := &decl{kind: "property", doc: p.doc()}
+		for p.kotlinModifier(prop) {
+		}
+		isProp := p.Peek(0).Is("val") || p.Peek(0).Is("var")
+		if isProp {
+			prop.modifiers = append(prop.modifiers, p.Peek(0).Text)
+			p.I++
+		}
+		i := p.I
+		p.I = save
+		if i >= part[1] || p.Toks[i].Kind != outline.Ident {
+			continue
+		}
+		pr := param{Name: p.Toks[i].Text, Variadic: prop.has("vararg")}
+		end := p.Find(i, part[1], "=")
+		if end < part[1] {
+			pr.Default = p.Text(end+1, part[1])
+		}
+		if i+1 < end && p.Toks[i+1].Is(":") {
+			pr.Type = p.Text(i+2, end)
+		}
+		out = append(out, pr)
+		if isProp && props != nil {
+			prop.name, prop.typ = pr.Name, pr.Type
+			prop.sig = strings.TrimSpace(strings.Join(prop.modifiers, " ") + " " + pr.Name + ": " + pr.Type)
+			p.at(prop, i)
+			last := p.Toks[part[1]-1]
+			prop.endLine, prop.endCol = last.EndLine, last.EndCol+1
+			*props = append(*props, prop)
+		}
+	}
+	return out
+}
+
+// kotlinFunction parses a function after fun: its type parameters,
+// receiver, parameters, result and body. Functions in a type are methods.
+func (p *parser) kotlinFunction(d *decl, owner string, start int) {
+	d.kind = "function"
+	if owner != "" {
+		d.kind = "method"
+	}
+	p.typeParams(d)
+	from, to := p.Until(true, "(", "=", "{", ":")
+	if !p.receiver(d, from, to) {
+		return
+	}
+	if p.Peek(0).Is("(") {
+		d.params = p.kotlinParams(nil)
+	}
+	if p.Accept(":") {
+		rfrom, rto := p.Until(true, "{", "=", "where", ";")
+		d.returns = p.Text(rfrom, rto)
+	}
+	if p.Accept("where") {
+		p.Until(true, "{", "=", ";")
+	}
+	d.sig = strings.TrimSpace(strings.Join(d.modifiers, " ") + " " + p.Text(start, p.I))
+	switch {
+	case p.Peek(0).Is("{"):
+		p.Group()
+	case p.Accept("="):
+		p.Until(false, ";")
+	}
+	p.end(d)
+	p.Accept(";")
+}
+
+// receiver sets the name of a function or property from the tokens
+// [from, to) before its parameters or type, and the receiver type of an
+// extension, as in List<T>.second. It reports whether there was a name.
+func (p *parser) receiver(d *decl, from, to int) bool {
+	nameAt := to - 1
+	if nameAt < from || p.Toks[nameAt].Kind != outline.Ident {
+		return false
+	}
+	d.name = p.Toks[nameAt].Text
+	p.at(d, nameAt)
+	if nameAt-1 > from && (p.Toks[nameAt-1].Is(".") || p.Toks[nameAt-1].Is("?.")) {
+		d.receiver = p.Text(from, nameAt-1)
+	}
+	return true
+}
+
+// kotlinProperty parses a property after val or var: its receiver, type,
+// initializer or delegate, and accessors. Destructuring declarations are
+// skipped and reported false.
+func (p *parser) kotlinProperty(d *decl, start int) bool {
+	d.kind = "property"
+	d.modifiers = append(d.modifiers, p.Toks[p.I-1].Text)
+	p.typeParams(d)
+	if p.Peek(0).Is("(") {
+		p.Until(false, ";")
+		return false
+	}
+	from, to := p.Until(true, ":", "=", "by", ";", "{")
+	if !p.receiver(d, from, to) {
+		return false
+	}
+	if p.Accept(":") {
+		tfrom, tto := p.Until(true, "=", "by", ";", "{", "get", "set")
+		d.typ = p.Text(tfrom, tto)
+	}
+	d.sig = strings.TrimSpace(strings.Join(d.modifiers[:len(d.modifiers)-1], " ") + " " + p.Text(start, p.I))
+	if p.Accept("=") || p.Accept("by") {
+		p.Until(false, ";")
+	}
+	p.end(d)
+	p.Accept(";")
+	// Accessors: get() = ..., private set, outline.Set(value) { ... }.
+	for {
+		save := p.I
+		p.doc()
+		p.annotations()
+		for p.Peek(0).Kind == outline.Ident && kotlinModifiers[p.Peek(0).Text] && (p.Peek(1).Is("get") || p.Peek(1).Is("set")) {
+			p.I++
+		}
+		if !p.Peek(0).Is("get") && !p.Peek(0).Is("set") || p.Peek(1).Is(".") || p.Peek(1).Is("?.") {
+			p.I = save
+			break
+		}
+		p.I++
+		if p.Peek(0).Is("(") {
+			p.Group()
+		}
+		if p.Accept(":") {
+			p.Until(true, "{", "=", ";")
+		}
+		switch {
+		case p.Peek(0).Is("{"):
+			p.Group()
+		case p.Accept("="):
+			p.Until(false, ";")
+		}
+		p.Accept(";")
+		p.end(d)
+	}
+	return true
+}
@@ -0,0 +1,314 @@
+package java
+
+import (
+	"encoding/xml"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// project is a Maven or Gradle module: a directory with a pom.xml or a
+// build.gradle(.kts).
+type project struct {
+	dir         string // relative to the input root
+	build       string // maven or gradle
+	group       string
+	artifact    string
+	version     string
+	description string
+	roots       []sourceRoot
+}
+
+// sourceRoot is a directory packages are laid out under.
+type sourceRoot struct {
+	Dir  string `json:"dir"` // relative to the input root
+	Test bool   `json:"test,omitempty"`
+}
+
+// name returns the full name of a project's container: group:artifact, as
+// Maven coordinates are written.
+func (p *project) name() string {
+	if p.group == "" {
+		return p.artifact
+	}
+	return p.group + ":" + p.artifact
+}
+
+// root returns the source root a file is under, or nil.
+func (p *project) root(rel string) *sourceRoot {
+	for i := range p.roots {
+		if strings.HasPrefix(rel, p.roots[i].Dir+"/") {
+			return &p.roots[i]
+		}
+	}
+	return nil
+}
+
+// layout finds the Maven and Gradle modules of an input root and their
+// source roots, which plan the package containers of the files under them.
+type layout struct {
+	root     string
+	mu       sync.Mutex
+	projects map[string]*project // by directory; nil where there is none
+	parses   map[string]*file
+	types    map[[2]string]map[string]string // top-level types, by project directory and package
+}
+
+func newLayout(root string) *layout {
+	return &layout{root: root, projects: map[string]*project{}, parses: map[string]*file{}, types: map[[2]string]map[string]string{}}
+}
+
+// parse parses a Java or Kotlin file under the root, once; nil if it cannot
+// be read.
+func (l *layout) parse(rel string) *file {
+	if f, ok := l.parses[rel]; ok {
+		return f
+	}
+	var f *file
+	if b, err := os.ReadFile(filepath.Join(l.root, filepath.FromSlash(rel))); err == nil {
+		f = parseSource(rel, string(b))
+	}
+	l.parses[rel] = f
+	return f
+}
+
+// packageTypes returns the top-level types a package declares under the
+// source roots of a project, in Java and Kotlin alike: name -> full name.
+// The main and test sources of a package, and its Java and Kotlin sources,
+// are in different roots and so in different units.
+func (l *layout) packageTypes(proj *project, pkg string) map[string]string {
+	key := [2]string{proj.dir, pkg}
+	if t, ok := l.types[key]; ok {
+		return t
+	}
+	t := map[string]string{}
+	for _, r := range proj.roots {
+		dir := path.Join(r.Dir, strings.ReplaceAll(pkg, ".", "/"))
+		entries, _ := os.ReadDir(filepath.Join(l.root, filepath.FromSlash(dir)))
+		for _, e := range entries {
+			rel := path.Join(dir, e.Name())
+			if e.IsDir() || !isSource(rel) {
+				continue
+			}
+			f := l.parse(rel)
+			if f == nil || f.pkg != pkg {
+				continue
+			}
+			for _, d := range f.decls {
+				if isType(d.kind) || d.kind == "typealias" {
+					t[d.name] = qualify(pkg, d.name)
+				}
+			}
+		}
+	}
+	l.types[key] = t
+	return t
+}
+
+// parseSource parses a source by its extension.
+func parseSource(rel, src string) *file {
+	if isKotlin(rel) {
+		return parseKotlin(src)
+	}
+	return parseJava(src)
+}
+
+func isKotlin(rel string) bool {
+	return path.Ext(rel) == ".kt"
+}
+
+// isSource reports whether a file is a Java or Kotlin source. Kotlin scripts
+// (.kts), Gradle's build scripts among them, are not: build files only
+// locate projects and their source roots.
+func isSource(rel string) bool {
+	return isKotlin(rel) || path.Ext(rel) == ".java"
+}
+
+// packageName returns the package a file's path puts it in, its directory
+// under its source root, and the source root and project it is in. Files
+// outside source roots are in the default package, "".
+func (l *layout) packageName(rel string) (string, *sourceRoot, *project) {
+	proj := l.project(path.Dir(rel))
+	if proj == nil {
+		return "", nil, nil
+	}
+	r := proj.root(rel)
+	if r == nil {
+		return "", nil, proj
+	}
+	dir := strings.TrimPrefix(path.Dir(rel), r.Dir)
+	return strings.ReplaceAll(strings.TrimPrefix(dir, "/"), "/", "."), r, proj
+}
+
+// project returns the nearest project at or above dir, or nil.
+func (l *layout) project(dir string) *project {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.lookup(dir)
+}
+
+func (l *layout) lookup(dir string) *project {
+	if p, seen := l.projects[dir]; seen {
+		return p
+	}
+	p := l.readMaven(dir)
+	if p == nil {
+		p = l.readGradle(dir)
+	}
+	if p == nil && dir != "." {
+		p = l.lookup(path.Dir(dir))
+	}
+	l.projects[dir] = p
+	return p
+}
+
+// pom is the part of a pom.xml the layout reads.
+type pom struct {
+	GroupID     string `xml:"groupId"`
+	ArtifactID  string `xml:"artifactId"`
+	Version     string `xml:"version"`
+	Description string `xml:"description"`
+	Parent      struct {
+		GroupID string `xml:"groupId"`
+		Version string `xml:"version"`
+	} `xml:"parent"`
+	Build struct {
+		SourceDirectory     string `xml:"sourceDirectory"`
+		TestSourceDirectory string `xml:"testSourceDirectory"`
+	} `xml:"build"`
+}
+
+// readMaven parses dir/pom.xml. A missing or malformed file is no project.
+func (l *layout) readMaven(dir string) *project {
+	b, err := os.ReadFile(filepath.Join(l.root, filepath.FromSlash(dir), "pom.xml"))
+	if err != nil {
+		return nil
+	}
+	var m pom
+	if err := xml.Unmarshal(b, &m); err != nil {
+		return nil
+	}
+	p := &project{
+		dir: dir, build: "maven", group: firstOf(m.GroupID, m.Parent.GroupID), artifact: m.ArtifactID,
+		version: firstOf(m.Version, m.Parent.Version), description: strings.TrimSpace(m.Description),
+	}
+	if p.artifact == "" {
+		p.artifact = path.Base(l.abs(dir))
+	}
+	// Maven compiles one main and one test root; the Kotlin plugin and the
+	// build helper add the conventional Kotlin ones.
+	for _, r := range []sourceRoot{
+		{Dir: firstOf(m.Build.SourceDirectory, "src/main/java")},
+		{Dir: firstOf(m.Build.TestSourceDirectory, "src/test/java"), Test: true},
+		{Dir: "src/main/kotlin"},
+		{Dir: "src/test/kotlin", Test: true},
+	} {
+		l.addRoot(p, r)
+	}
+	return p
+}
+
+// gradleSrcDirs matches the directories a build script adds to a source
+// set: srcDir("gen"), srcDirs = ['a', 'b'], srcDirs += listOf("a").
+var (
+	gradleSrcDirs = regexp.MustCompile(`\bsrcDirs?\s*(?:\(|=|\+=)?\s*(?:\[|listOf\(|setOf\()?((?:\s*["'][^"'\n]+["']\s*,?)+)`)
+	gradleString  = regexp.MustCompile(`["']([^"']+)["']`)
+	gradleProp    = regexp.MustCompile(`(?m)^\s*(group|version|description)\s*=\s*["']([^"']*)["']`)
+	gradleName    = regexp.MustCompile(`rootProject\.name\s*=\s*["']([^"']+)["']`)
+)
+
+// readGradle reads dir/build.gradle or dir/build.gradle.kts. Gradle needs
+// a build to read them, so the layout takes the conventional source sets,
+// src/<set>/java and src/<set>/kotlin, and the literal srcDirs it finds;
+// those hold tests when their path says so.
+func (l *layout) readGradle(dir string) *project {
+	var script []byte
+	for _, name := range []string{"build.gradle.kts", "build.gradle"} {
+		if b, err := os.ReadFile(filepath.Join(l.root, filepath.FromSlash(dir), name)); err == nil {
+			script = b
+			break
+		}
+	}
+	if script == nil {
+		return nil
+	}
+	p := &project{dir: dir, build: "gradle", artifact: path.Base(l.abs(dir))}
+	for _, name := range []string{"settings.gradle.kts", "settings.gradle"} {
+		if b, err := os.ReadFile(filepath.Join(l.root, filepath.FromSlash(dir), name)); err == nil {
+			if m := gradleName.FindSubmatch(b); m != nil {
+				p.artifact = string(m[1])
+			}
+			break
+		}
+	}
+	for _, m := range gradleProp.FindAllSubmatch(script, -1) {
+		switch v := string(m[2]); string(m[1]) {
+		case "group":
+			p.group = v
+		case "version":
+			p.version = v
+		case "description":
+			p.description = v
+		}
+	}
+	for _, m := range gradleSrcDirs.FindAllSubmatch(script, -1) {
+		for _, s := range gradleString.FindAllSubmatch(m[1], -1) {
+			l.addRoot(p, sourceRoot{Dir: string(s[1]), Test: isTestSet(string(s[1]))})
+		}
+	}
+	entries, _ := os.ReadDir(filepath.Join(l.root, filepath.FromSlash(dir), "src"))
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		for _, lang := range []string{"java", "kotlin"} {
+			l.addRoot(p, sourceRoot{Dir: "src/" + e.Name() + "/" + lang, Test: isTestSet(e.Name())})
+		}
+	}
+	return p
+}
+
+// isTestSet reports whether a source set or directory holds tests: test,
+// testFixtures, integrationTest and the like.
+func isTestSet(name string) bool {
+	return strings.Contains(strings.ToLower(name), "test")
+}
+
+// addRoot adds a source root, relative to the project, if it exists. Roots
+// are kept longest first so that nested ones win.
+func (l *layout) addRoot(p *project, r sourceRoot) {
+	r.Dir = path.Join(p.dir, path.Clean(filepath.ToSlash(r.Dir)))
+	for _, have := range p.roots {
+		if have.Dir == r.Dir {
+			return
+		}
+	}
+	if fi, err := os.Stat(filepath.Join(l.root, filepath.FromSlash(r.Dir))); err != nil || !fi.IsDir() {
+		return
+	}
+	p.roots = append(p.roots, r)
+	sort.SliceStable(p.roots, func(i, j int) bool { return len(p.roots[i].Dir) > len(p.roots[j].Dir) })
+}
+
+// abs returns the absolute path of a directory, so that the input root
+// itself has a name.
+func (l *layout) abs(dir string) string {
+	a, err := filepath.Abs(filepath.Join(l.root, filepath.FromSlash(dir)))
+	if err != nil {
+		return dir
+	}
+	return filepath.ToSlash(a)
+}
+
+func firstOf(vs ...string) string {
+	for _, v := range vs {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,208 @@
+package java
+
+import (
+	"strings"
+
+	"github.com/ChaseHampton/cargoworker/internal/pack/outline"
+)
+
+// token is a token. Backquoted Kotlin names are identifiers without their
+// quotes; string and text block literals include their templates; doc
+// comments, /** */, keep their markers.
+type token = outline.Token
+
+// puncts are the multi-character operators, longest first. Angle brackets
+// are always single tokens so that generics nest.
+var puncts = []string{
+	"...", "!==", "===", "::", "->", "?.", "?:", "!!", "..", "==", "!=", "<=", "&&", "||", "++", "--",
+	"+=", "-=", "*=", "/=", "%=", "&=", "|=", "^=",
+}
+
+type lexer struct {
+	outline.Scanner
+	kotlin bool // block comments nest and strings have templates
+	out    []token
+}
+
+// lex splits a source into tokens, dropping comments other than doc
+// comments.
+func lex(src string, kotlin bool) []token {
+	l := &lexer{Scanner: outline.NewScanner(src), kotlin: kotlin}
+	if strings.HasPrefix(src, "\ufeff") {
+		l.I = 3
+	}
+	if strings.HasPrefix(src[l.I:], "#!") {
+		l.SkipTo(strings.IndexByte(src, '\n') + 1)
+	}
+	l.run()
+	return l.out
+}
+
+func (l *lexer) run() {
+	for l.I < len(l.Src) {
+		c := l.Src[l.I]
+		switch {
+		case c == '\n':
+			l.SkipTo(l.I + 1)
+			continue
+		case c == ' ' || c == '\t' || c == '\r' || c == '\f':
+			l.I++
+			continue
+		case c == '/' && l.Peek(1) == '/':
+			l.SkipLine()
+			continue
+		case c == '/' && l.Peek(1) == '*':
+			t := token{Pos: l.I, Line: l.Line, Col: l.I - l.LineStart}
+			l.SkipTo(l.block(l.I))
+			t.Text = l.Src[t.Pos:l.I]
+			if strings.HasPrefix(t.Text, "/**") && !strings.HasPrefix(t.Text, "/**/") && !strings.HasPrefix(t.Text, "/***") {
+				t.Kind, t.EndLine, t.EndCol = outline.Doc, l.Line, l.I-l.LineStart
+				l.out = append(l.out, t)
+			}
+			continue
+		}
+		t := token{Line: l.Line, Col: l.I - l.LineStart, Pos: l.I}
+		start := l.I
+		switch {
+		case c == '"':
+			l.SkipTo(l.string(l.I))
+			t.Kind = outline.String
+		case c == '\'':
+			l.SkipTo(l.char(l.I))
+			t.Kind = outline.Char
+		case c == '`' && l.kotlin:
+			end := strings.IndexByte(l.Src[l.I+1:], '`')
+			if end < 0 {
+				end = len(l.Src) - l.I - 2
+			}
+			l.SkipTo(l.I + end + 2)
+			t.Kind = outline.Ident
+		case isIdentStart(c):
+			for l.I < len(l.Src) && isIdent(l.Src[l.I]) {
+				l.I++
+			}
+			t.Kind = outline.Ident
+		case c >= '0' && c <= '9' || c == '.' && l.Peek(1) >= '0' && l.Peek(1) <= '9':
+			l.I++
+			for l.I < len(l.Src) {
+				d := l.Src[l.I]
+				if isIdent(d) || d == '.' && l.Peek(1) >= '0' && l.Peek(1) <= '9' ||
+					(d == '+' || d == '-') && (l.Src[l.I-1] == 'e' || l.Src[l.I-1] == 'E' || l.Src[l.I-1] == 'p' || l.Src[l.I-1] == 'P') {
+					l.I++
+					continue
+				}
+				break
+			}
+			t.Kind = outline.Number
+		default:
+			t.Kind = outline.Punct
+			l.SkipPunct(puncts)
+		}
+		t.Text = l.Src[start:l.I]
+		if t.Kind == outline.Ident && strings.HasPrefix(t.Text, "`") {
+			t.Text = strings.Trim(t.Text, "`")
+		}
+		t.EndLine, t.EndCol = l.Line, l.I-l.LineStart
+		l.out = append(l.out, t)
+	}
+}
+
+// block returns the offset after the block comment at i. Kotlin's block
+// comments nest; Java's end at the first */.
+func (l *lexer) block(i int) int {
+	depth := 0
+	for i < len(l.Src) {
+		switch {
+		case strings.HasPrefix(l.Src[i:], "/*") && (l.kotlin || depth == 0):
+			depth++
+			i += 2
+		case strings.HasPrefix(l.Src[i:], "*/"):
+			depth--
+			i += 2
+			if depth == 0 {
+				return i
+			}
+		default:
+			i++
+		}
+	}
+	return len(l.Src)
+}
+
+// string returns the offset after the string literal or text block at i.
+// Kotlin templates, ${...}, may hold strings of their own.
+func (l *lexer) string(i int) int {
+	raw := strings.HasPrefix(l.Src[i:], `"""`)
+	if raw {
+		i += 3
+	} else {
+		i++
+	}
+	for i < len(l.Src) {
+		switch c := l.Src[i]; {
+		case raw && strings.HasPrefix(l.Src[i:], `"""`):
+			i += 3
+			for i < len(l.Src) && l.Src[i] == '"' {
+				i++ // """"quoted"""" ends with the last quotes
+			}
+			return i
+		case !raw && c == '"':
+			return i + 1
+		case !raw && c == '\n':
+			return i // unterminated
+		case c == '\\' && (!raw || !l.kotlin):
+			i += 2
+		case c == '$' && l.kotlin && i+1 < len(l.Src) && l.Src[i+1] == '{':
+			i = l.template(i + 2)
+		default:
+			i++
+		}
+	}
+	return len(l.Src)
+}
+
+// template returns the offset after the closing brace of a string template
+// whose expression starts at i.
+func (l *lexer) template(i int) int {
+	depth := 1
+	for i < len(l.Src) {
+		switch l.Src[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		case '"':
+			i = l.string(i)
+			continue
+		case '\'':
+			i = l.char(i)
+			continue
+		}
+		i++
+	}
+	return len(l.Src)
+}
+
+// char returns the offset after the character literal at i.
+func (l *lexer) char(i int) int {
+	for j := i + 1; j < len(l.Src); j++ {
+		switch l.Src[j] {
+		case '\\':
+			j++
+		case '\'':
+			return j + 1
+		case '\n':
+			return j
+		}
+	}
+	return len(l.Src)
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c == '$' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+func isIdent(c byte) bool { return isIdentStart(c) || c >= '0' && c <= '9' }
@@ -0,0 +1,514 @@
+// Package java is the language pack for Java and Kotlin. It reads sources
+// without a compiler: token-level outline parsers find the classes,
+// interfaces, enums, records, annotation types and objects of a file, with
+// their constructors, methods, fields and properties, and the top-level
+// functions, properties and type aliases of Kotlin.
+//
+// Each package is a container, nested in the Maven or Gradle module whose
+// source roots it is found under: src/main/java, src/test/kotlin and the
+// other conventional roots, and those a pom.xml or build script names.
+// extends and implements clauses, and Kotlin's supertype lists, become
+// relations. Annotations and modifiers are kept in the extra_json of a
+// symbol, with the @param, @return and @throws tags of its Javadoc or KDoc;
+// declared and documented exceptions fill the throws of its signature.
+package java
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+
+	"github.com/ChaseHampton/cargoworker/internal/ir"
+	"github.com/ChaseHampton/cargoworker/internal/pack"
+	"github.com/ChaseHampton/cargoworker/internal/pack/outline"
+)
+
+func init() {
+	p := New()
+	pack.Register("java", p)
+	pack.Register("kotlin", p)
+}
+
+// defaultPackage names the container of files without a package
+// declaration.
+const defaultPackage = "(default)"
+
+// Pack extracts Java and Kotlin. Main and test sources of a package share
+// its container, and types extend and implement types of other packages, so
+// containers and symbol ids are kept in tables across units.
+type Pack struct {
+	mu      sync.Mutex
+	layouts map[string]*layout // by input root
+	tables  *outline.Tables
+}
+
+func New() *Pack {
+	return &Pack{layouts: map[string]*layout{}, tables: outline.NewTables()}
+}
+
+func (p *Pack) Name() string { return "java" }
+
+var _ pack.Pack = (*Pack)(nil)
+
+// source is a parsed file of a unit.
+type source struct {
+	rel    string
+	src    []byte
+	f      *file
+	kotlin bool
+}
+
+// Extract outlines the files of a unit. The files are parsed first, so
+// that each sees the types the others declare in its package.
+func (p *Pack) Extract(ctx context.Context, u pack.Unit) (*ir.Fragment, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	l, ok := p.layouts[u.Root]
+	if !ok {
+		l = newLayout(u.Root)
+		p.layouts[u.Root] = l
+	}
+	x := &extraction{l: l, u: u, frag: p.tables.Fragment(u), types: map[string]map[string]string{}}
+	var srcs []*source
+	for _, rel := range u.Files {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if !isSource(rel) {
+			continue
+		}
+		s := &source{rel: rel, kotlin: isKotlin(rel)}
+		b, err := os.ReadFile(filepath.Join(u.Root, filepath.FromSlash(rel)))
+		if err != nil {
+			return nil, err
+		}
+		s.src, s.f = b, l.parse(rel)
+		if s.f == nil {
+			s.f = parseSource(rel, string(b))
+		}
+		for _, d := range s.f.decls {
+			if isType(d.kind) || d.kind == "typealias" {
+				x.declare(s.f.pkg, d.name)
+			}
+		}
+		srcs = append(srcs, s)
+	}
+	for _, s := range srcs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		x.file(s)
+	}
+	return x.frag.Fragment, nil
+}
+
+// extraction is the state of one Extract call.
+type extraction struct {
+	l     *layout
+	u     pack.Unit
+	frag  *outline.Fragment            // containers of the fragment, by key
+	types map[string]map[string]string // top-level types of the unit: package -> name -> full name
+}
+
+func (x *extraction) declare(pkg, name string) {
+	if x.types[pkg] == nil {
+		x.types[pkg] = map[string]string{}
+	}
+	x.types[pkg][name] = qualify(pkg, name)
+}
+
+func qualify(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
+
+// projectExtra is the extra_json of a module container.
+type projectExtra struct {
+	Build       string       `json:"build"` // maven or gradle
+	Dir         string       `json:"dir"`
+	Group       string       `json:"group,omitempty"`
+	Artifact    string       `json:"artifact"`
+	SourceRoots []sourceRoot `json:"source_roots,omitempty"`
+}
+
+// projectContainer returns the container of a Maven or Gradle module,
+// making it.
+func (x *extraction) projectContainer(proj *project) uuid.UUID {
+	return x.frag.Container("module:"+proj.dir, func() ir.Container {
+		c := ir.Container{Name: proj.artifact, FullName: proj.name(), Kind: "module", VersionTag: proj.version, DocRaw: proj.description}
+		b, _ := json.Marshal(projectExtra{Build: proj.build, Dir: proj.dir, Group: proj.group, Artifact: proj.artifact, SourceRoots: proj.roots})
+		c.ExtraJson = string(b)
+		return c
+	})
+}
+
+// packageContainer returns the container of a package of a project, or of
+// the input root when proj is nil, making it.
+func (x *extraction) packageContainer(proj *project, pkg string) uuid.UUID {
+	var parent uuid.UUID
+	if proj != nil {
+		parent = x.projectContainer(proj)
+	}
+	key := packageKey(proj, pkg)
+	if pkg == "" {
+		pkg = defaultPackage
+	}
+	return x.frag.Container(key, func() ir.Container {
+		return ir.Container{ParentId: parent, Name: pkg[strings.LastIndex(pkg, ".")+1:], FullName: pkg, Kind: "package"}
+	})
+}
+
+// packageKey keys the container of a package of a project. Main and test
+// sources of a package share it.
+func packageKey(proj *project, pkg string) string {
+	if proj == nil {
+		return "package::" + pkg
+	}
+	return "package:" + proj.dir + ":" + pkg
+}
+
+// packageExtra is the extra_json of a package container with a
+// package-info.java.
+type packageExtra struct {
+	Annotations []string `json:"annotations,omitempty"`
+}
+
+func (x *extraction) file(s *source) {
+	pathPkg, _, proj := x.l.packageName(s.rel)
+	cid := x.packageContainer(proj, s.f.pkg)
+	fid := x.frag.File(cid, s.rel, s.src)
+	// Java requires a file's directory to match its package; Kotlin only
+	// recommends it.
+	if !s.kotlin && proj != nil && proj.root(s.rel) != nil && pathPkg != s.f.pkg {
+		x.frag.Diagnostics = append(x.frag.Diagnostics, ir.Diagnostic{
+			Id: uuid.New(), Scope: "file", Severity: "warn", Code: "package_dir", FileId: fid, Line: s.f.pkgLine,
+			Message: fmt.Sprintf("package %q does not match directory %s", s.f.pkg, path.Dir(s.rel)),
+		})
+	}
+	if path.Base(s.rel) == "package-info.java" {
+		if c := x.frag.Made(packageKey(proj, s.f.pkg)); c != nil {
+			if s.f.pkgDoc != "" {
+				c.DocRaw, c.DocFmt = s.f.pkgDoc, parseDoc(cleanComment(s.f.pkgDoc)).Summary
+			}
+			if len(s.f.annotations) > 0 {
+				b, _ := json.Marshal(packageExtra{Annotations: s.f.annotations})
+				c.ExtraJson = string(b)
+			}
+		}
+	}
+	x.imports(cid, s)
+	e := &emitter{x: x, cid: cid, fid: fid, rel: s.rel, kotlin: s.kotlin, res: x.resolver(s)}
+	e.decls(nil, nil, s.f.pkg, s.f.decls, e.res, nil)
+}
+
+// imports records the imports of a file: a type or member, or a package
+// or type whose members a wildcard imports.
+func (x *extraction) imports(cid uuid.UUID, s *source) {
+	type details struct {
+		File     string `json:"file"`
+		Line     int    `json:"line"`
+		Static   bool   `json:"static,omitempty"`
+		Wildcard bool   `json:"wildcard,omitempty"`
+		Stdlib   bool   `json:"stdlib,omitempty"`
+	}
+	for _, im := range s.f.imports {
+		if im.path == "" {
+			continue
+		}
+		b, _ := json.Marshal(details{File: s.rel, Line: im.line, Static: im.static, Wildcard: im.wildcard, Stdlib: isStdlib(im.path)})
+		x.frag.Imports = append(x.frag.Imports, ir.Import{ContainerId: cid, Target: im.path, Alias: im.alias, DetailsJson: string(b)})
+	}
+}
+
+// resolver returns the resolver of the type names of a file: its package's
+// types are those of the unit and those under the other source roots of its
+// project. A Java file without wildcard imports can only mean a type of its
+// own package by a name it neither imports nor declares.
+func (x *extraction) resolver(s *source) *resolver {
+	r := &resolver{pkg: s.f.pkg, kotlin: s.kotlin, imports: map[string]string{}, defs: maps.Clone(x.types[s.f.pkg])}
+	if r.defs == nil {
+		r.defs = map[string]string{}
+	}
+	if _, _, proj := x.l.packageName(s.rel); proj != nil {
+		for name, full := range x.l.packageTypes(proj, s.f.pkg) {
+			if _, ok := r.defs[name]; !ok {
+				r.defs[name] = full
+			}
+		}
+	}
+	wildcard := false
+	for _, im := range s.f.imports {
+		switch {
+		case im.wildcard:
+			wildcard = true
+		case im.alias != "":
+			r.imports[im.alias] = im.path
+		case !im.static || s.kotlin:
+			r.imports[im.path[strings.LastIndex(im.path, ".")+1:]] = im.path
+		}
+	}
+	r.local = !s.kotlin && !wildcard
+	return r
+}
+
+// emitter turns the declarations of a file into symbols.
+type emitter struct {
+	x      *extraction
+	cid    uuid.UUID
+	fid    uuid.UUID
+	rel    string
+	kotlin bool
+	res    *resolver
+}
+
+// extra is the extra_json of a symbol.
+type extra struct {
+	Modifiers   []string `json:"modifiers,omitempty"`
+	Annotations []string `json:"annotations,omitempty"`
+	Receiver    string   `json:"receiver,omitempty"` // of a Kotlin extension
+	Doc         *javadoc `json:"doc,omitempty"`
+}
+
+// isType reports whether a kind of declaration is a type, whose members
+// are its children.
+func isType(kind string) bool {
+	switch kind {
+	case "class", "interface", "enum", "record", "annotation", "object":
+		return true
+	}
+	return false
+}
+
+// decls emits declarations. owner is the type they are members of, with
+// its symbol id, and params the type parameters in scope; all are nil at
+// the top level of a file.
+func (e *emitter) decls(owner *decl, ownerID *uuid.UUID, prefix string, decls []*decl, res *resolver, params map[string]bool) {
+	for i, d := range decls {
+		if d.name == "" {
+			continue
+		}
+		full := qualify(prefix, d.name)
+		id := e.x.frag.NewSymbolID(full)
+		sym := ir.Symbol{
+			Id: id, ContainerId: e.cid, Name: d.name, FullName: full, Kind: d.kind,
+			Visibility: e.visibility(d, owner), OriginFileId: e.fid,
+			StartLine: d.line, StartCol: d.col, EndLine: d.endLine, EndCol: d.endCol, DocRaw: d.doc,
+		}
+		ex := extra{Modifiers: d.modifiers, Annotations: d.annotations, Receiver: d.receiver}
+		var doc *javadoc
+		if d.doc != "" {
+			sym.DocFmt = cleanComment(d.doc)
+			doc = parseDoc(sym.DocFmt)
+			ex.Doc = doc
+		}
+		if b, _ := json.Marshal(ex); string(b) != "{}" {
+			sym.ExtraJson = string(b)
+		}
+		e.x.frag.Symbols = append(e.x.frag.Symbols, sym)
+		if ownerID != nil {
+			e.x.frag.Members = append(e.x.frag.Members, ir.Member{Id: uuid.New(), OwnerSymbolId: *ownerID, ChildSymbolId: id, Order: i})
+		}
+		scope := maps.Clone(params)
+		if scope == nil {
+			scope = map[string]bool{}
+		}
+		for _, tp := range d.typeParams {
+			scope[tp.Name] = true
+		}
+		inner := res
+		if isType(d.kind) {
+			nested := map[string]string{}
+			for _, c := range d.children {
+				if isType(c.kind) {
+					nested[c.name] = full + "." + c.name
+				}
+			}
+			inner = res.scope(nested)
+			e.relations(id, d, inner)
+		}
+		e.signature(id, ownerID, d, doc, inner, scope)
+		if len(d.children) > 0 {
+			e.decls(d, &id, full, d.children, inner, scope)
+		}
+	}
+}
+
+// visibility maps modifiers onto a symbol's visibility. Java's default is
+// package-private, internal here, except for the members of interfaces and
+// annotation types, which are public, and enum constructors, which are
+// private; Kotlin's default is public.
+func (e *emitter) visibility(d *decl, owner *decl) string {
+	for _, v := range []string{"private", "protected", "internal", "public"} {
+		if d.has(v) {
+			return v
+		}
+	}
+	switch {
+	case e.kotlin, d.kind == "enum_member":
+		return "public"
+	case owner == nil:
+		return "internal"
+	case owner.kind == "interface" || owner.kind == "annotation":
+		return "public"
+	case owner.kind == "enum" && d.kind == "constructor":
+		return "private"
+	case owner.kind == "record" && d.kind == "property":
+		return "public" // its accessor is
+	}
+	return "internal"
+}
+
+// relations records what a type extends and implements. Supertypes that
+// do not resolve, or are in the standard libraries, are left to the
+// typerefs.
+func (e *emitter) relations(id uuid.UUID, d *decl, res *resolver) {
+	add := func(kind string, types []string) {
+		for _, t := range types {
+			r, ok := res.resolve(erasure(t))
+			if !ok || r.Symbol == "" || isStdlib(r.Symbol) {
+				continue
+			}
+			b, _ := json.Marshal(map[string]string{"file": e.rel, "type": t})
+			e.x.frag.Relations = append(e.x.frag.Relations, ir.Relation{
+				SourceSymbolId: id, Relation: kind, DstSymbolId: e.x.frag.SymbolID(r.Symbol), DetailsJson: string(b),
+			})
+		}
+	}
+	add("extends", d.extends)
+	add("implements", d.implements)
+}
+
+// thrown is an exception a callable throws: declared in a throws clause
+// or a @Throws annotation, or documented with @throws.
+type thrown struct {
+	Type string `json:"type"`
+	Desc string `json:"desc,omitempty"`
+}
+
+// throws returns the exceptions a declaration throws, declared ones first.
+// A documented exception matches a declared one by its simple name.
+func throws(d *decl, doc *javadoc) []thrown {
+	var out []thrown
+	add := func(typ, desc string) {
+		simple := erasure(typ)
+		simple = simple[strings.LastIndex(simple, ".")+1:]
+		for i := range out {
+			if o := erasure(out[i].Type); o[strings.LastIndex(o, ".")+1:] == simple {
+				if out[i].Desc == "" {
+					out[i].Desc = desc
+				}
+				return
+			}
+		}
+		out = append(out, thrown{Type: typ, Desc: desc})
+	}
+	for _, t := range d.throws {
+		add(t, "")
+	}
+	for _, a := range d.annotations {
+		args, ok := strings.CutPrefix(a, "Throws(")
+		if !ok {
+			args, ok = strings.CutPrefix(a, "kotlin.jvm.Throws(")
+		}
+		if !ok {
+			continue
+		}
+		for _, t := range strings.Split(strings.TrimSuffix(args, ")"), ",") {
+			if t = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(t), "::class")); t != "" {
+				add(t, "")
+			}
+		}
+	}
+	if doc != nil {
+		for _, t := range doc.Throws {
+			if t.Type != "" {
+				add(t.Type, t.Desc)
+			}
+		}
+	}
+	return out
+}
+
+// signature records the signature and type references of a declaration.
+// params holds the type parameters in scope.
+func (e *emitter) signature(id uuid.UUID, owner *uuid.UUID, d *decl, doc *javadoc, res *resolver, params map[string]bool) {
+	if d.sig == "" {
+		return
+	}
+	sig := ir.Signature{SymbolId: id, Text: d.sig}
+	type result struct {
+		Type string `json:"type"`
+	}
+	js := struct {
+		Receiver   string      `json:"receiver,omitempty"`
+		Params     []param     `json:"params,omitempty"`
+		Results    []result    `json:"results,omitempty"`
+		TypeParams []typeParam `json:"type_params,omitempty"`
+		Throws     []thrown    `json:"throws,omitempty"`
+	}{Receiver: d.receiver, TypeParams: d.typeParams}
+	switch d.kind {
+	case "method", "function", "constructor":
+		js.Params = d.params
+		if js.Params == nil {
+			js.Params = []param{}
+		}
+		if d.returns != "" && d.returns != "void" {
+			js.Results = []result{{d.returns}}
+		}
+		js.Throws = throws(d, doc)
+		e.typerefs(res, id, "receiver", d.receiver, "", params)
+		for i, pr := range d.params {
+			e.typerefs(res, id, fmt.Sprintf("param:%d", i), pr.Type, "", params)
+		}
+		e.typerefs(res, id, "result:0", d.returns, "", params)
+		for i, t := range js.Throws {
+			e.typerefs(res, id, fmt.Sprintf("throws:%d", i), t.Type, "class", params)
+		}
+	case "class", "interface", "enum", "record", "annotation", "object":
+		js.Params = d.params // of records
+		kind := "class"
+		if d.kind == "interface" {
+			kind = "interface"
+		}
+		for i, b := range d.extends {
+			e.typerefs(res, id, fmt.Sprintf("base:%d", i), b, kind, params)
+		}
+		for i, b := range d.implements {
+			e.typerefs(res, id, fmt.Sprintf("implements:%d", i), b, "interface", params)
+		}
+	case "field", "property":
+		if owner != nil {
+			e.typerefs(res, *owner, "field:"+d.name, d.typ, "", params)
+		} else {
+			e.typerefs(res, id, "type", d.typ, "", params)
+		}
+		e.typerefs(res, id, "receiver", d.receiver, "", params)
+	default:
+		e.typerefs(res, id, "type", d.typ, "", params)
+	}
+	if b, _ := json.Marshal(js); string(b) != "{}" {
+		sig.Json = string(b)
+	}
+	e.x.frag.Signatures = append(e.x.frag.Signatures, sig)
+}
+
+func (e *emitter) typerefs(res *resolver, owner uuid.UUID, slot, typ, kind string, params map[string]bool) {
+	if typ == "" {
+		return
+	}
+	for i, r := range res.refs(typ, params) {
+		r.Type = kind
+		b, _ := json.Marshal(r)
+		e.x.frag.Typerefs = append(e.x.frag.Typerefs, ir.Typeref{Id: uuid.New(), OwnerSymbolId: owner, Slot: slot, Json: string(b), Order: i})
+	}
+}
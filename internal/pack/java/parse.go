@@ -0,0 +1,230 @@
+package java
+
+import (
+	"strings"
+
+	"github.com/ChaseHampton/cargoworker/internal/pack/outline"
+)
+
+// file is the outline of one source file.
+type file struct {
+	pkg         string
+	pkgLine     int
+	pkgDoc      string   // the doc comment on the package declaration, as in package-info.java
+	annotations []string // of the package declaration
+	imports     []importDecl
+	decls       []*decl
+}
+
+// importDecl is one import: a type, a package with a wildcard, or with
+// static a member.
+type importDecl struct {
+	path     string // dotted, without the wildcard
+	alias    string // Kotlin's import ... as alias
+	static   bool
+	wildcard bool
+	line     int
+}
+
+// decl is a declaration: a type, or a member of one.
+type decl struct {
+	kind        string // class, interface, enum, record, annotation, object; constructor, method, function, field, property, enum_member, typealias
+	name        string
+	line, col   int // of the name: 1-based line and byte column
+	endLine     int
+	endCol      int // 1-based, after the last character
+	modifiers   []string
+	annotations []string // without the @
+	doc         string   // the raw doc comment
+	typeParams  []typeParam
+	params      []param
+	returns     string
+	typ         string // of fields and properties; the aliased type
+	receiver    string // of Kotlin extensions
+	extends     []string
+	implements  []string
+	throws      []string
+	sig         string
+	children    []*decl
+}
+
+type param struct {
+	Name     string `json:"name"`
+	Type     string `json:"type,omitempty"`
+	Default  string `json:"default,omitempty"`
+	Variadic bool   `json:"variadic,omitempty"`
+}
+
+type typeParam struct {
+	Name       string `json:"name"`
+	Constraint string `json:"constraint,omitempty"`
+	Variance   string `json:"variance,omitempty"` // Kotlin's in and out
+}
+
+func (d *decl) has(modifier string) bool {
+	for _, m := range d.modifiers {
+		if m == modifier {
+			return true
+		}
+	}
+	return false
+}
+
+type parser struct {
+	outline.Cursor
+	kotlin bool
+}
+
+// end sets the end of d to the last consumed token.
+func (p *parser) end(d *decl) {
+	t := p.Last()
+	d.endLine, d.endCol = t.EndLine, t.EndCol+1
+}
+
+// at sets the position of d to the token at i.
+func (p *parser) at(d *decl, i int) {
+	if i < len(p.Toks) {
+		d.line, d.col = p.Toks[i].Line, p.Toks[i].Col+1
+	}
+}
+
+// lineEnds reports whether a Kotlin line break ends the range Until is
+// skipping before the current token.
+func (p *parser) lineEnds() bool {
+	prev, t := p.Toks[p.I-1], p.Toks[p.I]
+	return t.Line > prev.EndLine && !continues(prev, t)
+}
+
+// continues reports whether a Kotlin expression or type goes on from prev
+// to t on the next line.
+func continues(prev, t token) bool {
+	if prev.Kind == outline.Punct && !prev.Closes() {
+		switch prev.Text {
+		case ">", "?", "!!", "++", "--", ";":
+		default:
+			return true
+		}
+	}
+	if t.Kind == outline.Punct {
+		switch t.Text {
+		case ".", "?.", "?:", "&&", "||", "+", "-", "*", "/", "%", "=", "==", "!=", "===", "!==", "<", ">", "<=", "..", "::", "->", ":", ",", "|", "&":
+			return true
+		}
+	}
+	return t.Is("else") || t.Is("catch") || t.Is("finally") || t.Is("as") || t.Is("is")
+}
+
+// doc reads the doc comments before a declaration and returns the last.
+func (p *parser) doc() string {
+	doc := ""
+	for !p.EOF() && p.Peek(0).Kind == outline.Doc {
+		doc = p.Peek(0).Text
+		p.I++
+	}
+	return doc
+}
+
+// annotations reads annotations: @Name, @a.b.Name(args) and, in Kotlin,
+// @target:Name and @[A B]. Java's @interface is not one.
+func (p *parser) annotations() []string {
+	var out []string
+	for !p.EOF() && p.Peek(0).Is("@") && !p.Peek(1).Is("interface") {
+		start := p.I
+		p.I++
+		if p.Peek(0).Is("[") {
+			from, to := p.Group()
+			for _, part := range strings.Fields(p.Text(from, to)) {
+				out = append(out, part)
+			}
+			continue
+		}
+		if p.kotlin && p.Peek(0).Kind == outline.Ident && p.Peek(1).Is(":") {
+			p.I += 2 // a use-site target: @field:Inject
+		}
+		for p.Peek(0).Kind == outline.Ident {
+			p.I++
+			if !p.Peek(0).Is(".") || p.Peek(1).Kind != outline.Ident {
+				break
+			}
+			p.I++
+		}
+		if p.Peek(0).Is("(") && (!p.kotlin || p.Peek(0).Line == p.Toks[p.I-1].Line) {
+			p.Group()
+		}
+		out = append(out, p.Text(start+1, p.I))
+		if p.I == start+1 {
+			break
+		}
+	}
+	return out
+}
+
+// typeParams parses a type parameter list, if present, and returns its
+// text.
+func (p *parser) typeParams(d *decl) string {
+	if !p.Peek(0).Is("<") {
+		return ""
+	}
+	p.I++
+	from, to := p.untilAngle()
+	p.Accept(">")
+	for _, part := range p.Split(from, to) {
+		i, end := part[0], part[1]
+		for i < end && p.Toks[i].Is("@") {
+			save := p.I
+			p.I = i
+			p.annotations()
+			i, p.I = p.I, save
+		}
+		var tp typeParam
+		for i < end && (p.Toks[i].Is("in") || p.Toks[i].Is("out") || p.Toks[i].Is("reified")) {
+			if !p.Toks[i].Is("reified") {
+				tp.Variance = p.Toks[i].Text
+			}
+			i++
+		}
+		if i >= end {
+			continue
+		}
+		tp.Name = p.Toks[i].Text
+		if i+1 < end && (p.Toks[i+1].Is("extends") || p.Toks[i+1].Is(":")) {
+			tp.Constraint = p.Text(i+2, end)
+		}
+		d.typeParams = append(d.typeParams, tp)
+	}
+	return "<" + p.Text(from, to) + ">"
+}
+
+// untilAngle skips to the > closing a type parameter or argument list.
+func (p *parser) untilAngle() (from, to int) {
+	from = p.I
+	depth := 0
+	for !p.EOF() {
+		t := p.Toks[p.I]
+		switch {
+		case t.Is(">") && depth == 0:
+			return from, p.I
+		case t.Is("<"):
+			depth++
+		case t.Is(">"):
+			depth--
+		case t.Opens():
+			p.Group()
+			continue
+		case t.Closes(), t.Is(";"), t.Is("{"):
+			return from, p.I
+		}
+		p.I++
+	}
+	return from, p.I
+}
+
+// typeList splits a comma-separated list of types in [from, to), leaving
+// out type arguments' spacing as written.
+func (p *parser) typeList(from, to int) []string {
+	var out []string
+	for _, part := range p.Split(from, to) {
+		out = append(out, p.Text(part[0], part[1]))
+	}
+	return out
+}
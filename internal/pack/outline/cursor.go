@@ -0,0 +1,195 @@
+package outline
+
+import "strings"
+
+// Cursor walks the tokens of a source. Outline parsers embed it: they read
+// declarations token by token and skip what they do not outline, bodies and
+// initializers, a bracketed group or a range at a time.
+type Cursor struct {
+	Src  string
+	Toks []Token
+	I    int // the current token
+	// Ends, if set, reports whether the range Until is skipping ends before
+	// the current token, as at a line break in languages where one may end
+	// a statement. Until asks it outside brackets, after the first token.
+	Ends func() bool
+	// Angle, if set, reports whether the < at i opens a type argument list
+	// for Split and Find, where by default every < does.
+	Angle func(i int) bool
+}
+
+var eofToken = Token{Kind: Punct}
+
+// Peek returns the token n past the current one, or an empty punctuator
+// past the end.
+func (c *Cursor) Peek(n int) Token {
+	if c.I+n < len(c.Toks) {
+		return c.Toks[c.I+n]
+	}
+	return eofToken
+}
+
+func (c *Cursor) EOF() bool { return c.I >= len(c.Toks) }
+
+// Accept consumes the current token if it is text.
+func (c *Cursor) Accept(text string) bool {
+	if !c.EOF() && c.Toks[c.I].Is(text) {
+		c.I++
+		return true
+	}
+	return false
+}
+
+// Last returns the last consumed token.
+func (c *Cursor) Last() Token {
+	if c.I == 0 {
+		return eofToken
+	}
+	return c.Toks[min(c.I, len(c.Toks))-1]
+}
+
+// Text returns the source of tokens [from, to) with runs of whitespace
+// collapsed.
+func (c *Cursor) Text(from, to int) string {
+	to = min(to, len(c.Toks))
+	if from >= to {
+		return ""
+	}
+	last := c.Toks[to-1]
+	return OneLine(c.Src[c.Toks[from].Pos : last.Pos+len(last.Text)])
+}
+
+// Name returns tokens [from, to) with no spaces and no doc comments: a
+// qualified name.
+func (c *Cursor) Name(from, to int) string {
+	var b strings.Builder
+	for i := from; i < min(to, len(c.Toks)); i++ {
+		if c.Toks[i].Kind != Doc {
+			b.WriteString(c.Toks[i].Text)
+		}
+	}
+	return b.String()
+}
+
+// Skip returns the index after the bracketed group opening at i, or
+// len(Toks)+1 if the group does not close.
+func (c *Cursor) Skip(i int) int {
+	depth := 0
+	for ; i < len(c.Toks); i++ {
+		t := c.Toks[i]
+		switch {
+		case t.Opens():
+			depth++
+		case t.Closes():
+			if depth--; depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return len(c.Toks) + 1
+}
+
+// Group skips the bracketed group opening at the current token and returns
+// the token range inside it.
+func (c *Cursor) Group() (from, to int) {
+	from = c.I + 1
+	end := c.Skip(c.I)
+	if end > len(c.Toks) {
+		c.I = len(c.Toks) // unclosed: the group runs to the end
+		return from, c.I
+	}
+	c.I = end
+	return from, end - 1
+}
+
+// Until skips tokens up to one of stops outside brackets, an unbalanced
+// closer, or where Ends says the range ends, and returns the range
+// skipped. Angle brackets nest when types is set.
+func (c *Cursor) Until(types bool, stops ...string) (from, to int) {
+	from = c.I
+	angles := 0
+	for !c.EOF() {
+		t := c.Toks[c.I]
+		if angles == 0 {
+			for _, s := range stops {
+				if t.Is(s) {
+					return from, c.I
+				}
+			}
+			if c.I > from && c.Ends != nil && c.Ends() {
+				return from, c.I
+			}
+		}
+		switch {
+		case t.Opens():
+			c.Group()
+			continue
+		case t.Closes():
+			return from, c.I
+		case types && t.Is("<"):
+			angles++
+		case types && t.Is(">") && angles > 0:
+			angles--
+		case t.Is(";") && angles > 0:
+			return from, c.I // unbalanced
+		}
+		c.I++
+	}
+	return from, c.I
+}
+
+// opensAngle reports whether the < at i, in a range starting at from,
+// opens a type argument list.
+func (c *Cursor) opensAngle(from, i int) bool {
+	return c.Toks[i].Is("<") && (c.Angle == nil || i > from && c.Angle(i))
+}
+
+// Split splits the token range [from, to) at commas outside brackets.
+func (c *Cursor) Split(from, to int) [][2]int {
+	var out [][2]int
+	depth, angles, start := 0, 0, from
+	for i := from; i < to; i++ {
+		t := c.Toks[i]
+		switch {
+		case t.Opens():
+			depth++
+		case t.Closes():
+			depth--
+		case c.opensAngle(from, i):
+			angles++
+		case t.Is(">") && angles > 0:
+			angles--
+		case t.Is(",") && depth == 0 && angles == 0:
+			if i > start {
+				out = append(out, [2]int{start, i})
+			}
+			start = i + 1
+		}
+	}
+	if to > start {
+		out = append(out, [2]int{start, to})
+	}
+	return out
+}
+
+// Find returns the index of the first token text in [from, to) outside
+// brackets, or to.
+func (c *Cursor) Find(from, to int, text string) int {
+	depth, angles := 0, 0
+	for i := from; i < to; i++ {
+		t := c.Toks[i]
+		switch {
+		case depth == 0 && angles == 0 && t.Is(text):
+			return i
+		case t.Opens():
+			depth++
+		case t.Closes():
+			depth--
+		case c.opensAngle(from, i):
+			angles++
+		case t.Is(">") && angles > 0:
+			angles--
+		}
+	}
+	return to
+}
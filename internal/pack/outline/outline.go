@@ -0,0 +1,106 @@
+// Package outline holds what the hand-written language packs share: the
+// tables that keep containers and symbol ids the same across the units of a
+// run, and the tokens and cursor their outline parsers are written over.
+// The rules of each language stay in its pack.
+package outline
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/google/uuid"
+
+	"github.com/ChaseHampton/cargoworker/internal/ir"
+	"github.com/ChaseHampton/cargoworker/internal/pack"
+)
+
+// Tables remember, by input root, the containers a pack has made and the
+// ids of the symbols other units refer to. Units arrive a directory at a
+// time, so a package or namespace spread over several directories must come
+// out as one container, and a symbol named in one unit and declared in
+// another as one id. Tables are not safe for concurrent use; packs extract
+// under their own lock.
+type Tables struct {
+	containers map[[2]string]uuid.UUID // by input root and key
+	symbols    map[[2]string]uuid.UUID // by input root and full name
+	emitted    map[uuid.UUID]bool
+}
+
+func NewTables() *Tables {
+	return &Tables{containers: map[[2]string]uuid.UUID{}, symbols: map[[2]string]uuid.UUID{}, emitted: map[uuid.UUID]bool{}}
+}
+
+// Fragment is the fragment of one unit under construction.
+type Fragment struct {
+	*ir.Fragment
+	t     *Tables
+	u     pack.Unit
+	index map[string]int // containers of the fragment, by key
+}
+
+// Fragment starts the fragment of a unit.
+func (t *Tables) Fragment(u pack.Unit) *Fragment {
+	return &Fragment{Fragment: &ir.Fragment{}, t: t, u: u, index: map[string]int{}}
+}
+
+// Container returns the container with a key, making it with mk if no unit
+// has. The id and language are set here.
+func (f *Fragment) Container(key string, mk func() ir.Container) uuid.UUID {
+	if i, ok := f.index[key]; ok {
+		return f.Containers[i].Id
+	}
+	k := [2]string{f.u.Root, key}
+	if id, ok := f.t.containers[k]; ok {
+		return id
+	}
+	c := mk()
+	c.Id, c.Language = uuid.New(), f.u.Language
+	f.t.containers[k] = c.Id
+	f.index[key] = len(f.Containers)
+	f.Containers = append(f.Containers, c)
+	return c.Id
+}
+
+// SymbolID returns the id of the symbol named full, which may be emitted
+// in a later unit.
+func (f *Fragment) SymbolID(full string) uuid.UUID {
+	key := [2]string{f.u.Root, full}
+	id, ok := f.t.symbols[key]
+	if !ok {
+		id = uuid.New()
+		f.t.symbols[key] = id
+	}
+	return id
+}
+
+// NewSymbolID returns the id to emit the symbol named full with: the one
+// references to it use, unless a symbol of that name was emitted already,
+// as an overload or under another build condition.
+func (f *Fragment) NewSymbolID(full string) uuid.UUID {
+	id := f.SymbolID(full)
+	if f.t.emitted[id] {
+		return uuid.New()
+	}
+	f.t.emitted[id] = true
+	return id
+}
+
+// File records a file of the unit in container cid and returns its id.
+func (f *Fragment) File(cid uuid.UUID, rel string, src []byte) uuid.UUID {
+	sum := sha256.Sum256(src)
+	fid := uuid.New()
+	f.Files = append(f.Files, ir.File{
+		Id: fid, ContainerId: cid, Path: rel, Checksum: hex.EncodeToString(sum[:]),
+		Language: f.u.Language, SizeBytes: int64(len(src)),
+	})
+	return fid
+}
+
+// Made returns the container with a key if this unit made it, for details
+// learned after making it; otherwise nil.
+func (f *Fragment) Made(key string) *ir.Container {
+	if i, ok := f.index[key]; ok {
+		return &f.Containers[i]
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package outline
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ChaseHampton/cargoworker/internal/ir"
+	"github.com/ChaseHampton/cargoworker/internal/pack"
+)
+
+// cursor returns a Cursor over the space-separated words of src, each an
+// identifier, a number or a punctuator.
+func cursor(src string) *Cursor {
+	c := &Cursor{Src: src}
+	line, start := 1, 0
+	for i := 0; i < len(src); {
+		switch src[i] {
+		case '\n':
+			line, start = line+1, i+1
+			fallthrough
+		case ' ':
+			i++
+			continue
+		}
+		j := i
+		for j < len(src) && src[j] != ' ' && src[j] != '\n' {
+			j++
+		}
+		t := Token{Kind: Punct, Text: src[i:j], Pos: i, Line: line, Col: i - start, EndLine: line, EndCol: j - start}
+		if c := src[i]; c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' {
+			t.Kind = Ident
+		} else if c >= '0' && c <= '9' {
+			t.Kind = Number
+		}
+		c.Toks = append(c.Toks, t)
+		i = j
+	}
+	return c
+}
+
+func TestCursor(t *testing.T) {
+	c := cursor("f ( a , b ) { x ; } ;")
+	c.I = 1
+	if from, to := c.Group(); from != 2 || to != 5 || c.I != 6 {
+		t.Errorf("Group = %d, %d at %d, want 2, 5 at 6", from, to, c.I)
+	}
+	if got := c.Split(2, 5); !reflect.DeepEqual(got, [][2]int{{2, 3}, {4, 5}}) {
+		t.Errorf("Split = %v", got)
+	}
+	from, to := c.Until(false, ";")
+	if c.Text(from, to) != "{ x ; }" {
+		t.Errorf("Until skipped %q", c.Text(from, to))
+	}
+
+	c = cursor("( a ,")
+	if from, to := c.Group(); from != 1 || to != 3 || c.I != 3 {
+		t.Errorf("unclosed Group = %d, %d at %d, want 1, 3 at 3", from, to, c.I)
+	}
+	if c.Peek(0) != eofToken || !c.EOF() {
+		t.Errorf("Peek past the end = %+v", c.Peek(0))
+	}
+
+	// A < after a number is a comparison, not a type argument list.
+	c = cursor("Map < K , V > , 1 < b , c")
+	c.Angle = func(i int) bool { return c.Toks[i-1].Kind == Ident }
+	var parts []string
+	for _, p := range c.Split(0, len(c.Toks)) {
+		parts = append(parts, c.Text(p[0], p[1]))
+	}
+	if want := []string{"Map < K , V >", "1 < b", "c"}; !reflect.DeepEqual(parts, want) {
+		t.Errorf("Split = %q, want %q", parts, want)
+	}
+
+	c = cursor("x = a +\nb\ny = 2")
+	c.Ends = func() bool {
+		prev := c.Toks[c.I-1]
+		return c.Toks[c.I].Line > prev.EndLine && prev.Kind == Ident
+	}
+	c.I = 2
+	if from, to := c.Until(false, ";"); c.Text(from, to) != "a + b" {
+		t.Errorf("Until with Ends skipped %q", c.Text(from, to))
+	}
+}
+
+func TestTables(t *testing.T) {
+	tables := NewTables()
+	mk := func() ir.Container { return ir.Container{Name: "p", Kind: "package"} }
+	a := tables.Fragment(pack.Unit{Root: "/r", Dir: "a", Language: "l"})
+	cid := a.Container("p", mk)
+	if a.Container("p", mk) != cid || len(a.Containers) != 1 || a.Containers[0].Language != "l" {
+		t.Fatalf("containers = %+v", a.Containers)
+	}
+	ref := a.SymbolID("p.T")
+	fid := a.File(cid, "a/x.l", []byte("x"))
+	if len(a.Files) != 1 || a.Files[0].Id != fid || !strings.HasPrefix(a.Files[0].Checksum, "2d711642") {
+		t.Errorf("files = %+v", a.Files)
+	}
+
+	b := tables.Fragment(pack.Unit{Root: "/r", Dir: "b", Language: "l"})
+	if b.Container("p", mk) != cid || len(b.Containers) != 0 || b.Made("p") != nil {
+		t.Errorf("the second unit made the container again: %+v", b.Containers)
+	}
+	if id := b.NewSymbolID("p.T"); id != ref {
+		t.Errorf("NewSymbolID = %v, want the referenced id %v", id, ref)
+	}
+	if id := b.NewSymbolID("p.T"); id == ref {
+		t.Error("an overload got the id of the first declaration")
+	}
+
+	other := tables.Fragment(pack.Unit{Root: "/s", Dir: "a", Language: "l"})
+	if other.Container("p", mk) == cid || other.SymbolID("p.T") == ref {
+		t.Error("another input root shares the tables of /r")
+	}
+}
@@ -0,0 +1,51 @@
+package outline
+
+import "strings"
+
+// Scanner is a lexer's position in its source. Lexers embed it and read
+// their language's tokens from Src[I:].
+type Scanner struct {
+	Src       string
+	I         int // byte offset
+	Line      int // 1-based
+	LineStart int // byte offset of the line
+}
+
+// NewScanner returns a Scanner at the start of src.
+func NewScanner(src string) Scanner { return Scanner{Src: src, Line: 1} }
+
+// Peek returns the byte n past the current one, or 0 past the end.
+func (s *Scanner) Peek(n int) byte {
+	if s.I+n < len(s.Src) {
+		return s.Src[s.I+n]
+	}
+	return 0
+}
+
+// SkipTo advances to end, counting the lines passed.
+func (s *Scanner) SkipTo(end int) {
+	for ; s.I < end && s.I < len(s.Src); s.I++ {
+		if s.Src[s.I] == '\n' {
+			s.Line, s.LineStart = s.Line+1, s.I+1
+		}
+	}
+}
+
+// SkipLine advances to the line break ending the current line.
+func (s *Scanner) SkipLine() {
+	for s.I < len(s.Src) && s.Src[s.I] != '\n' {
+		s.I++
+	}
+}
+
+// SkipPunct advances past the punctuator at the current byte: the first of
+// puncts, longest first, that starts there, or the one byte.
+func (s *Scanner) SkipPunct(puncts []string) {
+	for _, p := range puncts {
+		if strings.HasPrefix(s.Src[s.I:], p) {
+			s.I += len(p)
+			return
+		}
+	}
+	s.I++
+}
@@ -0,0 +1,60 @@
+package outline
+
+import "strings"
+
+// Kind classifies a token. Packs number the kinds only their lexers make,
+// as template literals or lifetimes, from Other on.
+type Kind int
+
+const (
+	Ident  Kind = iota // identifiers and keywords
+	String             // string literals, quotes and prefixes included
+	Char
+	Number
+	Punct
+	Doc // a doc comment
+	Other
+)
+
+// Token is a token of a source.
+type Token struct {
+	Kind    Kind
+	Text    string
+	Pos     int // byte offset
+	Line    int // 1-based
+	Col     int // 0-based byte column
+	EndLine int // 1-based line of the last byte
+	EndCol  int // 0-based byte column after the token
+	// NL marks the first token on its line, and Doc holds the doc comment
+	// right before the token, for lexers that keep comments on tokens
+	// rather than as Doc tokens.
+	NL  bool
+	Doc string
+}
+
+// Is reports whether t is the identifier, keyword or punctuator text.
+func (t Token) Is(text string) bool {
+	return (t.Kind == Ident || t.Kind == Punct) && t.Text == text
+}
+
+// Opens reports whether t opens a bracketed group: (, [ or {.
+func (t Token) Opens() bool {
+	return t.Kind == Punct && (t.Text == "(" || t.Text == "[" || t.Text == "{")
+}
+
+// Closes reports whether t closes a bracketed group.
+func (t Token) Closes() bool {
+	return t.Kind == Punct && (t.Text == ")" || t.Text == "]" || t.Text == "}")
+}
+
+// Set returns the set of the space-separated words.
+func Set(words string) map[string]bool {
+	m := map[string]bool{}
+	for _, w := range strings.Fields(words) {
+		m[w] = true
+	}
+	return m
+}
+
+// OneLine collapses the runs of whitespace in s to single spaces.
+func OneLine(s string) string { return strings.Join(strings.Fields(s), " ") }
@@ -0,0 +1,139 @@
+// Package pack runs language packs over the sources of a run. The planned
+// files are grouped into units, one per language and directory, and the pack
+// registered for the language turns each unit into an ir.Fragment for the
+// store.
+package pack
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/ChaseHampton/cargoworker/internal/ir"
+	"github.com/ChaseHampton/cargoworker/internal/store"
+)
+
+// Unit is the input of one Extract call: the files of one language in one
+// directory.
+type Unit struct {
+	Root     string   // input root directory
+	Dir      string   // directory relative to Root, slash separated; "." for Root
+	Language string   // language id, as in the language table
+	Files    []string // paths relative to Root, slash separated, sorted
+}
+
+type Pack interface {
+	// Name identifies the pack in logs.
+	Name() string
+	Extract(ctx context.Context, u Unit) (*ir.Fragment, error)
+}
+
+var (
+	mu       sync.RWMutex
+	registry = map[string]Pack{}
+)
+
+// Register makes p the pack for a language id. It panics if the language
+// already has one, as packs register from init functions.
+func Register(language string, p Pack) {
+	mu.Lock()
+	defer mu.Unlock()
+	if prev, ok := registry[language]; ok {
+		panic(fmt.Sprintf("pack: %s already registered for %s", prev.Name(), language))
+	}
+	registry[language] = p
+}
+
+// For returns the pack for a language id, or nil.
+func For(language string) Pack {
+	mu.RLock()
+	defer mu.RUnlock()
+	return registry[language]
+}
+
+// Languages returns the language ids that have a pack, sorted.
+func Languages() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	out := make([]string, 0, len(registry))
+	for l := range registry {
+		out = append(out, l)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Units groups files, relative to root, into units of the languages that
+// have a pack. language returns the language id of a file, "" for none.
+func Units(root string, files []string, language func(rel string) string) []Unit {
+	byKey := map[[2]string]*Unit{}
+	var units []*Unit
+	for _, rel := range files {
+		lang := language(rel)
+		if lang == "" || For(lang) == nil {
+			continue
+		}
+		key := [2]string{lang, path.Dir(rel)}
+		u, ok := byKey[key]
+		if !ok {
+			u = &Unit{Root: root, Dir: key[1], Language: lang}
+			byKey[key] = u
+			units = append(units, u)
+		}
+		u.Files = append(u.Files, rel)
+	}
+	out := make([]Unit, 0, len(units))
+	for _, u := range units {
+		slices.Sort(u.Files)
+		out = append(out, *u)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Dir != out[j].Dir {
+			return out[i].Dir < out[j].Dir
+		}
+		return out[i].Language < out[j].Language
+	})
+	return out
+}
+
+// Stats counts what Run extracted.
+type Stats struct {
+	Units   int
+	Files   int
+	Symbols int
+	Failed  int // units whose pack failed; they are logged and skipped
+}
+
+// Run extracts every unit with its language's pack and writes the fragments
+// to st. A unit its pack fails on is logged and skipped; a fragment the
+// store fails to write stops the run, as the store is then likely unusable.
+func Run(ctx context.Context, st store.Store, units []Unit, lg *slog.Logger) (Stats, error) {
+	var s Stats
+	for _, u := range units {
+		if err := ctx.Err(); err != nil {
+			return s, err
+		}
+		p := For(u.Language)
+		if p == nil {
+			continue
+		}
+		f, err := p.Extract(ctx, u)
+		if err != nil {
+			lg.Warn("extract failed", "pack", p.Name(), "dir", u.Dir, "err", err)
+			s.Failed++
+			continue
+		}
+		if err := st.WriteFragment(ctx, f); err != nil {
+			return s, fmt.Errorf("write %s fragment of %s: %w", u.Language, u.Dir, err)
+		}
+		lg.Debug("unit extracted", "pack", p.Name(), "dir", u.Dir, "files", len(f.Files), "symbols", len(f.Symbols))
+		s.Units++
+		s.Files += len(f.Files)
+		s.Symbols += len(f.Symbols)
+	}
+	return s, nil
+}
@@ -0,0 +1,96 @@
+package pack
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ChaseHampton/cargoworker/internal/ir"
+	"github.com/ChaseHampton/cargoworker/internal/store"
+)
+
+type nopPack struct{}
+
+func (nopPack) Name() string                                        { return "nop" }
+func (nopPack) Extract(context.Context, Unit) (*ir.Fragment, error) { return &ir.Fragment{}, nil }
+
+func TestUnits(t *testing.T) {
+	Register("test-a", nopPack{})
+	Register("test-b", nopPack{})
+	langs := map[string]string{".a": "test-a", ".b": "test-b", ".c": "test-none"}
+	files := []string{"x/2.a", "x/1.a", "x/y/3.a", "x/4.b", "5.a", "x/6.c", "README"}
+	got := Units("/r", files, func(rel string) string {
+		for ext, l := range langs {
+			if len(rel) > 2 && rel[len(rel)-2:] == ext {
+				return l
+			}
+		}
+		return ""
+	})
+	want := []Unit{
+		{Root: "/r", Dir: ".", Language: "test-a", Files: []string{"5.a"}},
+		{Root: "/r", Dir: "x", Language: "test-a", Files: []string{"x/1.a", "x/2.a"}},
+		{Root: "/r", Dir: "x", Language: "test-b", Files: []string{"x/4.b"}},
+		{Root: "/r", Dir: "x/y", Language: "test-a", Files: []string{"x/y/3.a"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Units = %+v\nwant %+v", got, want)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("duplicate Register did not panic")
+		}
+	}()
+	Register("test-a", nopPack{})
+}
+
+// failStore rejects the fragments of the directories in fail.
+type failStore struct {
+	store.Store
+	fail    map[string]bool
+	written []string
+}
+
+func (s *failStore) WriteFragment(_ context.Context, f *ir.Fragment) error {
+	dir := f.Containers[0].Name
+	if s.fail[dir] {
+		return errors.New("constraint failed")
+	}
+	s.written = append(s.written, dir)
+	return nil
+}
+
+// dirPack makes a container for the directory of a unit, and fails on
+// the directory "broken".
+type dirPack struct{}
+
+func (dirPack) Name() string { return "dir" }
+func (dirPack) Extract(_ context.Context, u Unit) (*ir.Fragment, error) {
+	if u.Dir == "broken" {
+		return nil, errors.New("parse failed")
+	}
+	return &ir.Fragment{Containers: []ir.Container{{Name: u.Dir}}}, nil
+}
+
+func TestRunFailures(t *testing.T) {
+	Register("test-dir", dirPack{})
+	var units []Unit
+	for _, dir := range []string{"a", "broken", "b", "c"} {
+		units = append(units, Unit{Dir: dir, Language: "test-dir"})
+	}
+	st := &failStore{fail: map[string]bool{"b": true}}
+	s, err := Run(context.Background(), st, units, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err == nil || !strings.Contains(err.Error(), "constraint failed") {
+		t.Errorf("Run error = %v, want the write error", err)
+	}
+	if s.Units != 1 || s.Failed != 1 {
+		t.Errorf("Run stats = %+v, want 1 unit and 1 failed", s)
+	}
+	if want := []string{"a"}; !reflect.DeepEqual(st.written, want) {
+		t.Errorf("written = %v, want %v", st.written, want)
+	}
+}
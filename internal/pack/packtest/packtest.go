@@ -0,0 +1,95 @@
+// Package packtest runs language packs in tests the way the indexer does:
+// over a source tree written to disk, a unit per language and directory, in
+// the order of pack.Units, through pack.Run.
+package packtest
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/ChaseHampton/cargoworker/internal/ir"
+	"github.com/ChaseHampton/cargoworker/internal/pack"
+	"github.com/ChaseHampton/cargoworker/internal/store"
+)
+
+// WriteTree writes the sources of tree, by slash-separated path, to a
+// temporary directory and returns it.
+func WriteTree(t testing.TB, tree map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for rel, src := range tree {
+		p := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+// ExtractTree writes tree and runs the registered packs over all of its
+// files.
+func ExtractTree(t testing.TB, tree map[string]string, language func(rel string) string) *ir.Fragment {
+	t.Helper()
+	return Extract(t, WriteTree(t, tree), slices.Sorted(maps.Keys(tree)), language)
+}
+
+// ByExt returns a language function that looks a file's extension up in
+// exts.
+func ByExt(exts map[string]string) func(rel string) string {
+	return func(rel string) string { return exts[path.Ext(rel)] }
+}
+
+// Extract runs the registered packs over files, relative to root, and
+// returns the fragments they wrote merged into one, in the order written.
+// language returns the language id of a file, as the language table would.
+// A unit that fails fails the test.
+func Extract(t testing.TB, root string, files []string, language func(rel string) string) *ir.Fragment {
+	t.Helper()
+	st := &collector{}
+	lg := slog.New(slog.NewTextHandler(logWriter{t}, nil))
+	s, err := pack.Run(context.Background(), st, pack.Units(root, files, language), lg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Failed > 0 {
+		t.Fatalf("%d units failed", s.Failed)
+	}
+	return &st.all
+}
+
+// collector is a store that keeps the fragments written to it.
+type collector struct {
+	store.Store
+	all ir.Fragment
+}
+
+func (c *collector) WriteFragment(_ context.Context, f *ir.Fragment) error {
+	c.all.Containers = append(c.all.Containers, f.Containers...)
+	c.all.Files = append(c.all.Files, f.Files...)
+	c.all.Symbols = append(c.all.Symbols, f.Symbols...)
+	c.all.Signatures = append(c.all.Signatures, f.Signatures...)
+	c.all.Typerefs = append(c.all.Typerefs, f.Typerefs...)
+	c.all.Members = append(c.all.Members, f.Members...)
+	c.all.Relations = append(c.all.Relations, f.Relations...)
+	c.all.Imports = append(c.all.Imports, f.Imports...)
+	c.all.Diagnostics = append(c.all.Diagnostics, f.Diagnostics...)
+	return nil
+}
+
+// logWriter sends Run's log to the test log.
+type logWriter struct{ t testing.TB }
+
+func (w logWriter) Write(p []byte) (int, error) {
+	w.t.Helper()
+	w.t.Log(string(p))
+	return len(p), nil
+}
@@ -0,0 +1,259 @@
+package proto
+
+import (
+	"encoding/json"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/ChaseHampton/cargoworker/internal/ir"
+)
+
+var (
+	goSource    = regexp.MustCompile(`^// source: (\S+)`)
+	goPackage   = regexp.MustCompile(`^package (\w+)`)
+	goType      = regexp.MustCompile(`^type (\w+) (struct|interface|int32)\b`)
+	goField     = regexp.MustCompile(`^\t(\w+)\s+(\S+)\s+` + "`" + `protobuf:"[^"]*\bname=(\w+)`)
+	goConst     = regexp.MustCompile(`^\t(\w+)\s+(\w+)\s*=\s*-?\d+`)
+	goMethod    = regexp.MustCompile(`^\t(\w+)\(`)
+	goEndOfType = regexp.MustCompile(`^[})]`)
+)
+
+// generated outlines a Go file protoc generated from the proto file
+// protoRel: the Go declarations of its messages, enums and services, each
+// linked to the declaration it was generated from.
+func (x *extraction) generated(rel, protoRel string, src []byte) {
+	lines := strings.Split(string(src), "\n")
+	_, pkg := goHeader(lines)
+	f := x.l.parse(protoRel)
+	if f == nil {
+		return
+	}
+	importPath := path.Dir(rel)
+	for _, o := range f.options {
+		if o.Name == "go_package" {
+			importPath, _, _ = strings.Cut(o.Value, ";")
+		}
+	}
+	names := goNames(f)
+	cid := x.frag.Container("go:"+path.Dir(rel), func() ir.Container {
+		return ir.Container{Name: pkg, FullName: importPath, Kind: "package"}
+	})
+	fid := x.frag.File(cid, rel, src)
+	g := &goEmitter{x: x, rel: rel, fid: fid, cid: cid, importPath: importPath, names: names}
+	var owner *goOwner
+	for i, line := range lines {
+		if owner != nil && goEndOfType.MatchString(line) {
+			owner = nil
+			continue
+		}
+		if m := goType.FindStringSubmatch(line); m != nil {
+			owner = g.typ(m[1], m[2], lines, i)
+			continue
+		}
+		if owner == nil {
+			if m := goConst.FindStringSubmatch(line); m != nil {
+				if from := names[m[1]]; from.kind == "enum_member" && names[m[2]].kind == "enum" {
+					g.symbol(nil, m[1], "constant", strings.Join(strings.Fields(line), " "), from, lines, i, strings.Index(line, m[1]))
+				}
+			}
+			continue
+		}
+		var name, field, text string
+		if m := goField.FindStringSubmatch(line); m != nil && owner.kind == "struct" {
+			name, field, text = m[1], m[3], m[1]+" "+m[2]
+		} else if m := goMethod.FindStringSubmatch(line); m != nil && owner.kind == "interface" {
+			name, field, text = m[1], m[1], strings.TrimSpace(line)
+		}
+		if name == "" {
+			continue
+		}
+		if from, ok := names[owner.name+"."+field]; ok {
+			kind := "field"
+			if owner.kind == "interface" {
+				kind = "method"
+			}
+			g.symbol(owner, name, kind, text, from, lines, i, strings.Index(line, name))
+		}
+	}
+}
+
+// goHeader returns the proto file a generated Go file's source comment
+// names and its package.
+func goHeader(lines []string) (source, pkg string) {
+	for _, line := range lines {
+		if m := goSource.FindStringSubmatch(line); m != nil && source == "" {
+			source = m[1]
+		}
+		if m := goPackage.FindStringSubmatch(line); m != nil {
+			return source, m[1]
+		}
+	}
+	return source, ""
+}
+
+// goSymbol is a proto declaration a Go name was generated from.
+type goSymbol struct {
+	full string // its full name
+	kind string
+}
+
+// goNames returns the Go names protoc-gen-go and protoc-gen-go-grpc give
+// the declarations of a proto file, with what they name: a message's
+// nested name joined by underscores, an enum value's prefixed by its
+// enum's, or its parent message's when nested, and a service's client and
+// server interfaces. The fields of a struct are keyed by its Go name and
+// their proto name, which their struct tags keep, and the methods of an
+// interface by its Go name and theirs.
+func goNames(f *file) map[string]goSymbol {
+	out := map[string]goSymbol{}
+	var walk func(goPrefix, scope string, decls []*decl)
+	walk = func(goPrefix, scope string, decls []*decl) {
+		for _, d := range decls {
+			full := qualify(scope, d.name)
+			goName := goCamelCase(d.name)
+			if goPrefix != "" {
+				goName = goPrefix + "_" + goName
+			}
+			switch d.kind {
+			case "message":
+				out[goName] = goSymbol{full, d.kind}
+				for _, c := range d.children {
+					if c.kind == "field" && c.extendee == "" {
+						out[goName+"."+c.name] = goSymbol{qualify(full, c.name), c.kind}
+					}
+				}
+				walk(goName, full, d.children)
+			case "enum":
+				out[goName] = goSymbol{full, d.kind}
+				valuePrefix := goName
+				if goPrefix != "" {
+					valuePrefix = goPrefix
+				}
+				for _, c := range d.children {
+					out[valuePrefix+"_"+c.name] = goSymbol{qualify(full, c.name), c.kind}
+				}
+			case "service":
+				for _, side := range []string{"Client", "Server"} {
+					out[goName+side] = goSymbol{full, d.kind}
+					for _, c := range d.children {
+						out[goName+side+"."+goCamelCase(c.name)] = goSymbol{qualify(full, c.name), c.kind}
+					}
+				}
+			}
+		}
+	}
+	walk("", f.pkg, f.decls)
+	return out
+}
+
+// goCamelCase converts a proto name to the Go name protoc-gen-go makes of
+// it.
+func goCamelCase(s string) string {
+	var b []byte
+	lower := func(c byte) bool { return c >= 'a' && c <= 'z' }
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '.' && i+1 < len(s) && lower(s[i+1]):
+		case c == '.':
+			b = append(b, '_')
+		case c == '_' && (i == 0 || s[i-1] == '.'):
+			b = append(b, 'X')
+		case c == '_' && i+1 < len(s) && lower(s[i+1]):
+		case c >= '0' && c <= '9':
+			b = append(b, c)
+		default:
+			if lower(c) {
+				c -= 'a' - 'A'
+			}
+			b = append(b, c)
+			for ; i+1 < len(s) && lower(s[i+1]); i++ {
+				b = append(b, s[i+1])
+			}
+		}
+	}
+	return string(b)
+}
+
+// goEmitter turns the declarations of a generated Go file into symbols.
+type goEmitter struct {
+	x          *extraction
+	rel        string
+	fid        uuid.UUID
+	cid        uuid.UUID
+	importPath string
+	names      map[string]goSymbol
+}
+
+// goOwner is a struct or interface whose fields or methods are being
+// read.
+type goOwner struct {
+	name  string
+	kind  string
+	id    uuid.UUID
+	order int
+}
+
+// typ emits a type declared on lines[i], returning it as the owner of the
+// lines that follow, or nil if it was not generated from a declaration.
+func (g *goEmitter) typ(name, kind string, lines []string, i int) *goOwner {
+	from, ok := g.names[name]
+	if !ok || from.kind != map[string]string{"struct": "message", "interface": "service", "int32": "enum"}[kind] {
+		return nil
+	}
+	if kind == "int32" {
+		kind = "type"
+	}
+	text := strings.TrimSuffix(strings.TrimSpace(lines[i]), " {")
+	id := g.symbol(nil, name, kind, text, from, lines, i, len("type "))
+	if kind == "type" {
+		return nil
+	}
+	return &goOwner{name: name, kind: kind, id: id}
+}
+
+// symbol emits a Go declaration on lines[i], at byte column col, and its
+// generated_from relation; owner is the type it is a field or method of.
+func (g *goEmitter) symbol(owner *goOwner, name, kind, text string, from goSymbol, lines []string, i, col int) uuid.UUID {
+	full := g.importPath + "." + name
+	if owner != nil {
+		full = g.importPath + "." + owner.name + "." + name
+	}
+	id := g.x.newSymbolID("go", full)
+	doc := goDoc(lines, i)
+	g.x.frag.Symbols = append(g.x.frag.Symbols, ir.Symbol{
+		Id: id, ContainerId: g.cid, Name: name, FullName: full, Kind: kind, Visibility: "public", OriginFileId: g.fid,
+		StartLine: i + 1, StartCol: col + 1, EndLine: i + 1, EndCol: len(lines[i]) + 1,
+		DocRaw: doc, DocFmt: cleanComment(doc),
+	})
+	g.x.frag.Signatures = append(g.x.frag.Signatures, ir.Signature{SymbolId: id, Text: text})
+	if owner != nil {
+		g.x.frag.Members = append(g.x.frag.Members, ir.Member{Id: uuid.New(), OwnerSymbolId: owner.id, ChildSymbolId: id, Order: owner.order})
+		owner.order++
+	}
+	type details struct {
+		File string `json:"file"`
+		Line int    `json:"line"`
+	}
+	b, _ := json.Marshal(details{File: g.rel, Line: i + 1})
+	g.x.frag.Relations = append(g.x.frag.Relations, ir.Relation{
+		SourceSymbolId: id, Relation: "generated_from", DstSymbolId: g.x.symbolID("proto", from.full), DetailsJson: string(b),
+	})
+	return id
+}
+
+// goDoc returns the // comment lines directly above lines[i].
+func goDoc(lines []string, i int) string {
+	j := i
+	for j > 0 && strings.HasPrefix(strings.TrimSpace(lines[j-1]), "//") {
+		j--
+	}
+	var out []string
+	for _, l := range lines[j:i] {
+		out = append(out, strings.TrimSpace(l))
+	}
+	return strings.Join(out, "\n")
+}
@@ -0,0 +1,131 @@
+package proto
+
+import "strings"
+
+// scalars are the builtin types of Protocol Buffers and Thrift, and the
+// words of their container types, which type references leave out.
+var scalars = set(`
+	double float int32 int64 uint32 uint64 sint32 sint64 fixed32 fixed64 sfixed32 sfixed64 bool string bytes
+	byte i8 i16 i32 i64 binary uuid slist void list set map stream group`)
+
+func set(words string) map[string]bool {
+	m := map[string]bool{}
+	for _, w := range strings.Fields(words) {
+		m[w] = true
+	}
+	return m
+}
+
+// isStdlib reports whether a full name is of the well-known types.
+func isStdlib(name string) bool {
+	return strings.HasPrefix(name, "google.protobuf.")
+}
+
+// ref is a name a type refers to: qualified when it resolves, else as
+// written.
+type ref struct {
+	Symbol string `json:"symbol,omitempty"`
+	Name   string `json:"name,omitempty"`
+	Type   string `json:"type,omitempty"`
+	Text   string `json:"text,omitempty"` // the whole type
+}
+
+// index is what a file and the files it imports declare.
+type index struct {
+	types    map[string]string // full name -> kind
+	programs map[string]string // Thrift file name -> its namespace
+}
+
+// add records the types among decls, prefix being the package or message
+// they are in.
+func (ix *index) add(prefix string, decls []*decl) {
+	for _, d := range decls {
+		switch d.kind {
+		case "message", "enum", "service", "struct", "union", "exception", "typedef":
+			full := qualify(prefix, d.name)
+			ix.types[full] = d.kind
+			ix.add(full, d.children)
+		}
+	}
+}
+
+func qualify(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
+
+// resolver resolves the type names of a file to full names, looking them
+// up from the scope they are used in outwards, as protoc does.
+type resolver struct {
+	ix     *index
+	thrift bool
+}
+
+// refs returns the names a type refers to, in order, leaving out scalars.
+func (r *resolver) refs(typ, scope string) []ref {
+	var out []ref
+	seen := map[string]bool{}
+	toks := lex(typ, false)
+	for i := 0; i < len(toks); i++ {
+		if toks[i].kind != tIdent && !toks[i].is(".") {
+			continue
+		}
+		name := toks[i].text
+		for i+2 < len(toks) && toks[i+1].is(".") && toks[i+2].kind == tIdent {
+			name += "." + toks[i+2].text
+			i += 2
+		}
+		if name == "." || scalars[name] || seen[name] {
+			continue
+		}
+		seen[name] = true
+		q := r.resolve(name, scope)
+		q.Text = typ
+		out = append(out, q)
+	}
+	return out
+}
+
+// resolve resolves a type name used in scope. A name with a leading dot is
+// fully qualified; in Thrift, a name qualified by the name of an included
+// file is in that file's namespace.
+func (r *resolver) resolve(name, scope string) ref {
+	if full, ok := strings.CutPrefix(name, "."); ok {
+		if r.ix.types[full] != "" || isStdlib(full) {
+			return ref{Symbol: full}
+		}
+		return ref{Name: full}
+	}
+	if r.thrift {
+		if prog, rest, ok := strings.Cut(name, "."); ok {
+			if ns, ok := r.ix.programs[prog]; ok && r.ix.types[qualify(ns, rest)] != "" {
+				return ref{Symbol: qualify(ns, rest)}
+			}
+		}
+	}
+	for s := scope; ; s = parentScope(s) {
+		if q := qualify(s, name); r.ix.types[q] != "" {
+			return ref{Symbol: q}
+		}
+		if s == "" {
+			break
+		}
+	}
+	if isStdlib(name) {
+		return ref{Symbol: name}
+	}
+	return ref{Name: name}
+}
+
+// kind returns the kind of a type the file sees, "" for others.
+func (r *resolver) kind(full string) string { return r.ix.types[full] }
+
+// parentScope returns the package or message a full name is in.
+func parentScope(name string) string {
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		return name[:i]
+	}
+	return ""
+}
@@ -0,0 +1,252 @@
+package proto
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// layout indexes the .proto and .thrift files of an input root and parses
+// them, once each: a file's type names resolve to the declarations of the
+// files it imports, wherever those are.
+type layout struct {
+	root  string
+	roots []string        // the directories imports are relative to: buf module roots, then the input root
+	files map[string]bool // every .proto and .thrift file under the root
+	// generated are the Go files protoc generated, by the proto file their
+	// source comment names; those whose source is not under the root are
+	// left out.
+	generated map[string][]string
+	byBase    map[string][]string
+	parses    map[string]*file
+	closures  map[string][]string
+	indexes   map[string]*index
+}
+
+func newLayout(root string) *layout {
+	l := &layout{
+		root: root, files: map[string]bool{}, generated: map[string][]string{}, byBase: map[string][]string{},
+		parses: map[string]*file{}, closures: map[string][]string{}, indexes: map[string]*index{},
+	}
+	l.walk()
+	return l
+}
+
+// walk lists the IDL files and generated Go files under the root, but not
+// in hidden directories, and reads the module roots of the buf.yaml and
+// buf.work.yaml files it finds.
+func (l *layout) walk() {
+	var bufs, gen []string
+	filepath.WalkDir(l.root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		rel, _ := filepath.Rel(l.root, p)
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			if rel != "." && (strings.HasPrefix(d.Name(), ".") || d.Name() == "node_modules") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		switch name := d.Name(); {
+		case name == "buf.yaml" || name == "buf.work.yaml":
+			bufs = append(bufs, rel)
+		case path.Ext(name) == ".proto" || path.Ext(name) == ".thrift":
+			l.files[rel] = true
+			l.byBase[name] = append(l.byBase[name], rel)
+		case strings.HasSuffix(name, ".pb.go"):
+			gen = append(gen, rel)
+		}
+		return nil
+	})
+	for _, rels := range l.byBase {
+		sort.Strings(rels)
+	}
+	sort.Strings(bufs)
+	for _, rel := range bufs {
+		l.roots = append(l.roots, l.readBuf(rel)...)
+	}
+	l.roots = append(l.roots, ".")
+	sort.Strings(gen)
+	for _, rel := range gen {
+		b, err := os.ReadFile(filepath.Join(l.root, filepath.FromSlash(rel)))
+		if err != nil {
+			continue
+		}
+		source, _ := goHeader(strings.Split(string(b), "\n"))
+		if source == "" {
+			continue
+		}
+		if p, _ := l.resolve(rel, importDecl{path: source}); path.Ext(p) == ".proto" {
+			l.generated[p] = append(l.generated[p], rel)
+		}
+	}
+}
+
+// readBuf returns the module roots a buf.yaml or buf.work.yaml names: the
+// build roots or module paths of a buf.yaml, its directory without any,
+// and the directories of a buf.work.yaml. The files are simple enough to
+// read a line at a time.
+func (l *layout) readBuf(rel string) []string {
+	b, err := os.ReadFile(filepath.Join(l.root, filepath.FromSlash(rel)))
+	if err != nil {
+		return nil
+	}
+	dir := path.Dir(rel)
+	var out []string
+	list := false // in a list of roots
+	for _, line := range strings.Split(string(b), "\n") {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		t := strings.TrimSpace(line)
+		switch {
+		case t == "":
+		case t == "roots:" || t == "directories:":
+			list = true
+		case list && strings.HasPrefix(t, "- "):
+			out = append(out, path.Join(dir, yamlScalar(t[2:])))
+		case strings.HasPrefix(t, "- path:") || strings.HasPrefix(t, "path:") && strings.HasPrefix(line, " "):
+			_, v, _ := strings.Cut(t, "path:")
+			out = append(out, path.Join(dir, yamlScalar(v)))
+			list = false
+		default:
+			list = false
+		}
+	}
+	if len(out) == 0 && path.Base(rel) == "buf.yaml" {
+		out = append(out, dir)
+	}
+	return out
+}
+
+func yamlScalar(s string) string {
+	return strings.Trim(strings.TrimSpace(s), `"'`)
+}
+
+// parse parses a file under the root, once; nil if it cannot be read.
+func (l *layout) parse(rel string) *file {
+	if f, ok := l.parses[rel]; ok {
+		return f
+	}
+	var f *file
+	if b, err := os.ReadFile(filepath.Join(l.root, filepath.FromSlash(rel))); err == nil {
+		f = parseFile(rel, string(b))
+	}
+	l.parses[rel] = f
+	return f
+}
+
+// parseFile parses a source by its extension.
+func parseFile(rel, src string) *file {
+	if path.Ext(rel) == ".thrift" {
+		f := parseThrift(src)
+		if f.pkg == "" {
+			// Thrift names a file's definitions by its name.
+			f.pkg = strings.TrimSuffix(path.Base(rel), ".thrift")
+		}
+		return f
+	}
+	return parseProto(src)
+}
+
+// resolve finds the file an import names. A proto import is relative to
+// a module root: those of buf.yaml files, then the input root; failing
+// those, it is looked for beside the importing file, and then anywhere
+// under the root that ends with its path. A Thrift include is relative to
+// the including file first. An unresolved import of google/protobuf is of
+// the well-known types.
+func (l *layout) resolve(from string, imp importDecl) (rel string, stdlib bool) {
+	p := path.Clean(imp.path)
+	beside := path.Join(path.Dir(from), p)
+	if path.Ext(from) == ".thrift" && l.files[beside] {
+		return beside, false
+	}
+	for _, root := range l.roots {
+		if r := path.Join(root, p); l.files[r] {
+			return r, false
+		}
+	}
+	if l.files[beside] {
+		return beside, false
+	}
+	best, score := "", -1
+	for _, r := range l.byBase[path.Base(p)] {
+		if r != p && !strings.HasSuffix(r, "/"+p) {
+			continue
+		}
+		// The nearest to the importing file.
+		if n := commonPrefix(r, from); n > score {
+			best, score = r, n
+		}
+	}
+	if best != "" {
+		return best, false
+	}
+	return "", strings.HasPrefix(p, "google/protobuf/")
+}
+
+func commonPrefix(a, b string) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
+
+// closure returns a file and the files it imports, transitively, in the
+// order they are first imported.
+func (l *layout) closure(rel string) []string {
+	if c, ok := l.closures[rel]; ok {
+		return c
+	}
+	seen := map[string]bool{}
+	var out []string
+	var visit func(r string)
+	visit = func(r string) {
+		if seen[r] {
+			return
+		}
+		seen[r] = true
+		out = append(out, r)
+		f := l.parse(r)
+		if f == nil {
+			return
+		}
+		for _, imp := range f.imports {
+			if imp.kind == "cpp_include" {
+				continue
+			}
+			if dep, _ := l.resolve(r, imp); dep != "" {
+				visit(dep)
+			}
+		}
+	}
+	visit(rel)
+	l.closures[rel] = out
+	return out
+}
+
+// index returns what a file sees: the types of its import closure.
+func (l *layout) index(rel string) *index {
+	if ix, ok := l.indexes[rel]; ok {
+		return ix
+	}
+	ix := &index{types: map[string]string{}, programs: map[string]string{}}
+	for _, r := range l.closure(rel) {
+		f := l.parse(r)
+		if f == nil {
+			continue
+		}
+		ix.add(f.pkg, f.decls)
+		if path.Ext(r) == ".thrift" {
+			ix.programs[strings.TrimSuffix(path.Base(r), ".thrift")] = f.pkg
+		}
+	}
+	l.indexes[rel] = ix
+	return ix
+}
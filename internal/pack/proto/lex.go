@@ -0,0 +1,194 @@
+package proto
+
+import "strings"
+
+type tokKind int
+
+const (
+	tIdent  tokKind = iota // identifiers and keywords, dotted names split at the dots
+	tString                // quoted strings, quotes included
+	tNumber
+	tPunct
+)
+
+type token struct {
+	kind    tokKind
+	text    string
+	pos     int // byte offset
+	end     int // byte offset after the token
+	line    int // 1-based
+	col     int // 0-based byte column
+	endLine int
+	doc     string // the comments directly above the token, markers included
+	trail   string // a comment after the token on its line
+}
+
+func (t token) is(text string) bool {
+	return (t.kind == tIdent || t.kind == tPunct) && t.text == text
+}
+
+// lex splits a .proto or .thrift source into tokens. Comments are not
+// tokens: a run of them directly above a token, with no blank line
+// between, is its doc, and one that follows a token on its line is that
+// token's trailing comment. hash is whether # starts a comment, as it does
+// in Thrift.
+func lex(src string, hash bool) []token {
+	var out []token
+	var pending []string // the comment run being read
+	pendingEnd := 0      // the line the run ends on
+	line, lineStart := 1, 0
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '\n':
+			line++
+			lineStart = i + 1
+			i++
+		case c == ' ' || c == '\t' || c == '\r' || c == '\f' || c == '\v':
+			i++
+		case c == '/' && i+1 < len(src) && (src[i+1] == '/' || src[i+1] == '*') || c == '#' && hash:
+			start, startLine := i, line
+			if c == '/' && src[i+1] == '*' {
+				end := strings.Index(src[i+2:], "*/")
+				if end < 0 {
+					end = len(src) - i - 4
+				}
+				i += end + 4
+				for _, ch := range src[start:min(i, len(src))] {
+					if ch == '\n' {
+						line++
+					}
+				}
+				if j := strings.LastIndexByte(src[:min(i, len(src))], '\n'); j >= start {
+					lineStart = j + 1
+				}
+			} else {
+				for i < len(src) && src[i] != '\n' {
+					i++
+				}
+			}
+			text := src[start:min(i, len(src))]
+			if n := len(out); n > 0 && out[n-1].endLine == startLine && out[n-1].trail == "" {
+				out[n-1].trail = text
+				continue
+			}
+			if len(pending) > 0 && pendingEnd < startLine-1 {
+				pending = nil // a blank line detaches the comments above
+			}
+			pending = append(pending, text)
+			pendingEnd = line
+		default:
+			t := token{pos: i, line: line, col: i - lineStart}
+			switch {
+			case c == '"' || c == '\'':
+				j := i + 1
+				for j < len(src) && src[j] != c && src[j] != '\n' {
+					if src[j] == '\\' {
+						j++
+					}
+					j++
+				}
+				t.kind, i = tString, min(j+1, len(src))
+			case isIdentStart(c):
+				j := i
+				for j < len(src) && isIdent(src[j]) {
+					j++
+				}
+				t.kind, i = tIdent, j
+			case c >= '0' && c <= '9' || c == '-' && i+1 < len(src) && src[i+1] >= '0' && src[i+1] <= '9':
+				j := i + 1
+				for j < len(src) && (isIdent(src[j]) || src[j] == '.' || (src[j] == '-' || src[j] == '+') && (src[j-1] == 'e' || src[j-1] == 'E')) {
+					j++
+				}
+				t.kind, i = tNumber, j
+			default:
+				t.kind, i = tPunct, i+1
+			}
+			t.text, t.end, t.endLine = src[t.pos:i], i, line
+			if len(pending) > 0 && pendingEnd >= line-1 {
+				t.doc = strings.Join(pending, "\n")
+			}
+			pending = nil
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isIdent(c byte) bool { return isIdentStart(c) || c >= '0' && c <= '9' }
+
+// cleanComment strips the markers of comments: the // or # of each line,
+// or the /* and */ of a block and the leading asterisks of its lines.
+func cleanComment(raw string) string {
+	var lines []string
+	for _, block := range splitComments(raw) {
+		if strings.HasPrefix(block, "/*") {
+			s := strings.TrimSuffix(strings.TrimLeft(block[2:], "*!"), "*/")
+			for i, l := range strings.Split(s, "\n") {
+				t := strings.TrimLeft(l, " \t")
+				if strings.HasPrefix(t, "*") {
+					t = strings.TrimPrefix(strings.TrimLeft(t, "*"), " ")
+				} else if i > 0 {
+					t = l
+				}
+				lines = append(lines, strings.TrimRight(t, " \t"))
+			}
+			continue
+		}
+		l := strings.TrimLeft(block, " \t")
+		l = strings.TrimLeft(l, "/#!")
+		lines = append(lines, strings.TrimRight(strings.TrimPrefix(l, " "), " \t"))
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return dedent(lines)
+}
+
+// splitComments splits a doc into its comments: a block comment whole, and
+// line comments a line each.
+func splitComments(raw string) []string {
+	var out []string
+	for raw != "" {
+		if strings.HasPrefix(raw, "/*") {
+			end := strings.Index(raw, "*/")
+			if end < 0 {
+				return append(out, raw)
+			}
+			out = append(out, raw[:end+2])
+			raw = strings.TrimLeft(raw[end+2:], "\n")
+			continue
+		}
+		l, rest, _ := strings.Cut(raw, "\n")
+		out = append(out, l)
+		raw = rest
+	}
+	return out
+}
+
+// dedent removes the indentation the lines share.
+func dedent(lines []string) string {
+	indent := -1
+	for _, l := range lines {
+		if strings.TrimSpace(l) == "" {
+			continue
+		}
+		n := len(l) - len(strings.TrimLeft(l, " \t"))
+		if indent < 0 || n < indent {
+			indent = n
+		}
+	}
+	for i, l := range lines {
+		if len(l) >= indent && indent > 0 {
+			lines[i] = l[indent:]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
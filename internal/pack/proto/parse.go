@@ -0,0 +1,545 @@
+package proto
+
+import (
+	"strconv"
+	"strings"
+)
+
+// file is the outline of a .proto or .thrift file.
+type file struct {
+	syntax     string            // proto2, proto3, or editions and its edition; "" for Thrift
+	pkg        string            // the package; Thrift's namespace
+	namespaces map[string]string // Thrift's namespaces, by language
+	options    []option          // file options
+	imports    []importDecl
+	decls      []*decl
+}
+
+// importDecl is an import, or a Thrift include.
+type importDecl struct {
+	path string
+	kind string // public or weak; include or cpp_include for Thrift
+	line int
+}
+
+type option struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// decl is a declaration: a type, a service, or one of their members.
+type decl struct {
+	kind      string // message, enum, service, rpc, field, enum_member; struct, union, exception, typedef, constant for Thrift
+	name      string
+	line, col int // of the name: 1-based line and byte column
+	endLine   int
+	endCol    int // 1-based, after the last character
+	doc       string
+	typ       string // of fields, typedefs and consts
+	label     string // repeated, optional or required; Thrift's requiredness
+	tag       *int   // field number, or enum value
+	oneof     string // the oneof a field is in
+	extendee  string // the message an extension field extends
+	value     string // a field's default, a const's value
+	options   []option
+	reserved  []string // reserved numbers, ranges and names
+	params    []param  // of an rpc
+	results   []param
+	throws    []param
+	modifiers []string // oneway; stream is kept on params
+	extends   string   // the service a Thrift service extends
+	sig       string
+	children  []*decl
+}
+
+// param is the request or response of an rpc, or a parameter, result or
+// exception of a Thrift function.
+type param struct {
+	Name   string `json:"name,omitempty"`
+	Type   string `json:"type"`
+	Tag    *int   `json:"tag,omitempty"`
+	Stream bool   `json:"stream,omitempty"`
+}
+
+type parser struct {
+	src  string
+	toks []token
+	i    int
+}
+
+var eofToken = token{kind: tPunct}
+
+func (p *parser) peek(n int) token {
+	if p.i+n < len(p.toks) {
+		return p.toks[p.i+n]
+	}
+	return eofToken
+}
+
+func (p *parser) eof() bool { return p.i >= len(p.toks) }
+
+func (p *parser) accept(text string) bool {
+	if p.peek(0).is(text) {
+		p.i++
+		return true
+	}
+	return false
+}
+
+// text returns the source of tokens [from, to), with runs of white space,
+// and comments, collapsed to a space.
+func (p *parser) text(from, to int) string {
+	if from >= to || from >= len(p.toks) {
+		return ""
+	}
+	var b strings.Builder
+	for i := from; i < to && i < len(p.toks); i++ {
+		if i > from && p.toks[i].pos > p.toks[i-1].end {
+			b.WriteByte(' ')
+		}
+		b.WriteString(p.toks[i].text)
+	}
+	return b.String()
+}
+
+// name reads a dotted name at p.i: a.b.C, or .a.b.C when fully qualified.
+func (p *parser) name() string {
+	start := p.i
+	p.accept(".")
+	for p.peek(0).kind == tIdent {
+		p.i++
+		if !p.peek(0).is(".") || p.peek(1).kind != tIdent {
+			break
+		}
+		p.i++
+	}
+	return p.text(start, p.i)
+}
+
+// at sets the position of a declaration to that of token i.
+func (p *parser) at(d *decl, i int) {
+	t := p.toks[min(i, len(p.toks)-1)]
+	d.line, d.col = t.line, t.col+1
+}
+
+// end sets the end of a declaration to that of the token before p.i.
+func (p *parser) end(d *decl) {
+	if p.i > 0 {
+		t := p.toks[min(p.i, len(p.toks))-1]
+		d.endLine, d.endCol = t.endLine, t.col+len(t.text)+1
+	}
+}
+
+// docAt returns the doc of the declaration starting at token i: the
+// comments directly above it.
+func (p *parser) docAt(i int) string {
+	if i < len(p.toks) {
+		return p.toks[i].doc
+	}
+	return ""
+}
+
+// skipStatement skips to after the next ; or block at this depth.
+func (p *parser) skipStatement() {
+	for !p.eof() {
+		t := p.peek(0)
+		switch {
+		case t.is(";"):
+			p.i++
+			return
+		case t.is("{"):
+			p.group()
+			p.accept(";")
+			return
+		case t.is("}"):
+			return
+		case t.is("(") || t.is("["):
+			p.group()
+		default:
+			p.i++
+		}
+	}
+}
+
+// group skips a bracketed group at p.i, returning the tokens inside it.
+func (p *parser) group() (from, to int) {
+	open := p.peek(0).text
+	close := map[string]string{"{": "}", "(": ")", "[": "]", "<": ">"}[open]
+	from = p.i + 1
+	depth := 0
+	for ; !p.eof(); p.i++ {
+		switch p.peek(0).text {
+		case open:
+			depth++
+		case close:
+			if depth--; depth == 0 {
+				p.i++
+				return from, p.i - 1
+			}
+		}
+	}
+	return from, p.i
+}
+
+// atoi parses a field number or enum value: decimal, hex or octal.
+func atoi(s string) *int {
+	v, err := strconv.ParseInt(s, 0, 64)
+	if err != nil {
+		return nil
+	}
+	n := int(v)
+	return &n
+}
+
+// parseProto outlines a .proto source.
+func parseProto(src string) *file {
+	p := &parser{src: src, toks: lex(src, false)}
+	f := &file{}
+	for !p.eof() {
+		start := p.i
+		switch t := p.peek(0); {
+		case t.is("syntax") || t.is("edition"):
+			p.i++
+			p.accept("=")
+			f.syntax = strings.Trim(p.peek(0).text, `"'`)
+			if t.is("edition") {
+				f.syntax = "editions " + f.syntax
+			}
+			p.skipStatement()
+		case t.is("package"):
+			p.i++
+			f.pkg = strings.TrimPrefix(p.name(), ".")
+			p.skipStatement()
+		case t.is("import"):
+			p.i++
+			imp := importDecl{line: t.line}
+			if p.peek(0).is("public") || p.peek(0).is("weak") {
+				imp.kind = p.peek(0).text
+				p.i++
+			}
+			imp.path = unquote(p.peek(0).text)
+			f.imports = append(f.imports, imp)
+			p.skipStatement()
+		case t.is("option"):
+			f.options = append(f.options, p.option())
+		default:
+			f.decls = append(f.decls, p.protoDecl(nil)...)
+		}
+		if p.i == start {
+			p.i++
+		}
+	}
+	return f
+}
+
+func unquote(s string) string {
+	if u, err := strconv.Unquote(s); err == nil {
+		return u
+	}
+	return strings.Trim(s, `"'`)
+}
+
+// option parses option name = value; at p.i.
+func (p *parser) option() option {
+	p.i++
+	from := p.i
+	for !p.eof() && !p.peek(0).is("=") && !p.peek(0).is(";") {
+		p.i++
+	}
+	o := option{Name: p.text(from, p.i)}
+	if p.accept("=") {
+		from := p.i
+		for !p.eof() && !p.peek(0).is(";") && !p.peek(0).is("}") {
+			if p.peek(0).is("{") {
+				p.group()
+				continue
+			}
+			p.i++
+		}
+		o.Value = unquoteValue(p.text(from, p.i))
+	}
+	p.accept(";")
+	return o
+}
+
+// unquoteValue unquotes a string value; others are kept as written.
+func unquoteValue(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') {
+		return unquote(s)
+	}
+	return s
+}
+
+// options parses a field's [name = value, ...].
+func (p *parser) options() []option {
+	from, to := p.group()
+	var out []option
+	for _, part := range p.split(from, to, ",") {
+		eq := part[0]
+		for eq < part[1] && !p.toks[eq].is("=") {
+			eq++
+		}
+		o := option{Name: p.text(part[0], eq)}
+		if eq < part[1] {
+			o.Value = unquoteValue(p.text(eq+1, part[1]))
+		}
+		out = append(out, o)
+	}
+	return out
+}
+
+// split splits tokens [from, to) at sep outside brackets.
+func (p *parser) split(from, to int, sep string) [][2]int {
+	var out [][2]int
+	depth, start := 0, from
+	for i := from; i < to; i++ {
+		switch t := p.toks[i]; {
+		case t.is("(") || t.is("[") || t.is("{") || t.is("<"):
+			depth++
+		case t.is(")") || t.is("]") || t.is("}") || t.is(">"):
+			depth--
+		case depth == 0 && t.is(sep):
+			if i > start {
+				out = append(out, [2]int{start, i})
+			}
+			start = i + 1
+		}
+	}
+	if to > start {
+		out = append(out, [2]int{start, to})
+	}
+	return out
+}
+
+// protoDecl parses a declaration in a file, or the body of the message
+// owner.
+func (p *parser) protoDecl(owner *decl) []*decl {
+	start := p.i
+	switch t := p.peek(0); {
+	case t.is(";"):
+		p.i++
+		return nil
+	case (t.is("message") || t.is("enum") || t.is("service")) && p.peek(1).kind == tIdent && p.peek(2).is("{"):
+		d := &decl{kind: t.text, name: p.peek(1).text, doc: p.docAt(start)}
+		p.at(d, p.i+1)
+		p.i += 2
+		d.sig = p.text(start, p.i)
+		p.body(d)
+		p.end(d)
+		return []*decl{d}
+	case t.is("extend") && owner == nil || t.is("extend") && p.peek(1).kind == tIdent:
+		p.i++
+		extendee := p.name()
+		if !p.peek(0).is("{") {
+			p.skipStatement()
+			return nil
+		}
+		p.i++
+		var out []*decl
+		for !p.eof() && !p.peek(0).is("}") {
+			before := p.i
+			for _, d := range p.protoDecl(owner) {
+				if d.kind == "field" {
+					d.extendee = extendee
+				}
+				out = append(out, d)
+			}
+			if p.i == before {
+				p.i++
+			}
+		}
+		p.accept("}")
+		return out
+	case t.is("option"):
+		p.option()
+		return nil
+	case t.is("reserved") || t.is("extensions"):
+		p.i++
+		from := p.i
+		p.skipStatement()
+		if t.is("reserved") && owner != nil {
+			for _, part := range p.split(from, p.i-1, ",") {
+				owner.reserved = append(owner.reserved, unquoteValue(p.text(part[0], part[1])))
+			}
+		}
+		return nil
+	case t.is("oneof") && p.peek(1).kind == tIdent && p.peek(2).is("{"):
+		name := p.peek(1).text
+		p.i += 3
+		var out []*decl
+		for !p.eof() && !p.peek(0).is("}") {
+			before := p.i
+			if p.peek(0).is("option") {
+				p.option()
+				continue
+			}
+			for _, d := range p.protoDecl(owner) {
+				d.oneof = name
+				out = append(out, d)
+			}
+			if p.i == before {
+				p.i++
+			}
+		}
+		p.accept("}")
+		return out
+	case t.is("rpc") && owner != nil && owner.kind == "service":
+		return []*decl{p.rpc()}
+	case owner != nil && owner.kind == "enum":
+		return p.enumValue()
+	case t.kind == tIdent:
+		return p.field()
+	}
+	p.skipStatement()
+	return nil
+}
+
+// body parses the declarations of a message, enum or service, from its {
+// at p.i to after its }.
+func (p *parser) body(d *decl) {
+	p.accept("{")
+	for !p.eof() && !p.peek(0).is("}") {
+		before := p.i
+		d.children = append(d.children, p.protoDecl(d)...)
+		if p.i == before {
+			p.i++
+		}
+	}
+	p.accept("}")
+}
+
+// field parses a field: [label] Type name = N [options];, a map field, or
+// a proto2 group.
+func (p *parser) field() []*decl {
+	start := p.i
+	d := &decl{kind: "field", doc: p.docAt(start)}
+	if t := p.peek(0).text; t == "repeated" || t == "optional" || t == "required" {
+		if p.peek(1).kind == tIdent && !p.peek(2).is("=") {
+			d.label = t
+			p.i++
+		}
+	}
+	if p.peek(0).is("map") && p.peek(1).is("<") {
+		p.i++
+		from, to := p.group()
+		d.typ = "map<" + p.text(from, to) + ">"
+	} else if p.peek(0).is("group") {
+		// A group is a nested message and a field of its type.
+		p.i++
+		g := &decl{kind: "message", name: p.peek(0).text, doc: d.doc}
+		p.at(g, p.i)
+		p.i++
+		d.typ, d.name = g.name, strings.ToLower(g.name)
+		p.at(d, p.i-1)
+		if p.accept("=") {
+			d.tag = atoi(p.peek(0).text)
+			p.i++
+		}
+		if p.peek(0).is("[") {
+			d.options = p.options()
+		}
+		g.sig = p.text(start, p.i)
+		d.sig = g.sig
+		if p.peek(0).is("{") {
+			p.body(g)
+		}
+		p.end(g)
+		p.end(d)
+		return []*decl{g, d}
+	} else {
+		d.typ = p.name()
+	}
+	if p.peek(0).kind != tIdent {
+		p.skipStatement()
+		return nil
+	}
+	d.name = p.peek(0).text
+	p.at(d, p.i)
+	p.i++
+	if p.accept("=") {
+		d.tag = atoi(p.peek(0).text)
+		p.i++
+	}
+	if p.peek(0).is("[") {
+		d.options = p.options()
+		for _, o := range d.options {
+			if o.Name == "default" {
+				d.value = o.Value
+			}
+		}
+	}
+	d.sig = p.text(start, p.i)
+	p.accept(";")
+	p.end(d)
+	if d.doc == "" {
+		d.doc = p.toks[p.i-1].trail
+	}
+	return []*decl{d}
+}
+
+// enumValue parses NAME = N [options];.
+func (p *parser) enumValue() []*decl {
+	start := p.i
+	d := &decl{kind: "enum_member", name: p.peek(0).text, doc: p.docAt(start)}
+	p.at(d, p.i)
+	p.i++
+	if p.accept("=") {
+		d.tag = atoi(p.peek(0).text)
+		p.i++
+	}
+	if p.peek(0).is("[") {
+		d.options = p.options()
+	}
+	d.sig = p.text(start, p.i)
+	p.accept(";")
+	p.end(d)
+	if d.doc == "" {
+		d.doc = p.toks[p.i-1].trail
+	}
+	return []*decl{d}
+}
+
+// rpc parses rpc Name (stream Req) returns (stream Resp) followed by ;
+// or a block of options.
+func (p *parser) rpc() *decl {
+	start := p.i
+	d := &decl{kind: "rpc", name: p.peek(1).text, doc: p.docAt(start)}
+	p.at(d, p.i+1)
+	p.i += 2
+	msg := func() []param {
+		if !p.peek(0).is("(") {
+			return nil
+		}
+		p.i++
+		pr := param{}
+		if p.peek(0).is("stream") && !p.peek(1).is(")") {
+			pr.Stream = true
+			p.i++
+		}
+		pr.Type = p.name()
+		p.accept(")")
+		return []param{pr}
+	}
+	d.params = msg()
+	if p.accept("returns") {
+		d.results = msg()
+	}
+	d.sig = p.text(start, p.i)
+	if p.peek(0).is("{") {
+		from, to := p.group()
+		for i := from; i < to; i++ {
+			if p.toks[i].is("option") {
+				save := p.i
+				p.i = i
+				d.options = append(d.options, p.option())
+				i = p.i - 1
+				p.i = save
+			}
+		}
+		p.accept(";")
+	} else {
+		p.accept(";")
+	}
+	p.end(d)
+	return d
+}
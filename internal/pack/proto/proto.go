@@ -0,0 +1,343 @@
+// Package proto is the language pack for Protocol Buffers and Thrift
+// IDLs, and the Go code protoc generates from them. A token-level parser
+// outlines a .proto file's messages, enums and services, with their
+// fields, values and rpcs, and a .thrift file's structs, unions,
+// exceptions, enums, typedefs, constants and services.
+//
+// Each proto package is a container, and each Thrift namespace, that of
+// every language or else the first listed, or the file's name without
+// one. Fields and values are the members of their types, with their tag
+// numbers in extra_json. Imports and includes are resolved against the
+// module roots of buf.yaml and buf.work.yaml files, the input root, the
+// importing file's directory and the files under the input root, and type
+// names are resolved, as protoc does, against the declarations of the
+// file and the files it imports.
+//
+// A *.pb.go or *_grpc.pb.go file is linked to the proto file its source
+// comment names: the structs, enum types and constants, fields, and client
+// and server interfaces generated for its declarations are symbols of
+// their Go package, with a generated_from relation to what they were
+// generated from. The pack finds those files in its own walk of the input
+// root and outlines each with the proto file it was generated from, so it
+// leaves the go language to a Go pack.
+package proto
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+
+	"github.com/ChaseHampton/cargoworker/internal/ir"
+	"github.com/ChaseHampton/cargoworker/internal/pack"
+	"github.com/ChaseHampton/cargoworker/internal/pack/outline"
+)
+
+func init() {
+	p := New()
+	for _, lang := range []string{"proto", "thrift"} {
+		pack.Register(lang, p)
+	}
+}
+
+// defaultPackage names the container of a proto file without a package.
+const defaultPackage = "(default)"
+
+// Pack extracts IDLs and the Go code generated from them. Generated code
+// is in a different directory and language from its source, so one pack
+// serves them all and keeps containers and symbol ids, language-qualified,
+// in tables across units; each root's layout keeps its parsed sources.
+type Pack struct {
+	mu      sync.Mutex
+	layouts map[string]*layout // by input root
+	tables  *outline.Tables
+}
+
+func New() *Pack {
+	return &Pack{layouts: map[string]*layout{}, tables: outline.NewTables()}
+}
+
+func (p *Pack) Name() string { return "proto" }
+
+var _ pack.Pack = (*Pack)(nil)
+
+// Extract outlines the files of a unit, and the Go files generated from
+// its proto files.
+func (p *Pack) Extract(ctx context.Context, u pack.Unit) (*ir.Fragment, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	l, ok := p.layouts[u.Root]
+	if !ok {
+		l = newLayout(u.Root)
+		p.layouts[u.Root] = l
+	}
+	x := &extraction{l: l, u: u, frag: p.tables.Fragment(u)}
+	var gen *extraction // of the generated Go files, whose language is go
+	for _, rel := range u.Files {
+		if ext := path.Ext(rel); ext != ".proto" && ext != ".thrift" {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		b, err := os.ReadFile(filepath.Join(u.Root, filepath.FromSlash(rel)))
+		if err != nil {
+			return nil, err
+		}
+		f := l.parse(rel)
+		if f == nil {
+			f = parseFile(rel, string(b))
+		}
+		x.file(rel, b, f)
+		for _, goRel := range l.generated[rel] {
+			b, err := os.ReadFile(filepath.Join(u.Root, filepath.FromSlash(goRel)))
+			if err != nil {
+				return nil, err
+			}
+			if gen == nil {
+				gu := pack.Unit{Root: u.Root, Dir: u.Dir, Language: "go"}
+				gen = &extraction{l: l, u: gu, frag: p.tables.Fragment(gu)}
+			}
+			gen.generated(goRel, rel, b)
+		}
+	}
+	if gen != nil {
+		merge(x.frag.Fragment, gen.frag.Fragment)
+	}
+	return x.frag.Fragment, nil
+}
+
+// merge appends the records of g to f.
+func merge(f, g *ir.Fragment) {
+	f.Containers = append(f.Containers, g.Containers...)
+	f.Files = append(f.Files, g.Files...)
+	f.Symbols = append(f.Symbols, g.Symbols...)
+	f.Signatures = append(f.Signatures, g.Signatures...)
+	f.Typerefs = append(f.Typerefs, g.Typerefs...)
+	f.Members = append(f.Members, g.Members...)
+	f.Relations = append(f.Relations, g.Relations...)
+	f.Imports = append(f.Imports, g.Imports...)
+	f.Diagnostics = append(f.Diagnostics, g.Diagnostics...)
+}
+
+// extraction is the state of one Extract call.
+type extraction struct {
+	l    *layout
+	u    pack.Unit
+	frag *outline.Fragment
+}
+
+// packageContainer returns the container of a proto package or Thrift
+// namespace, making it.
+func (x *extraction) packageContainer(f *file) uuid.UUID {
+	kind, pkg := "package", f.pkg
+	if x.u.Language == "thrift" {
+		kind = "namespace"
+	}
+	if pkg == "" {
+		pkg = defaultPackage
+	}
+	return x.frag.Container(x.u.Language+":"+pkg, func() ir.Container {
+		c := ir.Container{Name: pkg[strings.LastIndex(pkg, ".")+1:], FullName: pkg, Kind: kind}
+		if len(f.namespaces) > 0 {
+			b, _ := json.Marshal(map[string]any{"namespaces": f.namespaces})
+			c.ExtraJson = string(b)
+		}
+		return c
+	})
+}
+
+// symbolID returns the id of the symbol named full in a language, which
+// may be emitted in a later unit.
+func (x *extraction) symbolID(lang, full string) uuid.UUID {
+	return x.frag.SymbolID(lang + ":" + full)
+}
+
+// newSymbolID returns the id to emit the symbol named full in a language
+// with.
+func (x *extraction) newSymbolID(lang, full string) uuid.UUID {
+	return x.frag.NewSymbolID(lang + ":" + full)
+}
+
+func (x *extraction) file(rel string, src []byte, f *file) {
+	cid := x.packageContainer(f)
+	fid := x.frag.File(cid, rel, src)
+	x.imports(cid, rel, f.imports)
+	res := &resolver{ix: x.l.index(rel), thrift: x.u.Language == "thrift"}
+	e := &emitter{x: x, res: res, fid: fid, rel: rel, cid: cid}
+	e.decls(nil, f.pkg, f.decls)
+}
+
+// imports records the imports or includes of a file.
+func (x *extraction) imports(cid uuid.UUID, rel string, imps []importDecl) {
+	type details struct {
+		File     string `json:"file"`
+		Line     int    `json:"line"`
+		Kind     string `json:"kind,omitempty"`     // public or weak; include or cpp_include
+		Resolved string `json:"resolved,omitempty"` // the file under the input root
+		Stdlib   bool   `json:"stdlib,omitempty"`
+	}
+	for _, imp := range imps {
+		var r string
+		var stdlib bool
+		if imp.kind != "cpp_include" {
+			r, stdlib = x.l.resolve(rel, imp)
+		}
+		b, _ := json.Marshal(details{File: rel, Line: imp.line, Kind: imp.kind, Resolved: r, Stdlib: stdlib})
+		x.frag.Imports = append(x.frag.Imports, ir.Import{ContainerId: cid, Target: imp.path, DetailsJson: string(b)})
+	}
+}
+
+// emitter turns the declarations of a file into symbols.
+type emitter struct {
+	x   *extraction
+	res *resolver
+	fid uuid.UUID
+	rel string
+	cid uuid.UUID
+}
+
+// extra is the extra_json of a symbol.
+type extra struct {
+	Tag       *int     `json:"tag,omitempty"`   // a field's number
+	Value     *int     `json:"value,omitempty"` // an enum value's
+	Label     string   `json:"label,omitempty"` // repeated, optional or required
+	Oneof     string   `json:"oneof,omitempty"`
+	MapKey    string   `json:"map_key,omitempty"`
+	MapValue  string   `json:"map_value,omitempty"`
+	Default   string   `json:"default,omitempty"`
+	Extendee  string   `json:"extendee,omitempty"` // the message an extension extends
+	Options   []option `json:"options,omitempty"`  // Thrift's annotations
+	Reserved  []string `json:"reserved,omitempty"`
+	Modifiers []string `json:"modifiers,omitempty"`
+}
+
+// decls emits declarations in scope, a package or the type they are
+// members of; owner is that type's symbol id, nil outside types.
+func (e *emitter) decls(owner *uuid.UUID, scope string, decls []*decl) {
+	lang := e.x.u.Language
+	for i, d := range decls {
+		if d.name == "" {
+			continue
+		}
+		full := qualify(scope, d.name)
+		id := e.x.newSymbolID(lang, full)
+		sym := ir.Symbol{
+			Id: id, ContainerId: e.cid, Name: d.name, FullName: full, Kind: d.kind, Visibility: "public", OriginFileId: e.fid,
+			StartLine: d.line, StartCol: d.col, EndLine: d.endLine, EndCol: d.endCol,
+			DocRaw: d.doc, DocFmt: cleanComment(d.doc),
+		}
+		ex := extra{Label: d.label, Oneof: d.oneof, Extendee: d.extendee, Options: d.options, Reserved: d.reserved, Modifiers: d.modifiers}
+		switch d.kind {
+		case "field":
+			ex.Tag, ex.Default = d.tag, d.value
+			if k, v, ok := mapTypes(d.typ); ok {
+				ex.MapKey, ex.MapValue = k, v
+			}
+		case "enum_member":
+			ex.Value = d.tag
+		case "constant":
+			ex.Default = d.value
+		}
+		if b, _ := json.Marshal(ex); string(b) != "{}" {
+			sym.ExtraJson = string(b)
+		}
+		e.x.frag.Symbols = append(e.x.frag.Symbols, sym)
+		if owner != nil {
+			e.x.frag.Members = append(e.x.frag.Members, ir.Member{Id: uuid.New(), OwnerSymbolId: *owner, ChildSymbolId: id, Order: i})
+		}
+		e.relations(id, d, scope)
+		e.signature(id, owner, d, scope)
+		if len(d.children) > 0 {
+			e.decls(&id, full, d.children)
+		}
+	}
+}
+
+// mapTypes splits map<K, V> into its key and value types.
+func mapTypes(typ string) (key, value string, ok bool) {
+	args, ok := strings.CutPrefix(typ, "map<")
+	if !ok || !strings.HasSuffix(args, ">") {
+		return "", "", false
+	}
+	key, value, ok = strings.Cut(strings.TrimSuffix(args, ">"), ",")
+	return strings.TrimSpace(key), strings.TrimSpace(value), ok
+}
+
+// relations records the service a Thrift service extends.
+func (e *emitter) relations(id uuid.UUID, d *decl, scope string) {
+	if d.extends == "" {
+		return
+	}
+	r := e.res.resolve(d.extends, scope)
+	if r.Symbol == "" || e.res.kind(r.Symbol) != "service" {
+		return
+	}
+	js, _ := json.Marshal(map[string]string{"file": e.rel, "type": d.extends})
+	e.x.frag.Relations = append(e.x.frag.Relations, ir.Relation{
+		SourceSymbolId: id, Relation: "extends", DstSymbolId: e.x.symbolID(e.x.u.Language, r.Symbol), DetailsJson: string(js),
+	})
+}
+
+// signature records the signature and type references of a declaration,
+// scope being the package or type it is declared in.
+func (e *emitter) signature(id uuid.UUID, owner *uuid.UUID, d *decl, scope string) {
+	if d.sig == "" {
+		return
+	}
+	sig := ir.Signature{SymbolId: id, Text: d.sig}
+	js := struct {
+		Params  []param `json:"params,omitempty"`
+		Results []param `json:"results,omitempty"`
+		Throws  []param `json:"throws,omitempty"`
+	}{}
+	switch d.kind {
+	case "rpc":
+		js.Params, js.Results, js.Throws = d.params, d.results, d.throws
+		if js.Params == nil {
+			js.Params = []param{}
+		}
+		for i, pr := range d.params {
+			e.typerefs(id, fmt.Sprintf("param:%d", i), pr.Type, scope)
+		}
+		for _, pr := range d.results {
+			e.typerefs(id, "result:0", pr.Type, scope)
+		}
+		for i, pr := range d.throws {
+			e.typerefs(id, fmt.Sprintf("throws:%d", i), pr.Type, scope)
+		}
+	case "field":
+		if owner != nil && d.extendee == "" {
+			e.typerefs(*owner, "field:"+d.name, d.typ, scope)
+		} else {
+			e.typerefs(id, "type", d.typ, scope)
+		}
+	case "typedef", "constant":
+		e.typerefs(id, "type", d.typ, scope)
+	case "service":
+		if d.extends != "" {
+			e.typerefs(id, "base:0", d.extends, scope)
+		}
+	}
+	if b, _ := json.Marshal(js); string(b) != "{}" {
+		sig.Json = string(b)
+	}
+	e.x.frag.Signatures = append(e.x.frag.Signatures, sig)
+}
+
+func (e *emitter) typerefs(owner uuid.UUID, slot, typ, scope string) {
+	if typ == "" {
+		return
+	}
+	for i, r := range e.res.refs(typ, scope) {
+		r.Type = e.res.kind(r.Symbol)
+		b, _ := json.Marshal(r)
+		e.x.frag.Typerefs = append(e.x.frag.Typerefs, ir.Typeref{Id: uuid.New(), OwnerSymbolId: owner, Slot: slot, Json: string(b), Order: i})
+	}
+}
@@ -0,0 +1,534 @@
+package proto
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/ChaseHampton/cargoworker/internal/db"
+	"github.com/ChaseHampton/cargoworker/internal/ir"
+	"github.com/ChaseHampton/cargoworker/internal/pack"
+	"github.com/ChaseHampton/cargoworker/internal/pack/packtest"
+	"github.com/ChaseHampton/cargoworker/internal/store"
+)
+
+var tree = map[string]string{
+	"proto/buf.yaml": `version: v1
+name: buf.build/acme/shop
+deps:
+  - buf.build/googleapis/googleapis
+`,
+	"proto/acme/common/v1/money.proto": `syntax = "proto3";
+
+package acme.common.v1;
+
+option go_package = "github.com/acme/shop/gen/common/v1;commonv1";
+
+// An amount of money in a currency.
+message Money {
+  string currency = 1; // ISO 4217
+  int64 units = 2;
+  int32 nanos = 3 [deprecated = true];
+}
+`,
+	"proto/acme/shop/v1/shop.proto": `syntax = "proto3";
+
+package acme.shop.v1;
+
+import "acme/common/v1/money.proto";
+import public "google/protobuf/timestamp.proto";
+
+option go_package = "github.com/acme/shop/gen/shop/v1;shopv1";
+
+// An order of items.
+//
+// Orders are immutable once placed.
+message Order {
+  // The state of an order.
+  enum State {
+    STATE_UNSPECIFIED = 0;
+    STATE_PLACED = 1;
+  }
+
+  // An item of an order.
+  message Item {
+    string sku = 1;
+    acme.common.v1.Money price = 2;
+  }
+
+  reserved 4, 8 to 10;
+  reserved "legacy";
+
+  string id = 1;
+  repeated Item items = 2;
+  State state = 3;
+  map<string, Item> by_sku = 5;
+  google.protobuf.Timestamp placed_at = 6;
+  oneof payment {
+    string card_token = 7;
+    string voucher = 11;
+  }
+}
+
+/* The kinds of order. */
+enum Kind {
+  KIND_UNSPECIFIED = 0;
+  KIND_EXPRESS = 1 [deprecated = true];
+}
+
+// Places and lists orders.
+service Orders {
+  // Places an order.
+  rpc PlaceOrder(Order) returns (Order);
+  rpc WatchOrders(.acme.shop.v1.Kind) returns (stream Order) {
+    option deprecated = true;
+  }
+}
+`,
+	"gen/shop/v1/shop.pb.go": `// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: acme/shop/v1/shop.proto
+
+package shopv1
+
+// The kinds of order.
+type Kind int32
+
+const (
+	Kind_KIND_UNSPECIFIED Kind = 0
+	Kind_KIND_EXPRESS     Kind = 1
+)
+
+// The state of an order.
+type Order_State int32
+
+const (
+	Order_STATE_UNSPECIFIED Order_State = 0
+	Order_STATE_PLACED      Order_State = 1
+)
+
+// An order of items.
+//
+// Orders are immutable once placed.
+type Order struct {
+	state         protoimpl.MessageState
+	Id    string              ` + "`" + `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"` + "`" + `
+	Items []*Order_Item       ` + "`" + `protobuf:"bytes,2,rep,name=items,proto3" json:"items,omitempty"` + "`" + `
+	BySku map[string]*Order_Item ` + "`" + `protobuf:"bytes,5,rep,name=by_sku,json=bySku,proto3" json:"by_sku,omitempty"` + "`" + `
+	Payment isOrder_Payment ` + "`" + `protobuf_oneof:"payment"` + "`" + `
+}
+
+type Order_Item struct {
+	Sku string ` + "`" + `protobuf:"bytes,1,opt,name=sku,proto3" json:"sku,omitempty"` + "`" + `
+}
+`,
+	"gen/shop/v1/shop_grpc.pb.go": `// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// source: acme/shop/v1/shop.proto
+
+package shopv1
+
+// OrdersClient is the client API for Orders service.
+type OrdersClient interface {
+	// Places an order.
+	PlaceOrder(ctx context.Context, in *Order, opts ...grpc.CallOption) (*Order, error)
+}
+
+type OrdersServer interface {
+	PlaceOrder(context.Context, *Order) (*Order, error)
+	WatchOrders(*Kind, Orders_WatchOrdersServer) error
+	mustEmbedUnimplementedOrdersServer()
+}
+`,
+	"thrift/shared.thrift": `namespace java com.acme.shared
+namespace py acme.shared
+
+# A failure.
+exception Failure {
+  1: required string message,
+  2: optional i32 code = 500 (go.tag = "code"),
+}
+
+typedef i64 Timestamp
+
+service Base {
+  /** Reports health. */
+  bool ping(),
+}
+`,
+	"thrift/users.thrift": `include "shared.thrift"
+
+namespace * acme.users
+
+const i32 MAX_USERS = 100 // the most users
+
+enum Role {
+  GUEST,
+  ADMIN = 5,
+  OWNER
+}
+
+struct User {
+  1: string name
+  2: Role role = Role.GUEST;
+  3: list<shared.Timestamp> logins
+}
+
+union Who { 1: User user; 2: string email }
+
+service Users extends shared.Base {
+  User get(1: string name) throws (1: shared.Failure failure),
+  oneway void forget(1: string name)
+}
+`,
+	"main.go": `package main
+
+func main() {}
+`,
+}
+
+// languages are the language ids of the tree's files, as the language
+// table gives them.
+var languages = map[string]string{".proto": "proto", ".thrift": "thrift", ".go": "go"}
+
+func TestExtract(t *testing.T) {
+	f := packtest.ExtractTree(t, tree, packtest.ByExt(languages))
+
+	containers := map[string]ir.Container{}
+	byID := map[uuid.UUID]string{}
+	for _, c := range f.Containers {
+		byID[c.Id] = c.FullName
+		containers[c.FullName] = c
+	}
+	for name, want := range map[string]string{
+		"acme.common.v1":                   "proto package v1",
+		"acme.shop.v1":                     "proto package v1",
+		"com.acme.shared":                  "thrift namespace shared",
+		"acme.users":                       "thrift namespace users",
+		"github.com/acme/shop/gen/shop/v1": "go package shopv1",
+	} {
+		c := containers[name]
+		if got := c.Language + " " + c.Kind + " " + c.Name; got != want {
+			t.Errorf("container %s = %q, want %q", name, got, want)
+		}
+	}
+	if len(f.Containers) != 5 {
+		t.Errorf("%d containers", len(f.Containers))
+	}
+	if c := containers["com.acme.shared"]; c.ExtraJson != `{"namespaces":{"java":"com.acme.shared","py":"acme.shared"}}` {
+		t.Errorf("shared extra = %s", c.ExtraJson)
+	}
+	if len(f.Files) != 6 {
+		t.Errorf("%d files", len(f.Files)) // main.go is left out
+	}
+	for _, file := range f.Files {
+		if strings.HasSuffix(file.Path, ".pb.go") != (file.Language == "go") {
+			t.Errorf("%s language = %s", file.Path, file.Language)
+		}
+	}
+	if pack.For("go") != nil {
+		t.Error("the pack registered for go, which a Go pack needs")
+	}
+
+	syms := map[string]ir.Symbol{}
+	names := map[uuid.UUID]string{}
+	for _, s := range f.Symbols {
+		if _, dup := syms[s.FullName]; dup {
+			t.Errorf("duplicate symbol %s", s.FullName)
+		}
+		syms[s.FullName] = s
+		names[s.Id] = s.FullName
+	}
+	const gen = "github.com/acme/shop/gen/shop/v1."
+	for name, want := range map[string]string{
+		"acme.common.v1.Money":                      "message",
+		"acme.common.v1.Money.currency":             "field",
+		"acme.shop.v1.Order":                        "message",
+		"acme.shop.v1.Order.State":                  "enum",
+		"acme.shop.v1.Order.State.STATE_PLACED":     "enum_member",
+		"acme.shop.v1.Order.Item":                   "message",
+		"acme.shop.v1.Order.Item.price":             "field",
+		"acme.shop.v1.Order.voucher":                "field",
+		"acme.shop.v1.Kind":                         "enum",
+		"acme.shop.v1.Orders":                       "service",
+		"acme.shop.v1.Orders.WatchOrders":           "rpc",
+		"com.acme.shared.Failure":                   "exception",
+		"com.acme.shared.Timestamp":                 "typedef",
+		"com.acme.shared.Base.ping":                 "rpc",
+		"acme.users.MAX_USERS":                      "constant",
+		"acme.users.Role.OWNER":                     "enum_member",
+		"acme.users.User":                           "struct",
+		"acme.users.Who":                            "union",
+		"acme.users.Who.email":                      "field",
+		"acme.users.Users.forget":                   "rpc",
+		gen + "Kind":                                "type",
+		gen + "Kind_KIND_EXPRESS":                   "constant",
+		gen + "Order_State":                         "type",
+		gen + "Order":                               "struct",
+		gen + "Order.BySku":                         "field",
+		gen + "Order_Item":                          "struct",
+		gen + "OrdersClient":                        "interface",
+		gen + "OrdersServer.WatchOrders":            "method",
+		gen + "OrdersServer.mustEmbedUnimplemented": "",
+	} {
+		if got := syms[name].Kind; got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	if n := len(f.Symbols); n != 62 {
+		t.Errorf("%d symbols", n)
+	}
+
+	order := syms["acme.shop.v1.Order"]
+	if order.StartLine != 13 || order.StartCol != 9 || order.EndLine != 38 || order.DocFmt != "An order of items.\n\nOrders are immutable once placed." ||
+		order.ExtraJson != `{"reserved":["4","8 to 10","legacy"]}` {
+		t.Errorf("Order = %+v", order)
+	}
+	for name, want := range map[string]string{
+		"acme.common.v1.Money.currency":         `{"tag":1}`,
+		"acme.common.v1.Money.nanos":            `{"tag":3,"options":[{"name":"deprecated","value":"true"}]}`,
+		"acme.shop.v1.Order.items":              `{"tag":2,"label":"repeated"}`,
+		"acme.shop.v1.Order.by_sku":             `{"tag":5,"map_key":"string","map_value":"Item"}`,
+		"acme.shop.v1.Order.voucher":            `{"tag":11,"oneof":"payment"}`,
+		"acme.shop.v1.Order.State.STATE_PLACED": `{"value":1}`,
+		"com.acme.shared.Failure.code":          `{"tag":2,"label":"optional","default":"500","options":[{"name":"go.tag","value":"code"}]}`,
+		"acme.users.Role.OWNER":                 `{"value":6}`,
+		"acme.users.Users.forget":               `{"modifiers":["oneway"]}`,
+	} {
+		if got := syms[name].ExtraJson; got != want {
+			t.Errorf("%s extra = %s, want %s", name, got, want)
+		}
+	}
+	for name, want := range map[string]string{
+		"acme.common.v1.Money.currency":   "ISO 4217",
+		"acme.shop.v1.Kind":               "The kinds of order.",
+		"acme.shop.v1.Orders.PlaceOrder":  "Places an order.",
+		"com.acme.shared.Failure":         "A failure.",
+		"com.acme.shared.Base.ping":       "Reports health.",
+		"acme.users.MAX_USERS":            "the most users",
+		gen + "Order_State":               "The state of an order.",
+		gen + "OrdersClient.PlaceOrder":   "Places an order.",
+		"acme.shop.v1.Orders.WatchOrders": "",
+	} {
+		if got := syms[name].DocFmt; got != want {
+			t.Errorf("%s doc = %q, want %q", name, got, want)
+		}
+	}
+
+	members := map[string]string{}
+	for _, m := range f.Members {
+		members[names[m.ChildSymbolId]] = fmt.Sprintf("%s %d", names[m.OwnerSymbolId], m.Order)
+	}
+	for child, owner := range map[string]string{
+		"acme.shop.v1.Order.Item":         "acme.shop.v1.Order 1",
+		"acme.shop.v1.Order.voucher":      "acme.shop.v1.Order 8",
+		"acme.shop.v1.Order.Item.price":   "acme.shop.v1.Order.Item 1",
+		"acme.shop.v1.Orders.WatchOrders": "acme.shop.v1.Orders 1",
+		"acme.users.Role.ADMIN":           "acme.users.Role 1",
+		gen + "Order.BySku":               gen + "Order 2",
+		gen + "OrdersServer.WatchOrders":  gen + "OrdersServer 1",
+		gen + "Kind_KIND_EXPRESS":         "",
+	} {
+		if members[child] != owner {
+			t.Errorf("owner of %s = %q, want %q", child, members[child], owner)
+		}
+	}
+
+	sigs := map[uuid.UUID]ir.Signature{}
+	for _, s := range f.Signatures {
+		sigs[s.SymbolId] = s
+	}
+	for name, want := range map[string]string{
+		"acme.shop.v1.Order.by_sku":       "map<string, Item> by_sku = 5",
+		"acme.shop.v1.Orders.WatchOrders": "rpc WatchOrders(.acme.shop.v1.Kind) returns (stream Order)",
+		"acme.users.Users":                "service Users extends shared.Base",
+		"acme.users.Users.get":            "User get(1: string name) throws (1: shared.Failure failure)",
+		"acme.users.User.role":            "2: Role role = Role.GUEST",
+		gen + "Order.BySku":               "BySku map[string]*Order_Item",
+		gen + "Kind_KIND_EXPRESS":         "Kind_KIND_EXPRESS Kind = 1",
+		gen + "OrdersClient":              "type OrdersClient interface",
+	} {
+		if got := sigs[syms[name].Id].Text; got != want {
+			t.Errorf("%s sig = %s, want %s", name, got, want)
+		}
+	}
+	for name, want := range map[string]string{
+		"acme.shop.v1.Orders.WatchOrders": `{"params":[{"type":".acme.shop.v1.Kind"}],"results":[{"type":"Order","stream":true}]}`,
+		"acme.users.Users.get": `{"params":[{"name":"name","type":"string","tag":1}],"results":[{"type":"User"}],` +
+			`"throws":[{"name":"failure","type":"shared.Failure","tag":1}]}`,
+		"acme.shop.v1.Order": "",
+	} {
+		if got := sigs[syms[name].Id].Json; got != want {
+			t.Errorf("%s sig json = %s\nwant %s", name, got, want)
+		}
+	}
+
+	refs := map[string][]string{}
+	for _, tr := range f.Typerefs {
+		key := names[tr.OwnerSymbolId] + " " + tr.Slot
+		refs[key] = append(refs[key], tr.Json)
+	}
+	for key, want := range map[string]string{
+		"acme.shop.v1.Order.Item field:price":     `{"symbol":"acme.common.v1.Money","type":"message","text":"acme.common.v1.Money"}`,
+		"acme.shop.v1.Order field:by_sku":         `{"symbol":"acme.shop.v1.Order.Item","type":"message","text":"map\u003cstring, Item\u003e"}`,
+		"acme.shop.v1.Order field:placed_at":      `{"symbol":"google.protobuf.Timestamp","text":"google.protobuf.Timestamp"}`,
+		"acme.shop.v1.Order field:id":             "",
+		"acme.shop.v1.Orders.WatchOrders param:0": `{"symbol":"acme.shop.v1.Kind","type":"enum","text":".acme.shop.v1.Kind"}`,
+		"acme.users.User field:logins":            `{"symbol":"com.acme.shared.Timestamp","type":"typedef","text":"list\u003cshared.Timestamp\u003e"}`,
+		"acme.users.Users.get throws:0":           `{"symbol":"com.acme.shared.Failure","type":"exception","text":"shared.Failure"}`,
+	} {
+		if got := strings.Join(refs[key], " "); got != want {
+			t.Errorf("typerefs %s = %s, want %s", key, got, want)
+		}
+	}
+
+	var rels []string
+	for _, r := range f.Relations {
+		rels = append(rels, names[r.SourceSymbolId]+" "+r.Relation+" "+names[r.DstSymbolId])
+	}
+	sort.Strings(rels)
+	if got, want := strings.Join(rels, "\n"), strings.Join([]string{
+		"acme.users.Users extends com.acme.shared.Base",
+		gen + "Kind generated_from acme.shop.v1.Kind",
+		gen + "Kind_KIND_EXPRESS generated_from acme.shop.v1.Kind.KIND_EXPRESS",
+		gen + "Kind_KIND_UNSPECIFIED generated_from acme.shop.v1.Kind.KIND_UNSPECIFIED",
+		gen + "Order generated_from acme.shop.v1.Order",
+		gen + "Order.BySku generated_from acme.shop.v1.Order.by_sku",
+		gen + "Order.Id generated_from acme.shop.v1.Order.id",
+		gen + "Order.Items generated_from acme.shop.v1.Order.items",
+		gen + "Order_Item generated_from acme.shop.v1.Order.Item",
+		gen + "Order_Item.Sku generated_from acme.shop.v1.Order.Item.sku",
+		gen + "Order_STATE_PLACED generated_from acme.shop.v1.Order.State.STATE_PLACED",
+		gen + "Order_STATE_UNSPECIFIED generated_from acme.shop.v1.Order.State.STATE_UNSPECIFIED",
+		gen + "Order_State generated_from acme.shop.v1.Order.State",
+		gen + "OrdersClient generated_from acme.shop.v1.Orders",
+		gen + "OrdersClient.PlaceOrder generated_from acme.shop.v1.Orders.PlaceOrder",
+		gen + "OrdersServer generated_from acme.shop.v1.Orders",
+		gen + "OrdersServer.PlaceOrder generated_from acme.shop.v1.Orders.PlaceOrder",
+		gen + "OrdersServer.WatchOrders generated_from acme.shop.v1.Orders.WatchOrders",
+	}, "\n"); got != want {
+		t.Errorf("relations =\n%s\nwant\n%s", got, want)
+	}
+	for _, r := range f.Relations {
+		if names[r.SourceSymbolId] == gen+"OrdersServer" && r.DetailsJson != `{"file":"gen/shop/v1/shop_grpc.pb.go","line":12}` {
+			t.Errorf("OrdersServer details = %s", r.DetailsJson)
+		}
+	}
+
+	var imports []string
+	for _, im := range f.Imports {
+		imports = append(imports, byID[im.ContainerId]+" "+im.Target+" "+im.DetailsJson)
+	}
+	sort.Strings(imports)
+	if got, want := strings.Join(imports, "\n"), strings.Join([]string{
+		`acme.shop.v1 acme/common/v1/money.proto {"file":"proto/acme/shop/v1/shop.proto","line":5,"resolved":"proto/acme/common/v1/money.proto"}`,
+		`acme.shop.v1 google/protobuf/timestamp.proto {"file":"proto/acme/shop/v1/shop.proto","line":6,"kind":"public","stdlib":true}`,
+		`acme.users shared.thrift {"file":"thrift/users.thrift","line":1,"kind":"include","resolved":"thrift/shared.thrift"}`,
+	}, "\n"); got != want {
+		t.Errorf("imports =\n%s\nwant\n%s", got, want)
+	}
+}
+
+// A proto package and a Thrift namespace of the same name are containers of
+// different languages, and both are stored.
+func TestSharedNamespace(t *testing.T) {
+	ctx := context.Background()
+	root := packtest.WriteTree(t, map[string]string{
+		"a.proto":  "syntax = \"proto3\";\npackage p;\nmessage A {}\n",
+		"b.thrift": "namespace go p\nstruct B {}\n",
+	})
+	rdb, err := db.Open(ctx, filepath.Join(t.TempDir(), db.FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rdb.Close()
+	st := store.NewSQLite(rdb)
+	if err := st.Migrate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.WriteProject(ctx, &ir.Project{Id: uuid.New(), Name: "idl", RootUri: root}); err != nil {
+		t.Fatal(err)
+	}
+	units := pack.Units(root, []string{"a.proto", "b.thrift"}, packtest.ByExt(languages))
+	s, err := pack.Run(ctx, st, units, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil || s.Failed != 0 {
+		t.Fatalf("Run = %+v, %v; want no failures", s, err)
+	}
+	var n int
+	if err := rdb.QueryRow(`SELECT count(DISTINCT p.container_id) FROM package p WHERE p.import_path = 'p'`).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("containers of p = %d, want one per language", n)
+	}
+}
+
+func TestParseProto(t *testing.T) {
+	f := parseProto(`syntax = "proto2";
+package a.b;
+option java_package = "com.a.b";
+
+message M {
+  optional int32 x = 1 [default = 7];
+  repeated group Result = 2 {
+    required string url = 3;
+  }
+  extensions 100 to max;
+}
+
+// Extends M.
+extend M {
+  optional string note = 100; // A note.
+}
+`)
+	if f.syntax != "proto2" || f.pkg != "a.b" || len(f.options) != 1 || f.options[0] != (option{"java_package", "com.a.b"}) {
+		t.Fatalf("file = %+v", f)
+	}
+	var got []string
+	var walk func(prefix string, ds []*decl)
+	walk = func(prefix string, ds []*decl) {
+		for _, d := range ds {
+			got = append(got, fmt.Sprintf("%s %s%s %s %q %q", d.kind, prefix, d.name, d.typ, d.value+d.extendee, cleanComment(d.doc)))
+			walk(prefix+d.name+".", d.children)
+		}
+	}
+	walk("", f.decls)
+	want := []string{
+		`message M  "" ""`,
+		`field M.x int32 "7" ""`,
+		`message M.Result  "" ""`,
+		`field M.Result.url string "" ""`,
+		`field M.result Result "" ""`,
+		`field note string "M" "A note."`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("decls =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestLex(t *testing.T) {
+	src := "// Doc one.\n/* Doc\n * two. */\nmessage M { // trailing\n\n  // detached\n\n  int32 x = -1; # not a comment\n}\n"
+	toks := lex(src, false)
+	var got []string
+	for _, tok := range toks {
+		got = append(got, tok.text)
+	}
+	want := []string{"message", "M", "{", "int32", "x", "=", "-1", ";", "#", "not", "a", "comment", "}"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("tokens = %q, want %q", got, want)
+	}
+	if d := cleanComment(toks[0].doc); d != "Doc one.\nDoc\ntwo." {
+		t.Errorf("doc = %q", d)
+	}
+	if toks[2].trail != "// trailing" || toks[3].doc != "" {
+		t.Errorf("trail = %q, doc = %q", toks[2].trail, toks[3].doc)
+	}
+	if toks := lex("a # b\nc", true); len(toks) != 2 || toks[0].trail != "# b" {
+		t.Errorf("thrift tokens = %+v", toks)
+	}
+}
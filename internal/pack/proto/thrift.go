@@ -0,0 +1,301 @@
+package proto
+
+import "strings"
+
+// parseThrift outlines a .thrift source.
+func parseThrift(src string) *file {
+	p := &parser{src: src, toks: lex(src, true)}
+	f := &file{namespaces: map[string]string{}}
+	for !p.eof() {
+		start := p.i
+		switch t := p.peek(0); {
+		case t.is("include") || t.is("cpp_include"):
+			p.i++
+			f.imports = append(f.imports, importDecl{path: unquote(p.peek(0).text), kind: t.text, line: t.line})
+			p.i++
+		case t.is("namespace") || t.is("cpp_namespace") || t.is("php_namespace") || t.is("py_module"):
+			p.i++
+			lang := "cpp"
+			if t.is("namespace") {
+				lang = p.peek(0).text
+				p.i++
+			} else if t.text != "cpp_namespace" {
+				lang = strings.TrimSuffix(strings.TrimSuffix(t.text, "_namespace"), "_module")
+			}
+			ns := strings.TrimPrefix(p.name(), ".")
+			if len(f.namespaces) == 0 {
+				f.pkg = ns // without a namespace for every language, the one listed first
+			}
+			f.namespaces[lang] = ns
+		default:
+			if d := p.thriftDecl(); d != nil {
+				f.decls = append(f.decls, d)
+			}
+		}
+		p.annotations()
+		p.accept(";")
+		p.accept(",")
+		if p.i == start {
+			p.i++
+		}
+	}
+	if ns := f.namespaces["*"]; ns != "" {
+		f.pkg = ns
+	}
+	return f
+}
+
+// annotations skips the (key = "value", ...) annotations Thrift allows
+// after types and declarations, returning them.
+func (p *parser) annotations() []option {
+	if !p.peek(0).is("(") {
+		return nil
+	}
+	return p.options()
+}
+
+// thriftType reads a type: a name, with the arguments of a container
+// type, and its annotations.
+func (p *parser) thriftType() string {
+	start := p.i
+	p.name()
+	if p.peek(0).is("<") {
+		p.group()
+	}
+	end := p.i
+	p.annotations()
+	return p.text(start, end)
+}
+
+// thriftDecl parses a definition at p.i, or skips what it does not know.
+func (p *parser) thriftDecl() *decl {
+	start := p.i
+	t := p.peek(0)
+	switch t.text {
+	case "typedef":
+		p.i++
+		d := &decl{kind: "typedef", doc: p.docAt(start)}
+		d.typ = p.thriftType()
+		d.name = p.peek(0).text
+		p.at(d, p.i)
+		p.i++
+		d.sig = p.text(start, p.i)
+		p.end(d)
+		return d
+	case "const":
+		p.i++
+		d := &decl{kind: "constant", doc: p.docAt(start)}
+		d.typ = p.thriftType()
+		d.name = p.peek(0).text
+		p.at(d, p.i)
+		p.i++
+		if p.accept("=") {
+			from := p.i
+			p.value()
+			d.value = unquoteValue(p.text(from, p.i))
+		}
+		d.sig = p.text(start, p.i)
+		p.end(d)
+		if d.doc == "" {
+			d.doc = p.toks[p.i-1].trail
+		}
+		return d
+	case "enum", "senum":
+		d := &decl{kind: "enum", name: p.peek(1).text, doc: p.docAt(start)}
+		p.at(d, p.i+1)
+		p.i += 2
+		d.sig = p.text(start, p.i)
+		if !p.accept("{") {
+			return nil
+		}
+		next := 0
+		for !p.eof() && !p.peek(0).is("}") {
+			if p.peek(0).kind != tIdent && p.peek(0).kind != tString {
+				p.i++
+				continue
+			}
+			from := p.i
+			c := &decl{kind: "enum_member", name: unquote(p.peek(0).text), doc: p.docAt(from)}
+			p.at(c, p.i)
+			p.i++
+			if p.accept("=") {
+				c.tag = atoi(p.peek(0).text)
+				p.i++
+			}
+			if c.tag == nil && t.text == "enum" {
+				n := next
+				c.tag = &n
+			}
+			if c.tag != nil {
+				next = *c.tag + 1
+			}
+			c.sig = p.text(from, p.i)
+			p.annotations()
+			if !p.accept(",") {
+				p.accept(";")
+			}
+			p.end(c)
+			if c.doc == "" {
+				c.doc = p.toks[p.i-1].trail
+			}
+			d.children = append(d.children, c)
+		}
+		p.accept("}")
+		p.end(d)
+		return d
+	case "struct", "union", "exception":
+		d := &decl{kind: t.text, name: p.peek(1).text, doc: p.docAt(start)}
+		p.at(d, p.i+1)
+		p.i += 2
+		p.accept("xsd_all")
+		d.sig = p.text(start, p.i)
+		if !p.accept("{") {
+			return nil
+		}
+		for !p.eof() && !p.peek(0).is("}") {
+			before := p.i
+			if f := p.thriftField(); f != nil {
+				d.children = append(d.children, f)
+			}
+			if p.i == before {
+				p.i++
+			}
+		}
+		p.accept("}")
+		p.end(d)
+		return d
+	case "service":
+		d := &decl{kind: "service", name: p.peek(1).text, doc: p.docAt(start)}
+		p.at(d, p.i+1)
+		p.i += 2
+		if p.accept("extends") {
+			d.extends = strings.TrimPrefix(p.name(), ".")
+		}
+		d.sig = p.text(start, p.i)
+		if !p.accept("{") {
+			return nil
+		}
+		for !p.eof() && !p.peek(0).is("}") {
+			before := p.i
+			if fn := p.thriftFunction(); fn != nil {
+				d.children = append(d.children, fn)
+			}
+			if p.i == before {
+				p.i++
+			}
+		}
+		p.accept("}")
+		p.end(d)
+		return d
+	}
+	return nil
+}
+
+// value skips a constant value: a literal, a name, or a list or map.
+func (p *parser) value() {
+	if p.peek(0).is("[") || p.peek(0).is("{") {
+		p.group()
+		return
+	}
+	if p.peek(0).kind == tIdent {
+		p.name()
+		return
+	}
+	p.i++
+}
+
+// thriftField parses a field: [N:] [required|optional] Type name [= default]
+// [annotations] [,|;].
+func (p *parser) thriftField() *decl {
+	start := p.i
+	d := &decl{kind: "field", doc: p.docAt(start)}
+	if p.peek(0).kind == tNumber && p.peek(1).is(":") {
+		d.tag = atoi(p.peek(0).text)
+		p.i += 2
+	}
+	if t := p.peek(0).text; (t == "required" || t == "optional") && p.peek(1).kind == tIdent {
+		d.label = t
+		p.i++
+	}
+	if p.peek(0).kind != tIdent {
+		return nil
+	}
+	d.typ = p.thriftType()
+	if p.peek(0).kind != tIdent {
+		return nil
+	}
+	d.name = p.peek(0).text
+	p.at(d, p.i)
+	p.i++
+	if p.accept("=") {
+		from := p.i
+		p.value()
+		d.value = unquoteValue(p.text(from, p.i))
+	}
+	d.sig = p.text(start, p.i)
+	d.options = p.annotations()
+	if !p.accept(",") {
+		p.accept(";")
+	}
+	p.end(d)
+	if d.doc == "" {
+		d.doc = p.toks[p.i-1].trail
+	}
+	return d
+}
+
+// thriftFunction parses a function of a service:
+// [oneway] Type name(fields) [throws (fields)] [annotations] [,|;].
+func (p *parser) thriftFunction() *decl {
+	start := p.i
+	d := &decl{kind: "rpc", doc: p.docAt(start)}
+	if p.accept("oneway") {
+		d.modifiers = append(d.modifiers, "oneway")
+	}
+	if p.peek(0).kind != tIdent {
+		return nil
+	}
+	result := p.thriftType()
+	if p.peek(0).kind != tIdent || !p.peek(1).is("(") {
+		return nil
+	}
+	if result != "void" {
+		d.results = []param{{Type: result}}
+	}
+	d.name = p.peek(0).text
+	p.at(d, p.i)
+	p.i++
+	d.params = p.thriftParams()
+	if p.accept("throws") {
+		d.throws = p.thriftParams()
+	}
+	d.sig = p.text(start, p.i)
+	d.options = p.annotations()
+	if !p.accept(",") {
+		p.accept(";")
+	}
+	p.end(d)
+	if d.doc == "" {
+		d.doc = p.toks[p.i-1].trail
+	}
+	return d
+}
+
+// thriftParams parses the (fields) of a function's parameters or throws.
+func (p *parser) thriftParams() []param {
+	out := []param{}
+	if !p.accept("(") {
+		return out
+	}
+	for !p.eof() && !p.peek(0).is(")") {
+		before := p.i
+		if f := p.thriftField(); f != nil {
+			out = append(out, param{Name: f.name, Type: f.typ, Tag: f.tag})
+		}
+		if p.i == before {
+			p.i++
+		}
+	}
+	p.accept(")")
+	return out
+}
@@ -0,0 +1,439 @@
+package python
+
+import (
+	"regexp"
+	"strings"
+)
+
+// docItem is one entry of a docstring section: a parameter, a return value
+// or a raised exception.
+type docItem struct {
+	Name string `json:"name,omitempty"`
+	Type string `json:"type,omitempty"`
+	Desc string `json:"desc,omitempty"`
+}
+
+// section is a docstring section. Sections of entries have items; others,
+// such as Examples, keep their text.
+type section struct {
+	title string
+	items []docItem
+	text  []string
+}
+
+// doc is a parsed docstring.
+type doc struct {
+	style    string // google, numpy, rest, or "" for plain text
+	text     []string
+	sections []*section
+}
+
+// titles maps the spellings of section titles, lower-cased, to their
+// canonical form.
+var titles = map[string]string{
+	"args": "Args", "arguments": "Args", "parameters": "Args", "params": "Args", "parameter": "Args",
+	"keyword args": "Keyword Args", "keyword arguments": "Keyword Args", "kwargs": "Keyword Args",
+	"other parameters": "Other Parameters", "attributes": "Attributes", "receives": "Receives",
+	"returns": "Returns", "return": "Returns", "yields": "Yields", "yield": "Yields",
+	"raises": "Raises", "raise": "Raises", "exceptions": "Raises", "except": "Raises",
+	"warns": "Warns", "warnings": "Warnings", "warning": "Warning",
+	"note": "Note", "notes": "Notes", "example": "Example", "examples": "Examples",
+	"see also": "See Also", "references": "References", "todo": "Todo",
+	"methods": "Methods", "deprecated": "Deprecated",
+}
+
+// entrySections hold items; the values tell how an entry is named.
+var entrySections = map[string]string{
+	"Args": "name", "Keyword Args": "name", "Other Parameters": "name", "Attributes": "name", "Receives": "name",
+	"Returns": "type", "Yields": "type", "Raises": "type", "Warns": "type",
+}
+
+// cleanDoc removes the indentation of a docstring like inspect.cleandoc:
+// the first line is stripped, the common indentation of the others removed,
+// and leading and trailing blank lines dropped.
+func cleanDoc(raw string) []string {
+	lines := strings.Split(strings.ReplaceAll(strings.ReplaceAll(raw, "\r\n", "\n"), "\t", "        "), "\n")
+	margin := -1
+	for _, l := range lines[1:] {
+		if t := strings.TrimLeft(l, " "); t != "" {
+			if n := len(l) - len(t); margin < 0 || n < margin {
+				margin = n
+			}
+		}
+	}
+	lines[0] = strings.TrimSpace(lines[0])
+	for i := 1; i < len(lines); i++ {
+		if len(lines[i]) >= margin && margin > 0 {
+			lines[i] = lines[i][margin:]
+		}
+		lines[i] = strings.TrimRight(lines[i], " ")
+		if strings.TrimSpace(lines[i]) == "" {
+			lines[i] = ""
+		}
+	}
+	for len(lines) > 0 && lines[0] == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+var (
+	googleTitle = regexp.MustCompile(`^([A-Za-z][A-Za-z ]*):$`)
+	numpyRule   = regexp.MustCompile(`^-{3,}$`)
+	restField   = regexp.MustCompile(`^:(param|parameter|arg|argument|key|keyword|type|returns?|rtype|raises?|except|exception|yields?|ytype|ivar|var|cvar|vartype)\b([^:]*):(.*)$`)
+)
+
+// parseDoc splits a cleaned docstring into its text and sections, detecting
+// the style by the first section header it finds.
+func parseDoc(lines []string) *doc {
+	for i, l := range lines {
+		switch {
+		case restField.MatchString(l):
+			return parseRest(lines)
+		case i+1 < len(lines) && titles[strings.ToLower(strings.TrimSpace(l))] != "" && numpyRule.MatchString(strings.TrimSpace(lines[i+1])):
+			return parseNumpy(lines)
+		case googleTitle.MatchString(l) && titles[strings.ToLower(strings.TrimSuffix(l, ":"))] != "":
+			return parseGoogle(lines)
+		}
+	}
+	return &doc{text: lines}
+}
+
+// parseGoogle parses sections like
+//
+//	Args:
+//	    path (str): Where to write.
+func parseGoogle(lines []string) *doc {
+	d := &doc{style: "google"}
+	var cur *section
+	var body []string
+	flush := func() {
+		if cur != nil {
+			fill(cur, body, googleEntry)
+		}
+		body = nil
+	}
+	for _, l := range lines {
+		if m := googleTitle.FindStringSubmatch(l); m != nil && titles[strings.ToLower(m[1])] != "" {
+			flush()
+			cur = &section{title: titles[strings.ToLower(m[1])]}
+			d.sections = append(d.sections, cur)
+			continue
+		}
+		if cur == nil {
+			d.text = append(d.text, l)
+			continue
+		}
+		if l != "" && l[0] != ' ' {
+			// Back at the margin: the section has ended.
+			flush()
+			cur = nil
+			d.text = append(d.text, l)
+			continue
+		}
+		body = append(body, l)
+	}
+	flush()
+	d.text = trimBlank(d.text)
+	return d
+}
+
+// parseNumpy parses sections like
+//
+//	Parameters
+//	----------
+//	path : str
+//	    Where to write.
+func parseNumpy(lines []string) *doc {
+	d := &doc{style: "numpy"}
+	var cur *section
+	var body []string
+	flush := func() {
+		if cur != nil {
+			fill(cur, body, numpyEntry)
+		}
+		body = nil
+	}
+	for i := 0; i < len(lines); i++ {
+		l := lines[i]
+		if i+1 < len(lines) && l != "" && numpyRule.MatchString(strings.TrimSpace(lines[i+1])) {
+			flush()
+			title := strings.TrimSpace(l)
+			if t := titles[strings.ToLower(title)]; t != "" {
+				title = t
+			}
+			cur = &section{title: title}
+			d.sections = append(d.sections, cur)
+			i++
+			continue
+		}
+		if cur == nil {
+			d.text = append(d.text, l)
+			continue
+		}
+		body = append(body, l)
+	}
+	flush()
+	d.text = trimBlank(d.text)
+	return d
+}
+
+// parseRest parses Sphinx field lists like
+//
+//	:param str path: Where to write.
+//	:raises OSError: If it cannot.
+func parseRest(lines []string) *doc {
+	d := &doc{style: "rest"}
+	get := func(title string) *section {
+		for _, s := range d.sections {
+			if s.title == title {
+				return s
+			}
+		}
+		s := &section{title: title}
+		d.sections = append(d.sections, s)
+		return s
+	}
+	item := func(title, name string) *docItem {
+		s := get(title)
+		for i := range s.items {
+			if s.items[i].Name == name {
+				return &s.items[i]
+			}
+		}
+		s.items = append(s.items, docItem{Name: name})
+		return &s.items[len(s.items)-1]
+	}
+	var last *docItem
+	typed := false // whether last continues a type rather than a description
+	for _, l := range lines {
+		m := restField.FindStringSubmatch(l)
+		if m == nil {
+			if last != nil && strings.HasPrefix(l, " ") {
+				if typed {
+					last.Type = join(last.Type, strings.TrimSpace(l))
+				} else {
+					last.Desc = join(last.Desc, strings.TrimSpace(l))
+				}
+				continue
+			}
+			last = nil
+			if len(d.sections) == 0 {
+				d.text = append(d.text, l)
+			}
+			continue
+		}
+		arg, value := strings.Fields(m[2]), strings.TrimSpace(m[3])
+		name := ""
+		if len(arg) > 0 {
+			name = arg[len(arg)-1]
+		}
+		typed = false
+		switch m[1] {
+		case "param", "parameter", "arg", "argument", "key", "keyword":
+			title := "Args"
+			if m[1] == "key" || m[1] == "keyword" {
+				title = "Keyword Args"
+			}
+			last = item(title, name)
+			last.Desc = value
+			if len(arg) > 1 {
+				last.Type = strings.Join(arg[:len(arg)-1], " ")
+			}
+		case "type":
+			last, typed = item("Args", name), true
+			last.Type = value
+		case "ivar", "var", "cvar":
+			last = item("Attributes", name)
+			last.Desc = value
+		case "vartype":
+			last, typed = item("Attributes", name), true
+			last.Type = value
+		case "returns", "return":
+			last = item("Returns", "")
+			last.Desc = value
+		case "rtype":
+			last, typed = item("Returns", ""), true
+			last.Type = value
+		case "yields", "yield":
+			last = item("Yields", "")
+			last.Desc = value
+		case "ytype":
+			last, typed = item("Yields", ""), true
+			last.Type = value
+		default: // raises
+			s := get("Raises")
+			s.items = append(s.items, docItem{Type: name, Desc: value})
+			last = &s.items[len(s.items)-1]
+		}
+	}
+	d.text = trimBlank(d.text)
+	return d
+}
+
+// fill sets the items or text of s from its indented body lines. Entries
+// start at the smallest indentation; deeper lines continue them.
+func fill(s *section, body []string, entry func(head string, named bool) docItem) {
+	body = trimBlank(dedent(body))
+	naming, ok := entrySections[s.title]
+	if !ok {
+		s.text = body
+		return
+	}
+	for _, l := range body {
+		switch {
+		case l == "":
+		case l[0] != ' ':
+			s.items = append(s.items, entry(l, naming == "name"))
+		case len(s.items) > 0:
+			it := &s.items[len(s.items)-1]
+			it.Desc = join(it.Desc, strings.TrimSpace(l))
+		}
+	}
+}
+
+// googleEntry parses "name (type): desc", or "type: desc" for unnamed
+// entries.
+func googleEntry(head string, named bool) docItem {
+	c := strings.Index(head, ":")
+	if c < 0 {
+		if named {
+			return docItem{Name: head}
+		}
+		return docItem{Desc: head}
+	}
+	left, desc := strings.TrimSpace(head[:c]), strings.TrimSpace(head[c+1:])
+	if !named {
+		if strings.ContainsAny(left, " ") && !strings.ContainsAny(left, "[(") {
+			return docItem{Desc: head} // prose, not a type
+		}
+		return docItem{Type: left, Desc: desc}
+	}
+	it := docItem{Name: left, Desc: desc}
+	if o := strings.Index(left, "("); o > 0 && strings.HasSuffix(left, ")") {
+		it.Name, it.Type = strings.TrimSpace(left[:o]), strings.TrimSpace(left[o+1:len(left)-1])
+	}
+	return it
+}
+
+// numpyEntry parses "name : type", or "type" for unnamed entries.
+func numpyEntry(head string, named bool) docItem {
+	if c := strings.Index(head, " : "); c >= 0 || strings.HasSuffix(head, " :") {
+		if c < 0 {
+			c = len(head) - 2
+		}
+		name, typ := strings.TrimSpace(head[:c]), strings.TrimSpace(head[min(c+3, len(head)):])
+		if !named {
+			return docItem{Type: typ, Name: name}
+		}
+		return docItem{Name: name, Type: typ}
+	}
+	if named {
+		return docItem{Name: strings.TrimSpace(head)}
+	}
+	return docItem{Type: strings.TrimSpace(head)}
+}
+
+// format renders a docstring in Google style whatever style it was written
+// in, so docs read and search alike.
+func (d *doc) format() string {
+	var b strings.Builder
+	b.WriteString(strings.Join(d.text, "\n"))
+	for _, s := range d.sections {
+		if len(s.items) == 0 && len(s.text) == 0 {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteString("\n\n")
+		}
+		b.WriteString(s.title + ":")
+		for _, it := range s.items {
+			b.WriteString("\n    ")
+			head := it.Name
+			switch {
+			case head != "" && it.Type != "":
+				head += " (" + it.Type + ")"
+			case head == "":
+				head = it.Type
+			}
+			switch {
+			case head != "" && it.Desc != "":
+				b.WriteString(head + ": " + it.Desc)
+			default:
+				b.WriteString(head + it.Desc)
+			}
+		}
+		for _, l := range s.text {
+			b.WriteString("\n")
+			if l != "" {
+				b.WriteString("    " + l)
+			}
+		}
+	}
+	return b.String()
+}
+
+// summary is the first paragraph of the description, its lines joined.
+func (d *doc) summary() string {
+	var para []string
+	for _, l := range d.text {
+		if strings.TrimSpace(l) == "" {
+			if len(para) > 0 {
+				break
+			}
+			continue
+		}
+		para = append(para, strings.TrimSpace(l))
+	}
+	return strings.Join(para, "\n")
+}
+
+// formatDoc cleans and parses a raw docstring, returning the Google-style
+// text and the parsed doc.
+func formatDoc(raw string) (string, *doc) {
+	if strings.TrimSpace(raw) == "" {
+		return "", nil
+	}
+	d := parseDoc(cleanDoc(raw))
+	return d.format(), d
+}
+
+// dedent removes the common indentation of non-blank lines.
+func dedent(lines []string) []string {
+	margin := -1
+	for _, l := range lines {
+		if t := strings.TrimLeft(l, " "); t != "" {
+			if n := len(l) - len(t); margin < 0 || n < margin {
+				margin = n
+			}
+		}
+	}
+	out := make([]string, len(lines))
+	for i, l := range lines {
+		if len(l) >= margin && margin > 0 {
+			l = l[margin:]
+		}
+		out[i] = l
+	}
+	return out
+}
+
+func trimBlank(lines []string) []string {
+	for len(lines) > 0 && lines[0] == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+func join(a, b string) string {
+	if a == "" {
+		return b
+	}
+	return a + " " + b
+}
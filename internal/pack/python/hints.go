@@ -0,0 +1,180 @@
+package python
+
+import (
+	"strings"
+)
+
+// builtins are names annotations use without importing, and typing
+// constructs; they are not recorded as type references.
+var builtins = map[string]bool{}
+
+func init() {
+	for _, n := range strings.Fields(`
+		None NoneType Ellipsis NotImplemented object type bool int float complex str bytes
+		bytearray memoryview list tuple dict set frozenset range slice property classmethod
+		staticmethod super BaseException Exception self cls`) {
+		builtins[n] = true
+	}
+}
+
+// typingModules hold the typing constructs: Optional, Union, Callable and
+// the like.
+var typingModules = []string{"typing", "typing_extensions", "collections.abc", "builtins"}
+
+// resolver resolves the names of a module to qualified names.
+type resolver struct {
+	module  string            // the module's dotted name
+	imports map[string]string // local name -> qualified name
+	defs    map[string]bool   // top-level definitions
+}
+
+func newResolver(module string, m *module, pkg string) *resolver {
+	n := &resolver{module: module, imports: map[string]string{}, defs: map[string]bool{}}
+	for _, d := range m.defs {
+		n.defs[d.name] = true
+	}
+	for _, im := range m.imports {
+		target := absolute(im, pkg)
+		switch {
+		case im.name == "":
+			if im.alias != "" {
+				n.imports[im.alias] = target
+			} else {
+				top, _, _ := strings.Cut(target, ".")
+				n.imports[top] = top
+			}
+		case im.name != "*":
+			local := im.name
+			if im.alias != "" {
+				local = im.alias
+			}
+			if target == "" {
+				n.imports[local] = im.name
+			} else {
+				n.imports[local] = target + "." + im.name
+			}
+		}
+	}
+	return n
+}
+
+// absolute returns the module an import reads from, resolving relative
+// imports against pkg, the package of the importing module.
+func absolute(im imp, pkg string) string {
+	if im.level == 0 {
+		return im.module
+	}
+	base := pkg
+	for i := 1; i < im.level && base != ""; i++ {
+		if k := strings.LastIndexByte(base, '.'); k >= 0 {
+			base = base[:k]
+		} else {
+			base = ""
+		}
+	}
+	switch {
+	case base == "":
+		return im.module
+	case im.module == "":
+		return base
+	}
+	return base + "." + im.module
+}
+
+// ref is a name an annotation refers to: qualified when it resolves, else
+// as written.
+type ref struct {
+	Symbol string `json:"symbol,omitempty"`
+	Name   string `json:"name,omitempty"`
+	Type   string `json:"type,omitempty"`
+	Text   string `json:"text,omitempty"` // the whole annotation
+}
+
+// refs returns the names an annotation refers to, in order, leaving out
+// builtins and typing constructs.
+func (n *resolver) refs(annotation string) []ref {
+	var out []ref
+	seen := map[string]bool{}
+	for _, name := range dottedNames(annotation) {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		r, ok := n.resolve(name)
+		if ok {
+			r.Text = oneLine(annotation)
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+func (n *resolver) resolve(name string) (ref, bool) {
+	first, rest, dotted := strings.Cut(name, ".")
+	var q string
+	switch {
+	case n.imports[first] != "":
+		q = n.imports[first]
+		if dotted {
+			q += "." + rest
+		}
+	case n.defs[first]:
+		q = n.module + "." + name
+	case builtins[first] && !dotted:
+		return ref{}, false
+	default:
+		return ref{Name: name}, true
+	}
+	for _, t := range typingModules {
+		if strings.HasPrefix(q, t+".") {
+			return ref{}, false
+		}
+	}
+	return ref{Symbol: q}, true
+}
+
+// dottedNames returns the dotted names in an annotation, reading string
+// forward references as code and skipping the values of Literal.
+func dottedNames(s string) []string {
+	var out []string
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '"' || c == '\'':
+			end := skipString(s, i)
+			q := 1
+			if end-i >= 6 && s[i+1] == c && s[i+2] == c {
+				q = 3
+			}
+			if end-i >= 2*q {
+				out = append(out, dottedNames(s[i+q:end-q])...)
+			}
+			i = end
+		case isIdentStart(c):
+			j := i
+			for j < len(s) && (isIdent(s[j]) || s[j] == '.' && j+1 < len(s) && isIdentStart(s[j+1])) {
+				j++
+			}
+			name := s[i:j]
+			out = append(out, name)
+			i = j
+			if name == "Literal" || strings.HasSuffix(name, ".Literal") {
+				for i < len(s) && s[i] == ' ' {
+					i++
+				}
+				if i < len(s) && s[i] == '[' {
+					if c := closing(s, i); c > 0 {
+						i = c + 1
+					}
+				}
+			}
+		case c >= '0' && c <= '9':
+			for i < len(s) && (isIdent(s[i]) || s[i] == '.') {
+				i++
+			}
+		default:
+			i++
+		}
+	}
+	return out
+}
@@ -0,0 +1,193 @@
+package python
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// project is a directory with a pyproject.toml.
+type project struct {
+	dir     string // relative to the input root
+	name    string // distribution name
+	version string
+	roots   []string // source roots, relative to the input root
+}
+
+// layout names the modules of an input root. Modules are named from their
+// source root: the source roots a pyproject.toml declares, or else the
+// nearest ancestor directory that is not a package.
+type layout struct {
+	root     string
+	mu       sync.Mutex
+	projects map[string]*project // by directory; nil where there is none
+	packages map[string]bool     // whether a directory has an __init__.py(i)
+}
+
+func newLayout(root string) *layout {
+	return &layout{root: root, projects: map[string]*project{}, packages: map[string]bool{}}
+}
+
+// moduleName returns the dotted name of the module of a file and the
+// project it belongs to, if any. Package __init__ files name the package.
+func (l *layout) moduleName(rel string) (string, *project) {
+	dir := path.Dir(rel)
+	stem := strings.TrimSuffix(path.Base(rel), path.Ext(rel))
+	pkg, proj := l.packageName(dir)
+	switch {
+	case stem == "__init__":
+		return pkg, proj
+	case pkg == "":
+		return stem, proj
+	}
+	return pkg + "." + stem, proj
+}
+
+// packageName returns the dotted name of the package in dir, "" for a source
+// root.
+func (l *layout) packageName(dir string) (string, *project) {
+	proj := l.project(dir)
+	if proj != nil {
+		for _, r := range proj.roots {
+			if r == proj.dir {
+				continue // the flat layout; packages have __init__ files
+			}
+			if dir == r {
+				return "", proj
+			}
+			if strings.HasPrefix(dir, r+"/") {
+				// Under a source root every directory is a package, as
+				// namespace packages need no __init__.
+				return dotted(strings.TrimPrefix(dir, r+"/")), proj
+			}
+		}
+	}
+	// Climb while the parent is a package too, up to the project.
+	top := dir
+	for top != "." && l.isPackage(top) && (proj == nil || top != proj.dir) {
+		top = path.Dir(top)
+	}
+	if top == dir {
+		return "", proj
+	}
+	if top == "." {
+		return dotted(dir), proj
+	}
+	return dotted(strings.TrimPrefix(dir, top+"/")), proj
+}
+
+func dotted(dir string) string {
+	if dir == "." {
+		return ""
+	}
+	return strings.ReplaceAll(dir, "/", ".")
+}
+
+func (l *layout) isPackage(dir string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if ok, seen := l.packages[dir]; seen {
+		return ok
+	}
+	ok := false
+	for _, name := range []string{"__init__.py", "__init__.pyi"} {
+		if fi, err := os.Stat(filepath.Join(l.root, filepath.FromSlash(dir), name)); err == nil && !fi.IsDir() {
+			ok = true
+			break
+		}
+	}
+	l.packages[dir] = ok
+	return ok
+}
+
+// project returns the nearest project at or above dir, or nil.
+func (l *layout) project(dir string) *project {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.lookup(dir)
+}
+
+func (l *layout) lookup(dir string) *project {
+	if p, seen := l.projects[dir]; seen {
+		return p
+	}
+	p := l.read(dir)
+	if p == nil && dir != "." {
+		p = l.lookup(path.Dir(dir))
+	}
+	l.projects[dir] = p
+	return p
+}
+
+// read parses dir/pyproject.toml. A missing or malformed file is no project.
+func (l *layout) read(dir string) *project {
+	b, err := os.ReadFile(filepath.Join(l.root, filepath.FromSlash(dir), "pyproject.toml"))
+	if err != nil {
+		return nil
+	}
+	var doc map[string]any
+	if err := toml.Unmarshal(b, &doc); err != nil {
+		return nil
+	}
+	p := &project{
+		dir:     dir,
+		name:    firstString(field(doc, "project", "name"), field(doc, "tool", "poetry", "name")),
+		version: firstString(field(doc, "project", "version"), field(doc, "tool", "poetry", "version")),
+	}
+	var roots []string
+	if where, ok := field(doc, "tool", "setuptools", "packages", "find", "where").([]any); ok {
+		for _, w := range where {
+			if s, ok := w.(string); ok {
+				roots = append(roots, s)
+			}
+		}
+	}
+	if s, ok := field(doc, "tool", "setuptools", "package-dir", "").(string); ok {
+		roots = append(roots, s)
+	}
+	if pkgs, ok := field(doc, "tool", "poetry", "packages").([]any); ok {
+		for _, pk := range pkgs {
+			if m, ok := pk.(map[string]any); ok {
+				if s, ok := m["from"].(string); ok {
+					roots = append(roots, s)
+				}
+			}
+		}
+	}
+	if len(roots) == 0 {
+		// The src layout, or the flat layout.
+		if fi, err := os.Stat(filepath.Join(l.root, filepath.FromSlash(dir), "src")); err == nil && fi.IsDir() {
+			roots = append(roots, "src")
+		}
+		roots = append(roots, ".")
+	}
+	for _, r := range roots {
+		p.roots = append(p.roots, path.Join(dir, path.Clean(filepath.ToSlash(r))))
+	}
+	return p
+}
+
+// field walks nested tables by key, returning nil where one is missing.
+func field(v any, keys ...string) any {
+	for _, k := range keys {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		v = m[k]
+	}
+	return v
+}
+
+func firstString(vs ...any) string {
+	for _, v := range vs {
+		if s, ok := v.(string); ok && s != "" {
+			return s
+		}
+	}
+	return ""
+}
@@ -0,0 +1,278 @@
+package python
+
+import "strings"
+
+// logicalLine is a Python logical line: physical lines joined inside
+// brackets or after a backslash, without comments. Strings are kept as
+// written.
+type logicalLine struct {
+	indent  int    // width of the leading whitespace, tabs to multiples of 8
+	line    int    // 1-based line of the first token
+	col     int    // 0-based byte column of the first token
+	endLine int    // 1-based line of the last token
+	endCol  int    // 0-based byte column after the last token
+	text    string // from the first token on; joined lines keep their newlines
+}
+
+// logicalLines splits src into logical lines, skipping blank and
+// comment-only lines.
+func logicalLines(src []byte) []logicalLine {
+	var (
+		out   []logicalLine
+		b     strings.Builder
+		cur   logicalLine
+		depth int
+		line  = 1
+		col   = 0 // byte column of i
+		open  = false
+	)
+	end := func(i int) {
+		cur.text = strings.TrimRight(b.String(), " \t")
+		if cur.text != "" {
+			cur.endLine, cur.endCol = line, col
+			out = append(out, cur)
+		}
+		b.Reset()
+		open, depth = false, 0
+	}
+	for i := 0; i < len(src); {
+		c := src[i]
+		if !open {
+			// Start of a line: measure the indentation.
+			indent, j := 0, i
+			for j < len(src) && (src[j] == ' ' || src[j] == '\t' || src[j] == '\f') {
+				if src[j] == '\t' {
+					indent = indent/8*8 + 8
+				} else if src[j] == ' ' {
+					indent++
+				}
+				j++
+			}
+			col += j - i
+			i = j
+			if i >= len(src) {
+				break
+			}
+			if src[i] == '\n' || src[i] == '\r' || src[i] == '#' {
+				for i < len(src) && src[i] != '\n' {
+					i++
+				}
+				i++
+				line, col = line+1, 0
+				continue
+			}
+			cur = logicalLine{indent: indent, line: line, col: col}
+			open = true
+			continue
+		}
+		switch {
+		case c == '#':
+			for i < len(src) && src[i] != '\n' {
+				i++
+				col++
+			}
+		case c == '\\' && i+1 < len(src) && (src[i+1] == '\n' || src[i+1] == '\r'):
+			i++
+			if src[i] == '\r' && i+1 < len(src) && src[i+1] == '\n' {
+				i++
+			}
+			i++
+			line, col = line+1, 0
+			b.WriteByte(' ')
+		case c == '\r':
+			i++
+		case c == '\n':
+			if depth > 0 {
+				b.WriteByte('\n')
+				i++
+				line, col = line+1, 0
+				continue
+			}
+			end(i)
+			i++
+			line, col = line+1, 0
+		case c == '"' || c == '\'':
+			n, lines, last := scanString(src[i:])
+			b.Write(src[i : i+n])
+			i += n
+			if lines > 0 {
+				line, col = line+lines, last
+			} else {
+				col += n
+			}
+		case isIdentStart(c):
+			j := i
+			for j < len(src) && isIdent(src[j]) {
+				j++
+			}
+			if j < len(src) && (src[j] == '"' || src[j] == '\'') && isStringPrefix(string(src[i:j])) {
+				n, lines, last := scanString(src[j:])
+				b.Write(src[i : j+n])
+				if lines > 0 {
+					line, col = line+lines, last
+				} else {
+					col += j - i + n
+				}
+				i = j + n
+				continue
+			}
+			b.Write(src[i:j])
+			col += j - i
+			i = j
+		default:
+			switch c {
+			case '(', '[', '{':
+				depth++
+			case ')', ']', '}':
+				depth = max(depth-1, 0)
+			}
+			b.WriteByte(c)
+			i++
+			col++
+		}
+	}
+	if open {
+		end(len(src))
+	}
+	return out
+}
+
+// scanString returns the length of the string literal at the start of s,
+// the newlines it spans and the column after it when it spans any. An
+// unterminated single-quoted string ends at the end of its line.
+func scanString(s []byte) (n, lines, lastCol int) {
+	q := s[0]
+	triple := len(s) >= 3 && s[1] == q && s[2] == q
+	i := 1
+	if triple {
+		i = 3
+	}
+	col := i
+	for i < len(s) {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s):
+			if s[i+1] == '\n' {
+				lines++
+				col = -1
+			}
+			i += 2
+			col += 2
+			continue
+		case c == '\n':
+			if !triple {
+				return i, lines, col
+			}
+			lines++
+			i++
+			col = 0
+			continue
+		case c == q && !triple:
+			return i + 1, lines, col + 1
+		case c == q && triple && i+2 < len(s) && s[i+1] == q && s[i+2] == q:
+			return i + 3, lines, col + 3
+		}
+		i++
+		col++
+	}
+	return len(s), lines, col
+}
+
+func isStringPrefix(p string) bool {
+	switch strings.ToLower(p) {
+	case "r", "u", "b", "f", "br", "rb", "fr", "rf", "t", "tr", "rt":
+		return true
+	}
+	return false
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+func isIdent(c byte) bool { return isIdentStart(c) || c >= '0' && c <= '9' }
+
+// skipString returns the index after the string literal starting at s[i],
+// which is a quote.
+func skipString(s string, i int) int {
+	n, _, _ := scanString([]byte(s[i:]))
+	return i + n
+}
+
+// splitTop splits s at sep outside brackets and strings, trimming the parts
+// and dropping empty ones.
+func splitTop(s string, sep byte) []string {
+	var out []string
+	depth, start := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"', '\'':
+			i = skipString(s, i) - 1
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+		case sep:
+			if depth == 0 {
+				if p := strings.TrimSpace(s[start:i]); p != "" {
+					out = append(out, p)
+				}
+				start = i + 1
+			}
+		}
+	}
+	if p := strings.TrimSpace(s[start:]); p != "" {
+		out = append(out, p)
+	}
+	return out
+}
+
+// indexTop returns the index of the first c in s outside brackets and
+// strings, or -1. For '=' it skips comparison and augmented operators, and
+// for ':' the walrus operator.
+func indexTop(s string, c byte) int {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch ch := s[i]; {
+		case ch == '"' || ch == '\'':
+			i = skipString(s, i) - 1
+		case ch == '(' || ch == '[' || ch == '{':
+			depth++
+		case ch == ')' || ch == ']' || ch == '}':
+			depth--
+		case ch == c && depth == 0:
+			next := byte(0)
+			if i+1 < len(s) {
+				next = s[i+1]
+			}
+			if c == '=' && (next == '=' || i > 0 && strings.IndexByte("=!<>+-*/%&|^@:", s[i-1]) >= 0) {
+				i++
+				continue
+			}
+			if c == ':' && next == '=' {
+				continue
+			}
+			return i
+		}
+	}
+	return -1
+}
+
+// closing returns the index of the bracket closing the one at s[open], or -1.
+func closing(s string, open int) int {
+	depth := 0
+	for i := open; i < len(s); i++ {
+		switch s[i] {
+		case '"', '\'':
+			i = skipString(s, i) - 1
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
@@ -0,0 +1,597 @@
+package python
+
+import (
+	"strings"
+)
+
+// module is the outline of one source file.
+type module struct {
+	doc     string   // raw docstring
+	all     []string // names in __all__; nil without one
+	hasAll  bool
+	imports []imp
+	defs    []*def
+}
+
+// imp is one imported name. For "import a.b as c" module is a.b and alias
+// c; for "from .a import b as c" level is 1, module a, name b and alias c.
+type imp struct {
+	level  int
+	module string
+	name   string // "" for plain imports, "*" for star imports
+	alias  string
+	file   string
+	line   int
+}
+
+// def is a definition in a module, class or function header.
+type def struct {
+	kind       string // class, function, method, property, field, constant, variable, type
+	name       string
+	line, col  int // 1-based position of the name
+	endLine    int
+	endCol     int // 1-based, after the last character
+	decorators []string
+	async      bool
+	params     []param
+	returns    string
+	bases      []string
+	annotation string // of fields, constants and variables; the aliased type of types
+	doc        string // raw docstring
+	sig        string
+	overloads  []string // signatures of preceding @overload definitions
+	overload   bool
+	children   []*def
+	stub       *def // the stub definition merged in, if any
+	inStub     bool // defined by the stub alone
+}
+
+type param struct {
+	Name     string `json:"name"`
+	Type     string `json:"type,omitempty"`
+	Default  string `json:"default,omitempty"`
+	Kind     string `json:"kind,omitempty"` // positional_only, keyword_only, var_positional, var_keyword
+	Variadic bool   `json:"variadic,omitempty"`
+}
+
+// scope is what the statements of a block define.
+type scope int
+
+const (
+	moduleScope scope = iota
+	classScope
+)
+
+type parser struct {
+	lines []logicalLine
+	i     int
+	mod   *module
+}
+
+// parse outlines a Python source.
+func parse(src []byte) *module {
+	p := &parser{lines: logicalLines(src), mod: &module{}}
+	if len(p.lines) > 0 {
+		if doc, ok := docstring(p.lines[0].text); ok {
+			p.mod.doc = doc
+			p.i++
+		}
+	}
+	p.mod.defs = p.block(-1, moduleScope)
+	return p.mod
+}
+
+// block parses the statements indented deeper than parent and returns the
+// definitions they make.
+func (p *parser) block(parent int, sc scope) []*def {
+	var defs []*def
+	var decorators []string
+	add := func(d *def) {
+		if d.overload {
+			for _, o := range defs {
+				if o.name == d.name && o.overload {
+					o.overloads = append(o.overloads, d.sig)
+					return
+				}
+			}
+			defs = append(defs, d)
+			return
+		}
+		for k, o := range defs {
+			if o.name != d.name {
+				continue
+			}
+			if o.overload {
+				// The implementation after its overloads.
+				d.overloads = append(o.overloads, o.sig)
+				defs[k] = d
+				return
+			}
+			// Redefined, as in the branches of an if; the first one stands.
+			return
+		}
+		defs = append(defs, d)
+	}
+	for p.i < len(p.lines) {
+		l := p.lines[p.i]
+		if l.indent <= parent {
+			break
+		}
+		text := l.text
+		switch {
+		case strings.HasPrefix(text, "@"):
+			decorators = append(decorators, strings.TrimSpace(text[1:]))
+			p.i++
+			continue
+		case keyword(text, "class"), keyword(text, "def"), keyword(text, "async") && keyword(strings.TrimSpace(text[5:]), "def"):
+			d := p.definition(l, sc, decorators)
+			decorators = nil
+			if d != nil && !setterOrDeleter(d) {
+				add(d)
+			}
+			continue
+		}
+		decorators = nil
+		p.i++
+		switch {
+		case keyword(text, "import"), keyword(text, "from"):
+			p.mod.imports = append(p.mod.imports, parseImport(text, l.line)...)
+		case compound(text):
+			if strings.HasSuffix(text, ":") {
+				// if, try, with and the like do not open a scope.
+				for _, d := range p.block(l.indent, sc) {
+					add(d)
+				}
+			}
+		default:
+			if d := assignment(l, sc); d != nil {
+				if d.name == "__all__" && sc == moduleScope {
+					p.mod.all, p.mod.hasAll = names(text[indexTop(text, '=')+1:]), true
+					continue
+				}
+				d.endLine, d.endCol = l.endLine, l.endCol+1
+				if p.i < len(p.lines) && p.lines[p.i].indent == l.indent {
+					if doc, ok := docstring(p.lines[p.i].text); ok {
+						d.doc = doc // an attribute docstring
+						p.i++
+					}
+				}
+				add(d)
+			}
+		}
+	}
+	return defs
+}
+
+// definition parses a class or function whose header is l, with its body.
+func (p *parser) definition(l logicalLine, sc scope, decorators []string) *def {
+	p.i++
+	text := l.text
+	d := &def{decorators: decorators, line: l.line}
+	if keyword(text, "async") {
+		d.async = true
+		text = strings.TrimSpace(text[5:])
+	}
+	isClass := keyword(text, "class")
+	if isClass {
+		text = strings.TrimSpace(text[5:])
+	} else {
+		text = strings.TrimSpace(text[3:])
+	}
+	n := 0
+	for n < len(text) && isIdent(text[n]) {
+		n++
+	}
+	d.name = text[:n]
+	d.col = l.col + strings.Index(l.text, d.name) + 1
+	rest := strings.TrimSpace(text[n:])
+	typeParams := ""
+	if strings.HasPrefix(rest, "[") {
+		if c := closing(rest, 0); c > 0 {
+			typeParams, rest = rest[:c+1], strings.TrimSpace(rest[c+1:])
+		}
+	}
+	var inline string
+	if isClass {
+		d.kind = "class"
+		if strings.HasPrefix(rest, "(") {
+			if c := closing(rest, 0); c > 0 {
+				d.bases = splitTop(rest[1:c], ',')
+				rest = strings.TrimSpace(rest[c+1:])
+			}
+		}
+		inline = strings.TrimSpace(strings.TrimPrefix(rest, ":"))
+		d.sig = "class " + d.name + typeParams
+		if len(d.bases) > 0 {
+			d.sig += "(" + strings.Join(d.bases, ", ") + ")"
+		}
+	} else {
+		d.kind = "function"
+		if sc == classScope {
+			d.kind = "method"
+		}
+		c := -1
+		if strings.HasPrefix(rest, "(") {
+			c = closing(rest, 0)
+		}
+		if c < 0 {
+			p.skip(l.indent)
+			return nil // a header this parser does not follow
+		}
+		d.params = parseParams(rest[1:c])
+		rest = strings.TrimSpace(rest[c+1:])
+		if strings.HasPrefix(rest, "->") {
+			rest = rest[2:]
+			if k := indexTop(rest, ':'); k >= 0 {
+				d.returns, rest = strings.TrimSpace(rest[:k]), rest[k:]
+			}
+		}
+		inline = strings.TrimSpace(strings.TrimPrefix(rest, ":"))
+		d.sig = "def " + d.name + typeParams + "(" + oneLine(texts(d.params)) + ")"
+		if d.async {
+			d.sig = "async " + d.sig
+		}
+		if d.returns != "" {
+			d.sig += " -> " + oneLine(d.returns)
+		}
+		for _, dec := range decorators {
+			switch decoratorName(dec) {
+			case "property", "cached_property", "functools.cached_property", "abc.abstractproperty":
+				d.kind = "property"
+			case "overload", "typing.overload", "typing_extensions.overload":
+				d.overload = true
+			}
+		}
+	}
+	d.endLine, d.endCol = l.endLine, l.endCol+1
+	if inline != "" {
+		if doc, ok := docstring(inline); ok {
+			d.doc = doc
+		}
+		return d
+	}
+	if p.i < len(p.lines) && p.lines[p.i].indent > l.indent {
+		if doc, ok := docstring(p.lines[p.i].text); ok {
+			d.doc = doc
+			d.endLine, d.endCol = p.lines[p.i].endLine, p.lines[p.i].endCol+1
+			p.i++
+		}
+	}
+	if isClass {
+		start := p.i
+		d.children = p.block(l.indent, classScope)
+		if p.i > start {
+			last := p.lines[p.i-1]
+			d.endLine, d.endCol = last.endLine, last.endCol+1
+		}
+		return d
+	}
+	if last, ok := p.skip(l.indent); ok {
+		d.endLine, d.endCol = last.endLine, last.endCol+1
+	}
+	return d
+}
+
+// skip passes over a function body, keeping its imports, and returns its
+// last line.
+func (p *parser) skip(indent int) (logicalLine, bool) {
+	var last logicalLine
+	ok := false
+	for p.i < len(p.lines) && p.lines[p.i].indent > indent {
+		last, ok = p.lines[p.i], true
+		if keyword(last.text, "import") || keyword(last.text, "from") {
+			p.mod.imports = append(p.mod.imports, parseImport(last.text, last.line)...)
+		}
+		p.i++
+	}
+	return last, ok
+}
+
+// assignment parses a definition made by an assignment statement, or
+// returns nil. Modules define constants, annotated variables and type
+// aliases; classes define fields.
+func assignment(l logicalLine, sc scope) *def {
+	text := l.text
+	if keyword(text, "type") {
+		// A type alias statement: type Name[T] = ...
+		rest := strings.TrimSpace(text[4:])
+		n := 0
+		for n < len(rest) && isIdent(rest[n]) {
+			n++
+		}
+		if eq := indexTop(rest, '='); n > 0 && eq > 0 {
+			name := rest[:n]
+			return &def{
+				kind: "type", name: name, line: l.line, col: l.col + strings.Index(text, name) + 1,
+				annotation: strings.TrimSpace(rest[eq+1:]), sig: "type " + oneLine(strings.TrimSpace(rest[:eq])),
+			}
+		}
+	}
+	target, annotation, value := text, "", ""
+	if eq := indexTop(text, '='); eq >= 0 {
+		target, value = text[:eq], strings.TrimSpace(text[eq+1:])
+	}
+	if c := indexTop(target, ':'); c >= 0 {
+		target, annotation = target[:c], strings.TrimSpace(target[c+1:])
+	} else if value == "" {
+		return nil // an expression statement
+	}
+	name := strings.TrimSpace(target)
+	if !identifier(name) {
+		return nil
+	}
+	d := &def{name: name, line: l.line, col: l.col + 1, annotation: annotation}
+	switch {
+	case sc == classScope:
+		if strings.HasPrefix(name, "__") && strings.HasSuffix(name, "__") {
+			return nil
+		}
+		d.kind = "field"
+	case annotation == "TypeAlias" || annotation == "typing.TypeAlias" || strings.HasPrefix(value, "TypeVar(") || strings.HasPrefix(value, "typing.TypeVar("):
+		d.kind = "type"
+		if annotation != "" {
+			d.annotation = value
+		}
+	case constName(name):
+		d.kind = "constant"
+	case annotation != "" || name == "__all__":
+		d.kind = "variable"
+	default:
+		return nil
+	}
+	d.sig = name
+	if annotation != "" {
+		d.sig += ": " + oneLine(annotation)
+	}
+	return d
+}
+
+// parseImport returns the names an import statement imports.
+func parseImport(text string, line int) []imp {
+	var out []imp
+	if keyword(text, "import") {
+		for _, part := range splitTop(text[6:], ',') {
+			mod, alias := asClause(part)
+			out = append(out, imp{module: mod, alias: alias, line: line})
+		}
+		return out
+	}
+	rest := strings.TrimSpace(text[4:])
+	k := strings.Index(rest, " import")
+	if k < 0 {
+		return nil
+	}
+	from, names := strings.TrimSpace(rest[:k]), strings.TrimSpace(rest[k+7:])
+	level := 0
+	for level < len(from) && from[level] == '.' {
+		level++
+	}
+	names = strings.TrimSuffix(strings.TrimPrefix(names, "("), ")")
+	for _, part := range splitTop(names, ',') {
+		name, alias := asClause(part)
+		out = append(out, imp{level: level, module: from[level:], name: name, alias: alias, line: line})
+	}
+	return out
+}
+
+func asClause(s string) (name, alias string) {
+	f := strings.Fields(s)
+	if len(f) == 3 && f[1] == "as" {
+		return f[0], f[2]
+	}
+	return strings.Join(f, ""), ""
+}
+
+// parseParams parses the text between the parentheses of a def.
+func parseParams(s string) []param {
+	var out []param
+	keywordOnly := false
+	for _, part := range splitTop(s, ',') {
+		switch part {
+		case "/":
+			for i := range out {
+				if out[i].Kind == "" {
+					out[i].Kind = "positional_only"
+				}
+			}
+			continue
+		case "*":
+			keywordOnly = true
+			continue
+		}
+		var pr param
+		if eq := indexTop(part, '='); eq >= 0 {
+			pr.Default = oneLine(strings.TrimSpace(part[eq+1:]))
+			part = strings.TrimSpace(part[:eq])
+		}
+		if c := indexTop(part, ':'); c >= 0 {
+			pr.Type = oneLine(strings.TrimSpace(part[c+1:]))
+			part = strings.TrimSpace(part[:c])
+		}
+		switch {
+		case strings.HasPrefix(part, "**"):
+			pr.Kind, pr.Variadic, part = "var_keyword", true, part[2:]
+		case strings.HasPrefix(part, "*"):
+			pr.Kind, pr.Variadic, part = "var_positional", true, part[1:]
+			keywordOnly = true
+		case keywordOnly:
+			pr.Kind = "keyword_only"
+		}
+		pr.Name = strings.TrimSpace(part)
+		out = append(out, pr)
+	}
+	return out
+}
+
+// texts renders params as they are written.
+func texts(ps []param) string {
+	var parts []string
+	for i, pr := range ps {
+		if pr.Kind == "keyword_only" && (i == 0 || ps[i-1].Kind != "keyword_only" && ps[i-1].Kind != "var_positional") {
+			parts = append(parts, "*")
+		}
+		s := pr.Name
+		switch pr.Kind {
+		case "var_positional":
+			s = "*" + s
+		case "var_keyword":
+			s = "**" + s
+		}
+		if pr.Type != "" {
+			s += ": " + pr.Type
+		}
+		if pr.Default != "" {
+			if pr.Type != "" {
+				s += " = " + pr.Default
+			} else {
+				s += "=" + pr.Default
+			}
+		}
+		parts = append(parts, s)
+		if pr.Kind == "positional_only" && (i == len(ps)-1 || ps[i+1].Kind != "positional_only") {
+			parts = append(parts, "/")
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// docstring returns the contents of a statement that is a single string
+// literal, or a concatenation of them.
+func docstring(text string) (string, bool) {
+	var b strings.Builder
+	s := strings.TrimSpace(text)
+	if s == "" {
+		return "", false
+	}
+	for s != "" {
+		n := 0
+		for n < len(s) && isIdent(s[n]) {
+			n++
+		}
+		prefix := strings.ToLower(s[:n])
+		if n > 0 && (!isStringPrefix(prefix) || strings.ContainsAny(prefix, "bft")) {
+			return "", false
+		}
+		if n >= len(s) || s[n] != '"' && s[n] != '\'' {
+			return "", false
+		}
+		end := skipString(s, n)
+		lit := s[n:end]
+		q := 1
+		if len(lit) >= 6 && (strings.HasPrefix(lit, `"""`) || strings.HasPrefix(lit, "'''")) {
+			q = 3
+		}
+		if len(lit) < 2*q {
+			return "", false
+		}
+		body := lit[q : len(lit)-q]
+		if !strings.Contains(prefix, "r") {
+			body = unescape(body)
+		}
+		b.WriteString(body)
+		s = strings.TrimSpace(s[end:])
+	}
+	return b.String(), true
+}
+
+// unescape resolves the common escapes of a string literal body.
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case '\\', '\'', '"':
+			b.WriteByte(s[i])
+		case '\n':
+		default:
+			b.WriteByte('\\')
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// names returns the string items of a list or tuple display, as in __all__.
+func names(s string) []string {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && (s[0] == '[' || s[0] == '(') {
+		s = s[1 : len(s)-1]
+	}
+	var out []string
+	for _, item := range splitTop(s, ',') {
+		if doc, ok := docstring(item); ok {
+			out = append(out, doc)
+		}
+	}
+	return out
+}
+
+// keyword reports whether text starts with the keyword kw.
+func keyword(text, kw string) bool {
+	return strings.HasPrefix(text, kw) && (len(text) == len(kw) || !isIdent(text[len(kw)]))
+}
+
+// compound reports whether text is the header of a compound statement other
+// than a class or def.
+func compound(text string) bool {
+	for _, kw := range []string{"if", "elif", "else", "try", "except", "finally", "with", "for", "while", "match", "case", "async"} {
+		if keyword(text, kw) {
+			// match and case are soft keywords.
+			return kw != "match" && kw != "case" || strings.HasSuffix(text, ":")
+		}
+	}
+	return false
+}
+
+func setterOrDeleter(d *def) bool {
+	for _, dec := range d.decorators {
+		if strings.HasSuffix(dec, ".setter") || strings.HasSuffix(dec, ".deleter") {
+			return true
+		}
+	}
+	return false
+}
+
+// decoratorName drops the arguments of a decorator.
+func decoratorName(dec string) string {
+	if k := strings.IndexByte(dec, '('); k >= 0 {
+		return strings.TrimSpace(dec[:k])
+	}
+	return dec
+}
+
+func identifier(s string) bool {
+	if s == "" || !isIdentStart(s[0]) {
+		return false
+	}
+	for i := 1; i < len(s); i++ {
+		if !isIdent(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// constName reports whether name is spelled like a constant: upper case,
+// optionally with a leading underscore.
+func constName(name string) bool {
+	name = strings.TrimLeft(name, "_")
+	if name == "" || name[0] < 'A' || name[0] > 'Z' {
+		return false
+	}
+	return strings.ToUpper(name) == name
+}
+
+// oneLine collapses runs of whitespace, as in joined lines.
+func oneLine(s string) string { return strings.Join(strings.Fields(s), " ") }
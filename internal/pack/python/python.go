@@ -0,0 +1,469 @@
+// Package python is the language pack for Python. It reads sources without
+// an interpreter: an indentation-aware outline parser finds the classes,
+// functions, methods, properties, fields and module constants of a module,
+// with their decorators, type hints and docstrings.
+//
+// Modules are named by their dotted path from a source root, found from
+// __init__ files and pyproject.toml. Packages and modules are containers; a
+// module's parent is its package, and __init__ defines the package itself.
+// Type stubs (.pyi) merge into the module they describe: types and
+// signatures come from the stub, locations and docs from the implementation,
+// and definitions only in the stub are added.
+package python
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+
+	"github.com/ChaseHampton/cargoworker/internal/ir"
+	"github.com/ChaseHampton/cargoworker/internal/pack"
+	"github.com/ChaseHampton/cargoworker/internal/pack/outline"
+)
+
+func init() {
+	pack.Register("python", New())
+}
+
+// Pack extracts Python. A package's modules span directories, so its
+// containers are kept in tables, by full name, to parent the modules of
+// later units.
+type Pack struct {
+	mu      sync.Mutex
+	layouts map[string]*layout // by input root
+	tables  *outline.Tables
+}
+
+func New() *Pack {
+	return &Pack{layouts: map[string]*layout{}, tables: outline.NewTables()}
+}
+
+func (p *Pack) Name() string { return "python" }
+
+var _ pack.Pack = (*Pack)(nil)
+
+// source is the files of one module: its implementation, its stub, or both.
+type source struct {
+	py, pyi string
+	pkg     bool // an __init__ file
+	proj    *project
+}
+
+// Extract outlines the modules of a unit.
+func (p *Pack) Extract(ctx context.Context, u pack.Unit) (*ir.Fragment, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	l, ok := p.layouts[u.Root]
+	if !ok {
+		l = newLayout(u.Root)
+		p.layouts[u.Root] = l
+	}
+	x := &extraction{u: u, frag: p.tables.Fragment(u)}
+
+	mods := map[string]*source{}
+	for _, rel := range u.Files {
+		name, proj := l.moduleName(rel)
+		if name == "" {
+			continue // an __init__ at a source root names no package
+		}
+		s, ok := mods[name]
+		if !ok {
+			s = &source{proj: proj}
+			mods[name] = s
+		}
+		if path.Ext(rel) == ".pyi" {
+			s.pyi = rel
+		} else {
+			s.py = rel
+		}
+		s.pkg = strings.HasPrefix(path.Base(rel), "__init__.")
+	}
+	names := make([]string, 0, len(mods))
+	for n := range mods {
+		names = append(names, n)
+	}
+	sort.Strings(names) // packages before their modules
+	for _, n := range names {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err := x.module(n, mods[n]); err != nil {
+			return nil, err
+		}
+	}
+	return x.frag.Fragment, nil
+}
+
+// extraction is the state of one Extract call.
+type extraction struct {
+	u    pack.Unit
+	frag *outline.Fragment
+}
+
+// container returns the container of a package or module, making it and any
+// missing parent packages.
+func (x *extraction) container(full, kind string, proj *project) uuid.UUID {
+	return x.frag.Container(full, func() ir.Container {
+		c := ir.Container{Name: full, FullName: full, Kind: kind}
+		if k := strings.LastIndexByte(full, '.'); k >= 0 {
+			c.Name = full[k+1:]
+			c.ParentId = x.container(full[:k], "package", proj)
+		} else if proj != nil {
+			c.VersionTag = proj.version
+			if proj.name != "" {
+				b, _ := json.Marshal(map[string]string{"distribution": proj.name})
+				c.ExtraJson = string(b)
+			}
+		}
+		return c
+	})
+}
+
+// file reads and records one file of a module.
+func (x *extraction) file(rel string, cid uuid.UUID) (*module, uuid.UUID, error) {
+	src, err := os.ReadFile(filepath.Join(x.u.Root, filepath.FromSlash(rel)))
+	if err != nil {
+		return nil, uuid.Nil, err
+	}
+	fid := x.frag.File(cid, rel, src)
+	m := parse(src)
+	for i := range m.imports {
+		m.imports[i].file = rel
+	}
+	return m, fid, nil
+}
+
+func (x *extraction) module(name string, s *source) error {
+	kind := "module"
+	if s.pkg {
+		kind = "package"
+	}
+	cid := x.container(name, kind, s.proj)
+	var impl, stub *module
+	var implFile, stubFile uuid.UUID
+	var err error
+	if s.py != "" {
+		if impl, implFile, err = x.file(s.py, cid); err != nil {
+			return err
+		}
+	}
+	if s.pyi != "" {
+		if stub, stubFile, err = x.file(s.pyi, cid); err != nil {
+			return err
+		}
+	}
+	m := impl
+	switch {
+	case impl == nil:
+		m = stub
+		markStub(m.defs)
+	case stub != nil:
+		m.defs = merge(impl.defs, stub.defs)
+		m.imports = append(m.imports, stub.imports...)
+		if m.doc == "" {
+			m.doc = stub.doc
+		}
+		if !m.hasAll {
+			m.all, m.hasAll = stub.all, stub.hasAll
+		}
+	}
+
+	if c := x.frag.Made(name); c != nil && m.doc != "" {
+		c.DocRaw = m.doc
+		c.DocFmt, _ = formatDoc(m.doc)
+	}
+	pkg := name
+	if !s.pkg {
+		pkg = ""
+		if k := strings.LastIndexByte(name, '.'); k >= 0 {
+			pkg = name[:k]
+		}
+	}
+	x.imports(cid, m, pkg)
+	e := emitter{x: x, cid: cid, res: newResolver(name, m, pkg), mod: m, implFile: implFile, stubFile: stubFile, s: s}
+	e.defs(nil, name, m.defs, true)
+	return nil
+}
+
+// imports records the modules a module imports, one row per module and
+// alias, with the imported names in its details.
+func (x *extraction) imports(cid uuid.UUID, m *module, pkg string) {
+	type key struct{ target, alias string }
+	type details struct {
+		File   string   `json:"file"`
+		Line   int      `json:"line"`
+		Names  []string `json:"names,omitempty"`
+		Stdlib bool     `json:"stdlib,omitempty"`
+	}
+	var order []key
+	byKey := map[key]*details{}
+	for _, im := range m.imports {
+		k := key{target: absolute(im, pkg)}
+		if im.name == "" {
+			k.alias = im.alias
+		}
+		if k.target == "" {
+			continue
+		}
+		d, ok := byKey[k]
+		if !ok {
+			d = &details{File: im.file, Line: im.line, Stdlib: isStdlib(k.target)}
+			byKey[k] = d
+			order = append(order, k)
+		}
+		if im.name != "" && !slices.Contains(d.Names, im.name) {
+			d.Names = append(d.Names, im.name)
+		}
+	}
+	for _, k := range order {
+		b, _ := json.Marshal(byKey[k])
+		x.frag.Imports = append(x.frag.Imports, ir.Import{ContainerId: cid, Target: k.target, Alias: k.alias, DetailsJson: string(b)})
+	}
+}
+
+// merge merges the definitions of a stub into those of its implementation.
+func merge(impl, stub []*def) []*def {
+	byName := map[string]*def{}
+	for _, d := range stub {
+		byName[d.name] = d
+	}
+	seen := map[string]bool{}
+	out := make([]*def, 0, len(impl))
+	for _, d := range impl {
+		s := byName[d.name]
+		if s == nil {
+			out = append(out, d)
+			continue
+		}
+		seen[d.name] = true
+		d.stub = s
+		switch {
+		case s.kind == "class":
+			if len(s.bases) > 0 {
+				d.bases, d.sig = s.bases, s.sig
+			}
+			d.children = merge(d.children, s.children)
+		case s.kind == "function" || s.kind == "method" || s.kind == "property":
+			params := stubParams(d.params, s.params)
+			d.sig = strings.Replace(s.sig, "("+oneLine(texts(s.params))+")", "("+oneLine(texts(params))+")", 1)
+			d.params, d.returns, d.async = params, s.returns, s.async
+			if len(s.overloads) > 0 {
+				d.overloads = s.overloads
+			}
+			if s.kind == "property" {
+				d.kind = "property"
+			}
+		case s.annotation != "":
+			d.annotation, d.sig = s.annotation, s.sig
+		}
+		for _, dec := range s.decorators {
+			if !slices.Contains(d.decorators, dec) {
+				d.decorators = append(d.decorators, dec)
+			}
+		}
+		if d.doc == "" {
+			d.doc = s.doc
+		}
+		out = append(out, d)
+	}
+	for _, s := range stub {
+		if !seen[s.name] {
+			markStub([]*def{s})
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// stubParams returns the parameters of a stub with the defaults it elides as
+// ... taken from the implementation.
+func stubParams(impl, stub []param) []param {
+	out := slices.Clone(stub)
+	for i, p := range out {
+		if p.Default != "..." {
+			continue
+		}
+		for _, ip := range impl {
+			if ip.Name == p.Name && ip.Default != "" {
+				out[i].Default = ip.Default
+				break
+			}
+		}
+	}
+	return out
+}
+
+func markStub(defs []*def) {
+	for _, d := range defs {
+		d.inStub = true
+		markStub(d.children)
+	}
+}
+
+// emitter turns the definitions of a module into symbols.
+type emitter struct {
+	x                  *extraction
+	cid                uuid.UUID
+	res                *resolver
+	mod                *module
+	implFile, stubFile uuid.UUID
+	s                  *source
+}
+
+// extra is the extra_json of a symbol.
+type extra struct {
+	Decorators []string `json:"decorators,omitempty"`
+	Async      bool     `json:"async,omitempty"`
+	Overloads  []string `json:"overloads,omitempty"`
+	Stub       string   `json:"stub,omitempty"` // the .pyi merged in
+	Doc        *docInfo `json:"doc,omitempty"`
+}
+
+// docInfo is the parsed sections of a docstring, and its summary: the first
+// paragraph of its description.
+type docInfo struct {
+	Style      string    `json:"style,omitempty"` // empty for plain text
+	Summary    string    `json:"summary,omitempty"`
+	Params     []docItem `json:"params,omitempty"`
+	Returns    []docItem `json:"returns,omitempty"`
+	Raises     []docItem `json:"raises,omitempty"`
+	Attributes []docItem `json:"attributes,omitempty"`
+}
+
+func (e *emitter) defs(owner *uuid.UUID, prefix string, defs []*def, top bool) {
+	for i, d := range defs {
+		id := uuid.New()
+		file := e.implFile
+		if d.inStub {
+			file = e.stubFile
+		}
+		sym := ir.Symbol{
+			Id: id, ContainerId: e.cid, Name: d.name, FullName: prefix + "." + d.name, Kind: d.kind,
+			Visibility: e.visibility(d, top), OriginFileId: file,
+			StartLine: d.line, StartCol: d.col, EndLine: d.endLine, EndCol: d.endCol,
+			DocRaw: d.doc,
+		}
+		ex := extra{Decorators: d.decorators, Async: d.async, Overloads: d.overloads}
+		if d.stub != nil {
+			ex.Stub = e.s.pyi
+		}
+		if d.doc != "" {
+			var parsed *doc
+			sym.DocFmt, parsed = formatDoc(d.doc)
+			ex.Doc = parsed.info()
+		}
+		if b, _ := json.Marshal(ex); string(b) != "{}" {
+			sym.ExtraJson = string(b)
+		}
+		e.x.frag.Symbols = append(e.x.frag.Symbols, sym)
+		if owner != nil {
+			e.x.frag.Members = append(e.x.frag.Members, ir.Member{Id: uuid.New(), OwnerSymbolId: *owner, ChildSymbolId: id, Order: i})
+		}
+		e.signature(id, owner, d)
+		if d.kind == "class" {
+			e.defs(&id, sym.FullName, d.children, false)
+		}
+	}
+}
+
+// signature records the signature and type references of a definition.
+func (e *emitter) signature(id uuid.UUID, owner *uuid.UUID, d *def) {
+	if d.sig == "" {
+		return
+	}
+	sig := ir.Signature{SymbolId: id, Text: d.sig}
+	switch d.kind {
+	case "function", "method", "property":
+		type result struct {
+			Type string `json:"type"`
+		}
+		js := struct {
+			Params  []param  `json:"params"`
+			Results []result `json:"results,omitempty"`
+		}{Params: d.params}
+		if js.Params == nil {
+			js.Params = []param{}
+		}
+		if d.returns != "" {
+			js.Results = []result{{oneLine(d.returns)}}
+		}
+		b, _ := json.Marshal(js)
+		sig.Json = string(b)
+		for i, pr := range d.params {
+			e.typerefs(id, fmt.Sprintf("param:%d", i), pr.Type, "")
+		}
+		e.typerefs(id, "result:0", d.returns, "")
+	case "class":
+		for i, b := range d.bases {
+			if !strings.Contains(b, "=") { // not a keyword such as metaclass=
+				e.typerefs(id, fmt.Sprintf("base:%d", i), b, "class")
+			}
+		}
+	case "field":
+		if owner != nil {
+			e.typerefs(*owner, "field:"+d.name, d.annotation, "")
+		}
+	default:
+		e.typerefs(id, "type", d.annotation, "")
+	}
+	e.x.frag.Signatures = append(e.x.frag.Signatures, sig)
+}
+
+func (e *emitter) typerefs(owner uuid.UUID, slot, annotation, kind string) {
+	if annotation == "" {
+		return
+	}
+	for i, r := range e.res.refs(annotation) {
+		r.Type = kind
+		b, _ := json.Marshal(r)
+		e.x.frag.Typerefs = append(e.x.frag.Typerefs, ir.Typeref{Id: uuid.New(), OwnerSymbolId: owner, Slot: slot, Json: string(b), Order: i})
+	}
+}
+
+// visibility follows Python's conventions: a module's __all__ lists its
+// public names, and otherwise a leading underscore marks a private one.
+func (e *emitter) visibility(d *def, top bool) string {
+	if top && e.mod.hasAll {
+		if slices.Contains(e.mod.all, d.name) {
+			return "public"
+		}
+		return "private"
+	}
+	if strings.HasPrefix(d.name, "_") && !(strings.HasPrefix(d.name, "__") && strings.HasSuffix(d.name, "__")) {
+		return "private"
+	}
+	return "public"
+}
+
+// info returns the entry sections of a doc, or nil for plain text.
+func (d *doc) info() *docInfo {
+	if d == nil {
+		return nil
+	}
+	in := &docInfo{Style: d.style, Summary: d.summary()}
+	if in.Style == "" && in.Summary == "" {
+		return nil
+	}
+	for _, s := range d.sections {
+		switch s.title {
+		case "Args", "Keyword Args", "Other Parameters":
+			in.Params = append(in.Params, s.items...)
+		case "Returns", "Yields":
+			in.Returns = append(in.Returns, s.items...)
+		case "Raises":
+			in.Raises = append(in.Raises, s.items...)
+		case "Attributes":
+			in.Attributes = append(in.Attributes, s.items...)
+		}
+	}
+	return in
+}
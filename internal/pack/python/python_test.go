@@ -0,0 +1,352 @@
+package python
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/ChaseHampton/cargoworker/internal/ir"
+	"github.com/ChaseHampton/cargoworker/internal/pack"
+	"github.com/ChaseHampton/cargoworker/internal/pack/packtest"
+)
+
+var tree = map[string]string{
+	"pyproject.toml": `[project]
+name = "acme-shop"
+version = "1.4.0"
+`,
+	"src/shop/__init__.py": `"""The shop."""
+from .cart import Cart as Cart
+
+__all__ = ["Cart", "VERSION"]
+VERSION = "1.4.0"
+`,
+	"src/shop/cart.py": `"""Shopping carts."""
+from __future__ import annotations
+
+import json
+from typing import Optional
+from . import pricing
+from .pricing import Price
+
+MAX_ITEMS = 100
+_cache = {}
+
+
+class Cart(pricing.Base, metaclass=abc.ABCMeta):
+    """A cart.
+
+    Attributes:
+        owner (str): Who shops.
+    """
+
+    owner: str
+    items: list[Item] = []
+    """Items in order."""
+
+    def __init__(self, owner, *, limit=MAX_ITEMS):
+        self.owner = owner
+        def inner():
+            import os
+
+    @property
+    def total(self) -> Price:
+        return sum(i.price for i in self.items)
+
+    @total.setter
+    def total(self, value):
+        pass
+
+    @staticmethod
+    async def load(path: str = ")", /, *args: "Item", **kw) -> Optional["Cart"]:
+        """Load a cart.
+
+        Parameters
+        ----------
+        path : str
+            Where from.
+
+        Returns
+        -------
+        Cart or None
+        """
+        data = json.loads(
+            open(path).read()
+        )
+
+    def _check(self): ...
+
+
+if TYPE_CHECKING:
+    class Item:
+        pass
+else:
+    Item = dict
+
+
+def checkout(cart):
+    '''Check out.
+
+    :param Cart cart: The cart.
+    :raises ValueError: If empty.
+    '''
+`,
+	"src/shop/cart.pyi": `from .pricing import Price
+
+class Cart:
+    def __init__(self, owner: str, *, limit: int = ...) -> None: ...
+    def merge(self, other: Cart) -> Cart: ...
+
+def checkout(cart: Cart) -> Price: ...
+`,
+}
+
+func TestExtract(t *testing.T) {
+	root := packtest.WriteTree(t, tree)
+	p := New()
+	f, err := p.Extract(context.Background(), pack.Unit{
+		Root: root, Dir: "src/shop", Language: "python",
+		Files: []string{"src/shop/__init__.py", "src/shop/cart.py", "src/shop/cart.pyi"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	containers := map[string]ir.Container{}
+	for _, c := range f.Containers {
+		containers[c.FullName] = c
+	}
+	pkg, mod := containers["shop"], containers["shop.cart"]
+	if pkg.Kind != "package" || pkg.ParentId != uuid.Nil || pkg.VersionTag != "1.4.0" || pkg.DocFmt != "The shop." ||
+		pkg.ExtraJson != `{"distribution":"acme-shop"}` {
+		t.Errorf("package = %+v", pkg)
+	}
+	if mod.Kind != "module" || mod.ParentId != pkg.Id || mod.Name != "cart" || mod.DocRaw != "Shopping carts." {
+		t.Errorf("module = %+v", mod)
+	}
+	if len(f.Files) != 3 {
+		t.Errorf("files = %d, want 3", len(f.Files))
+	}
+
+	syms := map[string]ir.Symbol{}
+	for _, s := range f.Symbols {
+		if _, dup := syms[s.FullName]; dup {
+			t.Errorf("duplicate symbol %s", s.FullName)
+		}
+		syms[s.FullName] = s
+	}
+	kinds := map[string]string{
+		"shop.Cart":                     "", // an import, not a definition
+		"shop.VERSION":                  "constant",
+		"shop.cart.MAX_ITEMS":           "constant",
+		"shop.cart._cache":              "",
+		"shop.cart.Cart":                "class",
+		"shop.cart.Cart.owner":          "field",
+		"shop.cart.Cart.items":          "field",
+		"shop.cart.Cart.__init__":       "method",
+		"shop.cart.Cart.total":          "property",
+		"shop.cart.Cart.load":           "method",
+		"shop.cart.Cart._check":         "method",
+		"shop.cart.Cart.merge":          "method",
+		"shop.cart.Cart.__init__.inner": "",
+		"shop.cart.Item":                "class",
+		"shop.cart.checkout":            "function",
+	}
+	for name, kind := range kinds {
+		if got := syms[name].Kind; got != kind {
+			t.Errorf("%s: kind %q, want %q", name, got, kind)
+		}
+	}
+	if n := len(f.Symbols); n != 12 {
+		t.Errorf("symbols = %d, want 12", n)
+	}
+
+	cart := syms["shop.cart.Cart"]
+	if cart.StartLine != 13 || cart.StartCol != 7 || cart.EndLine != 54 {
+		t.Errorf("Cart span = %d:%d-%d", cart.StartLine, cart.StartCol, cart.EndLine)
+	}
+	if want := "A cart.\n\nAttributes:\n    owner (str): Who shops."; cart.DocFmt != want {
+		t.Errorf("Cart doc = %q, want %q", cart.DocFmt, want)
+	}
+	if syms["shop.VERSION"].Visibility != "public" || syms["shop.cart.Cart._check"].Visibility != "private" ||
+		syms["shop.cart.Cart.__init__"].Visibility != "public" {
+		t.Error("visibility not from __all__ and underscores")
+	}
+	if syms["shop.cart.Cart.items"].DocRaw != "Items in order." {
+		t.Errorf("attribute docstring = %q", syms["shop.cart.Cart.items"].DocRaw)
+	}
+
+	load := syms["shop.cart.Cart.load"]
+	var ex extra
+	if err := json.Unmarshal([]byte(load.ExtraJson), &ex); err != nil {
+		t.Fatal(err)
+	}
+	if !ex.Async || len(ex.Decorators) != 1 || ex.Decorators[0] != "staticmethod" || ex.Doc == nil || ex.Doc.Style != "numpy" || ex.Doc.Summary != "Load a cart." ||
+		len(ex.Doc.Params) != 1 || ex.Doc.Params[0] != (docItem{Name: "path", Type: "str", Desc: "Where from."}) {
+		t.Errorf("load extra = %s", load.ExtraJson)
+	}
+	if !strings.Contains(load.DocFmt, "Args:\n    path (str): Where from.\n\nReturns:\n    Cart or None") {
+		t.Errorf("load doc = %q", load.DocFmt)
+	}
+	checkout := syms["shop.cart.checkout"]
+	if !strings.Contains(checkout.DocFmt, "Args:\n    cart (Cart): The cart.\n\nRaises:\n    ValueError: If empty.") {
+		t.Errorf("checkout doc = %q", checkout.DocFmt)
+	}
+
+	sigs := map[uuid.UUID]ir.Signature{}
+	for _, s := range f.Signatures {
+		sigs[s.SymbolId] = s
+	}
+	if got, want := sigs[load.Id].Text, `async def load(path: str = ")", /, *args: "Item", **kw) -> Optional["Cart"]`; got != want {
+		t.Errorf("load sig = %s, want %s", got, want)
+	}
+	if got, want := sigs[load.Id].Json, `{"params":[{"name":"path","type":"str","default":"\")\"","kind":"positional_only"},`+
+		`{"name":"args","type":"\"Item\"","kind":"var_positional","variadic":true},{"name":"kw","kind":"var_keyword","variadic":true}],`+
+		`"results":[{"type":"Optional[\"Cart\"]"}]}`; got != want {
+		t.Errorf("load sig json = %s\nwant %s", got, want)
+	}
+	// The stub's types win; the implementation keeps its location and the
+	// defaults the stub elides.
+	init := syms["shop.cart.Cart.__init__"]
+	if got, want := sigs[init.Id].Text, "def __init__(self, owner: str, *, limit: int = MAX_ITEMS) -> None"; got != want {
+		t.Errorf("__init__ sig = %s, want %s", got, want)
+	}
+	if got, want := sigs[init.Id].Json, `{"params":[{"name":"self"},{"name":"owner","type":"str"},`+
+		`{"name":"limit","type":"int","default":"MAX_ITEMS","kind":"keyword_only"}],"results":[{"type":"None"}]}`; got != want {
+		t.Errorf("__init__ sig json = %s\nwant %s", got, want)
+	}
+	if init.StartLine != 24 || !strings.Contains(init.ExtraJson, `"stub":"src/shop/cart.pyi"`) {
+		t.Errorf("__init__ = %+v", init)
+	}
+	if syms["shop.cart.Cart.merge"].OriginFileId == init.OriginFileId {
+		t.Error("stub-only method not from the stub file")
+	}
+
+	refs := map[string][]string{}
+	for _, tr := range f.Typerefs {
+		refs[tr.Slot] = append(refs[tr.Slot], tr.Json)
+	}
+	for slot, want := range map[string]string{
+		"base:0":      `{"symbol":"shop.pricing.Base","type":"class","text":"pricing.Base"}`,
+		"result:0":    `"symbol":"shop.cart.Cart"`,
+		"param:1":     `{"symbol":"shop.cart.Item","text":"\"Item\""}`,
+		"field:items": `{"symbol":"shop.cart.Item","text":"list[Item]"}`,
+		"param:0":     `{"symbol":"shop.cart.Cart","text":"Cart"}`,
+		"field:owner": "",
+		"base:1":      "",
+	} {
+		got := strings.Join(refs[slot], " ")
+		if want == "" && got != "" || !strings.Contains(got, want) {
+			t.Errorf("typerefs %s = %s, want %s", slot, got, want)
+		}
+	}
+
+	imports := map[string]ir.Import{}
+	for _, im := range f.Imports {
+		imports[im.Target+" "+im.Alias] = im
+	}
+	for key, details := range map[string]string{
+		"json ":         `{"file":"src/shop/cart.py","line":4,"stdlib":true}`,
+		"shop.pricing ": `{"file":"src/shop/cart.py","line":7,"names":["Price"]}`,
+		"shop ":         `{"file":"src/shop/cart.py","line":6,"names":["pricing"]}`,
+		"os ":           `{"file":"src/shop/cart.py","line":27,"stdlib":true}`,
+	} {
+		if got := imports[key].DetailsJson; got != details {
+			t.Errorf("import %q = %s, want %s", key, got, details)
+		}
+	}
+}
+
+func TestModuleNames(t *testing.T) {
+	root := t.TempDir()
+	for _, rel := range []string{
+		"svc/pyproject.toml", "svc/src/app/__init__.py", "svc/src/app/ns/mod.py", "svc/tests/test_app.py",
+		"lib/pkg/__init__.py", "lib/pkg/sub/__init__.py", "lib/pkg/sub/x.py", "scripts/run.py",
+	} {
+		p := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l := newLayout(root)
+	for rel, want := range map[string]string{
+		"svc/src/app/__init__.py": "app",
+		"svc/src/app/ns/mod.py":   "app.ns.mod",
+		"svc/tests/test_app.py":   "test_app",
+		"lib/pkg/__init__.py":     "pkg",
+		"lib/pkg/sub/x.py":        "pkg.sub.x",
+		"scripts/run.py":          "run",
+	} {
+		if got, _ := l.moduleName(rel); got != want {
+			t.Errorf("moduleName(%s) = %q, want %q", rel, got, want)
+		}
+	}
+}
+
+func TestDocStyles(t *testing.T) {
+	for _, tc := range []struct {
+		name, raw, style, want, summary string
+	}{
+		{"plain", "Just text,\n    wrapped.\n\n    More.", "", "Just text,\nwrapped.\n\nMore.", "Just text,\nwrapped."},
+		{"google", `Sum two numbers.
+
+    Args:
+        a (int): The first.
+        b: The second,
+            continued.
+
+    Returns:
+        int: The sum.
+
+    Example:
+        >>> add(1, 2)
+    `, "google", "Sum two numbers.\n\nArgs:\n    a (int): The first.\n    b: The second, continued.\n\nReturns:\n    int: The sum.\n\nExample:\n    >>> add(1, 2)", "Sum two numbers."},
+		{"numpy", `Sum two numbers.
+
+    Parameters
+    ----------
+    a, b : int
+        The numbers.
+
+    Raises
+    ------
+    OverflowError
+        If too big.
+    `, "numpy", "Sum two numbers.\n\nArgs:\n    a, b (int): The numbers.\n\nRaises:\n    OverflowError: If too big.", "Sum two numbers."},
+		{"rest", `Sum two numbers.
+
+    :param a: The first.
+    :type a: int
+    :returns: The sum.
+    :rtype: int
+    `, "rest", "Sum two numbers.\n\nArgs:\n    a (int): The first.\n\nReturns:\n    int: The sum.", "Sum two numbers."},
+	} {
+		got, d := formatDoc(tc.raw)
+		if d.style != tc.style || got != tc.want {
+			t.Errorf("%s: style %q, doc\n%s\nwant style %q, doc\n%s", tc.name, d.style, got, tc.style, tc.want)
+		}
+		if in := d.info(); in == nil || in.Summary != tc.summary {
+			t.Errorf("%s: info %+v, want summary %q", tc.name, in, tc.summary)
+		}
+	}
+}
+
+func TestLogicalLines(t *testing.T) {
+	src := "x = (1,\n     2)  # c\ns = '''a\n# not a comment\n'''\n\n  \ny = 1 + \\\n  2\n"
+	var got []string
+	for _, l := range logicalLines([]byte(src)) {
+		got = append(got, l.text)
+	}
+	want := []string{"x = (1,\n     2)", "s = '''a\n# not a comment\n'''", "y = 1 +    2"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("lines = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,156 @@
+package rust
+
+import (
+	"strings"
+
+	"github.com/ChaseHampton/cargoworker/internal/pack/outline"
+)
+
+// builtins are the primitive types, the names of the prelude and the
+// keywords of type expressions, which type references leave out.
+var builtins = outline.Set(`
+	bool char str u8 u16 u32 u64 u128 usize i8 i16 i32 i64 i128 isize f16 f32 f64 f128
+	Self self dyn impl mut const for where as unsafe extern fn crate super
+	Option Some None Result Ok Err Vec String Box ToString ToOwned Clone Copy Send Sync Sized Unpin
+	Drop Fn FnMut FnOnce AsRef AsMut From Into TryFrom TryInto Default Iterator IntoIterator
+	DoubleEndedIterator ExactSizeIterator Extend FromIterator PartialEq Eq PartialOrd Ord`)
+
+// stdlib are the crates that ship with the compiler.
+var stdlib = outline.Set(`std core alloc proc_macro test`)
+
+// isStdlib reports whether a resolved path is in a crate that ships with
+// the compiler.
+func isStdlib(path string) bool {
+	first, _, _ := strings.Cut(path, "::")
+	return stdlib[first]
+}
+
+// ref is a name a type refers to: qualified when it resolves, else as
+// written.
+type ref struct {
+	Symbol string `json:"symbol,omitempty"`
+	Name   string `json:"name,omitempty"`
+	Type   string `json:"type,omitempty"`
+	Text   string `json:"text,omitempty"` // the whole type
+}
+
+// resolver resolves the paths of a module to full names.
+type resolver struct {
+	module string            // the full name of the module
+	crate  string            // the name of its crate
+	uses   map[string]string // local name -> full path, from use declarations
+	defs   map[string]bool   // the items of the module
+}
+
+// absolute makes a path written in the module absolute: crate, self and
+// super are replaced, names the module defines or imports are qualified,
+// and anything else is taken to start with a crate name.
+func (r *resolver) absolute(path string) string {
+	if rest, ok := strings.CutPrefix(path, "::"); ok {
+		return rest
+	}
+	first, rest, more := strings.Cut(path, "::")
+	join := func(base string) string {
+		if more {
+			return base + "::" + rest
+		}
+		return base
+	}
+	switch {
+	case first == "crate":
+		return join(r.crate)
+	case first == "self":
+		return join(r.module)
+	case first == "super":
+		parent, tail := r.module, path
+		for tail == "super" || strings.HasPrefix(tail, "super::") {
+			if i := strings.LastIndex(parent, "::"); i > 0 {
+				parent = parent[:i]
+			}
+			tail = strings.TrimPrefix(strings.TrimPrefix(tail, "super"), "::")
+		}
+		if tail == "" {
+			return parent
+		}
+		return parent + "::" + tail
+	case r.uses[first] != "":
+		return join(r.uses[first])
+	case r.defs[first]:
+		return join(r.module + "::" + first)
+	}
+	return path
+}
+
+// refs returns the paths a type refers to, in order, leaving out builtins
+// and the type parameters in scope.
+func (r *resolver) refs(typ string, params map[string]bool) []ref {
+	var out []ref
+	seen := map[string]bool{}
+	toks := lex(typ)
+	for i := 0; i < len(toks); i++ {
+		t := toks[i]
+		if t.Kind != outline.Ident || i > 0 && toks[i-1].Is("::") {
+			continue
+		}
+		// The names of associated type bindings: Iterator<Item = T>.
+		if i+1 < len(toks) && toks[i+1].Is("=") {
+			continue
+		}
+		path := t.Text
+		for i+2 < len(toks) && toks[i+1].Is("::") && toks[i+2].Kind == outline.Ident {
+			path += "::" + toks[i+2].Text
+			i += 2
+		}
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+		first, _, _ := strings.Cut(path, "::")
+		if params[first] {
+			continue
+		}
+		if q, ok := r.resolve(path); ok {
+			q.Text = typ
+			out = append(out, q)
+		}
+	}
+	return out
+}
+
+// resolve resolves a path written in a type.
+func (r *resolver) resolve(path string) (ref, bool) {
+	first, _, more := strings.Cut(path, "::")
+	switch {
+	case first == "Self" || !more && builtins[first] && r.uses[first] == "" && !r.defs[first]:
+		return ref{}, false
+	case first == "crate" || first == "self" || first == "super" || r.uses[first] != "" || r.defs[first] || more:
+		return ref{Symbol: r.absolute(path)}, true
+	}
+	return ref{Name: path}, true
+}
+
+// base returns the path of the type an impl block is for, without
+// references, generic arguments and lifetimes: Foo for &'a mut Foo<T>.
+func base(typ string) string {
+	toks := lex(typ)
+	i := 0
+	for i < len(toks) && (toks[i].Is("&") || toks[i].Is("&&") || toks[i].Is("mut") || toks[i].Is("dyn") || toks[i].Kind == tLifetime) {
+		i++
+	}
+	var path string
+	if i < len(toks) && toks[i].Is("::") {
+		path = "::"
+		i++
+	}
+	for i < len(toks) && toks[i].Kind == outline.Ident {
+		path += toks[i].Text
+		if i+1 < len(toks) && toks[i+1].Is("::") && i+2 < len(toks) && toks[i+2].Kind == outline.Ident {
+			path += "::"
+			i += 2
+			continue
+		}
+		i++
+		break
+	}
+	return path
+}
@@ -0,0 +1,348 @@
+package rust
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// pkg is a directory with a Cargo.toml that has a [package] table.
+type pkg struct {
+	dir         string // relative to the input root
+	name        string
+	version     string
+	description string
+	edition     string
+}
+
+// crate is one compilation target of a package: its library, a binary, an
+// example, a test or a bench.
+type crate struct {
+	name string // the full name of its container
+	kind string // lib, bin, example, test or bench
+	root string // the root source file, relative to the input root
+	pkg  *pkg
+}
+
+// mod is a module of a crate: a file, or an inline mod block.
+type mod struct {
+	full  string // crate name and module path, :: separated
+	file  string // the file that holds it
+	doc   string // its inner docs, and for modules in files the docs on mod name;
+	vis   string // the narrowest visibility on its path from the crate root
+	crate *crate
+}
+
+// layout finds the crates of an input root and walks their module trees, so
+// that each file is known by the module path mod declarations give it.
+type layout struct {
+	root   string
+	mu     sync.Mutex
+	pkgs   map[string]*pkg   // by directory; nil where there is none
+	walked map[string]bool   // packages whose crates are walked, by directory
+	files  map[string]*mod   // modules by file
+	mods   map[string]*mod   // modules by full name
+	parsed map[string]*file  // outlines, by file
+	crates map[string]*crate // by full name
+}
+
+func newLayout(root string) *layout {
+	return &layout{
+		root: root, pkgs: map[string]*pkg{}, walked: map[string]bool{}, files: map[string]*mod{},
+		mods: map[string]*mod{}, parsed: map[string]*file{}, crates: map[string]*crate{},
+	}
+}
+
+// module returns the module of a file. Files no mod declaration reaches are
+// named from their path under the package's src directory.
+func (l *layout) module(rel string) *mod {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	p := l.pkg(path.Dir(rel))
+	if p != nil && !l.walked[p.dir] {
+		l.walked[p.dir] = true
+		l.walk(p)
+	}
+	if m, ok := l.files[rel]; ok {
+		return m
+	}
+	// An orphan file: name it after its path.
+	var c *crate
+	rest := strings.TrimSuffix(rel, ".rs")
+	if p != nil {
+		c = l.crates[l.libName(p)]
+		if c == nil {
+			c = l.addCrate(p, crateName(p.name), "lib", "")
+		}
+		rest = strings.TrimPrefix(strings.TrimPrefix(rest, p.dir+"/"), "src/")
+		if p.dir == "." {
+			rest = strings.TrimPrefix(rest, "src/")
+		}
+	} else {
+		c = l.addCrate(nil, crateName(path.Base(rest)), "bin", rel)
+		rest = ""
+	}
+	full := c.name
+	for _, seg := range strings.Split(rest, "/") {
+		if seg != "" && seg != "mod" && seg != "lib" && seg != "main" {
+			full += "::" + seg
+		}
+	}
+	m := &mod{full: full, file: rel, crate: c}
+	if f := l.outline(rel); f != nil {
+		m.doc = f.doc
+	}
+	l.files[rel] = m
+	if _, ok := l.mods[full]; !ok {
+		l.mods[full] = m
+	}
+	return m
+}
+
+// lookupMod returns the module named full, if a walk found it.
+func (l *layout) lookupMod(full string) *mod {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.mods[full]
+}
+
+// crateName turns a package name into the name code refers to it by.
+func crateName(s string) string { return strings.ReplaceAll(s, "-", "_") }
+
+func (l *layout) libName(p *pkg) string {
+	for _, c := range l.crates {
+		if c.pkg == p && c.kind == "lib" {
+			return c.name
+		}
+	}
+	return crateName(p.name)
+}
+
+// pkg returns the nearest package at or above dir, or nil.
+func (l *layout) pkg(dir string) *pkg {
+	if p, seen := l.pkgs[dir]; seen {
+		return p
+	}
+	p := l.read(dir)
+	if p == nil && dir != "." {
+		p = l.pkg(path.Dir(dir))
+	}
+	l.pkgs[dir] = p
+	return p
+}
+
+// manifest is the part of a Cargo.toml the layout reads.
+type manifest struct {
+	Package *struct {
+		Name        string `toml:"name"`
+		Version     any    `toml:"version"` // a string, or {workspace = true}
+		Description any    `toml:"description"`
+		Edition     any    `toml:"edition"`
+	} `toml:"package"`
+	Lib *target  `toml:"lib"`
+	Bin []target `toml:"bin"`
+}
+
+type target struct {
+	Name string `toml:"name"`
+	Path string `toml:"path"`
+}
+
+// read parses dir/Cargo.toml. A missing or malformed file, or a workspace
+// without a package, is no package.
+func (l *layout) read(dir string) *pkg {
+	m := l.manifest(dir)
+	if m == nil || m.Package == nil {
+		return nil
+	}
+	str := func(v any) string { s, _ := v.(string); return s }
+	return &pkg{dir: dir, name: m.Package.Name, version: str(m.Package.Version), description: str(m.Package.Description), edition: str(m.Package.Edition)}
+}
+
+func (l *layout) manifest(dir string) *manifest {
+	b, err := os.ReadFile(filepath.Join(l.root, filepath.FromSlash(dir), "Cargo.toml"))
+	if err != nil {
+		return nil
+	}
+	var m manifest
+	if err := toml.Unmarshal(b, &m); err != nil {
+		return nil
+	}
+	return &m
+}
+
+func (l *layout) exists(rel string) bool {
+	fi, err := os.Stat(filepath.Join(l.root, filepath.FromSlash(rel)))
+	return err == nil && !fi.IsDir()
+}
+
+// walk finds the crates of a package, as Cargo does, and walks their module
+// trees.
+func (l *layout) walk(p *pkg) {
+	m := l.manifest(p.dir)
+	join := func(rel string) string { return path.Join(p.dir, rel) }
+	libRoot, libName := join("src/lib.rs"), crateName(p.name)
+	if m.Lib != nil {
+		if m.Lib.Path != "" {
+			libRoot = join(m.Lib.Path)
+		}
+		if m.Lib.Name != "" {
+			libName = crateName(m.Lib.Name)
+		}
+	}
+	if l.exists(libRoot) {
+		l.addCrate(p, libName, "lib", libRoot)
+	}
+	seen := map[string]bool{}
+	for _, b := range m.Bin {
+		root := b.Path
+		if root == "" {
+			root = "src/bin/" + b.Name + ".rs"
+			if !l.exists(join(root)) {
+				root = "src/main.rs"
+			}
+		}
+		if l.exists(join(root)) {
+			seen[join(root)] = true
+			l.addCrate(p, crateName(b.Name), "bin", join(root))
+		}
+	}
+	if r := join("src/main.rs"); l.exists(r) && !seen[r] {
+		l.addCrate(p, crateName(p.name), "bin", r)
+	}
+	for _, t := range []struct{ dir, kind string }{{"src/bin", "bin"}, {"examples", "example"}, {"tests", "test"}, {"benches", "bench"}} {
+		for _, root := range l.targets(join(t.dir)) {
+			if seen[root] {
+				continue
+			}
+			name := strings.TrimSuffix(path.Base(root), ".rs")
+			if name == "main" {
+				name = path.Base(path.Dir(root))
+			}
+			l.addCrate(p, crateName(name), t.kind, root)
+		}
+	}
+	if r := join("build.rs"); l.exists(r) {
+		l.addCrate(p, "build_script_build", "build", r)
+	}
+}
+
+// targets returns the auto-discovered targets in dir: its .rs files and
+// the main.rs of its subdirectories.
+func (l *layout) targets(dir string) []string {
+	entries, err := os.ReadDir(filepath.Join(l.root, filepath.FromSlash(dir)))
+	if err != nil {
+		return nil
+	}
+	var out []string
+	for _, e := range entries {
+		switch {
+		case !e.IsDir() && strings.HasSuffix(e.Name(), ".rs"):
+			out = append(out, path.Join(dir, e.Name()))
+		case e.IsDir() && l.exists(path.Join(dir, e.Name(), "main.rs")):
+			out = append(out, path.Join(dir, e.Name(), "main.rs"))
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// addCrate records a crate and walks its module tree from root. Names that
+// are taken, as by a binary named after its package's library, are
+// qualified by the kind of target.
+func (l *layout) addCrate(p *pkg, name, kind, root string) *crate {
+	if _, taken := l.crates[name]; taken {
+		name += "-" + kind
+	}
+	c := &crate{name: name, kind: kind, root: root, pkg: p}
+	l.crates[name] = c
+	if root != "" {
+		if _, ok := l.files[root]; !ok {
+			l.tree(c, name, root, path.Dir(root), l.outline(root), "", "public")
+		}
+	}
+	return c
+}
+
+// tree records the module in file, visible as vis, and the modules its mod
+// declarations declare. dir is where the files of its child modules are.
+func (l *layout) tree(c *crate, full, rel, dir string, f *file, doc, vis string) {
+	if f == nil {
+		return
+	}
+	m := &mod{full: full, file: rel, doc: joinDocs(doc, f.doc), vis: vis, crate: c}
+	l.files[rel] = m
+	l.mods[full] = m
+	l.children(c, full, rel, dir, f.items, vis)
+}
+
+// children walks the mod items of a module visible as vis.
+func (l *layout) children(c *crate, full, rel, dir string, items []*item, vis string) {
+	for _, it := range items {
+		if it.kind != "mod" || it.name == "" {
+			continue
+		}
+		child, childVis := full+"::"+it.name, narrower(vis, visibility(it.vis))
+		if it.inline {
+			l.mods[child] = &mod{full: child, file: rel, doc: joinDocs(it.doc, it.innerDoc), vis: childVis, crate: c}
+			sub := path.Join(dir, it.name)
+			if it.path != "" {
+				sub = path.Join(dir, it.path)
+			}
+			l.children(c, child, rel, sub, it.children, childVis)
+			continue
+		}
+		var cands []string
+		if it.path != "" {
+			cands = []string{path.Join(path.Dir(rel), it.path)}
+		} else {
+			cands = []string{path.Join(dir, it.name+".rs"), path.Join(dir, it.name, "mod.rs")}
+		}
+		for _, cand := range cands {
+			if _, done := l.files[cand]; done || !l.exists(cand) {
+				continue
+			}
+			sub := path.Join(path.Dir(cand), it.name)
+			if path.Base(cand) == "mod.rs" || it.path != "" {
+				sub = path.Dir(cand)
+			}
+			l.tree(c, child, cand, sub, l.outline(cand), it.doc, childVis)
+			break
+		}
+	}
+}
+
+func joinDocs(outer, inner string) string {
+	switch {
+	case outer == "":
+		return inner
+	case inner == "":
+		return outer
+	}
+	return outer + "\n\n" + inner
+}
+
+// outline parses a file, caching the result.
+func (l *layout) outline(rel string) *file {
+	if f, ok := l.parsed[rel]; ok {
+		return f
+	}
+	var f *file
+	if src, err := os.ReadFile(filepath.Join(l.root, filepath.FromSlash(rel))); err == nil {
+		f = parse(string(src))
+	}
+	l.parsed[rel] = f
+	return f
+}
+
+// parsedFile returns the outline of a file the layout has read.
+func (l *layout) parsedFile(rel string) *file {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.outline(rel)
+}
@@ -0,0 +1,252 @@
+package rust
+
+import (
+	"strings"
+
+	"github.com/ChaseHampton/cargoworker/internal/pack/outline"
+)
+
+// The kinds of token only Rust has.
+const (
+	tLifetime outline.Kind = outline.Other + iota // 'a, quote included
+	tInnerDoc                                     // an inner doc comment: //!, /*! */
+)
+
+// token is a token. Raw identifiers are kept without their r#, and the
+// text of doc comments, outline.Doc for outer ones, without their markers.
+type token = outline.Token
+
+// puncts are the multi-character operators, longest first. Angle brackets
+// are always single tokens so that generics nest.
+var puncts = []string{
+	"...", "..=", "::", "->", "=>", "..", "==", "!=", "<=", "&&", "||", "+=", "-=", "*=", "/=", "%=", "^=", "&=", "|=",
+}
+
+type lexer struct {
+	outline.Scanner
+	out []token
+}
+
+// lex splits a source into tokens, dropping comments other than doc
+// comments.
+func lex(src string) []token {
+	l := &lexer{Scanner: outline.NewScanner(src)}
+	l.run()
+	return l.out
+}
+
+func (l *lexer) run() {
+	if strings.HasPrefix(l.Src, "\ufeff") {
+		l.I = 3
+	}
+	if strings.HasPrefix(l.Src[l.I:], "#!") && !strings.HasPrefix(l.Src[l.I:], "#![") {
+		l.SkipLine()
+	}
+	for l.I < len(l.Src) {
+		c := l.Src[l.I]
+		switch {
+		case c == '\n':
+			l.SkipTo(l.I + 1)
+			continue
+		case c == ' ' || c == '\t' || c == '\r' || c == '\f' || c == '\v':
+			l.I++
+			continue
+		case c == '/' && l.Peek(1) == '/':
+			start := l.I
+			l.SkipLine()
+			text := l.Src[start:l.I]
+			switch {
+			case strings.HasPrefix(text, "///") && !strings.HasPrefix(text, "////"):
+				l.emit(outline.Doc, start, strings.TrimSuffix(text[3:], "\r"))
+			case strings.HasPrefix(text, "//!"):
+				l.emit(tInnerDoc, start, strings.TrimSuffix(text[3:], "\r"))
+			}
+			continue
+		case c == '/' && l.Peek(1) == '*':
+			start, line, col := l.I, l.Line, l.I-l.LineStart
+			l.SkipTo(l.block(l.I))
+			text := l.Src[start:l.I]
+			kind := outline.Punct
+			switch {
+			case strings.HasPrefix(text, "/**") && !strings.HasPrefix(text, "/***") && text != "/**/":
+				kind = outline.Doc
+			case strings.HasPrefix(text, "/*!"):
+				kind = tInnerDoc
+			}
+			if kind != outline.Punct {
+				l.out = append(l.out, token{Kind: kind, Text: blockDoc(text), Pos: start, Line: line, Col: col, EndLine: l.Line, EndCol: l.I - l.LineStart})
+			}
+			continue
+		}
+		t := token{Line: l.Line, Col: l.I - l.LineStart, Pos: l.I}
+		start := l.I
+		switch {
+		case l.stringStart():
+			l.SkipTo(l.string(l.I))
+			t.Kind = outline.String
+		case c == '\'':
+			if end, ok := l.char(l.I); ok {
+				l.SkipTo(end)
+				t.Kind = outline.Char
+			} else {
+				l.I++
+				for l.I < len(l.Src) && isIdent(l.Src[l.I]) {
+					l.I++
+				}
+				t.Kind = tLifetime
+			}
+		case isIdentStart(c):
+			if c == 'r' && l.Peek(1) == '#' && isIdentStart(l.Peek(2)) {
+				l.I += 2
+			}
+			for l.I < len(l.Src) && isIdent(l.Src[l.I]) {
+				l.I++
+			}
+			t.Kind = outline.Ident
+		case c >= '0' && c <= '9':
+			l.I++
+			for l.I < len(l.Src) {
+				d := l.Src[l.I]
+				if isIdent(d) || d == '.' && l.I+1 < len(l.Src) && l.Src[l.I+1] >= '0' && l.Src[l.I+1] <= '9' {
+					l.I++
+					continue
+				}
+				break
+			}
+			t.Kind = outline.Number
+		default:
+			t.Kind = outline.Punct
+			l.SkipPunct(puncts)
+		}
+		t.Text = l.Src[start:l.I]
+		if t.Kind == outline.Ident && strings.HasPrefix(t.Text, "r#") {
+			t.Text = t.Text[2:]
+		}
+		t.EndLine, t.EndCol = l.Line, l.I-l.LineStart
+		l.out = append(l.out, t)
+	}
+}
+
+func (l *lexer) emit(kind outline.Kind, start int, text string) {
+	l.out = append(l.out, token{Kind: kind, Text: text, Pos: start, Line: l.Line, Col: start - l.LineStart, EndLine: l.Line, EndCol: l.I - l.LineStart})
+}
+
+// block returns the offset after the block comment at i; block comments
+// nest.
+func (l *lexer) block(i int) int {
+	depth := 0
+	for i < len(l.Src) {
+		switch {
+		case strings.HasPrefix(l.Src[i:], "/*"):
+			depth++
+			i += 2
+		case strings.HasPrefix(l.Src[i:], "*/"):
+			depth--
+			i += 2
+			if depth == 0 {
+				return i
+			}
+		default:
+			i++
+		}
+	}
+	return len(l.Src)
+}
+
+// stringStart reports whether a string literal starts here: "..", r".." or
+// r#".."#, and their b and c prefixed forms.
+func (l *lexer) stringStart() bool {
+	s := l.Src[l.I:]
+	switch {
+	case strings.HasPrefix(s, `"`):
+		return true
+	case strings.HasPrefix(s, "br"), strings.HasPrefix(s, "cr"):
+		s = strings.TrimLeft(s[2:], "#")
+	case strings.HasPrefix(s, "r"):
+		s = strings.TrimLeft(s[1:], "#")
+	case strings.HasPrefix(s, "b"), strings.HasPrefix(s, "c"):
+		s = s[1:]
+	default:
+		return false
+	}
+	return strings.HasPrefix(s, `"`)
+}
+
+// string returns the offset after the string literal at i.
+func (l *lexer) string(i int) int {
+	for l.Src[i] != '"' && l.Src[i] != 'r' {
+		i++ // b or c
+	}
+	if l.Src[i] == 'r' {
+		i++
+		hashes := 0
+		for i < len(l.Src) && l.Src[i] == '#' {
+			hashes++
+			i++
+		}
+		if i >= len(l.Src) {
+			return len(l.Src)
+		}
+		end := strings.Index(l.Src[i+1:], `"`+strings.Repeat("#", hashes))
+		if end < 0 {
+			return len(l.Src)
+		}
+		return i + 1 + end + 1 + hashes
+	}
+	for i++; i < len(l.Src); i++ {
+		switch l.Src[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return len(l.Src)
+}
+
+// char returns the offset after the character literal at i, or false for a
+// lifetime.
+func (l *lexer) char(i int) (int, bool) {
+	j := i + 1
+	if j >= len(l.Src) {
+		return 0, false
+	}
+	if l.Src[j] == '\\' && j+2 < len(l.Src) {
+		end := strings.IndexByte(l.Src[j+2:], '\'')
+		if end < 0 {
+			return 0, false
+		}
+		return j + 2 + end + 1, true
+	}
+	// One character, maybe several bytes long, then the closing quote.
+	k := j + 1
+	for k < len(l.Src) && l.Src[k]&0xC0 == 0x80 {
+		k++
+	}
+	if k < len(l.Src) && l.Src[k] == '\'' {
+		return k + 1, true
+	}
+	return 0, false
+}
+
+// blockDoc returns the text of a block doc comment, without its markers and
+// the leading asterisks of its lines.
+func blockDoc(raw string) string {
+	s := strings.TrimSuffix(raw[3:], "*/")
+	lines := strings.Split(s, "\n")
+	for i, ln := range lines {
+		t := strings.TrimLeft(ln, " \t")
+		if strings.HasPrefix(t, "*") {
+			lines[i] = t[1:]
+		} else if i > 0 {
+			lines[i] = ln
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+func isIdent(c byte) bool { return isIdentStart(c) || c >= '0' && c <= '9' }
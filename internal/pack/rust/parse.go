@@ -0,0 +1,888 @@
+package rust
+
+import (
+	"strings"
+
+	"github.com/ChaseHampton/cargoworker/internal/pack/outline"
+)
+
+// file is the outline of one source file.
+type file struct {
+	doc   string // inner doc comments
+	items []*item
+}
+
+// item is an item of a module, or of a struct, enum, trait or impl.
+type item struct {
+	kind      string // function, method, struct, enum, union, trait, impl, type, constant, static, macro, mod, use, extern_crate, field, enum_member
+	name      string
+	line, col int // of the name: 1-based line and byte column
+	endLine   int
+	endCol    int // 1-based, after the last character
+	vis       string
+	attrs     []string // outer attributes, without #[ ]
+	doc       string
+	quals     []string // const, async, unsafe, extern "C", default
+	generics  []generic
+	where     string
+	params    []param
+	returns   string
+	typ       string // of fields, constants and statics; the aliased type
+	bounds    []string
+	sig       string
+	children  []*item
+	// impl blocks
+	trait    string
+	self     string
+	negative bool
+	// modules: inline holds the items of mod name { ... }; path is the
+	// #[path] attribute of mod name;
+	inline   bool
+	innerDoc string
+	path     string
+	// use declarations
+	uses []usePath
+}
+
+type param struct {
+	Name string `json:"name"`
+	Type string `json:"type,omitempty"`
+}
+
+type generic struct {
+	Name       string `json:"name"`
+	Constraint string `json:"constraint,omitempty"`
+	Default    string `json:"default,omitempty"`
+	Const      bool   `json:"const,omitempty"`
+}
+
+// usePath is one path a use declaration brings into scope.
+type usePath struct {
+	path  string // as written, :: separated
+	alias string // the name after as; "_" for anonymous
+	glob  bool   // path::*
+}
+
+type parser struct {
+	outline.Cursor
+}
+
+func parse(src string) *file {
+	p := &parser{Cursor: outline.Cursor{Src: src, Toks: lex(src)}}
+	f := &file{}
+	f.doc = p.innerDocs()
+	f.items = p.items(false)
+	return f
+}
+
+// end sets the end of it to the last consumed token.
+func (p *parser) end(it *item) {
+	t := p.Last()
+	it.endLine, it.endCol = t.EndLine, t.EndCol+1
+}
+
+// innerDocs reads the inner doc comments and attributes at the start of a
+// file or module block and returns the docs.
+func (p *parser) innerDocs() string {
+	var docs []string
+	for !p.EOF() {
+		switch t := p.Peek(0); {
+		case t.Kind == tInnerDoc:
+			docs = append(docs, t.Text)
+			p.I++
+		case t.Is("#") && p.Peek(1).Is("!") && p.Peek(2).Is("["):
+			p.I += 2
+			from, to := p.Group()
+			if d, ok := docAttr(p, from, to); ok {
+				docs = append(docs, d)
+			}
+		default:
+			return joinDoc(docs)
+		}
+	}
+	return joinDoc(docs)
+}
+
+// docAttr returns the text of a doc = "..." attribute.
+func docAttr(p *parser, from, to int) (string, bool) {
+	if to-from == 3 && p.Toks[from].Is("doc") && p.Toks[from+1].Is("=") && p.Toks[from+2].Kind == outline.String {
+		s := p.Toks[from+2].Text
+		if strings.HasPrefix(s, "r") {
+			return strings.Trim(strings.TrimLeft(s[1:], "#"), `"#`), true
+		}
+		return unescape(s[1 : len(s)-1]), true
+	}
+	return "", false
+}
+
+func unescape(s string) string {
+	r := strings.NewReplacer(`\n`, "\n", `\t`, "\t", `\"`, `"`, `\\`, `\`, `\'`, "'")
+	return r.Replace(s)
+}
+
+// joinDoc joins doc comment lines, dropping the space after the markers
+// that every line has.
+func joinDoc(lines []string) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	text := strings.Join(lines, "\n")
+	lines = strings.Split(text, "\n")
+	margin := -1
+	for _, l := range lines {
+		if t := strings.TrimLeft(l, " \t"); t != "" {
+			if n := len(l) - len(t); margin < 0 || n < margin {
+				margin = n
+			}
+		}
+	}
+	for i, l := range lines {
+		if len(l) >= margin && margin > 0 {
+			lines[i] = l[margin:]
+		}
+		lines[i] = strings.TrimRight(lines[i], " \t\r")
+	}
+	return strings.Trim(strings.Join(lines, "\n"), "\n")
+}
+
+// outer reads the doc comments and attributes before an item.
+func (p *parser) outer() (doc string, attrs []string) {
+	var docs []string
+	for !p.EOF() {
+		switch t := p.Peek(0); {
+		case t.Kind == outline.Doc:
+			docs = append(docs, t.Text)
+			p.I++
+		case t.Kind == tInnerDoc:
+			p.I++ // misplaced; rustc rejects it
+		case t.Is("#") && p.Peek(1).Is("["):
+			p.I++
+			from, to := p.Group()
+			if d, ok := docAttr(p, from, to); ok {
+				docs = append(docs, d)
+			} else {
+				attrs = append(attrs, p.Text(from, to))
+			}
+		default:
+			return joinDoc(docs), attrs
+		}
+	}
+	return joinDoc(docs), attrs
+}
+
+// visibility reads pub, pub(crate), pub(super), pub(self) or pub(in path).
+func (p *parser) visibility() string {
+	if p.Peek(0).Is("crate") && !p.Peek(1).Is("::") {
+		p.I++ // the unstable crate visibility
+		return "pub(crate)"
+	}
+	if !p.Accept("pub") {
+		return ""
+	}
+	if p.Peek(0).Is("(") {
+		switch n := p.Peek(1); {
+		case n.Is("crate") || n.Is("super") || n.Is("self") || n.Is("in"):
+			from, to := p.Group()
+			return "pub(" + p.Text(from, to) + ")"
+		}
+	}
+	return "pub"
+}
+
+// items parses items up to the end of the file or, in a block, its closing
+// brace.
+func (p *parser) items(block bool) []*item {
+	var out []*item
+	for !p.EOF() {
+		if block && p.Peek(0).Is("}") {
+			break
+		}
+		if p.Accept(";") {
+			continue
+		}
+		start := p.I
+		if it := p.item(); it != nil {
+			out = append(out, it)
+		}
+		if p.I == start {
+			p.I++
+		}
+	}
+	return out
+}
+
+// item parses one item, or skips a macro invocation or stray tokens and
+// returns nil.
+func (p *parser) item() *item {
+	doc, attrs := p.outer()
+	if p.EOF() || p.Peek(0).Is("}") {
+		return nil
+	}
+	vis := p.visibility()
+	var quals []string
+	for {
+		t, n := p.Peek(0), p.Peek(1)
+		switch {
+		case t.Is("default") && n.Kind == outline.Ident && !n.Is("for"),
+			t.Is("const") && (n.Is("fn") || n.Is("unsafe") || n.Is("async") || n.Is("extern")),
+			t.Is("async") && (n.Is("fn") || n.Is("unsafe") || n.Is("extern")),
+			t.Is("unsafe") && (n.Is("fn") || n.Is("impl") || n.Is("trait") || n.Is("extern") || n.Is("auto")),
+			t.Is("safe") && (n.Is("fn") || n.Is("static")),
+			t.Is("auto") && n.Is("trait"):
+			quals = append(quals, t.Text)
+			p.I++
+			continue
+		case t.Is("extern") && n.Kind == outline.String && p.Peek(2).Is("fn"):
+			quals = append(quals, "extern "+n.Text)
+			p.I += 2
+			continue
+		case t.Is("extern") && p.Peek(1).Is("fn"):
+			quals = append(quals, "extern")
+			p.I++
+			continue
+		}
+		break
+	}
+	t := p.Peek(0)
+	var it *item
+	switch {
+	case t.Is("fn"):
+		it = p.function(false)
+	case t.Is("struct"), t.Is("union") && p.Peek(1).Kind == outline.Ident:
+		it = p.structure()
+	case t.Is("enum"):
+		it = p.enumeration()
+	case t.Is("trait"):
+		it = p.trait()
+	case t.Is("impl"):
+		it = p.impl()
+	case t.Is("type"):
+		it = p.typeAlias()
+	case t.Is("const") && (p.Peek(1).Kind == outline.Ident || p.Peek(1).Is("_")), t.Is("static"):
+		it = p.constant()
+	case t.Is("mod"):
+		it = p.module()
+	case t.Is("use"):
+		it = p.use()
+	case t.Is("extern") && p.Peek(1).Is("crate"):
+		it = p.externCrate()
+	case t.Is("extern") && (p.Peek(1).Is("{") || p.Peek(1).Kind == outline.String && p.Peek(2).Is("{")):
+		it = p.externBlock()
+	case t.Is("macro_rules") && p.Peek(1).Is("!"):
+		it = p.macroRules()
+	case t.Is("macro") && p.Peek(1).Kind == outline.Ident:
+		it = p.macro()
+	default:
+		p.skipMacro()
+		return nil
+	}
+	if it == nil {
+		return nil
+	}
+	it.vis, it.doc, it.attrs = vis, doc, append(attrs, it.attrs...)
+	if len(quals) > 0 {
+		it.quals = quals
+		it.sig = strings.Join(quals, " ") + " " + it.sig
+	}
+	if vis != "" {
+		it.sig = vis + " " + it.sig
+	}
+	for _, a := range it.attrs {
+		if v, ok := strings.CutPrefix(a, "path = "); ok {
+			it.path = strings.Trim(v, `"`)
+		}
+	}
+	return it
+}
+
+// skipMacro skips a macro invocation, or anything else, up to the end of
+// its statement.
+func (p *parser) skipMacro() {
+	for !p.EOF() {
+		t := p.Peek(0)
+		switch {
+		case t.Is(";"):
+			p.I++
+			return
+		case t.Is("{"):
+			p.Group()
+			return
+		case t.Opens():
+			p.Group()
+		case t.Closes():
+			return
+		default:
+			p.I++
+		}
+	}
+}
+
+// name reads the name of an item.
+func (p *parser) name(it *item) {
+	t := p.Peek(0)
+	if t.Kind == outline.Ident {
+		it.name, it.line, it.col = t.Text, t.Line, t.Col+1
+		p.I++
+	}
+}
+
+// generics parses a generic parameter list, if present, and returns its
+// text.
+func (p *parser) generics(it *item) string {
+	if !p.Peek(0).Is("<") {
+		return ""
+	}
+	p.I++
+	from, to := p.Until(true, ">")
+	p.Accept(">")
+	for _, part := range p.Split(from, to) {
+		i, end := part[0], part[1]
+		for i < end && p.Toks[i].Is("#") {
+			// An attribute on the parameter.
+			save := p.I
+			p.I = i + 1
+			p.Group()
+			i, p.I = p.I, save
+		}
+		if i >= end {
+			continue
+		}
+		g := generic{}
+		if p.Toks[i].Is("const") {
+			g.Const = true
+			i++
+		}
+		if i >= end {
+			continue
+		}
+		g.Name = p.Toks[i].Text
+		i++
+		if i < end && p.Toks[i].Is(":") {
+			j := p.Find(i+1, end, "=")
+			g.Constraint, i = p.Text(i+1, j), j
+		}
+		if i < end && p.Toks[i].Is("=") {
+			g.Default = p.Text(i+1, end)
+		}
+		it.generics = append(it.generics, g)
+	}
+	return "<" + p.Text(from, to) + ">"
+}
+
+// whereClause parses a where clause, if present, and returns its text.
+func (p *parser) whereClause(it *item) string {
+	if !p.Peek(0).Is("where") {
+		return ""
+	}
+	p.I++
+	from, to := p.Until(true, "{", ";", "=")
+	it.where = strings.TrimSuffix(p.Text(from, to), ",")
+	return " where " + it.where
+}
+
+// function parses fn name<...>(params) -> ret where ... { body } or ;.
+func (p *parser) function(method bool) *item {
+	p.I++
+	it := &item{kind: "function"}
+	p.name(it)
+	gen := p.generics(it)
+	var params string
+	if p.Peek(0).Is("(") {
+		from, to := p.Group()
+		params = p.Text(from, to)
+		for _, part := range p.Split(from, to) {
+			it.params = append(it.params, p.param(part[0], part[1]))
+		}
+	}
+	if len(it.params) > 0 && it.params[0].Name == "self" || method {
+		it.kind = "method"
+	}
+	ret := ""
+	if p.Accept("->") {
+		from, to := p.Until(true, "{", ";", "where")
+		it.returns = p.Text(from, to)
+		ret = " -> " + it.returns
+	}
+	where := p.whereClause(it)
+	if p.Peek(0).Is("{") {
+		p.Group()
+	} else {
+		p.Accept(";")
+	}
+	p.end(it)
+	it.sig = "fn " + it.name + gen + "(" + params + ")" + ret + where
+	return it
+}
+
+// param parses one function parameter: pattern: Type, or a self parameter.
+func (p *parser) param(from, to int) param {
+	for from < to && p.Toks[from].Is("#") {
+		depth := 0
+		for from < to {
+			t := p.Toks[from]
+			from++
+			if t.Opens() {
+				depth++
+			} else if t.Closes() {
+				depth--
+				if depth == 0 {
+					break
+				}
+			}
+		}
+	}
+	colon := p.Find(from, to, ":")
+	if colon == to {
+		text := p.Text(from, to)
+		if strings.HasSuffix(text, "self") {
+			return param{Name: "self", Type: text}
+		}
+		return param{Type: text} // ... or a type in a function pointer
+	}
+	name := p.Text(from, colon)
+	if strings.TrimPrefix(name, "mut ") == "self" {
+		name = "self"
+	}
+	return param{Name: strings.TrimPrefix(name, "mut "), Type: p.Text(colon+1, to)}
+}
+
+func (p *parser) structure() *item {
+	kw := p.Toks[p.I]
+	p.I++
+	it := &item{kind: kw.Text}
+	p.name(it)
+	gen := p.generics(it)
+	it.sig = kw.Text + " " + it.name + gen
+	switch {
+	case p.Peek(0).Is("("):
+		from, to := p.Group()
+		for k, part := range p.Split(from, to) {
+			f := p.field(part[0], part[1], true)
+			if f != nil {
+				f.name = itoa(k)
+				it.children = append(it.children, f)
+			}
+		}
+		it.sig += "(" + p.Text(from, to) + ")"
+		it.sig += p.whereClause(it)
+		p.Accept(";")
+	default:
+		it.sig += p.whereClause(it)
+		if p.Peek(0).Is("{") {
+			from, to := p.Group()
+			for _, part := range p.Split(from, to) {
+				if f := p.field(part[0], part[1], false); f != nil {
+					it.children = append(it.children, f)
+				}
+			}
+		} else {
+			p.Accept(";")
+		}
+	}
+	p.end(it)
+	return it
+}
+
+func itoa(n int) string {
+	if n < 10 {
+		return string(rune('0' + n))
+	}
+	return itoa(n/10) + itoa(n%10)
+}
+
+// field parses a named or tuple field in the token range [from, to).
+func (p *parser) field(from, to int, tuple bool) *item {
+	save := p.I
+	defer func() { p.I = save }()
+	p.I = from
+	doc, attrs := p.outer()
+	vis := p.visibility()
+	if p.I >= to {
+		return nil
+	}
+	t := p.Toks[p.I]
+	f := &item{kind: "field", doc: doc, attrs: attrs, vis: vis, line: t.Line, col: t.Col + 1}
+	if tuple {
+		f.typ = p.Text(p.I, to)
+	} else {
+		if t.Kind != outline.Ident || p.I+1 >= to || !p.Toks[p.I+1].Is(":") {
+			return nil
+		}
+		f.name = t.Text
+		f.typ = p.Text(p.I+2, to)
+		if eq := p.Find(p.I+2, to, "="); eq < to {
+			f.typ = p.Text(p.I+2, eq) // a default field value
+		}
+	}
+	last := p.Toks[to-1]
+	f.endLine, f.endCol = last.EndLine, last.EndCol+1
+	f.sig = strings.TrimSpace(vis + " " + f.name + ": " + f.typ)
+	if tuple {
+		f.sig = strings.TrimSpace(vis + " " + f.typ)
+	}
+	return f
+}
+
+func (p *parser) enumeration() *item {
+	p.I++
+	it := &item{kind: "enum"}
+	p.name(it)
+	gen := p.generics(it)
+	it.sig = "enum " + it.name + gen + p.whereClause(it)
+	if p.Peek(0).Is("{") {
+		from, to := p.Group()
+		for _, part := range p.Split(from, to) {
+			if v := p.variant(it.name, part[0], part[1]); v != nil {
+				it.children = append(it.children, v)
+			}
+		}
+	}
+	p.end(it)
+	return it
+}
+
+func (p *parser) variant(enum string, from, to int) *item {
+	save := p.I
+	defer func() { p.I = save }()
+	p.I = from
+	doc, attrs := p.outer()
+	p.visibility()
+	if p.I >= to || p.Toks[p.I].Kind != outline.Ident {
+		return nil
+	}
+	t := p.Toks[p.I]
+	v := &item{kind: "enum_member", name: t.Text, doc: doc, attrs: attrs, line: t.Line, col: t.Col + 1}
+	last := p.Toks[to-1]
+	v.endLine, v.endCol = last.EndLine, last.EndCol+1
+	v.sig = enum + "::" + p.Text(p.I, to)
+	p.I++
+	if p.I < to && (p.Toks[p.I].Is("(") || p.Toks[p.I].Is("{")) {
+		tuple := p.Toks[p.I].Is("(")
+		gfrom, gto := p.Group()
+		for k, part := range p.Split(gfrom, gto) {
+			if f := p.field(part[0], part[1], tuple); f != nil {
+				if tuple {
+					f.name = itoa(k)
+				}
+				v.children = append(v.children, f)
+			}
+		}
+	}
+	return v
+}
+
+func (p *parser) trait() *item {
+	p.I++
+	it := &item{kind: "trait"}
+	p.name(it)
+	gen := p.generics(it)
+	it.sig = "trait " + it.name + gen
+	if p.Accept(":") {
+		from, to := p.Until(true, "{", "where", ";")
+		it.bounds = p.bounds(from, to)
+		it.sig += ": " + p.Text(from, to)
+	}
+	if p.Accept("=") {
+		// A trait alias.
+		from, to := p.Until(true, ";")
+		it.bounds = p.bounds(from, to)
+		it.sig += " = " + p.Text(from, to)
+	}
+	it.sig += p.whereClause(it)
+	if p.Accept("{") {
+		p.innerDocs()
+		it.children = p.assocItems(true)
+		p.Accept("}")
+	} else {
+		p.Accept(";")
+	}
+	p.end(it)
+	return it
+}
+
+// bounds splits bounds at + outside brackets, leaving out lifetimes.
+func (p *parser) bounds(from, to int) []string {
+	var out []string
+	depth, start := 0, from
+	flush := func(end int) {
+		if end > start && p.Toks[start].Kind != tLifetime {
+			out = append(out, strings.TrimPrefix(strings.TrimPrefix(p.Text(start, end), "?"), "~const "))
+		}
+	}
+	for i := from; i < to; i++ {
+		t := p.Toks[i]
+		switch {
+		case t.Opens(), t.Is("<"):
+			depth++
+		case t.Closes(), t.Is(">") && depth > 0:
+			depth--
+		case t.Is("+") && depth == 0:
+			flush(i)
+			start = i + 1
+		}
+	}
+	flush(to)
+	return out
+}
+
+// assocItems parses the items of a trait or impl block up to its closing
+// brace. Functions in them are methods.
+func (p *parser) assocItems(inTrait bool) []*item {
+	var out []*item
+	for !p.EOF() && !p.Peek(0).Is("}") {
+		if p.Accept(";") {
+			continue
+		}
+		start := p.I
+		it := p.item()
+		if it != nil {
+			if it.kind == "function" {
+				it.kind = "method"
+			}
+			out = append(out, it)
+		}
+		if p.I == start {
+			p.I++
+		}
+	}
+	return out
+}
+
+func (p *parser) impl() *item {
+	kw := p.Toks[p.I]
+	p.I++
+	it := &item{kind: "impl", line: kw.Line, col: kw.Col + 1}
+	gen := p.generics(it)
+	p.Accept("const")
+	if p.Accept("!") {
+		it.negative = true
+	}
+	from, to := p.Until(true, "for", "{", "where", ";")
+	if p.Accept("for") {
+		it.trait = p.Text(from, to)
+		from, to = p.Until(true, "{", "where", ";")
+	}
+	it.self = p.Text(from, to)
+	neg := ""
+	if it.negative {
+		neg = "!"
+	}
+	it.sig = "impl" + gen + " "
+	if it.trait != "" {
+		it.sig += neg + it.trait + " for "
+	}
+	it.sig += it.self + p.whereClause(it)
+	if p.Accept("{") {
+		p.innerDocs()
+		it.children = p.assocItems(false)
+		p.Accept("}")
+	} else {
+		p.Accept(";")
+	}
+	p.end(it)
+	return it
+}
+
+func (p *parser) typeAlias() *item {
+	p.I++
+	it := &item{kind: "type"}
+	p.name(it)
+	gen := p.generics(it)
+	it.sig = "type " + it.name + gen
+	if p.Accept(":") {
+		from, to := p.Until(true, "=", ";", "where")
+		it.bounds = p.bounds(from, to)
+		it.sig += ": " + p.Text(from, to)
+	}
+	it.sig += p.whereClause(it)
+	if p.Accept("=") {
+		from, to := p.Until(true, ";", "where")
+		it.typ = p.Text(from, to)
+		it.sig += " = " + it.typ
+		it.sig += p.whereClause(it)
+	}
+	p.Accept(";")
+	p.end(it)
+	return it
+}
+
+// constant parses const and static items, whose values it skips.
+func (p *parser) constant() *item {
+	kw := p.Toks[p.I]
+	p.I++
+	it := &item{kind: "constant"}
+	sig := "const "
+	if kw.Is("static") {
+		it.kind, sig = "static", "static "
+		if p.Accept("mut") {
+			it.quals = append(it.quals, "mut")
+			sig += "mut "
+		}
+	}
+	if p.Peek(0).Is("_") {
+		p.skipMacro()
+		return nil
+	}
+	p.name(it)
+	if p.Accept(":") {
+		from, to := p.Until(true, "=", ";")
+		it.typ = p.Text(from, to)
+	}
+	it.sig = sig + it.name + ": " + it.typ
+	if p.Accept("=") {
+		from, to := p.Until(false, ";")
+		if to-from <= 8 {
+			it.sig += " = " + p.Text(from, to)
+		}
+	}
+	p.Accept(";")
+	p.end(it)
+	return it
+}
+
+func (p *parser) module() *item {
+	p.I++
+	it := &item{kind: "mod"}
+	p.name(it)
+	it.sig = "mod " + it.name
+	if p.Accept("{") {
+		it.inline = true
+		it.innerDoc = p.innerDocs()
+		it.children = p.items(true)
+		p.Accept("}")
+	} else {
+		p.Accept(";")
+	}
+	p.end(it)
+	return it
+}
+
+func (p *parser) use() *item {
+	kw := p.Toks[p.I]
+	p.I++
+	it := &item{kind: "use", line: kw.Line, col: kw.Col + 1}
+	from, to := p.Until(false, ";")
+	p.Accept(";")
+	p.end(it)
+	it.sig = "use " + p.Text(from, to)
+	it.uses = p.useTree("", from, to)
+	return it
+}
+
+// useTree expands a use tree into the paths it imports.
+func (p *parser) useTree(prefix string, from, to int) []usePath {
+	var out []usePath
+	path := prefix
+	join := func(seg string) {
+		if path == "" {
+			path = seg
+		} else {
+			path += "::" + seg
+		}
+	}
+	for i := from; i < to; i++ {
+		t := p.Toks[i]
+		switch {
+		case t.Is("::"):
+			if i == from && prefix == "" {
+				path = "::"
+				continue
+			}
+		case t.Is("*"):
+			return append(out, usePath{path: path, glob: true})
+		case t.Is("{"):
+			save := p.I
+			p.I = i
+			gfrom, gto := p.Group()
+			p.I = save
+			for _, part := range p.Split(gfrom, gto) {
+				out = append(out, p.useTree(path, part[0], part[1])...)
+			}
+			return out
+		case t.Is("as") && i+1 < to:
+			return append(out, usePath{path: path, alias: p.Toks[i+1].Text})
+		case t.Kind == outline.Ident:
+			if t.Is("self") && path != "" && i+1 >= to {
+				return append(out, usePath{path: path})
+			}
+			if path == "::" {
+				path = t.Text
+			} else {
+				join(t.Text)
+			}
+		}
+	}
+	if path != "" && path != prefix {
+		out = append(out, usePath{path: path})
+	}
+	return out
+}
+
+func (p *parser) externCrate() *item {
+	kw := p.Toks[p.I]
+	p.I += 2
+	it := &item{kind: "extern_crate", line: kw.Line, col: kw.Col + 1}
+	from, to := p.Until(false, ";")
+	p.Accept(";")
+	p.end(it)
+	u := usePath{}
+	if to > from {
+		u.path = p.Toks[from].Text
+		if to-from == 3 && p.Toks[from+1].Is("as") {
+			u.alias = p.Toks[from+2].Text
+		}
+	}
+	it.uses = []usePath{u}
+	it.sig = "extern crate " + p.Text(from, to)
+	return it
+}
+
+// externBlock parses extern "C" { ... }, whose items belong to the
+// enclosing module.
+func (p *parser) externBlock() *item {
+	p.I++
+	abi := "C"
+	if p.Peek(0).Kind == outline.String {
+		abi = strings.Trim(p.Peek(0).Text, `"`)
+		p.I++
+	}
+	it := &item{kind: "extern"}
+	if p.Accept("{") {
+		p.innerDocs()
+		it.children = p.items(true)
+		p.Accept("}")
+	}
+	for _, c := range it.children {
+		c.quals = append([]string{"extern \"" + abi + "\""}, c.quals...)
+	}
+	return it
+}
+
+func (p *parser) macroRules() *item {
+	p.I += 2
+	it := &item{kind: "macro"}
+	p.name(it)
+	it.sig = "macro_rules! " + it.name
+	if p.Peek(0).Opens() {
+		brace := p.Peek(0).Is("{")
+		p.Group()
+		if !brace {
+			p.Accept(";")
+		}
+	}
+	p.end(it)
+	return it
+}
+
+// macro parses a declarative macro 2.0: macro name(...) { ... }.
+func (p *parser) macro() *item {
+	p.I++
+	it := &item{kind: "macro"}
+	p.name(it)
+	it.sig = "macro " + it.name
+	for p.Peek(0).Opens() {
+		p.Group()
+	}
+	p.end(it)
+	return it
+}
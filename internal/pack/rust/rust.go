@@ -0,0 +1,538 @@
+// Package rust is the language pack for Rust. It reads sources without a
+// compiler: a token-level outline parser finds the structs, enums, unions,
+// traits, impl blocks, functions, constants, statics, type aliases, macros
+// and modules of a file, with their attributes and doc comments.
+//
+// Each crate of a Cargo.toml package is a container: its library, binaries,
+// examples, tests and benches, found as Cargo finds them. The mod tree of a
+// crate is walked from its root file, and each module, in a file of its own
+// or inline, is a container nested in its parent. Visibility maps onto the
+// symbol's: pub is public, pub(crate), pub(super) and pub(in path) are
+// internal, and anything else is private. An item is no more visible than
+// the modules it is in, nor a field or method than the type it is of, so pub
+// in a private module is private. Methods of impl blocks are
+// members of the type they are for, and impl Trait for Type is an
+// implements relation from the type to the trait.
+package rust
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+
+	"github.com/ChaseHampton/cargoworker/internal/ir"
+	"github.com/ChaseHampton/cargoworker/internal/pack"
+	"github.com/ChaseHampton/cargoworker/internal/pack/outline"
+)
+
+func init() {
+	pack.Register("rust", New())
+}
+
+// Pack extracts Rust. A crate's modules span directories, and impl blocks
+// refer to types declared elsewhere, so crate and module containers and
+// symbol ids are kept in tables across units.
+type Pack struct {
+	mu      sync.Mutex
+	layouts map[string]*layout // by input root
+	tables  *outline.Tables
+}
+
+func New() *Pack {
+	return &Pack{layouts: map[string]*layout{}, tables: outline.NewTables()}
+}
+
+func (p *Pack) Name() string { return "rust" }
+
+var _ pack.Pack = (*Pack)(nil)
+
+// Extract outlines the modules of a unit.
+func (p *Pack) Extract(ctx context.Context, u pack.Unit) (*ir.Fragment, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	l, ok := p.layouts[u.Root]
+	if !ok {
+		l = newLayout(u.Root)
+		p.layouts[u.Root] = l
+	}
+	x := &extraction{l: l, u: u, frag: p.tables.Fragment(u)}
+	for _, rel := range u.Files {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if !strings.HasSuffix(rel, ".rs") {
+			continue
+		}
+		if err := x.module(rel); err != nil {
+			return nil, err
+		}
+	}
+	return x.frag.Fragment, nil
+}
+
+// extraction is the state of one Extract call.
+type extraction struct {
+	l    *layout
+	u    pack.Unit
+	frag *outline.Fragment // containers keyed by full name
+}
+
+// crateExtra is the extra_json of a crate container.
+type crateExtra struct {
+	Target      string `json:"target"` // lib, bin, example, test, bench or build
+	Package     string `json:"package,omitempty"`
+	Dir         string `json:"dir,omitempty"`
+	Root        string `json:"root,omitempty"`
+	Edition     string `json:"edition,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// crateContainer returns the container of a crate, making it.
+func (x *extraction) crateContainer(c *crate) uuid.UUID {
+	return x.frag.Container(c.name, func() ir.Container {
+		ct := ir.Container{Name: c.name, FullName: c.name, Kind: "crate"}
+		ex := crateExtra{Target: c.kind, Root: c.root}
+		if c.pkg != nil {
+			ct.VersionTag = c.pkg.version
+			ex.Package, ex.Dir, ex.Edition, ex.Description = c.pkg.name, c.pkg.dir, c.pkg.edition, c.pkg.description
+		}
+		if m := x.l.lookupMod(c.name); m != nil {
+			ct.DocRaw = m.doc
+		}
+		if ct.DocRaw == "" {
+			ct.DocRaw = ex.Description
+		}
+		b, _ := json.Marshal(ex)
+		ct.ExtraJson = string(b)
+		return ct
+	})
+}
+
+// moduleContainer returns the container of a module of crate c, making it
+// and the modules it is in.
+func (x *extraction) moduleContainer(c *crate, full string) uuid.UUID {
+	i := strings.LastIndex(full, "::")
+	if full == c.name || i < 0 {
+		return x.crateContainer(c)
+	}
+	parent := x.moduleContainer(c, full[:i])
+	return x.frag.Container(full, func() ir.Container {
+		ct := ir.Container{ParentId: parent, Name: full[i+2:], FullName: full, Kind: "module"}
+		if m := x.l.lookupMod(full); m != nil {
+			ct.DocRaw = m.doc
+		}
+		return ct
+	})
+}
+
+func (x *extraction) module(rel string) error {
+	m := x.l.module(rel)
+	cid := x.moduleContainer(m.crate, m.full)
+	src, err := os.ReadFile(filepath.Join(x.u.Root, filepath.FromSlash(rel)))
+	if err != nil {
+		return err
+	}
+	fid := x.frag.File(cid, rel, src)
+	f := x.l.parsedFile(rel)
+	if f == nil {
+		f = parse(string(src))
+	}
+	e := &emitter{x: x, fid: fid, rel: rel, crate: m.crate}
+	e.module(cid, m.full, f.items)
+	return nil
+}
+
+// emitter turns the items of a file into symbols.
+type emitter struct {
+	x     *extraction
+	fid   uuid.UUID
+	rel   string
+	crate *crate
+	vis   string // the visibility of the module being emitted
+}
+
+// extra is the extra_json of a symbol.
+type extra struct {
+	Vis        string   `json:"vis,omitempty"` // as written
+	Qualifiers []string `json:"qualifiers,omitempty"`
+	Derives    []string `json:"derives,omitempty"`
+	Attrs      []string `json:"attrs,omitempty"`
+	Impl       string   `json:"impl,omitempty"`  // the type of the impl block of a method
+	Trait      string   `json:"trait,omitempty"` // the trait that impl block implements
+	Sections   []string `json:"doc_sections,omitempty"`
+}
+
+// module emits the items of a module, and of the inline modules in it.
+func (e *emitter) module(cid uuid.UUID, full string, items []*item) {
+	outer := e.vis
+	e.vis = "public"
+	if m := e.x.l.lookupMod(full); m != nil {
+		e.vis = m.vis
+	}
+	defer func() { e.vis = outer }()
+
+	var flat []*item
+	for _, it := range items {
+		if it.kind == "extern" {
+			flat = append(flat, it.children...)
+		} else {
+			flat = append(flat, it)
+		}
+	}
+	res := e.resolver(full, flat)
+	e.imports(cid, full, res, flat)
+	order := 0
+	for _, it := range flat {
+		switch it.kind {
+		case "use", "extern_crate":
+		case "mod":
+			if it.inline && it.name != "" {
+				child := full + "::" + it.name
+				e.module(e.x.moduleContainer(e.crate, child), child, it.children)
+			}
+		case "impl":
+			e.impl(cid, full, res, it)
+		default:
+			if it.name == "" {
+				continue
+			}
+			if it.kind == "function" && procMacro(it) != "" {
+				e.procMacro(it)
+				continue
+			}
+			if it.kind == "macro" && hasAttr(it, "macro_export") {
+				e.emit(e.x.crateContainer(e.crate), nil, e.crate.name+"::"+it.name, it, "public", extra{}, res, nil, 0)
+				continue
+			}
+			e.emit(cid, nil, full+"::"+it.name, it, narrower(e.vis, visibility(it.vis)), extra{}, res, nil, order)
+			order++
+		}
+	}
+}
+
+// resolver returns the resolver of the names in a module: its items, and
+// the names its use declarations and extern crates bring into scope.
+func (e *emitter) resolver(full string, items []*item) *resolver {
+	r := &resolver{module: full, crate: e.crate.name, uses: map[string]string{}, defs: map[string]bool{}}
+	for _, it := range items {
+		switch it.kind {
+		case "use", "extern_crate", "impl":
+		default:
+			if it.name != "" {
+				r.defs[it.name] = true
+			}
+		}
+	}
+	for _, it := range items {
+		for _, u := range it.uses {
+			name := localName(u)
+			if u.glob || name == "" || name == "_" {
+				continue
+			}
+			if it.kind == "extern_crate" {
+				r.uses[name] = u.path
+				continue
+			}
+			r.uses[name] = r.absolute(u.path)
+		}
+	}
+	return r
+}
+
+// localName returns the name a use path binds.
+func localName(u usePath) string {
+	if u.alias != "" {
+		return u.alias
+	}
+	return u.path[strings.LastIndex(u.path, ":")+1:]
+}
+
+// imports records the paths a module's use declarations and extern crates
+// import, and emits aliases for the paths it re-exports.
+func (e *emitter) imports(cid uuid.UUID, full string, res *resolver, items []*item) {
+	type key struct{ target, alias string }
+	type details struct {
+		File   string `json:"file"`
+		Line   int    `json:"line"`
+		Glob   bool   `json:"glob,omitempty"`
+		Pub    bool   `json:"pub,omitempty"`
+		Kind   string `json:"kind,omitempty"` // extern_crate; empty for use
+		Stdlib bool   `json:"stdlib,omitempty"`
+	}
+	seen := map[key]bool{}
+	for _, it := range items {
+		if it.kind != "use" && it.kind != "extern_crate" {
+			continue
+		}
+		for _, u := range it.uses {
+			if u.path == "" {
+				continue
+			}
+			target := u.path
+			if it.kind == "use" {
+				target = res.absolute(u.path)
+			}
+			k := key{target, u.alias}
+			if !seen[k] {
+				seen[k] = true
+				d := details{File: e.rel, Line: it.line, Glob: u.glob, Pub: it.vis != "", Stdlib: isStdlib(target)}
+				if it.kind == "extern_crate" {
+					d.Kind = it.kind
+				}
+				b, _ := json.Marshal(d)
+				e.x.frag.Imports = append(e.x.frag.Imports, ir.Import{ContainerId: cid, Target: target, Alias: u.alias, DetailsJson: string(b)})
+			}
+			if it.kind == "use" && it.vis != "" && !u.glob {
+				e.alias(cid, full, it, u, target)
+			}
+		}
+	}
+}
+
+// alias emits a name a module re-exports with pub use.
+func (e *emitter) alias(cid uuid.UUID, module string, it *item, u usePath, target string) {
+	name := localName(u)
+	if name == "_" || name == "self" {
+		return
+	}
+	full := module + "::" + name
+	id := e.x.frag.NewSymbolID(full)
+	e.x.frag.Symbols = append(e.x.frag.Symbols, ir.Symbol{
+		Id: id, ContainerId: cid, Name: name, FullName: full, Kind: "alias",
+		Visibility: narrower(e.vis, visibility(it.vis)), OriginFileId: e.fid,
+		StartLine: it.line, StartCol: it.col, EndLine: it.endLine, EndCol: it.endCol, DocRaw: it.doc,
+	})
+	sig := it.vis + " use " + u.path
+	if u.alias != "" {
+		sig += " as " + u.alias
+	}
+	e.x.frag.Signatures = append(e.x.frag.Signatures, ir.Signature{SymbolId: id, Text: sig})
+	b, _ := json.Marshal(ref{Type: "alias", Symbol: target})
+	e.x.frag.Typerefs = append(e.x.frag.Typerefs, ir.Typeref{Id: uuid.New(), OwnerSymbolId: id, Slot: "target", Json: string(b)})
+}
+
+// visibility maps a Rust visibility onto a symbol's.
+func visibility(vis string) string {
+	switch vis {
+	case "pub":
+		return "public"
+	case "", "pub(self)":
+		return "private"
+	}
+	return "internal"
+}
+
+// narrower returns the narrower of two symbol visibilities.
+func narrower(a, b string) string {
+	rank := map[string]int{"private": 0, "internal": 1, "public": 2}
+	if rank[b] < rank[a] {
+		return b
+	}
+	return a
+}
+
+// kinds maps item kinds onto symbol kinds, where they differ.
+var kinds = map[string]string{"static": "variable"}
+
+// emit emits an item and its fields, variants or associated items. owner
+// is the symbol it is a member of, and params the generic parameters in
+// scope.
+func (e *emitter) emit(cid uuid.UUID, owner *uuid.UUID, full string, it *item, vis string, ex extra, res *resolver, params map[string]bool, order int) uuid.UUID {
+	id := e.x.frag.NewSymbolID(full)
+	kind := it.kind
+	if k, ok := kinds[kind]; ok {
+		kind = k
+	}
+	sym := ir.Symbol{
+		Id: id, ContainerId: cid, Name: it.name, FullName: full, Kind: kind, Visibility: vis,
+		OriginFileId: e.fid, StartLine: it.line, StartCol: it.col, EndLine: it.endLine, EndCol: it.endCol,
+		DocRaw: it.doc,
+	}
+	ex.Vis, ex.Qualifiers, ex.Sections = it.vis, it.quals, sections(it.doc)
+	for _, a := range it.attrs {
+		if d, ok := strings.CutPrefix(a, "derive("); ok {
+			for _, n := range strings.Split(strings.TrimSuffix(d, ")"), ",") {
+				if n = strings.TrimSpace(n); n != "" {
+					ex.Derives = append(ex.Derives, n)
+				}
+			}
+		} else {
+			ex.Attrs = append(ex.Attrs, a)
+		}
+	}
+	if b, _ := json.Marshal(ex); string(b) != "{}" {
+		sym.ExtraJson = string(b)
+	}
+	e.x.frag.Symbols = append(e.x.frag.Symbols, sym)
+	if owner != nil {
+		e.x.frag.Members = append(e.x.frag.Members, ir.Member{Id: uuid.New(), OwnerSymbolId: *owner, ChildSymbolId: id, Order: order})
+	}
+	scope := map[string]bool{}
+	for k := range params {
+		scope[k] = true
+	}
+	for _, g := range it.generics {
+		scope[g.Name] = true
+	}
+	e.signature(id, owner, it, res, scope)
+
+	// Enum variants and trait items are as visible as their owner.
+	childVis := func(c *item) string { return narrower(vis, visibility(c.vis)) }
+	if it.kind == "enum" || it.kind == "enum_member" || it.kind == "trait" {
+		childVis = func(*item) string { return vis }
+	}
+	for i, c := range it.children {
+		if c.name == "" {
+			continue
+		}
+		e.emit(cid, &id, full+"::"+c.name, c, childVis(c), extra{}, res, scope, i)
+	}
+	return id
+}
+
+// impl emits the items of an impl block as members of the type it is for,
+// and an implements relation for a trait impl.
+func (e *emitter) impl(cid uuid.UUID, module string, res *resolver, it *item) {
+	params := map[string]bool{}
+	for _, g := range it.generics {
+		params[g.Name] = true
+	}
+	self := base(it.self)
+	first, _, _ := strings.Cut(self, "::")
+	var owner *uuid.UUID
+	prefix := module + "::" + self[strings.LastIndex(self, ":")+1:]
+	if r, ok := res.resolve(self); ok && r.Symbol != "" && !params[first] && !isStdlib(r.Symbol) {
+		id := e.x.frag.SymbolID(r.Symbol)
+		owner, prefix = &id, r.Symbol
+	}
+	if it.trait != "" && !it.negative && owner != nil {
+		if r, ok := res.resolve(base(it.trait)); ok && r.Symbol != "" && !isStdlib(r.Symbol) {
+			b, _ := json.Marshal(map[string]string{"file": e.rel, "trait": it.trait, "type": it.self})
+			e.x.frag.Relations = append(e.x.frag.Relations, ir.Relation{
+				SourceSymbolId: *owner, Relation: "implements", DstSymbolId: e.x.frag.SymbolID(r.Symbol), DetailsJson: string(b),
+			})
+		}
+	}
+	for i, c := range it.children {
+		if c.name == "" {
+			continue
+		}
+		vis := narrower(e.vis, visibility(c.vis))
+		if it.trait != "" {
+			vis = e.vis // trait items are as visible as the trait
+		}
+		e.emit(cid, owner, prefix+"::"+c.name, c, vis, extra{Impl: it.self, Trait: it.trait}, res, params, i)
+	}
+}
+
+// procMacro returns the name of the macro a procedural macro function
+// defines, or "".
+func procMacro(it *item) string {
+	for _, a := range it.attrs {
+		switch {
+		case a == "proc_macro", a == "proc_macro_attribute":
+			return it.name
+		case strings.HasPrefix(a, "proc_macro_derive("):
+			name, _, _ := strings.Cut(strings.TrimPrefix(a, "proc_macro_derive("), ",")
+			return strings.TrimSpace(strings.TrimSuffix(name, ")"))
+		}
+	}
+	return ""
+}
+
+// procMacro emits a procedural macro, which its crate exports at its root.
+func (e *emitter) procMacro(it *item) {
+	m := *it
+	m.kind, m.name = "macro", procMacro(it)
+	e.emit(e.x.crateContainer(e.crate), nil, e.crate.name+"::"+m.name, &m, "public", extra{}, nil, nil, 0)
+}
+
+func hasAttr(it *item, name string) bool {
+	for _, a := range it.attrs {
+		if a == name {
+			return true
+		}
+	}
+	return false
+}
+
+// sections returns the headings of a doc comment: Examples, Panics, Errors,
+// Safety and the like.
+func sections(doc string) []string {
+	var out []string
+	fence := false
+	for _, l := range strings.Split(doc, "\n") {
+		t := strings.TrimSpace(l)
+		switch {
+		case strings.HasPrefix(t, "```"):
+			fence = !fence
+		case !fence && strings.HasPrefix(t, "# "):
+			out = append(out, strings.TrimSpace(t[2:]))
+		}
+	}
+	return out
+}
+
+// signature records the signature and type references of an item. params
+// holds the generic parameters in scope.
+func (e *emitter) signature(id uuid.UUID, owner *uuid.UUID, it *item, res *resolver, params map[string]bool) {
+	if it.sig == "" {
+		return
+	}
+	sig := ir.Signature{SymbolId: id, Text: it.sig}
+	type result struct {
+		Type string `json:"type"`
+	}
+	js := struct {
+		Params     []param   `json:"params,omitempty"`
+		Results    []result  `json:"results,omitempty"`
+		TypeParams []generic `json:"type_params,omitempty"`
+	}{TypeParams: it.generics}
+	switch it.kind {
+	case "function", "method":
+		js.Params = it.params
+		if js.Params == nil {
+			js.Params = []param{}
+		}
+		if it.returns != "" {
+			js.Results = []result{{it.returns}}
+		}
+		for i, pr := range it.params {
+			if pr.Name != "self" {
+				e.typerefs(res, id, fmt.Sprintf("param:%d", i), pr.Type, "", params)
+			}
+		}
+		e.typerefs(res, id, "result:0", it.returns, "", params)
+	case "trait":
+		for i, b := range it.bounds {
+			e.typerefs(res, id, fmt.Sprintf("base:%d", i), b, "trait", params)
+		}
+	case "field":
+		if owner != nil {
+			e.typerefs(res, *owner, "field:"+it.name, it.typ, "", params)
+		}
+	default:
+		e.typerefs(res, id, "type", it.typ, "", params)
+	}
+	if b, _ := json.Marshal(js); string(b) != "{}" {
+		sig.Json = string(b)
+	}
+	e.x.frag.Signatures = append(e.x.frag.Signatures, sig)
+}
+
+func (e *emitter) typerefs(res *resolver, owner uuid.UUID, slot, typ, kind string, params map[string]bool) {
+	if typ == "" || res == nil {
+		return
+	}
+	for i, r := range res.refs(typ, params) {
+		r.Type = kind
+		b, _ := json.Marshal(r)
+		e.x.frag.Typerefs = append(e.x.frag.Typerefs, ir.Typeref{Id: uuid.New(), OwnerSymbolId: owner, Slot: slot, Json: string(b), Order: i})
+	}
+}
@@ -0,0 +1,331 @@
+package rust
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/ChaseHampton/cargoworker/internal/ir"
+	"github.com/ChaseHampton/cargoworker/internal/pack/packtest"
+)
+
+var tree = map[string]string{
+	"Cargo.toml": `[workspace]
+members = ["shapes"]
+`,
+	"shapes/Cargo.toml": `[package]
+name = "acme-shapes"
+version = "0.3.1"
+edition = "2021"
+description = "Shapes and areas."
+
+[[bin]]
+name = "draw"
+path = "src/draw.rs"
+`,
+	"shapes/src/lib.rs": `//! Shapes and the areas they cover.
+#![deny(missing_docs)]
+
+pub mod geo;
+mod util;
+#[path = "extra/colors.rs"]
+pub mod colors;
+
+pub use geo::point::Point;
+pub use crate::geo::*;
+use std::fmt::{self, Display};
+extern crate serde as sd;
+
+/// Anything with an area.
+pub trait Shape: Display + Named {
+    /// The area it covers.
+    fn area(&self) -> f64;
+    type Pen;
+}
+
+pub(crate) trait Named {
+    fn name(&self) -> &str;
+}
+
+/// A square.
+///
+/// # Examples
+///
+/// ` + "```" + `
+/// # use acme_shapes::Square;
+/// let s = Square::new(2.0);
+/// ` + "```" + `
+#[derive(Debug, Clone)]
+#[non_exhaustive]
+pub struct Square {
+    /// The length of a side.
+    pub side: f64,
+    origin: Point,
+}
+
+impl Square {
+    /// Makes a square.
+    pub fn new(side: f64) -> Self { Square { side, origin: Point::default() } }
+    fn corner(&self) -> &Point { &self.origin }
+}
+
+impl Shape for Square {
+    fn area(&self) -> f64 { self.side * self.side }
+    type Pen = util::Pen;
+}
+
+impl Display for Square {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result { write!(f, "square") }
+}
+
+pub enum Kind {
+    Square(Square),
+    Circle { radius: f64 },
+}
+
+pub const MAX_SIDES: u32 = 12;
+static mut COUNT: usize = 0;
+
+/// Makes a square.
+#[macro_export]
+macro_rules! square {
+    ($s:expr) => { $crate::Square::new($s) };
+}
+
+pub(crate) unsafe fn bump<'a, T: Shape + 'a>(shapes: &'a [T]) -> Option<Area> where T: Clone {
+    let c = '\'';
+    None
+}
+
+pub type Area = f64;
+
+#[cfg(test)]
+mod tests {
+    //! Tests.
+    use super::*;
+    #[test]
+    fn area() {}
+}
+
+mod inner { pub fn f() {} }
+`,
+	"shapes/src/util.rs": `pub struct Pen(pub u8, String);
+`,
+	"shapes/src/geo/mod.rs": `//! Geometry.
+pub mod point;
+pub(super) fn origin() -> point::Point { point::Point::default() }
+`,
+	"shapes/src/geo/point.rs": `/// A point in the plane.
+#[derive(Default)]
+pub struct Point { pub x: f64, pub y: f64 }
+
+impl super::super::Named for Point {
+    fn name(&self) -> &str { "point" }
+}
+`,
+	"shapes/src/extra/colors.rs": `pub static RED: &str = r#"#f00"#;
+`,
+	"shapes/src/draw.rs": `use acme_shapes::{Shape, Square};
+fn main() {}
+`,
+	"shapes/tests/it.rs": `#[test]
+fn squares() {}
+`,
+	"scripts/tool.rs": `fn main() {}
+`,
+}
+
+func TestExtract(t *testing.T) {
+	f := packtest.ExtractTree(t, tree, packtest.ByExt(map[string]string{".rs": "rust"}))
+
+	containers := map[string]ir.Container{}
+	for _, c := range f.Containers {
+		if _, dup := containers[c.FullName]; dup {
+			t.Errorf("duplicate container %s", c.FullName)
+		}
+		containers[c.FullName] = c
+	}
+	lib := containers["acme_shapes"]
+	if lib.Kind != "crate" || lib.ParentId != uuid.Nil || lib.VersionTag != "0.3.1" || lib.DocRaw != "Shapes and the areas they cover." ||
+		!strings.HasPrefix(lib.ExtraJson, `{"target":"lib","package":"acme-shapes","dir":"shapes","root":"shapes/src/lib.rs"`) {
+		t.Errorf("lib = %+v", lib)
+	}
+	for name, want := range map[string]string{
+		"acme_shapes::geo":        "module acme_shapes Geometry.",
+		"acme_shapes::geo::point": "module acme_shapes::geo ",
+		"acme_shapes::colors":     "module acme_shapes ",
+		"acme_shapes::tests":      "module acme_shapes Tests.",
+		"draw":                    "crate  Shapes and areas.",
+		"it":                      "crate  Shapes and areas.",
+		"tool":                    "crate  ",
+	} {
+		c := containers[name]
+		parent := ""
+		for full, p := range containers {
+			if p.Id == c.ParentId && c.ParentId != uuid.Nil {
+				parent = full
+			}
+		}
+		if got := c.Kind + " " + parent + " " + c.DocRaw; got != want {
+			t.Errorf("container %s = %q, want %q", name, got, want)
+		}
+	}
+
+	syms := map[string]ir.Symbol{}
+	for _, s := range f.Symbols {
+		if _, dup := syms[s.FullName]; dup {
+			t.Errorf("duplicate symbol %s", s.FullName)
+		}
+		syms[s.FullName] = s
+	}
+	for name, want := range map[string]string{
+		"acme_shapes::Shape":                   "trait public",
+		"acme_shapes::Shape::area":             "method public",
+		"acme_shapes::Shape::Pen":              "type public",
+		"acme_shapes::Named":                   "trait internal",
+		"acme_shapes::Named::name":             "method internal",
+		"acme_shapes::Square":                  "struct public",
+		"acme_shapes::Square::side":            "field public",
+		"acme_shapes::Square::origin":          "field private",
+		"acme_shapes::Square::new":             "method public",
+		"acme_shapes::Square::corner":          "method private",
+		"acme_shapes::Square::area":            "method public",
+		"acme_shapes::Square::fmt":             "method public",
+		"acme_shapes::Kind::Circle":            "enum_member public",
+		"acme_shapes::Kind::Circle::radius":    "field public",
+		"acme_shapes::Kind::Square::0":         "field public",
+		"acme_shapes::MAX_SIDES":               "constant public",
+		"acme_shapes::COUNT":                   "variable private",
+		"acme_shapes::square":                  "macro public",
+		"acme_shapes::bump":                    "function internal",
+		"acme_shapes::Area":                    "type public",
+		"acme_shapes::Point":                   "alias public",
+		"acme_shapes::tests::area":             "function private",
+		"acme_shapes::util::Pen::0":            "field private",
+		"acme_shapes::inner::f":                "function private",
+		"acme_shapes::util::Pen::1":            "field private",
+		"acme_shapes::geo::origin":             "function internal",
+		"acme_shapes::geo::point::Point::name": "method public",
+		"acme_shapes::colors::RED":             "variable public",
+		"draw::main":                           "function private",
+		"tool::main":                           "function private",
+	} {
+		if got := syms[name].Kind + " " + syms[name].Visibility; got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	if sq := syms["acme_shapes::Square"]; sq.StartLine != 35 || sq.StartCol != 12 || sq.EndLine != 39 ||
+		!strings.HasPrefix(sq.DocRaw, "A square.\n\n# Examples") ||
+		sq.ExtraJson != `{"vis":"pub","derives":["Debug","Clone"],"attrs":["non_exhaustive"],"doc_sections":["Examples"]}` {
+		t.Errorf("Square = %+v", sq)
+	}
+	if fmt := syms["acme_shapes::Square::fmt"]; fmt.ExtraJson != `{"impl":"Square","trait":"Display"}` {
+		t.Errorf("fmt extra = %s", fmt.ExtraJson)
+	}
+
+	owners := map[uuid.UUID]string{}
+	for name, s := range syms {
+		owners[s.Id] = name
+	}
+	var rels []string
+	for _, r := range f.Relations {
+		rels = append(rels, owners[r.SourceSymbolId]+" "+r.Relation+" "+owners[r.DstSymbolId])
+	}
+	sort.Strings(rels)
+	if got, want := strings.Join(rels, "\n"), "acme_shapes::Square implements acme_shapes::Shape\nacme_shapes::geo::point::Point implements acme_shapes::Named"; got != want {
+		t.Errorf("relations =\n%s\nwant\n%s", got, want)
+	}
+	members := map[string]string{}
+	for _, m := range f.Members {
+		members[owners[m.ChildSymbolId]] = owners[m.OwnerSymbolId]
+	}
+	for child, owner := range map[string]string{
+		"acme_shapes::Square::new":             "acme_shapes::Square",
+		"acme_shapes::Square::fmt":             "acme_shapes::Square",
+		"acme_shapes::Shape::area":             "acme_shapes::Shape",
+		"acme_shapes::Kind::Circle::radius":    "acme_shapes::Kind::Circle",
+		"acme_shapes::geo::point::Point::name": "acme_shapes::geo::point::Point",
+	} {
+		if members[child] != owner {
+			t.Errorf("owner of %s = %q, want %q", child, members[child], owner)
+		}
+	}
+
+	sigs := map[uuid.UUID]ir.Signature{}
+	for _, s := range f.Signatures {
+		sigs[s.SymbolId] = s
+	}
+	for name, want := range map[string]string{
+		"acme_shapes::bump":         "pub(crate) unsafe fn bump<'a, T: Shape + 'a>(shapes: &'a [T]) -> Option<Area> where T: Clone",
+		"acme_shapes::Shape":        "pub trait Shape: Display + Named",
+		"acme_shapes::Kind::Circle": "Kind::Circle { radius: f64 }",
+		"acme_shapes::colors::RED":  `pub static RED: &str = r#"#f00"#`,
+		"acme_shapes::Point":        "pub use geo::point::Point",
+	} {
+		if got := sigs[syms[name].Id].Text; got != want {
+			t.Errorf("%s sig = %s, want %s", name, got, want)
+		}
+	}
+	if got, want := sigs[syms["acme_shapes::bump"].Id].Json,
+		`{"params":[{"name":"shapes","type":"\u0026'a [T]"}],"results":[{"type":"Option\u003cArea\u003e"}],"type_params":[{"name":"'a"},{"name":"T","constraint":"Shape + 'a"}]}`; got != want {
+		t.Errorf("bump sig json = %s\nwant %s", got, want)
+	}
+
+	refs := map[string][]string{}
+	for _, tr := range f.Typerefs {
+		key := owners[tr.OwnerSymbolId] + " " + tr.Slot
+		refs[key] = append(refs[key], tr.Json)
+	}
+	for key, want := range map[string]string{
+		"acme_shapes::Shape base:0":         `{"symbol":"std::fmt::Display","type":"trait","text":"Display"}`,
+		"acme_shapes::Shape base:1":         `{"symbol":"acme_shapes::Named","type":"trait","text":"Named"}`,
+		"acme_shapes::Square field:origin":  `{"symbol":"acme_shapes::geo::point::Point","text":"Point"}`,
+		"acme_shapes::Square::fmt param:1":  `{"symbol":"std::fmt::Formatter","text":"\u0026mut fmt::Formatter\u003c'_\u003e"}`,
+		"acme_shapes::Square::Pen type":     `{"symbol":"acme_shapes::util::Pen","text":"util::Pen"}`,
+		"acme_shapes::bump param:0":         "", // a type parameter
+		"acme_shapes::bump result:0":        `{"symbol":"acme_shapes::Area","text":"Option\u003cArea\u003e"}`,
+		"acme_shapes::geo::origin result:0": `{"symbol":"acme_shapes::geo::point::Point","text":"point::Point"}`,
+		"acme_shapes::Point target":         `{"symbol":"acme_shapes::geo::point::Point","type":"alias"}`,
+		"acme_shapes::Kind::Square field:0": `{"symbol":"acme_shapes::Square","text":"Square"}`,
+		"acme_shapes::util::Pen field:1":    "",
+	} {
+		if got := strings.Join(refs[key], " "); got != want {
+			t.Errorf("typerefs %s = %s, want %s", key, got, want)
+		}
+	}
+
+	var imports []string
+	for _, im := range f.Imports {
+		imports = append(imports, im.Target+" "+im.Alias+" "+im.DetailsJson)
+	}
+	for _, want := range []string{
+		`acme_shapes::geo::point::Point  {"file":"shapes/src/lib.rs","line":9,"pub":true}`,
+		`acme_shapes::geo  {"file":"shapes/src/lib.rs","line":10,"glob":true,"pub":true}`,
+		`std::fmt  {"file":"shapes/src/lib.rs","line":11,"stdlib":true}`,
+		`std::fmt::Display  {"file":"shapes/src/lib.rs","line":11,"stdlib":true}`,
+		`serde sd {"file":"shapes/src/lib.rs","line":12,"kind":"extern_crate"}`,
+		`acme_shapes::Square  {"file":"shapes/src/draw.rs","line":1}`,
+	} {
+		found := false
+		for _, im := range imports {
+			found = found || im == want
+		}
+		if !found {
+			t.Errorf("missing import %s in\n%s", want, strings.Join(imports, "\n"))
+		}
+	}
+}
+
+func TestLex(t *testing.T) {
+	src := "/// Doc.\nfn f<'a>(x: &'a str) -> char { let s = br##\"a\"#b\"##; /* a /* nested */ b */ 'x' }\n//! Inner.\n"
+	var got []string
+	for _, tok := range lex(src) {
+		got = append(got, tok.Text)
+	}
+	want := []string{" Doc.", "fn", "f", "<", "'a", ">", "(", "x", ":", "&", "'a", "str", ")", "->", "char", "{", "let", "s", "=", `br##"a"#b"##`, ";", "'x'", "}", " Inner."}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("tokens = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,182 @@
+package shell
+
+import "strings"
+
+// fishParser finds the functions, exports and sources of a fish script.
+type fishParser struct {
+	src    string
+	toks   []token
+	i      int
+	s      *script
+	blocks []*function // the blocks open, nil for those other than functions
+	seen   map[string]bool
+}
+
+// fishBlocks are the commands that open a block closed by end.
+var fishBlocks = map[string]bool{"if": true, "while": true, "for": true, "switch": true, "begin": true}
+
+// fishPrefixes are the words a command may start with before its name.
+var fishPrefixes = map[string]bool{"and": true, "or": true, "not": true, "!": true, "builtin": true, "command": true, "exec": true, "time": true, "else": true}
+
+func parseFish(src string) *script {
+	toks, lead := lex(src, true)
+	p := &fishParser{src: src, toks: toks, s: &script{}, seen: map[string]bool{}}
+	p.run()
+	p.s.header = header(lead, nil, false, 0)
+	for _, t := range toks {
+		if t.kind != tNewline {
+			p.s.header = header(lead, &t, t.is("function"), 0)
+			break
+		}
+	}
+	return p.s
+}
+
+func (p *fishParser) run() {
+	for p.i < len(p.toks) {
+		t := p.toks[p.i]
+		if t.kind == tNewline || t.kind == tOp {
+			p.i++
+			continue
+		}
+		var words []token
+		for ; p.i < len(p.toks); p.i++ {
+			t := p.toks[p.i]
+			if t.kind == tNewline || (t.kind == tOp && separators[t.text]) {
+				break
+			}
+			if t.kind == tOp {
+				p.i++ // a redirection and its target
+				continue
+			}
+			words = append(words, t)
+		}
+		p.command(words)
+	}
+	if n := len(p.toks); n > 0 {
+		for _, f := range p.blocks {
+			if f != nil {
+				f.endLine, f.endCol = p.toks[n-1].endLine, p.toks[n-1].endCol
+			}
+		}
+	}
+}
+
+// command reads one command: the start or end of a block, or a simple
+// command.
+func (p *fishParser) command(words []token) {
+	for len(words) > 0 && fishPrefixes[words[0].text] {
+		if words[0].text == "else" && len(words) > 1 && words[1].text == "if" {
+			words = words[2:] // else if continues the block of its if
+			continue
+		}
+		words = words[1:]
+	}
+	if len(words) == 0 {
+		return
+	}
+	switch name := words[0].text; {
+	case name == "function":
+		p.def(words)
+	case fishBlocks[name]:
+		p.blocks = append(p.blocks, nil)
+		p.command(words[1:]) // begin, at least, may have a command after it
+	case name == "end":
+		if n := len(p.blocks) - 1; n >= 0 {
+			if f := p.blocks[n]; f != nil {
+				f.endLine, f.endCol = words[0].endLine, words[0].endCol
+			}
+			p.blocks = p.blocks[:n]
+		}
+	case name == "set":
+		p.set(words)
+	case name == "source" || name == ".":
+		if len(words) > 1 && words[1].text != "-" {
+			p.s.sources = append(p.s.sources, &source{target: unquote(words[1].text), kind: "source", line: words[0].line})
+		}
+	}
+}
+
+// def reads the line that defines a function: its name, and the
+// description and argument names among its options.
+func (p *fishParser) def(words []token) {
+	if len(words) < 2 {
+		return
+	}
+	first, last := words[0], words[len(words)-1]
+	f := &function{
+		name: unquote(words[1].text), sig: collapse(p.src[first.pos:last.end]), doc: adjacent(first),
+		line: first.line, col: first.col, endLine: last.endLine, endCol: last.endCol,
+	}
+	args := false
+	for i := 2; i < len(words); i++ {
+		w := unquote(words[i].text)
+		opt, value, hasValue := strings.Cut(w, "=")
+		switch {
+		case opt == "-d" || opt == "--description":
+			args = false
+			if hasValue {
+				f.desc = value
+			} else if i+1 < len(words) {
+				i++
+				f.desc = unquote(words[i].text)
+			}
+		case opt == "-a" || opt == "--argument-names":
+			args = true
+			if hasValue {
+				f.params = append(f.params, param{Name: value})
+			}
+		case strings.HasPrefix(w, "-"):
+			args = false
+		case args:
+			f.params = append(f.params, param{Name: w})
+		}
+	}
+	p.s.funcs = append(p.s.funcs, f)
+	p.blocks = append(p.blocks, f)
+}
+
+// set reads the variables a set with -x or --export exports.
+func (p *fishParser) set(words []token) {
+	exported := false
+	args := words[1:]
+flags:
+	for len(args) > 0 && strings.HasPrefix(args[0].text, "-") {
+		switch w := args[0].text; {
+		case w == "--":
+			args = args[1:]
+			break flags
+		case w == "--export":
+			exported = true
+		case w == "--unexport" || w == "--erase" || w == "--query" || w == "--names" || w == "--show":
+			return
+		case strings.HasPrefix(w, "--"):
+		case strings.ContainsAny(w, "ueqnS"):
+			return
+		case strings.Contains(w, "x"):
+			exported = true
+		}
+		args = args[1:]
+	}
+	if !exported || len(args) == 0 {
+		return
+	}
+	name, _, _ := strings.Cut(unquote(args[0].text), "[")
+	if !identifier.MatchString(name) || p.seen[name] {
+		return
+	}
+	p.seen[name] = true
+	var values []string
+	for _, w := range args[1:] {
+		values = append(values, unquote(w.text))
+	}
+	doc := adjacent(words[0])
+	if doc == "" {
+		doc = words[len(words)-1].trail
+	}
+	w := args[0]
+	p.s.exports = append(p.s.exports, &export{
+		name: name, value: strings.Join(values, " "), stmt: p.src[words[0].pos:words[len(words)-1].end], doc: doc,
+		line: w.line, col: w.col, endLine: w.endLine, endCol: w.endCol,
+	})
+}
@@ -0,0 +1,224 @@
+package shell
+
+import (
+	"regexp"
+	"strings"
+)
+
+// help is PowerShell comment-based help: the .SYNOPSIS, .DESCRIPTION,
+// .PARAMETER and other sections of a comment.
+type help struct {
+	Synopsis    string     `json:"synopsis,omitempty"`
+	Description string     `json:"description,omitempty"`
+	Params      []docParam `json:"params,omitempty"`
+	Examples    []string   `json:"examples,omitempty"`
+	Inputs      []string   `json:"inputs,omitempty"`
+	Outputs     []string   `json:"outputs,omitempty"`
+	Notes       string     `json:"notes,omitempty"`
+	Links       []string   `json:"links,omitempty"`
+}
+
+type docParam struct {
+	Name string `json:"name"`
+	Desc string `json:"desc,omitempty"`
+}
+
+// helpKeyword matches the line that starts a section of comment-based
+// help, with its argument: the name after .PARAMETER.
+var helpKeyword = regexp.MustCompile(`(?i)^\.(synopsis|description|parameter|example|inputs|outputs|notes|link|component|role|functionality|forwardhelptargetname|forwardhelpcategory|remotehelprunspace|externalhelp)\b\s*(.*)$`)
+
+// parseHelp parses the cleaned text of a comment as comment-based help,
+// returning nil if it has no .SYNOPSIS or .DESCRIPTION, as PowerShell
+// requires.
+func parseHelp(text string) *help {
+	var h help
+	found := false
+	var section, arg string
+	var body []string
+	flush := func() {
+		s := strings.TrimSpace(strings.Join(dedent(body), "\n"))
+		switch section {
+		case "synopsis":
+			h.Synopsis = s
+		case "description":
+			h.Description = s
+		case "parameter":
+			h.Params = append(h.Params, docParam{Name: arg, Desc: s})
+		case "example":
+			h.Examples = append(h.Examples, s)
+		case "inputs":
+			h.Inputs = append(h.Inputs, s)
+		case "outputs":
+			h.Outputs = append(h.Outputs, s)
+		case "notes":
+			h.Notes = s
+		case "link":
+			h.Links = append(h.Links, strings.TrimSpace(arg+"\n"+s))
+		}
+		body = nil
+	}
+	for _, line := range strings.Split(text, "\n") {
+		m := helpKeyword.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			body = append(body, line)
+			continue
+		}
+		flush()
+		section, arg = strings.ToLower(m[1]), strings.TrimSpace(m[2])
+		found = found || section == "synopsis" || section == "description"
+	}
+	flush()
+	if !found {
+		return nil
+	}
+	return &h
+}
+
+// param returns the help of a parameter.
+func (h *help) param(name string) string {
+	if h == nil {
+		return ""
+	}
+	for _, p := range h.Params {
+		if strings.EqualFold(p.Name, name) {
+			return p.Desc
+		}
+	}
+	return ""
+}
+
+// format returns the doc_fmt of comment-based help: the synopsis and
+// description, then sections for the parameters, examples, inputs,
+// outputs, notes and links.
+func (h *help) format() string {
+	var b strings.Builder
+	para := func(s string) {
+		if s == "" {
+			return
+		}
+		if b.Len() > 0 {
+			b.WriteString("\n\n")
+		}
+		b.WriteString(s)
+	}
+	indent := func(s string) string { return "    " + strings.ReplaceAll(s, "\n", "\n    ") }
+	section := func(title string, items []docParam) {
+		var lines []string
+		for _, it := range items {
+			switch {
+			case it.Name == "":
+				lines = append(lines, indent(it.Desc))
+			case it.Desc == "":
+				lines = append(lines, indent(it.Name))
+			default:
+				lines = append(lines, indent(it.Name+": "+it.Desc))
+			}
+		}
+		if len(lines) > 0 {
+			para(title + ":\n" + strings.Join(lines, "\n"))
+		}
+	}
+	texts := func(list []string) []docParam {
+		var out []docParam
+		for _, s := range list {
+			if s != "" {
+				out = append(out, docParam{Desc: s})
+			}
+		}
+		return out
+	}
+	para(h.Synopsis)
+	para(h.Description)
+	section("Parameters", h.Params)
+	section("Examples", texts(h.Examples))
+	section("Inputs", texts(h.Inputs))
+	section("Outputs", texts(h.Outputs))
+	section("Notes", texts([]string{h.Notes}))
+	section("Links", texts(h.Links))
+	return b.String()
+}
+
+// usageLine matches the line of a header comment that starts its usage
+// text.
+var usageLine = regexp.MustCompile(`(?i)^usage\s*:?\s*(.*)$`)
+
+// usage returns the usage text of a cleaned header comment: the paragraph
+// starting at its Usage: line, dedented. synopsis is its first line, less
+// the Usage: label, or the line after the label when that is alone on its
+// line.
+func usage(text string) (block, synopsis string) {
+	lines := strings.Split(text, "\n")
+	for i, l := range lines {
+		m := usageLine.FindStringSubmatch(strings.TrimSpace(l))
+		if m == nil {
+			continue
+		}
+		end := i + 1
+		for end < len(lines) && strings.TrimSpace(lines[end]) != "" {
+			end++
+		}
+		synopsis = strings.TrimSpace(m[1])
+		if synopsis == "" && end > i+1 {
+			synopsis = strings.TrimSpace(lines[i+1])
+		}
+		block = strings.TrimSpace(strings.Join(dedent(lines[i:end]), "\n"))
+		return block, synopsis
+	}
+	return "", ""
+}
+
+// dedent removes the common indentation of non-blank lines.
+func dedent(lines []string) []string {
+	margin := -1
+	for _, l := range lines {
+		if t := strings.TrimLeft(l, " \t"); t != "" {
+			if n := len(l) - len(t); margin < 0 || n < margin {
+				margin = n
+			}
+		}
+	}
+	out := make([]string, len(lines))
+	for i, l := range lines {
+		if margin > 0 && len(l) >= margin {
+			l = l[margin:]
+		}
+		out[i] = strings.TrimRight(l, " \t\r")
+	}
+	return out
+}
+
+// directives match the comment lines that are for tools, not readers:
+// shellcheck directives, PowerShell #Requires and editor mode lines.
+var directive = regexp.MustCompile(`(?i)^#\s*(shellcheck\s|requires\s|vim?:|ex:|-\*-.*-\*-\s*$)`)
+
+// cleanComment strips the markers of comments: the # of each line, or the
+// <# and #> of a PowerShell block. Lines for tools are left out.
+func cleanComment(raw string) string {
+	var lines []string
+	for _, l := range strings.Split(raw, "\n") {
+		t := strings.TrimSpace(l)
+		if directive.MatchString(t) {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(t, "<#"):
+			t = strings.TrimPrefix(t, "<#")
+			t = strings.TrimSuffix(t, "#>")
+		case strings.HasSuffix(t, "#>"):
+			t = strings.TrimSuffix(t, "#>")
+		case strings.HasPrefix(t, "#"):
+			t = strings.TrimPrefix(strings.TrimPrefix(t, "#"), " ")
+		default:
+			t = strings.TrimRight(l, " \t\r") // the inside of a block keeps its indentation
+		}
+		lines = append(lines, strings.TrimRight(t, " \t\r"))
+	}
+	lines = dedent(lines)
+	for len(lines) > 0 && lines[0] == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
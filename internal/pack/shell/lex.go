@@ -0,0 +1,420 @@
+package shell
+
+import (
+	"sort"
+	"strings"
+)
+
+// Token kinds.
+const (
+	tWord    = iota // a word, its quotes and expansions included
+	tOp             // an operator: ; && | ( and the others, redirections among them
+	tNewline        // the end of a line outside quotes
+)
+
+type token struct {
+	kind     int
+	text     string // as written
+	pos, end int    // byte offsets in the source
+	line     int    // 1-based
+	col      int    // 1-based
+	endLine  int
+	endCol   int    // 1-based, inclusive
+	doc      string // the comment run above the token's line, markers and all; on the first token of a line
+	gap      int    // the blank lines between doc and the token
+	trail    string // a comment after the token on its line
+}
+
+// is reports whether t is a word or operator, in any case.
+func (t token) is(s string) bool { return t.kind != tNewline && strings.EqualFold(t.text, s) }
+
+// lines maps byte offsets of a source to lines and columns.
+type lines []int // the offset each line starts at
+
+func newLines(src string) lines {
+	ls := lines{0}
+	for i := 0; i < len(src); i++ {
+		if src[i] == '\n' {
+			ls = append(ls, i+1)
+		}
+	}
+	return ls
+}
+
+// at returns the 1-based line and column of an offset.
+func (ls lines) at(pos int) (line, col int) {
+	n := sort.Search(len(ls), func(i int) bool { return ls[i] > pos }) - 1
+	return n + 1, pos - ls[n] + 1
+}
+
+// docs gathers the comments of a source into runs: the comments alone on
+// consecutive lines. A run is the doc of the first token after it.
+type docs struct {
+	run        []string
+	first, end int      // lines of the run
+	lead       []string // the runs before the first token
+	seen       bool     // whether a token has been seen
+}
+
+// comment adds a comment alone on lines [line, endLine].
+func (d *docs) comment(raw string, line, endLine int) {
+	if len(d.run) > 0 && line != d.end+1 {
+		d.flush()
+	}
+	if len(d.run) == 0 {
+		d.first = line
+	}
+	d.run = append(d.run, raw)
+	d.end = endLine
+}
+
+func (d *docs) flush() {
+	if !d.seen && len(d.run) > 0 {
+		d.lead = append(d.lead, strings.Join(d.run, "\n"))
+	}
+	d.run = nil
+}
+
+// attach gives t, the first token of its line, the run above it.
+func (d *docs) attach(t *token) {
+	if len(d.run) > 0 {
+		t.doc, t.gap = strings.Join(d.run, "\n"), t.line-d.end-1
+		d.flush()
+	}
+	d.seen = true
+}
+
+// lexer splits a POSIX or fish script into tokens. It knows enough of the
+// grammar to find where words end: quotes, expansions, here-documents and
+// comments.
+type lexer struct {
+	src      string
+	fish     bool
+	i        int
+	ls       lines
+	d        docs
+	toks     []token
+	lastLine int // the line the last token ends on
+	heredocs []heredoc
+}
+
+// heredoc is a pending here-document, whose body starts on the next line.
+type heredoc struct {
+	delim string
+	strip bool // <<- strips leading tabs
+}
+
+// lex splits a script into tokens, returning them with the comment runs
+// before the first. A #! line is left out.
+func lex(src string, fish bool) ([]token, []string) {
+	l := &lexer{src: src, fish: fish, ls: newLines(src)}
+	if strings.HasPrefix(src, "#!") {
+		l.i = lineEnd(src, 0)
+	}
+	for l.i < len(src) {
+		c := src[l.i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r':
+			l.i++
+		case c == '\\' && l.i+1 < len(src) && src[l.i+1] == '\n':
+			l.i += 2
+		case c == '\n':
+			l.emit(tNewline, l.i, l.i+1)
+			l.i++
+			if len(l.heredocs) > 0 {
+				l.bodies()
+			}
+		case c == '#':
+			l.comment()
+		case !fish && (c == '<' || c == '>') && l.i+1 < len(src) && src[l.i+1] == '(':
+			l.word() // process substitution
+		case fish && c == '(':
+			l.word() // a command substitution
+		case strings.IndexByte(";&|<>()", c) >= 0:
+			l.op()
+		default:
+			l.word()
+		}
+	}
+	l.d.flush()
+	return l.toks, l.d.lead
+}
+
+func (l *lexer) emit(kind, pos, end int) {
+	t := token{kind: kind, text: l.src[pos:end], pos: pos, end: end}
+	t.line, t.col = l.ls.at(pos)
+	t.endLine, t.endCol = l.ls.at(max(pos, end-1))
+	if kind != tNewline {
+		if t.line != l.lastLine {
+			l.d.attach(&t)
+		}
+		l.lastLine = t.endLine
+	}
+	l.toks = append(l.toks, t)
+}
+
+// comment reads a comment to the end of its line.
+func (l *lexer) comment() {
+	start := l.i
+	l.i = lineEnd(l.src, l.i)
+	raw := strings.TrimRight(l.src[start:l.i], "\r")
+	line, _ := l.ls.at(start)
+	if line == l.lastLine {
+		if n := len(l.toks) - 1; n >= 0 && l.toks[n].trail == "" {
+			l.toks[n].trail = raw
+		}
+		return
+	}
+	l.d.comment(raw, line, line)
+}
+
+// operators are the operators of the shells, longest first.
+var operators = []string{";;&", "&>>", "<<<", "<<-", ";;", ";&", "&&", "||", "|&", "&>", ">>", ">&", ">|", "<<", "<&", "<>", ";", "&", "|", "<", ">", "(", ")"}
+
+func (l *lexer) op() {
+	for _, op := range operators {
+		if !strings.HasPrefix(l.src[l.i:], op) || (l.fish && (op == "<<" || op == "<<-" || op == "<<<")) {
+			continue
+		}
+		l.emit(tOp, l.i, l.i+len(op))
+		l.i += len(op)
+		if op == "<<" || op == "<<-" {
+			l.heredoc(op == "<<-")
+		}
+		return
+	}
+	l.i++
+}
+
+// heredoc reads the delimiter of a here-document.
+func (l *lexer) heredoc(strip bool) {
+	for l.i < len(l.src) && (l.src[l.i] == ' ' || l.src[l.i] == '\t') {
+		l.i++
+	}
+	start := l.i
+	l.word()
+	if l.i > start {
+		l.heredocs = append(l.heredocs, heredoc{delim: unquote(l.src[start:l.i]), strip: strip})
+	}
+}
+
+// bodies skips the bodies of the pending here-documents.
+func (l *lexer) bodies() {
+	for _, h := range l.heredocs {
+		for l.i < len(l.src) {
+			end := lineEnd(l.src, l.i)
+			line := strings.TrimRight(l.src[l.i:end], "\r")
+			l.i = min(end+1, len(l.src))
+			if h.strip {
+				line = strings.TrimLeft(line, "\t")
+			}
+			if line == h.delim {
+				break
+			}
+		}
+	}
+	l.heredocs = nil
+}
+
+// word reads a word: up to white space or an operator outside quotes.
+func (l *lexer) word() {
+	start := l.i
+	src := l.src
+loop:
+	for l.i < len(src) {
+		c := src[l.i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			break loop
+		case c == '(' && l.fish:
+			l.group('(', ')')
+		case c == '(' && l.i > start && (src[l.i-1] == '=' || strings.IndexByte("@?*+!<>", src[l.i-1]) >= 0):
+			l.group('(', ')') // an array, an extended glob or a process substitution
+		case (c == '<' || c == '>') && !l.fish && l.i+1 < len(src) && src[l.i+1] == '(':
+			l.i++
+			l.group('(', ')')
+		case strings.IndexByte(";&|<>()", c) >= 0:
+			break loop
+		case c == '\\':
+			l.i += 2
+		case c == '\'':
+			l.squote()
+		case c == '"':
+			l.dquote()
+		case c == '$' && !l.fish && l.i+1 < len(src) && src[l.i+1] == '\'':
+			l.i++
+			l.squote()
+		case c == '$' && l.i+1 < len(src) && src[l.i+1] == '(':
+			l.i++
+			l.group('(', ')')
+		case c == '$' && !l.fish && l.i+1 < len(src) && src[l.i+1] == '{':
+			l.i++
+			l.group('{', '}')
+		case c == '`' && !l.fish:
+			l.backquote()
+		default:
+			l.i++
+		}
+	}
+	l.i = min(l.i, len(src))
+	if l.i > start {
+		l.emit(tWord, start, l.i)
+	}
+}
+
+// squote reads a single-quoted string, in which fish, and the $'...' of
+// the other shells, allow escapes.
+func (l *lexer) squote() {
+	escapes := l.fish || (l.i > 0 && l.src[l.i-1] == '$')
+	for l.i++; l.i < len(l.src); l.i++ {
+		switch l.src[l.i] {
+		case '\\':
+			if escapes {
+				l.i++
+			}
+		case '\'':
+			l.i++
+			return
+		}
+	}
+}
+
+func (l *lexer) dquote() {
+	for l.i++; l.i < len(l.src); {
+		switch c := l.src[l.i]; {
+		case c == '\\':
+			l.i += 2
+		case c == '"':
+			l.i++
+			return
+		case c == '$' && l.i+1 < len(l.src) && (l.src[l.i+1] == '(' || (!l.fish && l.src[l.i+1] == '{')):
+			l.i++
+			if l.src[l.i] == '(' {
+				l.group('(', ')')
+			} else {
+				l.group('{', '}')
+			}
+		case c == '`' && !l.fish:
+			l.backquote()
+		default:
+			l.i++
+		}
+	}
+}
+
+func (l *lexer) backquote() {
+	for l.i++; l.i < len(l.src); l.i++ {
+		switch l.src[l.i] {
+		case '\\':
+			l.i++
+		case '`':
+			l.i++
+			return
+		}
+	}
+}
+
+// group reads a bracketed group at l.i, with its quotes and nested groups.
+func (l *lexer) group(open, close byte) {
+	depth := 0
+	for l.i < len(l.src) {
+		switch c := l.src[l.i]; {
+		case c == open:
+			depth++
+			l.i++
+		case c == close:
+			l.i++
+			if depth--; depth == 0 {
+				return
+			}
+		case c == '\\':
+			l.i += 2
+		case c == '\'':
+			l.squote()
+		case c == '"':
+			l.dquote()
+		case c == '`' && !l.fish:
+			l.backquote()
+		default:
+			l.i++
+		}
+	}
+}
+
+// lineEnd returns the offset of the end of the line at i.
+func lineEnd(src string, i int) int {
+	if n := strings.IndexByte(src[i:], '\n'); n >= 0 {
+		return i + n
+	}
+	return len(src)
+}
+
+// unquote returns the value of a word with its quotes removed. Command
+// substitutions and expansions are kept as written.
+func unquote(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\' && i+1 < len(s):
+			i++
+			b.WriteByte(s[i])
+		case c == '\'':
+			j := strings.IndexByte(s[i+1:], '\'')
+			if j < 0 {
+				j = len(s) - i - 1
+			}
+			b.WriteString(s[i+1 : i+1+j])
+			i += j + 1
+		case c == '$' && i+1 < len(s) && s[i+1] == '\'':
+			// $'...' keeps its escapes
+		case c == '"':
+			for i++; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '$' && i+1 < len(s) && s[i+1] == '(' {
+					j := i + matching(s[i+1:], '(', ')') + 1
+					b.WriteString(s[i:j])
+					i = j - 1
+					continue
+				}
+				if s[i] == '\\' && i+1 < len(s) && strings.IndexByte("$`\"\\", s[i+1]) >= 0 {
+					i++
+				}
+				b.WriteByte(s[i])
+			}
+		case c == '$' && i+1 < len(s) && s[i+1] == '(':
+			j := i + matching(s[i+1:], '(', ')') + 1
+			b.WriteString(s[i:j])
+			i = j - 1
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// matching returns the length of the bracketed group s starts with,
+// quotes inside it skipped.
+func matching(s string, open, close byte) int {
+	depth := 0
+	quote := byte(0)
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '\\':
+			i++
+		case c == open:
+			depth++
+		case c == close:
+			if depth--; depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return len(s)
+}
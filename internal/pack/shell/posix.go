@@ -0,0 +1,320 @@
+package shell
+
+import (
+	"regexp"
+	"strings"
+)
+
+// script is what a scanner finds in a script.
+type script struct {
+	header  string // the header comment, markers and all
+	funcs   []*function
+	exports []*export
+	sources []*source
+	params  []param // of a PowerShell script
+
+	exportList bool     // whether a PowerShell module lists the functions it exports
+	exported   []string // the functions it lists, wildcards and all
+}
+
+// function is a function a script defines.
+type function struct {
+	name    string
+	sig     string
+	doc     string // the comment above it
+	bodyDoc string // the comment-based help in its body, for PowerShell
+	params  []param
+	desc    string // a fish function's --description
+	private bool   // a PowerShell function in the private: scope
+
+	line, col, endLine, endCol int
+}
+
+// export is an environment variable a script exports.
+type export struct {
+	name, value string
+	stmt        string // the statement exporting it
+	doc         string
+
+	line, col, endLine, endCol int
+}
+
+// source is a file a script sources, or a module it imports.
+type source struct {
+	target string
+	kind   string // source, module or requires
+	line   int
+}
+
+// param is a parameter of a function or PowerShell script.
+type param struct {
+	Name      string `json:"name"`
+	Type      string `json:"type,omitempty"`
+	Mandatory bool   `json:"mandatory,omitempty"`
+	Default   string `json:"default,omitempty"`
+	Desc      string `json:"desc,omitempty"`
+}
+
+// header picks the header comment of a script out of the comment runs
+// before its code: the first with more than a directive in it. The run
+// directly above a function defined first, within maxGap blank lines, is
+// that function's doc instead.
+func header(lead []string, first *token, firstIsFunc bool, maxGap int) string {
+	if firstIsFunc && first != nil && first.doc != "" && first.gap <= maxGap && len(lead) > 0 {
+		lead = lead[:len(lead)-1]
+	}
+	for _, run := range lead {
+		if cleanComment(run) != "" {
+			return run
+		}
+	}
+	return ""
+}
+
+// adjacent returns the doc of a token if nothing separates the two.
+func adjacent(t token) string {
+	if t.gap == 0 {
+		return t.doc
+	}
+	return ""
+}
+
+// collapse collapses the runs of white space in s to a space.
+func collapse(s string) string { return strings.Join(strings.Fields(s), " ") }
+
+// posixParser finds the functions, exports and sources of a POSIX, bash,
+// zsh or ksh script.
+type posixParser struct {
+	src    string
+	toks   []token
+	i      int
+	s      *script
+	braces int // depth of { } groups
+	parens int // depth of ( ) subshells
+	open   []opened
+	seen   map[string]bool // exported names
+}
+
+// opened is a function whose body has not ended yet.
+type opened struct {
+	f     *function
+	brace bool // whether the body is a { } group rather than a subshell
+	depth int  // of the body's group
+}
+
+// separators end a command.
+var separators = map[string]bool{
+	";": true, ";;": true, ";&": true, ";;&": true, "&&": true, "||": true, "|": true, "|&": true, "&": true, "(": true, ")": true,
+}
+
+// reserved are the words after which a command starts.
+var reserved = map[string]bool{
+	"if": true, "then": true, "else": true, "elif": true, "fi": true, "do": true, "done": true,
+	"while": true, "until": true, "!": true, "time": true, "esac": true,
+}
+
+var (
+	funcName   = regexp.MustCompile(`^[A-Za-z_][\w.:@+-]*$`)
+	assignment = regexp.MustCompile(`(?s)^([A-Za-z_]\w*)(\[[^\]]*\])?(\+?=)(.*)$`)
+	identifier = regexp.MustCompile(`^[A-Za-z_]\w*$`)
+)
+
+func parsePosix(src string) *script {
+	toks, lead := lex(src, false)
+	p := &posixParser{src: src, toks: toks, s: &script{}, seen: map[string]bool{}}
+	p.run()
+	p.s.header = header(lead, nil, false, 0)
+	for i, t := range toks {
+		if t.kind != tNewline {
+			isFunc := t.is("function") || (i+2 < len(toks) && toks[i+1].is("(") && toks[i+2].is(")"))
+			p.s.header = header(lead, &t, isFunc, 0)
+			break
+		}
+	}
+	return p.s
+}
+
+func (p *posixParser) peek(n int) token {
+	if p.i+n < len(p.toks) {
+		return p.toks[p.i+n]
+	}
+	return token{kind: tNewline}
+}
+
+func (p *posixParser) run() {
+	p.i = 0
+	start := true
+	for p.i < len(p.toks) {
+		t := p.toks[p.i]
+		switch {
+		case t.kind == tNewline || (t.kind == tOp && separators[t.text]):
+			if t.is("(") {
+				p.parens++
+			} else if t.is(")") {
+				p.parens--
+				p.close(false, t)
+			}
+			start = true
+			p.i++
+		case !start || t.kind == tOp:
+			p.i++
+		case t.is("{"):
+			p.braces++
+			p.i++
+		case t.is("}"):
+			p.braces--
+			p.close(true, t)
+			start = false
+			p.i++
+		case reserved[t.text]:
+			p.i++
+		default:
+			start = p.command()
+		}
+	}
+	if n := len(p.toks); n > 0 {
+		for _, o := range p.open {
+			o.f.endLine, o.f.endCol = p.toks[n-1].endLine, p.toks[n-1].endCol
+		}
+	}
+}
+
+// close ends the function whose body t closes, if any.
+func (p *posixParser) close(brace bool, t token) {
+	n := len(p.open) - 1
+	if n < 0 || p.open[n].brace != brace {
+		return
+	}
+	depth := p.parens
+	if brace {
+		depth = p.braces
+	}
+	if depth == p.open[n].depth {
+		p.open[n].f.endLine, p.open[n].f.endCol = t.endLine, t.endCol
+		p.open = p.open[:n]
+	}
+}
+
+// command reads a command at p.i: a function definition or a simple
+// command. It reports whether a command starts at the new p.i, as in the
+// { } body of a function.
+func (p *posixParser) command() bool {
+	first := p.toks[p.i]
+	switch {
+	case first.is("function") && p.peek(1).kind == tWord:
+		name := p.peek(1)
+		last := name
+		p.i += 2
+		if p.peek(0).is("(") && p.peek(1).is(")") {
+			last = p.peek(1)
+			p.i += 2
+		}
+		return p.def(first, last, name.text)
+	case p.peek(1).is("(") && p.peek(2).is(")") && funcName.MatchString(first.text):
+		p.i += 3
+		return p.def(first, p.toks[p.i-1], first.text)
+	}
+	var words []token
+	for ; p.i < len(p.toks); p.i++ {
+		t := p.toks[p.i]
+		if t.kind == tNewline || (t.kind == tOp && separators[t.text]) {
+			break
+		}
+		if t.kind == tOp {
+			p.i++ // a redirection and its target
+			continue
+		}
+		words = append(words, t)
+	}
+	p.simple(words)
+	return false
+}
+
+// def reads the body of a function defined by tokens [first, last],
+// reporting whether it is a { } group.
+func (p *posixParser) def(first, last token, name string) bool {
+	for p.i < len(p.toks) && p.toks[p.i].kind == tNewline {
+		p.i++
+	}
+	f := &function{
+		name: name, sig: collapse(p.src[first.pos:last.end]), doc: adjacent(first),
+		line: first.line, col: first.col, endLine: last.endLine, endCol: last.endCol,
+	}
+	p.s.funcs = append(p.s.funcs, f)
+	switch body := p.peek(0); {
+	case body.is("{"):
+		p.open = append(p.open, opened{f: f, brace: true, depth: p.braces})
+		return true
+	case body.is("("):
+		p.open = append(p.open, opened{f: f, depth: p.parens})
+	default:
+		f.endLine, f.endCol = body.endLine, body.endCol
+	}
+	return false
+}
+
+// simple reads the exports and sources of a simple command.
+func (p *posixParser) simple(words []token) {
+	for len(words) > 0 && assignment.MatchString(words[0].text) {
+		words = words[1:]
+	}
+	for len(words) > 0 && (words[0].is("builtin") || words[0].is("command")) {
+		words = words[1:]
+	}
+	if len(words) == 0 {
+		return
+	}
+	switch name := unquote(words[0].text); name {
+	case "export", "declare", "typeset", "readonly":
+		p.export(name, words)
+	case "source", ".":
+		if len(words) > 1 {
+			p.s.sources = append(p.s.sources, &source{target: unquote(words[1].text), kind: "source", line: words[0].line})
+		}
+	}
+}
+
+// export reads the variables an export, or a declare, typeset or readonly
+// with -x, exports.
+func (p *posixParser) export(cmd string, words []token) {
+	flags := ""
+	args := words[1:]
+	for len(args) > 0 && (strings.HasPrefix(args[0].text, "-") || strings.HasPrefix(args[0].text, "+")) {
+		if args[0].text == "--" {
+			args = args[1:]
+			break
+		}
+		if strings.HasPrefix(args[0].text, "+") {
+			flags += "+" // unexports, or unsets an attribute
+		}
+		flags += args[0].text[1:]
+		args = args[1:]
+	}
+	switch {
+	case strings.ContainsAny(flags, "nfFp+"):
+		return
+	case cmd != "export" && !strings.Contains(flags, "x"):
+		return
+	}
+	stmt := p.src[words[0].pos:words[len(words)-1].end]
+	doc := adjacent(words[0])
+	for _, w := range words {
+		if doc == "" && w.trail != "" {
+			doc = w.trail
+		}
+	}
+	for _, w := range args {
+		name, value := w.text, ""
+		if m := assignment.FindStringSubmatch(w.text); m != nil {
+			name, value = m[1], unquote(m[4])
+		}
+		if !identifier.MatchString(name) || p.seen[name] {
+			continue
+		}
+		p.seen[name] = true
+		p.s.exports = append(p.s.exports, &export{
+			name: name, value: value, stmt: stmt, doc: doc,
+			line: w.line, col: w.col, endLine: w.endLine, endCol: w.endCol,
+		})
+	}
+}
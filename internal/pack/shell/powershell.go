@@ -0,0 +1,820 @@
+package shell
+
+import (
+	"path"
+	"regexp"
+	"strings"
+)
+
+// psLexer splits a PowerShell script into tokens: words, with their
+// strings, here-strings and subexpressions; the brackets, braces and
+// separators as operators; and newlines. #Requires comments are kept
+// apart, as they import modules.
+type psLexer struct {
+	src      string
+	i        int
+	ls       lines
+	d        docs
+	toks     []token
+	lastLine int
+	requires []comment
+}
+
+// comment is a comment and the line it is on.
+type comment struct {
+	text string
+	line int
+}
+
+// lexPS splits a PowerShell script into tokens, returning them with the
+// comment runs before the first token and the run after the last.
+func lexPS(src string) (toks []token, lead []string, tail string, requires []comment) {
+	l := &psLexer{src: src, ls: newLines(src)}
+	for l.i < len(src) {
+		c := src[l.i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\f':
+			l.i++
+		case c == '`' && l.i+1 < len(src) && (src[l.i+1] == '\n' || src[l.i+1] == '\r'):
+			l.i += 2 // a line continuation
+		case c == '\n':
+			l.emit(tNewline, l.i, l.i+1)
+			l.i++
+		case strings.HasPrefix(src[l.i:], "<#"):
+			l.block()
+		case c == '#':
+			l.comment()
+		case c == '@' && l.i+1 < len(src) && (src[l.i+1] == '(' || src[l.i+1] == '{'):
+			l.emit(tOp, l.i, l.i+1) // an array or a hash table
+			l.i++
+		case strings.HasPrefix(src[l.i:], "&&") || strings.HasPrefix(src[l.i:], "||"):
+			l.emit(tOp, l.i, l.i+2)
+			l.i += 2
+		case strings.IndexByte(psOps, c) >= 0:
+			l.emit(tOp, l.i, l.i+1)
+			l.i++
+		default:
+			l.word()
+		}
+	}
+	if l.d.seen && len(l.d.run) > 0 {
+		tail = strings.Join(l.d.run, "\n")
+	}
+	l.d.flush()
+	return l.toks, l.d.lead, tail, l.requires
+}
+
+// psOps are the characters that are operators alone.
+const psOps = "{}()[],;|&="
+
+func (l *psLexer) emit(kind, pos, end int) {
+	t := token{kind: kind, text: l.src[pos:end], pos: pos, end: end}
+	t.line, t.col = l.ls.at(pos)
+	t.endLine, t.endCol = l.ls.at(max(pos, end-1))
+	if kind != tNewline {
+		if t.line != l.lastLine {
+			l.d.attach(&t)
+		}
+		l.lastLine = t.endLine
+	}
+	l.toks = append(l.toks, t)
+}
+
+var requiresLine = regexp.MustCompile(`(?i)^#requires\s`)
+
+func (l *psLexer) comment() {
+	start := l.i
+	l.i = lineEnd(l.src, l.i)
+	raw := strings.TrimRight(l.src[start:l.i], "\r")
+	line, _ := l.ls.at(start)
+	if requiresLine.MatchString(raw) {
+		l.requires = append(l.requires, comment{raw, line})
+	}
+	if line == l.lastLine {
+		if n := len(l.toks) - 1; n >= 0 && l.toks[n].trail == "" {
+			l.toks[n].trail = raw
+		}
+		return
+	}
+	l.d.comment(raw, line, line)
+}
+
+// block reads a <# #> comment.
+func (l *psLexer) block() {
+	start := l.i
+	end := strings.Index(l.src[l.i+2:], "#>")
+	if end < 0 {
+		l.i = len(l.src)
+	} else {
+		l.i += 2 + end + 2
+	}
+	raw := l.src[start:l.i]
+	line, _ := l.ls.at(start)
+	endLine, _ := l.ls.at(l.i - 1)
+	if line == l.lastLine && line == endLine {
+		if n := len(l.toks) - 1; n >= 0 && l.toks[n].trail == "" {
+			l.toks[n].trail = raw
+		}
+		return
+	}
+	l.d.comment(raw, line, endLine)
+	// A token after the comment on its last line is not documented by it.
+	if i := l.i; i < len(l.src) {
+		for i < len(l.src) && (l.src[i] == ' ' || l.src[i] == '\t' || l.src[i] == '\r') {
+			i++
+		}
+		if i < len(l.src) && l.src[i] != '\n' && l.src[i] != '#' {
+			l.d.flush()
+		}
+	}
+}
+
+// word reads a word: up to white space or an operator outside quotes.
+func (l *psLexer) word() {
+	start := l.i
+	src := l.src
+loop:
+	for l.i < len(src) {
+		c := src[l.i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f':
+			break loop
+		case c == '`':
+			l.i += 2
+		case c == '@' && l.i+1 < len(src) && (src[l.i+1] == '\'' || src[l.i+1] == '"') && l.hereString():
+		case c == '\'':
+			l.squote()
+		case c == '"':
+			l.dquote()
+		case c == '$' && l.i+1 < len(src) && src[l.i+1] == '(':
+			l.i++
+			l.group()
+		case c == '$' && l.i+1 < len(src) && src[l.i+1] == '{':
+			if j := strings.IndexByte(src[l.i:], '}'); j >= 0 {
+				l.i += j + 1
+			} else {
+				l.i = len(src)
+			}
+		case strings.IndexByte(psOps, c) >= 0:
+			break loop
+		default:
+			l.i++
+		}
+	}
+	l.i = min(l.i, len(src))
+	if l.i > start {
+		l.emit(tWord, start, l.i)
+	} else {
+		l.i++
+	}
+}
+
+// hereString reads a here-string at l.i, reporting whether there is one:
+// @' or @" must end their line.
+func (l *psLexer) hereString() bool {
+	q := l.src[l.i+1]
+	j := l.i + 2
+	for j < len(l.src) && (l.src[j] == ' ' || l.src[j] == '\t' || l.src[j] == '\r') {
+		j++
+	}
+	if j < len(l.src) && l.src[j] != '\n' {
+		return false
+	}
+	for j < len(l.src) {
+		j = lineEnd(l.src, j) + 1
+		if j < len(l.src) && l.src[j] == q && j+1 < len(l.src) && l.src[j+1] == '@' {
+			l.i = j + 2
+			return true
+		}
+	}
+	l.i = len(l.src)
+	return true
+}
+
+func (l *psLexer) squote() {
+	for l.i++; l.i < len(l.src); l.i++ {
+		if l.src[l.i] == '\'' {
+			if l.i+1 < len(l.src) && l.src[l.i+1] == '\'' {
+				l.i++
+				continue
+			}
+			l.i++
+			return
+		}
+	}
+}
+
+func (l *psLexer) dquote() {
+	for l.i++; l.i < len(l.src); {
+		switch c := l.src[l.i]; {
+		case c == '`':
+			l.i += 2
+		case c == '"' && l.i+1 < len(l.src) && l.src[l.i+1] == '"':
+			l.i += 2
+		case c == '"':
+			l.i++
+			return
+		case c == '$' && l.i+1 < len(l.src) && l.src[l.i+1] == '(':
+			l.i++
+			l.group()
+		default:
+			l.i++
+		}
+	}
+}
+
+// group reads a parenthesized subexpression at l.i.
+func (l *psLexer) group() {
+	depth := 0
+	for l.i < len(l.src) {
+		switch c := l.src[l.i]; {
+		case c == '(':
+			depth++
+			l.i++
+		case c == ')':
+			l.i++
+			if depth--; depth == 0 {
+				return
+			}
+		case c == '`':
+			l.i += 2
+		case c == '\'':
+			l.squote()
+		case c == '"':
+			l.dquote()
+		default:
+			l.i++
+		}
+	}
+}
+
+// psUnquote returns the value of a PowerShell word with its quotes
+// removed. Variables and subexpressions are kept as written.
+func psUnquote(s string) string {
+	if len(s) >= 2 && (s[0] == '\'' || s[0] == '"') && s[len(s)-1] == s[0] {
+		q := s[:1]
+		s = strings.ReplaceAll(s[1:len(s)-1], q+q, q)
+		if q == `"` {
+			s = strings.NewReplacer("`n", "\n", "`t", "\t", "``", "`", "`\"", `"`, "`$", "$").Replace(s)
+		}
+		return s
+	}
+	if strings.HasPrefix(s, "@'") || strings.HasPrefix(s, `@"`) {
+		s = strings.TrimSpace(s[2 : len(s)-2])
+	}
+	return s
+}
+
+// psParser finds the functions, parameters, exports and imports of a
+// PowerShell script.
+type psParser struct {
+	src    string
+	toks   []token
+	i      int
+	s      *script
+	braces int
+	parens int
+	open   []psOpened
+	seen   map[string]bool
+	begun  bool // whether the script has had a statement other than param
+}
+
+// psOpened is a function whose body has not ended yet.
+type psOpened struct {
+	f     *function
+	depth int // of the body's braces
+}
+
+func parsePowerShell(src string) *script {
+	toks, lead, tail, requires := lexPS(src)
+	p := &psParser{src: src, toks: toks, s: &script{}, seen: map[string]bool{}}
+	p.run()
+	for _, r := range requires {
+		p.requires(r)
+	}
+	p.s.header = header(lead, nil, false, 1)
+	for _, t := range toks {
+		if t.kind != tNewline {
+			isFunc := t.is("function") || t.is("filter") || t.is("workflow")
+			p.s.header = header(lead, &t, isFunc, 1)
+			break
+		}
+	}
+	if parseHelp(cleanComment(p.s.header)) == nil && parseHelp(cleanComment(tail)) != nil {
+		p.s.header = tail // help may end a script too
+	}
+	return p.s
+}
+
+func (p *psParser) peek(n int) token {
+	if p.i+n < len(p.toks) {
+		return p.toks[p.i+n]
+	}
+	return token{kind: tNewline}
+}
+
+func (p *psParser) run() {
+	start := true
+	for p.i < len(p.toks) {
+		t := p.toks[p.i]
+		switch {
+		case t.kind == tNewline || t.is(";") || t.is("|") || t.is("&&") || t.is("||"):
+			start = true
+			p.i++
+		case t.is("{"):
+			p.braces++
+			start = true
+			p.i++
+		case t.is("}"):
+			p.braces--
+			p.close(t)
+			start = true
+			p.i++
+		case t.is("("):
+			p.parens++
+			start = true
+			p.i++
+		case t.is(")"):
+			p.parens--
+			p.i++
+		case !start || t.kind == tOp && !t.is("["):
+			p.i++
+		default:
+			start = p.statement()
+		}
+	}
+	if n := len(p.toks); n > 0 {
+		for _, o := range p.open {
+			o.f.endLine, o.f.endCol = p.toks[n-1].endLine, p.toks[n-1].endCol
+		}
+	}
+}
+
+// close ends the function whose body t closes, if any.
+func (p *psParser) close(t token) {
+	n := len(p.open) - 1
+	if n < 0 || p.open[n].depth != p.braces {
+		return
+	}
+	f := p.open[n].f
+	f.endLine, f.endCol = t.endLine, t.endCol
+	if f.bodyDoc == "" && parseHelp(cleanComment(t.doc)) != nil {
+		f.bodyDoc = t.doc // help may end a body
+	}
+	p.open = p.open[:n]
+}
+
+// statement reads a statement at p.i, reporting whether the next token
+// still starts one, as after an attribute.
+func (p *psParser) statement() bool {
+	t := p.toks[p.i]
+	if t.is("[") {
+		end := p.bracket(p.i)
+		if p.setEnvironment(end) {
+			return false
+		}
+		p.i = end
+		return true
+	}
+	body := p.body()
+	if body == nil && p.braces == 0 && p.parens == 0 && !t.is("param") {
+		p.begun = true
+	}
+	switch {
+	case (t.is("function") || t.is("filter") || t.is("workflow")) && p.peek(1).kind == tWord:
+		return p.def()
+	case t.is("param") && p.peek(1).is("("):
+		params := p.params(p.i + 1)
+		switch {
+		case body != nil && body.f.params == nil:
+			body.f.params = params
+		case body == nil && p.braces == 0 && !p.begun && p.s.params == nil:
+			p.s.params = params
+		}
+		p.i = p.paren(p.i + 1)
+		return false
+	case t.is(".") && p.peek(1).kind == tWord && p.peek(1).line == t.line:
+		p.s.sources = append(p.s.sources, &source{target: psUnquote(p.peek(1).text), kind: "source", line: t.line})
+	case t.is("using") && p.peek(1).is("module") && p.peek(2).kind == tWord:
+		p.s.sources = append(p.s.sources, &source{target: psUnquote(p.peek(2).text), kind: "module", line: t.line})
+	case t.is("Import-Module") || t.is("ipmo"):
+		for _, name := range p.args(psImportOptions, "-Name") {
+			p.s.sources = append(p.s.sources, &source{target: name, kind: "module", line: t.line})
+		}
+	case t.is("Export-ModuleMember"):
+		p.s.exportList = true
+		p.s.exported = append(p.s.exported, p.args(nil, "-Function")...)
+	case t.is("Set-Item") || t.is("New-Item"):
+		p.setItem()
+	case envVar.MatchString(t.text):
+		p.envAssign()
+	}
+	p.i++
+	return false
+}
+
+// body returns the function whose body p.i is directly in, if any.
+func (p *psParser) body() *psOpened {
+	if n := len(p.open) - 1; n >= 0 && p.open[n].depth == p.braces-1 {
+		return &p.open[n]
+	}
+	return nil
+}
+
+// bracket returns the index after the ] that closes the [ at i.
+func (p *psParser) bracket(i int) int {
+	depth := 0
+	for ; i < len(p.toks); i++ {
+		switch {
+		case p.toks[i].is("["):
+			depth++
+		case p.toks[i].is("]"):
+			if depth--; depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return i
+}
+
+// paren returns the index after the ) that closes the ( at i.
+func (p *psParser) paren(i int) int {
+	depth := 0
+	for ; i < len(p.toks); i++ {
+		switch {
+		case p.toks[i].is("("):
+			depth++
+		case p.toks[i].is(")"):
+			if depth--; depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return i
+}
+
+// def reads a function definition: its name, the parameters in
+// parentheses after it, if any, and the start of its body. It reports
+// whether a body was started.
+func (p *psParser) def() bool {
+	first, nameTok := p.toks[p.i], p.toks[p.i+1]
+	p.i += 2
+	name := nameTok.text
+	private := false
+	if scope, rest, ok := strings.Cut(name, ":"); ok {
+		name, private = rest, strings.EqualFold(scope, "private")
+	}
+	f := &function{
+		name: name, doc: adjacentHelp(first), private: private,
+		line: first.line, col: first.col, endLine: nameTok.endLine, endCol: nameTok.endCol,
+	}
+	if p.peek(0).is("(") {
+		f.params = p.params(p.i)
+		p.i = p.paren(p.i)
+	}
+	for p.i < len(p.toks) && p.toks[p.i].kind == tNewline {
+		p.i++
+	}
+	p.s.funcs = append(p.s.funcs, f)
+	if !p.peek(0).is("{") {
+		return false
+	}
+	for j := p.i + 1; j < len(p.toks); j++ {
+		if p.toks[j].kind != tNewline {
+			if parseHelp(cleanComment(p.toks[j].doc)) != nil {
+				f.bodyDoc = p.toks[j].doc // help may start a body
+			}
+			break
+		}
+	}
+	p.open = append(p.open, psOpened{f: f, depth: p.braces})
+	p.braces++
+	p.i++
+	return true
+}
+
+// adjacentHelp returns the doc of a function keyword: the comment directly
+// above it, or comment-based help with at most one blank line between.
+func adjacentHelp(t token) string {
+	if t.gap == 0 || (t.gap == 1 && parseHelp(cleanComment(t.doc)) != nil) {
+		return t.doc
+	}
+	return ""
+}
+
+// params reads the parameters in the parentheses at i: a param block or
+// those after a function's name.
+func (p *psParser) params(i int) []param {
+	end := p.paren(i) - 1
+	var out []param
+	from := i + 1
+	depth := 0
+	for j := from; j <= end; j++ {
+		t := p.toks[j]
+		switch {
+		case t.is("(") || t.is("[") || t.is("{"):
+			depth++
+		case t.is(")") || t.is("]") || t.is("}"):
+			depth--
+		}
+		if (depth == 0 && t.is(",")) || j == end {
+			if pr, ok := p.param(from, j); ok {
+				out = append(out, pr)
+			}
+			from = j + 1
+		}
+	}
+	if out == nil {
+		out = []param{}
+	}
+	return out
+}
+
+// mandatory matches a Parameter attribute that makes its parameter
+// mandatory.
+var mandatory = regexp.MustCompile(`(?i)^parameter\s*\(.*\bmandatory\b\s*(\)|,|=\s*\$true)`)
+
+// param reads the parameter in tokens [from, to): its attributes and type,
+// its name and its default.
+func (p *psParser) param(from, to int) (param, bool) {
+	for from < to && p.toks[from].kind == tNewline {
+		from++
+	}
+	var pr param
+	var doc string
+	for j := from; j < to; {
+		t := p.toks[j]
+		switch {
+		case t.kind == tNewline:
+			j++
+		case t.is("["):
+			if doc == "" {
+				doc = t.doc
+			}
+			end := p.bracket(j)
+			inner := strings.TrimSpace(p.src[t.end:p.toks[end-1].pos])
+			if strings.Contains(inner, "(") {
+				pr.Mandatory = pr.Mandatory || mandatory.MatchString(collapse(inner))
+			} else {
+				pr.Type = inner
+			}
+			j = end
+		case strings.HasPrefix(t.text, "$"):
+			if doc == "" {
+				doc = t.doc
+			}
+			pr.Name = strings.TrimPrefix(t.text, "$")
+			if j+1 < to && p.toks[j+1].is("=") && j+2 < to {
+				last := to - 1
+				for last > j+2 && p.toks[last].kind == tNewline {
+					last--
+				}
+				pr.Default = collapse(p.src[p.toks[j+2].pos:p.toks[last].end])
+			}
+			for _, k := range []int{to, to - 1} {
+				if doc == "" && k < len(p.toks) {
+					doc = p.toks[k].trail
+				}
+			}
+			pr.Desc = cleanComment(doc)
+			return pr, pr.Name != ""
+		default:
+			j++
+		}
+	}
+	return pr, false
+}
+
+// psImportOptions are the switches of Import-Module; its other options
+// take a value.
+var psImportOptions = map[string]bool{
+	"-force": true, "-global": true, "-passthru": true, "-ascustomobject": true, "-disablenamechecking": true,
+	"-noclobber": true, "-skipeditioncheck": true, "-usewindowspowershell": true, "-verbose": true,
+}
+
+// args returns the values of a command's option named by list, or of its
+// first positional argument, to the end of the statement: the names, say,
+// of Import-Module a, b.
+func (p *psParser) args(switches map[string]bool, list string) []string {
+	var out []string
+	positional := true
+	j := p.i + 1
+	for j < len(p.toks) {
+		t := p.toks[j]
+		switch {
+		case t.kind == tNewline || t.is(";") || t.is("|") || t.is("}"):
+			return out
+		case strings.HasPrefix(t.text, "-") && t.kind == tWord:
+			opt := strings.ToLower(t.text)
+			j++
+			switch {
+			case strings.EqualFold(opt, list):
+				out = append(out, p.list(&j)...)
+			case !switches[opt]:
+				p.list(&j) // the value of another option
+			}
+		case positional:
+			positional = false
+			out = append(out, p.list(&j)...)
+		default:
+			j++
+		}
+	}
+	return out
+}
+
+// list reads a comma-separated list of words at *j.
+func (p *psParser) list(j *int) []string {
+	var out []string
+	for *j < len(p.toks) {
+		t := p.toks[*j]
+		if t.kind != tWord {
+			return out
+		}
+		out = append(out, psUnquote(t.text))
+		*j++
+		if *j >= len(p.toks) || !p.toks[*j].is(",") {
+			return out
+		}
+		*j++
+	}
+	return out
+}
+
+// envVar matches a word naming an environment variable: $env:NAME or
+// ${env:NAME}.
+var envVar = regexp.MustCompile(`(?i)^\$\{?env:([A-Za-z_]\w*)\}?\+?$`)
+
+// envAssign reads an assignment to an environment variable.
+func (p *psParser) envAssign() {
+	t := p.toks[p.i]
+	if !p.peek(1).is("=") {
+		return
+	}
+	end := p.i + 2
+	for end < len(p.toks) && p.toks[end].kind != tNewline && !p.toks[end].is(";") && !p.toks[end].is("}") {
+		end++
+	}
+	value := ""
+	if end > p.i+2 {
+		value = psUnquote(collapse(p.src[p.toks[p.i+2].pos:p.toks[end-1].end]))
+	}
+	p.export(envVar.FindStringSubmatch(t.text)[1], value, t, p.toks[end-1])
+	p.i = end - 1
+}
+
+// setEnvironment reads an [Environment]::SetEnvironmentVariable call
+// whose type ends at i, reporting whether there is one.
+func (p *psParser) setEnvironment(i int) bool {
+	if i-1 <= p.i || !p.toks[i-1].is("]") {
+		return false // an unclosed bracket
+	}
+	typ := strings.ToLower(strings.TrimSpace(p.src[p.toks[p.i].end:p.toks[i-1].pos]))
+	if (typ != "environment" && typ != "system.environment") || i+1 >= len(p.toks) ||
+		!strings.EqualFold(p.toks[i].text, "::SetEnvironmentVariable") || !p.toks[i+1].is("(") {
+		return false
+	}
+	first := p.toks[p.i]
+	end := p.paren(i + 1)
+	j := i + 2
+	args := p.list(&j)
+	if len(args) >= 1 && identifier.MatchString(args[0]) {
+		value := ""
+		if len(args) >= 2 {
+			value = args[1]
+		}
+		p.export(args[0], value, first, p.toks[end-1])
+	}
+	p.i = end
+	return true
+}
+
+// setItem reads a Set-Item or New-Item of a path on the Env: drive.
+func (p *psParser) setItem() {
+	t := p.toks[p.i]
+	var pos []string
+	var name, value string
+	j := p.i + 1
+	for j < len(p.toks) && p.toks[j].kind == tWord {
+		w := p.toks[j]
+		j++
+		switch opt := strings.ToLower(w.text); {
+		case (opt == "-path" || opt == "-name" || opt == "-literalpath") && j < len(p.toks):
+			name = psUnquote(p.toks[j].text)
+			j++
+		case opt == "-value" && j < len(p.toks):
+			value = psUnquote(p.toks[j].text)
+			j++
+		case strings.HasPrefix(opt, "-"):
+		default:
+			pos = append(pos, psUnquote(w.text))
+		}
+	}
+	if name == "" && len(pos) > 0 {
+		name, pos = pos[0], pos[1:]
+	}
+	if value == "" && len(pos) > 0 {
+		value = pos[0]
+	}
+	lower := strings.ToLower(name)
+	if !strings.HasPrefix(lower, "env:") {
+		return
+	}
+	name = strings.TrimLeft(name[4:], `\/`)
+	if identifier.MatchString(name) {
+		p.export(name, value, t, p.toks[j-1])
+	}
+	p.i = j - 1
+}
+
+// export records a variable exported by the statement of tokens [first,
+// last].
+func (p *psParser) export(name, value string, first, last token) {
+	if p.seen[strings.ToLower(name)] {
+		return
+	}
+	p.seen[strings.ToLower(name)] = true
+	doc := adjacent(first)
+	if doc == "" {
+		doc = last.trail
+	}
+	p.s.exports = append(p.s.exports, &export{
+		name: name, value: value, stmt: p.src[first.pos:last.end], doc: doc,
+		line: first.line, col: first.col, endLine: last.endLine, endCol: last.endCol,
+	})
+}
+
+// requires reads the modules of a #Requires -Modules comment: names, or
+// hash tables with a ModuleName.
+func (p *psParser) requires(c comment) {
+	m := requiresModules.FindStringSubmatch(c.text)
+	if m == nil {
+		return
+	}
+	for _, spec := range splitTop(m[1]) {
+		name := strings.TrimSpace(spec)
+		if strings.HasPrefix(name, "@{") {
+			mm := moduleName.FindStringSubmatch(name)
+			if mm == nil {
+				continue
+			}
+			name = mm[1]
+		}
+		if name = psUnquote(name); name != "" {
+			p.s.sources = append(p.s.sources, &source{target: name, kind: "requires", line: c.line})
+		}
+	}
+}
+
+var (
+	requiresModules = regexp.MustCompile(`(?i)^#requires\s.*-modules\s+(.*?)\s*(\s-\w+.*)?$`)
+	moduleName      = regexp.MustCompile(`(?i)modulename\s*=\s*['"]?([^'";}]+)`)
+)
+
+// splitTop splits s at the commas outside braces.
+func splitTop(s string) []string {
+	var out []string
+	depth, from := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				out = append(out, s[from:i])
+				from = i + 1
+			}
+		}
+	}
+	return append(out, s[from:])
+}
+
+// psSyntax returns the syntax of a command the way Get-Help shows it:
+// its name and parameters, the optional ones in brackets.
+func psSyntax(name string, params []param) string {
+	parts := []string{name}
+	for _, pr := range params {
+		s := "-" + pr.Name
+		if pr.Type != "" && !strings.EqualFold(pr.Type, "switch") {
+			s += " <" + pr.Type + ">"
+		}
+		if !pr.Mandatory {
+			s = "[" + s + "]"
+		}
+		parts = append(parts, s)
+	}
+	return strings.Join(parts, " ")
+}
+
+// exportedBy reports whether a module's export list names a function, by
+// name or wildcard.
+func exportedBy(list []string, name string) bool {
+	for _, pat := range list {
+		if ok, _ := path.Match(strings.ToLower(pat), strings.ToLower(name)); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,313 @@
+// Package shell is the language pack for shell scripts: POSIX sh, bash,
+// zsh and ksh, fish, and PowerShell. It reads scripts without a shell: a
+// lexer that knows their quoting, expansions, here-documents and comments
+// feeds a command-level parser that finds the functions a script defines,
+// the environment variables it exports, and the files it sources.
+//
+// Each script is a container of kind script, and a symbol of kind script
+// too, so that what it does is searchable: its doc is the header comment,
+// and the usage text of the header, from its Usage: line, is kept in
+// extra_json with the script's synopsis as its signature. Functions and
+// exported variables are symbols, members of the script's. Sourced files,
+// dot-sourced scripts, imported and required modules are imports of the
+// script's container; a path relative to the script's directory is
+// resolved to one relative to the input root.
+//
+// PowerShell comment-based help is parsed: the .SYNOPSIS, .DESCRIPTION,
+// .PARAMETER and other sections of a script's or function's help become the
+// sections of doc_fmt and are kept in extra_json, and the parameters of a
+// param block, with their types, defaults and help, the signature. A
+// module's Export-ModuleMember decides which of its functions are public.
+package shell
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/ChaseHampton/cargoworker/internal/ir"
+	"github.com/ChaseHampton/cargoworker/internal/pack"
+)
+
+func init() {
+	p := New()
+	for _, lang := range []string{"shell", "fish", "powershell"} {
+		pack.Register(lang, p)
+	}
+}
+
+// Pack extracts shell scripts. Each script stands alone, so the pack keeps
+// no state.
+type Pack struct{}
+
+func New() *Pack { return &Pack{} }
+
+func (p *Pack) Name() string { return "shell" }
+
+var _ pack.Pack = (*Pack)(nil)
+
+// scriptExts are the extensions of scripts, and the shells they are for.
+// The shell language also covers makefiles and Dockerfiles, which are left
+// out.
+var scriptExts = map[string]string{
+	".sh": "sh", ".bash": "bash", ".zsh": "zsh", ".ksh": "ksh",
+	".fish": "fish", ".ps1": "powershell", ".psm1": "powershell",
+}
+
+// Extract outlines the scripts of a unit.
+func (p *Pack) Extract(ctx context.Context, u pack.Unit) (*ir.Fragment, error) {
+	frag := &ir.Fragment{}
+	for _, rel := range u.Files {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		sh, ok := scriptExts[path.Ext(rel)]
+		if !ok {
+			continue
+		}
+		src, err := os.ReadFile(filepath.Join(u.Root, filepath.FromSlash(rel)))
+		if err != nil {
+			return nil, err
+		}
+		var s *script
+		switch sh {
+		case "fish":
+			s = parseFish(string(src))
+		case "powershell":
+			s = parsePowerShell(string(src))
+		default:
+			if i := shebang(string(src)); posixShells[i] {
+				sh = i
+			}
+			s = parsePosix(string(src))
+		}
+		e := &emitter{frag: frag, rel: rel, lang: u.Language, sh: sh, s: s}
+		e.script(src)
+	}
+	return frag, nil
+}
+
+// posixShells are the shells a #! line may name for the POSIX parser.
+var posixShells = map[string]bool{"sh": true, "bash": true, "zsh": true, "ksh": true, "mksh": true, "dash": true, "ash": true}
+
+// shebangLine matches a #! line, with the interpreter it runs.
+var shebangLine = regexp.MustCompile(`^#!\s*(?:\S*/)?([\w.-]+)(?:\s+(?:-\S+\s+)*([\w.-]+))?`)
+
+// shebang returns the shell a script's #! line names, if any: the
+// interpreter, or the program env runs.
+func shebang(src string) string {
+	m := shebangLine.FindStringSubmatch(src[:lineEnd(src, 0)])
+	if m == nil {
+		return ""
+	}
+	if m[1] == "env" {
+		return m[2]
+	}
+	return m[1]
+}
+
+// emitter turns a parsed script into the records of a fragment.
+type emitter struct {
+	frag *ir.Fragment
+	rel  string
+	lang string
+	sh   string
+	s    *script
+	cid  uuid.UUID
+	fid  uuid.UUID
+}
+
+// extra is the extra_json of a script or function.
+type extra struct {
+	Shell       string `json:"shell,omitempty"`
+	Usage       string `json:"usage,omitempty"`
+	Description string `json:"description,omitempty"` // a fish function's --description
+	Help        *help  `json:"help,omitempty"`
+	Value       string `json:"value,omitempty"` // an exported variable's
+}
+
+func (e *emitter) script(src []byte) {
+	s := e.s
+	doc, help := e.doc(s.header)
+	block, synopsis := usage(cleanComment(s.header))
+	ex := extra{Shell: e.sh, Usage: block, Help: help}
+	b, _ := json.Marshal(ex)
+
+	e.cid = uuid.New()
+	e.frag.Containers = append(e.frag.Containers, ir.Container{
+		Id: e.cid, Name: path.Base(e.rel), FullName: e.rel, Kind: "script", Language: e.lang,
+		DocRaw: s.header, DocFmt: doc, ExtraJson: string(b),
+	})
+	sum := sha256.Sum256(src)
+	e.fid = uuid.New()
+	e.frag.Files = append(e.frag.Files, ir.File{
+		Id: e.fid, ContainerId: e.cid, Path: e.rel, Checksum: hex.EncodeToString(sum[:]),
+		Language: e.lang, SizeBytes: int64(len(src)),
+	})
+
+	ls := newLines(string(src))
+	endLine, endCol := ls.at(max(0, len(src)-1))
+	id := e.symbol(path.Base(e.rel), e.rel, "script", "public", s.header, doc, 1, 1, endLine, endCol, ex)
+	switch {
+	case e.lang == "powershell" && s.params != nil:
+		if help != nil {
+			for i := range s.params {
+				if d := help.param(s.params[i].Name); d != "" {
+					s.params[i].Desc = d
+				}
+			}
+		}
+		e.signature(id, psSyntax(path.Base(e.rel), s.params), s.params)
+	case synopsis != "":
+		e.signature(id, synopsis, nil)
+	}
+
+	order := 0
+	for _, f := range s.funcs {
+		e.member(id, e.function(f), &order)
+	}
+	for _, x := range s.exports {
+		e.member(id, e.export(x), &order)
+	}
+	e.imports()
+}
+
+// doc returns the doc_fmt of a comment, and its comment-based help for
+// PowerShell.
+func (e *emitter) doc(raw string) (string, *help) {
+	text := cleanComment(raw)
+	if e.lang == "powershell" {
+		if h := parseHelp(text); h != nil {
+			return h.format(), h
+		}
+	}
+	return text, nil
+}
+
+func (e *emitter) symbol(name, full, kind, visibility, docRaw, docFmt string, line, col, endLine, endCol int, ex extra) uuid.UUID {
+	id := uuid.New()
+	sym := ir.Symbol{
+		Id: id, ContainerId: e.cid, Name: name, FullName: full, Kind: kind, Visibility: visibility, OriginFileId: e.fid,
+		StartLine: line, StartCol: col, EndLine: endLine, EndCol: endCol,
+		DocRaw: docRaw, DocFmt: docFmt,
+	}
+	if b, _ := json.Marshal(ex); string(b) != "{}" {
+		sym.ExtraJson = string(b)
+	}
+	e.frag.Symbols = append(e.frag.Symbols, sym)
+	return id
+}
+
+func (e *emitter) member(owner, child uuid.UUID, order *int) {
+	e.frag.Members = append(e.frag.Members, ir.Member{Id: uuid.New(), OwnerSymbolId: owner, ChildSymbolId: child, Order: *order})
+	*order++
+}
+
+func (e *emitter) signature(id uuid.UUID, text string, params []param) {
+	sig := ir.Signature{SymbolId: id, Text: text}
+	if params != nil {
+		b, _ := json.Marshal(struct {
+			Params []param `json:"params"`
+		}{params})
+		sig.Json = string(b)
+	}
+	e.frag.Signatures = append(e.frag.Signatures, sig)
+}
+
+func (e *emitter) function(f *function) uuid.UUID {
+	raw := f.doc
+	if f.bodyDoc != "" && (raw == "" || parseHelp(cleanComment(raw)) == nil) {
+		raw = f.bodyDoc
+	}
+	doc, help := e.doc(raw)
+	if doc == "" {
+		doc = f.desc
+	}
+	visibility := "public"
+	if f.private || (e.s.exportList && !exportedBy(e.s.exported, f.name)) {
+		visibility = "private"
+	}
+	id := e.symbol(f.name, e.rel+"."+f.name, "function", visibility, raw, doc,
+		f.line, f.col, f.endLine, f.endCol, extra{Description: f.desc, Help: help})
+	sig := f.sig
+	if e.lang == "powershell" {
+		for i := range f.params {
+			if d := help.param(f.params[i].Name); d != "" {
+				f.params[i].Desc = d
+			}
+		}
+		sig = psSyntax(f.name, f.params)
+		if f.params == nil {
+			f.params = []param{}
+		}
+	}
+	e.signature(id, sig, f.params)
+	return id
+}
+
+func (e *emitter) export(x *export) uuid.UUID {
+	id := e.symbol(x.name, e.rel+"."+x.name, "variable", "public", x.doc, cleanComment(x.doc),
+		x.line, x.col, x.endLine, x.endCol, extra{Value: x.value})
+	e.signature(id, collapse(x.stmt), nil)
+	return id
+}
+
+// imports records the files a script sources and the modules it imports.
+func (e *emitter) imports() {
+	type details struct {
+		File string `json:"file"`
+		Line int    `json:"line"`
+		Kind string `json:"kind"`
+		Text string `json:"text,omitempty"` // as written, when resolved
+	}
+	seen := map[string]bool{}
+	for _, src := range e.s.sources {
+		target := src.target
+		if src.kind == "source" || strings.HasPrefix(target, ".") || strings.ContainsAny(target, `/\`) {
+			target = resolve(path.Dir(e.rel), target) // a module may be named by its path
+		}
+		if target == "" || seen[src.kind+" "+target] {
+			continue
+		}
+		seen[src.kind+" "+target] = true
+		d := details{File: e.rel, Line: src.line, Kind: src.kind}
+		if target != src.target {
+			d.Text = src.target
+		}
+		b, _ := json.Marshal(d)
+		e.frag.Imports = append(e.frag.Imports, ir.Import{ContainerId: e.cid, Target: target, DetailsJson: string(b)})
+	}
+}
+
+// scriptDir matches the ways a script names its own directory at the start
+// of a path: $(dirname "$0"), ${BASH_SOURCE%/*}, $PSScriptRoot, fish's
+// (status dirname) and the variables scripts commonly keep it in.
+var scriptDir = regexp.MustCompile(`^(?:` +
+	`\$\(\s*dirname\s+[^)]*\)|` +
+	`\$\(\s*cd\s+"?\$\(\s*dirname\s+[^)]*\)"?\s*(?:&&|;)\s*pwd(?:\s+-P)?\s*\)|` +
+	`\$\{(?:BASH_SOURCE(?:\[0\])?|0)%/\*\}|` +
+	`\(\s*status\s+(?:dirname|--current-filename|-f)\s*\)|` +
+	`\(\s*dirname\s+\(\s*status\s+(?:--current-filename|-f)\s*\)\s*\)|` +
+	`\$\{?(?i:PSScriptRoot|script_?dir|scriptdir|dir|here|basedir|base_dir|root_?dir)\}?` +
+	`)/`)
+
+// resolve returns the path, relative to the input root, of a file sourced
+// by a script in dir: a relative path or one from the script's directory.
+// Other paths, those with variables or absolute, are returned as written.
+func resolve(dir, target string) string {
+	t := strings.ReplaceAll(target, `\`, "/")
+	if m := scriptDir.FindString(t); m != "" {
+		t = t[len(m):]
+	} else if strings.ContainsAny(t, "$`(~") || path.IsAbs(t) {
+		return target
+	}
+	return path.Clean(path.Join(dir, t))
+}
@@ -0,0 +1,386 @@
+package shell
+
+import (
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/ChaseHampton/cargoworker/internal/ir"
+	"github.com/ChaseHampton/cargoworker/internal/pack/packtest"
+)
+
+var tree = map[string]string{
+	"ops/deploy.sh": `#!/usr/bin/env bash
+# shellcheck disable=SC2034
+#
+# Deploys the service to a cluster.
+#
+# Usage: deploy.sh [-n] <env>
+#   -n  dry run
+#
+# Talks to the cluster API.
+
+set -euo pipefail
+source "$(dirname "$0")/lib/common.sh"
+. /etc/profile
+
+# Where releases are kept.
+export RELEASE_DIR="$HOME/releases" DEPLOY_ENV
+declare -x REGION=eu-west-1 # the default region
+export -n OLD
+readonly -x VERSION
+
+# Builds the image.
+build() {
+  cat <<EOF
+function not_a_function() {
+EOF
+  docker build -t "app:$VERSION" .
+}
+
+function push {
+  export PUSHED=1
+  ( cd build && push_image )
+}
+
+cleanup() ( rm -rf "$tmp" )
+
+build && push
+`,
+	"ops/lib/common.sh": `log() { echo "$@" >&2; }
+`,
+	"ops/Makefile": "all:\n\t./deploy.sh prod\n",
+	"fish/functions.fish": `# Fish helpers for the team.
+#
+# Usage: source functions.fish
+
+set -gx EDITOR vim
+set -x PATH $HOME/bin $PATH
+set -q FOO; or set -U FOO bar
+source ./aliases.fish
+
+function greet --description 'Say hello' --argument-names name greeting
+    if test -z "$name"
+        set name world
+    else if test "$name" = me
+        echo (whoami)
+    end
+    echo "hello $name"
+end
+
+# Lists the pods.
+function pods
+    kubectl get pods
+end
+`,
+	"ps/Deploy.ps1": `#Requires -Version 7
+#Requires -Modules Az.Accounts, @{ModuleName='Az.Aks'; ModuleVersion='2.0'}
+
+<#
+.SYNOPSIS
+    Deploys the service.
+
+.DESCRIPTION
+    Builds and pushes the image, then rolls out.
+
+.PARAMETER Environment
+    The environment to deploy to.
+
+.EXAMPLE
+    ./Deploy.ps1 -Environment prod
+#>
+[CmdletBinding()]
+param(
+    [Parameter(Mandatory)]
+    [ValidateSet('dev', 'prod')]
+    [string]$Environment,
+
+    # Skips the image build.
+    [switch]$NoBuild,
+
+    [int]$Replicas = 3
+)
+
+. $PSScriptRoot\lib\Helpers.ps1
+Import-Module -Name Az.Aks -Force
+using module ./Tools.psm1
+
+$env:DEPLOY_ENV = $Environment
+[Environment]::SetEnvironmentVariable('KUBECONFIG', "$HOME/.kube/$Environment", 'Process')
+
+function Invoke-Rollout {
+    <#
+    .SYNOPSIS
+    Rolls out a release.
+    .PARAMETER Name
+    The release.
+    #>
+    param([Parameter(Mandatory = $true)][string]$Name, [int]$Timeout = 60)
+    $body = @"
+function Not-AFunction { }
+"@
+    kubectl rollout status "deploy/$Name" --timeout "${Timeout}s"
+}
+
+# Writes a step.
+function Write-Step($Message) { Write-Host "==> $Message" }
+
+Invoke-Rollout -Name app
+`,
+	"ps/Tools.psm1": `function Get-Tool { 'tool' }
+function Remove-Tool { }
+function private:Helper { }
+Export-ModuleMember -Function Get-*
+`,
+}
+
+// shellLanguage gives the language ids of the tree's files, as the language
+// table does.
+func shellLanguage(rel string) string {
+	switch path.Ext(rel) {
+	case ".fish":
+		return "fish"
+	case ".ps1", ".psm1":
+		return "powershell"
+	}
+	return "shell"
+}
+
+func TestExtract(t *testing.T) {
+	f := packtest.ExtractTree(t, tree, shellLanguage)
+
+	var containers []string
+	for _, c := range f.Containers {
+		containers = append(containers, c.Kind+" "+c.Name+" "+c.FullName)
+	}
+	want := "script functions.fish fish/functions.fish, script deploy.sh ops/deploy.sh, script common.sh ops/lib/common.sh, " +
+		"script Deploy.ps1 ps/Deploy.ps1, script Tools.psm1 ps/Tools.psm1"
+	if got := strings.Join(containers, ", "); got != want {
+		t.Errorf("containers = %s", got) // the Makefile is left out
+	}
+	if c := f.Containers[1]; c.ExtraJson != `{"shell":"bash","usage":"Usage: deploy.sh [-n] \u003cenv\u003e\n  -n  dry run"}` ||
+		c.DocFmt != "Deploys the service to a cluster.\n\nUsage: deploy.sh [-n] <env>\n  -n  dry run\n\nTalks to the cluster API." {
+		t.Errorf("deploy.sh doc = %q, extra = %s", c.DocFmt, c.ExtraJson)
+	}
+
+	syms := map[string]ir.Symbol{}
+	names := map[uuid.UUID]string{}
+	for _, s := range f.Symbols {
+		if _, dup := syms[s.FullName]; dup {
+			t.Errorf("duplicate symbol %s", s.FullName)
+		}
+		syms[s.FullName] = s
+		names[s.Id] = s.FullName
+	}
+	for name, want := range map[string]string{
+		"ops/deploy.sh":                  "script public",
+		"ops/deploy.sh.build":            "function public",
+		"ops/deploy.sh.push":             "function public",
+		"ops/deploy.sh.cleanup":          "function public",
+		"ops/deploy.sh.not_a_function":   "",
+		"ops/deploy.sh.RELEASE_DIR":      "variable public",
+		"ops/deploy.sh.DEPLOY_ENV":       "variable public",
+		"ops/deploy.sh.REGION":           "variable public",
+		"ops/deploy.sh.VERSION":          "variable public",
+		"ops/deploy.sh.PUSHED":           "variable public",
+		"ops/deploy.sh.OLD":              "",
+		"ops/lib/common.sh.log":          "function public",
+		"fish/functions.fish.greet":      "function public",
+		"fish/functions.fish.pods":       "function public",
+		"fish/functions.fish.EDITOR":     "variable public",
+		"fish/functions.fish.FOO":        "",
+		"ps/Deploy.ps1.Invoke-Rollout":   "function public",
+		"ps/Deploy.ps1.Write-Step":       "function public",
+		"ps/Deploy.ps1.Not-AFunction":    "",
+		"ps/Deploy.ps1.DEPLOY_ENV":       "variable public",
+		"ps/Deploy.ps1.KUBECONFIG":       "variable public",
+		"ps/Tools.psm1.Get-Tool":         "function public",
+		"ps/Tools.psm1.Remove-Tool":      "function private",
+		"ps/Tools.psm1.Helper":           "function private",
+		"ps/Tools.psm1.private:Helper":   "",
+		"fish/functions.fish.PATH":       "variable public",
+		"ops/deploy.sh.tmp":              "",
+		"ps/Deploy.ps1.Environment":      "",
+		"fish/functions.fish.name":       "",
+		"ops/lib/common.sh":              "script public",
+		"fish/functions.fish":            "script public",
+		"ps/Deploy.ps1":                  "script public",
+		"ps/Tools.psm1":                  "script public",
+		"ops/deploy.sh.function":         "",
+		"fish/functions.fish.not_a_func": "",
+	} {
+		got := ""
+		if s, ok := syms[name]; ok {
+			got = s.Kind + " " + s.Visibility
+		}
+		if got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	if n := len(f.Symbols); n != 25 {
+		t.Errorf("%d symbols", n)
+	}
+
+	for name, want := range map[string]string{
+		"ops/deploy.sh.build":          "Builds the image.",
+		"ops/deploy.sh.RELEASE_DIR":    "Where releases are kept.",
+		"ops/deploy.sh.REGION":         "the default region",
+		"fish/functions.fish.greet":    "Say hello",
+		"fish/functions.fish.pods":     "Lists the pods.",
+		"ps/Deploy.ps1.Invoke-Rollout": "Rolls out a release.\n\nParameters:\n    Name: The release.",
+		"ps/Deploy.ps1.Write-Step":     "Writes a step.",
+		"ps/Deploy.ps1": "Deploys the service.\n\nBuilds and pushes the image, then rolls out.\n\n" +
+			"Parameters:\n    Environment: The environment to deploy to.\n\nExamples:\n    ./Deploy.ps1 -Environment prod",
+	} {
+		if got := syms[name].DocFmt; got != want {
+			t.Errorf("%s doc = %q, want %q", name, got, want)
+		}
+	}
+	for name, want := range map[string]string{
+		"ops/deploy.sh.REGION":         `{"value":"eu-west-1"}`,
+		"fish/functions.fish.PATH":     `{"value":"$HOME/bin $PATH"}`,
+		"fish/functions.fish.greet":    `{"description":"Say hello"}`,
+		"ps/Deploy.ps1.KUBECONFIG":     `{"value":"$HOME/.kube/$Environment"}`,
+		"ps/Deploy.ps1.Invoke-Rollout": `{"help":{"synopsis":"Rolls out a release.","params":[{"name":"Name","desc":"The release."}]}}`,
+	} {
+		if got := syms[name].ExtraJson; got != want {
+			t.Errorf("%s extra = %s, want %s", name, got, want)
+		}
+	}
+	for name, want := range map[string][4]int{
+		"ops/deploy.sh.build":          {22, 1, 27, 1},
+		"ops/deploy.sh.cleanup":        {34, 1, 34, 27},
+		"fish/functions.fish.greet":    {10, 1, 17, 3},
+		"ps/Deploy.ps1.Invoke-Rollout": {36, 1, 48, 1},
+		"ps/Deploy.ps1.KUBECONFIG":     {34, 1, 34, 90},
+	} {
+		s := syms[name]
+		if got := [4]int{s.StartLine, s.StartCol, s.EndLine, s.EndCol}; got != want {
+			t.Errorf("%s at %v, want %v", name, got, want)
+		}
+	}
+
+	sigs := map[string]string{}
+	for _, s := range f.Signatures {
+		sigs[names[s.SymbolId]] = strings.TrimSpace(s.Text + " " + s.Json)
+	}
+	for name, want := range map[string]string{
+		"ops/deploy.sh":             "deploy.sh [-n] <env>",
+		"ops/deploy.sh.build":       "build()",
+		"ops/deploy.sh.push":        "function push",
+		"ops/deploy.sh.DEPLOY_ENV":  `export RELEASE_DIR="$HOME/releases" DEPLOY_ENV`,
+		"fish/functions.fish.greet": `function greet --description 'Say hello' --argument-names name greeting {"params":[{"name":"name"},{"name":"greeting"}]}`,
+		"fish/functions.fish.PATH":  "set -x PATH $HOME/bin $PATH",
+		"ps/Deploy.ps1": `Deploy.ps1 -Environment <string> [-NoBuild] [-Replicas <int>] {"params":[` +
+			`{"name":"Environment","type":"string","mandatory":true,"desc":"The environment to deploy to."},` +
+			`{"name":"NoBuild","type":"switch","desc":"Skips the image build."},{"name":"Replicas","type":"int","default":"3"}]}`,
+		"ps/Deploy.ps1.Invoke-Rollout": `Invoke-Rollout -Name <string> [-Timeout <int>] {"params":[` +
+			`{"name":"Name","type":"string","mandatory":true,"desc":"The release."},{"name":"Timeout","type":"int","default":"60"}]}`,
+		"ps/Deploy.ps1.Write-Step": `Write-Step [-Message] {"params":[{"name":"Message"}]}`,
+		"ps/Deploy.ps1.DEPLOY_ENV": "$env:DEPLOY_ENV = $Environment",
+		"ps/Tools.psm1.Get-Tool":   `Get-Tool {"params":[]}`,
+	} {
+		if got := sigs[name]; got != want {
+			t.Errorf("%s sig = %q, want %q", name, got, want)
+		}
+	}
+
+	var members []string
+	for _, m := range f.Members {
+		if names[m.OwnerSymbolId] == "ops/deploy.sh" {
+			members = append(members, syms[names[m.ChildSymbolId]].Name)
+		}
+	}
+	if got := strings.Join(members, " "); got != "build push cleanup RELEASE_DIR DEPLOY_ENV REGION VERSION PUSHED" {
+		t.Errorf("deploy.sh members = %s", got)
+	}
+
+	var imports []string
+	for _, im := range f.Imports {
+		imports = append(imports, im.Target+" "+im.DetailsJson)
+	}
+	wantImports := []string{
+		`fish/aliases.fish {"file":"fish/functions.fish","line":8,"kind":"source","text":"./aliases.fish"}`,
+		`ops/lib/common.sh {"file":"ops/deploy.sh","line":12,"kind":"source","text":"$(dirname \"$0\")/lib/common.sh"}`,
+		`/etc/profile {"file":"ops/deploy.sh","line":13,"kind":"source"}`,
+		`ps/lib/Helpers.ps1 {"file":"ps/Deploy.ps1","line":29,"kind":"source","text":"$PSScriptRoot\\lib\\Helpers.ps1"}`,
+		`Az.Aks {"file":"ps/Deploy.ps1","line":30,"kind":"module"}`,
+		`ps/Tools.psm1 {"file":"ps/Deploy.ps1","line":31,"kind":"module","text":"./Tools.psm1"}`,
+		`Az.Accounts {"file":"ps/Deploy.ps1","line":2,"kind":"requires"}`,
+		`Az.Aks {"file":"ps/Deploy.ps1","line":2,"kind":"requires"}`,
+	}
+	if strings.Join(imports, "\n") != strings.Join(wantImports, "\n") {
+		t.Errorf("imports =\n%s\nwant\n%s", strings.Join(imports, "\n"), strings.Join(wantImports, "\n"))
+	}
+}
+
+func TestHelp(t *testing.T) {
+	h := parseHelp(cleanComment(`<#
+.Synopsis
+  Gets a thing.
+.PARAMETER Name
+  The name,
+  in full.
+.PARAMETER Force
+.LINK
+  https://example.com/things
+#>`))
+	if h == nil {
+		t.Fatal("no help")
+	}
+	if h.Synopsis != "Gets a thing." || h.param("name") != "The name,\nin full." || len(h.Params) != 2 || h.Links[0] != "https://example.com/things" {
+		t.Errorf("help = %+v", h)
+	}
+	if got := h.format(); got != "Gets a thing.\n\nParameters:\n    Name: The name,\n    in full.\n    Force\n\nLinks:\n    https://example.com/things" {
+		t.Errorf("format = %q", got)
+	}
+	if parseHelp("Just a comment.\n.PARAMETER x") != nil {
+		t.Error("help without a synopsis or description")
+	}
+
+	block, synopsis := usage("Backs up the database.\n\nUsage:\n    backup.sh <db> [dest]\n      dest defaults to .\n\nExit codes follow.")
+	if block != "Usage:\n    backup.sh <db> [dest]\n      dest defaults to ." || synopsis != "backup.sh <db> [dest]" {
+		t.Errorf("usage = %q, %q", block, synopsis)
+	}
+	if d := cleanComment("# shellcheck shell=bash\n# vim: ft=sh\n#Requires -Version 7\n#  Indented.\n#  Twice."); d != "Indented.\nTwice." {
+		t.Errorf("clean = %q", d)
+	}
+}
+
+func TestLex(t *testing.T) {
+	src := "#!/bin/sh\n# Doc.\necho \"a $(printf ')' \"b\")\" 'c d'>out <<-EOF; x=${y:-}\n\tbody )\n\tEOF\nf() { :; } # trailing\n"
+	toks, lead := lex(src, false)
+	var got []string
+	for _, tok := range toks {
+		got = append(got, tok.text)
+	}
+	want := []string{"\n", "\n", "echo", `"a $(printf ')' "b")"`, "'c d'", ">", "out", "<<-", "EOF", ";", "x=${y:-}", "\n", "f", "(", ")", "{", ":", ";", "}", "\n"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("tokens = %q, want %q", got, want)
+	}
+	if len(lead) != 1 || toks[2].doc != "# Doc." || toks[18].trail != "# trailing" {
+		t.Errorf("lead = %q, doc = %q, trail = %q", lead, toks[2].doc, toks[18].trail)
+	}
+	if u := unquote(`"a \"$(b "c")\""'d'\e`); u != `a "$(b "c")"de` {
+		t.Errorf("unquote = %q", u)
+	}
+
+	ps := "<# Help. #>\n$x = @'\nfunction Not { }\n'@ # here\nWrite-Host \"`\"}\" $(Get-Date) ``\n"
+	toks, lead, _, _ = lexPS(ps)
+	got = nil
+	for _, tok := range toks {
+		got = append(got, tok.text)
+	}
+	want = []string{"\n", "$x", "=", "@'\nfunction Not { }\n'@", "\n", "Write-Host", "\"`\"}\"", "$(Get-Date)", "``", "\n"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("PowerShell tokens = %q, want %q", got, want)
+	}
+	if toks[1].doc != "<# Help. #>" || toks[3].trail != "# here" || psUnquote(toks[6].text) != `"}` {
+		t.Errorf("doc = %q, trail = %q, unquote = %q", toks[1].doc, toks[3].trail, psUnquote(toks[6].text))
+	}
+	if sh := shebang("#!/usr/bin/env -S bash -e\n"); sh != "bash" {
+		t.Errorf("shebang = %q", sh)
+	}
+}
@@ -0,0 +1,256 @@
+package sql
+
+import (
+	"regexp"
+	"strings"
+)
+
+type tokKind int
+
+const (
+	tWord   tokKind = iota // keywords and bare identifiers
+	tQuoted                // "identifiers", `identifiers` and [identifiers], quotes included
+	tString                // 'strings', E'strings' and $tag$strings$tag$
+	tNumber
+	tPunct
+)
+
+type token struct {
+	kind    tokKind
+	text    string
+	pos     int // byte offset
+	end     int // byte offset after the token
+	line    int // 1-based
+	col     int // 0-based byte column
+	endLine int
+	doc     string // the comments directly above the token, markers included
+	trail   string // a comment after the token on its line
+}
+
+// endCol returns the 1-based column after the token.
+func (t token) endCol() int {
+	if i := strings.LastIndexByte(t.text, '\n'); i >= 0 {
+		return len(t.text) - i
+	}
+	return t.col + len(t.text) + 1
+}
+
+// is reports whether the token is a keyword or punctuation, keywords
+// matching in any case.
+func (t token) is(text string) bool {
+	return (t.kind == tWord || t.kind == tPunct) && strings.EqualFold(t.text, text)
+}
+
+// isName reports whether the token can name something.
+func (t token) isName() bool { return t.kind == tWord || t.kind == tQuoted }
+
+// name returns the identifier a token spells, unquoted.
+func (t token) name() string {
+	if t.kind != tQuoted {
+		return t.text
+	}
+	s := t.text[1 : len(t.text)-1]
+	switch t.text[0] {
+	case '"':
+		return strings.ReplaceAll(s, `""`, `"`)
+	case '`':
+		return strings.ReplaceAll(s, "``", "`")
+	}
+	return s
+}
+
+// lex splits a SQL source into tokens. Comments are not tokens: a run of
+// them directly above a token, with no blank line between, is its doc,
+// and one that follows a token on its line is that token's trailing
+// comment.
+func lex(src string) []token {
+	var out []token
+	var pending []string // the comment run being read
+	pendingEnd := 0      // the line the run ends on
+	line, lineStart := 1, 0
+	count := func(from, to int) {
+		for j := from; j < to; j++ {
+			if src[j] == '\n' {
+				line++
+				lineStart = j + 1
+			}
+		}
+	}
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '\n':
+			line++
+			lineStart = i + 1
+			i++
+		case c == ' ' || c == '\t' || c == '\r' || c == '\f' || c == '\v':
+			i++
+		case c == '-' && strings.HasPrefix(src[i:], "--") || c == '/' && strings.HasPrefix(src[i:], "/*"):
+			start, startLine := i, line
+			if c == '/' {
+				end := strings.Index(src[i+2:], "*/")
+				if end < 0 {
+					i = len(src)
+				} else {
+					i += end + 4
+				}
+				count(start, i)
+			} else {
+				for i < len(src) && src[i] != '\n' {
+					i++
+				}
+			}
+			text := src[start:i]
+			if directive.MatchString(text) {
+				if len(pending) > 0 {
+					pendingEnd = line
+				}
+				continue
+			}
+			if n := len(out); n > 0 && out[n-1].endLine == startLine && out[n-1].trail == "" {
+				out[n-1].trail = text
+				continue
+			}
+			if len(pending) > 0 && pendingEnd < startLine-1 {
+				pending = nil // a blank line detaches the comments above
+			}
+			pending = append(pending, text)
+			pendingEnd = line
+		default:
+			t := token{pos: i, line: line, col: i - lineStart}
+			switch {
+			case c == '\'' || (c == 'E' || c == 'e' || c == 'X' || c == 'x' || c == 'N' || c == 'n') && i+1 < len(src) && src[i+1] == '\'':
+				j := i + 1
+				if c != '\'' {
+					j++
+				}
+				for j < len(src) {
+					if src[j] == '\\' && c != '\'' {
+						j += 2
+						continue
+					}
+					if src[j] == '\'' {
+						if j+1 < len(src) && src[j+1] == '\'' {
+							j += 2
+							continue
+						}
+						break
+					}
+					j++
+				}
+				t.kind, i = tString, min(j+1, len(src))
+			case c == '$' && dollarTag(src[i:]) != "":
+				tag := dollarTag(src[i:])
+				end := strings.Index(src[i+len(tag):], tag)
+				if end < 0 {
+					i = len(src)
+				} else {
+					i += len(tag) + end + len(tag)
+				}
+				t.kind = tString
+			case c == '"' || c == '`' || c == '[' && !subscript(out, i):
+				closer := c
+				if c == '[' {
+					closer = ']'
+				}
+				j := i + 1
+				for j < len(src) {
+					if src[j] == closer {
+						if closer != ']' && j+1 < len(src) && src[j+1] == closer {
+							j += 2
+							continue
+						}
+						break
+					}
+					j++
+				}
+				t.kind, i = tQuoted, min(j+1, len(src))
+			case isIdentStart(c):
+				j := i
+				for j < len(src) && isIdent(src[j]) {
+					j++
+				}
+				t.kind, i = tWord, j
+			case c >= '0' && c <= '9' || c == '.' && i+1 < len(src) && src[i+1] >= '0' && src[i+1] <= '9':
+				j := i + 1
+				for j < len(src) && (isIdent(src[j]) || src[j] == '.' || (src[j] == '-' || src[j] == '+') && (src[j-1] == 'e' || src[j-1] == 'E')) {
+					j++
+				}
+				t.kind, i = tNumber, j
+			case c == ':' && i+1 < len(src) && src[i+1] == ':', c == '|' && i+1 < len(src) && src[i+1] == '|':
+				t.kind, i = tPunct, i+2
+			default:
+				t.kind, i = tPunct, i+1
+			}
+			count(t.pos, i)
+			t.text, t.end, t.endLine = src[t.pos:i], i, line
+			if len(pending) > 0 && pendingEnd >= t.line-1 {
+				t.doc = strings.Join(pending, "\n")
+			}
+			pending = nil
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// directive matches the comments migration tools read, which are not
+// docs.
+var directive = regexp.MustCompile(`^--\s*(\+goose\s|migrate:)`)
+
+// subscript reports whether a [ at src[i] follows the last token directly,
+// an array type or subscript rather than a quoted identifier.
+func subscript(out []token, i int) bool {
+	if len(out) == 0 {
+		return false
+	}
+	last := out[len(out)-1]
+	return last.end == i && (last.kind != tPunct || last.text == ")" || last.text == "]")
+}
+
+// dollarTag returns the $tag$ that opens a dollar-quoted string at the
+// start of s, or "".
+func dollarTag(s string) string {
+	for j := 1; j < len(s); j++ {
+		switch {
+		case s[j] == '$':
+			return s[:j+1]
+		case !isIdent(s[j]) || j == 1 && s[j] >= '0' && s[j] <= '9':
+			return ""
+		}
+	}
+	return ""
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+func isIdent(c byte) bool { return isIdentStart(c) || c >= '0' && c <= '9' || c == '$' }
+
+// cleanComment strips the markers of comments: the -- of each line, or
+// the /* and */ of a block and the leading asterisks of its lines.
+func cleanComment(raw string) string {
+	var lines []string
+	for _, l := range strings.Split(raw, "\n") {
+		t := strings.TrimSpace(l)
+		switch {
+		case strings.HasPrefix(t, "--"):
+			t = strings.TrimPrefix(strings.TrimLeft(t, "-"), " ")
+		case strings.HasPrefix(t, "/*"):
+			t = strings.TrimSpace(strings.TrimSuffix(strings.TrimLeft(t[2:], "*"), "*/"))
+		case strings.HasSuffix(t, "*/"):
+			t = strings.TrimSpace(strings.TrimPrefix(strings.TrimSuffix(t, "*/"), "*"))
+		case strings.HasPrefix(t, "*"):
+			t = strings.TrimPrefix(strings.TrimPrefix(t, "*"), " ")
+		}
+		lines = append(lines, strings.TrimRight(t, " \t"))
+	}
+	for len(lines) > 0 && lines[0] == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
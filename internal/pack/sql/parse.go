@@ -0,0 +1,385 @@
+package sql
+
+import (
+	"regexp"
+	"strings"
+)
+
+// stmt is a statement of a source: its tokens, without the ;.
+type stmt struct {
+	toks  []token
+	trail string // a comment after the ; on its line
+}
+
+// downMarker starts the down half of a goose or dbmate migration, which
+// undoes the up half above it.
+var downMarker = regexp.MustCompile(`(?im)^\s*--\s*(\+goose\s+down|migrate:down)\b`)
+
+// split splits a source into statements. The ; inside the BEGIN ... END
+// body of a SQLite trigger do not end it; a PostgreSQL function body is a
+// string, so needs no care.
+func split(src string) []stmt {
+	if m := downMarker.FindStringIndex(src); m != nil {
+		src = src[:m[0]]
+	}
+	var out []stmt
+	toks := lex(src)
+	start, depth := 0, 0
+	trigger := false
+	for i, t := range toks {
+		switch {
+		case i == start:
+			trigger = isTrigger(toks[start:])
+		case trigger && (t.is("BEGIN") || t.is("CASE")):
+			depth++
+		case trigger && t.is("END") && depth > 0:
+			depth--
+		}
+		if t.is(";") && depth == 0 {
+			if i > start {
+				out = append(out, stmt{toks: toks[start:i], trail: t.trail})
+			}
+			start = i + 1
+		}
+	}
+	if start < len(toks) {
+		out = append(out, stmt{toks: toks[start:]})
+	}
+	return out
+}
+
+// isTrigger reports whether a statement creates a trigger.
+func isTrigger(toks []token) bool {
+	if len(toks) == 0 || !toks[0].is("CREATE") {
+		return false
+	}
+	for _, t := range toks[1:min(len(toks), 6)] {
+		if t.is("TRIGGER") {
+			return true
+		}
+	}
+	return false
+}
+
+type parser struct {
+	toks []token
+	i    int
+}
+
+var eofToken = token{kind: tPunct}
+
+func (p *parser) peek(n int) token {
+	if p.i+n < len(p.toks) {
+		return p.toks[p.i+n]
+	}
+	return eofToken
+}
+
+func (p *parser) eof() bool { return p.i >= len(p.toks) }
+
+// accept consumes a sequence of keywords or punctuation, or nothing if it
+// is not next.
+func (p *parser) accept(words ...string) bool {
+	for n, w := range words {
+		if !p.peek(n).is(w) {
+			return false
+		}
+	}
+	p.i += len(words)
+	return true
+}
+
+// text returns the source of tokens [from, to), with runs of white space,
+// and comments, collapsed to a space.
+func (p *parser) text(from, to int) string {
+	var b strings.Builder
+	for i := from; i < to && i < len(p.toks); i++ {
+		if i > from && p.toks[i].pos > p.toks[i-1].end {
+			b.WriteByte(' ')
+		}
+		b.WriteString(p.toks[i].text)
+	}
+	return b.String()
+}
+
+// qname reads a name, qualified by a schema or not, returning it as
+// written less the quotes, with the default schemas left out.
+func (p *parser) qname() string {
+	if !p.peek(0).isName() {
+		return ""
+	}
+	parts := []string{p.peek(0).name()}
+	p.i++
+	for p.peek(0).is(".") && p.peek(1).isName() {
+		parts = append(parts, p.peek(1).name())
+		p.i += 2
+	}
+	if len(parts) > 1 && (strings.EqualFold(parts[0], "public") || strings.EqualFold(parts[0], "main")) {
+		parts = parts[1:]
+	}
+	return strings.Join(parts, ".")
+}
+
+// group skips a parenthesized group at p.i, returning the tokens inside
+// it.
+func (p *parser) group() (from, to int) {
+	from = p.i + 1
+	depth := 0
+	for ; !p.eof(); p.i++ {
+		switch {
+		case p.peek(0).is("("):
+			depth++
+		case p.peek(0).is(")"):
+			if depth--; depth == 0 {
+				p.i++
+				return from, p.i - 1
+			}
+		}
+	}
+	return from, p.i
+}
+
+// split splits tokens [from, to) at commas outside parentheses.
+func (p *parser) split(from, to int) [][2]int {
+	var out [][2]int
+	depth, start := 0, from
+	for i := from; i < to; i++ {
+		switch t := p.toks[i]; {
+		case t.is("("):
+			depth++
+		case t.is(")"):
+			depth--
+		case depth == 0 && t.is(","):
+			if i > start {
+				out = append(out, [2]int{start, i})
+			}
+			start = i + 1
+		}
+	}
+	if to > start {
+		out = append(out, [2]int{start, to})
+	}
+	return out
+}
+
+// names reads a parenthesized list of names: of columns, or of values.
+func (p *parser) names() []string {
+	if !p.peek(0).is("(") {
+		return nil
+	}
+	from, to := p.group()
+	var out []string
+	for _, part := range p.split(from, to) {
+		t := p.toks[part[0]]
+		if t.kind == tString {
+			out = append(out, unquote(t.text))
+		} else {
+			out = append(out, t.name())
+		}
+	}
+	return out
+}
+
+// trail returns the first trailing comment among tokens [from, to].
+func (p *parser) trail(from, to int) string {
+	for i := from; i <= to && i < len(p.toks); i++ {
+		if p.toks[i].trail != "" {
+			return p.toks[i].trail
+		}
+	}
+	return ""
+}
+
+// unquote returns the text of a string literal.
+func unquote(s string) string {
+	if strings.HasPrefix(s, "$") {
+		tag := dollarTag(s)
+		return strings.TrimSuffix(strings.TrimPrefix(s, tag), tag)
+	}
+	if i := strings.IndexByte(s, '\''); i >= 0 && len(s) >= i+2 {
+		return strings.ReplaceAll(s[i+1:len(s)-1], "''", "'")
+	}
+	return s
+}
+
+// columnKeywords start the constraints of a column definition, which end
+// its type.
+var columnKeywords = map[string]bool{
+	"CONSTRAINT": true, "PRIMARY": true, "NOT": true, "NULL": true, "UNIQUE": true, "CHECK": true,
+	"DEFAULT": true, "REFERENCES": true, "COLLATE": true, "GENERATED": true, "AS": true,
+}
+
+// column parses a column definition of a file in tokens [from, to).
+func (p *parser) column(from, to int, file string) (*column, *foreignKey) {
+	p.i = from
+	c := &column{name: p.peek(0).name(), file: file}
+	c.line, c.col = p.peek(0).line, p.peek(0).col+1
+	p.i++
+	start := p.i
+	for p.i < to && !columnKeywords[strings.ToUpper(p.peek(0).text)] {
+		if p.peek(0).is("(") {
+			p.group()
+			continue
+		}
+		p.i++
+	}
+	c.typ = p.text(start, p.i)
+	var fk *foreignKey
+	constraint := ""
+	for p.i < to {
+		switch {
+		case p.accept("CONSTRAINT"):
+			constraint = p.peek(0).name()
+			p.i++
+			continue
+		case p.accept("PRIMARY", "KEY"):
+			c.primaryKey = true
+			p.accept("ASC")
+			p.accept("DESC")
+			p.conflict()
+			if p.accept("AUTOINCREMENT") {
+				c.autoincrement = true
+			}
+		case p.accept("NOT", "NULL"):
+			c.notNull = true
+			p.conflict()
+		case p.accept("NULL"):
+			c.notNull = false
+		case p.accept("UNIQUE"):
+			c.unique = true
+			p.conflict()
+		case p.accept("CHECK"):
+			from, to := p.group()
+			c.checks = append(c.checks, p.text(from, to))
+		case p.accept("DEFAULT"):
+			c.dflt = p.expr(to)
+		case p.accept("COLLATE"):
+			c.collate = p.peek(0).name()
+			p.i++
+		case p.accept("REFERENCES"):
+			fk = p.references(to)
+			fk.name, fk.columns, fk.file = constraint, []string{c.name}, file
+		case p.accept("GENERATED", "ALWAYS", "AS", "IDENTITY"), p.accept("GENERATED", "BY", "DEFAULT", "AS", "IDENTITY"):
+			c.identity = true
+			if p.peek(0).is("(") {
+				p.group()
+			}
+		case p.accept("GENERATED", "ALWAYS", "AS"), p.accept("AS"):
+			from, to := p.group()
+			c.generated = p.text(from, to)
+			p.accept("STORED")
+			p.accept("VIRTUAL")
+		default:
+			p.i++
+		}
+		constraint = ""
+	}
+	c.endLine, c.endCol = p.toks[to-1].endLine, p.toks[to-1].endCol()
+	if c.doc = p.toks[from].doc; c.doc == "" {
+		c.doc = p.trail(from, to)
+	}
+	return c, fk
+}
+
+// conflict skips an ON CONFLICT clause.
+func (p *parser) conflict() {
+	if p.accept("ON", "CONFLICT") {
+		p.i++
+	}
+}
+
+// expr reads an expression up to the next column constraint, before to.
+func (p *parser) expr(to int) string {
+	start := p.i
+	for p.i < to && (p.i == start || !columnKeywords[strings.ToUpper(p.peek(0).text)] || p.peek(0).kind != tWord) {
+		if p.peek(0).is("(") {
+			p.group()
+			continue
+		}
+		p.i++
+	}
+	s := p.text(start, p.i)
+	if strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")") {
+		s = strings.TrimSpace(s[1 : len(s)-1])
+	}
+	return s
+}
+
+// references parses the rest of REFERENCES table (columns) and its
+// actions, before to.
+func (p *parser) references(to int) *foreignKey {
+	fk := &foreignKey{line: p.peek(0).line}
+	fk.table = p.qname()
+	fk.refColumns = p.names()
+	for p.i < to {
+		switch {
+		case p.accept("ON", "DELETE"):
+			fk.onDelete = p.action()
+		case p.accept("ON", "UPDATE"):
+			fk.onUpdate = p.action()
+		case p.accept("MATCH"), p.accept("NOT", "DEFERRABLE"), p.accept("DEFERRABLE"), p.accept("INITIALLY"):
+			if p.peek(0).is("DEFERRED") || p.peek(0).is("IMMEDIATE") || p.peek(0).is("FULL") ||
+				p.peek(0).is("PARTIAL") || p.peek(0).is("SIMPLE") {
+				p.i++
+			}
+		default:
+			return fk
+		}
+	}
+	return fk
+}
+
+// action reads a referential action: CASCADE, SET NULL, and the others.
+func (p *parser) action() string {
+	for _, a := range [][]string{{"SET", "NULL"}, {"SET", "DEFAULT"}, {"NO", "ACTION"}, {"CASCADE"}, {"RESTRICT"}} {
+		if p.accept(a...) {
+			return strings.Join(a, " ")
+		}
+	}
+	return ""
+}
+
+// isTableConstraint reports whether a table element at p.i is a
+// constraint rather than a column.
+func (p *parser) isTableConstraint() bool {
+	switch t := p.peek(0); {
+	case t.is("CONSTRAINT"), t.is("FOREIGN"), t.is("EXCLUDE"):
+		return true
+	case t.is("PRIMARY"), t.is("UNIQUE"), t.is("CHECK"):
+		return p.peek(1).is("KEY") || p.peek(1).is("(") || p.peek(1).is("NULLS")
+	}
+	return false
+}
+
+// tableConstraint parses a constraint of a table, in tokens [from, to),
+// into t.
+func (p *parser) tableConstraint(t *object, from, to int, file string) {
+	p.i = from
+	name := ""
+	if p.accept("CONSTRAINT") {
+		name = p.peek(0).name()
+		p.i++
+	}
+	switch {
+	case p.accept("PRIMARY", "KEY"):
+		t.primaryKey = p.names()
+		for _, c := range t.primaryKey {
+			if col := t.column(c); col != nil {
+				col.primaryKey = true
+			}
+		}
+	case p.accept("UNIQUE"):
+		p.accept("NULLS", "NOT", "DISTINCT")
+		t.uniques = append(t.uniques, p.names())
+	case p.accept("CHECK"):
+		f, e := p.group()
+		t.checks = append(t.checks, p.text(f, e))
+	case p.accept("FOREIGN", "KEY"):
+		cols := p.names()
+		if p.accept("REFERENCES") {
+			fk := p.references(to)
+			fk.name, fk.columns, fk.file = name, cols, file
+			t.foreignKeys = append(t.foreignKeys, fk)
+		}
+	}
+}
@@ -0,0 +1,834 @@
+package sql
+
+import (
+	"slices"
+	"strings"
+)
+
+// object is a table, view, index, trigger, function or type of the
+// schema.
+type object struct {
+	kind    string // table, view, index, trigger, function or enum
+	name    string
+	file    string
+	doc     string
+	line    int
+	col     int
+	endLine int
+	endCol  int
+
+	// Of tables and views.
+	columns     []*column
+	primaryKey  []string
+	uniques     [][]string
+	checks      []string
+	foreignKeys []*foreignKey
+	using       string   // the module of a virtual table
+	options     []string // its k=v arguments
+	rowid       bool     // false for WITHOUT ROWID
+	strict      bool
+	query       string   // of a view, or of a table created AS a query
+	sources     []string // the tables and views a view selects from
+
+	// Of indexes and triggers.
+	table     string
+	indexed   []string // the columns, or expressions, of an index
+	unique    bool
+	where     string
+	timing    string // BEFORE, AFTER or INSTEAD OF
+	event     string // INSERT, UPDATE or DELETE
+	ofColumns []string
+	forEach   string // ROW or STATEMENT
+	function  string // the function a PostgreSQL trigger executes
+
+	// Of functions and enums.
+	params  []param
+	returns string
+	values  []string
+}
+
+// column is a column of a table or view.
+type column struct {
+	name          string
+	typ           string
+	doc           string
+	file          string
+	line          int
+	col           int
+	endLine       int
+	endCol        int
+	notNull       bool
+	primaryKey    bool
+	unique        bool
+	autoincrement bool
+	identity      bool
+	dflt          string
+	generated     string
+	collate       string
+	checks        []string
+}
+
+// foreignKey is a FOREIGN KEY constraint, or a column's REFERENCES.
+type foreignKey struct {
+	name       string
+	columns    []string
+	table      string
+	refColumns []string // the primary key of table when empty
+	onDelete   string
+	onUpdate   string
+	file       string
+	line       int
+}
+
+// param is a parameter of a function.
+type param struct {
+	Name string `json:"name,omitempty"`
+	Type string `json:"type,omitempty"`
+	Mode string `json:"mode,omitempty"` // IN, OUT, INOUT or VARIADIC
+}
+
+// extension is a CREATE EXTENSION.
+type extension struct {
+	name string
+	file string
+	line int
+}
+
+// column returns the column of an object with a name, or nil.
+func (o *object) column(name string) *column {
+	for _, c := range o.columns {
+		if strings.EqualFold(c.name, name) {
+			return c
+		}
+	}
+	return nil
+}
+
+// keyColumns returns the primary key of a table, declared as a
+// constraint or on its columns.
+func (o *object) keyColumns() []string {
+	if len(o.primaryKey) > 0 {
+		return o.primaryKey
+	}
+	var out []string
+	for _, c := range o.columns {
+		if c.primaryKey {
+			out = append(out, c.name)
+		}
+	}
+	return out
+}
+
+// schema is the schema a sequence of migrations builds: each statement
+// is applied to the objects the statements before it left.
+type schema struct {
+	objects    map[string]*object // by namespace and lower-case name
+	order      []string           // the keys of objects, in order of creation
+	extensions []extension
+}
+
+func newSchema() *schema { return &schema{objects: map[string]*object{}} }
+
+// namespace returns the namespace of the names of a kind of object:
+// tables, views and indexes share one.
+func namespace(kind string) string {
+	switch kind {
+	case "table", "view", "index":
+		return "relation"
+	case "enum", "type":
+		return "type"
+	}
+	return kind
+}
+
+func key(kind, name string) string { return namespace(kind) + ":" + strings.ToLower(name) }
+
+func (s *schema) lookup(kind, name string) *object { return s.objects[key(kind, name)] }
+
+// put adds an object, replacing any of its name.
+func (s *schema) put(o *object) {
+	k := key(o.kind, o.name)
+	if _, ok := s.objects[k]; ok {
+		s.remove(k)
+	}
+	s.objects[k] = o
+	s.order = append(s.order, k)
+}
+
+// remove drops an object, and the indexes and triggers of a table.
+func (s *schema) remove(k string) {
+	o, ok := s.objects[k]
+	if !ok {
+		return
+	}
+	delete(s.objects, k)
+	for i, sk := range s.order {
+		if sk == k {
+			s.order = slices.Delete(s.order, i, i+1)
+			break
+		}
+	}
+	if o.kind != "table" && o.kind != "view" {
+		return
+	}
+	for _, dk := range append([]string(nil), s.order...) {
+		if d := s.objects[dk]; (d.kind == "index" || d.kind == "trigger") && strings.EqualFold(d.table, o.name) {
+			s.remove(dk)
+		}
+	}
+}
+
+// list returns the objects in order of creation.
+func (s *schema) list() []*object {
+	out := make([]*object, 0, len(s.order))
+	for _, k := range s.order {
+		out = append(out, s.objects[k])
+	}
+	return out
+}
+
+// exec applies a statement of a file.
+func (s *schema) exec(file string, st stmt) {
+	p := &parser{toks: st.toks}
+	switch {
+	case p.accept("CREATE"):
+		s.create(p, file)
+	case p.accept("ALTER"):
+		s.alter(p, file, st.trail)
+	case p.accept("DROP"):
+		s.drop(p)
+	case p.accept("COMMENT", "ON"):
+		s.comment(p)
+	}
+}
+
+// newObject returns an object a CREATE statement declares, spanning the
+// statement.
+func (p *parser) newObject(kind, name, file string) *object {
+	first, last := p.toks[0], p.toks[len(p.toks)-1]
+	return &object{
+		kind: kind, name: name, file: file, doc: first.doc, rowid: true,
+		line: first.line, col: first.col + 1, endLine: last.endLine, endCol: last.endCol(),
+	}
+}
+
+func (s *schema) create(p *parser, file string) {
+	p.accept("OR", "REPLACE")
+	_ = p.accept("TEMP") || p.accept("TEMPORARY")
+	_ = p.accept("UNLOGGED") || p.accept("GLOBAL") || p.accept("LOCAL")
+	unique := p.accept("UNIQUE")
+	switch {
+	case p.accept("TABLE"):
+		s.table(p, file)
+	case p.accept("VIRTUAL", "TABLE"):
+		s.virtualTable(p, file)
+	case p.accept("VIEW"), p.accept("MATERIALIZED", "VIEW"):
+		s.view(p, file)
+	case p.accept("INDEX"):
+		s.index(p, file, unique)
+	case p.accept("TRIGGER"), p.accept("CONSTRAINT", "TRIGGER"):
+		s.trigger(p, file)
+	case p.accept("FUNCTION"), p.accept("PROCEDURE"):
+		s.function(p, file)
+	case p.accept("TYPE"):
+		s.enum(p, file)
+	case p.accept("EXTENSION"):
+		p.accept("IF", "NOT", "EXISTS")
+		if name := p.qname(); name != "" {
+			s.extensions = append(s.extensions, extension{name: name, file: file, line: p.toks[0].line})
+		}
+	}
+}
+
+// ifNotExists consumes IF NOT EXISTS and reads the name after it,
+// reporting false if the statement is a no-op because an object of that
+// name exists.
+func (s *schema) ifNotExists(p *parser, kind string) (string, bool) {
+	ifNot := p.accept("IF", "NOT", "EXISTS")
+	name := p.qname()
+	if name == "" || ifNot && s.lookup(kind, name) != nil {
+		return "", false
+	}
+	return name, true
+}
+
+func (s *schema) table(p *parser, file string) {
+	name, ok := s.ifNotExists(p, "table")
+	if !ok {
+		return
+	}
+	o := p.newObject("table", name, file)
+	switch {
+	case p.accept("AS"):
+		o.query = p.text(p.i, len(p.toks))
+	case p.peek(0).is("("):
+		from, to := p.group()
+		after := p.i
+		for _, part := range p.split(from, to) {
+			p.i = part[0]
+			if p.isTableConstraint() {
+				p.tableConstraint(o, part[0], part[1], file)
+				continue
+			}
+			o.addColumn(p.column(part[0], part[1], file))
+		}
+		for p.i = after; !p.eof(); {
+			switch {
+			case p.accept("WITHOUT", "ROWID"):
+				o.rowid = false
+			case p.accept("STRICT"):
+				o.strict = true
+			default:
+				p.i++
+			}
+		}
+	}
+	s.put(o)
+}
+
+// addColumn adds a column, and the foreign key it declares if any.
+func (o *object) addColumn(c *column, fk *foreignKey) {
+	o.columns = append(o.columns, c)
+	if fk != nil {
+		o.foreignKeys = append(o.foreignKeys, fk)
+	}
+}
+
+// virtualTable reads a SQLite virtual table: the bare names among the
+// arguments of its module are its columns, and the k=v ones options.
+func (s *schema) virtualTable(p *parser, file string) {
+	name, ok := s.ifNotExists(p, "table")
+	if !ok || !p.accept("USING") {
+		return
+	}
+	o := p.newObject("table", name, file)
+	o.using = p.peek(0).text
+	p.i++
+	if p.peek(0).is("(") {
+		from, to := p.group()
+		for _, part := range p.split(from, to) {
+			if part[1]-part[0] > 1 && p.toks[part[0]+1].is("=") || !p.toks[part[0]].isName() {
+				o.options = append(o.options, p.text(part[0], part[1]))
+				continue
+			}
+			t := p.toks[part[0]]
+			doc := t.doc
+			if doc == "" {
+				doc = p.trail(part[0], part[1])
+			}
+			last := p.toks[part[1]-1]
+			o.columns = append(o.columns, &column{
+				name: t.name(), typ: p.text(part[0]+1, part[1]), doc: doc, file: file,
+				line: t.line, col: t.col + 1, endLine: last.endLine, endCol: last.endCol(),
+			})
+		}
+	}
+	s.put(o)
+}
+
+func (s *schema) view(p *parser, file string) {
+	name, ok := s.ifNotExists(p, "view")
+	if !ok {
+		return
+	}
+	o := p.newObject("view", name, file)
+	explicit := p.names()
+	for !p.eof() && !p.accept("AS") {
+		p.i++
+	}
+	o.query = p.text(p.i, len(p.toks))
+	selected, sources := p.selectList()
+	o.sources = sources
+	if explicit != nil {
+		selected = explicit
+	}
+	for _, c := range selected {
+		o.columns = append(o.columns, &column{name: c, file: file, line: o.line, col: o.col, endLine: o.endLine, endCol: o.endCol})
+	}
+	s.put(o)
+}
+
+// selectList reads the query at p.i, returning the names of the columns
+// of its first SELECT, and the tables and views it reads from.
+func (p *parser) selectList() (columns, sources []string) {
+	for !p.eof() && !p.peek(0).is("SELECT") {
+		p.i++
+	}
+	if !p.accept("SELECT") {
+		return nil, nil
+	}
+	p.accept("DISTINCT")
+	start := p.i
+	for !p.eof() && !p.peek(0).is("FROM") {
+		if p.peek(0).is("(") {
+			p.group()
+			continue
+		}
+		p.i++
+	}
+	for _, part := range p.split(start, p.i) {
+		last := p.toks[part[1]-1]
+		if !last.isName() {
+			continue
+		}
+		columns = append(columns, last.name())
+	}
+	for !p.eof() {
+		switch {
+		case p.accept("FROM"), p.accept("JOIN"):
+			if p.peek(0).isName() {
+				sources = appendNew(sources, p.qname())
+			}
+		case p.peek(0).is("("):
+			p.group()
+		default:
+			p.i++
+		}
+	}
+	return columns, sources
+}
+
+func appendNew(list []string, s string) []string {
+	for _, x := range list {
+		if strings.EqualFold(x, s) {
+			return list
+		}
+	}
+	return append(list, s)
+}
+
+func (s *schema) index(p *parser, file string, unique bool) {
+	p.accept("CONCURRENTLY")
+	name, ok := s.ifNotExists(p, "index")
+	if !ok || !p.accept("ON") {
+		return
+	}
+	p.accept("ONLY")
+	o := p.newObject("index", name, file)
+	o.table, o.unique = p.qname(), unique
+	if p.accept("USING") {
+		o.using = p.peek(0).text
+		p.i++
+	}
+	if p.peek(0).is("(") {
+		from, to := p.group()
+		for _, part := range p.split(from, to) {
+			if part[1]-part[0] == 1 && p.toks[part[0]].isName() {
+				o.indexed = append(o.indexed, p.toks[part[0]].name())
+			} else {
+				o.indexed = append(o.indexed, p.text(part[0], part[1]))
+			}
+		}
+	}
+	for !p.eof() {
+		if p.accept("WHERE") {
+			o.where = p.text(p.i, len(p.toks))
+			break
+		}
+		p.i++
+	}
+	s.put(o)
+}
+
+func (s *schema) trigger(p *parser, file string) {
+	name, ok := s.ifNotExists(p, "trigger")
+	if !ok {
+		return
+	}
+	o := p.newObject("trigger", name, file)
+	for _, t := range [][]string{{"BEFORE"}, {"AFTER"}, {"INSTEAD", "OF"}} {
+		if p.accept(t...) {
+			o.timing = strings.ToUpper(strings.Join(t, " "))
+		}
+	}
+	var events []string
+	for !p.eof() && !p.peek(0).is("ON") {
+		switch t := p.peek(0); {
+		case t.is("INSERT"), t.is("UPDATE"), t.is("DELETE"), t.is("TRUNCATE"):
+			events = append(events, strings.ToUpper(t.text))
+			p.i++
+		case t.is("OF"):
+			p.i++
+			for p.peek(0).isName() && !p.peek(0).is("ON") && !p.peek(0).is("OR") {
+				o.ofColumns = append(o.ofColumns, p.peek(0).name())
+				if p.i++; !p.accept(",") {
+					break
+				}
+			}
+		default:
+			p.i++
+		}
+	}
+	o.event = strings.Join(events, " OR ")
+	if !p.accept("ON") {
+		return
+	}
+	o.table = p.qname()
+	for !p.eof() {
+		switch {
+		case p.accept("FOR", "EACH"), p.accept("FOR"):
+			o.forEach = strings.ToUpper(p.peek(0).text)
+			p.i++
+		case p.accept("EXECUTE", "FUNCTION"), p.accept("EXECUTE", "PROCEDURE"):
+			o.function = p.qname()
+			p.i = len(p.toks)
+		case p.peek(0).is("BEGIN"):
+			p.i = len(p.toks)
+		case p.peek(0).is("("):
+			p.group()
+		default:
+			p.i++
+		}
+	}
+	s.put(o)
+}
+
+// function reads a PostgreSQL function or procedure: its parameters and
+// return type; its body is not read.
+func (s *schema) function(p *parser, file string) {
+	name := p.qname()
+	if name == "" || !p.peek(0).is("(") {
+		return
+	}
+	o := p.newObject("function", name, file)
+	from, to := p.group()
+	for _, part := range p.split(from, to) {
+		o.params = append(o.params, p.param(part[0], part[1]))
+	}
+	if o.params == nil {
+		o.params = []param{}
+	}
+	if p.accept("RETURNS") {
+		start := p.i
+		for !p.eof() && !p.peek(0).is("AS") && !p.peek(0).is("LANGUAGE") {
+			if p.peek(0).is("(") {
+				p.group()
+				continue
+			}
+			p.i++
+		}
+		o.returns = p.text(start, p.i)
+	}
+	s.put(o)
+}
+
+// param reads a parameter of a function in tokens [from, to): [mode]
+// [name] type [DEFAULT expr].
+func (p *parser) param(from, to int) param {
+	p.i = from
+	var pr param
+	for _, m := range []string{"IN", "OUT", "INOUT", "VARIADIC"} {
+		if p.accept(m) {
+			pr.Mode = m
+			break
+		}
+	}
+	end := to
+	for i := p.i; i < to; i++ {
+		if p.toks[i].is("DEFAULT") || p.toks[i].is("=") {
+			end = i
+			break
+		}
+	}
+	if end-p.i > 1 && p.peek(0).isName() && !p.peek(1).is("(") && !p.peek(1).is(".") && !p.peek(1).is("[") {
+		pr.Name = p.peek(0).name()
+		p.i++
+	}
+	pr.Type = p.text(p.i, end)
+	return pr
+}
+
+// enum reads CREATE TYPE ... AS ENUM; other types are not kept.
+func (s *schema) enum(p *parser, file string) {
+	name := p.qname()
+	if name == "" || !p.accept("AS", "ENUM") {
+		return
+	}
+	o := p.newObject("enum", name, file)
+	o.values = p.names()
+	s.put(o)
+}
+
+// alter applies ALTER TABLE, ALTER TYPE ... ADD VALUE and the renames of
+// other objects; trail is the comment after the statement, the doc of a
+// column it adds.
+func (s *schema) alter(p *parser, file, trail string) {
+	var kind string
+	switch {
+	case p.accept("TABLE"):
+		kind = "table"
+	case p.accept("VIEW"), p.accept("MATERIALIZED", "VIEW"):
+		kind = "view"
+	case p.accept("INDEX"):
+		kind = "index"
+	case p.accept("TYPE"):
+		kind = "enum"
+	case p.accept("FUNCTION"):
+		kind = "function"
+	default:
+		return
+	}
+	p.accept("IF", "EXISTS")
+	p.accept("ONLY")
+	o := s.lookup(kind, p.qname())
+	if o == nil {
+		return
+	}
+	if kind == "function" && p.peek(0).is("(") {
+		p.group()
+	}
+	end := len(p.toks)
+	for _, part := range p.split(p.i, end) {
+		p.i = part[0]
+		s.action(p, o, part[1], file, trail)
+	}
+}
+
+// action applies an action of an ALTER statement, in tokens [p.i, to), to
+// o.
+func (s *schema) action(p *parser, o *object, to int, file, trail string) {
+	switch {
+	case p.accept("RENAME", "TO"):
+		s.rename(o, p.qname())
+	case p.accept("RENAME", "VALUE"):
+		old := unquote(p.peek(0).text)
+		if p.i++; p.accept("TO") {
+			for i, v := range o.values {
+				if v == old {
+					o.values[i] = unquote(p.peek(0).text)
+				}
+			}
+		}
+	case p.accept("RENAME"):
+		p.accept("COLUMN")
+		old := p.peek(0).name()
+		if p.i++; !p.accept("TO") {
+			return
+		}
+		s.renameColumn(o, old, p.peek(0).name())
+	case p.accept("ADD", "VALUE"):
+		p.accept("IF", "NOT", "EXISTS")
+		v := unquote(p.peek(0).text)
+		p.i++
+		if indexFold(o.values, v) >= 0 {
+			return
+		}
+		i := len(o.values)
+		switch {
+		case p.accept("BEFORE"):
+			i = max(indexFold(o.values, unquote(p.peek(0).text)), 0)
+		case p.accept("AFTER"):
+			i = indexFold(o.values, unquote(p.peek(0).text)) + 1
+			if i == 0 {
+				i = len(o.values)
+			}
+		}
+		o.values = append(o.values[:i], append([]string{v}, o.values[i:]...)...)
+	case p.accept("ADD"):
+		if p.isTableConstraint() {
+			p.tableConstraint(o, p.i, to, file)
+			return
+		}
+		p.accept("COLUMN")
+		ifNot := p.accept("IF", "NOT", "EXISTS")
+		if ifNot && p.peek(0).isName() && o.column(p.peek(0).name()) != nil {
+			return
+		}
+		c, fk := p.column(p.i, to, file)
+		if c.doc == "" {
+			c.doc = trail
+		}
+		o.addColumn(c, fk)
+	case p.accept("DROP", "CONSTRAINT"):
+		p.accept("IF", "EXISTS")
+		name := p.peek(0).name()
+		o.foreignKeys = slices.DeleteFunc(o.foreignKeys, func(fk *foreignKey) bool { return strings.EqualFold(fk.name, name) })
+	case p.accept("DROP"):
+		p.accept("COLUMN")
+		p.accept("IF", "EXISTS")
+		name := p.peek(0).name()
+		for i, c := range o.columns {
+			if strings.EqualFold(c.name, name) {
+				o.columns = slices.Delete(o.columns, i, i+1)
+				break
+			}
+		}
+		o.foreignKeys = slices.DeleteFunc(o.foreignKeys, func(fk *foreignKey) bool { return indexFold(fk.columns, name) >= 0 })
+		for _, d := range s.list() {
+			if d.kind == "index" && strings.EqualFold(d.table, o.name) && indexFold(d.indexed, name) >= 0 {
+				s.remove(key(d.kind, d.name))
+			}
+		}
+	case p.accept("ALTER"):
+		p.accept("COLUMN")
+		c := o.column(p.peek(0).name())
+		if p.i++; c == nil {
+			return
+		}
+		switch {
+		case p.accept("TYPE"), p.accept("SET", "DATA", "TYPE"):
+			start := p.i
+			for p.i < to && !p.peek(0).is("USING") && !p.peek(0).is("COLLATE") {
+				p.i++
+			}
+			c.typ = p.text(start, p.i)
+		case p.accept("SET", "NOT", "NULL"):
+			c.notNull = true
+		case p.accept("DROP", "NOT", "NULL"):
+			c.notNull = false
+		case p.accept("SET", "DEFAULT"):
+			c.dflt = p.expr(to)
+		case p.accept("DROP", "DEFAULT"):
+			c.dflt = ""
+		}
+	}
+}
+
+// rename renames an object, and the references other objects make to it.
+func (s *schema) rename(o *object, name string) {
+	if name == "" {
+		return
+	}
+	old := o.name
+	k := key(o.kind, old)
+	delete(s.objects, k)
+	o.name = name
+	nk := key(o.kind, name)
+	s.objects[nk] = o
+	for i, sk := range s.order {
+		if sk == k {
+			s.order[i] = nk
+		}
+	}
+	for _, d := range s.objects {
+		if strings.EqualFold(d.table, old) {
+			d.table = name
+		}
+		for _, fk := range d.foreignKeys {
+			if strings.EqualFold(fk.table, old) {
+				fk.table = name
+			}
+		}
+	}
+}
+
+// renameColumn renames a column of a table, and the references to it.
+func (s *schema) renameColumn(o *object, old, name string) {
+	c := o.column(old)
+	if c == nil {
+		return
+	}
+	c.name = name
+	rename := func(list []string) {
+		for i, n := range list {
+			if strings.EqualFold(n, old) {
+				list[i] = name
+			}
+		}
+	}
+	rename(o.primaryKey)
+	for _, u := range o.uniques {
+		rename(u)
+	}
+	for _, fk := range o.foreignKeys {
+		rename(fk.columns)
+	}
+	for _, d := range s.objects {
+		if strings.EqualFold(d.table, o.name) {
+			rename(d.indexed)
+			rename(d.ofColumns)
+		}
+		for _, fk := range d.foreignKeys {
+			if strings.EqualFold(fk.table, o.name) {
+				rename(fk.refColumns)
+			}
+		}
+	}
+}
+
+// drop applies DROP of one or more objects.
+func (s *schema) drop(p *parser) {
+	var kind string
+	switch {
+	case p.accept("TABLE"):
+		kind = "table"
+	case p.accept("VIEW"), p.accept("MATERIALIZED", "VIEW"):
+		kind = "view"
+	case p.accept("INDEX"):
+		kind = "index"
+	case p.accept("TRIGGER"):
+		kind = "trigger"
+	case p.accept("FUNCTION"), p.accept("PROCEDURE"):
+		kind = "function"
+	case p.accept("TYPE"):
+		kind = "enum"
+	default:
+		return
+	}
+	p.accept("CONCURRENTLY")
+	p.accept("IF", "EXISTS")
+	for p.peek(0).isName() {
+		if o := s.lookup(kind, p.qname()); o != nil && o.kind == kind {
+			s.remove(key(o.kind, o.name))
+		}
+		if p.peek(0).is("(") {
+			p.group()
+		}
+		if !p.accept(",") {
+			break
+		}
+	}
+}
+
+// comment applies COMMENT ON a table, view, column, index, trigger,
+// function or type, whose text replaces its doc.
+func (s *schema) comment(p *parser) {
+	var o *object
+	switch {
+	case p.accept("COLUMN"):
+		start := p.i
+		p.qname()
+		name := p.toks[p.i-1].name()
+		p.i = start
+		table := p.qname()
+		table = table[:max(strings.LastIndex(table, "."), 0)]
+		if t := s.lookup("table", table); t != nil {
+			if c := t.column(name); c != nil && p.accept("IS") && p.peek(0).kind == tString {
+				c.doc = unquote(p.peek(0).text)
+			}
+		}
+		return
+	case p.accept("TRIGGER"):
+		o = s.lookup("trigger", p.qname())
+		p.accept("ON")
+		p.qname()
+	default:
+		for _, k := range []string{"TABLE", "VIEW", "INDEX", "FUNCTION", "TYPE"} {
+			if p.accept(k) {
+				kind := map[string]string{"TYPE": "enum"}[k]
+				if kind == "" {
+					kind = strings.ToLower(k)
+				}
+				if o = s.lookup(kind, p.qname()); o != nil && o.kind != kind {
+					o = nil
+				}
+				break
+			}
+		}
+	}
+	if p.peek(0).is("(") {
+		p.group()
+	}
+	if o != nil && p.accept("IS") && p.peek(0).kind == tString {
+		o.doc = unquote(p.peek(0).text)
+	}
+}
+
+func indexFold(list []string, s string) int {
+	for i, x := range list {
+		if strings.EqualFold(x, s) {
+			return i
+		}
+	}
+	return -1
+}
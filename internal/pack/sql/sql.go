@@ -0,0 +1,475 @@
+// Package sql is the language pack for SQL schemas. The .sql files of a
+// directory are read as a sequence of migrations, in the natural order of
+// their names, and their DDL is applied in turn to build the schema they
+// leave: CREATE, ALTER (added, dropped, renamed and altered columns,
+// renamed tables, added constraints), DROP and COMMENT ON statements of
+// SQLite and PostgreSQL. The down half of a goose or dbmate migration, and
+// a *.down.sql file, are left out.
+//
+// Each directory is a container of kind schema. Its tables and views are
+// symbols, with their columns as members, and its indexes, triggers,
+// functions and enum types are symbols too. The -- or /* */ comments
+// directly above a statement are the doc of what it creates, and those
+// after a column definition on its line the column's. A foreign key is a
+// references relation from its column to the column it references, and
+// CREATE EXTENSION an import.
+package sql
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/ChaseHampton/cargoworker/internal/ir"
+	"github.com/ChaseHampton/cargoworker/internal/pack"
+)
+
+func init() {
+	pack.Register("sql", New())
+}
+
+// Pack extracts SQL schemas. A schema is the migrations of one directory,
+// so a unit is all it needs and the pack keeps no state.
+type Pack struct{}
+
+func New() *Pack { return &Pack{} }
+
+func (p *Pack) Name() string { return "sql" }
+
+var _ pack.Pack = (*Pack)(nil)
+
+// Extract builds the schema of a unit's migrations.
+func (p *Pack) Extract(ctx context.Context, u pack.Unit) (*ir.Fragment, error) {
+	var files []string
+	for _, rel := range u.Files {
+		if path.Ext(rel) == ".sql" {
+			files = append(files, rel)
+		}
+	}
+	frag := &ir.Fragment{}
+	if len(files) == 0 {
+		return frag, nil
+	}
+	slices.SortStableFunc(files, func(a, b string) int { return naturalCompare(path.Base(a), path.Base(b)) })
+	name := path.Base(u.Dir)
+	if u.Dir == "." {
+		name = filepath.Base(u.Root)
+	}
+	e := &emitter{
+		frag: frag, cid: uuid.New(), files: map[string]uuid.UUID{},
+		syms: map[string]known{}, done: map[uuid.UUID]bool{},
+	}
+	frag.Containers = append(frag.Containers, ir.Container{Id: e.cid, Name: name, FullName: u.Dir, Kind: "schema", Language: u.Language})
+	s := newSchema()
+	for _, rel := range files {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		b, err := os.ReadFile(filepath.Join(u.Root, filepath.FromSlash(rel)))
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(b)
+		fid := uuid.New()
+		e.files[rel] = fid
+		frag.Files = append(frag.Files, ir.File{
+			Id: fid, ContainerId: e.cid, Path: rel, Checksum: hex.EncodeToString(sum[:]),
+			Language: u.Language, SizeBytes: int64(len(b)),
+		})
+		if strings.HasSuffix(rel, ".down.sql") {
+			continue
+		}
+		for _, st := range split(string(b)) {
+			s.exec(rel, st)
+		}
+	}
+	e.schema(s)
+	return frag, nil
+}
+
+// naturalCompare orders names with the runs of digits in them compared as
+// numbers, so 2_x.sql comes before 10_x.sql.
+func naturalCompare(a, b string) int {
+	for a != "" && b != "" {
+		da, db := digits(a), digits(b)
+		if da > 0 && db > 0 {
+			na, nb := strings.TrimLeft(a[:da], "0"), strings.TrimLeft(b[:db], "0")
+			if c := len(na) - len(nb); c != 0 {
+				return c
+			}
+			if c := strings.Compare(na, nb); c != 0 {
+				return c
+			}
+			a, b = a[da:], b[db:]
+			continue
+		}
+		if a[0] != b[0] {
+			return int(a[0]) - int(b[0])
+		}
+		a, b = a[1:], b[1:]
+	}
+	return len(a) - len(b)
+}
+
+// digits returns the length of the run of digits s starts with.
+func digits(s string) int {
+	n := 0
+	for n < len(s) && s[n] >= '0' && s[n] <= '9' {
+		n++
+	}
+	return n
+}
+
+// emitter turns a schema into the records of a fragment.
+type emitter struct {
+	frag  *ir.Fragment
+	cid   uuid.UUID
+	files map[string]uuid.UUID // by path
+	syms  map[string]known     // by lower-case full name
+	done  map[uuid.UUID]bool   // the ids emitted
+}
+
+// known is a symbol of the schema.
+type known struct {
+	full string
+	id   uuid.UUID
+	kind string
+}
+
+// ref is a reference to a symbol of the schema.
+type ref struct {
+	Symbol string `json:"symbol,omitempty"`
+	Name   string `json:"name,omitempty"`
+	Type   string `json:"type,omitempty"`
+	Text   string `json:"text,omitempty"`
+}
+
+// extra is the extra_json of a symbol.
+type extra struct {
+	Type          string     `json:"type,omitempty"` // a column's
+	NotNull       bool       `json:"not_null,omitempty"`
+	PrimaryKey    any        `json:"primary_key,omitempty"` // true for a column, its columns for a table
+	Unique        any        `json:"unique,omitempty"`      // true for a column or an index, the unique column sets of a table
+	Autoincrement bool       `json:"autoincrement,omitempty"`
+	Identity      bool       `json:"identity,omitempty"`
+	Default       string     `json:"default,omitempty"`
+	Generated     string     `json:"generated,omitempty"`
+	Collate       string     `json:"collate,omitempty"`
+	Checks        []string   `json:"checks,omitempty"`
+	References    string     `json:"references,omitempty"` // table(column)
+	Using         string     `json:"using,omitempty"`      // a virtual table's module, an index's method
+	Options       []string   `json:"options,omitempty"`
+	WithoutRowid  bool       `json:"without_rowid,omitempty"`
+	Strict        bool       `json:"strict,omitempty"`
+	Query         string     `json:"query,omitempty"`
+	Table         string     `json:"table,omitempty"` // of an index or trigger
+	Columns       []string   `json:"columns,omitempty"`
+	Where         string     `json:"where,omitempty"`
+	Timing        string     `json:"timing,omitempty"`
+	Event         string     `json:"event,omitempty"`
+	ForEach       string     `json:"for_each,omitempty"`
+	Function      string     `json:"function,omitempty"`
+	Values        []string   `json:"values,omitempty"`
+	ForeignKeys   []fkDetail `json:"foreign_keys,omitempty"`
+}
+
+// fkDetail is a foreign key of a table in its extra_json.
+type fkDetail struct {
+	Name       string   `json:"name,omitempty"`
+	Columns    []string `json:"columns"`
+	Table      string   `json:"table"`
+	RefColumns []string `json:"ref_columns,omitempty"`
+	OnDelete   string   `json:"on_delete,omitempty"`
+	OnUpdate   string   `json:"on_update,omitempty"`
+}
+
+func (e *emitter) schema(s *schema) {
+	objects := s.list()
+	// Ids first: foreign keys and type references point either way.
+	for _, o := range objects {
+		e.id(o.name, o.kind)
+		for _, c := range o.columns {
+			e.id(o.name+"."+c.name, "column")
+		}
+		for _, v := range o.values {
+			e.id(o.name+"."+v, "enum_member")
+		}
+	}
+	for _, o := range objects {
+		e.object(s, o)
+	}
+	for _, x := range s.extensions {
+		b, _ := json.Marshal(map[string]any{"file": x.file, "line": x.line, "kind": "extension"})
+		e.frag.Imports = append(e.frag.Imports, ir.Import{ContainerId: e.cid, Target: x.name, DetailsJson: string(b)})
+	}
+}
+
+func (e *emitter) id(full, kind string) {
+	k := strings.ToLower(full)
+	if _, ok := e.syms[k]; !ok {
+		e.syms[k] = known{full, uuid.New(), kind}
+	}
+}
+
+// lookup returns the symbol of a name, in any case, or the zero known if
+// the schema has none.
+func (e *emitter) lookup(name string) known { return e.syms[strings.ToLower(name)] }
+
+func (e *emitter) symbol(full, name, kind, file, doc string, line, col, endLine, endCol int, ex extra) uuid.UUID {
+	id := e.lookup(full).id
+	if e.done[id] {
+		id = uuid.New() // an index, say, named as a trigger is
+	}
+	e.done[id] = true
+	sym := ir.Symbol{
+		Id: id, ContainerId: e.cid, Name: name, FullName: full, Kind: kind, Visibility: "public", OriginFileId: e.files[file],
+		StartLine: line, StartCol: col, EndLine: endLine, EndCol: endCol,
+		DocRaw: doc, DocFmt: cleanComment(doc),
+	}
+	if b, _ := json.Marshal(ex); string(b) != "{}" {
+		sym.ExtraJson = string(b)
+	}
+	e.frag.Symbols = append(e.frag.Symbols, sym)
+	return id
+}
+
+func (e *emitter) object(s *schema, o *object) {
+	ex := extra{Using: o.using, Options: o.options, WithoutRowid: !o.rowid, Strict: o.strict, Query: o.query, Values: o.values}
+	var sig string
+	switch o.kind {
+	case "table":
+		sig = "CREATE TABLE " + o.name
+		if o.using != "" {
+			sig = "CREATE VIRTUAL TABLE " + o.name + " USING " + o.using
+		}
+		if pk := o.keyColumns(); len(pk) > 0 {
+			ex.PrimaryKey = pk
+		}
+		if len(o.uniques) > 0 {
+			ex.Unique = o.uniques
+		}
+		ex.Checks = o.checks
+		for _, fk := range o.foreignKeys {
+			ex.ForeignKeys = append(ex.ForeignKeys, fkDetail{
+				Name: fk.name, Columns: fk.columns, Table: fk.table, RefColumns: fk.refColumns, OnDelete: fk.onDelete, OnUpdate: fk.onUpdate,
+			})
+		}
+	case "view":
+		sig = "CREATE VIEW " + o.name
+	case "index":
+		sig = "CREATE INDEX "
+		if o.unique {
+			sig, ex.Unique = "CREATE UNIQUE INDEX ", true
+		}
+		sig += o.name + " ON " + o.table
+		if o.using != "" {
+			sig += " USING " + o.using
+		}
+		sig += " (" + strings.Join(o.indexed, ", ") + ")"
+		if o.where != "" {
+			sig += " WHERE " + o.where
+		}
+		ex.Table, ex.Columns, ex.Where = o.table, o.indexed, o.where
+	case "trigger":
+		sig = "CREATE TRIGGER " + o.name + " " + strings.TrimSpace(o.timing+" "+o.event)
+		if len(o.ofColumns) > 0 {
+			sig += " OF " + strings.Join(o.ofColumns, ", ")
+		}
+		sig += " ON " + o.table
+		if o.forEach != "" {
+			sig += " FOR EACH " + o.forEach
+		}
+		if o.function != "" {
+			sig += " EXECUTE FUNCTION " + o.function + "()"
+		}
+		ex.Table, ex.Timing, ex.Event, ex.Columns, ex.ForEach, ex.Function = o.table, o.timing, o.event, o.ofColumns, o.forEach, o.function
+	case "function":
+		var params []string
+		for _, pr := range o.params {
+			params = append(params, strings.TrimSpace(strings.Join([]string{pr.Mode, pr.Name, pr.Type}, " ")))
+		}
+		sig = "CREATE FUNCTION " + o.name + "(" + strings.Join(params, ", ") + ")"
+		if o.returns != "" {
+			sig += " RETURNS " + o.returns
+		}
+	case "enum":
+		quoted := make([]string, len(o.values))
+		for i, v := range o.values {
+			quoted[i] = "'" + strings.ReplaceAll(v, "'", "''") + "'"
+		}
+		sig = "CREATE TYPE " + o.name + " AS ENUM (" + strings.Join(quoted, ", ") + ")"
+	}
+	id := e.symbol(o.name, o.name, o.kind, o.file, o.doc, o.line, o.col, o.endLine, o.endCol, ex)
+	e.signature(o, id, sig)
+	for i, c := range o.columns {
+		cid := e.column(s, o, c)
+		e.frag.Members = append(e.frag.Members, ir.Member{Id: uuid.New(), OwnerSymbolId: id, ChildSymbolId: cid, Order: i})
+	}
+	for i, v := range o.values {
+		vid := e.symbol(o.name+"."+v, v, "enum_member", o.file, "", o.line, o.col, o.endLine, o.endCol, extra{})
+		e.frag.Members = append(e.frag.Members, ir.Member{Id: uuid.New(), OwnerSymbolId: id, ChildSymbolId: vid, Order: i})
+	}
+	e.foreignKeys(s, o)
+}
+
+// signature records the signature of an object and the objects it refers
+// to: the table of an index or trigger, the function a trigger executes,
+// the sources of a view, and the types of columns that are the schema's.
+func (e *emitter) signature(o *object, id uuid.UUID, text string) {
+	sig := ir.Signature{SymbolId: id, Text: text}
+	js := struct {
+		Columns []param `json:"columns,omitempty"`
+		Params  []param `json:"params,omitempty"`
+		Results []param `json:"results,omitempty"`
+	}{}
+	switch o.kind {
+	case "table", "view":
+		for _, c := range o.columns {
+			js.Columns = append(js.Columns, param{Name: c.name, Type: c.typ})
+			e.typeref(id, "field:"+c.name, 0, c.typ, "enum")
+		}
+		for i, src := range o.sources {
+			e.typeref(id, "from", i, src, "table", "view")
+		}
+	case "index", "trigger":
+		e.typeref(id, "table", 0, o.table, "table", "view")
+		if o.function != "" {
+			e.typeref(id, "function", 0, o.function, "function")
+		}
+	case "function":
+		js.Params = o.params
+		for i, pr := range o.params {
+			e.typeref(id, fmt.Sprintf("param:%d", i), 0, pr.Type, "enum", "table")
+		}
+		if o.returns != "" {
+			js.Results = []param{{Type: o.returns}}
+			e.typeref(id, "result:0", 0, strings.TrimPrefix(o.returns, "SETOF "), "enum", "table")
+		}
+	}
+	if b, _ := json.Marshal(js); string(b) != "{}" {
+		sig.Json = string(b)
+	}
+	e.frag.Signatures = append(e.frag.Signatures, sig)
+}
+
+// typeref records a reference to a symbol of the schema of one of kinds;
+// references to anything else, built-in types among them, are not kept.
+func (e *emitter) typeref(owner uuid.UUID, slot string, order int, name string, kinds ...string) {
+	text := name
+	name = strings.TrimSuffix(name, "[]")
+	k := e.lookup(name)
+	if !slices.Contains(kinds, k.kind) {
+		return
+	}
+	b, _ := json.Marshal(ref{Symbol: k.full, Name: name, Type: k.kind, Text: text})
+	e.frag.Typerefs = append(e.frag.Typerefs, ir.Typeref{Id: uuid.New(), OwnerSymbolId: owner, Slot: slot, Json: string(b), Order: order})
+}
+
+func (e *emitter) column(s *schema, o *object, c *column) uuid.UUID {
+	ex := extra{
+		Type: c.typ, NotNull: c.notNull, Autoincrement: c.autoincrement, Identity: c.identity,
+		Default: c.dflt, Generated: c.generated, Collate: c.collate, Checks: c.checks,
+	}
+	parts := []string{c.name}
+	if c.typ != "" {
+		parts = append(parts, c.typ)
+	}
+	if c.primaryKey {
+		ex.PrimaryKey = true
+		parts = append(parts, "PRIMARY KEY")
+		if c.autoincrement {
+			parts = append(parts, "AUTOINCREMENT")
+		}
+	}
+	if c.notNull {
+		parts = append(parts, "NOT NULL")
+	}
+	if c.unique {
+		ex.Unique = true
+		parts = append(parts, "UNIQUE")
+	}
+	if c.identity {
+		parts = append(parts, "GENERATED AS IDENTITY")
+	}
+	if c.dflt != "" {
+		parts = append(parts, "DEFAULT "+c.dflt)
+	}
+	if c.generated != "" {
+		parts = append(parts, "GENERATED ALWAYS AS ("+c.generated+")")
+	}
+	if c.collate != "" {
+		parts = append(parts, "COLLATE "+c.collate)
+	}
+	for _, chk := range c.checks {
+		parts = append(parts, "CHECK ("+chk+")")
+	}
+	for _, fk := range o.foreignKeys {
+		if len(fk.columns) == 1 && strings.EqualFold(fk.columns[0], c.name) {
+			ex.References = fk.table
+			if cols := e.refColumns(s, fk); len(cols) == 1 {
+				ex.References += "(" + cols[0] + ")"
+			}
+			r := "REFERENCES " + ex.References
+			if fk.onDelete != "" {
+				r += " ON DELETE " + fk.onDelete
+			}
+			if fk.onUpdate != "" {
+				r += " ON UPDATE " + fk.onUpdate
+			}
+			parts = append(parts, r)
+		}
+	}
+	id := e.symbol(o.name+"."+c.name, c.name, "column", c.file, c.doc, c.line, c.col, c.endLine, c.endCol, ex)
+	e.frag.Signatures = append(e.frag.Signatures, ir.Signature{SymbolId: id, Text: strings.Join(parts, " ")})
+	return id
+}
+
+// refColumns returns the columns a foreign key references: those it
+// names, or the primary key of its table.
+func (e *emitter) refColumns(s *schema, fk *foreignKey) []string {
+	if len(fk.refColumns) > 0 {
+		return fk.refColumns
+	}
+	if t := s.lookup("table", fk.table); t != nil {
+		return t.keyColumns()
+	}
+	return nil
+}
+
+// foreignKeys records a references relation from each column of a
+// table's foreign keys to the column it references, or to the table when
+// the column is not known.
+func (e *emitter) foreignKeys(s *schema, o *object) {
+	type details struct {
+		File       string `json:"file"`
+		Line       int    `json:"line"`
+		Constraint string `json:"constraint,omitempty"`
+		OnDelete   string `json:"on_delete,omitempty"`
+		OnUpdate   string `json:"on_update,omitempty"`
+	}
+	for _, fk := range o.foreignKeys {
+		refs := e.refColumns(s, fk)
+		b, _ := json.Marshal(details{File: fk.file, Line: fk.line, Constraint: fk.name, OnDelete: fk.onDelete, OnUpdate: fk.onUpdate})
+		for i, c := range fk.columns {
+			src, dst := e.lookup(o.name+"."+c).id, e.lookup(fk.table).id
+			if i < len(refs) {
+				if id := e.lookup(fk.table + "." + refs[i]).id; id != uuid.Nil {
+					dst = id
+				}
+			}
+			if src == uuid.Nil || dst == uuid.Nil {
+				continue
+			}
+			e.frag.Relations = append(e.frag.Relations, ir.Relation{SourceSymbolId: src, Relation: "references", DstSymbolId: dst, DetailsJson: string(b)})
+		}
+	}
+}
@@ -0,0 +1,341 @@
+package sql
+
+import (
+	"context"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/ChaseHampton/cargoworker/internal/db"
+	"github.com/ChaseHampton/cargoworker/internal/ir"
+	"github.com/ChaseHampton/cargoworker/internal/pack/packtest"
+)
+
+var tree = map[string]string{
+	"db/migrations/1_init.sql": `-- +goose Up
+-- Accounts that own projects.
+CREATE TABLE accounts (
+  id         INTEGER PRIMARY KEY AUTOINCREMENT,
+  email      TEXT NOT NULL UNIQUE, -- lower-cased
+  created_at TEXT NOT NULL DEFAULT (datetime('now'))
+);
+
+CREATE TABLE projects (
+  id     INTEGER PRIMARY KEY,
+  owner  INTEGER NOT NULL,
+  name   TEXT NOT NULL,
+  legacy TEXT,
+  CONSTRAINT fk_owner FOREIGN KEY (owner) REFERENCES accounts ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE projects;
+DROP TABLE accounts;
+`,
+	"db/migrations/2_tasks.sql": `CREATE TABLE tasks (
+  id         INTEGER PRIMARY KEY,
+  project_id INTEGER REFERENCES projects(id),
+  title      TEXT
+);
+
+-- Keeps the project's name fresh.
+CREATE TRIGGER tasks_ai AFTER INSERT ON tasks BEGIN
+  UPDATE projects SET name = name WHERE id = new.project_id;
+  SELECT CASE WHEN new.title = '' THEN RAISE(ABORT, 'no title') END;
+END;
+`,
+	"db/migrations/10_rename.sql": `ALTER TABLE projects RENAME TO project;
+ALTER TABLE project DROP COLUMN legacy;
+ALTER TABLE tasks ADD COLUMN done INTEGER NOT NULL DEFAULT 0; -- 1 once finished
+ALTER TABLE tasks RENAME COLUMN title TO summary;
+
+/* Open tasks by project. */
+CREATE INDEX idx_tasks_open ON tasks(project_id) WHERE done = 0;
+
+CREATE VIEW open_tasks AS
+SELECT t.id, t.summary AS title, p.name
+FROM tasks t JOIN project p ON p.id = t.project_id
+WHERE t.done = 0;
+`,
+	"db/migrations/10_rename.down.sql": `DROP VIEW open_tasks;
+`,
+	"db/migrations/schema.prisma": `model Account {
+  id Int @id
+}
+`,
+	"pg/schema.sql": `CREATE EXTENSION IF NOT EXISTS pgcrypto;
+
+CREATE TYPE mood AS ENUM ('sad', 'happy');
+
+CREATE TABLE public.people (
+  id      bigint GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+  feeling mood NOT NULL
+);
+COMMENT ON COLUMN people.feeling IS 'How they feel.';
+
+CREATE FUNCTION touch() RETURNS trigger LANGUAGE plpgsql AS $$
+BEGIN
+  RETURN NEW; -- unchanged
+END;
+$$;
+
+CREATE TRIGGER people_touch BEFORE UPDATE ON people
+  FOR EACH ROW EXECUTE FUNCTION touch();
+
+ALTER TYPE mood ADD VALUE 'meh' BEFORE 'happy';
+`,
+}
+
+// sqlLanguage is the language of every file of the trees: .prisma files are
+// language sql too.
+func sqlLanguage(string) string { return "sql" }
+
+func TestExtract(t *testing.T) {
+	var files []string
+	for rel := range tree {
+		files = append(files, rel)
+	}
+	f := packtest.Extract(t, packtest.WriteTree(t, tree), files, sqlLanguage)
+
+	var containers []string
+	for _, c := range f.Containers {
+		containers = append(containers, c.Kind+" "+c.Name+" "+c.FullName)
+	}
+	if got := strings.Join(containers, ", "); got != "schema migrations db/migrations, schema pg pg" {
+		t.Errorf("containers = %s", got)
+	}
+	if len(f.Files) != 5 {
+		t.Errorf("%d files", len(f.Files)) // schema.prisma is left out
+	}
+
+	syms := map[string]ir.Symbol{}
+	names := map[uuid.UUID]string{}
+	for _, s := range f.Symbols {
+		if _, dup := syms[s.FullName]; dup {
+			t.Errorf("duplicate symbol %s", s.FullName)
+		}
+		syms[s.FullName] = s
+		names[s.Id] = s.FullName
+	}
+	for name, want := range map[string]string{
+		"accounts":           "table",
+		"accounts.email":     "column",
+		"project":            "table",
+		"project.owner":      "column",
+		"project.legacy":     "",
+		"projects":           "",
+		"tasks.summary":      "column",
+		"tasks.title":        "",
+		"tasks.done":         "column",
+		"idx_tasks_open":     "index",
+		"tasks_ai":           "trigger",
+		"open_tasks":         "view",
+		"open_tasks.title":   "column",
+		"mood":               "enum",
+		"mood.meh":           "enum_member",
+		"people":             "table",
+		"people.feeling":     "column",
+		"touch":              "function",
+		"people_touch":       "trigger",
+		"public.people":      "",
+		"tasks.project_id":   "column",
+		"open_tasks.summary": "",
+	} {
+		if got := syms[name].Kind; got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	if n := len(f.Symbols); n != 28 {
+		t.Errorf("%d symbols", n)
+	}
+
+	for name, want := range map[string]string{
+		"accounts":       "Accounts that own projects.",
+		"accounts.email": "lower-cased",
+		"tasks.done":     "1 once finished",
+		"idx_tasks_open": "Open tasks by project.",
+		"tasks_ai":       "Keeps the project's name fresh.",
+		"people.feeling": "How they feel.",
+	} {
+		if got := syms[name].DocFmt; got != want {
+			t.Errorf("%s doc = %q, want %q", name, got, want)
+		}
+	}
+	for name, want := range map[string]string{
+		"accounts":       `{"primary_key":["id"]}`,
+		"accounts.email": `{"type":"TEXT","not_null":true,"unique":true}`,
+		"accounts.id":    `{"type":"INTEGER","primary_key":true,"autoincrement":true}`,
+		"project":        `{"primary_key":["id"],"foreign_keys":[{"name":"fk_owner","columns":["owner"],"table":"accounts","on_delete":"CASCADE"}]}`,
+		"tasks.done":     `{"type":"INTEGER","not_null":true,"default":"0"}`,
+		"idx_tasks_open": `{"table":"tasks","columns":["project_id"],"where":"done = 0"}`,
+		"tasks_ai":       `{"table":"tasks","timing":"AFTER","event":"INSERT"}`,
+		"people.id":      `{"type":"bigint","primary_key":true,"identity":true}`,
+		"people_touch":   `{"table":"people","timing":"BEFORE","event":"UPDATE","for_each":"ROW","function":"touch"}`,
+		"mood":           `{"values":["sad","meh","happy"]}`,
+	} {
+		if got := syms[name].ExtraJson; got != want {
+			t.Errorf("%s extra = %s, want %s", name, got, want)
+		}
+	}
+	if s := syms["tasks"]; s.StartLine != 1 || s.StartCol != 1 || s.EndLine != 5 || s.EndCol != 2 {
+		t.Errorf("tasks at %d:%d-%d:%d", s.StartLine, s.StartCol, s.EndLine, s.EndCol)
+	}
+	if s := syms["tasks.done"]; s.StartLine != 3 || s.StartCol != 30 {
+		t.Errorf("tasks.done at %d:%d", s.StartLine, s.StartCol)
+	}
+
+	sigs := map[string]string{}
+	for _, s := range f.Signatures {
+		sigs[names[s.SymbolId]] = s.Text
+	}
+	for name, want := range map[string]string{
+		"accounts.created_at": "created_at TEXT NOT NULL DEFAULT datetime('now')",
+		"project.owner":       "owner INTEGER NOT NULL REFERENCES accounts(id) ON DELETE CASCADE",
+		"tasks.project_id":    "project_id INTEGER REFERENCES project(id)",
+		"idx_tasks_open":      "CREATE INDEX idx_tasks_open ON tasks (project_id) WHERE done = 0",
+		"tasks_ai":            "CREATE TRIGGER tasks_ai AFTER INSERT ON tasks",
+		"people_touch":        "CREATE TRIGGER people_touch BEFORE UPDATE ON people FOR EACH ROW EXECUTE FUNCTION touch()",
+		"touch":               "CREATE FUNCTION touch() RETURNS trigger",
+		"mood":                "CREATE TYPE mood AS ENUM ('sad', 'meh', 'happy')",
+	} {
+		if got := sigs[name]; got != want {
+			t.Errorf("%s sig = %q, want %q", name, got, want)
+		}
+	}
+
+	var members []string
+	for _, m := range f.Members {
+		if names[m.OwnerSymbolId] == "open_tasks" {
+			members = append(members, names[m.ChildSymbolId])
+		}
+	}
+	if got := strings.Join(members, " "); got != "open_tasks.id open_tasks.title open_tasks.name" {
+		t.Errorf("open_tasks members = %s", got)
+	}
+
+	var rels []string
+	for _, r := range f.Relations {
+		rels = append(rels, names[r.SourceSymbolId]+" "+r.Relation+" "+names[r.DstSymbolId]+" "+r.DetailsJson)
+	}
+	want := []string{
+		`project.owner references accounts.id {"file":"db/migrations/1_init.sql","line":14,"constraint":"fk_owner","on_delete":"CASCADE"}`,
+		`tasks.project_id references project.id {"file":"db/migrations/2_tasks.sql","line":3}`,
+	}
+	if strings.Join(rels, "\n") != strings.Join(want, "\n") {
+		t.Errorf("relations =\n%s\nwant\n%s", strings.Join(rels, "\n"), strings.Join(want, "\n"))
+	}
+
+	var refs []string
+	for _, r := range f.Typerefs {
+		refs = append(refs, names[r.OwnerSymbolId]+" "+r.Slot+" "+r.Json)
+	}
+	sort.Strings(refs)
+	wantRefs := []string{
+		`idx_tasks_open table {"symbol":"tasks","name":"tasks","type":"table","text":"tasks"}`,
+		`open_tasks from {"symbol":"project","name":"project","type":"table","text":"project"}`,
+		`open_tasks from {"symbol":"tasks","name":"tasks","type":"table","text":"tasks"}`,
+		`people field:feeling {"symbol":"mood","name":"mood","type":"enum","text":"mood"}`,
+		`people_touch function {"symbol":"touch","name":"touch","type":"function","text":"touch"}`,
+		`people_touch table {"symbol":"people","name":"people","type":"table","text":"people"}`,
+		`tasks_ai table {"symbol":"tasks","name":"tasks","type":"table","text":"tasks"}`,
+	}
+	if strings.Join(refs, "\n") != strings.Join(wantRefs, "\n") {
+		t.Errorf("typerefs =\n%s\nwant\n%s", strings.Join(refs, "\n"), strings.Join(wantRefs, "\n"))
+	}
+
+	if len(f.Imports) != 1 || f.Imports[0].Target != "pgcrypto" ||
+		f.Imports[0].DetailsJson != `{"file":"pg/schema.sql","kind":"extension","line":1}` {
+		t.Errorf("imports = %+v", f.Imports)
+	}
+}
+
+// TestMigrations checks the schema built from cargoworker's own SQLite
+// migrations against the one SQLite builds from them.
+func TestMigrations(t *testing.T) {
+	root := "../../db/sql/migrations"
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var files []string
+	for _, e := range entries {
+		files = append(files, e.Name())
+	}
+	f := packtest.Extract(t, root, files, sqlLanguage)
+
+	tables, err := db.Schema(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want []string
+	for _, tb := range tables {
+		kind := "table"
+		if tb.View {
+			kind = "view"
+		}
+		want = append(want, tb.Name+" "+kind+"("+strings.Join(tb.Columns, ", ")+")")
+	}
+	columns := map[uuid.UUID][]string{}
+	names := map[uuid.UUID]string{}
+	for _, s := range f.Symbols {
+		names[s.Id] = s.Name
+	}
+	for _, m := range f.Members {
+		columns[m.OwnerSymbolId] = append(columns[m.OwnerSymbolId], names[m.ChildSymbolId])
+	}
+	var got []string
+	for _, s := range f.Symbols {
+		if s.Kind == "table" || s.Kind == "view" {
+			got = append(got, s.Name+" "+s.Kind+"("+strings.Join(columns[s.Id], ", ")+")")
+		}
+	}
+	sort.Strings(got)
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("schema =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestSplit(t *testing.T) {
+	src := "CREATE TABLE a (x TEXT DEFAULT ';');\n" +
+		"CREATE TRIGGER t AFTER INSERT ON a BEGIN\n  SELECT CASE WHEN 1 THEN 2 END;\n  DELETE FROM a;\nEND;\n" +
+		"CREATE FUNCTION f() RETURNS int AS $body$ SELECT 1; $body$ LANGUAGE sql;\n" +
+		"-- migrate:down\nDROP TABLE a;\n"
+	var got []string
+	for _, st := range split(src) {
+		p := &parser{toks: st.toks}
+		got = append(got, p.text(0, len(st.toks)))
+	}
+	want := []string{
+		"CREATE TABLE a (x TEXT DEFAULT ';')",
+		"CREATE TRIGGER t AFTER INSERT ON a BEGIN SELECT CASE WHEN 1 THEN 2 END; DELETE FROM a; END",
+		"CREATE FUNCTION f() RETURNS int AS $body$ SELECT 1; $body$ LANGUAGE sql",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("statements =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if naturalCompare("2_b.sql", "10_a.sql") >= 0 || naturalCompare("V1__x.sql", "V1__y.sql") >= 0 {
+		t.Error("natural order")
+	}
+}
+
+func TestLex(t *testing.T) {
+	src := "-- Doc one.\n/* Doc\n * two. */\nCREATE TABLE \"my \"\"t\"\" \" ( -- trailing\n\n  -- detached\n\n  x int DEFAULT E'it\\'s', y text[]);\n"
+	toks := lex(src)
+	var got []string
+	for _, tok := range toks {
+		got = append(got, tok.text)
+	}
+	want := []string{"CREATE", "TABLE", `"my ""t"" "`, "(", "x", "int", "DEFAULT", `E'it\'s'`, ",", "y", "text", "[", "]", ")", ";"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("tokens = %q, want %q", got, want)
+	}
+	if d := cleanComment(toks[0].doc); d != "Doc one.\nDoc\ntwo." {
+		t.Errorf("doc = %q", d)
+	}
+	if toks[2].name() != `my "t" ` || toks[3].trail != "-- trailing" || toks[4].doc != "" {
+		t.Errorf("name = %q, trail = %q, doc = %q", toks[2].name(), toks[3].trail, toks[4].doc)
+	}
+}
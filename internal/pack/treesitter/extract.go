@@ -0,0 +1,313 @@
+package treesitter
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/ChaseHampton/cargoworker/internal/ir"
+	"github.com/ChaseHampton/cargoworker/internal/pack"
+)
+
+// maxSignature bounds signature text; a @signature node may span a body.
+const maxSignature = 1000
+
+// extraction is the state of one Extract call.
+type extraction struct {
+	p          *Pack
+	u          pack.Unit
+	frag       *ir.Fragment
+	containers map[string]uuid.UUID // by full name
+	dirs       map[string]Container // by directory
+	imports    map[[3]string]bool   // container, target, alias
+	children   map[uuid.UUID]int    // members so far, by owner
+}
+
+func newExtraction(p *Pack, u pack.Unit) *extraction {
+	return &extraction{
+		p: p, u: u, frag: &ir.Fragment{},
+		containers: map[string]uuid.UUID{},
+		dirs:       map[string]Container{},
+		imports:    map[[3]string]bool{},
+		children:   map[uuid.UUID]int{},
+	}
+}
+
+func (x *extraction) container(rel string) (uuid.UUID, string) {
+	dir := path.Dir(rel)
+	c, ok := x.dirs[dir]
+	if !ok {
+		c = x.p.lang.Container(x.u.Root, dir)
+		x.dirs[dir] = c
+	}
+	if id, ok := x.containers[c.FullName]; ok {
+		return id, c.FullName
+	}
+	id := uuid.New()
+	x.containers[c.FullName] = id
+	x.frag.Containers = append(x.frag.Containers, ir.Container{
+		Id: id, Language: x.u.Language, Name: c.Name, FullName: c.FullName, Kind: c.Kind,
+	})
+	return id, c.FullName
+}
+
+// def is a definition gathered from the matches of its node.
+type def struct {
+	kind    string
+	pattern int
+	node    Capture
+	name    Capture
+	docs    []Capture
+	sig     *Capture
+}
+
+func (x *extraction) file(ctx context.Context, rel string) error {
+	src, err := os.ReadFile(filepath.Join(x.u.Root, filepath.FromSlash(rel)))
+	if err != nil {
+		return err
+	}
+	cid, cname := x.container(rel)
+	fid := uuid.New()
+	sum := sha256.Sum256(src)
+	x.frag.Files = append(x.frag.Files, ir.File{
+		Id: fid, ContainerId: cid, Path: rel, Checksum: hex.EncodeToString(sum[:]),
+		Language: x.u.Language, SizeBytes: int64(len(src)),
+	})
+
+	res, err := x.p.rt.Query(ctx, x.u.Language, src, x.p.lang.Query)
+	if err != nil {
+		return fmt.Errorf("%s: %w", rel, err)
+	}
+	if n := len(res.Errors); n > 0 {
+		x.frag.Diagnostics = append(x.frag.Diagnostics, ir.Diagnostic{
+			Id: uuid.New(), Scope: "parse", Severity: "warn", Code: "syntax", FileId: fid,
+			Line: res.Errors[0].Row + 1, Column: res.Errors[0].Column + 1,
+			Message: fmt.Sprintf("%d syntax error(s); extraction may be incomplete", n),
+		})
+	}
+
+	defs := x.definitions(cid, cname, rel, src, res.Matches)
+	sort.Slice(defs, func(i, j int) bool {
+		if defs[i].node.StartByte != defs[j].node.StartByte {
+			return defs[i].node.StartByte < defs[j].node.StartByte
+		}
+		return defs[i].node.EndByte > defs[j].node.EndByte
+	})
+
+	type open struct {
+		d        *def
+		id       uuid.UUID
+		fullName string
+	}
+	var stack []open
+	for _, d := range defs {
+		for len(stack) > 0 && stack[len(stack)-1].d.node.EndByte < d.node.EndByte {
+			stack = stack[:len(stack)-1]
+		}
+		name := string(src[d.name.StartByte:d.name.EndByte])
+		prefix, kind := cname, d.kind
+		var parent *open
+		if len(stack) > 0 {
+			parent = &stack[len(stack)-1]
+			prefix = parent.fullName
+			if kind == "function" && classLike(parent.d.kind) {
+				kind = "method"
+			}
+		}
+		id := uuid.New()
+		fullName := prefix + x.p.lang.Separator + name
+		raw := docText(src, d)
+		x.frag.Symbols = append(x.frag.Symbols, ir.Symbol{
+			Id: id, ContainerId: cid, Name: name, FullName: fullName, Kind: kind, OriginFileId: fid,
+			StartLine: d.name.Start.Row + 1, StartCol: d.name.Start.Column + 1,
+			EndLine: d.node.End.Row + 1, EndCol: d.node.End.Column + 1,
+			DocRaw: raw, DocFmt: cleanDoc(raw),
+		})
+		if d.sig != nil {
+			text := strings.Join(strings.Fields(string(src[d.sig.StartByte:d.sig.EndByte])), " ")
+			if len(text) > maxSignature {
+				text = text[:maxSignature] + "…"
+			}
+			x.frag.Signatures = append(x.frag.Signatures, ir.Signature{SymbolId: id, Text: text})
+		}
+		if parent != nil {
+			x.frag.Members = append(x.frag.Members, ir.Member{
+				Id: uuid.New(), OwnerSymbolId: parent.id, ChildSymbolId: id, Order: x.children[parent.id],
+			})
+			x.children[parent.id]++
+		}
+		stack = append(stack, open{d, id, fullName})
+	}
+	return nil
+}
+
+// definitions gathers the definitions of a file's matches, merging matches
+// of the same node, and records its imports.
+func (x *extraction) definitions(cid uuid.UUID, cname, rel string, src []byte, matches []Match) []*def {
+	byNode := map[[2]int]*def{}
+	var defs []*def
+	for _, m := range matches {
+		var d def
+		var node, name, imp, alias *Capture
+		for i := range m.Captures {
+			c := &m.Captures[i]
+			switch {
+			case strings.HasPrefix(c.Name, "definition."):
+				node, d.kind = c, strings.TrimPrefix(c.Name, "definition.")
+			case c.Name == "name":
+				name = c
+			case c.Name == "doc":
+				d.docs = append(d.docs, *c)
+			case c.Name == "signature":
+				d.sig = c
+			case c.Name == "import":
+				imp = c
+			case c.Name == "import.alias":
+				alias = c
+			}
+		}
+		if imp != nil {
+			x.addImport(cid, cname, rel, src, imp, alias)
+		}
+		if node == nil || name == nil || name.EndByte <= name.StartByte {
+			continue
+		}
+		d.node, d.name, d.pattern = *node, *name, m.Pattern
+		key := [2]int{node.StartByte, node.EndByte}
+		prev, ok := byNode[key]
+		if !ok {
+			byNode[key] = &d
+			defs = append(defs, &d)
+			continue
+		}
+		// The earliest pattern names the definition; docs and signatures
+		// come from any.
+		if d.pattern < prev.pattern {
+			prev.kind, prev.name, prev.pattern = d.kind, d.name, d.pattern
+		}
+		for _, c := range d.docs {
+			if !hasCapture(prev.docs, c) {
+				prev.docs = append(prev.docs, c)
+			}
+		}
+		if prev.sig == nil {
+			prev.sig = d.sig
+		}
+	}
+	return defs
+}
+
+func hasCapture(cs []Capture, c Capture) bool {
+	for _, o := range cs {
+		if o.StartByte == c.StartByte && o.EndByte == c.EndByte {
+			return true
+		}
+	}
+	return false
+}
+
+func (x *extraction) addImport(cid uuid.UUID, cname, rel string, src []byte, imp, alias *Capture) {
+	target := unquote(string(src[imp.StartByte:imp.EndByte]))
+	if target == "" {
+		return
+	}
+	as := ""
+	if alias != nil {
+		as = string(src[alias.StartByte:alias.EndByte])
+	}
+	key := [3]string{cname, target, as}
+	if x.imports[key] {
+		return
+	}
+	x.imports[key] = true
+	details, _ := json.Marshal(map[string]any{"file": rel, "line": imp.Start.Row + 1})
+	x.frag.Imports = append(x.frag.Imports, ir.Import{ContainerId: cid, Target: target, Alias: as, DetailsJson: string(details)})
+}
+
+// unquote drops the quotes or brackets around an import target.
+func unquote(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) < 2 {
+		return s
+	}
+	switch first, last := s[0], s[len(s)-1]; {
+	case (first == '"' || first == '\'' || first == '`') && last == first, first == '<' && last == '>':
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// classLike reports whether functions defined in a definition of kind are
+// its methods.
+func classLike(kind string) bool {
+	switch kind {
+	case "class", "struct", "interface", "trait", "impl", "object", "enum", "record", "protocol", "module", "type":
+		return true
+	}
+	return false
+}
+
+// docText joins the doc captures of d: those inside the definition, such as
+// docstrings, and the block of comments ending right above it. Comments
+// separated from the definition by a blank line are not its docs.
+func docText(src []byte, d *def) string {
+	docs := append([]Capture(nil), d.docs...)
+	sort.Slice(docs, func(i, j int) bool { return docs[i].StartByte < docs[j].StartByte })
+	var above, inside []string
+	next := d.node.Start.Row
+	for i := len(docs) - 1; i >= 0; i-- {
+		c := docs[i]
+		text := string(src[c.StartByte:c.EndByte])
+		if c.StartByte >= d.node.StartByte {
+			inside = append([]string{text}, inside...)
+			continue
+		}
+		end := c.End.Row
+		if c.End.Column == 0 && end > c.Start.Row {
+			end-- // the comment node includes its newline
+		}
+		if end < next-1 {
+			break
+		}
+		above = append([]string{text}, above...)
+		next = c.Start.Row
+	}
+	return strings.Join(append(above, inside...), "\n")
+}
+
+// cleanDoc strips comment and docstring markers from each line of a doc.
+func cleanDoc(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	var lines []string
+	for _, l := range strings.Split(strings.ReplaceAll(raw, "\r\n", "\n"), "\n") {
+		l = strings.TrimSpace(l)
+		for _, p := range []string{"/**", "/*!", "/*", "///", "//!", "//", "##", "#", "---", "--[[", "--", ";;", ";", `"""`, "'''", "*"} {
+			if strings.HasPrefix(l, p) && !(p == "*" && strings.HasPrefix(l, "*/")) {
+				l = l[len(p):]
+				break
+			}
+		}
+		for _, s := range []string{"*/", `"""`, "'''", "]]"} {
+			l = strings.TrimSuffix(strings.TrimRight(l, " \t"), s)
+		}
+		lines = append(lines, strings.TrimRight(strings.TrimPrefix(l, " "), " \t"))
+	}
+	for len(lines) > 0 && lines[0] == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
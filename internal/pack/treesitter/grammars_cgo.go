@@ -0,0 +1,15 @@
+//go:build cgo
+
+package treesitter
+
+import (
+	"unsafe"
+
+	golang "github.com/tree-sitter/tree-sitter-go/bindings/go"
+)
+
+// grammars are the compiled-in grammars, by language id. A language needs a
+// grammar here and a query file in queries/.
+var grammars = map[string]func() unsafe.Pointer{
+	"go": golang.Language,
+}
@@ -0,0 +1,84 @@
+; Go: functions, methods, types with their fields and interface methods,
+; constants and variables, with the comments above them; imports with
+; their names.
+
+(
+  (comment)* @doc
+  .
+  (function_declaration
+    name: (identifier) @name
+    parameters: (parameter_list) @signature) @definition.function
+)
+
+(
+  (comment)* @doc
+  .
+  (method_declaration
+    name: (field_identifier) @name
+    parameters: (parameter_list) @signature) @definition.method
+)
+
+; A struct or interface type matches its own pattern and the one of any
+; type; the earlier pattern names its kind.
+(
+  (comment)* @doc
+  .
+  (type_declaration
+    (type_spec
+      name: (type_identifier) @name
+      type: (struct_type)) @definition.struct)
+)
+
+(
+  (comment)* @doc
+  .
+  (type_declaration
+    (type_spec
+      name: (type_identifier) @name
+      type: (interface_type)) @definition.interface)
+)
+
+(
+  (comment)* @doc
+  .
+  (type_declaration
+    [
+      (type_spec name: (type_identifier) @name)
+      (type_alias name: (type_identifier) @name)
+    ] @definition.type)
+)
+
+(
+  (comment)* @doc
+  .
+  (field_declaration
+    name: (field_identifier) @name) @definition.field
+)
+
+(
+  (comment)* @doc
+  .
+  (method_elem
+    name: (field_identifier) @name
+    parameters: (parameter_list) @signature) @definition.method
+)
+
+(
+  (comment)* @doc
+  .
+  (const_declaration
+    (const_spec
+      name: (identifier) @name) @definition.constant)
+)
+
+(
+  (comment)* @doc
+  .
+  (var_declaration
+    (var_spec
+      name: (identifier) @name) @definition.variable)
+)
+
+(import_spec
+  name: (_)? @import.alias
+  path: (_) @import)
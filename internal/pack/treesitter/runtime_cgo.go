@@ -0,0 +1,111 @@
+//go:build cgo
+
+package treesitter
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"unsafe"
+
+	sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+func newRuntime() Runtime { return &cgoRuntime{queries: map[string]*sitter.Query{}} }
+
+// cgoRuntime runs the tree-sitter C library through its Go binding, with
+// the grammars of grammars_cgo.go.
+type cgoRuntime struct {
+	mu      sync.Mutex
+	queries map[string]*sitter.Query // compiled, by language and text
+}
+
+func (r *cgoRuntime) Supports(language string) bool {
+	_, ok := grammars[language]
+	return ok
+}
+
+func (r *cgoRuntime) language(id string) (*sitter.Language, error) {
+	grammar, ok := grammars[id]
+	if !ok {
+		return nil, fmt.Errorf("treesitter: no grammar for %s", id)
+	}
+	return sitter.NewLanguage(unsafe.Pointer(grammar())), nil
+}
+
+func (r *cgoRuntime) query(lang *sitter.Language, id, text string) (*sitter.Query, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := id + "\x00" + text
+	if q, ok := r.queries[key]; ok {
+		return q, nil
+	}
+	q, qerr := sitter.NewQuery(lang, text)
+	if qerr != nil {
+		return nil, fmt.Errorf("treesitter: %s query: %s", id, qerr.Error())
+	}
+	r.queries[key] = q
+	return q, nil
+}
+
+func (r *cgoRuntime) Query(ctx context.Context, language string, src []byte, query string) (*Result, error) {
+	lang, err := r.language(language)
+	if err != nil {
+		return nil, err
+	}
+	q, err := r.query(lang, language, query)
+	if err != nil {
+		return nil, err
+	}
+	parser := sitter.NewParser()
+	defer parser.Close()
+	if err := parser.SetLanguage(lang); err != nil {
+		return nil, fmt.Errorf("treesitter: %s: %w", language, err)
+	}
+	tree := parser.Parse(src, nil)
+	if tree == nil {
+		return nil, fmt.Errorf("treesitter: %s: parse failed", language)
+	}
+	defer tree.Close()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	res := &Result{}
+	root := tree.RootNode()
+	if root.HasError() {
+		res.Errors = syntaxErrors(root, nil)
+	}
+	names := q.CaptureNames()
+	qc := sitter.NewQueryCursor()
+	defer qc.Close()
+	matches := qc.Matches(q, root, src)
+	for m := matches.Next(); m != nil; m = matches.Next() {
+		match := Match{Pattern: int(m.PatternIndex)}
+		for _, c := range m.Captures {
+			start, end := c.Node.StartPosition(), c.Node.EndPosition()
+			match.Captures = append(match.Captures, Capture{
+				Name:      names[c.Index],
+				StartByte: int(c.Node.StartByte()), EndByte: int(c.Node.EndByte()),
+				Start: Point{int(start.Row), int(start.Column)},
+				End:   Point{int(end.Row), int(end.Column)},
+			})
+		}
+		res.Matches = append(res.Matches, match)
+	}
+	return res, nil
+}
+
+// syntaxErrors appends the positions of the error and missing nodes under n.
+func syntaxErrors(n *sitter.Node, out []Point) []Point {
+	if n.IsError() || n.IsMissing() {
+		p := n.StartPosition()
+		return append(out, Point{int(p.Row), int(p.Column)})
+	}
+	for i := uint(0); i < n.ChildCount(); i++ {
+		if c := n.Child(i); c != nil && c.HasError() {
+			out = syntaxErrors(c, out)
+		}
+	}
+	return out
+}
@@ -0,0 +1,126 @@
+//go:build cgo
+
+package treesitter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/ChaseHampton/cargoworker/internal/pack"
+)
+
+const goSource = `package store
+
+import (
+	"context"
+	js "encoding/json"
+)
+
+// Limit bounds a page.
+const Limit = 50
+
+// Store keeps records.
+type Store struct {
+	// Path is where records live.
+	Path string
+}
+
+type Getter interface {
+	Get(ctx context.Context, id string) ([]byte, error)
+}
+
+// Save writes rec.
+func (s *Store) Save(rec any) error {
+	_, err := js.Marshal(rec)
+	return err
+}
+
+// Open opens a store.
+func Open(path string) *Store { return &Store{Path: path} }
+
+func broken( {
+`
+
+// TestGrammars runs every query file against its grammar.
+func TestGrammars(t *testing.T) {
+	langs, err := languages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range langs {
+		if !rt.Supports(l.ID) {
+			t.Errorf("%s.scm has no grammar", l.ID)
+			continue
+		}
+		if pack.For(l.ID) == nil {
+			t.Errorf("%s is not registered", l.ID)
+		}
+		if _, err := rt.Query(context.Background(), l.ID, nil, l.Query); err != nil {
+			t.Errorf("%s.scm: %v", l.ID, err)
+		}
+	}
+}
+
+func TestExtractGo(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "store"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for rel, src := range map[string]string{"store/store.go": goSource, "store/go.mod": "module example.com/store\n"} {
+		if err := os.WriteFile(filepath.Join(root, filepath.FromSlash(rel)), []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	u := pack.Unit{Root: root, Dir: "store", Language: "go", Files: []string{"store/go.mod", "store/store.go"}}
+	f, err := pack.For("go").Extract(context.Background(), u)
+	if err != nil {
+		t.Fatalf("extract: %v", err)
+	}
+
+	if len(f.Containers) != 1 || f.Containers[0].FullName != "example.com/store" || f.Containers[0].Kind != "package" || len(f.Files) != 1 || f.Files[0].Path != "store/store.go" {
+		t.Fatalf("containers %+v, files %+v", f.Containers, f.Files)
+	}
+	var got []string
+	for _, s := range f.Symbols {
+		got = append(got, s.Kind+" "+s.FullName+" "+strings.ReplaceAll(s.DocFmt, "\n", "|"))
+	}
+	want := []string{
+		"constant example.com/store.Limit Limit bounds a page.",
+		"struct example.com/store.Store Store keeps records.",
+		"field example.com/store.Store.Path Path is where records live.",
+		"interface example.com/store.Getter ",
+		"method example.com/store.Getter.Get ",
+		"method example.com/store.Save Save writes rec.",
+		"function example.com/store.Open Open opens a store.",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("symbols =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if len(f.Members) != 2 {
+		t.Errorf("members = %+v", f.Members)
+	}
+	sigs := map[string]bool{}
+	for _, s := range f.Signatures {
+		sigs[s.Text] = true
+	}
+	for _, s := range []string{"(rec any)", "(path string)", "(ctx context.Context, id string)"} {
+		if !sigs[s] {
+			t.Errorf("no signature %s in %v", s, sigs)
+		}
+	}
+	var imports []string
+	for _, im := range f.Imports {
+		imports = append(imports, im.Target+" "+im.Alias)
+	}
+	sort.Strings(imports)
+	if strings.Join(imports, ",") != "context ,encoding/json js" {
+		t.Errorf("imports = %q", imports)
+	}
+	if len(f.Diagnostics) != 1 || f.Diagnostics[0].Code != "syntax" {
+		t.Errorf("diagnostics = %+v", f.Diagnostics)
+	}
+}
@@ -0,0 +1,7 @@
+//go:build !cgo
+
+package treesitter
+
+// newRuntime reports no runtime: the tree-sitter runtime and grammars are C
+// libraries, compiled in only with cgo.
+func newRuntime() Runtime { return nil }
@@ -0,0 +1,230 @@
+// Package treesitter is a language pack for any language with a tree-sitter
+// grammar. What it extracts is spelled out per language in a query file,
+// queries/<language id>.scm, so basic support for a language is a query file
+// rather than Go code.
+//
+// Query files use the capture names of tree-sitter tags queries:
+//
+//	@definition.<kind>  the node of a definition; <kind> is the symbol kind
+//	@name               the defined name, within a definition pattern
+//	@doc                comments or a docstring documenting the definition
+//	@signature          optional: a node whose text is the signature
+//	@import             an imported module, package or file; quotes dropped
+//	@import.alias       optional: the local name of an import
+//
+// A definition inside another is its member and named after it: method Save
+// of class Store in directory app is app.Store.Save. Go packages are named
+// by import path instead, so that Go imports resolve to them.
+//
+// Parsing needs the tree-sitter runtime and grammars, C libraries compiled
+// in with cgo. A language is registered when it has both a query file and a
+// grammar in grammars_cgo.go; built without cgo, the pack registers none.
+package treesitter
+
+import (
+	"bufio"
+	"context"
+	"embed"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ChaseHampton/cargoworker/internal/ir"
+	"github.com/ChaseHampton/cargoworker/internal/pack"
+)
+
+//go:embed queries/*.scm
+var queryFS embed.FS
+
+// Point is a position in a source: 0-based row and byte column.
+type Point struct {
+	Row, Column int
+}
+
+type Capture struct {
+	Name               string // without the @
+	StartByte, EndByte int
+	Start, End         Point
+}
+
+// Match is one match of a query pattern.
+type Match struct {
+	Pattern  int // index of the pattern in the query, by position
+	Captures []Capture
+}
+
+type Result struct {
+	Matches []Match // in order of position
+	// Errors are where the parser recovered from syntax errors.
+	Errors []Point
+}
+
+// Runtime parses sources and runs queries over their syntax trees.
+type Runtime interface {
+	// Supports reports whether a grammar for a language id is compiled in.
+	Supports(language string) bool
+	// Query parses src as language and runs query over its tree. Compiled
+	// queries may be cached by text.
+	Query(ctx context.Context, language string, src []byte, query string) (*Result, error)
+}
+
+// rt is the compiled-in runtime; nil while none is.
+var rt Runtime = newRuntime()
+
+// Language configures extraction for one language. Only ID and Query are
+// required.
+type Language struct {
+	ID    string // language id, as in the language table
+	Query string // contents of the query file
+	// Container maps a directory, relative to the unit root, to the
+	// container the symbols of its files belong to. By default that is the
+	// directory itself, named by its path.
+	Container func(root, dir string) Container
+	// Separator joins container and symbol names into full names. Defaults
+	// to ".".
+	Separator string
+	// Source, if set, reports whether a file of the language is a source to
+	// parse. By default every file is.
+	Source func(rel string) bool
+}
+
+// Container names a container of extracted symbols.
+type Container struct {
+	Name, FullName, Kind string
+}
+
+// dirContainer is the default container: the directory itself.
+func dirContainer(_, dir string) Container {
+	return Container{Name: path.Base(dir), FullName: dir, Kind: "directory"}
+}
+
+// languages are the languages with a query file, configured by options.
+func languages() ([]Language, error) {
+	entries, err := queryFS.ReadDir("queries")
+	if err != nil {
+		return nil, err
+	}
+	var out []Language
+	for _, e := range entries {
+		b, err := queryFS.ReadFile("queries/" + e.Name())
+		if err != nil {
+			return nil, err
+		}
+		l := options[strings.TrimSuffix(e.Name(), ".scm")]
+		l.ID, l.Query = strings.TrimSuffix(e.Name(), ".scm"), string(b)
+		out = append(out, l)
+	}
+	return out, nil
+}
+
+// options holds the settings of languages that need more than a query file.
+var options = map[string]Language{
+	// The language table gives go.mod and go.sum the go language too.
+	"go": {
+		Source:    func(rel string) bool { return path.Ext(rel) == ".go" },
+		Container: goPackage,
+	},
+}
+
+// goPackage names the package of a Go file by its import path: the path of
+// the module whose go.mod is nearest above the file, joined with the file's
+// directory below it. Without a go.mod in the root the directory path stands
+// in.
+func goPackage(root, dir string) Container {
+	c := Container{Name: path.Base(dir), FullName: dir, Kind: "package"}
+	for mod := dir; ; mod = path.Dir(mod) {
+		if mp := modulePath(filepath.Join(root, filepath.FromSlash(mod), "go.mod")); mp != "" {
+			switch {
+			case mod == dir:
+				c.FullName = mp
+			case mod == ".":
+				c.FullName = mp + "/" + dir
+			default:
+				c.FullName = mp + "/" + dir[len(mod)+1:]
+			}
+			c.Name = path.Base(c.FullName)
+			return c
+		}
+		if mod == "." {
+			return c
+		}
+	}
+}
+
+// modulePath reads the module path from a go.mod file; it is empty when the
+// file is missing or declares none.
+func modulePath(gomod string) string {
+	f, err := os.Open(gomod)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		rest, ok := strings.CutPrefix(line, "module")
+		if !ok || rest == "" || (rest[0] != ' ' && rest[0] != '\t' && rest[0] != '"') {
+			continue
+		}
+		mp := strings.TrimSpace(rest)
+		if u, err := strconv.Unquote(mp); err == nil {
+			mp = u
+		}
+		return mp
+	}
+	return ""
+}
+
+func init() {
+	if rt == nil {
+		return
+	}
+	langs, err := languages()
+	if err != nil {
+		panic(err) // the embedded files are fixed at build time
+	}
+	for _, l := range langs {
+		if rt.Supports(l.ID) {
+			pack.Register(l.ID, New(rt, l))
+		}
+	}
+}
+
+// Pack extracts one language with a runtime.
+type Pack struct {
+	rt   Runtime
+	lang Language
+}
+
+func New(rt Runtime, lang Language) *Pack {
+	if lang.Container == nil {
+		lang.Container = dirContainer
+	}
+	if lang.Separator == "" {
+		lang.Separator = "."
+	}
+	return &Pack{rt: rt, lang: lang}
+}
+
+func (p *Pack) Name() string { return "treesitter/" + p.lang.ID }
+
+var _ pack.Pack = (*Pack)(nil)
+
+// Extract parses every file of the unit.
+func (p *Pack) Extract(ctx context.Context, u pack.Unit) (*ir.Fragment, error) {
+	x := newExtraction(p, u)
+	for _, rel := range u.Files {
+		if p.lang.Source != nil && !p.lang.Source(rel) {
+			continue
+		}
+		if err := x.file(ctx, rel); err != nil {
+			return nil, err
+		}
+	}
+	return x.frag, nil
+}
@@ -0,0 +1,185 @@
+package treesitter
+
+import (
+	"context"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ChaseHampton/cargoworker/internal/pack"
+)
+
+const source = `require "json"
+
+# Store keeps records.
+# Second line.
+class Store
+
+  # Save writes.
+  def save(rec)
+  end
+end
+
+# detached
+
+def helper
+end
+`
+
+// fakeRuntime answers every query with fixed matches.
+type fakeRuntime struct{ res *Result }
+
+func (f fakeRuntime) Supports(string) bool { return true }
+
+func (f fakeRuntime) Query(context.Context, string, []byte, string) (*Result, error) {
+	return f.res, nil
+}
+
+// capture captures the n-th occurrence (from 0) of text in source.
+func capture(t *testing.T, name, text string, n int) Capture {
+	t.Helper()
+	off := -1
+	for i := 0; i <= n; i++ {
+		j := strings.Index(source[off+1:], text)
+		if j < 0 {
+			t.Fatalf("%q not found", text)
+		}
+		off += j + 1
+	}
+	point := func(b int) Point {
+		return Point{strings.Count(source[:b], "\n"), b - strings.LastIndex(source[:b], "\n") - 1}
+	}
+	return Capture{Name: name, StartByte: off, EndByte: off + len(text), Start: point(off), End: point(off + len(text))}
+}
+
+func TestExtract(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "app"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "app", "store.rb"), []byte(source), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	class := source[strings.Index(source, "class Store") : strings.Index(source, "end\nend")+len("end\nend")]
+	method := "def save(rec)\n  end"
+	helper := "def helper\nend"
+	res := &Result{
+		Matches: []Match{
+			{Pattern: 3, Captures: []Capture{capture(t, "_require", "require", 0), capture(t, "import", "json", 0)}},
+			{Pattern: 0, Captures: []Capture{
+				capture(t, "doc", "# Store keeps records.", 0), capture(t, "doc", "# Second line.", 0),
+				capture(t, "definition.class", class, 0), capture(t, "name", "Store", 1),
+			}},
+			// The same class again from a later pattern adds nothing.
+			{Pattern: 2, Captures: []Capture{capture(t, "definition.constant", class, 0), capture(t, "name", "Store", 1)}},
+			{Pattern: 1, Captures: []Capture{
+				capture(t, "doc", "# Save writes.", 0), capture(t, "definition.function", method, 0),
+				capture(t, "name", "save", 0), capture(t, "signature", "(rec)", 0),
+			}},
+			{Pattern: 1, Captures: []Capture{
+				capture(t, "doc", "# detached", 0), capture(t, "definition.function", helper, 0), capture(t, "name", "helper", 0),
+			}},
+		},
+		Errors: []Point{{Row: 4, Column: 2}},
+	}
+	p := New(fakeRuntime{res}, Language{ID: "ruby"})
+	f, err := p.Extract(context.Background(), pack.Unit{Root: root, Dir: "app", Language: "ruby", Files: []string{"app/store.rb"}})
+	if err != nil {
+		t.Fatalf("extract: %v", err)
+	}
+
+	if len(f.Containers) != 1 || f.Containers[0].FullName != "app" || len(f.Files) != 1 || f.Files[0].SizeBytes != int64(len(source)) {
+		t.Fatalf("containers %+v, files %+v", f.Containers, f.Files)
+	}
+	want := []struct{ full, kind, doc string }{
+		{"app.Store", "class", "Store keeps records.\nSecond line."},
+		{"app.Store.save", "method", "Save writes."},
+		{"app.helper", "function", ""},
+	}
+	if len(f.Symbols) != len(want) {
+		t.Fatalf("symbols = %+v", f.Symbols)
+	}
+	for i, w := range want {
+		s := f.Symbols[i]
+		if s.FullName != w.full || s.Kind != w.kind || s.DocFmt != w.doc {
+			t.Errorf("symbol %d = %s %s %q, want %s %s %q", i, s.FullName, s.Kind, s.DocFmt, w.full, w.kind, w.doc)
+		}
+	}
+	if s := f.Symbols[0]; s.StartLine != 5 || s.StartCol != 7 || s.EndLine != 10 || s.EndCol != 4 {
+		t.Errorf("class span = %d:%d-%d:%d", s.StartLine, s.StartCol, s.EndLine, s.EndCol)
+	}
+	if len(f.Members) != 1 || f.Members[0].OwnerSymbolId != f.Symbols[0].Id || f.Members[0].ChildSymbolId != f.Symbols[1].Id {
+		t.Errorf("members = %+v", f.Members)
+	}
+	if len(f.Signatures) != 1 || f.Signatures[0].Text != "(rec)" {
+		t.Errorf("signatures = %+v", f.Signatures)
+	}
+	if len(f.Imports) != 1 || f.Imports[0].Target != "json" || !strings.Contains(f.Imports[0].DetailsJson, `"line":1`) {
+		t.Errorf("imports = %+v", f.Imports)
+	}
+	if len(f.Diagnostics) != 1 || f.Diagnostics[0].Line != 5 {
+		t.Errorf("diagnostics = %+v", f.Diagnostics)
+	}
+}
+
+func TestCleanDoc(t *testing.T) {
+	for raw, want := range map[string]string{
+		"/**\n * Adds.\n *\n * Twice.\n */": "Adds.\n\nTwice.",
+		"/// Line one.\n/// Line two.":      "Line one.\nLine two.",
+		`"""Docstring."""`:                  "Docstring.",
+		"# a\n#   indented":                 "a\n  indented",
+		"--[[ Lua block ]]":                 "Lua block",
+	} {
+		if got := cleanDoc(raw); got != want {
+			t.Errorf("cleanDoc(%q) = %q, want %q", raw, got, want)
+		}
+	}
+}
+
+func TestQueryFiles(t *testing.T) {
+	langs, err := languages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range langs {
+		if !strings.Contains(l.Query, "@definition.") || !strings.Contains(l.Query, "@name") {
+			t.Errorf("%s.scm defines nothing", l.ID)
+		}
+		if strings.Count(l.Query, "(") != strings.Count(l.Query, ")") {
+			t.Errorf("%s.scm has unbalanced parentheses", l.ID)
+		}
+	}
+}
+
+// Go packages are named by import path, the name Go imports use.
+func TestGoPackage(t *testing.T) {
+	root := t.TempDir()
+	for rel, src := range map[string]string{
+		"go.mod":           "// the app\nmodule example.com/app // trailing\n\ngo 1.25\n",
+		"tools/go.mod":     "module \"example.com/tools\"\n",
+		"internal/db/x.go": "package db\n",
+	} {
+		if err := os.MkdirAll(filepath.Join(root, filepath.Dir(rel)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(root, rel), []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for dir, want := range map[string]string{
+		".":           "example.com/app",
+		"internal/db": "example.com/app/internal/db",
+		"tools":       "example.com/tools",
+		"tools/gen":   "example.com/tools/gen",
+	} {
+		c := goPackage(root, dir)
+		if c.FullName != want || c.Name != path.Base(want) || c.Kind != "package" {
+			t.Errorf("goPackage(%q) = %+v, want %s", dir, c, want)
+		}
+	}
+	if c := goPackage(t.TempDir(), "cmd/tool"); c.FullName != "cmd/tool" {
+		t.Errorf("without go.mod: %+v", c)
+	}
+}
@@ -0,0 +1,123 @@
+package typescript
+
+import (
+	"strings"
+
+	"github.com/ChaseHampton/cargoworker/internal/pack/outline"
+)
+
+// builtins are the types of the language and its standard library, which
+// type references leave out, and the keywords of type expressions.
+var builtins = outline.Set(`
+	string number boolean bigint symbol object undefined null void never unknown any this true false
+	typeof keyof infer extends is asserts readonly unique in out as satisfies new const
+	Object Function String Number Boolean Symbol BigInt Array ReadonlyArray Date RegExp Error TypeError
+	RangeError SyntaxError Promise PromiseLike Map Set WeakMap WeakSet ReadonlyMap ReadonlySet Iterable
+	Iterator IterableIterator AsyncIterable AsyncIterator AsyncIterableIterator Generator AsyncGenerator
+	ArrayLike ArrayBuffer SharedArrayBuffer DataView Uint8Array Int8Array Uint16Array Int16Array
+	Uint32Array Int32Array Float32Array Float64Array BigInt64Array BigUint64Array Uint8ClampedArray
+	JSON Math Record Partial Required Readonly Pick Omit Exclude Extract NonNullable Parameters
+	ConstructorParameters ReturnType InstanceType ThisType Awaited Uppercase Lowercase Capitalize
+	Uncapitalize NoInfer ThisParameterType OmitThisParameter TemplateStringsArray PropertyKey
+	globalThis`)
+
+// nodeBuiltins are the modules built into Node.js, importable with or
+// without the node: prefix.
+var nodeBuiltins = outline.Set(`
+	assert async_hooks buffer child_process cluster console constants crypto dgram diagnostics_channel
+	dns domain events fs http http2 https inspector module net os path perf_hooks process punycode
+	querystring readline repl stream string_decoder sys timers tls trace_events tty url util v8 vm
+	wasi worker_threads zlib test sqlite`)
+
+// isStdlib reports whether a module specifier names a Node.js builtin.
+func isStdlib(spec string) bool {
+	if strings.HasPrefix(spec, "node:") {
+		return true
+	}
+	top, _, _ := strings.Cut(spec, "/")
+	return nodeBuiltins[top]
+}
+
+// ref is a name a type refers to: qualified when it resolves, else as
+// written.
+type ref struct {
+	Symbol string `json:"symbol,omitempty"`
+	Name   string `json:"name,omitempty"`
+	Type   string `json:"type,omitempty"`
+	Text   string `json:"text,omitempty"` // the whole type
+}
+
+// resolver resolves the names of a module to qualified names.
+type resolver struct {
+	module  string
+	target  func(spec string) string // the module a specifier names
+	imports map[string]string        // local name -> qualified name; modules for namespace imports
+	defs    map[string]bool          // top-level declarations
+}
+
+// refs returns the names a type refers to, in order, leaving out builtins
+// and the type parameters in scope.
+func (r *resolver) refs(typ string, params map[string]bool) []ref {
+	var out []ref
+	seen := map[string]bool{}
+	toks := lex(typ, false)
+	for i := 0; i < len(toks); i++ {
+		t := toks[i]
+		if t.Kind != outline.Ident || i > 0 && (toks[i-1].Is(".") || toks[i-1].Is("?.")) {
+			continue
+		}
+		if t.Is("import") && i+5 < len(toks) && toks[i+1].Is("(") && toks[i+2].Kind == outline.String && toks[i+3].Is(")") &&
+			toks[i+4].Is(".") && toks[i+5].Kind == outline.Ident {
+			// An import type: import("./mod").Name.
+			q := r.target(unquote(toks[i+2].Text))
+			for i += 4; i+1 < len(toks) && toks[i].Is(".") && toks[i+1].Kind == outline.Ident; i += 2 {
+				q += "." + toks[i+1].Text
+			}
+			if !seen[q] {
+				seen[q] = true
+				out = append(out, ref{Symbol: q, Text: typ})
+			}
+			i--
+			continue
+		}
+		// Keys of object types and names of function type parameters.
+		if i+1 < len(toks) && (toks[i+1].Is(":") || toks[i+1].Is("?") && i+2 < len(toks) && toks[i+2].Is(":")) {
+			continue
+		}
+		name := t.Text
+		for i+2 < len(toks) && toks[i+1].Is(".") && toks[i+2].Kind == outline.Ident {
+			name += "." + toks[i+2].Text
+			i += 2
+		}
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		first, _, _ := strings.Cut(name, ".")
+		if params[first] {
+			continue
+		}
+		if q, ok := r.resolve(name); ok {
+			q.Text = typ
+			out = append(out, q)
+		}
+	}
+	return out
+}
+
+func (r *resolver) resolve(name string) (ref, bool) {
+	first, rest, dotted := strings.Cut(name, ".")
+	switch {
+	case r.imports[first] != "":
+		q := r.imports[first]
+		if dotted {
+			q += "." + rest
+		}
+		return ref{Symbol: q}, true
+	case r.defs[first]:
+		return ref{Symbol: r.module + "." + name}, true
+	case builtins[first]:
+		return ref{}, false
+	}
+	return ref{Name: name}, true
+}
@@ -0,0 +1,219 @@
+package typescript
+
+import (
+	"strings"
+)
+
+// jsdoc is a parsed JSDoc or TSDoc comment: its description and the block
+// tags that describe the symbol.
+type jsdoc struct {
+	Summary    string     `json:"summary,omitempty"`
+	Remarks    string     `json:"remarks,omitempty"`
+	Params     []docParam `json:"params,omitempty"`
+	TypeParams []docParam `json:"type_params,omitempty"`
+	Properties []docParam `json:"properties,omitempty"`
+	Returns    *docType   `json:"returns,omitempty"`
+	Throws     []docType  `json:"throws,omitempty"`
+	Type       string     `json:"type,omitempty"`
+	Default    string     `json:"default,omitempty"`
+	Deprecated *string    `json:"deprecated,omitempty"` // set, maybe empty, when deprecated
+	Since      string     `json:"since,omitempty"`
+	Examples   []string   `json:"examples,omitempty"`
+	See        []string   `json:"see,omitempty"`
+	// Release is the TSDoc release tag: public, beta, alpha, internal or
+	// experimental.
+	Release string   `json:"release,omitempty"`
+	Tags    []docTag `json:"tags,omitempty"` // the others, in order
+}
+
+type docParam struct {
+	Name     string `json:"name"`
+	Type     string `json:"type,omitempty"`
+	Desc     string `json:"desc,omitempty"`
+	Optional bool   `json:"optional,omitempty"`
+	Default  string `json:"default,omitempty"`
+}
+
+type docType struct {
+	Type string `json:"type,omitempty"`
+	Desc string `json:"desc,omitempty"`
+}
+
+type docTag struct {
+	Tag  string `json:"tag"`
+	Text string `json:"text,omitempty"`
+}
+
+// cleanComment strips the comment markers of a JSDoc comment and the
+// leading asterisks of its lines.
+func cleanComment(raw string) string {
+	s := strings.TrimSuffix(strings.TrimPrefix(raw, "/**"), "*/")
+	lines := strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+	for i, l := range lines {
+		t := strings.TrimLeft(l, " \t")
+		if strings.HasPrefix(t, "*") {
+			t = strings.TrimPrefix(t[1:], " ")
+		} else if i > 0 {
+			t = l // a line without the asterisk keeps its indentation
+		}
+		lines[i] = strings.TrimRight(t, " \t")
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// parseJSDoc splits a cleaned comment into its description and block tags.
+// Lines starting with @ outside code fences begin tags.
+func parseJSDoc(text string) *jsdoc {
+	d := &jsdoc{}
+	var desc []string
+	var tag string
+	var body []string
+	fenced := false
+	flush := func() {
+		if tag != "" {
+			d.tag(tag, strings.TrimRight(strings.Join(body, "\n"), "\n "))
+		}
+		tag, body = "", nil
+	}
+	for _, l := range strings.Split(text, "\n") {
+		t := strings.TrimSpace(l)
+		if strings.HasPrefix(t, "```") {
+			fenced = !fenced
+		}
+		if !fenced && strings.HasPrefix(t, "@") && len(t) > 1 && isIdentStart(t[1]) {
+			flush()
+			name, rest, _ := strings.Cut(t[1:], " ")
+			tag, body = name, []string{strings.TrimSpace(rest)}
+			continue
+		}
+		if tag != "" {
+			body = append(body, l)
+		} else {
+			desc = append(desc, l)
+		}
+	}
+	flush()
+	d.Summary = strings.TrimSpace(strings.Join(desc, "\n"))
+	return d
+}
+
+// tag records one block tag.
+func (d *jsdoc) tag(name, text string) {
+	oneLine := strings.Join(strings.Fields(text), " ")
+	switch name {
+	case "param", "arg", "argument":
+		d.Params = append(d.Params, paramTag(oneLine))
+	case "typeParam", "template":
+		p := paramTag(oneLine)
+		if p.Type != "" && p.Name == "" {
+			p.Name, p.Type = p.Type, ""
+		}
+		for _, n := range strings.Split(p.Name, ",") {
+			q := p
+			q.Name = strings.TrimSpace(n)
+			d.TypeParams = append(d.TypeParams, q)
+		}
+	case "property", "prop":
+		d.Properties = append(d.Properties, paramTag(oneLine))
+	case "returns", "return":
+		typ, desc := braced(oneLine)
+		d.Returns = &docType{Type: typ, Desc: desc}
+	case "throws", "exception":
+		typ, desc := braced(oneLine)
+		d.Throws = append(d.Throws, docType{Type: typ, Desc: desc})
+	case "type":
+		d.Type, _ = braced(oneLine)
+	case "default", "defaultValue":
+		d.Default = oneLine
+	case "deprecated":
+		d.Deprecated = &oneLine
+	case "since":
+		d.Since = oneLine
+	case "example":
+		d.Examples = append(d.Examples, strings.TrimSpace(dedent(text)))
+	case "see":
+		d.See = append(d.See, oneLine)
+	case "remarks":
+		d.Remarks = strings.TrimSpace(dedent(text))
+	case "public", "beta", "alpha", "internal", "experimental":
+		d.Release = name
+	default:
+		d.Tags = append(d.Tags, docTag{Tag: name, Text: oneLine})
+	}
+}
+
+// paramTag parses "{type} name description", where the name may be
+// [name=default] for an optional parameter and the description may follow
+// a hyphen.
+func paramTag(s string) docParam {
+	var p docParam
+	p.Type, s = braced(s)
+	if strings.HasPrefix(s, "[") {
+		end := strings.IndexByte(s, ']')
+		if end < 0 {
+			end = len(s) - 1
+		}
+		inner := s[1:end]
+		s = strings.TrimSpace(s[min(end+1, len(s)):])
+		p.Optional = true
+		if name, def, ok := strings.Cut(inner, "="); ok {
+			p.Name, p.Default = strings.TrimSpace(name), strings.TrimSpace(def)
+		} else {
+			p.Name = strings.TrimSpace(inner)
+		}
+	} else {
+		p.Name, s, _ = strings.Cut(s, " ")
+	}
+	p.Desc = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(s), "- "))
+	if p.Desc == "-" {
+		p.Desc = ""
+	}
+	return p
+}
+
+// braced splits a leading {type} off s.
+func braced(s string) (typ, rest string) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "{") {
+		return "", s
+	}
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return strings.TrimSpace(s[1:i]), strings.TrimSpace(s[i+1:])
+			}
+		}
+	}
+	return "", s
+}
+
+// dedent removes the common indentation of the non-blank lines after the
+// first, which follows the tag.
+func dedent(s string) string {
+	lines := strings.Split(s, "\n")
+	margin := -1
+	for _, l := range lines[1:] {
+		if t := strings.TrimLeft(l, " \t"); t != "" {
+			if n := len(l) - len(t); margin < 0 || n < margin {
+				margin = n
+			}
+		}
+	}
+	for i := 1; i < len(lines); i++ {
+		if margin > 0 && len(lines[i]) >= margin {
+			lines[i] = lines[i][margin:]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
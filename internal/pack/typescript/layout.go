@@ -0,0 +1,472 @@
+package typescript
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// project is a directory with a package.json.
+type project struct {
+	dir         string // relative to the input root
+	name        string
+	version     string
+	description string
+	exports     json.RawMessage // as written
+	// entries are the source files of the public entry points, and
+	// prefixes the source directories exported by subpath patterns.
+	entries  []string
+	prefixes []string
+	// public maps the modules reachable from the entry points to the names
+	// they export publicly; "*" stands for all of them.
+	public map[string]map[string]bool
+}
+
+// layout names the modules of an input root after the packages they belong
+// to, and finds which of their exports are public.
+type layout struct {
+	root     string
+	mu       sync.Mutex
+	projects map[string]*project // by directory; nil where there is none
+	parsed   map[string]*module  // outlines read while walking re-exports
+}
+
+func newLayout(root string) *layout {
+	return &layout{root: root, projects: map[string]*project{}, parsed: map[string]*module{}}
+}
+
+// sourceExts are the extensions of sources, in the order a module specifier
+// without one is resolved.
+var sourceExts = []string{".ts", ".tsx", ".d.ts", ".js", ".jsx", ".mjs", ".cjs", ".mts", ".cts", ".d.mts", ".d.cts"}
+
+// stem returns a path without its source extension, .d.ts included.
+func stem(rel string) string {
+	for _, ext := range []string{".d.ts", ".d.mts", ".d.cts"} {
+		if strings.HasSuffix(rel, ext) {
+			return strings.TrimSuffix(rel, ext)
+		}
+	}
+	return strings.TrimSuffix(rel, path.Ext(rel))
+}
+
+// moduleName returns the name of the module of a file: its path without the
+// extension, relative to its package and prefixed with the package name.
+func (l *layout) moduleName(rel string) (string, *project) {
+	proj := l.project(path.Dir(rel))
+	s := stem(rel)
+	if proj == nil || proj.name == "" {
+		return s, proj
+	}
+	if proj.dir != "." {
+		s = strings.TrimPrefix(s, proj.dir+"/")
+	}
+	return proj.name + "/" + s, proj
+}
+
+func (l *layout) exists(rel string) bool {
+	fi, err := os.Stat(filepath.Join(l.root, filepath.FromSlash(rel)))
+	return err == nil && !fi.IsDir()
+}
+
+// resolve returns the file a relative module specifier in the file from
+// refers to, or "" if there is none.
+func (l *layout) resolve(from, spec string) string {
+	p := path.Join(path.Dir(from), spec)
+	if strings.HasPrefix(p, "../") || p == ".." {
+		return ""
+	}
+	var cands []string
+	if ext := path.Ext(p); ext != "" {
+		// TypeScript sources import each other by their output names.
+		base := strings.TrimSuffix(p, ext)
+		switch ext {
+		case ".js", ".jsx":
+			cands = append(cands, base+".ts", base+".tsx", base+".d.ts")
+		case ".mjs":
+			cands = append(cands, base+".mts", base+".d.mts")
+		case ".cjs":
+			cands = append(cands, base+".cts", base+".d.cts")
+		}
+		cands = append(cands, p)
+	}
+	for _, ext := range sourceExts {
+		cands = append(cands, p+ext)
+	}
+	for _, ext := range sourceExts {
+		cands = append(cands, p+"/index"+ext)
+	}
+	for _, c := range cands {
+		if l.exists(c) {
+			return c
+		}
+	}
+	return ""
+}
+
+// project returns the nearest project at or above dir, or nil.
+func (l *layout) project(dir string) *project {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.lookup(dir)
+}
+
+func (l *layout) lookup(dir string) *project {
+	if p, seen := l.projects[dir]; seen {
+		return p
+	}
+	var p *project
+	if path.Base(dir) != "node_modules" {
+		p = l.read(dir)
+	}
+	if p == nil && dir != "." {
+		p = l.lookup(path.Dir(dir))
+	}
+	l.projects[dir] = p
+	if p != nil && p.dir == dir {
+		l.entryPoints(p)
+	}
+	return p
+}
+
+// read parses dir/package.json. A missing or malformed file is no project.
+func (l *layout) read(dir string) *project {
+	b, err := os.ReadFile(filepath.Join(l.root, filepath.FromSlash(dir), "package.json"))
+	if err != nil {
+		return nil
+	}
+	var doc struct {
+		Name        string          `json:"name"`
+		Version     string          `json:"version"`
+		Description string          `json:"description"`
+		Exports     json.RawMessage `json:"exports"`
+		Main        string          `json:"main"`
+		Module      string          `json:"module"`
+		Types       string          `json:"types"`
+		Typings     string          `json:"typings"`
+		Browser     any             `json:"browser"`
+	}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil
+	}
+	p := &project{dir: dir, name: doc.Name, version: doc.Version, description: doc.Description, exports: doc.Exports}
+	var targets []string
+	if len(doc.Exports) > 0 && string(doc.Exports) != "null" {
+		var v any
+		if json.Unmarshal(doc.Exports, &v) == nil {
+			targets = exportTargets(v)
+		}
+	}
+	if len(targets) == 0 {
+		for _, t := range []string{doc.Types, doc.Typings, doc.Module, doc.Main} {
+			if t != "" {
+				targets = append(targets, t)
+			}
+		}
+	}
+	if len(targets) == 0 {
+		targets = []string{"index", "src/index"}
+	}
+	outDir, rootDir := l.tsconfig(dir)
+	for _, t := range targets {
+		if before, _, ok := strings.Cut(t, "*"); ok {
+			if src := l.sourceDir(path.Join(dir, path.Dir(before+"x")), outDir, rootDir); src != "" {
+				p.prefixes = append(p.prefixes, src)
+			}
+			continue
+		}
+		if src := l.sourceFile(path.Join(dir, t), outDir, rootDir); src != "" && !slices.Contains(p.entries, src) {
+			p.entries = append(p.entries, src)
+		}
+	}
+	return p
+}
+
+// exportTargets returns the files an exports field of a package.json points
+// to, under every condition. Subpath patterns keep their *.
+func exportTargets(v any) []string {
+	var out []string
+	switch v := v.(type) {
+	case string:
+		out = append(out, v)
+	case []any:
+		for _, e := range v {
+			out = append(out, exportTargets(e)...)
+		}
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			out = append(out, exportTargets(v[k])...)
+		}
+	}
+	return out
+}
+
+// outDirs are the usual names of build output directories, mapped back to
+// src when tsconfig.json does not say.
+var outDirs = map[string]bool{"dist": true, "lib": true, "build": true, "out": true, "esm": true, "cjs": true, "types": true}
+
+// sourceFile maps a file a package.json points to, often a build output, to
+// the source it is built from.
+func (l *layout) sourceFile(target, outDir, rootDir string) string {
+	target = path.Clean(target)
+	var bases []string
+	if outDir != "" && strings.HasPrefix(target, outDir+"/") {
+		bases = append(bases, path.Join(rootDir, strings.TrimPrefix(target, outDir+"/")))
+	}
+	if rel, ok := swapOutDir(target); ok {
+		bases = append(bases, rel)
+	}
+	bases = append(bases, target)
+	for _, b := range bases {
+		s := stem(b)
+		for _, ext := range sourceExts {
+			if l.exists(s + ext) {
+				return s + ext
+			}
+		}
+		if l.exists(b) && isSource(b) {
+			return b
+		}
+		for _, ext := range sourceExts {
+			if l.exists(b + "/index" + ext) {
+				return b + "/index" + ext
+			}
+		}
+	}
+	return ""
+}
+
+// sourceDir maps a directory of build outputs to the source directory.
+func (l *layout) sourceDir(dir, outDir, rootDir string) string {
+	dir = path.Clean(dir)
+	var cands []string
+	if outDir != "" && (dir == outDir || strings.HasPrefix(dir, outDir+"/")) {
+		cands = append(cands, path.Join(rootDir, strings.TrimPrefix(strings.TrimPrefix(dir, outDir), "/")))
+	}
+	if rel, ok := swapOutDir(dir + "/x"); ok {
+		cands = append(cands, path.Dir(rel))
+	}
+	cands = append(cands, dir)
+	for _, c := range cands {
+		if fi, err := os.Stat(filepath.Join(l.root, filepath.FromSlash(c))); err == nil && fi.IsDir() {
+			return c
+		}
+	}
+	return ""
+}
+
+// swapOutDir replaces the first conventional output directory of a path
+// with src.
+func swapOutDir(rel string) (string, bool) {
+	parts := strings.Split(rel, "/")
+	for i, p := range parts[:len(parts)-1] {
+		if outDirs[p] {
+			parts[i] = "src"
+			return strings.Join(parts, "/"), true
+		}
+	}
+	return "", false
+}
+
+func isSource(rel string) bool {
+	for _, ext := range sourceExts {
+		if strings.HasSuffix(rel, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// tsconfig returns the outDir and rootDir of dir/tsconfig.json, relative to
+// the input root, or "" where it sets none.
+func (l *layout) tsconfig(dir string) (outDir, rootDir string) {
+	b, err := os.ReadFile(filepath.Join(l.root, filepath.FromSlash(dir), "tsconfig.json"))
+	if err != nil {
+		return "", ""
+	}
+	var doc struct {
+		CompilerOptions struct {
+			OutDir  string `json:"outDir"`
+			RootDir string `json:"rootDir"`
+		} `json:"compilerOptions"`
+	}
+	if json.Unmarshal(jsonc(b), &doc) != nil || doc.CompilerOptions.OutDir == "" {
+		return "", ""
+	}
+	root := doc.CompilerOptions.RootDir
+	if root == "" {
+		root = "."
+	}
+	return path.Join(dir, doc.CompilerOptions.OutDir), path.Join(dir, root)
+}
+
+// jsonc strips the comments and trailing commas tsconfig.json allows.
+func jsonc(b []byte) []byte {
+	out := make([]byte, 0, len(b))
+	for i := 0; i < len(b); i++ {
+		switch c := b[i]; {
+		case c == '"':
+			j := i + 1
+			for ; j < len(b) && b[j] != '"'; j++ {
+				if b[j] == '\\' {
+					j++
+				}
+			}
+			out = append(out, b[i:min(j+1, len(b))]...)
+			i = j
+		case c == '/' && i+1 < len(b) && b[i+1] == '/':
+			for i < len(b) && b[i] != '\n' {
+				i++
+			}
+			i--
+		case c == '/' && i+1 < len(b) && b[i+1] == '*':
+			end := strings.Index(string(b[i+2:]), "*/")
+			if end < 0 {
+				return out
+			}
+			i += end + 3
+		case c == '}' || c == ']':
+			k := len(out) - 1
+			for k >= 0 && (out[k] == ' ' || out[k] == '\t' || out[k] == '\n' || out[k] == '\r') {
+				k--
+			}
+			if k >= 0 && out[k] == ',' {
+				out = append(out[:k], out[k+1:]...)
+			}
+			out = append(out, c)
+		default:
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// entryPoints walks the re-exports from the entry points of a project to
+// find the names each module exports publicly.
+func (l *layout) entryPoints(p *project) {
+	p.public = map[string]map[string]bool{}
+	type item struct {
+		rel  string
+		name string // "*" for every export
+	}
+	var queue []item
+	for _, e := range p.entries {
+		queue = append(queue, item{e, "*"})
+	}
+	for _, pre := range p.prefixes {
+		for _, rel := range l.sources(pre) {
+			queue = append(queue, item{rel, "*"})
+		}
+	}
+	for len(queue) > 0 {
+		it := queue[0]
+		queue = queue[1:]
+		names := p.public[it.rel]
+		if names == nil {
+			names = map[string]bool{}
+			p.public[it.rel] = names
+		}
+		if names[it.name] || names["*"] {
+			continue
+		}
+		names[it.name] = true
+		m := l.outline(it.rel)
+		if m == nil {
+			continue
+		}
+		for _, ex := range m.exports {
+			if ex.from == "" || !strings.HasPrefix(ex.from, ".") {
+				continue
+			}
+			target := l.resolve(it.rel, ex.from)
+			if target == "" {
+				continue
+			}
+			switch {
+			case ex.star && ex.as == "":
+				if it.name == "*" || it.name != "default" {
+					queue = append(queue, item{target, it.name})
+				}
+			case ex.star:
+				if it.name == "*" || it.name == ex.as {
+					queue = append(queue, item{target, "*"})
+				}
+			default:
+				for _, b := range ex.names {
+					if it.name == "*" || it.name == b.local {
+						queue = append(queue, item{target, b.name})
+					}
+				}
+			}
+		}
+	}
+}
+
+// sources returns the source files under a directory.
+func (l *layout) sources(dir string) []string {
+	var out []string
+	_ = filepath.WalkDir(filepath.Join(l.root, filepath.FromSlash(dir)), func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() && d.Name() == "node_modules" {
+			return filepath.SkipDir
+		}
+		if rel, err := filepath.Rel(l.root, p); err == nil && !d.IsDir() && isSource(d.Name()) {
+			out = append(out, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	return out
+}
+
+// outline parses a file for its exports, caching the result.
+func (l *layout) outline(rel string) *module {
+	if m, ok := l.parsed[rel]; ok {
+		return m
+	}
+	var m *module
+	if src, err := os.ReadFile(filepath.Join(l.root, filepath.FromSlash(rel))); err == nil {
+		m = parse(string(src), jsx(rel))
+	}
+	l.parsed[rel] = m
+	return m
+}
+
+// isPublic reports whether a module exports a name from its package.
+// Without a package.json, or entry points found for it, every export is
+// public.
+func (p *project) isPublic(rel string, names ...string) bool {
+	if p == nil || len(p.entries) == 0 && len(p.prefixes) == 0 {
+		return true
+	}
+	pub := p.public[rel]
+	if pub["*"] {
+		return true
+	}
+	for _, n := range names {
+		if pub[n] {
+			return true
+		}
+	}
+	return false
+}
+
+// jsx reports whether a file may contain JSX: .tsx and .jsx files, and .js
+// files by convention. In .ts files <T> is a type assertion.
+func jsx(rel string) bool {
+	switch path.Ext(rel) {
+	case ".tsx", ".jsx", ".js":
+		return true
+	}
+	return false
+}
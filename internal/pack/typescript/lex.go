@@ -0,0 +1,302 @@
+package typescript
+
+import (
+	"strings"
+
+	"github.com/ChaseHampton/cargoworker/internal/pack/outline"
+)
+
+// The kinds of token only JavaScript has.
+const (
+	tTemplate outline.Kind = outline.Other + iota // template literals, substitutions included
+	tRegex
+	tJSX // a whole JSX element
+)
+
+// token is a token. Doc is the JSDoc comment right before it, as written.
+type token = outline.Token
+
+// puncts are the multi-character operators, longest first. Angle brackets
+// are always single tokens so that type arguments nest.
+var puncts = []string{
+	">>>=", "...", "===", "!==", "**=", "&&=", "||=", "??=", "<<=",
+	"=>", "==", "!=", "<=", "&&", "||", "??", "?.", "++", "--", "+=", "-=", "*=", "/=", "%=", "&=", "|=", "^=", "**", "<<",
+}
+
+// lexer splits a source into tokens. It skips comments, keeping JSDoc
+// comments on the next token, and reads JSX elements as single tokens when
+// jsx is set.
+type lexer struct {
+	outline.Scanner
+	jsx bool
+	out []token
+}
+
+func lex(src string, jsx bool) []token {
+	l := &lexer{Scanner: outline.NewScanner(src), jsx: jsx}
+	l.run()
+	return l.out
+}
+
+func (l *lexer) run() {
+	nl := true
+	doc := ""
+	for l.I < len(l.Src) {
+		c := l.Src[l.I]
+		switch {
+		case c == '\n':
+			l.SkipTo(l.I + 1)
+			nl = true
+			continue
+		case c == ' ' || c == '\t' || c == '\r' || c == '\f' || c == '\v':
+			l.I++
+			continue
+		case c == '/' && l.Peek(1) == '/':
+			l.SkipLine()
+			continue
+		case c == '/' && l.Peek(1) == '*':
+			start := l.I
+			end := strings.Index(l.Src[l.I+2:], "*/")
+			if end < 0 {
+				end = len(l.Src)
+			} else {
+				end += l.I + 4
+			}
+			l.SkipTo(end)
+			if text := l.Src[start:end]; strings.HasPrefix(text, "/**") && text != "/**/" {
+				doc = text
+			}
+			continue
+		case c == 0xEF && strings.HasPrefix(l.Src[l.I:], "\ufeff"):
+			l.I += 3
+			continue
+		case c == '#' && l.I == 0 && l.Peek(1) == '!':
+			l.SkipLine()
+			continue
+		}
+		t := token{Line: l.Line, Col: l.I - l.LineStart, NL: nl, Doc: doc}
+		start := l.I
+		switch {
+		case c == '"' || c == '\'':
+			l.SkipTo(l.string(l.I))
+			t.Kind = outline.String
+		case c == '`':
+			l.SkipTo(l.template(l.I))
+			t.Kind = tTemplate
+		case isIdentStart(c) || c == '#' && l.I+1 < len(l.Src) && isIdentStart(l.Src[l.I+1]) || c == '\\' && l.Peek(1) == 'u':
+			l.I++
+			for l.I < len(l.Src) && (isIdent(l.Src[l.I]) || l.Src[l.I] == '\\') {
+				l.I++
+			}
+			t.Kind = outline.Ident
+		case c >= '0' && c <= '9' || c == '.' && l.Peek(1) >= '0' && l.Peek(1) <= '9':
+			l.I++
+			for l.I < len(l.Src) {
+				d := l.Src[l.I]
+				if isIdent(d) || d == '.' || (d == '+' || d == '-') && (l.Src[l.I-1] == 'e' || l.Src[l.I-1] == 'E') && !strings.HasPrefix(strings.ToLower(l.Src[start:]), "0x") {
+					l.I++
+					continue
+				}
+				break
+			}
+			t.Kind = outline.Number
+		case c == '/' && l.exprStart():
+			l.SkipTo(l.regex(l.I))
+			t.Kind = tRegex
+		case c == '<' && l.jsx && l.exprStart() && (isIdentStart(l.Peek(1)) || l.Peek(1) == '>'):
+			l.SkipTo(l.element(l.I))
+			t.Kind = tJSX
+		default:
+			t.Kind = outline.Punct
+			l.SkipPunct(puncts)
+		}
+		t.Text, t.Pos = l.Src[start:l.I], start
+		t.EndLine, t.EndCol = l.Line, l.I-l.LineStart
+		l.out = append(l.out, t)
+		nl, doc = false, ""
+	}
+}
+
+// exprStart reports whether an expression may start here, where a slash
+// begins a regular expression and an angle bracket a JSX element.
+func (l *lexer) exprStart() bool {
+	if len(l.out) == 0 {
+		return true
+	}
+	prev := l.out[len(l.out)-1]
+	switch prev.Kind {
+	case outline.Punct:
+		return prev.Text != ")" && prev.Text != "]" && prev.Text != "++" && prev.Text != "--"
+	case outline.Ident:
+		switch prev.Text {
+		case "return", "typeof", "instanceof", "in", "of", "new", "delete", "void", "throw", "case", "do", "else", "yield", "await":
+			return true
+		}
+	}
+	return false
+}
+
+// string returns the offset after the quoted string at i. An unterminated
+// string ends at the end of its line.
+func (l *lexer) string(i int) int {
+	q := l.Src[i]
+	for i++; i < len(l.Src); i++ {
+		switch l.Src[i] {
+		case '\\':
+			i++
+		case q:
+			return i + 1
+		case '\n':
+			return i
+		}
+	}
+	return len(l.Src)
+}
+
+// template returns the offset after the template literal at i.
+func (l *lexer) template(i int) int {
+	for i++; i < len(l.Src); i++ {
+		switch l.Src[i] {
+		case '\\':
+			i++
+		case '`':
+			return i + 1
+		case '$':
+			if i+1 < len(l.Src) && l.Src[i+1] == '{' {
+				i = l.code(i+2, '}') - 1
+			}
+		}
+	}
+	return len(l.Src)
+}
+
+// regex returns the offset after the regular expression literal at i.
+func (l *lexer) regex(i int) int {
+	class := false
+	for i++; i < len(l.Src); i++ {
+		switch c := l.Src[i]; {
+		case c == '\\':
+			i++
+		case c == '[':
+			class = true
+		case c == ']':
+			class = false
+		case c == '\n':
+			return i
+		case c == '/' && !class:
+			i++
+			for i < len(l.Src) && isIdent(l.Src[i]) {
+				i++
+			}
+			return i
+		}
+	}
+	return len(l.Src)
+}
+
+// code returns the offset after the close that ends the code starting at i,
+// skipping strings, templates, comments, JSX and nested brackets.
+func (l *lexer) code(i int, close byte) int {
+	depth := 0
+	for ; i < len(l.Src); i++ {
+		switch c := l.Src[i]; c {
+		case '"', '\'':
+			i = l.string(i) - 1
+		case '`':
+			i = l.template(i) - 1
+		case '/':
+			switch {
+			case i+1 < len(l.Src) && l.Src[i+1] == '/':
+				for i < len(l.Src) && l.Src[i] != '\n' {
+					i++
+				}
+			case i+1 < len(l.Src) && l.Src[i+1] == '*':
+				if end := strings.Index(l.Src[i+2:], "*/"); end >= 0 {
+					i += end + 3
+				} else {
+					i = len(l.Src)
+				}
+			}
+		case '<':
+			if l.jsx && i+1 < len(l.Src) && (isIdentStart(l.Src[i+1]) || l.Src[i+1] == '>') && prevIsOpen(l.Src[:i]) {
+				i = l.element(i) - 1
+			}
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			if depth == 0 && c == close {
+				return i + 1
+			}
+			depth--
+		}
+	}
+	return len(l.Src)
+}
+
+// prevIsOpen reports whether the last non-space character of s leaves an
+// expression to start, as inside JSX expression containers.
+func prevIsOpen(s string) bool {
+	s = strings.TrimRight(s, " \t\r\n")
+	if s == "" {
+		return true
+	}
+	return strings.IndexByte("({[,=:?&|!;>", s[len(s)-1]) >= 0 || strings.HasSuffix(s, "return")
+}
+
+// element returns the offset after the JSX element or fragment at i.
+func (l *lexer) element(i int) int {
+	depth := 0
+	for i < len(l.Src) {
+		switch c := l.Src[i]; {
+		case c == '<' && i+1 < len(l.Src) && l.Src[i+1] == '/':
+			// A closing tag.
+			end := strings.IndexByte(l.Src[i:], '>')
+			if end < 0 {
+				return len(l.Src)
+			}
+			i += end + 1
+			depth--
+			if depth <= 0 {
+				return i
+			}
+		case c == '<':
+			// An opening tag: its name and attributes.
+			i++
+			for i < len(l.Src) {
+				d := l.Src[i]
+				if d == '"' || d == '\'' {
+					i = l.string(i)
+					continue
+				}
+				if d == '{' {
+					i = l.code(i+1, '}')
+					continue
+				}
+				if d == '/' && i+1 < len(l.Src) && l.Src[i+1] == '>' {
+					i += 2
+					if depth == 0 {
+						return i
+					}
+					break
+				}
+				if d == '>' {
+					i++
+					depth++
+					break
+				}
+				i++
+			}
+		case c == '{':
+			i = l.code(i+1, '}')
+		default:
+			i++
+		}
+	}
+	return len(l.Src)
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c == '$' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+func isIdent(c byte) bool { return isIdentStart(c) || c >= '0' && c <= '9' }
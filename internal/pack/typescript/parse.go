@@ -0,0 +1,1227 @@
+package typescript
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/ChaseHampton/cargoworker/internal/pack/outline"
+)
+
+// module is the outline of one source file.
+type module struct {
+	doc     string // the JSDoc comment of the file, as written
+	imports []importDecl
+	exports []exportDecl
+	defs    []*decl
+	esm     bool // has import or export declarations
+	cjs     bool // assigns module.exports or exports
+}
+
+// importDecl is an import of another module: an import declaration, a
+// require call, a dynamic import or the source of a re-export.
+type importDecl struct {
+	spec     string
+	names    []binding
+	line     int
+	typeOnly bool
+	via      string // "", "require", "dynamic" or "reexport"
+}
+
+// binding pairs a name in another module, an identifier, "default" or "*",
+// with its local name.
+type binding struct {
+	name, local string
+}
+
+// exportDecl is an export list, export * or a CommonJS export.
+type exportDecl struct {
+	from  string // the module re-exported from; "" for local bindings
+	names []binding
+	star  bool   // export * [as ns] from
+	as    string // the namespace of export * as ns
+	line  int
+	col   int
+	doc   string
+}
+
+// decl is a declaration.
+type decl struct {
+	kind       string // class, interface, type, enum, function, variable, constant, namespace, method, constructor, property, field
+	name       string
+	line, col  int // of the name: 1-based line and byte column
+	endLine    int
+	endCol     int // 1-based, after the last character
+	exported   bool
+	isDefault  bool
+	exportAs   []string // names exported under, when not its own
+	declare    bool
+	async      bool
+	generator  bool
+	optional   bool
+	body       bool // a function with a body, not an overload signature
+	modifiers  []string
+	decorators []string
+	doc        string // JSDoc, as written
+	typeParams []typeParam
+	params     []param
+	returns    string
+	typ        string // of variables, fields and properties; the aliased type of type aliases
+	extends    []string
+	implements []string
+	sig        string
+	overloads  []string
+	children   []*decl
+}
+
+type param struct {
+	Name     string `json:"name"`
+	Type     string `json:"type,omitempty"`
+	Default  string `json:"default,omitempty"`
+	Optional bool   `json:"optional,omitempty"`
+	Variadic bool   `json:"variadic,omitempty"`
+	// modifiers make a constructor parameter a property.
+	modifiers []string
+	tok       token
+}
+
+type typeParam struct {
+	Name       string `json:"name"`
+	Constraint string `json:"constraint,omitempty"`
+	Default    string `json:"default,omitempty"`
+}
+
+type parser struct {
+	outline.Cursor
+	mod *module
+}
+
+// parse outlines a source. jsx enables JSX elements, as in .tsx, .jsx and
+// .js files.
+func parse(src string, jsx bool) *module {
+	p := &parser{Cursor: outline.Cursor{Src: src, Toks: lex(src, jsx)}, mod: &module{}}
+	p.Ends = p.asiEnd
+	if len(p.Toks) > 0 && fileDoc(p.Toks[0]) {
+		p.mod.doc, p.Toks[0].Doc = p.Toks[0].Doc, ""
+	}
+	p.mod.defs = p.statements(false)
+	p.requires()
+	p.resolveExports()
+	return p.mod
+}
+
+// fileDoc reports whether the comment before the first token of a file
+// documents the file rather than the first declaration.
+func fileDoc(t token) bool {
+	if t.Doc == "" {
+		return false
+	}
+	for _, tag := range []string{"@packageDocumentation", "@module", "@file", "@fileoverview", "@overview"} {
+		if strings.Contains(t.Doc, tag) {
+			return true
+		}
+	}
+	return t.Is("import") || t.Kind == outline.String
+}
+
+// end sets the end of d to the last consumed token.
+func (p *parser) end(d *decl) {
+	t := p.Last()
+	d.endLine, d.endCol = t.EndLine, t.EndCol+1
+}
+
+// angle skips the type argument or parameter list opening at the current
+// token and returns the token range inside it.
+func (p *parser) angle() (from, to int) {
+	depth := 0
+	from = p.I + 1
+	for !p.EOF() {
+		t := p.Toks[p.I]
+		switch {
+		case t.Is("<"):
+			depth++
+		case t.Is(">"):
+			depth--
+			if depth == 0 {
+				p.I++
+				return from, p.I - 1
+			}
+		case t.Opens():
+			p.Group()
+			continue
+		case t.Closes(), t.Is(";"):
+			return from, p.I // unbalanced; stop before it
+		}
+		p.I++
+	}
+	return from, p.I
+}
+
+// contAfter are tokens after which a line break does not end a statement.
+var contAfter = outline.Set(`= + - * / % , . ?. ( [ { ? : && || ?? => | & ^ ! ~ < <= >= == === != !== += -= *= /= %= **= <<= >>>= &= |= ^= &&= ||= ??= ... ** <<
+	extends implements new typeof keyof in of instanceof as satisfies await delete void is infer readonly unique asserts`)
+
+// contBefore are tokens before which a line break does not end a statement.
+var contBefore = outline.Set(`. ?. ( [ , ? : = => && || ?? + - * / % | & ^ < > == === != !== += -= *= /= as satisfies in instanceof extends implements`)
+
+// asiEnd reports whether a statement ends before the current token by
+// automatic semicolon insertion.
+func (p *parser) asiEnd() bool {
+	t := p.Peek(0)
+	if !t.NL || p.I == 0 {
+		return false
+	}
+	prev := p.Toks[p.I-1]
+	if prev.Kind == outline.Punct || prev.Kind == outline.Ident {
+		if contAfter[prev.Text] {
+			return false
+		}
+	}
+	if (t.Kind == outline.Punct || t.Kind == outline.Ident) && contBefore[t.Text] || t.Kind == tTemplate {
+		return false
+	}
+	return true
+}
+
+// skipStatement skips to the end of the statement at the current token.
+func (p *parser) skipStatement() {
+	p.Until(false, ";")
+	p.Accept(";")
+}
+
+// statements parses statements up to the end of the source or, in a block,
+// its closing brace.
+func (p *parser) statements(block bool) []*decl {
+	var defs []*decl
+	for !p.EOF() {
+		t := p.Peek(0)
+		if block && t.Is("}") {
+			break
+		}
+		if t.Is(";") || t.Closes() {
+			p.I++
+			continue
+		}
+		start := p.I
+		defs = p.statement(defs)
+		if p.I == start {
+			p.I++
+		}
+	}
+	return defs
+}
+
+// add appends declarations, merging overloads and declaration merges.
+func add(defs []*decl, ds ...*decl) []*decl {
+next:
+	for _, d := range ds {
+		for k, o := range defs {
+			if o.name != d.name || o.kind != d.kind {
+				continue
+			}
+			switch d.kind {
+			case "function", "method", "constructor":
+				switch {
+				case !o.body:
+					// The implementation, or another overload, after an overload.
+					if d.body {
+						d.overloads = append([]string{o.sig}, o.overloads...)
+						if d.doc == "" {
+							d.doc = o.doc
+						}
+						d.exported = d.exported || o.exported
+						defs[k] = d
+					} else {
+						o.overloads = append(o.overloads, d.sig)
+					}
+				}
+				continue next
+			case "interface", "namespace", "enum":
+				o.children = add(o.children, d.children...)
+				o.exported = o.exported || d.exported
+				continue next
+			case "property":
+				continue next // the setter of a getter
+			}
+		}
+		defs = append(defs, d)
+	}
+	return defs
+}
+
+func (p *parser) statement(defs []*decl) []*decl {
+	doc := p.Peek(0).Doc
+	decorators := p.decorators()
+	t := p.Peek(0)
+	switch {
+	case t.Is("import") && !p.Peek(1).Is("(") && !p.Peek(1).Is("."):
+		p.importDecl()
+		return defs
+	case t.Is("export"):
+		return add(defs, p.export(doc, decorators)...)
+	}
+	if ds := p.declaration(doc, decorators); ds != nil {
+		return add(defs, ds...)
+	}
+	return add(defs, p.expression()...)
+}
+
+// decorators skips the decorators at the current token and returns their
+// text, without the @.
+func (p *parser) decorators() []string {
+	var out []string
+	for p.Peek(0).Is("@") && p.Peek(1).Kind == outline.Ident {
+		p.I++
+		from := p.I
+		p.I++
+		for p.Peek(0).Is(".") && p.Peek(1).Kind == outline.Ident {
+			p.I += 2
+		}
+		if p.Peek(0).Is("(") && !p.Peek(0).NL {
+			p.Group()
+		}
+		out = append(out, p.Text(from, p.I))
+	}
+	return out
+}
+
+// declaration parses a declaration at the current token, or returns nil
+// with the position unchanged.
+func (p *parser) declaration(doc string, decorators []string) []*decl {
+	start := p.I
+	declare, async, abstract := false, false, false
+	for {
+		t, next := p.Peek(0), p.Peek(1)
+		switch {
+		case t.Is("declare") && !next.NL && next.Kind == outline.Ident:
+			declare = true
+		case t.Is("abstract") && next.Is("class"):
+			abstract = true
+		case t.Is("async") && next.Is("function") && !next.NL:
+			async = true
+		default:
+			goto kw
+		}
+		p.I++
+	}
+kw:
+	t, next := p.Peek(0), p.Peek(1)
+	var ds []*decl
+	switch {
+	case t.Is("function"):
+		ds = []*decl{p.function()}
+	case t.Is("class"):
+		ds = []*decl{p.class()}
+	case t.Is("interface") && next.Kind == outline.Ident && !next.NL:
+		ds = []*decl{p.iface()}
+	case t.Is("type") && next.Kind == outline.Ident && !next.NL && (p.Peek(2).Is("=") || p.Peek(2).Is("<")):
+		ds = []*decl{p.typeAlias()}
+	case t.Is("enum") && next.Kind == outline.Ident, t.Is("const") && next.Is("enum"):
+		ds = []*decl{p.enum()}
+	case t.Is("const") || t.Is("var") || t.Is("let") && (next.Kind == outline.Ident || next.Is("{") || next.Is("[")):
+		ds = p.variables()
+	case (t.Is("namespace") || t.Is("module")) && (next.Kind == outline.Ident || next.Kind == outline.String) && !next.NL:
+		ds = []*decl{p.namespace(declare)}
+	case t.Is("global") && declare && next.Is("{"):
+		ds = []*decl{p.namespace(true)}
+	default:
+		p.I = start
+		return nil
+	}
+	for _, d := range ds {
+		if d.doc == "" {
+			d.doc = doc
+		}
+		d.declare, d.decorators = declare, append(decorators, d.decorators...)
+		if async {
+			d.async = true
+			d.sig = "async " + d.sig
+		}
+		if abstract {
+			d.modifiers = append([]string{"abstract"}, d.modifiers...)
+			d.sig = "abstract " + d.sig
+		}
+	}
+	return ds
+}
+
+// name reads the name of a declaration, or returns "default" positioned at
+// the keyword for an anonymous one.
+func (p *parser) name(d *decl, kw token) {
+	t := p.Peek(0)
+	if t.Kind == outline.Ident && !reserved[t.Text] {
+		d.name, d.line, d.col = t.Text, t.Line, t.Col+1
+		p.I++
+		return
+	}
+	d.name, d.line, d.col = "default", kw.Line, kw.Col+1
+}
+
+// reserved are words that cannot name a declaration.
+var reserved = outline.Set(`extends implements from`)
+
+func (p *parser) function() *decl {
+	kw := p.Toks[p.I]
+	p.I++
+	d := &decl{kind: "function"}
+	if p.Accept("*") {
+		d.generator = true
+	}
+	p.name(d, kw)
+	tp := p.typeParams(d)
+	pt := p.params(d)
+	p.returns(d)
+	if p.Peek(0).Is("{") {
+		p.Group()
+		d.body = true
+	} else {
+		p.Accept(";")
+	}
+	p.end(d)
+	star := ""
+	if d.generator {
+		star = "*"
+	}
+	d.sig = "function" + star + " " + d.name + tp + "(" + pt + ")" + ret(d.returns)
+	return d
+}
+
+func ret(t string) string {
+	if t == "" {
+		return ""
+	}
+	return ": " + t
+}
+
+// typeParams parses <T extends U = V, ...> if present and returns its text.
+func (p *parser) typeParams(d *decl) string {
+	if !p.Peek(0).Is("<") {
+		return ""
+	}
+	from, to := p.angle()
+	for _, part := range p.Split(from, to) {
+		i, end := part[0], part[1]
+		for i < end-1 && (p.Toks[i].Is("in") || p.Toks[i].Is("out") || p.Toks[i].Is("const")) && p.Toks[i+1].Kind == outline.Ident {
+			i++
+		}
+		if i >= end {
+			continue
+		}
+		tp := typeParam{Name: p.Toks[i].Text}
+		i++
+		if i < end && p.Toks[i].Is("extends") {
+			j := p.Find(i+1, end, "=")
+			tp.Constraint = p.Text(i+1, j)
+			i = j
+		}
+		if i < end && p.Toks[i].Is("=") {
+			tp.Default = p.Text(i+1, end)
+		}
+		d.typeParams = append(d.typeParams, tp)
+	}
+	return "<" + p.Text(from, to) + ">"
+}
+
+// params parses a parameter list, if present, and returns its text.
+func (p *parser) params(d *decl) string {
+	if !p.Peek(0).Is("(") {
+		return ""
+	}
+	from, to := p.Group()
+	for _, part := range p.Split(from, to) {
+		i, end := part[0], part[1]
+		var pr param
+		for i < end-1 && paramModifiers[p.Toks[i].Text] && p.Toks[i].Kind == outline.Ident && p.Toks[i+1].Kind != outline.Punct {
+			pr.modifiers = append(pr.modifiers, p.Toks[i].Text)
+			i++
+		}
+		if p.Toks[i].Is("...") {
+			pr.Variadic = true
+			i++
+		}
+		if i >= end {
+			continue
+		}
+		if p.Toks[i].Opens() {
+			// A destructuring pattern.
+			j := i
+			depth := 0
+			for ; j < end; j++ {
+				if p.Toks[j].Opens() {
+					depth++
+				} else if p.Toks[j].Closes() {
+					depth--
+					if depth == 0 {
+						j++
+						break
+					}
+				}
+			}
+			pr.Name, i = p.Text(i, j), j
+		} else {
+			pr.Name, pr.tok = p.Toks[i].Text, p.Toks[i]
+			i++
+		}
+		if i < end && p.Toks[i].Is("?") {
+			pr.Optional = true
+			i++
+		}
+		if i < end && p.Toks[i].Is(":") {
+			j := p.Find(i+1, end, "=")
+			pr.Type, i = p.Text(i+1, j), j
+		}
+		if i < end && p.Toks[i].Is("=") {
+			pr.Default = p.Text(i+1, end)
+		}
+		d.params = append(d.params, pr)
+	}
+	return p.Text(from, to)
+}
+
+var paramModifiers = outline.Set(`public private protected readonly override`)
+
+// returns parses a return type annotation, if present.
+func (p *parser) returns(d *decl) {
+	if p.Accept(":") {
+		from, to := p.Until(true, "{", ";", "=>")
+		d.returns = p.Text(from, to)
+	}
+}
+
+func (p *parser) class() *decl {
+	kw := p.Toks[p.I]
+	p.I++
+	d := &decl{kind: "class"}
+	p.name(d, kw)
+	tp := p.typeParams(d)
+	sig := "class " + d.name + tp
+	if p.Accept("extends") {
+		from, to := p.Until(true, "implements", "{")
+		d.extends = []string{p.Text(from, to)}
+		sig += " extends " + d.extends[0]
+	}
+	if p.Accept("implements") {
+		from, to := p.Until(true, "{")
+		for _, part := range p.Split(from, to) {
+			d.implements = append(d.implements, p.Text(part[0], part[1]))
+		}
+		sig += " implements " + strings.Join(d.implements, ", ")
+	}
+	d.sig = sig
+	if p.Accept("{") {
+		d.children = p.members(false)
+		p.Accept("}")
+	}
+	p.end(d)
+	return d
+}
+
+func (p *parser) iface() *decl {
+	p.I++
+	d := &decl{kind: "interface"}
+	p.name(d, p.Last())
+	tp := p.typeParams(d)
+	d.sig = "interface " + d.name + tp
+	if p.Accept("extends") {
+		from, to := p.Until(true, "{")
+		for _, part := range p.Split(from, to) {
+			d.extends = append(d.extends, p.Text(part[0], part[1]))
+		}
+		d.sig += " extends " + strings.Join(d.extends, ", ")
+	}
+	if p.Accept("{") {
+		d.children = p.members(true)
+		p.Accept("}")
+	}
+	p.end(d)
+	return d
+}
+
+var memberModifiers = outline.Set(`public private protected static readonly abstract declare override accessor async`)
+
+// memberName reports whether t can start a member name.
+func memberName(t token) bool {
+	switch t.Kind {
+	case outline.Ident, outline.String, outline.Number:
+		return true
+	case outline.Punct:
+		return t.Text == "[" || t.Text == "*"
+	}
+	return false
+}
+
+// members parses the members of a class or interface body up to its
+// closing brace.
+func (p *parser) members(iface bool) []*decl {
+	var defs []*decl
+	for !p.EOF() && !p.Peek(0).Is("}") {
+		if p.Accept(";") || p.Accept(",") {
+			continue
+		}
+		start := p.I
+		doc := p.Peek(0).Doc
+		decorators := p.decorators()
+		var mods []string
+		for memberModifiers[p.Peek(0).Text] && p.Peek(0).Kind == outline.Ident && memberName(p.Peek(1)) && !p.Peek(1).NL {
+			mods = append(mods, p.Peek(0).Text)
+			p.I++
+		}
+		if p.Peek(0).Is("static") && p.Peek(1).Is("{") {
+			p.I++
+			p.Group() // a static initialization block
+			continue
+		}
+		accessor := ""
+		if (p.Peek(0).Is("get") || p.Peek(0).Is("set")) && memberName(p.Peek(1)) && !p.Peek(1).NL {
+			accessor = p.Peek(0).Text
+			p.I++
+		}
+		generator := p.Accept("*")
+		t := p.Peek(0)
+		d := &decl{kind: "field", modifiers: mods, decorators: decorators, doc: doc, line: t.Line, col: t.Col + 1, generator: generator}
+		switch {
+		case t.Kind == outline.Ident || t.Kind == outline.Number:
+			d.name = t.Text
+			p.I++
+		case t.Kind == outline.String:
+			d.name = unquote(t.Text)
+			p.I++
+		case t.Is("["):
+			if p.Peek(1).Kind == outline.Ident && p.Peek(2).Is(":") {
+				// An index signature.
+				p.Until(true, ";", ",")
+				continue
+			}
+			from, to := p.Group()
+			d.name = "[" + p.Text(from, to) + "]"
+		case t.Is("(") || t.Is("<"):
+			// A call signature.
+			p.Until(true, ";", ",")
+			continue
+		default:
+			if p.I == start {
+				p.I++
+			}
+			continue
+		}
+		if p.Accept("?") {
+			d.optional = true
+		} else {
+			p.Accept("!")
+		}
+		if p.Peek(0).Is("(") || p.Peek(0).Is("<") {
+			if iface && d.name == "new" {
+				p.Until(true, ";", ",") // a construct signature
+				continue
+			}
+			d.kind = "method"
+			switch {
+			case d.name == "constructor" && !iface:
+				d.kind = "constructor"
+			case accessor != "":
+				d.kind = "property"
+			}
+			tp := p.typeParams(d)
+			pt := p.params(d)
+			p.returns(d)
+			if p.Peek(0).Is("{") {
+				p.Group()
+				d.body = true
+			} else {
+				p.Accept(";")
+				d.body = iface || slices.Contains(mods, "abstract")
+			}
+			p.end(d)
+			words := append([]string(nil), mods...)
+			if accessor != "" {
+				words = append(words, accessor)
+			}
+			d.sig = strings.Join(append(words, d.name), " ") + optional(d) + tp + "(" + pt + ")" + ret(d.returns)
+			if d.kind == "property" {
+				if accessor == "get" {
+					d.typ = d.returns
+				} else if len(d.params) > 0 {
+					d.typ = d.params[0].Type
+				}
+			}
+			if d.kind == "constructor" {
+				defs = add(defs, d)
+				defs = add(defs, paramProperties(d)...)
+				continue
+			}
+			if slices.Contains(mods, "async") {
+				d.async = true
+			}
+		} else {
+			if p.Accept(":") {
+				from, to := p.Until(true, "=", ";", ",", "}")
+				d.typ = p.Text(from, to)
+			}
+			if p.Accept("=") {
+				p.Until(false, ";", ",", "}")
+			}
+			p.Accept(";")
+			p.end(d)
+			d.sig = strings.TrimSpace(strings.Join(mods, " ")+" "+d.name) + optional(d) + ret(d.typ)
+		}
+		defs = add(defs, d)
+	}
+	return defs
+}
+
+func optional(d *decl) string {
+	if d.optional {
+		return "?"
+	}
+	return ""
+}
+
+// paramProperties returns the fields a constructor declares with parameter
+// modifiers, as in constructor(private readonly db: DB).
+func paramProperties(ctor *decl) []*decl {
+	var out []*decl
+	for _, pr := range ctor.params {
+		if len(pr.modifiers) == 0 || pr.tok.Text == "" {
+			continue
+		}
+		out = append(out, &decl{
+			kind: "field", name: pr.Name, modifiers: pr.modifiers, typ: pr.Type, optional: pr.Optional,
+			line: pr.tok.Line, col: pr.tok.Col + 1, endLine: pr.tok.EndLine, endCol: pr.tok.EndCol + 1,
+			sig: strings.Join(pr.modifiers, " ") + " " + pr.Name + map[bool]string{true: "?"}[pr.Optional] + ret(pr.Type),
+		})
+	}
+	return out
+}
+
+func (p *parser) typeAlias() *decl {
+	p.I++
+	d := &decl{kind: "type"}
+	p.name(d, p.Last())
+	tp := p.typeParams(d)
+	p.Accept("=")
+	from, to := p.Until(true, ";")
+	d.typ = p.Text(from, to)
+	p.Accept(";")
+	p.end(d)
+	d.sig = "type " + d.name + tp + " = " + d.typ
+	return d
+}
+
+func (p *parser) enum() *decl {
+	d := &decl{kind: "enum"}
+	sig := "enum "
+	if p.Accept("const") {
+		d.modifiers = []string{"const"}
+		sig = "const enum "
+	}
+	p.I++
+	p.name(d, p.Last())
+	d.sig = sig + d.name
+	if p.Accept("{") {
+		for !p.EOF() && !p.Peek(0).Is("}") {
+			if p.Accept(",") {
+				continue
+			}
+			t := p.Peek(0)
+			m := &decl{kind: "constant", doc: t.Doc, line: t.Line, col: t.Col + 1, exported: true}
+			switch t.Kind {
+			case outline.Ident:
+				m.name = t.Text
+			case outline.String:
+				m.name = unquote(t.Text)
+			default:
+				p.I++
+				continue
+			}
+			p.I++
+			from := p.I
+			if p.Accept("=") {
+				p.Until(false, ",", "}")
+			}
+			p.end(m)
+			m.sig = d.name + "." + m.name
+			if from < p.I {
+				m.sig += " " + p.Text(from, p.I)
+			}
+			d.children = append(d.children, m)
+		}
+		p.Accept("}")
+	}
+	p.end(d)
+	return d
+}
+
+// variables parses a variable statement. Variables initialized with a
+// function or arrow function are functions.
+func (p *parser) variables() []*decl {
+	kw := p.Toks[p.I]
+	p.I++
+	kind := "variable"
+	if kw.Text == "const" {
+		kind = "constant"
+	}
+	var out []*decl
+	for !p.EOF() {
+		t := p.Peek(0)
+		if t.Opens() {
+			// A destructuring pattern.
+			p.Group()
+			p.Until(false, ",", ";")
+		} else if t.Kind == outline.Ident {
+			d := &decl{kind: kind, name: t.Text, line: t.Line, col: t.Col + 1, doc: t.Doc}
+			p.I++
+			p.Accept("!")
+			if p.Accept(":") {
+				from, to := p.Until(true, "=", ",", ";")
+				d.typ = p.Text(from, to)
+			}
+			d.sig = kw.Text + " " + d.name + ret(d.typ)
+			if p.Accept("=") {
+				p.initializer(d)
+			}
+			p.end(d)
+			out = append(out, d)
+		} else {
+			break
+		}
+		if !p.Accept(",") {
+			break
+		}
+	}
+	p.Accept(";")
+	return out
+}
+
+// initializer skips the initializer of a variable, making the variable a
+// function if it is a function or arrow function.
+func (p *parser) initializer(d *decl) {
+	start := p.I
+	async := false
+	if p.Peek(0).Is("async") && !p.Peek(1).NL {
+		async = true
+		p.I++
+	}
+	switch t := p.Peek(0); {
+	case t.Is("function"):
+		f := p.function()
+		d.kind, d.typeParams, d.params, d.returns, d.generator = "function", f.typeParams, f.params, f.returns, f.generator
+		d.sig = "function " + d.name + strings.TrimPrefix(f.sig, "function "+f.name)
+		if f.name == "default" {
+			d.sig = strings.Replace(f.sig, " default", " "+d.name, 1)
+		}
+		d.body = true
+	case t.Is("(") || t.Is("<") || t.Kind == outline.Ident && p.Peek(1).Is("=>"):
+		f := &decl{}
+		tp := p.typeParams(f)
+		var pt string
+		if p.Peek(0).Kind == outline.Ident {
+			f.params = []param{{Name: p.Peek(0).Text}}
+			pt = p.Peek(0).Text
+			p.I++
+		} else {
+			pt = p.params(f)
+		}
+		p.returns(f)
+		if !p.Accept("=>") {
+			p.I = start
+			break
+		}
+		d.kind, d.typeParams, d.params, d.returns, d.body = "function", f.typeParams, f.params, f.returns, true
+		d.sig = "const " + d.name + " = " + tp + "(" + pt + ")" + ret(f.returns) + " =>"
+	default:
+		p.I = start
+	}
+	if async && d.kind == "function" {
+		d.async = true
+		d.sig = strings.Replace(d.sig, "= ", "= async ", 1)
+		if strings.HasPrefix(d.sig, "function") {
+			d.sig = "async " + d.sig
+		}
+	}
+	p.Until(false, ",", ";")
+}
+
+func (p *parser) namespace(declare bool) *decl {
+	kw := p.Toks[p.I]
+	d := &decl{kind: "namespace"}
+	if kw.Is("global") {
+		d.name, d.line, d.col = "global", kw.Line, kw.Col+1
+		p.I++
+	} else {
+		p.I++
+		t := p.Peek(0)
+		d.line, d.col = t.Line, t.Col+1
+		if t.Kind == outline.String {
+			d.name = unquote(t.Text)
+			p.I++
+		} else {
+			from := p.I
+			p.I++
+			for p.Peek(0).Is(".") && p.Peek(1).Kind == outline.Ident {
+				p.I += 2
+			}
+			d.name = p.Text(from, p.I)
+		}
+	}
+	d.sig = kw.Text + " " + d.name
+	if p.Accept("{") {
+		d.children = p.statements(true)
+		p.Accept("}")
+		if declare {
+			// Declarations in an ambient module are exported.
+			for _, c := range d.children {
+				c.exported = true
+			}
+		}
+	} else {
+		p.Accept(";")
+	}
+	p.end(d)
+	return d
+}
+
+func (p *parser) importDecl() {
+	kw := p.Toks[p.I]
+	p.I++
+	p.mod.esm = true
+	im := importDecl{line: kw.Line}
+	if p.Peek(0).Is("type") && !p.Peek(1).Is(",") && !p.Peek(1).Is("from") && !p.Peek(1).Is("=") {
+		im.typeOnly = true
+		p.I++
+	}
+	if t := p.Peek(0); t.Kind == outline.String {
+		im.spec = unquote(t.Text)
+		p.I++
+	} else {
+		if t.Kind == outline.Ident && !t.Is("from") {
+			p.I++
+			if p.Accept("=") {
+				// import x = require("y"), or an alias of a namespace.
+				if p.Peek(0).Is("require") && p.Peek(1).Is("(") && p.Peek(2).Kind == outline.String {
+					im.spec = unquote(p.Peek(2).Text)
+					im.names = []binding{{"*", t.Text}}
+					im.via = "require"
+					p.mod.imports = append(p.mod.imports, im)
+				}
+				p.skipStatement()
+				return
+			}
+			im.names = append(im.names, binding{"default", t.Text})
+			p.Accept(",")
+		}
+		if p.Accept("*") {
+			p.Accept("as")
+			im.names = append(im.names, binding{"*", p.Peek(0).Text})
+			p.I++
+		}
+		if p.Peek(0).Is("{") {
+			from, to := p.Group()
+			im.names = append(im.names, p.bindings(from, to)...)
+		}
+		if p.Accept("from") && p.Peek(0).Kind == outline.String {
+			im.spec = unquote(p.Peek(0).Text)
+			p.I++
+		}
+	}
+	if p.Accept("with") || p.Accept("assert") {
+		if p.Peek(0).Is("{") {
+			p.Group()
+		}
+	}
+	p.Accept(";")
+	if im.spec != "" {
+		p.mod.imports = append(p.mod.imports, im)
+	}
+}
+
+// bindings parses the items of an import or export list: name [as local],
+// each maybe preceded by type.
+func (p *parser) bindings(from, to int) []binding {
+	var out []binding
+	for _, part := range p.Split(from, to) {
+		i, end := part[0], part[1]
+		if end-i > 1 && p.Toks[i].Is("type") {
+			i++
+		}
+		name := p.Toks[i].Text
+		if p.Toks[i].Kind == outline.String {
+			name = unquote(name)
+		}
+		b := binding{name, name}
+		if i+2 < end+1 && i+1 < end && p.Toks[i+1].Is("as") && i+2 < end {
+			b.local = p.Toks[i+2].Text
+			if p.Toks[i+2].Kind == outline.String {
+				b.local = unquote(b.local)
+			}
+		}
+		out = append(out, b)
+	}
+	return out
+}
+
+// export parses an export declaration and returns the declarations it
+// makes.
+func (p *parser) export(doc string, decorators []string) []*decl {
+	kw := p.Toks[p.I]
+	p.I++
+	p.mod.esm = true
+	ex := exportDecl{line: kw.Line, col: kw.Col + 1, doc: doc}
+	typeOnly := false
+	if p.Peek(0).Is("type") && (p.Peek(1).Is("{") || p.Peek(1).Is("*")) {
+		typeOnly = true
+		p.I++
+	}
+	switch t := p.Peek(0); {
+	case t.Is("*"):
+		p.I++
+		ex.star = true
+		if p.Accept("as") {
+			ex.as = unquote(p.Peek(0).Text)
+			p.I++
+		}
+		if p.Accept("from") && p.Peek(0).Kind == outline.String {
+			ex.from = unquote(p.Peek(0).Text)
+			p.I++
+		}
+		p.skipStatement()
+		p.reexport(ex, typeOnly)
+		return nil
+	case t.Is("{"):
+		from, to := p.Group()
+		ex.names = p.bindings(from, to)
+		if p.Accept("from") && p.Peek(0).Kind == outline.String {
+			ex.from = unquote(p.Peek(0).Text)
+			p.I++
+		}
+		p.skipStatement()
+		p.reexport(ex, typeOnly)
+		return nil
+	case t.Is("="):
+		// export = x, the CommonJS export of TypeScript.
+		p.I++
+		p.mod.cjs = true
+		if p.Peek(0).Kind == outline.Ident && (p.Peek(1).Is(";") || p.Peek(1).NL || p.I+1 >= len(p.Toks)) {
+			ex.names = []binding{{p.Peek(0).Text, "default"}}
+			p.mod.exports = append(p.mod.exports, ex)
+		}
+		p.skipStatement()
+		return nil
+	case t.Is("as"), t.Is("import"):
+		p.skipStatement() // export as namespace X; export import A = B
+		return nil
+	case t.Is("default"):
+		p.I++
+		decorators = append(decorators, p.decorators()...)
+		if ds := p.declaration(doc, decorators); ds != nil {
+			for _, d := range ds {
+				d.exported, d.isDefault = true, true
+			}
+			return ds
+		}
+		if n := p.Peek(0); n.Kind == outline.Ident && (p.Peek(1).Is(";") || p.Peek(1).NL || p.I+1 >= len(p.Toks) || p.Peek(1).Is("}")) {
+			ex.names = []binding{{n.Text, "default"}}
+			p.mod.exports = append(p.mod.exports, ex)
+			p.skipStatement()
+			return nil
+		}
+		d := &decl{kind: "variable", name: "default", line: t.Line, col: t.Col + 1, doc: doc, exported: true, isDefault: true}
+		from, to := p.Until(false, ";")
+		d.sig = "export default " + p.Text(from, to)
+		if len(d.sig) > maxSignature {
+			d.sig = "export default " + p.Text(from, from+1) + " …"
+		}
+		p.Accept(";")
+		p.end(d)
+		return []*decl{d}
+	}
+	decorators = append(decorators, p.decorators()...)
+	ds := p.declaration(doc, decorators)
+	for _, d := range ds {
+		d.exported = true
+	}
+	if ds == nil {
+		p.skipStatement()
+	}
+	return ds
+}
+
+// reexport records an export list or export *, and the import of the
+// module it re-exports from.
+func (p *parser) reexport(ex exportDecl, typeOnly bool) {
+	p.mod.exports = append(p.mod.exports, ex)
+	if ex.from == "" {
+		return
+	}
+	im := importDecl{spec: ex.from, line: ex.line, typeOnly: typeOnly, via: "reexport"}
+	if ex.star {
+		im.names = []binding{{"*", ex.as}}
+	} else {
+		im.names = ex.names
+	}
+	p.mod.imports = append(p.mod.imports, im)
+}
+
+// expression skips an expression statement, recording the CommonJS exports
+// it makes: module.exports = ..., exports.name = ... and
+// module.exports.name = ...
+func (p *parser) expression() []*decl {
+	t := p.Peek(0)
+	i := p.I
+	switch {
+	case t.Is("module") && p.Peek(1).Is(".") && p.Peek(2).Is("exports"):
+		i += 3
+	case t.Is("exports"):
+		i++
+	default:
+		p.skipStatement()
+		return nil
+	}
+	name := ""
+	if i+1 < len(p.Toks) && p.Toks[i].Is(".") && p.Toks[i+1].Kind == outline.Ident {
+		name, i = p.Toks[i+1].Text, i+2
+	}
+	if i >= len(p.Toks) || !p.Toks[i].Is("=") {
+		p.skipStatement()
+		return nil
+	}
+	p.mod.cjs = true
+	nameTok := p.Toks[i-1]
+	p.I = i + 1
+	ex := exportDecl{line: t.Line, col: t.Col + 1, doc: t.Doc}
+	v := p.Peek(0)
+	switch {
+	case name == "" && v.Is("{"):
+		// module.exports = { a, b: c, ... }
+		from, to := p.Group()
+		for _, part := range p.Split(from, to) {
+			k, end := part[0], part[1]
+			key := p.Toks[k]
+			switch {
+			case key.Kind != outline.Ident && key.Kind != outline.String:
+			case end-k == 1:
+				ex.names = append(ex.names, binding{key.Text, key.Text})
+			case end-k == 3 && p.Toks[k+1].Is(":") && p.Toks[k+2].Kind == outline.Ident:
+				ex.names = append(ex.names, binding{p.Toks[k+2].Text, unquote(key.Text)})
+			}
+		}
+		p.mod.exports = append(p.mod.exports, ex)
+		p.skipStatement()
+		return nil
+	case v.Kind == outline.Ident && !v.Is("function") && !v.Is("class") && !v.Is("async") && (p.Peek(1).Is(";") || p.Peek(1).NL || p.I+1 >= len(p.Toks)):
+		local := v.Text
+		if name == "" {
+			name = "default"
+		}
+		ex.names = []binding{{local, name}}
+		p.mod.exports = append(p.mod.exports, ex)
+		p.skipStatement()
+		return nil
+	}
+	if name == "" {
+		p.skipStatement()
+		return nil
+	}
+	d := &decl{kind: "variable", name: name, line: nameTok.Line, col: nameTok.Col + 1, doc: t.Doc, exported: true}
+	d.sig = "exports." + name
+	p.initializer(d)
+	if d.kind == "function" {
+		d.sig = strings.Replace(d.sig, "const "+name+" = ", "exports."+name+" = ", 1)
+	}
+	p.Accept(";")
+	p.end(d)
+	return []*decl{d}
+}
+
+// requires records the modules loaded by require calls and dynamic imports
+// anywhere in the source.
+func (p *parser) requires() {
+	seen := map[string]bool{}
+	for _, im := range p.mod.imports {
+		seen[im.spec] = true
+	}
+	for i := 0; i+3 < len(p.Toks); i++ {
+		t := p.Toks[i]
+		if !(t.Is("require") || t.Is("import")) || !p.Toks[i+1].Is("(") || p.Toks[i+2].Kind != outline.String || !p.Toks[i+3].Is(")") {
+			continue
+		}
+		if i > 0 && (p.Toks[i-1].Is(".") || p.Toks[i-1].Is("function")) {
+			continue
+		}
+		spec := unquote(p.Toks[i+2].Text)
+		if seen[spec] {
+			continue
+		}
+		seen[spec] = true
+		via := "require"
+		if t.Is("import") {
+			via = "dynamic"
+		}
+		p.mod.imports = append(p.mod.imports, importDecl{spec: spec, line: t.Line, via: via})
+	}
+}
+
+// resolveExports applies export lists without a source to the declarations
+// they name. Lists naming imports become re-exports of the imported module.
+func (p *parser) resolveExports() {
+	byName := map[string]*decl{}
+	for _, d := range p.mod.defs {
+		if _, ok := byName[d.name]; !ok {
+			byName[d.name] = d
+		}
+	}
+	imported := map[string]struct {
+		spec string
+		name string
+	}{}
+	for _, im := range p.mod.imports {
+		if im.via == "reexport" || im.via == "dynamic" {
+			continue
+		}
+		for _, b := range im.names {
+			imported[b.local] = struct{ spec, name string }{im.spec, b.name}
+		}
+	}
+	var exports []exportDecl
+	for _, ex := range p.mod.exports {
+		if ex.from != "" {
+			exports = append(exports, ex)
+			continue
+		}
+		var rest []binding
+		for _, b := range ex.names {
+			if d := byName[b.name]; d != nil {
+				d.exported = true
+				switch {
+				case b.local == "default":
+					d.isDefault = true
+				case b.local != b.name:
+					d.exportAs = append(d.exportAs, b.local)
+				}
+				continue
+			}
+			if im, ok := imported[b.name]; ok {
+				re := exportDecl{from: im.spec, line: ex.line, col: ex.col, doc: ex.doc}
+				if im.name == "*" {
+					re.star, re.as = true, b.local
+				} else {
+					re.names = []binding{{im.name, b.local}}
+				}
+				exports = append(exports, re)
+				continue
+			}
+			rest = append(rest, b)
+		}
+		if len(rest) > 0 {
+			ex.names = rest
+			exports = append(exports, ex)
+		}
+	}
+	p.mod.exports = exports
+}
+
+// unquote drops the quotes of a string token.
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'' || s[0] == '`') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
@@ -0,0 +1,520 @@
+// Package typescript is the language pack for TypeScript and JavaScript. It
+// reads sources without a compiler: a token-level outline parser finds the
+// classes, interfaces, type aliases, enums, functions, variables and
+// namespaces of a module, with their members, overloads, decorators, types
+// and JSDoc comments.
+//
+// Each file is a module container, named by its path from the package.json
+// of its package and prefixed with the package name: src/util.ts of package
+// acme is acme/src/util. The package itself is the parent container, with
+// its version and exports map. Exported declarations are public when an
+// entry point of the package exports them, directly or through export
+// from, and internal otherwise; entry points come from the exports map, or
+// main, module and types, mapped from build outputs back to sources.
+//
+// JavaScript and TypeScript register the same pack, so a package's state is
+// shared between them. A declaration file (.d.ts) is read only where there
+// is no implementation next to it, and a .js file only where there is no
+// TypeScript source it was compiled from.
+package typescript
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+
+	"github.com/ChaseHampton/cargoworker/internal/ir"
+	"github.com/ChaseHampton/cargoworker/internal/pack"
+	"github.com/ChaseHampton/cargoworker/internal/pack/outline"
+)
+
+func init() {
+	p := New()
+	pack.Register("js", p)
+	pack.Register("ts", p)
+}
+
+// maxSignature caps the length of signatures taken from expressions.
+const maxSignature = 200
+
+// Pack extracts TypeScript and JavaScript. The package container of a
+// package.json is shared by the modules of every directory under it.
+type Pack struct {
+	mu      sync.Mutex
+	layouts map[string]*layout // by input root
+	tables  *outline.Tables
+}
+
+func New() *Pack {
+	return &Pack{layouts: map[string]*layout{}, tables: outline.NewTables()}
+}
+
+func (p *Pack) Name() string { return "typescript" }
+
+var _ pack.Pack = (*Pack)(nil)
+
+// Extract outlines the modules of a unit. Only sources are files of the
+// run: package.json and tsconfig.json are read for the layout alone.
+func (p *Pack) Extract(ctx context.Context, u pack.Unit) (*ir.Fragment, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	l, ok := p.layouts[u.Root]
+	if !ok {
+		l = newLayout(u.Root)
+		p.layouts[u.Root] = l
+	}
+	x := &extraction{l: l, u: u, frag: p.tables.Fragment(u)}
+	for _, rel := range u.Files {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if !isSource(rel) || x.shadowed(rel) {
+			continue
+		}
+		if err := x.module(rel); err != nil {
+			return nil, err
+		}
+	}
+	return x.frag.Fragment, nil
+}
+
+// extraction is the state of one Extract call.
+type extraction struct {
+	l    *layout
+	u    pack.Unit
+	frag *outline.Fragment // containers keyed by full name
+}
+
+// shadowed reports whether a file is left out for a better source of the
+// same module: a declaration file next to its implementation, or compiled
+// JavaScript next to its TypeScript.
+func (x *extraction) shadowed(rel string) bool {
+	s := stem(rel)
+	var better []string
+	switch {
+	case strings.HasSuffix(rel, ".d.ts"):
+		better = []string{".ts", ".tsx", ".js", ".jsx"}
+	case strings.HasSuffix(rel, ".d.mts"):
+		better = []string{".mts", ".mjs"}
+	case strings.HasSuffix(rel, ".d.cts"):
+		better = []string{".cts", ".cjs"}
+	case strings.HasSuffix(rel, ".js"), strings.HasSuffix(rel, ".jsx"):
+		better = []string{".ts", ".tsx"}
+	case strings.HasSuffix(rel, ".mjs"):
+		better = []string{".mts"}
+	case strings.HasSuffix(rel, ".cjs"):
+		better = []string{".cts"}
+	}
+	for _, ext := range better {
+		if x.l.exists(s + ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// packageContainer returns the container of a named package, making it.
+func (x *extraction) packageContainer(proj *project) uuid.UUID {
+	return x.frag.Container(proj.name, func() ir.Container {
+		c := ir.Container{Name: proj.name, FullName: proj.name, Kind: "package", VersionTag: proj.version, DocRaw: proj.description}
+		ex := struct {
+			Dir         string          `json:"dir"`
+			Exports     json.RawMessage `json:"exports,omitempty"`
+			EntryPoints []string        `json:"entry_points,omitempty"`
+		}{Dir: proj.dir, Exports: proj.exports}
+		for _, e := range proj.entries {
+			name, _ := x.l.moduleName(e)
+			ex.EntryPoints = append(ex.EntryPoints, name)
+		}
+		b, _ := json.Marshal(ex)
+		c.ExtraJson = string(b)
+		return c
+	})
+}
+
+// file reads and records one file.
+func (x *extraction) file(rel string, cid uuid.UUID) (string, uuid.UUID, error) {
+	src, err := os.ReadFile(filepath.Join(x.u.Root, filepath.FromSlash(rel)))
+	if err != nil {
+		return "", uuid.Nil, err
+	}
+	return string(src), x.frag.File(cid, rel, src), nil
+}
+
+// moduleExtra is the extra_json of a module container.
+type moduleExtra struct {
+	Format     string     `json:"format"` // esm, commonjs or script
+	EntryPoint bool       `json:"entry_point,omitempty"`
+	Reexports  []reexport `json:"reexports,omitempty"` // export * from
+}
+
+type reexport struct {
+	From   string `json:"from"`
+	Module string `json:"module,omitempty"` // the module from resolves to
+	As     string `json:"as,omitempty"`
+}
+
+func (x *extraction) module(rel string) error {
+	name, proj := x.l.moduleName(rel)
+	var parent uuid.UUID
+	if proj != nil && proj.name != "" {
+		parent = x.packageContainer(proj)
+	}
+	// The container is made before the file is read so that the file can
+	// point to it; its details are filled in after parsing.
+	cid := x.frag.Container(name, func() ir.Container {
+		return ir.Container{ParentId: parent, Name: path.Base(stem(rel)), FullName: name, Kind: "module"}
+	})
+	src, fid, err := x.file(rel, cid)
+	if err != nil {
+		return err
+	}
+	m := parse(src, jsx(rel))
+
+	ex := moduleExtra{Format: format(rel, m), EntryPoint: proj != nil && slices.Contains(proj.entries, rel)}
+	for _, e := range m.exports {
+		if e.star {
+			ex.Reexports = append(ex.Reexports, reexport{From: e.from, Module: x.target(rel, e.from), As: e.as})
+		}
+	}
+	if c := x.frag.Made(name); c != nil {
+		b, _ := json.Marshal(ex)
+		c.ExtraJson = string(b)
+		if m.doc != "" {
+			c.DocRaw, c.DocFmt = m.doc, parseJSDoc(cleanComment(m.doc)).Summary
+		}
+	}
+
+	x.imports(cid, rel, m)
+	e := &emitter{x: x, cid: cid, fid: fid, rel: rel, module: name, proj: proj, format: ex.Format, res: x.resolver(rel, name, m)}
+	e.defs(nil, name, m.defs, "", nil)
+	e.reexports(m)
+	return nil
+}
+
+// format returns how a module exports: as an ES module, a CommonJS module
+// or a script, whose declarations are global.
+func format(rel string, m *module) string {
+	switch {
+	case m.esm, strings.HasSuffix(rel, ".mjs"), strings.HasSuffix(rel, ".mts"):
+		return "esm"
+	case m.cjs, strings.HasSuffix(rel, ".cjs"), strings.HasSuffix(rel, ".cts"):
+		return "commonjs"
+	}
+	for _, im := range m.imports {
+		if im.via == "require" {
+			return "commonjs"
+		}
+	}
+	return "script"
+}
+
+// target returns the import target of a module specifier: the name of the
+// module a relative specifier refers to, or the specifier.
+func (x *extraction) target(rel, spec string) string {
+	if !strings.HasPrefix(spec, "./") && !strings.HasPrefix(spec, "../") && spec != "." && spec != ".." {
+		return spec
+	}
+	if f := x.l.resolve(rel, spec); f != "" {
+		name, _ := x.l.moduleName(f)
+		return name
+	}
+	p := path.Join(path.Dir(rel), spec)
+	if strings.HasPrefix(p, "../") {
+		return spec
+	}
+	name, _ := x.l.moduleName(p + ".ts")
+	return name
+}
+
+// imports records the modules a module imports, one row per module and
+// alias, with the imported names in its details.
+func (x *extraction) imports(cid uuid.UUID, rel string, m *module) {
+	type key struct{ target, alias string }
+	type details struct {
+		File     string   `json:"file"`
+		Line     int      `json:"line"`
+		Names    []string `json:"names,omitempty"`
+		TypeOnly bool     `json:"type_only,omitempty"`
+		Kind     string   `json:"kind,omitempty"` // require, dynamic or reexport; empty for import
+		Stdlib   bool     `json:"stdlib,omitempty"`
+	}
+	var order []key
+	byKey := map[key]*details{}
+	for _, im := range m.imports {
+		k := key{target: x.target(rel, im.spec)}
+		var names []string
+		for _, b := range im.names {
+			if b.name == "*" {
+				k.alias = b.local
+			} else {
+				names = append(names, b.name)
+			}
+		}
+		d, ok := byKey[k]
+		if !ok {
+			d = &details{File: rel, Line: im.line, TypeOnly: im.typeOnly, Kind: im.via, Stdlib: isStdlib(im.spec)}
+			byKey[k] = d
+			order = append(order, k)
+		} else if !im.typeOnly {
+			d.TypeOnly = false
+		}
+		for _, n := range names {
+			if !slices.Contains(d.Names, n) {
+				d.Names = append(d.Names, n)
+			}
+		}
+	}
+	for _, k := range order {
+		b, _ := json.Marshal(byKey[k])
+		x.frag.Imports = append(x.frag.Imports, ir.Import{ContainerId: cid, Target: k.target, Alias: k.alias, DetailsJson: string(b)})
+	}
+}
+
+// resolver returns the resolver of the names in a module.
+func (x *extraction) resolver(rel, name string, m *module) *resolver {
+	r := &resolver{module: name, imports: map[string]string{}, defs: map[string]bool{}}
+	r.target = func(spec string) string { return x.target(rel, spec) }
+	for _, d := range m.defs {
+		r.defs[d.name] = true
+	}
+	for _, im := range m.imports {
+		if im.via == "reexport" || im.via == "dynamic" {
+			continue
+		}
+		target := x.target(rel, im.spec)
+		for _, b := range im.names {
+			if b.name == "*" {
+				r.imports[b.local] = target
+			} else {
+				r.imports[b.local] = target + "." + b.name
+			}
+		}
+	}
+	return r
+}
+
+// emitter turns the declarations of a module into symbols.
+type emitter struct {
+	x      *extraction
+	cid    uuid.UUID
+	fid    uuid.UUID
+	rel    string
+	module string
+	proj   *project
+	format string
+	res    *resolver
+}
+
+// extra is the extra_json of a symbol.
+type extra struct {
+	Default    bool     `json:"default,omitempty"`   // the default export
+	ExportAs   []string `json:"export_as,omitempty"` // other names it is exported under
+	Modifiers  []string `json:"modifiers,omitempty"`
+	Decorators []string `json:"decorators,omitempty"`
+	Declare    bool     `json:"declare,omitempty"`
+	Async      bool     `json:"async,omitempty"`
+	Generator  bool     `json:"generator,omitempty"`
+	Optional   bool     `json:"optional,omitempty"`
+	Overloads  []string `json:"overloads,omitempty"`
+	Doc        *jsdoc   `json:"doc,omitempty"`
+}
+
+// defs emits declarations. owner is the symbol they are members of, vis its
+// visibility and scope its type parameters; all are empty at the top level
+// of a module.
+func (e *emitter) defs(owner *uuid.UUID, prefix string, defs []*decl, vis string, scope map[string]bool) {
+	for i, d := range defs {
+		id := uuid.New()
+		sym := ir.Symbol{
+			Id: id, ContainerId: e.cid, Name: d.name, FullName: prefix + "." + d.name, Kind: d.kind,
+			Visibility: e.visibility(d, owner != nil, vis), OriginFileId: e.fid,
+			StartLine: d.line, StartCol: d.col, EndLine: d.endLine, EndCol: d.endCol,
+			DocRaw: d.doc,
+		}
+		ex := extra{
+			Default: d.isDefault, ExportAs: d.exportAs, Modifiers: d.modifiers, Decorators: d.decorators,
+			Declare: d.declare, Async: d.async, Generator: d.generator, Optional: d.optional, Overloads: d.overloads,
+		}
+		if d.doc != "" {
+			sym.DocFmt = cleanComment(d.doc)
+			ex.Doc = parseJSDoc(sym.DocFmt)
+		}
+		if b, _ := json.Marshal(ex); string(b) != "{}" {
+			sym.ExtraJson = string(b)
+		}
+		e.x.frag.Symbols = append(e.x.frag.Symbols, sym)
+		if owner != nil {
+			e.x.frag.Members = append(e.x.frag.Members, ir.Member{Id: uuid.New(), OwnerSymbolId: *owner, ChildSymbolId: id, Order: i})
+		}
+		params := maps.Clone(scope)
+		if params == nil {
+			params = map[string]bool{}
+		}
+		for _, tp := range d.typeParams {
+			params[tp.Name] = true
+		}
+		e.signature(id, owner, d, params)
+		if len(d.children) > 0 {
+			e.defs(&id, sym.FullName, d.children, sym.Visibility, params)
+		}
+	}
+}
+
+// visibility follows the module system. A top-level declaration of an ES
+// or CommonJS module is private unless exported, and an export is public
+// when the package's entry points export it; scripts declare globals.
+// Members are public unless marked private or protected, and the members of
+// namespaces and enums follow their owner.
+func (e *emitter) visibility(d *decl, member bool, owner string) string {
+	switch {
+	case member && !isMemberKind(d.kind):
+		// Enum members, and the declarations of a namespace.
+		if d.exported {
+			return owner
+		}
+		return "private"
+	case member:
+		switch {
+		case strings.HasPrefix(d.name, "#"), slices.Contains(d.modifiers, "private"):
+			return "private"
+		case slices.Contains(d.modifiers, "protected"):
+			return "protected"
+		}
+		return "public"
+	case e.format == "script":
+		return "public"
+	case !d.exported:
+		return "private"
+	}
+	names := append([]string{d.name}, d.exportAs...)
+	if d.isDefault {
+		names = append(names, "default")
+	}
+	if e.proj.isPublic(e.rel, names...) {
+		return "public"
+	}
+	return "internal"
+}
+
+func isMemberKind(kind string) bool {
+	switch kind {
+	case "method", "constructor", "property", "field":
+		return true
+	}
+	return false
+}
+
+// reexports emits the names a module re-exports from others as aliases of
+// what they export.
+func (e *emitter) reexports(m *module) {
+	for _, ex := range m.exports {
+		if ex.from == "" || ex.star && ex.as == "" {
+			continue
+		}
+		target := e.x.target(e.rel, ex.from)
+		var names []binding
+		if ex.star {
+			names = []binding{{"*", ex.as}}
+		} else {
+			names = ex.names
+		}
+		for _, b := range names {
+			id := uuid.New()
+			vis := "internal"
+			if e.format == "script" || e.proj.isPublic(e.rel, b.local) {
+				vis = "public"
+			}
+			sym := ir.Symbol{
+				Id: id, ContainerId: e.cid, Name: b.local, FullName: e.module + "." + b.local, Kind: "alias",
+				Visibility: vis, OriginFileId: e.fid, StartLine: ex.line, StartCol: ex.col,
+				EndLine: ex.line, EndCol: ex.col, DocRaw: ex.doc,
+			}
+			if ex.doc != "" {
+				sym.DocFmt = cleanComment(ex.doc)
+			}
+			sig := "export { " + b.name + " as " + b.local + " } from \"" + ex.from + "\""
+			r := ref{Type: "alias", Symbol: target + "." + b.name}
+			switch {
+			case b.name == "*":
+				sig = "export * as " + b.local + " from \"" + ex.from + "\""
+				r = ref{Type: "module", Name: target}
+			case b.name == b.local:
+				sig = "export { " + b.name + " } from \"" + ex.from + "\""
+			}
+			e.x.frag.Symbols = append(e.x.frag.Symbols, sym)
+			e.x.frag.Signatures = append(e.x.frag.Signatures, ir.Signature{SymbolId: id, Text: sig})
+			js, _ := json.Marshal(r)
+			e.x.frag.Typerefs = append(e.x.frag.Typerefs, ir.Typeref{Id: uuid.New(), OwnerSymbolId: id, Slot: "target", Json: string(js)})
+		}
+	}
+}
+
+// signature records the signature and type references of a declaration.
+// params holds the type parameters in scope.
+func (e *emitter) signature(id uuid.UUID, owner *uuid.UUID, d *decl, params map[string]bool) {
+	if d.sig == "" {
+		return
+	}
+	sig := ir.Signature{SymbolId: id, Text: d.sig}
+	type result struct {
+		Type string `json:"type"`
+	}
+	js := struct {
+		Params     []param     `json:"params,omitempty"`
+		Results    []result    `json:"results,omitempty"`
+		TypeParams []typeParam `json:"type_params,omitempty"`
+	}{TypeParams: d.typeParams}
+	switch d.kind {
+	case "function", "method", "constructor":
+		js.Params = d.params
+		if js.Params == nil {
+			js.Params = []param{}
+		}
+		if d.returns != "" {
+			js.Results = []result{{d.returns}}
+		}
+		for i, pr := range d.params {
+			e.typerefs(id, fmt.Sprintf("param:%d", i), pr.Type, "", params)
+		}
+		e.typerefs(id, "result:0", d.returns, "", params)
+	case "class", "interface":
+		kind := d.kind
+		for i, b := range d.extends {
+			e.typerefs(id, fmt.Sprintf("base:%d", i), b, kind, params)
+		}
+		for i, b := range d.implements {
+			e.typerefs(id, fmt.Sprintf("implements:%d", i), b, "interface", params)
+		}
+	case "field", "property":
+		if owner != nil {
+			e.typerefs(*owner, "field:"+d.name, d.typ, "", params)
+		}
+	default:
+		e.typerefs(id, "type", d.typ, "", params)
+	}
+	if b, _ := json.Marshal(js); string(b) != "{}" {
+		sig.Json = string(b)
+	}
+	e.x.frag.Signatures = append(e.x.frag.Signatures, sig)
+}
+
+func (e *emitter) typerefs(owner uuid.UUID, slot, typ, kind string, params map[string]bool) {
+	if typ == "" {
+		return
+	}
+	for i, r := range e.res.refs(typ, params) {
+		r.Type = kind
+		b, _ := json.Marshal(r)
+		e.x.frag.Typerefs = append(e.x.frag.Typerefs, ir.Typeref{Id: uuid.New(), OwnerSymbolId: owner, Slot: slot, Json: string(b), Order: i})
+	}
+}
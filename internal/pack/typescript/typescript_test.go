@@ -0,0 +1,383 @@
+package typescript
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/ChaseHampton/cargoworker/internal/ir"
+	"github.com/ChaseHampton/cargoworker/internal/pack/packtest"
+)
+
+var tree = map[string]string{
+	"package.json": `{
+  "name": "@acme/shapes",
+  "version": "2.1.0",
+  "description": "Shapes and areas.",
+  "exports": {
+    ".": {"types": "./dist/index.d.ts", "import": "./dist/index.js"},
+    "./util/*": "./dist/util/*.js",
+    "./package.json": "./package.json"
+  }
+}
+`,
+	"tsconfig.json": `{
+  // Build to dist.
+  "compilerOptions": {"outDir": "dist", "rootDir": "src",},
+}
+`,
+	"src/index.ts": `/**
+ * Shapes.
+ * @packageDocumentation
+ */
+export * from "./shapes.js";
+export { area as computeArea, type Unit } from "./area";
+import * as internal from "./internal";
+export { internal };
+`,
+	"src/shapes.ts": `import { EventEmitter } from "node:events";
+import type { Unit } from "./area";
+
+/**
+ * A shape.
+ *
+ * @typeParam U - The unit.
+ */
+export interface Shape<U extends Unit = Unit> {
+  /** The name. */
+  readonly name: string;
+  area(unit?: U): number;
+  [key: string]: unknown;
+}
+
+/** Kinds of shapes. */
+export const enum Kind {
+  Circle = 1,
+  Square,
+}
+
+/**
+ * A circle.
+ * @deprecated Use {@link Ellipse}.
+ */
+@sealed()
+export class Circle extends EventEmitter implements Shape, Iterable<number> {
+  static count = 0;
+  #radius: number;
+
+  constructor(private readonly r: number, public label?: string) {
+    super();
+  }
+
+  get radius(): number {
+    return this.#radius;
+  }
+  set radius(v: number) {
+    this.#radius = v;
+  }
+
+  protected area(unit?: Unit): number {
+    return Math.PI * this.r ** 2;
+  }
+
+  *[Symbol.iterator](): Iterator<number> {
+    yield this.r;
+  }
+}
+
+/**
+ * Scales a shape.
+ * @param s - The shape.
+ * @param by - The factor.
+ * @returns The scaled shape.
+ * @throws {RangeError} If by is negative.
+ */
+export function scale(s: Circle, by: number): Circle;
+export function scale(s: Shape, by: number): Shape;
+export function scale(s: Shape, by = 1): Shape {
+  return s;
+}
+
+export type Pair<T> = [T, T];
+
+const helper = (x: number) => x / 2;
+
+export const origin = { x: 0, y: 0 };
+
+export default class {}
+`,
+	"src/area.ts": `export type Unit = "cm" | "in";
+
+export const area = async <T,>(s: import("./shapes").Shape): Promise<number> => s.area();
+
+export namespace Units {
+  export const all: Unit[] = ["cm", "in"];
+  const hidden = 1;
+}
+`,
+	"src/internal.ts": `export function debug(): void {}
+`,
+	"src/secret.ts": `export const key = "k";
+`,
+	"src/util/math.ts": `export function clamp(n: number): number { return n; }
+`,
+	"legacy/old.js": `const fs = require("fs");
+
+/** Reads a file. */
+function read(p) {
+  return fs.readFileSync(p);
+}
+
+exports.write = function (p, data) {};
+module.exports.read = read;
+const el = <div className="x">{a / b}</div>;
+`,
+	"legacy/globals.js": `var VERSION = "1";
+function greet(name) { return "hi " + name; }
+`,
+	"src/shapes.d.ts": `export declare const ignored: number;
+`,
+}
+
+// tsLanguage gives the language ids of the tree's files, as the language
+// table does: by basename, then by extension.
+func tsLanguage(rel string) string {
+	switch path.Base(rel) {
+	case "package.json":
+		return "js"
+	case "tsconfig.json":
+		return "ts"
+	}
+	return map[string]string{".ts": "ts", ".js": "js"}[path.Ext(rel)]
+}
+
+func TestExtract(t *testing.T) {
+	f := packtest.ExtractTree(t, tree, tsLanguage)
+
+	containers := map[string]ir.Container{}
+	for _, c := range f.Containers {
+		containers[c.FullName] = c
+	}
+	pkg, index := containers["@acme/shapes"], containers["@acme/shapes/src/index"]
+	if pkg.Kind != "package" || pkg.ParentId != uuid.Nil || pkg.VersionTag != "2.1.0" || pkg.DocRaw != "Shapes and areas." ||
+		!strings.Contains(pkg.ExtraJson, `"entry_points":["@acme/shapes/src/index"]`) {
+		t.Errorf("package = %+v", pkg)
+	}
+	if index.Kind != "module" || index.ParentId != pkg.Id || index.Name != "index" || index.DocFmt != "Shapes." ||
+		!strings.Contains(index.ExtraJson, `"entry_point":true,"reexports":[{"from":"./shapes.js","module":"@acme/shapes/src/shapes"}`) {
+		t.Errorf("index = %+v", index)
+	}
+	for name, format := range map[string]string{
+		"@acme/shapes/legacy/old": "commonjs", "@acme/shapes/legacy/globals": "script", "@acme/shapes/src/area": "esm",
+	} {
+		if !strings.Contains(containers[name].ExtraJson, `"format":"`+format+`"`) {
+			t.Errorf("%s extra = %s, want format %s", name, containers[name].ExtraJson, format)
+		}
+	}
+	var paths []string
+	for _, fl := range f.Files {
+		paths = append(paths, fl.Path)
+	}
+	if got := strings.Join(paths, " "); strings.Contains(got, "shapes.d.ts") || strings.Contains(got, ".json") || !strings.Contains(got, "src/index.ts") {
+		t.Errorf("files = %s", got)
+	}
+
+	syms := map[string]ir.Symbol{}
+	for _, s := range f.Symbols {
+		name := strings.TrimPrefix(s.FullName, "@acme/shapes/")
+		if _, dup := syms[name]; dup {
+			t.Errorf("duplicate symbol %s", name)
+		}
+		syms[name] = s
+	}
+	for name, want := range map[string]string{
+		"src/shapes.Shape":                    "interface public",
+		"src/shapes.Shape.name":               "field public",
+		"src/shapes.Shape.area":               "method public",
+		"src/shapes.Kind":                     "enum public",
+		"src/shapes.Kind.Square":              "constant public",
+		"src/shapes.Circle":                   "class public",
+		"src/shapes.Circle.#radius":           "field private",
+		"src/shapes.Circle.constructor":       "constructor public",
+		"src/shapes.Circle.r":                 "field private",
+		"src/shapes.Circle.label":             "field public",
+		"src/shapes.Circle.radius":            "property public",
+		"src/shapes.Circle.area":              "method protected",
+		"src/shapes.Circle.[Symbol.iterator]": "method public",
+		"src/shapes.scale":                    "function public",
+		"src/shapes.Pair":                     "type public",
+		"src/shapes.helper":                   "function private",
+		"src/shapes.default":                  "class public",
+		"src/shapes.ignored":                  " ",
+		"src/area.area":                       "function public",
+		"src/area.Units":                      "namespace internal",
+		"src/area.Units.all":                  "constant internal",
+		"src/area.Units.hidden":               "constant private",
+		"src/index.computeArea":               "alias public",
+		"src/index.internal":                  "alias public",
+		"src/internal.debug":                  "function public",
+		"src/secret.key":                      "constant internal",
+		"src/util/math.clamp":                 "function public",
+		"legacy/old.read":                     "function internal",
+		"legacy/old.write":                    "function internal",
+		"legacy/old.el":                       "constant private",
+		"legacy/globals.greet":                "function public",
+	} {
+		if got := syms[name].Kind + " " + syms[name].Visibility; got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+
+	circle := syms["src/shapes.Circle"]
+	if circle.StartLine != 27 || circle.StartCol != 14 || circle.EndLine != 49 {
+		t.Errorf("Circle span = %d:%d-%d", circle.StartLine, circle.StartCol, circle.EndLine)
+	}
+	var ex extra
+	if err := json.Unmarshal([]byte(circle.ExtraJson), &ex); err != nil {
+		t.Fatal(err)
+	}
+	if len(ex.Decorators) != 1 || ex.Decorators[0] != "sealed()" || ex.Doc == nil || ex.Doc.Deprecated == nil || *ex.Doc.Deprecated != "Use {@link Ellipse}." {
+		t.Errorf("Circle extra = %s", circle.ExtraJson)
+	}
+
+	scale := syms["src/shapes.scale"]
+	ex = extra{}
+	if err := json.Unmarshal([]byte(scale.ExtraJson), &ex); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(ex.Overloads, "|"); got != "function scale(s: Circle, by: number): Circle|function scale(s: Shape, by: number): Shape" {
+		t.Errorf("scale overloads = %s", got)
+	}
+	if d := ex.Doc; d == nil || d.Summary != "Scales a shape." || len(d.Params) != 2 || d.Params[1] != (docParam{Name: "by", Desc: "The factor."}) ||
+		d.Returns == nil || d.Returns.Desc != "The scaled shape." || len(d.Throws) != 1 || d.Throws[0].Type != "RangeError" {
+		t.Errorf("scale doc = %s", scale.ExtraJson)
+	}
+	if scale.StartLine != 60 || !strings.HasPrefix(scale.DocFmt, "Scales a shape.\n@param s - The shape.") {
+		t.Errorf("scale = %+v", scale)
+	}
+
+	sigs := map[uuid.UUID]ir.Signature{}
+	for _, s := range f.Signatures {
+		sigs[s.SymbolId] = s
+	}
+	for name, want := range map[string]string{
+		"src/shapes.scale":         "function scale(s: Shape, by = 1): Shape",
+		"src/shapes.Shape":         "interface Shape<U extends Unit = Unit>",
+		"src/shapes.Circle":        "class Circle extends EventEmitter implements Shape, Iterable<number>",
+		"src/shapes.Circle.area":   "protected area(unit?: Unit): number",
+		"src/shapes.Circle.radius": "get radius(): number",
+		"src/shapes.Pair":          "type Pair<T> = [T, T]",
+		"src/area.area":            `const area = async <T,>(s: import("./shapes").Shape): Promise<number> =>`,
+		"src/index.computeArea":    `export { area as computeArea } from "./area"`,
+		"legacy/old.write":         "function write(p, data)",
+	} {
+		if got := sigs[syms[name].Id].Text; got != want {
+			t.Errorf("%s sig = %s, want %s", name, got, want)
+		}
+	}
+	if got, want := sigs[scale.Id].Json, `{"params":[{"name":"s","type":"Shape"},{"name":"by","default":"1"}],"results":[{"type":"Shape"}]}`; got != want {
+		t.Errorf("scale sig json = %s\nwant %s", got, want)
+	}
+	if got, want := sigs[syms["src/shapes.Shape"].Id].Json, `{"type_params":[{"name":"U","constraint":"Unit","default":"Unit"}]}`; got != want {
+		t.Errorf("Shape sig json = %s, want %s", got, want)
+	}
+
+	owners := map[uuid.UUID]string{}
+	for name, s := range syms {
+		owners[s.Id] = name
+	}
+	refs := map[string][]string{}
+	for _, tr := range f.Typerefs {
+		key := owners[tr.OwnerSymbolId] + " " + tr.Slot
+		refs[key] = append(refs[key], tr.Json)
+	}
+	for key, want := range map[string]string{
+		"src/shapes.Circle base:0":       `{"symbol":"node:events.EventEmitter","type":"class","text":"EventEmitter"}`,
+		"src/shapes.Circle implements:0": `{"symbol":"@acme/shapes/src/shapes.Shape","type":"interface","text":"Shape"}`,
+		"src/shapes.Circle implements:1": "",
+		"src/shapes.Shape.area param:0":  "", // a type parameter
+		"src/shapes.Circle.area param:0": `{"symbol":"@acme/shapes/src/area.Unit","text":"Unit"}`,
+		"src/area.area param:0":          `{"symbol":"@acme/shapes/src/shapes.Shape","text":"import(\"./shapes\").Shape"}`,
+		"src/area.Units.all type":        `{"symbol":"@acme/shapes/src/area.Unit","text":"Unit[]"}`,
+		"src/index.computeArea target":   `{"symbol":"@acme/shapes/src/area.area","type":"alias"}`,
+		"src/index.internal target":      `{"name":"@acme/shapes/src/internal","type":"module"}`,
+	} {
+		if got := strings.Join(refs[key], " "); got != want {
+			t.Errorf("typerefs %s = %s, want %s", key, got, want)
+		}
+	}
+
+	imports := map[string]ir.Import{}
+	for _, im := range f.Imports {
+		imports[im.DetailsJson[:strings.Index(im.DetailsJson, ",")]+" "+im.Target+" "+im.Alias] = im
+	}
+	for key, details := range map[string]string{
+		`{"file":"legacy/old.js" fs `:                               `{"file":"legacy/old.js","line":1,"kind":"require","stdlib":true}`,
+		`{"file":"src/shapes.ts" node:events `:                      `{"file":"src/shapes.ts","line":1,"names":["EventEmitter"],"stdlib":true}`,
+		`{"file":"src/shapes.ts" @acme/shapes/src/area `:            `{"file":"src/shapes.ts","line":2,"names":["Unit"],"type_only":true}`,
+		`{"file":"src/index.ts" @acme/shapes/src/area `:             `{"file":"src/index.ts","line":6,"names":["area","Unit"],"kind":"reexport"}`,
+		`{"file":"src/index.ts" @acme/shapes/src/internal internal`: `{"file":"src/index.ts","line":7}`,
+		`{"file":"src/area.ts" @acme/shapes/src/shapes `:            `{"file":"src/area.ts","line":3,"kind":"dynamic"}`,
+	} {
+		if got := imports[key].DetailsJson; got != details {
+			t.Errorf("import %q = %s, want %s", key, got, details)
+		}
+	}
+}
+
+func TestLex(t *testing.T) {
+	src := "const a = b / c / d, r = /[/]\\//g;\n" +
+		"const s = `x${ {a: `y${1}`}.a }z`; /** Doc. */\n" +
+		"const el = <A b={x > 1}>{c}</A>, lt = a < b;\n"
+	var got []string
+	for _, tok := range lex(src, true) {
+		switch tok.Kind {
+		case tRegex, tTemplate, tJSX:
+			got = append(got, tok.Text)
+		}
+		if tok.Doc != "" {
+			got = append(got, tok.Text+" "+tok.Doc)
+		}
+	}
+	want := []string{"/[/]\\//g", "`x${ {a: `y${1}`}.a }z`", "const /** Doc. */", "<A b={x > 1}>{c}</A>"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("tokens = %q, want %q", got, want)
+	}
+}
+
+func TestEntryPoints(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{"main", map[string]string{"package.json": `{"name": "a", "main": "lib/index.js"}`, "src/index.ts": ""}, "src/index.ts"},
+		{"outDir", map[string]string{
+			"package.json":   `{"name": "a", "exports": {"require": "./out/main.cjs", "import": "./out/main.mjs"}}`,
+			"tsconfig.json":  `{"compilerOptions": {"outDir": "./out", "rootDir": "./source"}}`,
+			"source/main.ts": "",
+		}, "source/main.ts"},
+		{"plain js", map[string]string{"package.json": `{"name": "a", "exports": "./index.js"}`, "index.js": ""}, "index.js"},
+		{"default", map[string]string{"package.json": `{"name": "a"}`, "index.mjs": ""}, "index.mjs"},
+	} {
+		root := t.TempDir()
+		for rel, src := range tc.files {
+			p := filepath.Join(root, filepath.FromSlash(rel))
+			if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(p, []byte(src), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		proj := newLayout(root).project(".")
+		if got := strings.Join(proj.entries, " "); got != tc.want {
+			t.Errorf("%s: entries = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
@@ -40,6 +40,9 @@ func (r *Runner) Plan(ctx context.Context) (*stats.PlanSnapshot, error) {
 	ig, err := ignore.CompileIgnoreFileAndLines(filepath.Join(in, ".gitignore"), r.RunPlan.Snapshot().ExcludeGlobs...)
 	if err != nil {
 		rc.Logger.Info("no .gitignore found or could not be read", "error", err.Error())
+		ig = ignore.CompileIgnoreLines(r.RunPlan.Snapshot().ExcludeGlobs...)
+	} else {
+		r.RunPlan.SetGitIgnoreFound(true)
 	}
 
 	metas := []project.FileMeta{}
@@ -73,6 +76,7 @@ func (r *Runner) Plan(ctx context.Context) (*stats.PlanSnapshot, error) {
 	if err != nil {
 		return r.RunPlan.Snapshot(), fmt.Errorf("internal: planRunner: failed to walk input path: %w", err)
 	}
+	rc.PlanContext = &project.PlanContext{Files: metas}
 
 	return r.RunPlan.Snapshot(), nil
 }
@@ -37,6 +37,7 @@ type ctxKey int
 const (
 	ctxKeyRunContext ctxKey = iota
 	ctxKeyInputPath
+	ctxKeyDBPath
 )
 
 func WithRunContext(ctx context.Context, rc *RunContext) context.Context {
@@ -64,3 +65,18 @@ func InputPathFrom(ctx context.Context) string {
 	}
 	return ""
 }
+
+// WithDBPath records the database file opened by commands that inspect an
+// existing run rather than creating one.
+func WithDBPath(ctx context.Context, path string) context.Context {
+	return context.WithValue(ctx, ctxKeyDBPath, path)
+}
+
+func DBPathFrom(ctx context.Context) string {
+	if v := ctx.Value(ctxKeyDBPath); v != nil {
+		if s, ok := v.(string); ok {
+			return s
+		}
+	}
+	return ""
+}
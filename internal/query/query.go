@@ -0,0 +1,421 @@
+// Package query answers the read-only lookups shared by the servers over a
+// run database: containers, symbols with their relations, and files. Ids are
+// row ids, the same ids search.Hit reports; ResolveID also accepts uids.
+package query
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrNotFound is returned when an id names no row.
+var ErrNotFound = errors.New("not found")
+
+// Page bounds a list query.
+type Page struct {
+	Limit  int
+	Offset int
+}
+
+const (
+	DefaultLimit = 50
+	MaxLimit     = 500
+)
+
+// Clamp applies the default and maximum limit and rejects negative offsets.
+func (p Page) Clamp() Page {
+	if p.Limit <= 0 {
+		p.Limit = DefaultLimit
+	}
+	p.Limit = min(p.Limit, MaxLimit)
+	p.Offset = max(p.Offset, 0)
+	return p
+}
+
+type Container struct {
+	ID       int64  `json:"id"`
+	UID      string `json:"uid,omitempty"`
+	ParentID int64  `json:"parent_id,omitempty"`
+	Name     string `json:"name"`
+	FullName string `json:"full_name"`
+	Kind     string `json:"kind,omitempty"`
+	Language string `json:"language,omitempty"`
+	Version  string `json:"version,omitempty"`
+	Doc      string `json:"doc,omitempty"`
+	Files    int    `json:"files"`
+	Symbols  int    `json:"symbols"`
+}
+
+// SymbolRef is the summary of a symbol used in lists and cross-references.
+type SymbolRef struct {
+	ID        int64  `json:"id"`
+	UID       string `json:"uid,omitempty"`
+	Name      string `json:"name"`
+	FullName  string `json:"full_name,omitempty"`
+	Kind      string `json:"kind"`
+	Container string `json:"container"`
+	File      string `json:"file,omitempty"`
+	Line      int    `json:"line,omitempty"`
+}
+
+type Symbol struct {
+	SymbolRef
+	ContainerID int64       `json:"container_id"`
+	FileID      int64       `json:"file_id,omitempty"`
+	Visibility  string      `json:"visibility,omitempty"`
+	Flags       int         `json:"flags,omitempty"`
+	Col         int         `json:"col,omitempty"`
+	EndLine     int         `json:"end_line,omitempty"`
+	EndCol      int         `json:"end_col,omitempty"`
+	Doc         string      `json:"doc,omitempty"`
+	Signature   string      `json:"signature,omitempty"`
+	Owner       *SymbolRef  `json:"owner,omitempty"`
+	Members     []SymbolRef `json:"members"`
+}
+
+// Relation directions, seen from the symbol whose relations are listed.
+const (
+	Out  = "out"
+	In   = "in"
+	Both = "both"
+)
+
+type Relation struct {
+	Kind      string          `json:"kind"`
+	Direction string          `json:"direction"`
+	Symbol    SymbolRef       `json:"symbol"` // the other end
+	Details   json.RawMessage `json:"details,omitempty"`
+}
+
+type File struct {
+	ID          int64       `json:"id"`
+	UID         string      `json:"uid,omitempty"`
+	Path        string      `json:"path"`
+	Language    string      `json:"language,omitempty"`
+	Checksum    string      `json:"checksum,omitempty"`
+	SizeBytes   int64       `json:"size_bytes"`
+	ContainerID int64       `json:"container_id,omitempty"`
+	Container   string      `json:"container,omitempty"`
+	Symbols     []SymbolRef `json:"symbols"`
+}
+
+// ResolveID maps a path parameter to a row id of table: a decimal row id, or
+// the uid of a row. It returns ErrNotFound when no row has it.
+func ResolveID(ctx context.Context, rdb *sql.DB, table, ref string) (int64, error) {
+	switch table {
+	case "package", "symbol", "file":
+	default:
+		return 0, fmt.Errorf("query: cannot resolve ids of %s", table)
+	}
+	q, arg := `SELECT id FROM `+table+` WHERE uid = ? LIMIT 1;`, any(ref)
+	if n, err := strconv.ParseInt(ref, 10, 64); err == nil {
+		q, arg = `SELECT id FROM `+table+` WHERE id = ?;`, n
+	}
+	var id int64
+	err := rdb.QueryRowContext(ctx, q, arg).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrNotFound
+	}
+	return id, err
+}
+
+type ContainerFilter struct {
+	Kind     string
+	Language string
+	Prefix   string // full name prefix
+}
+
+// Containers lists containers by full name.
+func Containers(ctx context.Context, rdb *sql.DB, f ContainerFilter, page Page) ([]Container, error) {
+	page = page.Clamp()
+	var (
+		where []string
+		args  []any
+	)
+	if f.Kind != "" {
+		where = append(where, "p.kind = ?")
+		args = append(args, f.Kind)
+	}
+	if f.Language != "" {
+		where = append(where, "p.language = ?")
+		args = append(args, f.Language)
+	}
+	if f.Prefix != "" {
+		where = append(where, "substr(p.import_path, 1, length(?)) = ?")
+		args = append(args, f.Prefix, f.Prefix)
+	}
+	cond := ""
+	if len(where) > 0 {
+		cond = "WHERE " + strings.Join(where, " AND ")
+	}
+	args = append(args, page.Limit, page.Offset)
+
+	rows, err := rdb.QueryContext(ctx, `
+		SELECT `+containerCols+`
+		FROM package p
+		`+cond+`
+		ORDER BY p.import_path, p.id
+		LIMIT ? OFFSET ?;`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []Container{}
+	for rows.Next() {
+		var c Container
+		if err := scanContainer(rows, &c); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+const containerCols = `p.id, COALESCE(p.uid, ''), COALESCE(p.parent_id, 0), p.name, p.import_path,
+	COALESCE(p.kind, ''), COALESCE(p.language, ''), COALESCE(p.version_tag, ''),
+	COALESCE(NULLIF(p.doc_fmt, ''), p.doc, ''),
+	(SELECT count(*) FROM file f WHERE f.package_id = p.id),
+	(SELECT count(*) FROM symbol s WHERE s.package_id = p.id)`
+
+func scanContainer(sc interface{ Scan(...any) error }, c *Container) error {
+	return sc.Scan(&c.ID, &c.UID, &c.ParentID, &c.Name, &c.FullName, &c.Kind, &c.Language,
+		&c.Version, &c.Doc, &c.Files, &c.Symbols)
+}
+
+// GetContainer returns one container.
+func GetContainer(ctx context.Context, rdb *sql.DB, id int64) (*Container, error) {
+	var c Container
+	err := scanContainer(rdb.QueryRowContext(ctx, `SELECT `+containerCols+` FROM package p WHERE p.id = ?;`, id), &c)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// ContainerSymbols lists the top-level symbols of container id, those no
+// other symbol has as a member, by name; kind optionally filters them.
+func ContainerSymbols(ctx context.Context, rdb *sql.DB, id int64, kind string, page Page) ([]SymbolRef, error) {
+	page = page.Clamp()
+	if err := exists(ctx, rdb, "package", id); err != nil {
+		return nil, err
+	}
+	cond, args := "", []any{id}
+	if kind != "" {
+		cond, args = " AND s.kind = ?", append(args, kind)
+	}
+	return refs(ctx, rdb, `
+		SELECT `+refCols+`
+		FROM symbol s `+refJoins+`
+		WHERE s.package_id = ?`+cond+`
+		  AND NOT EXISTS (SELECT 1 FROM member m WHERE m.child_symbol_id = s.id)
+		ORDER BY s.name, s.id
+		LIMIT ? OFFSET ?;`, append(args, page.Limit, page.Offset)...)
+}
+
+// refCols selects a SymbolRef from symbol s joined with package p and file f.
+const (
+	refCols  = `s.id, COALESCE(s.uid, ''), s.name, COALESCE(s.full_name, ''), s.kind, p.import_path, COALESCE(f.rel_path, ''), COALESCE(s.line, 0)`
+	refJoins = `JOIN package p ON p.id = s.package_id LEFT JOIN file f ON f.id = s.file_id`
+)
+
+func scanRef(sc interface{ Scan(...any) error }, r *SymbolRef, extra ...any) error {
+	return sc.Scan(append([]any{&r.ID, &r.UID, &r.Name, &r.FullName, &r.Kind, &r.Container, &r.File, &r.Line}, extra...)...)
+}
+
+func refs(ctx context.Context, rdb *sql.DB, q string, args ...any) ([]SymbolRef, error) {
+	rows, err := rdb.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []SymbolRef{}
+	for rows.Next() {
+		var r SymbolRef
+		if err := scanRef(rows, &r); err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+// GetSymbol returns a symbol with its signature, owner and members.
+func GetSymbol(ctx context.Context, rdb *sql.DB, id int64) (*Symbol, error) {
+	var s Symbol
+	err := scanRef(rdb.QueryRowContext(ctx, `
+		SELECT `+refCols+`, s.package_id, COALESCE(s.file_id, 0), COALESCE(s.visibility, ''), s.flags,
+		       COALESCE(s.col, 0), COALESCE(s.end_line, 0), COALESCE(s.end_col, 0), COALESCE(s.doc, ''),
+		       COALESCE((SELECT g.text FROM signature g WHERE g.symbol_id = s.id ORDER BY g.id LIMIT 1), '')
+		FROM symbol s `+refJoins+`
+		WHERE s.id = ?;`, id), &s.SymbolRef,
+		&s.ContainerID, &s.FileID, &s.Visibility, &s.Flags, &s.Col, &s.EndLine, &s.EndCol, &s.Doc, &s.Signature)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	owners, err := refs(ctx, rdb, `
+		SELECT `+refCols+`
+		FROM member m JOIN symbol s ON s.id = m.parent_symbol_id `+refJoins+`
+		WHERE m.child_symbol_id = ?
+		ORDER BY m.id LIMIT 1;`, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(owners) > 0 {
+		s.Owner = &owners[0]
+	}
+	s.Members, err = refs(ctx, rdb, `
+		SELECT `+refCols+`
+		FROM member m JOIN symbol s ON s.id = m.child_symbol_id `+refJoins+`
+		WHERE m.parent_symbol_id = ?
+		ORDER BY m.ord, s.name, s.id;`, id)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Relations lists the relations of symbol id in dir (Out, In or Both),
+// optionally of one kind: outgoing first, then by kind and the other
+// symbol's name. It returns ErrNotFound when the symbol does not exist.
+func Relations(ctx context.Context, rdb *sql.DB, id int64, dir, kind string, page Page) ([]Relation, error) {
+	page = page.Clamp()
+	if err := exists(ctx, rdb, "symbol", id); err != nil {
+		return nil, err
+	}
+	var parts []string
+	var args []any
+	side := func(direction, self, other string) {
+		q := `SELECT '` + direction + `', r.kind, COALESCE(r.detail, ''), ` + refCols + `
+			FROM relation r JOIN symbol s ON s.id = r.` + other + ` ` + refJoins + `
+			WHERE r.` + self + ` = ?`
+		args = append(args, id)
+		if kind != "" {
+			q += ` AND r.kind = ?`
+			args = append(args, kind)
+		}
+		parts = append(parts, q)
+	}
+	switch dir {
+	case Out:
+		side(Out, "from_symbol_id", "to_symbol_id")
+	case In:
+		side(In, "to_symbol_id", "from_symbol_id")
+	case Both, "":
+		side(Out, "from_symbol_id", "to_symbol_id")
+		side(In, "to_symbol_id", "from_symbol_id")
+	default:
+		return nil, fmt.Errorf("query: unknown direction %q (want out|in|both)", dir)
+	}
+	args = append(args, page.Limit, page.Offset)
+
+	// Columns: direction, kind, detail, then the SymbolRef (id, uid, name, ...).
+	rows, err := rdb.QueryContext(ctx, strings.Join(parts, " UNION ALL ")+`
+		ORDER BY 1 DESC, 2, 6, 4
+		LIMIT ? OFFSET ?;`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []Relation{}
+	for rows.Next() {
+		var (
+			r      Relation
+			detail string
+		)
+		if err := rows.Scan(&r.Direction, &r.Kind, &detail,
+			&r.Symbol.ID, &r.Symbol.UID, &r.Symbol.Name, &r.Symbol.FullName, &r.Symbol.Kind,
+			&r.Symbol.Container, &r.Symbol.File, &r.Symbol.Line); err != nil {
+			return nil, err
+		}
+		if json.Valid([]byte(detail)) {
+			r.Details = json.RawMessage(detail)
+		} else if detail != "" {
+			r.Details, _ = json.Marshal(detail)
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+// Implementation is a symbol implementing another, with the relation kind
+// that says so.
+type Implementation struct {
+	SymbolRef
+	Relation string `json:"relation"`
+}
+
+// Implementations lists the symbols with an "implements", "satisfies" or
+// "overrides" relation to symbol id, by container and name. It returns
+// ErrNotFound when the symbol does not exist.
+func Implementations(ctx context.Context, rdb *sql.DB, id int64, page Page) ([]Implementation, error) {
+	page = page.Clamp()
+	if err := exists(ctx, rdb, "symbol", id); err != nil {
+		return nil, err
+	}
+	rows, err := rdb.QueryContext(ctx, `
+		SELECT `+refCols+`, r.kind
+		FROM relation r JOIN symbol s ON s.id = r.from_symbol_id `+refJoins+`
+		WHERE r.to_symbol_id = ? AND r.kind IN ('implements', 'satisfies', 'overrides')
+		ORDER BY p.import_path, s.name, s.id, r.kind
+		LIMIT ? OFFSET ?;`, id, page.Limit, page.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []Implementation{}
+	for rows.Next() {
+		var im Implementation
+		if err := scanRef(rows, &im.SymbolRef, &im.Relation); err != nil {
+			return nil, err
+		}
+		out = append(out, im)
+	}
+	return out, rows.Err()
+}
+
+// GetFile returns a file with the symbols declared in it, by line.
+func GetFile(ctx context.Context, rdb *sql.DB, id int64) (*File, error) {
+	var f File
+	err := rdb.QueryRowContext(ctx, `
+		SELECT f.id, COALESCE(f.uid, ''), f.rel_path, COALESCE(f.language, ''), COALESCE(f.digest, ''),
+		       f.size_bytes, COALESCE(f.package_id, 0), COALESCE(p.import_path, '')
+		FROM file f LEFT JOIN package p ON p.id = f.package_id
+		WHERE f.id = ?;`, id).Scan(&f.ID, &f.UID, &f.Path, &f.Language, &f.Checksum,
+		&f.SizeBytes, &f.ContainerID, &f.Container)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	f.Symbols, err = refs(ctx, rdb, `
+		SELECT `+refCols+`
+		FROM symbol s `+refJoins+`
+		WHERE s.file_id = ?
+		ORDER BY COALESCE(s.line, 0), COALESCE(s.col, 0), s.id;`, id)
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+func exists(ctx context.Context, rdb *sql.DB, table string, id int64) error {
+	var one int
+	err := rdb.QueryRowContext(ctx, `SELECT 1 FROM `+table+` WHERE id = ?;`, id).Scan(&one)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
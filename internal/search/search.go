@@ -0,0 +1,294 @@
+package search
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ChaseHampton/cargoworker/internal/ident"
+)
+
+type Mode string
+
+const (
+	// ModeFTS ranks full-text matches over name, package, kind and doc.
+	ModeFTS Mode = "fts"
+	// ModePrefix matches symbol names starting with the query.
+	ModePrefix Mode = "prefix"
+	// ModeExact matches symbol names equal to the query.
+	ModeExact Mode = "exact"
+	// ModeSubstring matches names containing the query anywhere, through the
+	// trigram index.
+	ModeSubstring Mode = "substring"
+)
+
+func ParseMode(s string) (Mode, error) {
+	switch m := Mode(strings.ToLower(strings.TrimSpace(s))); m {
+	case ModeFTS, "":
+		return ModeFTS, nil
+	case ModePrefix, ModeExact, ModeSubstring:
+		return m, nil
+	default:
+		return "", fmt.Errorf("search: unknown mode %q (want fts|prefix|exact|substring)", s)
+	}
+}
+
+// Column weights for bm25 over search_fts(name, terms, pkg, kind, doc): a hit
+// in the name or its split words outranks the same hit in the package path,
+// which outranks the doc.
+const (
+	weightName  = 10.0
+	weightTerms = 8.0
+	weightPkg   = 2.0
+	weightKind  = 1.0
+	weightDoc   = 1.0
+)
+
+const defaultLimit = 20
+
+type Query struct {
+	Text  string
+	Mode  Mode
+	Kind  string // symbol kind filter
+	Pkg   string // package import path; '*' and '?' glob
+	Lang  string // container language
+	Limit int
+	// Offset skips that many hits for pagination.
+	Offset int
+	// Raw passes Text to FTS5 MATCH verbatim instead of quoting each term.
+	Raw bool
+	// Mark wraps matched text in highlights and snippets. Defaults to [ and ].
+	Mark [2]string
+}
+
+type Hit struct {
+	SymbolID  int64   `json:"symbol_id"`
+	Name      string  `json:"name"`
+	FullName  string  `json:"full_name,omitempty"`
+	Kind      string  `json:"kind"`
+	Pkg       string  `json:"pkg"`
+	Lang      string  `json:"lang,omitempty"`
+	File      string  `json:"file,omitempty"`
+	Line      int     `json:"line,omitempty"`
+	Score     float64 `json:"score"`               // lower is better (bm25)
+	Highlight string  `json:"highlight,omitempty"` // name with matches marked
+	Snippet   string  `json:"snippet,omitempty"`   // doc excerpt with matches marked
+}
+
+// Search runs q against the run database.
+func Search(ctx context.Context, rdb *sql.DB, q Query) ([]Hit, error) {
+	q.Text = strings.TrimSpace(q.Text)
+	if q.Text == "" {
+		return nil, fmt.Errorf("search: empty query")
+	}
+	if q.Limit <= 0 {
+		q.Limit = defaultLimit
+	}
+	if q.Mark == [2]string{} {
+		q.Mark = [2]string{"[", "]"}
+	}
+
+	switch q.Mode {
+	case ModeFTS, "":
+		return searchFTS(ctx, rdb, q)
+	case ModePrefix, ModeExact:
+		return searchName(ctx, rdb, q)
+	case ModeSubstring:
+		return searchSubstring(ctx, rdb, q)
+	default:
+		return nil, fmt.Errorf("search: unknown mode %q", q.Mode)
+	}
+}
+
+// filters returns the shared kind/pkg/lang predicates over s (symbol) and
+// p (package).
+func filters(q Query) (string, []any) {
+	var (
+		where []string
+		args  []any
+	)
+	if q.Kind != "" {
+		where = append(where, "s.kind = ?")
+		args = append(args, q.Kind)
+	}
+	if q.Pkg != "" {
+		if strings.ContainsAny(q.Pkg, "*?[") {
+			where = append(where, "p.import_path GLOB ?")
+		} else {
+			where = append(where, "p.import_path = ?")
+		}
+		args = append(args, q.Pkg)
+	}
+	if q.Lang != "" {
+		where = append(where, "p.language = ?")
+		args = append(args, q.Lang)
+	}
+	if len(where) == 0 {
+		return "", nil
+	}
+	return " AND " + strings.Join(where, " AND "), args
+}
+
+func searchFTS(ctx context.Context, rdb *sql.DB, q Query) ([]Hit, error) {
+	match := q.Text
+	if !q.Raw {
+		match = matchExpr(q.Text)
+	}
+	where, fargs := filters(q)
+
+	// Names equal to the query come first (exact case, then any case), the
+	// rest by bm25.
+	stmt := fmt.Sprintf(`
+		SELECT s.id, s.name, COALESCE(s.full_name, ''), s.kind, p.import_path,
+		       COALESCE(p.language, ''), COALESCE(f.rel_path, ''), COALESCE(s.line, 0),
+		       bm25(search_fts, %g, %g, %g, %g, %g) AS score,
+		       highlight(search_fts, 0, ?, ?),
+		       snippet(search_fts, 4, ?, ?, '…', 12)
+		FROM search_fts
+		JOIN symbol s ON s.id = search_fts.rowid
+		JOIN package p ON p.id = s.package_id
+		LEFT JOIN file f ON f.id = s.file_id
+		WHERE search_fts MATCH ?%s
+		ORDER BY s.name = ? DESC, s.name = ? COLLATE NOCASE DESC, score, s.id
+		LIMIT ? OFFSET ?;`, weightName, weightTerms, weightPkg, weightKind, weightDoc, where)
+
+	args := []any{q.Mark[0], q.Mark[1], q.Mark[0], q.Mark[1], match}
+	args = append(args, fargs...)
+	args = append(args, q.Text, q.Text, q.Limit, q.Offset)
+	return scanHits(ctx, rdb, stmt, args...)
+}
+
+// searchName resolves names through idx_symbol_pkg_name: the planner walks
+// the (small) package table and probes (package_id, name) for each row.
+func searchName(ctx context.Context, rdb *sql.DB, q Query) ([]Hit, error) {
+	where, fargs := filters(q)
+
+	var (
+		nameCond string
+		nameArgs []any
+		order    string
+	)
+	if q.Mode == ModeExact {
+		nameCond = "s.name = ?"
+		nameArgs = []any{q.Text}
+		order = "p.import_path, s.id"
+	} else {
+		nameCond = "s.name >= ? AND s.name < ?"
+		nameArgs = []any{q.Text, prefixUpper(q.Text)}
+		order = "length(s.name), s.name, p.import_path, s.id"
+	}
+
+	stmt := fmt.Sprintf(`
+		SELECT s.id, s.name, COALESCE(s.full_name, ''), s.kind, p.import_path,
+		       COALESCE(p.language, ''), COALESCE(f.rel_path, ''), COALESCE(s.line, 0),
+		       0.0 AS score,
+		       ? || substr(s.name, 1, ?) || ? || substr(s.name, ? + 1),
+		       substr(COALESCE(s.doc, ''), 1, 160)
+		FROM package p
+		JOIN symbol s INDEXED BY idx_symbol_pkg_name ON s.package_id = p.id AND %s
+		LEFT JOIN file f ON f.id = s.file_id
+		WHERE 1 = 1%s
+		ORDER BY %s
+		LIMIT ? OFFSET ?;`, nameCond, where, order)
+
+	n := len([]rune(q.Text))
+	args := []any{q.Mark[0], n, q.Mark[1], n}
+	args = append(args, nameArgs...)
+	args = append(args, fargs...)
+	args = append(args, q.Limit, q.Offset)
+	return scanHits(ctx, rdb, stmt, args...)
+}
+
+// searchSubstring matches names through the search_trigram index. Trigrams
+// need at least three characters; shorter queries fall back to a LIKE scan.
+func searchSubstring(ctx context.Context, rdb *sql.DB, q Query) ([]Hit, error) {
+	where, fargs := filters(q)
+
+	var (
+		from, cond, mark string
+		args             []any
+	)
+	if utf8.RuneCountInString(q.Text) >= 3 {
+		from = "search_trigram JOIN symbol s ON s.id = search_trigram.rowid"
+		cond = "search_trigram MATCH ?"
+		mark = "highlight(search_trigram, 0, ?, ?)"
+		args = []any{q.Mark[0], q.Mark[1], `"` + strings.ReplaceAll(q.Text, `"`, `""`) + `"`}
+	} else {
+		from = "symbol s"
+		cond = `s.name LIKE '%' || ? || '%' ESCAPE '\'`
+		mark = "s.name"
+		args = []any{likeEscape(q.Text)}
+	}
+
+	stmt := fmt.Sprintf(`
+		SELECT s.id, s.name, COALESCE(s.full_name, ''), s.kind, p.import_path,
+		       COALESCE(p.language, ''), COALESCE(f.rel_path, ''), COALESCE(s.line, 0),
+		       0.0 AS score,
+		       %s,
+		       substr(COALESCE(s.doc, ''), 1, 160)
+		FROM %s
+		JOIN package p ON p.id = s.package_id
+		LEFT JOIN file f ON f.id = s.file_id
+		WHERE %s%s
+		ORDER BY s.name = ? DESC, s.name = ? COLLATE NOCASE DESC, length(s.name), s.name, p.import_path, s.id
+		LIMIT ? OFFSET ?;`, mark, from, cond, where)
+
+	args = append(args, fargs...)
+	args = append(args, q.Text, q.Text, q.Limit, q.Offset)
+	return scanHits(ctx, rdb, stmt, args...)
+}
+
+func likeEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+func scanHits(ctx context.Context, rdb *sql.DB, stmt string, args ...any) ([]Hit, error) {
+	rows, err := rdb.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, fmt.Errorf("search: %w", err)
+	}
+	defer rows.Close()
+
+	hits := []Hit{}
+	for rows.Next() {
+		var h Hit
+		if err := rows.Scan(&h.SymbolID, &h.Name, &h.FullName, &h.Kind, &h.Pkg, &h.Lang,
+			&h.File, &h.Line, &h.Score, &h.Highlight, &h.Snippet); err != nil {
+			return nil, err
+		}
+		hits = append(hits, h)
+	}
+	return hits, rows.Err()
+}
+
+// matchExpr turns free text into an FTS5 expression. The query is split into
+// identifier words the same way names are split into the terms column, so
+// "ParseHTTP" and "http request" both find ParseHTTPRequest. Every term is
+// quoted so punctuation ("io.Reader", "-v") is not parsed as syntax, and the
+// last term matches as a prefix while the user is still typing.
+func matchExpr(text string) string {
+	terms := ident.Split(text)
+	if len(terms) == 0 {
+		return `""`
+	}
+	for i, t := range terms {
+		terms[i] = `"` + t + `"`
+	}
+	terms[len(terms)-1] += "*"
+	return strings.Join(terms, " ")
+}
+
+// prefixUpper returns the smallest string greater than every string starting
+// with p under BINARY collation.
+func prefixUpper(p string) string {
+	b := []byte(p)
+	for i := len(b) - 1; i >= 0; i-- {
+		if b[i] < 0xff {
+			b[i]++
+			return string(b[:i+1])
+		}
+	}
+	return string(b) + "\xff"
+}
@@ -0,0 +1,170 @@
+package search
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/ChaseHampton/cargoworker/internal/db"
+	"github.com/ChaseHampton/cargoworker/internal/ir"
+	"github.com/ChaseHampton/cargoworker/internal/store"
+)
+
+func seed(t *testing.T, ctx context.Context) *sql.DB {
+	t.Helper()
+	rdb, err := db.Open(ctx, filepath.Join(t.TempDir(), db.FileName))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { rdb.Close() })
+	st := store.NewSQLite(rdb)
+	if err := st.Migrate(ctx); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if err := st.WriteProject(ctx, &ir.Project{Id: uuid.New(), Name: "p", RootUri: "/src"}); err != nil {
+		t.Fatalf("project: %v", err)
+	}
+	io, util, f, g := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	err = st.WriteFragment(ctx, &ir.Fragment{
+		Containers: []ir.Container{
+			{Id: io, Language: "go", Name: "io", FullName: "io", Kind: "package"},
+			{Id: util, Language: "go", Name: "util", FullName: "example.com/util", Kind: "package"},
+		},
+		Files: []ir.File{
+			{Id: f, ContainerId: io, Path: "io.go", Language: "go"},
+			{Id: g, ContainerId: util, Path: "util/util.go", Language: "go"},
+		},
+		Symbols: []ir.Symbol{
+			{Id: uuid.New(), ContainerId: io, Name: "Reader", Kind: "interface", Visibility: "public", OriginFileId: f, StartLine: 10, DocFmt: "Reader wraps the basic Read method."},
+			{Id: uuid.New(), ContainerId: io, Name: "ReadAll", Kind: "function", Visibility: "public", OriginFileId: f, StartLine: 20, DocFmt: "ReadAll reads until EOF."},
+			{Id: uuid.New(), ContainerId: util, Name: "Copy", Kind: "function", Visibility: "public", OriginFileId: g, StartLine: 5, DocFmt: "Copy drains a reader into a writer."},
+			{Id: uuid.New(), ContainerId: util, Name: "Reader", Kind: "struct", Visibility: "public", OriginFileId: g, StartLine: 9},
+			{Id: uuid.New(), ContainerId: util, Name: "ParseHTTPRequest", Kind: "function", Visibility: "public", OriginFileId: g, StartLine: 30},
+			{Id: uuid.New(), ContainerId: util, Name: "Request", Kind: "struct", Visibility: "public", OriginFileId: g, StartLine: 40, DocFmt: "Request is built by ParseHTTPRequest."},
+		},
+	})
+	if err != nil {
+		t.Fatalf("fragment: %v", err)
+	}
+	if _, err := st.Flush(ctx); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	return rdb
+}
+
+func names(hits []Hit) []string {
+	out := make([]string, len(hits))
+	for i, h := range hits {
+		out[i] = h.Pkg + "." + h.Name
+	}
+	return out
+}
+
+func TestSearch_FTSRanksNameAboveDoc(t *testing.T) {
+	ctx := context.Background()
+	rdb := seed(t, ctx)
+
+	hits, err := Search(ctx, rdb, Query{Text: "reader"})
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	got := names(hits)
+	if len(got) != 3 || got[2] != "example.com/util.Copy" {
+		t.Fatalf("hits = %v; want both Readers before Copy (doc-only match)", got)
+	}
+	if !strings.Contains(hits[2].Snippet, "[reader]") {
+		t.Fatalf("snippet = %q; want marked match", hits[2].Snippet)
+	}
+	if hits[0].File == "" || hits[0].Line == 0 {
+		t.Fatalf("hit without location: %+v", hits[0])
+	}
+
+	hits, err = Search(ctx, rdb, Query{Text: "reader", Kind: "struct", Pkg: "example.com/*"})
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if got := names(hits); len(got) != 1 || got[0] != "example.com/util.Reader" {
+		t.Fatalf("filtered hits = %v", got)
+	}
+}
+
+func TestSearch_NameModes(t *testing.T) {
+	ctx := context.Background()
+	rdb := seed(t, ctx)
+
+	hits, err := Search(ctx, rdb, Query{Text: "Read", Mode: ModePrefix})
+	if err != nil {
+		t.Fatalf("prefix: %v", err)
+	}
+	if got := names(hits); len(got) != 3 || got[0] != "example.com/util.Reader" && got[0] != "io.Reader" {
+		t.Fatalf("prefix hits = %v", got)
+	}
+	if hits[0].Highlight != "[Read]er" {
+		t.Fatalf("highlight = %q", hits[0].Highlight)
+	}
+
+	hits, err = Search(ctx, rdb, Query{Text: "Reader", Mode: ModeExact, Lang: "go"})
+	if err != nil {
+		t.Fatalf("exact: %v", err)
+	}
+	if got := names(hits); len(got) != 2 || got[0] != "example.com/util.Reader" || got[1] != "io.Reader" {
+		t.Fatalf("exact hits = %v", got)
+	}
+}
+
+func TestSearch_IdentifierWords(t *testing.T) {
+	ctx := context.Background()
+	rdb := seed(t, ctx)
+
+	for _, text := range []string{"http request", "parse http", "ParseHTTP", "parsehttprequest"} {
+		hits, err := Search(ctx, rdb, Query{Text: text})
+		if err != nil {
+			t.Fatalf("search %q: %v", text, err)
+		}
+		if len(hits) == 0 || hits[0].Name != "ParseHTTPRequest" {
+			t.Errorf("search %q = %v; want ParseHTTPRequest first", text, names(hits))
+		}
+	}
+
+	// An exact name match outranks symbols that only mention it.
+	hits, err := Search(ctx, rdb, Query{Text: "request"})
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if len(hits) < 2 || hits[0].Name != "Request" {
+		t.Fatalf("search request = %v; want Request first", names(hits))
+	}
+
+	hits, err = Search(ctx, rdb, Query{Text: "ttpreq", Mode: ModeSubstring})
+	if err != nil {
+		t.Fatalf("substring: %v", err)
+	}
+	if len(hits) != 1 || hits[0].Highlight != "ParseH[TTPReq]uest" {
+		t.Fatalf("substring hits = %+v", hits)
+	}
+	hits, err = Search(ctx, rdb, Query{Text: "op", Mode: ModeSubstring})
+	if err != nil {
+		t.Fatalf("short substring: %v", err)
+	}
+	if got := names(hits); len(got) != 1 || got[0] != "example.com/util.Copy" {
+		t.Fatalf("short substring hits = %v", got)
+	}
+}
+
+func TestMatchExpr(t *testing.T) {
+	for in, want := range map[string]string{
+		"io.Reader":  `"io" "Reader"*`,
+		"read all":   `"read" "all"*`,
+		"-- ":        `""`,
+		"snake_case": `"snake" "case"*`,
+		"ParseHTTP":  `"Parse" "HTTP"*`,
+	} {
+		if got := matchExpr(in); got != want {
+			t.Errorf("matchExpr(%q) = %s, want %s", in, got, want)
+		}
+	}
+}
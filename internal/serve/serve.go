@@ -0,0 +1,333 @@
+// Package serve exposes a run database over a read-only JSON HTTP API and a
+// GraphQL endpoint.
+package serve
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ChaseHampton/cargoworker/internal/graphql"
+	"github.com/ChaseHampton/cargoworker/internal/manifest"
+	"github.com/ChaseHampton/cargoworker/internal/query"
+	"github.com/ChaseHampton/cargoworker/internal/search"
+)
+
+// shutdownTimeout bounds how long in-flight requests may take once the
+// server is asked to stop.
+const shutdownTimeout = 10 * time.Second
+
+// Server serves one run database. Every response carries the same ETag,
+// derived from the database checksum: the database is opened read-only, so a
+// response can only change when the server is restarted on a different file.
+type Server struct {
+	db   *sql.DB
+	etag string
+	log  *slog.Logger
+	mux  *http.ServeMux
+}
+
+// New builds the API handler:
+//
+//	GET /containers                 ?kind= &lang= &prefix= &limit= &offset=
+//	GET /symbols/{id}
+//	GET /symbols/{id}/relations     ?direction=out|in|both &kind= &limit= &offset=
+//	GET /search?q=                  &mode= &kind= &pkg= &lang= &limit= &offset=
+//	GET /files/{id}
+//	GET|POST /graphql               see package graphql
+//
+// {id} is a row id or a uid. Lists are wrapped in {"items", "limit",
+// "offset", "next"}, next being the URL of the following page while the
+// current one is full. Other methods on the JSON endpoints are answered 405.
+// GraphQL responses carry no ETag: a POSTed query is not a cacheable
+// resource.
+func New(rdb *sql.DB, etag string, lg *slog.Logger) *Server {
+	s := &Server{db: rdb, etag: etag, log: lg, mux: http.NewServeMux()}
+	s.handle("/containers", s.containers)
+	s.handle("/symbols/{id}", s.symbol)
+	s.handle("/symbols/{id}/relations", s.relations)
+	s.handle("/search", s.search)
+	s.handle("/files/{id}", s.file)
+	s.mux.Handle("/graphql", graphql.NewHandler(rdb, lg))
+	s.handle("/", func(r *http.Request) (func() (any, error), error) {
+		return nil, httpError{http.StatusNotFound, "no such endpoint: " + r.URL.Path}
+	})
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+type httpError struct {
+	status int
+	msg    string
+}
+
+func (e httpError) Error() string { return e.msg }
+
+func badRequest(format string, args ...any) error {
+	return httpError{http.StatusBadRequest, fmt.Sprintf(format, args...)}
+}
+
+// handler resolves a request: it checks its parameters and looks up the
+// resource it names, returning the function that loads the value to encode
+// as JSON.
+type handler func(*http.Request) (load func() (any, error), err error)
+
+// handle registers fn for GET and HEAD requests on the path pattern; other
+// methods are answered 405.
+func (s *Server) handle(pattern string, fn handler) {
+	s.mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		status := s.respond(w, r, fn)
+		s.log.Debug("http request", "method", r.Method, "path", r.URL.RequestURI(),
+			"status", status, "dur_ms", time.Since(start).Milliseconds())
+	})
+}
+
+// respond resolves the request before looking at If-None-Match, and loads
+// the response only after: the ETag validates successful responses only, so
+// an unknown route or id is a 404 whatever the request's preconditions,
+// while a matching request is answered without the work of loading it.
+func (s *Server) respond(w http.ResponseWriter, r *http.Request, fn handler) int {
+	h := w.Header()
+	h.Set("Cache-Control", "no-cache")
+
+	var v any
+	load, err := fn(r)
+	if err == nil && r.Method != http.MethodGet && r.Method != http.MethodHead {
+		h.Set("Allow", "GET, HEAD")
+		err = httpError{http.StatusMethodNotAllowed, "method " + r.Method + " not allowed on " + r.URL.Path}
+	}
+	if err == nil {
+		if etagMatch(r.Header.Get("If-None-Match"), s.etag) {
+			h.Set("ETag", s.etag)
+			w.WriteHeader(http.StatusNotModified)
+			return http.StatusNotModified
+		}
+		v, err = load()
+	}
+	status := http.StatusOK
+	if err == nil {
+		h.Set("ETag", s.etag)
+	} else {
+		var he httpError
+		switch {
+		case errors.As(err, &he):
+			status = he.status
+		case errors.Is(err, query.ErrNotFound):
+			status = http.StatusNotFound
+		default:
+			status = http.StatusInternalServerError
+			s.log.Error("http handler failed", "path", r.URL.Path, "err", err)
+		}
+		v = map[string]string{"error": err.Error()}
+	}
+
+	h.Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	if r.Method != http.MethodHead {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(v)
+	}
+	return status
+}
+
+// etagMatch reports whether an If-None-Match header lists etag (weak
+// comparison, as RFC 9110 prescribes for If-None-Match).
+func etagMatch(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || strings.TrimPrefix(t, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// list is the envelope of paginated responses.
+type list[T any] struct {
+	Items  []T    `json:"items"`
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset"`
+	Next   string `json:"next,omitempty"`
+}
+
+func newList[T any](r *http.Request, items []T, page query.Page) list[T] {
+	l := list[T]{Items: items, Limit: page.Limit, Offset: page.Offset}
+	if len(items) == page.Limit {
+		q := r.URL.Query()
+		q.Set("offset", strconv.Itoa(page.Offset+page.Limit))
+		q.Set("limit", strconv.Itoa(page.Limit))
+		l.Next = (&url.URL{Path: r.URL.Path, RawQuery: q.Encode()}).String()
+	}
+	return l
+}
+
+func pageOf(r *http.Request) (query.Page, error) {
+	var p query.Page
+	for _, f := range []struct {
+		name string
+		dst  *int
+	}{{"limit", &p.Limit}, {"offset", &p.Offset}} {
+		v := r.URL.Query().Get(f.name)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return p, badRequest("%s must be a non-negative integer", f.name)
+		}
+		*f.dst = n
+	}
+	return p.Clamp(), nil
+}
+
+func (s *Server) id(r *http.Request, table string) (int64, error) {
+	id, err := query.ResolveID(r.Context(), s.db, table, r.PathValue("id"))
+	if errors.Is(err, query.ErrNotFound) {
+		return 0, httpError{http.StatusNotFound, fmt.Sprintf("%s %s not found", table, r.PathValue("id"))}
+	}
+	return id, err
+}
+
+func (s *Server) containers(r *http.Request) (func() (any, error), error) {
+	page, err := pageOf(r)
+	if err != nil {
+		return nil, err
+	}
+	return func() (any, error) {
+		q := r.URL.Query()
+		items, err := query.Containers(r.Context(), s.db, query.ContainerFilter{
+			Kind: q.Get("kind"), Language: q.Get("lang"), Prefix: q.Get("prefix"),
+		}, page)
+		if err != nil {
+			return nil, err
+		}
+		return newList(r, items, page), nil
+	}, nil
+}
+
+func (s *Server) symbol(r *http.Request) (func() (any, error), error) {
+	id, err := s.id(r, "symbol")
+	if err != nil {
+		return nil, err
+	}
+	return func() (any, error) { return query.GetSymbol(r.Context(), s.db, id) }, nil
+}
+
+func (s *Server) relations(r *http.Request) (func() (any, error), error) {
+	id, err := s.id(r, "symbol")
+	if err != nil {
+		return nil, err
+	}
+	page, err := pageOf(r)
+	if err != nil {
+		return nil, err
+	}
+	dir := r.URL.Query().Get("direction")
+	switch dir {
+	case "", query.Out, query.In, query.Both:
+	default:
+		return nil, badRequest("direction must be out, in or both")
+	}
+	return func() (any, error) {
+		items, err := query.Relations(r.Context(), s.db, id, dir, r.URL.Query().Get("kind"), page)
+		if err != nil {
+			return nil, err
+		}
+		return newList(r, items, page), nil
+	}, nil
+}
+
+func (s *Server) search(r *http.Request) (func() (any, error), error) {
+	q := r.URL.Query()
+	text := strings.TrimSpace(q.Get("q"))
+	if text == "" {
+		return nil, badRequest("missing query parameter q")
+	}
+	mode, err := search.ParseMode(q.Get("mode"))
+	if err != nil {
+		return nil, badRequest("%v", err)
+	}
+	page, err := pageOf(r)
+	if err != nil {
+		return nil, err
+	}
+	return func() (any, error) {
+		hits, err := search.Search(r.Context(), s.db, search.Query{
+			Text: text, Mode: mode, Kind: q.Get("kind"), Pkg: q.Get("pkg"), Lang: q.Get("lang"),
+			Limit: page.Limit, Offset: page.Offset,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return newList(r, hits, page), nil
+	}, nil
+}
+
+func (s *Server) file(r *http.Request) (func() (any, error), error) {
+	id, err := s.id(r, "file")
+	if err != nil {
+		return nil, err
+	}
+	return func() (any, error) { return query.GetFile(r.Context(), s.db, id) }, nil
+}
+
+// ETag derives the entity tag of a database from its SHA-256, the digest the
+// run manifest records for it.
+func ETag(dbPath string) (string, error) {
+	a, err := manifest.Digest(filepath.Dir(dbPath), filepath.Base(dbPath))
+	if err != nil {
+		return "", err
+	}
+	return `"` + a.SHA256 + `"`, nil
+}
+
+// Run serves h on addr until ctx is cancelled, then stops accepting
+// connections and waits up to shutdownTimeout for in-flight requests.
+func Run(ctx context.Context, addr string, h http.Handler, lg *slog.Logger) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{
+		Handler:           h,
+		ReadHeaderTimeout: 10 * time.Second,
+		ErrorLog:          slog.NewLogLogger(lg.Handler(), slog.LevelWarn),
+	}
+	errc := make(chan error, 1)
+	go func() { errc <- srv.Serve(ln) }()
+	lg.Info("serving", "addr", ln.Addr().String())
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+	lg.Info("shutting down", "timeout", shutdownTimeout)
+	sctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(sctx); err != nil {
+		return fmt.Errorf("shutdown: %w", err)
+	}
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
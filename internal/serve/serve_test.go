@@ -0,0 +1,214 @@
+package serve
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/ChaseHampton/cargoworker/internal/db"
+	"github.com/ChaseHampton/cargoworker/internal/ir"
+	"github.com/ChaseHampton/cargoworker/internal/store"
+)
+
+func seedDB(t *testing.T, ctx context.Context) (*sql.DB, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), db.FileName)
+	rdb, err := db.Open(ctx, path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { rdb.Close() })
+	st := store.NewSQLite(rdb)
+	if err := st.Migrate(ctx); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if err := st.WriteProject(ctx, &ir.Project{Id: uuid.New(), Name: "p", RootUri: "/src"}); err != nil {
+		t.Fatalf("project: %v", err)
+	}
+	c, f, a, b, m := uuid.New(), uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	err = st.WriteFragment(ctx, &ir.Fragment{
+		Containers: []ir.Container{{Id: c, Name: "p", FullName: "example.com/p", Kind: "package", Language: "go"}},
+		Files:      []ir.File{{Id: f, ContainerId: c, Path: "p.go", Language: "go", SizeBytes: 42}},
+		Symbols: []ir.Symbol{
+			{Id: a, ContainerId: c, Name: "FileReader", FullName: "example.com/p.FileReader", Kind: "struct", OriginFileId: f, StartLine: 3},
+			{Id: b, ContainerId: c, Name: "Reader", FullName: "example.com/p.Reader", Kind: "interface", OriginFileId: f, StartLine: 10, DocFmt: "Reader reads."},
+			{Id: m, ContainerId: c, Name: "Read", FullName: "example.com/p.FileReader.Read", Kind: "method", OriginFileId: f, StartLine: 5},
+		},
+		Signatures: []ir.Signature{{SymbolId: m, Text: "func (r *FileReader) Read() error"}},
+		Members:    []ir.Member{{Id: uuid.New(), OwnerSymbolId: a, ChildSymbolId: m}},
+		Relations:  []ir.Relation{{SourceSymbolId: a, Relation: "implements", DstSymbolId: b, DetailsJson: `{"via":"Read"}`}},
+	})
+	if err != nil {
+		t.Fatalf("fragment: %v", err)
+	}
+	if _, err := st.Flush(ctx); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	return rdb, path
+}
+
+func TestServer(t *testing.T) {
+	ctx := context.Background()
+	rdb, path := seedDB(t, ctx)
+	etag, err := ETag(path)
+	if err != nil {
+		t.Fatalf("etag: %v", err)
+	}
+	srv := httptest.NewServer(New(rdb, etag, slog.New(slog.NewTextHandler(io.Discard, nil))))
+	defer srv.Close()
+
+	get := func(path string, header ...string) (*http.Response, map[string]any) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+path, nil)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		defer resp.Body.Close()
+		var body map[string]any
+		if resp.StatusCode != http.StatusNotModified {
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatalf("GET %s: decode: %v", path, err)
+			}
+		}
+		return resp, body
+	}
+	items := func(body map[string]any) []any {
+		t.Helper()
+		list, ok := body["items"].([]any)
+		if !ok {
+			t.Fatalf("no items in %v", body)
+		}
+		return list
+	}
+
+	resp, body := get("/containers")
+	if resp.StatusCode != 200 || resp.Header.Get("ETag") != etag {
+		t.Fatalf("containers: %d etag %q", resp.StatusCode, resp.Header.Get("ETag"))
+	}
+	if cs := items(body); len(cs) != 1 || cs[0].(map[string]any)["full_name"] != "example.com/p" || cs[0].(map[string]any)["symbols"] != 3.0 {
+		t.Fatalf("containers = %v", body)
+	}
+	if resp, _ := get("/containers", "If-None-Match", etag); resp.StatusCode != http.StatusNotModified {
+		t.Fatalf("conditional GET: %d", resp.StatusCode)
+	}
+	// Preconditions apply to what exists: an unknown id or route stays a 404.
+	for _, p := range []string{"/symbols/999999", "/nope"} {
+		if resp, _ := get(p, "If-None-Match", etag); resp.StatusCode != http.StatusNotFound || resp.Header.Get("ETag") != "" {
+			t.Fatalf("conditional GET %s: %d etag %q", p, resp.StatusCode, resp.Header.Get("ETag"))
+		}
+	}
+
+	// A known path answers other methods 405, not the catch-all's 404.
+	for _, p := range []string{"/containers", "/symbols/1"} {
+		resp, err := http.Post(srv.URL+p, "application/json", strings.NewReader("{}"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusMethodNotAllowed || resp.Header.Get("Allow") != "GET, HEAD" {
+			t.Errorf("POST %s = %d, Allow %q", p, resp.StatusCode, resp.Header.Get("Allow"))
+		}
+	}
+
+	// Search, paginated one hit at a time.
+	_, body = get("/search?q=reader&limit=1")
+	hits := items(body)
+	if len(hits) != 1 || hits[0].(map[string]any)["name"] != "Reader" {
+		t.Fatalf("search = %v", body)
+	}
+	next, _ := body["next"].(string)
+	if !strings.Contains(next, "offset=1") {
+		t.Fatalf("next = %q", next)
+	}
+	_, body = get(next)
+	if hits := items(body); len(hits) != 1 || hits[0].(map[string]any)["name"] != "FileReader" {
+		t.Fatalf("second page = %v", body)
+	}
+
+	_, body = get("/search?q=FileReader&mode=exact")
+	sym := items(body)[0].(map[string]any)
+	symID := int64(sym["symbol_id"].(float64))
+
+	resp, body = get("/symbols/" + itoa(symID))
+	if resp.StatusCode != 200 || body["kind"] != "struct" {
+		t.Fatalf("symbol = %d %v", resp.StatusCode, body)
+	}
+	members := body["members"].([]any)
+	if len(members) != 1 || members[0].(map[string]any)["name"] != "Read" {
+		t.Fatalf("members = %v", members)
+	}
+	uid := body["uid"].(string)
+	if _, byUID := get("/symbols/" + uid); byUID["id"] != body["id"] {
+		t.Fatalf("lookup by uid = %v", byUID)
+	}
+
+	_, body = get("/symbols/" + itoa(symID) + "/relations")
+	rels := items(body)
+	if len(rels) != 1 {
+		t.Fatalf("relations = %v", body)
+	}
+	rel := rels[0].(map[string]any)
+	if rel["kind"] != "implements" || rel["direction"] != "out" || rel["symbol"].(map[string]any)["name"] != "Reader" ||
+		rel["details"].(map[string]any)["via"] != "Read" {
+		t.Fatalf("relation = %v", rel)
+	}
+	if _, body = get("/symbols/" + itoa(symID) + "/relations?direction=in"); len(items(body)) != 0 {
+		t.Fatalf("incoming relations = %v", body)
+	}
+
+	_, body = get("/symbols/" + itoa(symID))
+	_, body = get("/files/" + itoa(int64(body["file_id"].(float64))))
+	if body["path"] != "p.go" || len(body["symbols"].([]any)) != 3 {
+		t.Fatalf("file = %v", body)
+	}
+
+	for path, status := range map[string]int{
+		"/symbols/999999":                   404,
+		"/symbols/nope":                     404,
+		"/files/999999":                     404,
+		"/search":                           400,
+		"/search?q=x&mode=fuzzy":            400,
+		"/containers?limit=-1":              400,
+		"/symbols/1/relations?direction=up": 400,
+		"/nowhere":                          404,
+	} {
+		resp, body := get(path)
+		if resp.StatusCode != status || body["error"] == nil {
+			t.Errorf("GET %s = %d %v, want %d with an error", path, resp.StatusCode, body, status)
+		}
+	}
+}
+
+func itoa(n int64) string { return strconv.FormatInt(n, 10) }
+
+// A conditional request matching the ETag is answered without loading the
+// response.
+func TestRespondNotModified(t *testing.T) {
+	s := &Server{etag: `"x"`, log: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	r := httptest.NewRequest(http.MethodGet, "/containers", nil)
+	r.Header.Set("If-None-Match", `"x"`)
+	w := httptest.NewRecorder()
+	status := s.respond(w, r, func(*http.Request) (func() (any, error), error) {
+		return func() (any, error) {
+			t.Error("loaded a response the client has")
+			return nil, nil
+		}, nil
+	})
+	if status != http.StatusNotModified || w.Header().Get("ETag") != `"x"` {
+		t.Errorf("status %d, ETag %q", status, w.Header().Get("ETag"))
+	}
+}
@@ -0,0 +1,204 @@
+package shell
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// errInterrupt is returned by readLine when the user presses Ctrl-C.
+var errInterrupt = errors.New("interrupt")
+
+// editor reads lines from a terminal in raw mode, with cursor movement,
+// history and tab completion. It does no terminal setup itself; keys come
+// from in and echo goes to out.
+type editor struct {
+	in       *bufio.Reader
+	out      io.Writer
+	history  []string
+	complete func(line string, pos int) (start int, candidates []string)
+}
+
+// line is the state of the line being edited.
+type line struct {
+	buf    []rune
+	pos    int
+	prompt string
+}
+
+// readLine reads one line. It returns io.EOF on Ctrl-D at an empty line and
+// errInterrupt on Ctrl-C.
+func (e *editor) readLine(prompt string) (string, error) {
+	l := &line{prompt: prompt}
+	hist := len(e.history) // index into history; len is the line being typed
+	var draft []rune
+	lastTab := false
+	e.redraw(l)
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		tab := false
+		switch r {
+		case '\r', '\n':
+			fmt.Fprint(e.out, "\r\n")
+			s := string(l.buf)
+			if strings.TrimSpace(s) != "" && (len(e.history) == 0 || e.history[len(e.history)-1] != s) {
+				e.history = append(e.history, s)
+			}
+			return s, nil
+		case 3: // Ctrl-C
+			fmt.Fprint(e.out, "^C\r\n")
+			return "", errInterrupt
+		case 4: // Ctrl-D
+			if len(l.buf) == 0 {
+				fmt.Fprint(e.out, "\r\n")
+				return "", io.EOF
+			}
+			l.delete()
+		case 1: // Ctrl-A
+			l.pos = 0
+		case 5: // Ctrl-E
+			l.pos = len(l.buf)
+		case 2: // Ctrl-B
+			l.pos = max(l.pos-1, 0)
+		case 6: // Ctrl-F
+			l.pos = min(l.pos+1, len(l.buf))
+		case 21: // Ctrl-U
+			l.buf, l.pos = l.buf[l.pos:], 0
+		case 11: // Ctrl-K
+			l.buf = l.buf[:l.pos]
+		case 23: // Ctrl-W
+			start := l.pos
+			for start > 0 && l.buf[start-1] == ' ' {
+				start--
+			}
+			for start > 0 && l.buf[start-1] != ' ' {
+				start--
+			}
+			l.buf, l.pos = append(l.buf[:start], l.buf[l.pos:]...), start
+		case 12: // Ctrl-L
+			fmt.Fprint(e.out, "\x1b[H\x1b[2J")
+		case 127, 8: // Backspace
+			if l.pos > 0 {
+				l.pos--
+				l.delete()
+			}
+		case '\t':
+			tab = true
+			e.completeLine(l, lastTab)
+		case 0x1b:
+			switch e.escape() {
+			case "[A", "OA": // up
+				if hist > 0 {
+					if hist == len(e.history) {
+						draft = l.buf
+					}
+					hist--
+					l.buf = []rune(e.history[hist])
+					l.pos = len(l.buf)
+				}
+			case "[B", "OB": // down
+				if hist < len(e.history) {
+					hist++
+					if hist == len(e.history) {
+						l.buf = draft
+					} else {
+						l.buf = []rune(e.history[hist])
+					}
+					l.pos = len(l.buf)
+				}
+			case "[C", "OC": // right
+				l.pos = min(l.pos+1, len(l.buf))
+			case "[D", "OD": // left
+				l.pos = max(l.pos-1, 0)
+			case "[H", "OH", "[1~", "[7~": // home
+				l.pos = 0
+			case "[F", "OF", "[4~", "[8~": // end
+				l.pos = len(l.buf)
+			case "[3~": // delete
+				l.delete()
+			}
+		default:
+			if r >= ' ' && r != utf8.RuneError {
+				l.insert(string(r))
+			}
+		}
+		lastTab = tab
+		e.redraw(l)
+	}
+}
+
+// escape reads the rest of an escape sequence after ESC: "[A", "[3~", "OH".
+func (e *editor) escape() string {
+	var seq []byte
+	for {
+		b, err := e.in.ReadByte()
+		if err != nil {
+			return string(seq)
+		}
+		seq = append(seq, b)
+		switch {
+		case len(seq) == 1 && b != '[' && b != 'O':
+			return string(seq) // Alt-<key>
+		case len(seq) > 1 && (b >= 'A' && b <= 'Z' || b >= 'a' && b <= 'z' || b == '~'):
+			return string(seq)
+		}
+	}
+}
+
+func (l *line) insert(s string) {
+	rs := []rune(s)
+	l.buf = append(l.buf[:l.pos], append(rs, l.buf[l.pos:]...)...)
+	l.pos += len(rs)
+}
+
+// delete removes the rune under the cursor.
+func (l *line) delete() {
+	if l.pos < len(l.buf) {
+		l.buf = append(l.buf[:l.pos], l.buf[l.pos+1:]...)
+	}
+}
+
+// completeLine completes the word before the cursor: with one candidate, to
+// it; with several, to their common prefix, listing them on a second tab.
+func (e *editor) completeLine(l *line, listed bool) {
+	if e.complete == nil {
+		return
+	}
+	head := string(l.buf[:l.pos])
+	start, cands := e.complete(head, len(head))
+	word := head[start:]
+	switch len(cands) {
+	case 0:
+		return
+	case 1:
+		l.insert(strings.TrimPrefix(cands[0], word) + " ")
+		return
+	}
+	prefix := cands[0]
+	for _, c := range cands[1:] {
+		for !strings.HasPrefix(c, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	if len(prefix) > len(word) {
+		l.insert(prefix[len(word):])
+		return
+	}
+	if listed {
+		fmt.Fprintf(e.out, "\r\n%s\r\n", strings.Join(cands, "  "))
+	}
+}
+
+// redraw rewrites the prompt and line and puts the cursor in place.
+func (e *editor) redraw(l *line) {
+	fmt.Fprintf(e.out, "\r%s%s\x1b[K", l.prompt, string(l.buf))
+	if back := len(l.buf) - l.pos; back > 0 {
+		fmt.Fprintf(e.out, "\x1b[%dD", back)
+	}
+}
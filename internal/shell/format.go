@@ -0,0 +1,124 @@
+package shell
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// cell renders a value for table output.
+func cell(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "NULL"
+	case []byte:
+		if utf8.Valid(v) {
+			return escape(string(v))
+		}
+		return "x'" + hex.EncodeToString(v) + "'"
+	case string:
+		return escape(v)
+	}
+	return fmt.Sprint(v)
+}
+
+// escape keeps a value on one line.
+func escape(s string) string {
+	return strings.NewReplacer("\n", `\n`, "\r", `\r`, "\t", `\t`).Replace(s)
+}
+
+// writeTable prints rows as columns aligned under a header, like
+//
+//	id  name
+//	--  ----
+//	1   Read
+//	(1 row)
+func writeTable(w io.Writer, cols []string, rows [][]any) error {
+	cells := make([][]string, 0, len(rows)+1)
+	cells = append(cells, cols)
+	widths := make([]int, len(cols))
+	for _, r := range rows {
+		line := make([]string, len(r))
+		for i, v := range r {
+			line[i] = cell(v)
+		}
+		cells = append(cells, line)
+	}
+	for _, line := range cells {
+		for i, c := range line {
+			widths[i] = max(widths[i], utf8.RuneCountInString(c))
+		}
+	}
+	rules := make([]string, len(cols))
+	for i, n := range widths {
+		rules[i] = strings.Repeat("-", max(n, 1))
+	}
+	cells = append(cells[:1], append([][]string{rules}, cells[1:]...)...)
+
+	var b strings.Builder
+	for _, line := range cells {
+		for i, c := range line {
+			if i == len(line)-1 {
+				b.WriteString(c)
+				break
+			}
+			b.WriteString(c)
+			b.WriteString(strings.Repeat(" ", widths[i]-utf8.RuneCountInString(c)+2))
+		}
+		b.WriteByte('\n')
+	}
+	switch len(rows) {
+	case 1:
+		b.WriteString("(1 row)\n")
+	default:
+		fmt.Fprintf(&b, "(%d rows)\n", len(rows))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeJSON prints rows as an array of objects keyed by column, in column
+// order.
+func writeJSON(w io.Writer, cols []string, rows [][]any) error {
+	var b bytes.Buffer
+	b.WriteByte('[')
+	for i, r := range rows {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteByte('{')
+		for j, v := range r {
+			if j > 0 {
+				b.WriteByte(',')
+			}
+			k, _ := json.Marshal(cols[j])
+			b.Write(k)
+			b.WriteByte(':')
+			if raw, ok := v.([]byte); ok {
+				if utf8.Valid(raw) {
+					v = string(raw)
+				} else {
+					v = "x'" + hex.EncodeToString(raw) + "'"
+				}
+			}
+			val, err := json.Marshal(v)
+			if err != nil {
+				return err
+			}
+			b.Write(val)
+		}
+		b.WriteByte('}')
+	}
+	b.WriteByte(']')
+	var out bytes.Buffer
+	if err := json.Indent(&out, b.Bytes(), "", "  "); err != nil {
+		return err
+	}
+	out.WriteByte('\n')
+	_, err := w.Write(out.Bytes())
+	return err
+}
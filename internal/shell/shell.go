@@ -0,0 +1,394 @@
+// Package shell is an interactive SQL shell over a run database: raw SQL
+// plus dot-commands for the common questions (where is a symbol, who
+// implements an interface, who calls a function, what a package imports),
+// with results as aligned tables or JSON.
+//
+// On a terminal, lines are edited in place with history and tab completion
+// of dot-commands and of table and column names from the schema the
+// migrations create. Other input is read line by line without prompts, so
+// scripts can be piped in; the first failing command then ends the run.
+package shell
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/mattn/go-isatty"
+
+	"github.com/ChaseHampton/cargoworker/internal/db"
+)
+
+// Output modes.
+const (
+	ModeTable = "table"
+	ModeJSON  = "json"
+)
+
+const (
+	prompt     = "cw> "
+	contPrompt = "...> "
+)
+
+// errQuit ends Run after .quit or .exit.
+var errQuit = errors.New("quit")
+
+type Shell struct {
+	db     *sql.DB
+	tables []db.Table
+	out    io.Writer
+	errOut io.Writer
+	mode   string
+}
+
+// New returns a shell over rdb printing results to out and errors to errOut.
+// tables is the schema offered for completion and by .tables and .schema.
+func New(rdb *sql.DB, tables []db.Table, out, errOut io.Writer) *Shell {
+	return &Shell{db: rdb, tables: tables, out: out, errOut: errOut, mode: ModeTable}
+}
+
+// SetMode selects table or JSON output.
+func (s *Shell) SetMode(mode string) error {
+	switch mode {
+	case ModeTable, ModeJSON:
+		s.mode = mode
+		return nil
+	}
+	return fmt.Errorf("unknown mode %q (want table|json)", mode)
+}
+
+// Run reads and runs commands from in until it is exhausted or the user
+// quits.
+func (s *Shell) Run(ctx context.Context, in io.Reader) error {
+	if f, ok := in.(*os.File); ok && isatty.IsTerminal(f.Fd()) {
+		return s.interactive(ctx, f)
+	}
+	sc := bufio.NewScanner(in)
+	sc.Buffer(make([]byte, 0, 64<<10), 16<<20)
+	var pending strings.Builder
+	n := 0
+	for sc.Scan() {
+		n++
+		cmd, ok := s.accumulate(&pending, sc.Text())
+		if !ok {
+			continue
+		}
+		if err := s.Exec(ctx, cmd); errors.Is(err, errQuit) {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("line %d: %w", n, err)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	if rest := strings.TrimSpace(pending.String()); rest != "" {
+		// A final statement without its semicolon still runs.
+		if err := s.Exec(ctx, rest); err != nil && !errors.Is(err, errQuit) {
+			return fmt.Errorf("line %d: %w", n, err)
+		}
+	}
+	return nil
+}
+
+func (s *Shell) interactive(ctx context.Context, f *os.File) error {
+	ed := &editor{in: bufio.NewReader(f), out: s.out, complete: s.complete}
+	fmt.Fprintln(s.out, `Enter SQL terminated by ";", or ".help" for commands.`)
+	var pending strings.Builder
+	for {
+		p := prompt
+		if pending.Len() > 0 {
+			p = contPrompt
+		}
+		restore, err := makeRaw(int(f.Fd()))
+		if err != nil {
+			return fmt.Errorf("terminal: %w", err)
+		}
+		text, err := ed.readLine(p)
+		if rerr := restore(); rerr != nil {
+			return fmt.Errorf("terminal: %w", rerr)
+		}
+		switch {
+		case errors.Is(err, errInterrupt):
+			pending.Reset()
+			continue
+		case errors.Is(err, io.EOF):
+			return nil
+		case err != nil:
+			return err
+		}
+
+		cmd, ok := s.accumulate(&pending, text)
+		if !ok {
+			continue
+		}
+		if err := s.Exec(ctx, cmd); errors.Is(err, errQuit) {
+			return nil
+		} else if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			fmt.Fprintf(s.errOut, "Error: %v\n", err)
+		}
+	}
+}
+
+// accumulate adds a line of input to pending and reports whether pending now
+// holds a command to run, which it returns and clears: a dot-command line,
+// or SQL up to a terminating semicolon.
+func (s *Shell) accumulate(pending *strings.Builder, text string) (string, bool) {
+	if pending.Len() == 0 {
+		trimmed := strings.TrimSpace(text)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			return "", false
+		}
+		if strings.HasPrefix(trimmed, ".") {
+			return trimmed, true
+		}
+	} else {
+		pending.WriteByte('\n')
+	}
+	pending.WriteString(text)
+	if !complete(pending.String()) {
+		return "", false
+	}
+	cmd := pending.String()
+	pending.Reset()
+	return cmd, true
+}
+
+// Exec runs one dot-command or one or more SQL statements.
+func (s *Shell) Exec(ctx context.Context, cmd string) error {
+	cmd = strings.TrimSpace(cmd)
+	if strings.HasPrefix(cmd, ".") {
+		return s.meta(ctx, cmd)
+	}
+	for _, stmt := range splitStatements(cmd) {
+		if err := s.query(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// query runs a statement and prints its rows.
+func (s *Shell) query(ctx context.Context, stmt string, args ...any) error {
+	rows, err := s.db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	var data [][]any
+	for rows.Next() {
+		vals := make([]any, len(cols))
+		ptrs := make([]any, len(cols))
+		for i := range vals {
+			ptrs[i] = &vals[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return err
+		}
+		data = append(data, vals)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(cols) == 0 {
+		return nil // a statement without results, such as PRAGMA x = y
+	}
+	if s.mode == ModeJSON {
+		return writeJSON(s.out, cols, data)
+	}
+	return writeTable(s.out, cols, data)
+}
+
+// metaCommand is a dot-command answered by a canned query whose ?1 is the
+// argument: a name or full name, '*' and '?' glob.
+type metaCommand struct {
+	usage, help, query string
+}
+
+// location renders the file:line of symbol s from file f.
+const location = `COALESCE(f.rel_path || ':' || s.line, f.rel_path) AS location`
+
+var metaCommands = map[string]metaCommand{
+	".symbols": {"<name>", "symbols with this name or full name", `
+		SELECT s.id, s.kind, COALESCE(s.full_name, s.name) AS symbol, p.import_path AS container, ` + location + `
+		FROM symbol s JOIN package p ON p.id = s.package_id LEFT JOIN file f ON f.id = s.file_id
+		WHERE s.name GLOB ?1 OR s.full_name GLOB ?1
+		ORDER BY symbol, s.id;`},
+	".impl": {"<interface>", "symbols implementing, satisfying or overriding it", `
+		SELECT s.id, s.kind, COALESCE(s.full_name, s.name) AS symbol, r.kind AS relation,
+		       COALESCE(t.full_name, t.name) AS target, ` + location + `
+		FROM relation r
+		JOIN symbol t ON t.id = r.to_symbol_id
+		JOIN symbol s ON s.id = r.from_symbol_id
+		LEFT JOIN file f ON f.id = s.file_id
+		WHERE r.kind IN ('implements', 'satisfies', 'overrides') AND (t.name GLOB ?1 OR t.full_name GLOB ?1)
+		ORDER BY target, symbol;`},
+	".callers": {"<function>", "functions calling it, with the call site", `
+		SELECT s.id, s.kind, COALESCE(s.full_name, s.name) AS caller, COALESCE(t.full_name, t.name) AS callee,
+		       COALESCE(f.rel_path || ':' || COALESCE(CASE WHEN json_valid(r.detail) THEN json_extract(r.detail, '$.line') END, s.line),
+		                f.rel_path) AS call_site
+		FROM relation r
+		JOIN symbol t ON t.id = r.to_symbol_id
+		JOIN symbol s ON s.id = r.from_symbol_id
+		LEFT JOIN file f ON f.id = s.file_id
+		WHERE r.kind = 'calls' AND (t.name GLOB ?1 OR t.full_name GLOB ?1)
+		ORDER BY callee, caller, call_site;`},
+	".deps": {"<container>", "what a package or module imports", `
+		SELECT p.import_path AS container, i.path AS imports, i.alias, i.is_stdlib AS stdlib,
+		       EXISTS (SELECT 1 FROM package q WHERE q.import_path = i.path) AS indexed
+		FROM pkg_import i JOIN package p ON p.id = i.package_id
+		WHERE p.import_path GLOB ?1 OR p.name GLOB ?1
+		ORDER BY container, imports;`},
+}
+
+// builtins are the dot-commands handled by the shell itself, for .help and
+// completion.
+var builtins = []struct{ name, usage, help string }{
+	{".help", "", "this list"},
+	{".tables", "", "tables and views of the schema"},
+	{".schema", "[table]", "columns of all tables or one"},
+	{".mode", "[table|json]", "show or set the output mode"},
+	{".quit", "", "leave the shell (also .exit)"},
+}
+
+func (s *Shell) meta(ctx context.Context, cmd string) error {
+	name, arg, _ := strings.Cut(cmd, " ")
+	arg = strings.TrimSpace(arg)
+	if mc, ok := metaCommands[name]; ok {
+		if arg == "" {
+			return fmt.Errorf("usage: %s %s", name, mc.usage)
+		}
+		return s.query(ctx, mc.query, arg)
+	}
+	switch name {
+	case ".help":
+		s.help()
+	case ".tables":
+		for _, t := range s.tables {
+			fmt.Fprintln(s.out, t.Name)
+		}
+	case ".schema":
+		found := false
+		for _, t := range s.tables {
+			if arg != "" && t.Name != arg {
+				continue
+			}
+			found = true
+			kind := "table"
+			if t.View {
+				kind = "view"
+			}
+			fmt.Fprintf(s.out, "%s %s(%s)\n", kind, t.Name, strings.Join(t.Columns, ", "))
+		}
+		if !found {
+			return fmt.Errorf("no table %q", arg)
+		}
+	case ".mode":
+		if arg == "" {
+			fmt.Fprintln(s.out, s.mode)
+			return nil
+		}
+		return s.SetMode(arg)
+	case ".quit", ".exit":
+		return errQuit
+	default:
+		return fmt.Errorf("unknown command %s; .help lists them", name)
+	}
+	return nil
+}
+
+func (s *Shell) help() {
+	var lines [][2]string
+	for _, b := range builtins {
+		lines = append(lines, [2]string{strings.TrimSpace(b.name + " " + b.usage), b.help})
+	}
+	names := make([]string, 0, len(metaCommands))
+	for n := range metaCommands {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		lines = append(lines, [2]string{n + " " + metaCommands[n].usage, metaCommands[n].help})
+	}
+	width := 0
+	for _, l := range lines {
+		width = max(width, len(l[0]))
+	}
+	for _, l := range lines {
+		fmt.Fprintf(s.out, "%-*s  %s\n", width, l[0], l[1])
+	}
+	fmt.Fprintln(s.out, "Names take * and ? wildcards. Anything else is SQL, run when a line ends with ;.")
+}
+
+// complete returns the completions of the word ending at pos: dot-commands
+// first on the line, table names after .schema, and table and column names
+// in SQL. A word "table." completes to that table's columns.
+func (s *Shell) complete(line string, pos int) (int, []string) {
+	line = line[:pos]
+	start := pos
+	for start > 0 && isWordByte(line[start-1]) {
+		start--
+	}
+	word := strings.ToLower(line[start:])
+	before := strings.TrimSpace(line[:start])
+
+	var pool []string
+	switch {
+	case before == "" && strings.HasPrefix(word, "."):
+		for _, b := range builtins {
+			pool = append(pool, b.name)
+		}
+		pool = append(pool, ".exit")
+		for n := range metaCommands {
+			pool = append(pool, n)
+		}
+	case before == ".schema":
+		for _, t := range s.tables {
+			pool = append(pool, t.Name)
+		}
+	case before == ".mode":
+		pool = []string{ModeTable, ModeJSON}
+	case strings.HasPrefix(before, "."):
+		return start, nil
+	case strings.Contains(word, "."):
+		table, _, _ := strings.Cut(word, ".")
+		for _, t := range s.tables {
+			if t.Name == table {
+				for _, c := range t.Columns {
+					pool = append(pool, t.Name+"."+c)
+				}
+			}
+		}
+	default:
+		for _, t := range s.tables {
+			pool = append(pool, t.Name)
+			pool = append(pool, t.Columns...)
+		}
+	}
+	var out []string
+	for _, c := range pool {
+		if strings.HasPrefix(c, word) {
+			out = append(out, c)
+		}
+	}
+	slices.Sort(out)
+	return start, slices.Compact(out)
+}
+
+func isWordByte(b byte) bool {
+	return b == '_' || b == '.' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9'
+}
@@ -0,0 +1,180 @@
+package shell
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/ChaseHampton/cargoworker/internal/db"
+	"github.com/ChaseHampton/cargoworker/internal/ir"
+	"github.com/ChaseHampton/cargoworker/internal/store"
+)
+
+// seed writes a run database with an interface, a struct implementing it
+// and a function calling its method.
+func seed(t *testing.T, ctx context.Context) *sql.DB {
+	t.Helper()
+	rdb, err := db.Open(ctx, filepath.Join(t.TempDir(), db.FileName))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { rdb.Close() })
+	st := store.NewSQLite(rdb)
+	if err := st.Migrate(ctx); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if err := st.WriteProject(ctx, &ir.Project{Id: uuid.New(), Name: "p", RootUri: "/src"}); err != nil {
+		t.Fatalf("project: %v", err)
+	}
+	c, f, reader, file, read, use := uuid.New(), uuid.New(), uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	err = st.WriteFragment(ctx, &ir.Fragment{
+		Containers: []ir.Container{{Id: c, Name: "p", FullName: "example.com/p", Kind: "package", Language: "go"}},
+		Files:      []ir.File{{Id: f, ContainerId: c, Path: "p/p.go", Language: "go"}},
+		Symbols: []ir.Symbol{
+			{Id: reader, ContainerId: c, Name: "Reader", FullName: "example.com/p.Reader", Kind: "interface", OriginFileId: f, StartLine: 4},
+			{Id: file, ContainerId: c, Name: "File", FullName: "example.com/p.File", Kind: "struct", OriginFileId: f, StartLine: 6},
+			{Id: read, ContainerId: c, Name: "Read", FullName: "example.com/p.File.Read", Kind: "method", OriginFileId: f, StartLine: 8},
+			{Id: use, ContainerId: c, Name: "Use", FullName: "example.com/p.Use", Kind: "function", OriginFileId: f, StartLine: 10},
+		},
+		Relations: []ir.Relation{
+			{SourceSymbolId: file, Relation: "implements", DstSymbolId: reader},
+			{SourceSymbolId: use, Relation: "calls", DstSymbolId: read, DetailsJson: `{"line":11}`},
+		},
+	})
+	if err != nil {
+		t.Fatalf("fragment: %v", err)
+	}
+	if _, err := st.Flush(ctx); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	return rdb
+}
+
+func newShell(t *testing.T) (*Shell, *bytes.Buffer) {
+	t.Helper()
+	ctx := context.Background()
+	tables, err := db.Schema(ctx)
+	if err != nil {
+		t.Fatalf("schema: %v", err)
+	}
+	var out bytes.Buffer
+	return New(seed(t, ctx), tables, &out, io.Discard), &out
+}
+
+func TestRun(t *testing.T) {
+	sh, out := newShell(t)
+	script := `.symbols Rea*
+.impl Reader
+.callers example.com/p.File.Read
+-- a comment
+SELECT kind,
+       count(*) AS n
+FROM symbol GROUP BY kind ORDER BY kind LIMIT 2; SELECT 'a;b' AS s;
+.mode json
+SELECT name, NULL AS doc FROM symbol WHERE name = 'Use'
+`
+	if err := sh.Run(context.Background(), strings.NewReader(script)); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	got := out.String()
+	for _, want := range []string{
+		"example.com/p.File.Read  example.com/p  p/p.go:8",
+		"example.com/p.File  implements  example.com/p.Reader  p/p.go:6",
+		"example.com/p.Use  example.com/p.File.Read  p/p.go:11",
+		"kind       n\n---------  -\nfunction   1\ninterface  1\n(2 rows)\n",
+		"s\n---\na;b\n(1 row)\n",
+		"[\n  {\n    \"name\": \"Use\",\n    \"doc\": null\n  }\n]\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output lacks %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	sh, _ := newShell(t)
+	for script, want := range map[string]string{
+		"SELECT 1;\nSELECT nope FROM symbol;\n": "line 2: SQL logic error: no such column: nope",
+		".impl\n":                               "usage: .impl <interface>",
+		".bogus\n":                              "unknown command .bogus",
+		".mode csv\n":                           `unknown mode "csv"`,
+	} {
+		err := sh.Run(context.Background(), strings.NewReader(script))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%q: err = %v, want %q", script, err, want)
+		}
+	}
+	if err := sh.Run(context.Background(), strings.NewReader(".quit\n.bogus\n")); err != nil {
+		t.Errorf(".quit: %v", err)
+	}
+}
+
+func TestComplete(t *testing.T) {
+	sh, _ := newShell(t)
+	for _, tc := range []struct {
+		line string
+		want []string
+	}{
+		{".im", []string{".impl"}},
+		{".schema rel", []string{"relation"}},
+		{"SELECT full_n", []string{"full_name"}},
+		{"SELECT * FROM sym", []string{"symbol", "symbol_id"}},
+		{"SELECT * FROM pkg_i", []string{"pkg_import"}},
+		{"SELECT relation.to", []string{"relation.to_symbol_id"}},
+		{".impl Rea", nil},
+	} {
+		start, got := sh.complete(tc.line, len(tc.line))
+		if strings.Join(got, ",") != strings.Join(tc.want, ",") {
+			t.Errorf("complete(%q) = %v, want %v", tc.line, got, tc.want)
+		}
+		if got != nil && !strings.HasPrefix(got[0], strings.ToLower(tc.line[start:])) {
+			t.Errorf("complete(%q) start = %d", tc.line, start)
+		}
+	}
+}
+
+func TestEditor(t *testing.T) {
+	sh, _ := newShell(t)
+	keys := "SELECT * FROM pkg_im\t;\r" + // completion
+		"\x1b[A\x01x\x1b[3~\x05\x7f!\r" + // history, home, delete, end, backspace
+		"abc\x03" + // interrupt
+		"\x04" // EOF
+	ed := &editor{in: bufio.NewReader(strings.NewReader(keys)), out: io.Discard, complete: sh.complete}
+	var lines []string
+	for {
+		l, err := ed.readLine(prompt)
+		if errors.Is(err, errInterrupt) {
+			lines = append(lines, "^C")
+			continue
+		}
+		if err != nil {
+			if err != io.EOF {
+				t.Fatal(err)
+			}
+			break
+		}
+		lines = append(lines, l)
+	}
+	want := []string{"SELECT * FROM pkg_import ;", "xELECT * FROM pkg_import !", "^C"}
+	if strings.Join(lines, "|") != strings.Join(want, "|") {
+		t.Errorf("lines = %q, want %q", lines, want)
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	got := splitStatements("SELECT ';' ; -- x;\nSELECT \"a;b\" /* ; */;;")
+	if len(got) != 2 || got[0] != "SELECT ';' ;" {
+		t.Errorf("split = %q", got)
+	}
+	if complete("SELECT 'x;") || complete("SELECT 1 /* ; */") || !complete("SELECT 1; -- done") {
+		t.Error("complete misjudges open quotes or comments")
+	}
+}
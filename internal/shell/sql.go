@@ -0,0 +1,70 @@
+package shell
+
+import "strings"
+
+// scan walks SQL text outside quotes, identifiers in brackets and comments,
+// calling at for each byte offset that is plain SQL. It reports whether the
+// text ends inside a quote or a block comment.
+func scan(text string, at func(i int)) (open bool) {
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; {
+		case c == '\'' || c == '"' || c == '`' || c == '[':
+			end := c
+			if c == '[' {
+				end = ']'
+			}
+			j := strings.IndexByte(text[i+1:], end)
+			if j < 0 {
+				return true
+			}
+			i += j + 1
+		case c == '-' && strings.HasPrefix(text[i:], "--"):
+			j := strings.IndexByte(text[i:], '\n')
+			if j < 0 {
+				return false
+			}
+			i += j
+		case c == '/' && strings.HasPrefix(text[i:], "/*"):
+			j := strings.Index(text[i+2:], "*/")
+			if j < 0 {
+				return true
+			}
+			i += j + 3
+		default:
+			at(i)
+		}
+	}
+	return false
+}
+
+// complete reports whether text ends a statement: its last plain SQL is a
+// semicolon.
+func complete(text string) bool {
+	last := -1
+	open := scan(text, func(i int) {
+		if text[i] != ' ' && text[i] != '\t' && text[i] != '\n' && text[i] != '\r' {
+			last = i
+		}
+	})
+	return !open && last >= 0 && text[last] == ';'
+}
+
+// splitStatements splits text at semicolons outside quotes and comments,
+// dropping empty statements.
+func splitStatements(text string) []string {
+	var out []string
+	start := 0
+	add := func(end int) {
+		if stmt := strings.TrimSpace(text[start:end]); stmt != "" && stmt != ";" {
+			out = append(out, stmt)
+		}
+	}
+	scan(text, func(i int) {
+		if text[i] == ';' {
+			add(i + 1)
+			start = i + 1
+		}
+	})
+	add(len(text))
+	return out
+}
@@ -0,0 +1,10 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd)
+
+package shell
+
+import "errors"
+
+// makeRaw is unsupported here; the shell falls back to reading plain lines.
+func makeRaw(fd int) (restore func() error, err error) {
+	return nil, errors.New("raw terminal mode not supported on this platform")
+}
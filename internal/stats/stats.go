@@ -1,13 +1,19 @@
 package stats
 
 import (
+	"encoding/json"
+	"os"
 	"strings"
 	"time"
 )
 
+// FileName is the name of the stats summary inside a run directory.
+const FileName = "stats.json"
+
 type Stats struct {
-	Run  RunStats      `json:"run"`
-	Plan *PlanSnapshot `json:"plan,omitempty"`
+	Run    RunStats         `json:"run"`
+	Plan   *PlanSnapshot    `json:"plan,omitempty"`
+	Counts map[string]int64 `json:"counts,omitempty"` // rows per IR table at finalize
 }
 
 type RunStats struct {
@@ -117,3 +123,35 @@ func (s *Stats) SetPlan(ps *PlanSnapshot) {
 	}
 	s.Plan = ps
 }
+
+func (s *Stats) SetCounts(counts map[string]int64) {
+	if s == nil {
+		return
+	}
+	s.Counts = counts
+}
+
+// ReadFile loads stats written by WriteFile.
+func ReadFile(path string) (*Stats, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var s Stats
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// WriteFile stores the stats as indented JSON at path.
+func (s *Stats) WriteFile(path string) error {
+	if s == nil {
+		return nil
+	}
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0o644)
+}
@@ -0,0 +1,181 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/ChaseHampton/cargoworker/internal/db"
+	"github.com/ChaseHampton/cargoworker/internal/ir"
+)
+
+// migrateLockKey serializes schema migrations of concurrent runs sharing one
+// Postgres database.
+const migrateLockKey = 0x636172676f // "cargo"
+
+// Postgres writes runs into a shared Postgres database. Each run is one
+// project row; fragments are bulk loaded with COPY.
+type Postgres struct {
+	pool *pgxpool.Pool
+
+	mu  sync.Mutex
+	res *resolver
+}
+
+func OpenPostgres(ctx context.Context, dsn string) (*Postgres, error) {
+	pool, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open postgres: %w", err)
+	}
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("ping postgres: %w", err)
+	}
+	return &Postgres{pool: pool, res: newResolver()}, nil
+}
+
+func (p *Postgres) Kind() Kind { return KindPostgres }
+
+// Migrate applies the Postgres translations of the schema migrations. Applied
+// versions are tracked in schema_migrations since Postgres has no
+// user_version.
+func (p *Postgres) Migrate(ctx context.Context) error {
+	return pgx.BeginFunc(ctx, p.pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, int64(migrateLockKey)); err != nil {
+			return fmt.Errorf("migration lock: %w", err)
+		}
+		if _, err := tx.Exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    INTEGER PRIMARY KEY,
+			name       TEXT NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		)`); err != nil {
+			return fmt.Errorf("create schema_migrations: %w", err)
+		}
+
+		var curr int
+		if err := tx.QueryRow(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&curr); err != nil {
+			return fmt.Errorf("get current version: %w", err)
+		}
+		list, err := db.PostgresMigrations(curr)
+		if err != nil {
+			return err
+		}
+		for _, m := range list {
+			if _, err := tx.Exec(ctx, m.SQL); err != nil {
+				return fmt.Errorf("migration %s failed: %w", m.Name, err)
+			}
+			if _, err := tx.Exec(ctx, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, m.Version, m.Name); err != nil {
+				return fmt.Errorf("record migration %s: %w", m.Name, err)
+			}
+		}
+		return nil
+	})
+}
+
+func (p *Postgres) WriteProject(ctx context.Context, proj *ir.Project) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var id int64
+	err := pgx.BeginFunc(ctx, p.pool, func(tx pgx.Tx) error {
+		t, pid, err := p.res.buildProject(ctx, proj, p.alloc(tx))
+		if err != nil {
+			return err
+		}
+		id = pid
+		return copyTable(ctx, tx, t)
+	})
+	if err != nil {
+		return err
+	}
+	p.res.projectID = id
+	return nil
+}
+
+func (p *Postgres) WriteFragment(ctx context.Context, f *ir.Fragment) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var st *staged
+	err := pgx.BeginFunc(ctx, p.pool, func(tx pgx.Tx) error {
+		tables, staged, err := p.res.build(ctx, f, p.alloc(tx))
+		if err != nil {
+			return err
+		}
+		for _, t := range tables {
+			if err := copyTable(ctx, tx, t); err != nil {
+				return err
+			}
+		}
+		st = staged
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	st.apply()
+	return nil
+}
+
+func (p *Postgres) Flush(ctx context.Context) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	tables, st, dropped := p.res.buildPending()
+	err := pgx.BeginFunc(ctx, p.pool, func(tx pgx.Tx) error {
+		for _, t := range tables {
+			if err := copyTable(ctx, tx, t); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	st.apply()
+	p.res.clearPending()
+	return dropped, nil
+}
+
+func (p *Postgres) Close() error {
+	p.pool.Close()
+	return nil
+}
+
+// alloc draws ids from the identity sequences so rows of concurrent runs never
+// collide. The ids are not necessarily contiguous.
+func (p *Postgres) alloc(tx pgx.Tx) allocFunc {
+	return func(ctx context.Context, table string, n int) ([]int64, error) {
+		rows, err := tx.Query(ctx,
+			`SELECT nextval(pg_get_serial_sequence($1, 'id')) FROM generate_series(1, $2)`, table, n)
+		if err != nil {
+			return nil, err
+		}
+		ids, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+		if err != nil {
+			return nil, err
+		}
+		if len(ids) != n {
+			return nil, fmt.Errorf("reserved %d of %d ids", len(ids), n)
+		}
+		return ids, nil
+	}
+}
+
+func copyTable(ctx context.Context, tx pgx.Tx, t *table) error {
+	if len(t.rows) == 0 {
+		return nil
+	}
+	n, err := tx.CopyFrom(ctx, pgx.Identifier{t.name}, t.cols, pgx.CopyFromRows(t.rows))
+	if err != nil {
+		return fmt.Errorf("copy %s: %w", t.name, err)
+	}
+	if int(n) != len(t.rows) {
+		return fmt.Errorf("copy %s: wrote %d of %d rows", t.name, n, len(t.rows))
+	}
+	return nil
+}
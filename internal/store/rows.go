@@ -0,0 +1,564 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/ChaseHampton/cargoworker/internal/ident"
+	"github.com/ChaseHampton/cargoworker/internal/ir"
+)
+
+// table is a batch of rows for one table in column order. Both backends
+// consume the same batches: SQLite through prepared INSERTs, Postgres
+// through COPY. A nil value is written as NULL.
+type table struct {
+	name string
+	cols []string
+	rows [][]any
+}
+
+func (t *table) add(vals ...any) { t.rows = append(t.rows, vals) }
+
+// allocFunc reserves n primary keys for the named table.
+type allocFunc func(ctx context.Context, table string, n int) ([]int64, error)
+
+type pkgInfo struct {
+	id     int64
+	module int64 // container row of the top-level ancestor
+	name   string
+}
+
+// moduleKey identifies a container row. Packs name their top-level
+// containers independently, so the same path may come from two languages.
+type moduleKey struct {
+	language, path string
+}
+
+type symInfo struct {
+	id       int64
+	name     string
+	kind     string
+	exported int
+}
+
+type relKey struct {
+	from, to int64
+	kind     string
+}
+
+// resolver maps IR UUIDs to row ids for everything written so far in the run.
+type resolver struct {
+	projectID int64
+	modules   map[moduleKey]int64 // (container.language, container.module_path) -> container.id
+	packages  map[uuid.UUID]pkgInfo
+	files     map[uuid.UUID]int64
+	symbols   map[uuid.UUID]symInfo
+	relSeen   map[relKey]struct{}
+
+	pendingMembers   []ir.Member
+	pendingRelations []ir.Relation
+}
+
+func newResolver() *resolver {
+	return &resolver{
+		modules:  make(map[moduleKey]int64),
+		packages: make(map[uuid.UUID]pkgInfo),
+		files:    make(map[uuid.UUID]int64),
+		symbols:  make(map[uuid.UUID]symInfo),
+		relSeen:  make(map[relKey]struct{}),
+	}
+}
+
+// staged collects the resolver updates of one fragment. They are applied only
+// after the backend committed the fragment's transaction.
+type staged struct {
+	r        *resolver
+	modules  map[moduleKey]int64
+	packages map[uuid.UUID]pkgInfo
+	files    map[uuid.UUID]int64
+	symbols  map[uuid.UUID]symInfo
+	relSeen  map[relKey]struct{}
+
+	pendingMembers   []ir.Member
+	pendingRelations []ir.Relation
+}
+
+func (r *resolver) stage() *staged {
+	return &staged{
+		r:        r,
+		modules:  make(map[moduleKey]int64),
+		packages: make(map[uuid.UUID]pkgInfo),
+		files:    make(map[uuid.UUID]int64),
+		symbols:  make(map[uuid.UUID]symInfo),
+		relSeen:  make(map[relKey]struct{}),
+	}
+}
+
+func (s *staged) apply() {
+	for k, v := range s.modules {
+		s.r.modules[k] = v
+	}
+	for k, v := range s.packages {
+		s.r.packages[k] = v
+	}
+	for k, v := range s.files {
+		s.r.files[k] = v
+	}
+	for k, v := range s.symbols {
+		s.r.symbols[k] = v
+	}
+	for k := range s.relSeen {
+		s.r.relSeen[k] = struct{}{}
+	}
+	s.r.pendingMembers = append(s.r.pendingMembers, s.pendingMembers...)
+	s.r.pendingRelations = append(s.r.pendingRelations, s.pendingRelations...)
+}
+
+func (s *staged) module(k moduleKey) (int64, bool) {
+	if id, ok := s.modules[k]; ok {
+		return id, true
+	}
+	id, ok := s.r.modules[k]
+	return id, ok
+}
+
+func (s *staged) pkg(id uuid.UUID) (pkgInfo, bool) {
+	if p, ok := s.packages[id]; ok {
+		return p, true
+	}
+	p, ok := s.r.packages[id]
+	return p, ok
+}
+
+func (s *staged) file(id uuid.UUID) (int64, bool) {
+	if f, ok := s.files[id]; ok {
+		return f, true
+	}
+	f, ok := s.r.files[id]
+	return f, ok
+}
+
+func (s *staged) sym(id uuid.UUID) (symInfo, bool) {
+	if v, ok := s.symbols[id]; ok {
+		return v, true
+	}
+	v, ok := s.r.symbols[id]
+	return v, ok
+}
+
+func (s *staged) seenRel(k relKey) bool {
+	if _, ok := s.relSeen[k]; ok {
+		return true
+	}
+	_, ok := s.r.relSeen[k]
+	return ok
+}
+
+func newProjectTable() *table {
+	return &table{name: "project", cols: []string{"id", "uid", "name", "root_path", "created_at", "tool_version", "ir_schema"}}
+}
+
+// batch holds the rows of one fragment, one table per IR record type.
+type batch struct {
+	container, pkg, file, symbol, signature, typeRef, member, relation, pkgImport, diagnostic *table
+}
+
+func newBatch() *batch {
+	t := &batch{}
+	t.container = &table{name: "container", cols: []string{"id", "project_id", "module_path", "language"}}
+	t.pkg = &table{name: "package", cols: []string{"id", "container_id", "parent_id", "uid", "import_path", "name", "doc", "doc_fmt", "language", "kind", "version_tag", "extra_json"}}
+	t.file = &table{name: "file", cols: []string{"id", "container_id", "package_id", "uid", "rel_path", "pkg_name", "is_test", "digest", "size_bytes", "language"}}
+	t.symbol = &table{name: "symbol", cols: []string{"id", "package_id", "file_id", "uid", "kind", "name", "name_terms", "full_name", "recv_type", "type_text", "doc", "doc_raw", "visibility", "flags", "line", "col", "end_line", "end_col", "exported", "extra_json"}}
+	t.signature = &table{name: "signature", cols: []string{"symbol_id", "text", "json", "params_json", "results_json", "type_params_json"}}
+	t.typeRef = &table{name: "type_ref", cols: []string{"symbol_id", "uid", "slot", "target_pkg", "target_name", "kind", "json", "ord"}}
+	t.member = &table{name: "member", cols: []string{"parent_symbol_id", "child_symbol_id", "uid", "name", "exported", "kind", "ord"}}
+	t.relation = &table{name: "relation", cols: []string{"from_symbol_id", "to_symbol_id", "kind", "detail"}}
+	t.pkgImport = &table{name: "pkg_import", cols: []string{"package_id", "path", "alias", "is_stdlib", "details_json"}}
+	t.diagnostic = &table{name: "diagnostic", cols: []string{"uid", "file_id", "scope", "severity", "code", "message", "line", "col"}}
+	return t
+}
+
+// tables lists the batch in foreign-key order.
+func (b *batch) tables() []*table {
+	return []*table{
+		b.container, b.pkg, b.file, b.symbol, b.signature, b.typeRef,
+		b.member, b.relation, b.pkgImport, b.diagnostic,
+	}
+}
+
+// buildProject returns the single project row of a run.
+func (r *resolver) buildProject(ctx context.Context, p *ir.Project, alloc allocFunc) (*table, int64, error) {
+	ids, err := alloc(ctx, "project", 1)
+	if err != nil {
+		return nil, 0, err
+	}
+	created := p.CreatedUtc
+	if created.IsZero() {
+		created = time.Now().UTC()
+	}
+	t := newProjectTable()
+	t.add(ids[0], uidOrNil(p.Id), p.Name, p.RootUri, created, nullStr(p.ToolVersion), nullStr(p.IrSchema))
+	return t, ids[0], nil
+}
+
+// build converts a fragment into table batches in foreign-key order.
+func (r *resolver) build(ctx context.Context, f *ir.Fragment, alloc allocFunc) ([]*table, *staged, error) {
+	if r.projectID == 0 {
+		return nil, nil, fmt.Errorf("store: project must be written before fragments")
+	}
+	st := r.stage()
+	t := newBatch()
+
+	// Containers: parents before children, top-level ones open a container row.
+	order, err := containerOrder(st, f.Containers)
+	if err != nil {
+		return nil, nil, err
+	}
+	var newModules []moduleKey
+	for _, c := range order {
+		if c.ParentId == uuid.Nil {
+			k := moduleKey{c.Language, c.FullName}
+			if _, ok := st.module(k); !ok && !slices.Contains(newModules, k) {
+				newModules = append(newModules, k)
+			}
+		}
+	}
+	moduleIDs, err := allocN(ctx, alloc, "container", len(newModules))
+	if err != nil {
+		return nil, nil, err
+	}
+	for i, k := range newModules {
+		st.modules[k] = moduleIDs[i]
+		t.container.add(moduleIDs[i], r.projectID, k.path, nullStr(k.language))
+	}
+
+	pkgIDs, err := allocN(ctx, alloc, "package", len(order))
+	if err != nil {
+		return nil, nil, err
+	}
+	for i, c := range order {
+		var parent any
+		var module int64
+		if c.ParentId == uuid.Nil {
+			module, _ = st.module(moduleKey{c.Language, c.FullName})
+		} else {
+			p, _ := st.pkg(c.ParentId)
+			parent, module = p.id, p.module
+		}
+		st.packages[c.Id] = pkgInfo{id: pkgIDs[i], module: module, name: c.Name}
+		t.pkg.add(pkgIDs[i], module, parent, c.Id.String(), c.FullName, c.Name,
+			nullStr(c.DocRaw), nullStr(c.DocFmt), nullStr(c.Language), nullStr(c.Kind),
+			nullStr(c.VersionTag), nullStr(c.ExtraJson))
+	}
+
+	fileIDs, err := allocN(ctx, alloc, "file", len(f.Files))
+	if err != nil {
+		return nil, nil, err
+	}
+	for i, fl := range f.Files {
+		p, ok := st.pkg(fl.ContainerId)
+		if !ok {
+			return nil, nil, fmt.Errorf("file %s: unknown container %s", fl.Path, fl.ContainerId)
+		}
+		st.files[fl.Id] = fileIDs[i]
+		t.file.add(fileIDs[i], p.module, p.id, fl.Id.String(), fl.Path, p.name, 0,
+			nullStr(fl.Checksum), fl.SizeBytes, nullStr(fl.Language))
+	}
+
+	// Receiver types and display text come from members and signatures of the
+	// same fragment.
+	owners := make(map[uuid.UUID]uuid.UUID, len(f.Members))
+	for _, m := range f.Members {
+		owners[m.ChildSymbolId] = m.OwnerSymbolId
+	}
+	sigText := make(map[uuid.UUID]string, len(f.Signatures))
+	for _, s := range f.Signatures {
+		sigText[s.SymbolId] = s.Text
+	}
+	names := make(map[uuid.UUID]string, len(f.Symbols))
+	for _, s := range f.Symbols {
+		names[s.Id] = s.Name
+	}
+
+	symIDs, err := allocN(ctx, alloc, "symbol", len(f.Symbols))
+	if err != nil {
+		return nil, nil, err
+	}
+	for i, s := range f.Symbols {
+		p, ok := st.pkg(s.ContainerId)
+		if !ok {
+			return nil, nil, fmt.Errorf("symbol %s: unknown container %s", s.FullName, s.ContainerId)
+		}
+		var fileID any
+		if s.OriginFileId != uuid.Nil {
+			id, ok := st.file(s.OriginFileId)
+			if !ok {
+				return nil, nil, fmt.Errorf("symbol %s: unknown file %s", s.FullName, s.OriginFileId)
+			}
+			fileID = id
+		}
+		var recv any
+		if s.Kind == "method" || s.Kind == "constructor" {
+			if owner, ok := owners[s.Id]; ok {
+				if n, ok := names[owner]; ok {
+					recv = n
+				} else if o, ok := st.sym(owner); ok {
+					recv = o.name
+				}
+			}
+		}
+		exported := 0
+		if s.Visibility == "public" {
+			exported = 1
+		}
+		doc := s.DocFmt
+		if doc == "" {
+			doc = s.DocRaw
+		}
+		st.symbols[s.Id] = symInfo{id: symIDs[i], name: s.Name, kind: s.Kind, exported: exported}
+		t.symbol.add(symIDs[i], p.id, fileID, s.Id.String(), s.Kind, s.Name, ident.Terms(s.Name), nullStr(s.FullName),
+			recv, nullStr(sigText[s.Id]), nullStr(doc), nullStr(s.DocRaw), nullStr(s.Visibility), s.Flags,
+			nullInt(s.StartLine), nullInt(s.StartCol), nullInt(s.EndLine), nullInt(s.EndCol),
+			exported, nullStr(s.ExtraJson))
+	}
+
+	for _, s := range f.Signatures {
+		owner, ok := st.sym(s.SymbolId)
+		if !ok {
+			return nil, nil, fmt.Errorf("signature: unknown symbol %s", s.SymbolId)
+		}
+		params, results, typeParams := splitSignature(s.Json)
+		t.signature.add(owner.id, s.Text, nullStr(s.Json), params, results, typeParams)
+	}
+
+	for _, tr := range f.Typerefs {
+		owner, ok := st.sym(tr.OwnerSymbolId)
+		if !ok {
+			return nil, nil, fmt.Errorf("type_ref %s: unknown symbol %s", tr.Id, tr.OwnerSymbolId)
+		}
+		pkg, name, kind := typeRefTarget(tr.Json)
+		t.typeRef.add(owner.id, tr.Id.String(), nullStr(tr.Slot), pkg, name, kind, nullStr(tr.Json), tr.Order)
+	}
+
+	for _, m := range f.Members {
+		if !st.addMember(t.member, m) {
+			st.pendingMembers = append(st.pendingMembers, m)
+		}
+	}
+	for _, rel := range f.Relations {
+		if !st.addRelation(t.relation, rel) {
+			st.pendingRelations = append(st.pendingRelations, rel)
+		}
+	}
+
+	for _, im := range f.Imports {
+		p, ok := st.pkg(im.ContainerId)
+		if !ok {
+			return nil, nil, fmt.Errorf("import %s: unknown container %s", im.Target, im.ContainerId)
+		}
+		t.pkgImport.add(p.id, im.Target, nullStr(im.Alias), stdlibFlag(im.DetailsJson), nullStr(im.DetailsJson))
+	}
+
+	for _, d := range f.Diagnostics {
+		var fileID any
+		if d.FileId != uuid.Nil {
+			id, ok := st.file(d.FileId)
+			if !ok {
+				return nil, nil, fmt.Errorf("diagnostic %s: unknown file %s", d.Id, d.FileId)
+			}
+			fileID = id
+		}
+		scope := d.Scope
+		if scope == "" {
+			scope = "file"
+		}
+		t.diagnostic.add(uidOrNil(d.Id), fileID, scope, d.Severity, nullStr(d.Code), d.Message, nullInt(d.Line), nullInt(d.Column))
+	}
+
+	return t.tables(), st, nil
+}
+
+// buildPending converts deferred references whose endpoints are now known and
+// returns how many are still unresolved. Unresolved references are dropped.
+func (r *resolver) buildPending() ([]*table, *staged, int) {
+	st := r.stage()
+	t := newBatch()
+	dropped := 0
+	for _, m := range r.pendingMembers {
+		if !st.addMember(t.member, m) {
+			dropped++
+		}
+	}
+	for _, rel := range r.pendingRelations {
+		if !st.addRelation(t.relation, rel) {
+			dropped++
+		}
+	}
+	return []*table{t.member, t.relation}, st, dropped
+}
+
+func (r *resolver) clearPending() {
+	r.pendingMembers = nil
+	r.pendingRelations = nil
+}
+
+func (s *staged) addMember(t *table, m ir.Member) bool {
+	owner, ok := s.sym(m.OwnerSymbolId)
+	if !ok {
+		return false
+	}
+	child, ok := s.sym(m.ChildSymbolId)
+	if !ok {
+		return false
+	}
+	t.add(owner.id, child.id, uidOrNil(m.Id), child.name, child.exported, child.kind, m.Order)
+	return true
+}
+
+func (s *staged) addRelation(t *table, rel ir.Relation) bool {
+	src, ok := s.sym(rel.SourceSymbolId)
+	if !ok {
+		return false
+	}
+	dst, ok := s.sym(rel.DstSymbolId)
+	if !ok {
+		return false
+	}
+	k := relKey{from: src.id, to: dst.id, kind: rel.Relation}
+	if s.seenRel(k) {
+		return true
+	}
+	s.relSeen[k] = struct{}{}
+	t.add(src.id, dst.id, rel.Relation, nullStr(rel.DetailsJson))
+	return true
+}
+
+func containerOrder(st *staged, cs []ir.Container) ([]ir.Container, error) {
+	placed := make(map[uuid.UUID]bool, len(cs))
+	out := make([]ir.Container, 0, len(cs))
+	for len(out) < len(cs) {
+		progress := false
+		for _, c := range cs {
+			if placed[c.Id] {
+				continue
+			}
+			if c.ParentId != uuid.Nil && !placed[c.ParentId] {
+				if _, ok := st.pkg(c.ParentId); !ok {
+					continue
+				}
+			}
+			placed[c.Id] = true
+			out = append(out, c)
+			progress = true
+		}
+		if !progress {
+			for _, c := range cs {
+				if !placed[c.Id] {
+					return nil, fmt.Errorf("container %s: unknown parent %s", c.FullName, c.ParentId)
+				}
+			}
+		}
+	}
+	return out, nil
+}
+
+func allocN(ctx context.Context, alloc allocFunc, table string, n int) ([]int64, error) {
+	if n == 0 {
+		return nil, nil
+	}
+	ids, err := alloc(ctx, table, n)
+	if err != nil {
+		return nil, fmt.Errorf("allocate %s ids: %w", table, err)
+	}
+	return ids, nil
+}
+
+// splitSignature slices the params/results/type_params arrays out of the
+// Signature JSON into the legacy per-part columns.
+func splitSignature(js string) (params, results, typeParams any) {
+	if js == "" {
+		return nil, nil, nil
+	}
+	var parts map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(js), &parts); err != nil {
+		return nil, nil, nil
+	}
+	raw := func(k string) any {
+		if v, ok := parts[k]; ok && string(v) != "null" {
+			return string(v)
+		}
+		return nil
+	}
+	return raw("params"), raw("results"), raw("type_params")
+}
+
+// typeRefTarget extracts the referenced package, name and kind from TypeRef
+// JSON for the indexed type_ref columns.
+func typeRefTarget(js string) (pkg any, name string, kind string) {
+	var ref struct {
+		Type   string `json:"type"`
+		Name   string `json:"name"`
+		Symbol string `json:"symbol"`
+	}
+	_ = json.Unmarshal([]byte(js), &ref)
+	kind = ref.Type
+	if kind == "" {
+		kind = "unknown"
+	}
+	switch {
+	case ref.Symbol != "":
+		p, n := splitQualified(ref.Symbol)
+		return nullStr(p), n, kind
+	case ref.Name != "":
+		return nil, ref.Name, kind
+	default:
+		return nil, kind, kind
+	}
+}
+
+// splitQualified splits a fully-qualified name at its last separator.
+func splitQualified(fqn string) (string, string) {
+	for _, sep := range []string{"::", "."} {
+		if i := strings.LastIndex(fqn, sep); i > 0 {
+			return fqn[:i], fqn[i+len(sep):]
+		}
+	}
+	return "", fqn
+}
+
+func stdlibFlag(details string) int {
+	var d struct {
+		Stdlib bool `json:"stdlib"`
+	}
+	if details != "" && json.Unmarshal([]byte(details), &d) == nil && d.Stdlib {
+		return 1
+	}
+	return 0
+}
+
+func nullStr(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+func nullInt(n int) any {
+	if n == 0 {
+		return nil
+	}
+	return n
+}
+
+func uidOrNil(id uuid.UUID) any {
+	if id == uuid.Nil {
+		return nil
+	}
+	return id.String()
+}
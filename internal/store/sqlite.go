@@ -0,0 +1,161 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ChaseHampton/cargoworker/internal/db"
+	"github.com/ChaseHampton/cargoworker/internal/ir"
+)
+
+// SQLite writes the IR into the per-run docdb.sqlite.
+type SQLite struct {
+	db *sql.DB
+
+	mu     sync.Mutex
+	res    *resolver
+	nextID map[string]int64
+}
+
+func NewSQLite(rdb *sql.DB) *SQLite {
+	return &SQLite{db: rdb, res: newResolver(), nextID: make(map[string]int64)}
+}
+
+func (s *SQLite) Kind() Kind { return KindSQLite }
+
+func (s *SQLite) Migrate(ctx context.Context) error {
+	return db.RunMigrations(ctx, s.db)
+}
+
+func (s *SQLite) WriteProject(ctx context.Context, p *ir.Project) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var id int64
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		t, pid, err := s.res.buildProject(ctx, p, s.alloc(tx))
+		if err != nil {
+			return err
+		}
+		id = pid
+		return insertTable(ctx, tx, t)
+	})
+	if err != nil {
+		return err
+	}
+	s.res.projectID = id
+	return nil
+}
+
+func (s *SQLite) WriteFragment(ctx context.Context, f *ir.Fragment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var st *staged
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		tables, staged, err := s.res.build(ctx, f, s.alloc(tx))
+		if err != nil {
+			return err
+		}
+		for _, t := range tables {
+			if err := insertTable(ctx, tx, t); err != nil {
+				return err
+			}
+		}
+		st = staged
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	st.apply()
+	return nil
+}
+
+func (s *SQLite) Flush(ctx context.Context) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tables, st, dropped := s.res.buildPending()
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		for _, t := range tables {
+			if err := insertTable(ctx, tx, t); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	st.apply()
+	s.res.clearPending()
+	return dropped, nil
+}
+
+// Close is a no-op: the run database belongs to the RunContext.
+func (s *SQLite) Close() error { return nil }
+
+func (s *SQLite) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// alloc hands out ids above the current maximum of each table. Ids burned by a
+// rolled-back fragment are simply skipped.
+func (s *SQLite) alloc(tx *sql.Tx) allocFunc {
+	return func(ctx context.Context, table string, n int) ([]int64, error) {
+		next, ok := s.nextID[table]
+		if !ok {
+			var max int64
+			q := fmt.Sprintf(`SELECT COALESCE(MAX(id), 0) FROM %q`, table)
+			if err := tx.QueryRowContext(ctx, q).Scan(&max); err != nil {
+				return nil, err
+			}
+			next = max + 1
+		}
+		ids := make([]int64, n)
+		for i := range ids {
+			ids[i] = next + int64(i)
+		}
+		s.nextID[table] = next + int64(n)
+		return ids, nil
+	}
+}
+
+func insertTable(ctx context.Context, tx *sql.Tx, t *table) error {
+	if len(t.rows) == 0 {
+		return nil
+	}
+	marks := strings.TrimSuffix(strings.Repeat("?,", len(t.cols)), ",")
+	q := fmt.Sprintf(`INSERT INTO %q (%s) VALUES (%s)`, t.name, strings.Join(t.cols, ", "), marks)
+	stmt, err := tx.PrepareContext(ctx, q)
+	if err != nil {
+		return fmt.Errorf("prepare %s insert: %w", t.name, err)
+	}
+	defer stmt.Close()
+
+	for _, row := range t.rows {
+		for i, v := range row {
+			// All times are stored as UTC ISO-8601 strings.
+			if ts, ok := v.(time.Time); ok {
+				row[i] = ts.UTC().Format(time.RFC3339Nano)
+			}
+		}
+		if _, err := stmt.ExecContext(ctx, row...); err != nil {
+			return fmt.Errorf("insert %s: %w", t.name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/ChaseHampton/cargoworker/internal/ir"
+)
+
+// Kind selects a storage backend.
+type Kind string
+
+const (
+	KindSQLite   Kind = "sqlite"
+	KindPostgres Kind = "postgres"
+)
+
+func ParseKind(s string) (Kind, error) {
+	switch k := Kind(strings.ToLower(strings.TrimSpace(s))); k {
+	case KindSQLite, "":
+		return KindSQLite, nil
+	case KindPostgres, "postgresql", "pg":
+		return KindPostgres, nil
+	default:
+		return "", fmt.Errorf("store: unknown kind %q (want sqlite|postgres)", s)
+	}
+}
+
+// Store persists IR for one run. The project is written first; every fragment
+// is then written in its own transaction. References to records that are not
+// known yet (members and relations crossing containers) are held back and
+// written by Flush once all fragments are in.
+type Store interface {
+	Kind() Kind
+	Migrate(ctx context.Context) error
+	WriteProject(ctx context.Context, p *ir.Project) error
+	WriteFragment(ctx context.Context, f *ir.Fragment) error
+	// Flush writes deferred references and reports how many were dropped
+	// because their endpoints never appeared.
+	Flush(ctx context.Context) (dropped int, err error)
+	Close() error
+}
+
+type Config struct {
+	Kind Kind
+	// DB is the run database for the sqlite backend. It is owned by the
+	// caller and left open by Close.
+	DB *sql.DB
+	// DSN is the connection string for the postgres backend.
+	DSN string
+}
+
+func Open(ctx context.Context, cfg Config) (Store, error) {
+	switch cfg.Kind {
+	case KindSQLite, "":
+		if cfg.DB == nil {
+			return nil, fmt.Errorf("store: sqlite backend needs the run database")
+		}
+		return NewSQLite(cfg.DB), nil
+	case KindPostgres:
+		if strings.TrimSpace(cfg.DSN) == "" {
+			return nil, fmt.Errorf("store: postgres backend needs a DSN")
+		}
+		return OpenPostgres(ctx, cfg.DSN)
+	default:
+		return nil, fmt.Errorf("store: unknown kind %q", cfg.Kind)
+	}
+}
@@ -0,0 +1,188 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/ChaseHampton/cargoworker/internal/db"
+	"github.com/ChaseHampton/cargoworker/internal/ir"
+)
+
+// testFragments returns two containers written as separate fragments where
+// the first one references a symbol of the second.
+func testFragments(projectID uuid.UUID) (*ir.Fragment, *ir.Fragment) {
+	modID, subID := uuid.New(), uuid.New()
+	fileA, fileB := uuid.New(), uuid.New()
+	iface, typ, method := uuid.New(), uuid.New(), uuid.New()
+
+	a := &ir.Fragment{
+		Containers: []ir.Container{{Id: modID, ProjectId: projectID, Language: "go", Name: "mod", FullName: "example.com/mod", Kind: "module"}},
+		Files:      []ir.File{{Id: fileA, ProjectId: projectID, ContainerId: modID, Path: "api.go", Language: "go", SizeBytes: 10}},
+		Symbols: []ir.Symbol{{Id: iface, ContainerId: modID, Name: "Reader", FullName: "example.com/mod.Reader",
+			Kind: "interface", Visibility: "public", OriginFileId: fileA, StartLine: 3, DocRaw: "// Reader reads.", DocFmt: "Reader reads."}},
+		Relations: []ir.Relation{{SourceSymbolId: typ, Relation: "implements", DstSymbolId: iface}},
+	}
+	b := &ir.Fragment{
+		Containers: []ir.Container{{Id: subID, ProjectId: projectID, ParentId: modID, Language: "go", Name: "sub", FullName: "example.com/mod/sub", Kind: "package"}},
+		Files:      []ir.File{{Id: fileB, ProjectId: projectID, ContainerId: subID, Path: "sub/file.go", Language: "go", SizeBytes: 20}},
+		Symbols: []ir.Symbol{
+			{Id: typ, ContainerId: subID, Name: "File", FullName: "example.com/mod/sub.File", Kind: "struct", Visibility: "public", OriginFileId: fileB},
+			{Id: method, ContainerId: subID, Name: "Read", FullName: "example.com/mod/sub.File.Read", Kind: "method", Visibility: "public", OriginFileId: fileB},
+		},
+		Signatures: []ir.Signature{{SymbolId: method, Text: "func (f *File) Read(p []byte) (int, error)",
+			Json: `{"params":[{"name":"p","type":{"type":"slice","elem":{"type":"builtin","name":"byte"}}}],"results":[{"name":"","type":{"type":"builtin","name":"int"}}]}`}},
+		Typerefs: []ir.Typeref{{Id: uuid.New(), OwnerSymbolId: method, Slot: "result:1", Json: `{"type":"named","symbol":"builtin.error"}`, Order: 1}},
+		Members:  []ir.Member{{Id: uuid.New(), OwnerSymbolId: typ, ChildSymbolId: method}},
+		Imports:  []ir.Import{{ContainerId: subID, Target: "io", DetailsJson: `{"stdlib":true}`}},
+		Diagnostics: []ir.Diagnostic{
+			{Id: uuid.New(), Scope: "container", Severity: "warn", Code: "parser", Message: "skipped cgo file"},
+		},
+	}
+	return a, b
+}
+
+func writeRun(t *testing.T, ctx context.Context, s Store) {
+	t.Helper()
+	projectID := uuid.New()
+	if err := s.Migrate(ctx); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if err := s.WriteProject(ctx, &ir.Project{Id: projectID, Name: "mod", RootUri: "/src/mod", ToolVersion: "v1", IrSchema: "v1", CreatedUtc: time.Now().UTC()}); err != nil {
+		t.Fatalf("write project: %v", err)
+	}
+	a, b := testFragments(projectID)
+	if err := s.WriteFragment(ctx, a); err != nil {
+		t.Fatalf("write fragment a: %v", err)
+	}
+	if err := s.WriteFragment(ctx, b); err != nil {
+		t.Fatalf("write fragment b: %v", err)
+	}
+	dropped, err := s.Flush(ctx)
+	if err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	if dropped != 0 {
+		t.Fatalf("flush dropped %d references, want 0", dropped)
+	}
+}
+
+func TestSQLite_WriteRun(t *testing.T) {
+	ctx := context.Background()
+	rdb, err := db.Open(ctx, filepath.Join(t.TempDir(), db.FileName))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer rdb.Close()
+
+	s, err := Open(ctx, Config{Kind: KindSQLite, DB: rdb})
+	if err != nil {
+		t.Fatalf("store: %v", err)
+	}
+	writeRun(t, ctx, s)
+
+	assertCount(t, rdb, `SELECT count(*) FROM container`, 1)
+	assertCount(t, rdb, `SELECT count(*) FROM package WHERE parent_id IS NOT NULL`, 1)
+	assertCount(t, rdb, `SELECT count(*) FROM symbol`, 3)
+	assertCount(t, rdb, `SELECT count(*) FROM symbol WHERE kind = 'method' AND recv_type = 'File'`, 1)
+	assertCount(t, rdb, `SELECT count(*) FROM relation r
+		JOIN symbol f ON f.id = r.from_symbol_id JOIN symbol d ON d.id = r.to_symbol_id
+		WHERE f.name = 'File' AND d.name = 'Reader' AND r.kind = 'implements'`, 1)
+	assertCount(t, rdb, `SELECT count(*) FROM signature WHERE params_json IS NOT NULL AND type_params_json IS NULL`, 1)
+	assertCount(t, rdb, `SELECT count(*) FROM type_ref WHERE target_pkg = 'builtin' AND target_name = 'error'`, 1)
+	assertCount(t, rdb, `SELECT count(*) FROM pkg_import WHERE is_stdlib = 1`, 1)
+	assertCount(t, rdb, `SELECT count(*) FROM diagnostic WHERE file_id IS NULL`, 1)
+	assertCount(t, rdb, `SELECT count(*) FROM search_fts WHERE search_fts MATCH 'reads'`, 1)
+}
+
+// Packs name their top-level containers independently: a Python package and
+// a Rust module may both be util.
+func TestSQLite_SharedModuleName(t *testing.T) {
+	ctx := context.Background()
+	rdb, err := db.Open(ctx, filepath.Join(t.TempDir(), db.FileName))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer rdb.Close()
+
+	s, err := Open(ctx, Config{Kind: KindSQLite, DB: rdb})
+	if err != nil {
+		t.Fatalf("store: %v", err)
+	}
+	if err := s.Migrate(ctx); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	projectID := uuid.New()
+	if err := s.WriteProject(ctx, &ir.Project{Id: projectID, Name: "mixed", RootUri: "/src/mixed"}); err != nil {
+		t.Fatalf("write project: %v", err)
+	}
+	for _, lang := range []string{"python", "rust"} {
+		cid := uuid.New()
+		f := &ir.Fragment{
+			Containers: []ir.Container{{Id: cid, ProjectId: projectID, Language: lang, Name: "util", FullName: "util", Kind: "module"}},
+			Symbols:    []ir.Symbol{{Id: uuid.New(), ContainerId: cid, Name: "helper", FullName: "util.helper", Kind: "function", Visibility: "public"}},
+		}
+		if err := s.WriteFragment(ctx, f); err != nil {
+			t.Fatalf("write %s fragment: %v", lang, err)
+		}
+	}
+
+	assertCount(t, rdb, `SELECT count(*) FROM container WHERE module_path = 'util'`, 2)
+	assertCount(t, rdb, `SELECT count(DISTINCT c.language) FROM package p JOIN container c ON c.id = p.container_id`, 2)
+	assertCount(t, rdb, `SELECT count(*) FROM package WHERE import_path = 'util'`, 2)
+}
+
+func TestPostgres_WriteRun(t *testing.T) {
+	dsn := os.Getenv("CARGOWORKER_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("CARGOWORKER_TEST_POSTGRES_DSN not set (docker compose up db)")
+	}
+	ctx := context.Background()
+	s, err := Open(ctx, Config{Kind: KindPostgres, DSN: dsn})
+	if err != nil {
+		t.Fatalf("store: %v", err)
+	}
+	defer s.Close()
+	writeRun(t, ctx, s)
+
+	pg := s.(*Postgres)
+	var n int
+	err = pg.pool.QueryRow(ctx, `SELECT count(*) FROM search_fts f
+		JOIN symbol s ON s.id = f.symbol_id JOIN package p ON p.id = s.package_id
+		JOIN container c ON c.id = p.container_id
+		WHERE c.project_id = $1 AND f.tsv @@ plainto_tsquery('english', 'reads')`, pg.res.projectID).Scan(&n)
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("tsvector search found %d symbols, want 1", n)
+	}
+}
+
+func TestParseKind(t *testing.T) {
+	for in, want := range map[string]Kind{"": KindSQLite, "SQLite": KindSQLite, "postgres": KindPostgres, "pg": KindPostgres} {
+		got, err := ParseKind(in)
+		if err != nil || got != want {
+			t.Fatalf("ParseKind(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParseKind("mysql"); err == nil {
+		t.Fatalf("expected error for unknown kind")
+	}
+}
+
+func assertCount(t *testing.T, rdb *sql.DB, q string, want int) {
+	t.Helper()
+	var n int
+	if err := rdb.QueryRow(q).Scan(&n); err != nil {
+		t.Fatalf("%s: %v", q, err)
+	}
+	if n != want {
+		t.Fatalf("%s = %d, want %d", q, n, want)
+	}
+}
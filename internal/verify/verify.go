@@ -0,0 +1,277 @@
+package verify
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/ChaseHampton/cargoworker/internal/db"
+	"github.com/ChaseHampton/cargoworker/internal/manifest"
+	"github.com/ChaseHampton/cargoworker/internal/stats"
+)
+
+// maxDetails bounds the sample rows reported per failed check.
+const maxDetails = 20
+
+// Report is the machine-readable outcome of Run.
+type Report struct {
+	RunDir   string  `json:"run_dir,omitempty"`
+	Database string  `json:"database"`
+	OK       bool    `json:"ok"`
+	Failed   int     `json:"failed"`
+	Checks   []Check `json:"checks"`
+}
+
+type Check struct {
+	Name    string   `json:"name"`
+	OK      bool     `json:"ok"`
+	Skipped bool     `json:"skipped,omitempty"`
+	Count   int64    `json:"count"` // offending rows
+	Details []string `json:"details,omitempty"`
+}
+
+// Run checks the run database at dbPath. runDir may be empty when a bare
+// database is verified, in which case the stats, manifest and read-only
+// checks are skipped; they are skipped too for a run whose manifest says its
+// IR went to postgres, as its run database holds none. A failing check is
+// reported, not returned as an error; the error is reserved for checks that
+// could not be executed.
+func Run(ctx context.Context, rdb *sql.DB, runDir, dbPath string) (*Report, error) {
+	r := &Report{RunDir: runDir, Database: dbPath}
+	if storedIn(runDir) == "postgres" {
+		runDir = ""
+	}
+
+	checks := []func(context.Context, *sql.DB) (Check, error){
+		integrity,
+		foreignKeys,
+		symbolsWithoutFile,
+		danglingRelations,
+		ftsSync,
+		func(ctx context.Context, rdb *sql.DB) (Check, error) { return statsCounts(ctx, rdb, runDir) },
+		func(ctx context.Context, rdb *sql.DB) (Check, error) { return manifestDigest(runDir, dbPath) },
+		func(ctx context.Context, rdb *sql.DB) (Check, error) { return readOnly(runDir, dbPath) },
+	}
+	for _, fn := range checks {
+		c, err := fn(ctx, rdb)
+		if err != nil {
+			return nil, fmt.Errorf("verify %s: %w", c.Name, err)
+		}
+		if !c.OK {
+			r.Failed++
+		}
+		r.Checks = append(r.Checks, c)
+	}
+	r.OK = r.Failed == 0
+	return r, nil
+}
+
+func integrity(ctx context.Context, rdb *sql.DB) (Check, error) {
+	c := Check{Name: "integrity_check"}
+	rows, err := rdb.QueryContext(ctx, `PRAGMA integrity_check;`)
+	if err != nil {
+		return c, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var msg string
+		if err := rows.Scan(&msg); err != nil {
+			return c, err
+		}
+		if msg != "ok" {
+			c.add(msg)
+		}
+	}
+	c.OK = c.Count == 0
+	return c, rows.Err()
+}
+
+func foreignKeys(ctx context.Context, rdb *sql.DB) (Check, error) {
+	c := Check{Name: "foreign_key_check"}
+	rows, err := rdb.QueryContext(ctx, `PRAGMA foreign_key_check;`)
+	if err != nil {
+		return c, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			table, parent string
+			rowid, fkid   sql.NullInt64
+		)
+		if err := rows.Scan(&table, &rowid, &parent, &fkid); err != nil {
+			return c, err
+		}
+		c.add(fmt.Sprintf("%s rowid %d references missing %s", table, rowid.Int64, parent))
+	}
+	c.OK = c.Count == 0
+	return c, rows.Err()
+}
+
+func symbolsWithoutFile(ctx context.Context, rdb *sql.DB) (Check, error) {
+	return sampleQuery(ctx, rdb, "symbols_without_file", `
+		SELECT 'symbol ' || s.id || ' ' || COALESCE(s.full_name, s.name) ||
+		       CASE WHEN s.file_id IS NULL THEN ' has no file' ELSE ' -> missing file ' || s.file_id END
+		FROM symbol s
+		LEFT JOIN file f ON f.id = s.file_id
+		WHERE f.id IS NULL
+		ORDER BY s.id;`)
+}
+
+func danglingRelations(ctx context.Context, rdb *sql.DB) (Check, error) {
+	return sampleQuery(ctx, rdb, "dangling_relations", `
+		SELECT 'relation ' || r.id || ' ' || r.kind || ': ' ||
+		       CASE WHEN a.id IS NULL THEN 'missing source ' || r.from_symbol_id ELSE 'source ok' END || ', ' ||
+		       CASE WHEN b.id IS NULL THEN 'missing target ' || r.to_symbol_id ELSE 'target ok' END
+		FROM relation r
+		LEFT JOIN symbol a ON a.id = r.from_symbol_id
+		LEFT JOIN symbol b ON b.id = r.to_symbol_id
+		WHERE a.id IS NULL OR b.id IS NULL
+		ORDER BY r.id;`)
+}
+
+// ftsSync compares the rows indexed in search_fts with the symbol table. The
+// FTS5 docsize shadow table holds one row per indexed document.
+func ftsSync(ctx context.Context, rdb *sql.DB) (Check, error) {
+	return sampleQuery(ctx, rdb, "fts_sync", `
+		SELECT 'symbol ' || s.id || ' not indexed'
+		FROM symbol s
+		WHERE s.id NOT IN (SELECT id FROM search_fts_docsize)
+		UNION ALL
+		SELECT 'search_fts row ' || d.id || ' has no symbol'
+		FROM search_fts_docsize d
+		WHERE d.id NOT IN (SELECT id FROM symbol);`)
+}
+
+func statsCounts(ctx context.Context, rdb *sql.DB, runDir string) (Check, error) {
+	c := Check{Name: "stats_counts"}
+	if runDir == "" {
+		c.OK, c.Skipped = true, true
+		return c, nil
+	}
+	st, err := stats.ReadFile(filepath.Join(runDir, stats.FileName))
+	if errors.Is(err, os.ErrNotExist) {
+		c.add(stats.FileName + " not found")
+		return c, nil
+	}
+	if err != nil {
+		c.add(fmt.Sprintf("read %s: %v", stats.FileName, err))
+		return c, nil
+	}
+	if st.Counts == nil {
+		c.add(stats.FileName + " has no row counts")
+		return c, nil
+	}
+	actual, err := db.TableCounts(ctx, rdb)
+	if err != nil {
+		return c, err
+	}
+	tables := make([]string, 0, len(actual))
+	for t := range actual {
+		tables = append(tables, t)
+	}
+	sort.Strings(tables)
+	for _, t := range tables {
+		if want := st.Counts[t]; want != actual[t] {
+			c.add(fmt.Sprintf("%s: stats.json %d, database %d", t, want, actual[t]))
+		}
+	}
+	c.OK = c.Count == 0
+	return c, nil
+}
+
+func manifestDigest(runDir, dbPath string) (Check, error) {
+	c := Check{Name: "manifest_digest"}
+	if runDir == "" {
+		c.OK, c.Skipped = true, true
+		return c, nil
+	}
+	m, err := manifest.Read(filepath.Join(runDir, manifest.FileName))
+	if errors.Is(err, os.ErrNotExist) {
+		c.add(manifest.FileName + " not found; run was not finalized")
+		return c, nil
+	}
+	if err != nil {
+		c.add(err.Error())
+		return c, nil
+	}
+	if m.Database == nil {
+		c.add(manifest.FileName + " has no database digest")
+		return c, nil
+	}
+	rel, err := filepath.Rel(runDir, dbPath)
+	if err != nil {
+		return c, err
+	}
+	got, err := manifest.Digest(runDir, rel)
+	if err != nil {
+		return c, err
+	}
+	if got.SHA256 != m.Database.SHA256 || got.Bytes != m.Database.Bytes {
+		c.add(fmt.Sprintf("%s: manifest sha256 %s (%d bytes), file sha256 %s (%d bytes)",
+			rel, m.Database.SHA256, m.Database.Bytes, got.SHA256, got.Bytes))
+	}
+	c.OK = c.Count == 0
+	return c, nil
+}
+
+// readOnly checks that finalize sealed the run database: no one may write to
+// the file.
+func readOnly(runDir, dbPath string) (Check, error) {
+	c := Check{Name: "read_only"}
+	if runDir == "" {
+		c.OK, c.Skipped = true, true
+		return c, nil
+	}
+	fi, err := os.Stat(dbPath)
+	if err != nil {
+		return c, err
+	}
+	if perm := fi.Mode().Perm(); perm&0o222 != 0 {
+		c.add(fmt.Sprintf("%s is writable (mode %v); run was not sealed", filepath.Base(dbPath), perm))
+	}
+	c.OK = c.Count == 0
+	return c, nil
+}
+
+// storedIn returns the store the manifest in runDir names, or "" when there is
+// none to read.
+func storedIn(runDir string) string {
+	if runDir == "" {
+		return ""
+	}
+	m, err := manifest.Read(filepath.Join(runDir, manifest.FileName))
+	if err != nil {
+		return ""
+	}
+	return m.Store
+}
+
+// sampleQuery runs a query yielding one description per offending row.
+func sampleQuery(ctx context.Context, rdb *sql.DB, name, q string) (Check, error) {
+	c := Check{Name: name}
+	rows, err := rdb.QueryContext(ctx, q)
+	if err != nil {
+		return c, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var msg string
+		if err := rows.Scan(&msg); err != nil {
+			return c, err
+		}
+		c.add(msg)
+	}
+	c.OK = c.Count == 0
+	return c, rows.Err()
+}
+
+func (c *Check) add(detail string) {
+	c.Count++
+	if len(c.Details) < maxDetails {
+		c.Details = append(c.Details, detail)
+	}
+}
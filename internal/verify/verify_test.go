@@ -0,0 +1,151 @@
+package verify
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/ChaseHampton/cargoworker/internal/db"
+	"github.com/ChaseHampton/cargoworker/internal/ir"
+	"github.com/ChaseHampton/cargoworker/internal/manifest"
+	"github.com/ChaseHampton/cargoworker/internal/stats"
+	"github.com/ChaseHampton/cargoworker/internal/store"
+)
+
+// seedRun writes a small finalized run the way index does.
+func seedRun(t *testing.T, ctx context.Context, dir string) *sql.DB {
+	t.Helper()
+	rdb, err := db.Open(ctx, filepath.Join(dir, db.FileName))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	st := store.NewSQLite(rdb)
+	if err := st.Migrate(ctx); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if err := st.WriteProject(ctx, &ir.Project{Id: uuid.New(), Name: "p", RootUri: "/src"}); err != nil {
+		t.Fatalf("project: %v", err)
+	}
+	c, f, a, b := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	err = st.WriteFragment(ctx, &ir.Fragment{
+		Containers: []ir.Container{{Id: c, Name: "p", FullName: "p", Kind: "package"}},
+		Files:      []ir.File{{Id: f, ContainerId: c, Path: "p.go"}},
+		Symbols: []ir.Symbol{
+			{Id: a, ContainerId: c, Name: "A", Kind: "struct", OriginFileId: f},
+			{Id: b, ContainerId: c, Name: "B", Kind: "interface", OriginFileId: f},
+		},
+		Relations: []ir.Relation{{SourceSymbolId: a, Relation: "implements", DstSymbolId: b}},
+	})
+	if err != nil {
+		t.Fatalf("fragment: %v", err)
+	}
+	if err := db.Finalize(ctx, rdb, db.FinalizeOptions{}); err != nil {
+		t.Fatalf("finalize: %v", err)
+	}
+	counts, err := db.TableCounts(ctx, rdb)
+	if err != nil {
+		t.Fatalf("counts: %v", err)
+	}
+	s := stats.New()
+	s.SetCounts(counts)
+	if err := s.WriteFile(filepath.Join(dir, stats.FileName)); err != nil {
+		t.Fatalf("stats: %v", err)
+	}
+	m := &manifest.Manifest{}
+	if m.Database, err = manifest.Digest(dir, db.FileName); err != nil {
+		t.Fatalf("digest: %v", err)
+	}
+	if err := m.Write(filepath.Join(dir, manifest.FileName)); err != nil {
+		t.Fatalf("manifest: %v", err)
+	}
+	return rdb
+}
+
+func TestRun_CleanAndCorrupted(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, db.FileName)
+	rdb := seedRun(t, ctx, dir)
+
+	report, err := Run(ctx, rdb, dir, dbPath)
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if !report.OK {
+		t.Fatalf("expected clean run to pass, got %+v", report.Checks)
+	}
+
+	// Unseal the run and bypass foreign keys to leave a relation pointing at
+	// a missing symbol.
+	if err := os.Chmod(dbPath, 0o644); err != nil {
+		t.Fatalf("chmod: %v", err)
+	}
+	if _, err := rdb.ExecContext(ctx, `PRAGMA query_only=OFF;`); err != nil {
+		t.Fatalf("query_only off: %v", err)
+	}
+	if _, err := rdb.ExecContext(ctx, `PRAGMA foreign_keys=OFF;`); err != nil {
+		t.Fatalf("fk off: %v", err)
+	}
+	if _, err := rdb.ExecContext(ctx, `INSERT INTO relation(from_symbol_id, to_symbol_id, kind) VALUES (1, 999, 'references');`); err != nil {
+		t.Fatalf("corrupt: %v", err)
+	}
+
+	report, err = Run(ctx, rdb, dir, dbPath)
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if report.OK {
+		t.Fatalf("expected corrupted run to fail")
+	}
+	failed := map[string]bool{}
+	for _, c := range report.Checks {
+		if !c.OK {
+			failed[c.Name] = true
+		}
+	}
+	for _, name := range []string{"foreign_key_check", "dangling_relations", "stats_counts", "manifest_digest", "read_only"} {
+		if !failed[name] {
+			t.Errorf("expected %s to fail; report: %+v", name, report.Checks)
+		}
+	}
+	if failed["fts_sync"] || failed["symbols_without_file"] {
+		t.Errorf("unexpected failures: %+v", report.Checks)
+	}
+}
+
+func TestRun_PostgresRun(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, db.FileName)
+	rdb, err := db.Open(ctx, dbPath)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if err := db.RunMigrations(ctx, rdb); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if err := stats.New().WriteFile(filepath.Join(dir, stats.FileName)); err != nil {
+		t.Fatalf("stats: %v", err)
+	}
+	m := &manifest.Manifest{Store: string(store.KindPostgres)}
+	if err := m.Write(filepath.Join(dir, manifest.FileName)); err != nil {
+		t.Fatalf("manifest: %v", err)
+	}
+
+	report, err := Run(ctx, rdb, dir, dbPath)
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if !report.OK {
+		t.Fatalf("expected a postgres run to pass, got %+v", report.Checks)
+	}
+	for _, c := range report.Checks {
+		if (c.Name == "stats_counts" || c.Name == "manifest_digest" || c.Name == "read_only") && !c.Skipped {
+			t.Errorf("%s checked the run database of a postgres run", c.Name)
+		}
+	}
+}